@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/agent"
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/report"
+	"github.com/junenu/solo-hackathon/day006_pingood-go/pingood"
+)
+
+func runAgent(args []string) {
+	fs := flag.NewFlagSet("agent", flag.ExitOnError)
+	var (
+		coordinatorURL string
+		name           string
+		token          string
+		iface          string
+		caFile         string
+	)
+	hostname, _ := os.Hostname()
+	fs.StringVar(&coordinatorURL, "coordinator", "", "Coordinator URL, e.g. https://pingood.example.com:8443")
+	fs.StringVar(&name, "name", hostname, "Name this agent registers under")
+	fs.StringVar(&token, "token", os.Getenv("PINGOOD_TOKEN"), "Shared token for the coordinator (default $PINGOOD_TOKEN)")
+	fs.StringVar(&iface, "i", pingood.DefaultInterface(), "Network interface to check")
+	fs.StringVar(&caFile, "ca", "", "PEM CA bundle used to verify the coordinator certificate")
+	fs.Parse(args)
+
+	if coordinatorURL == "" {
+		log.Fatalf("Error: -coordinator is required")
+	}
+
+	client := &http.Client{Timeout: 2 * time.Minute}
+	if caFile != "" {
+		pool, err := loadCertPool(caFile)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		client.Transport = &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}
+	}
+
+	a := &agent.Agent{
+		CoordinatorURL: coordinatorURL,
+		Token:          token,
+		Client:         client,
+		Registration: agent.Registration{
+			Name:      name,
+			Platform:  fmt.Sprintf("%s/%s", runtime.GOOS, runtime.GOARCH),
+			Interface: iface,
+		},
+		Run: func(ctx context.Context, cfg *pingood.Config, emit func(report.Section)) (*report.Report, error) {
+			runner := &pingood.Runner{Config: cfg, Interface: iface}
+			return runner.Run(ctx, emit)
+		},
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := a.Serve(ctx); err != nil && !errors.Is(err, context.Canceled) {
+		log.Fatalf("Error: %v", err)
+	}
+}
+
+func runCoordinator(args []string) {
+	fs := flag.NewFlagSet("coordinator", flag.ExitOnError)
+	var (
+		listen     string
+		configPath string
+		agentList  string
+		token      string
+		insecure   bool
+		tlsCert    string
+		tlsKey     string
+		wait       time.Duration
+		timeout    time.Duration
+	)
+	fs.StringVar(&listen, "listen", ":8080", "Address to accept agent connections on")
+	fs.StringVar(&configPath, "c", "conf.yaml", "Path to configuration file sent to agents")
+	fs.StringVar(&agentList, "agents", "", "Comma-separated agent names to run on (default: every agent that registers)")
+	fs.StringVar(&token, "token", os.Getenv("PINGOOD_TOKEN"), "Shared token agents must present (default $PINGOOD_TOKEN)")
+	fs.BoolVar(&insecure, "insecure", false, "Accept agents without a token, e.g. on a trusted test network")
+	fs.StringVar(&tlsCert, "tls-cert", "", "TLS certificate file; enables HTTPS together with -tls-key")
+	fs.StringVar(&tlsKey, "tls-key", "", "TLS private key file")
+	fs.DurationVar(&wait, "wait", 30*time.Second, "How long to wait for agents to register")
+	fs.DurationVar(&timeout, "timeout", 10*time.Minute, "How long to wait for agent results")
+	fs.Parse(args)
+
+	if token == "" && !insecure {
+		log.Fatalf("Error: a token is required: set -token or $PINGOOD_TOKEN, or pass -insecure to accept any agent")
+	}
+	cfg, err := pingood.LoadConfig(configPath)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	coordinator := agent.NewCoordinator(token)
+	coordinator.Insecure = insecure
+	server := &http.Server{Addr: listen, Handler: coordinator}
+	go func() {
+		var err error
+		if tlsCert != "" || tlsKey != "" {
+			err = server.ListenAndServeTLS(tlsCert, tlsKey)
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Error: %v", err)
+		}
+	}()
+	defer server.Close()
+
+	var selected []string
+	if agentList != "" {
+		selected = strings.Split(agentList, ",")
+	}
+
+	fmt.Printf("Waiting up to %s for agents on %s...\n", wait, listen)
+	waitCtx, cancelWait := context.WithTimeout(context.Background(), wait)
+	names, err := coordinator.WaitForAgents(waitCtx, selected)
+	cancelWait()
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	fmt.Printf("Dispatching to %s\n\n", strings.Join(names, ", "))
+
+	var mu sync.Mutex
+	runCtx, cancelRun := context.WithTimeout(context.Background(), timeout)
+	defer cancelRun()
+	reports, agentErrors, err := coordinator.Dispatch(runCtx, cfg, names, func(name string, section report.Section) {
+		mu.Lock()
+		defer mu.Unlock()
+		icon := section.Status().Icon()
+		if icon == "" {
+			icon = "ℹ️ "
+		}
+		fmt.Printf("[%s] %s %s\n", name, icon, section.Title)
+	})
+	if err != nil {
+		fmt.Printf("⚠️  %v\n", err)
+	}
+
+	var ordered []*report.Report
+	for _, name := range names {
+		if rep, ok := reports[name]; ok {
+			ordered = append(ordered, rep)
+		}
+	}
+
+	fmt.Println()
+	fmt.Println("=== Comparison ===")
+	report.WriteComparison(os.Stdout, ordered)
+	for _, name := range names {
+		if msg, ok := agentErrors[name]; ok {
+			fmt.Printf("❌ %s: %s\n", name, msg)
+		}
+	}
+}
+
+func loadCertPool(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+	return pool, nil
+}
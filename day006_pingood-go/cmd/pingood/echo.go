@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/middlebox"
+)
+
+func runEchoServer(args []string) {
+	fs := flag.NewFlagSet("echo-server", flag.ExitOnError)
+	var (
+		host    string
+		ports   string
+		timeout time.Duration
+	)
+	fs.StringVar(&host, "host", "", "Address to listen on (default: all)")
+	fs.StringVar(&ports, "ports", "tcp/8443", "Comma-separated ports to answer on, e.g. tcp/8443,tcp/25,udp/53")
+	fs.DurationVar(&timeout, "timeout", 10*time.Second, "Longest a client may take to send its probe")
+	fs.Parse(args)
+
+	server := &middlebox.EchoServer{Timeout: timeout}
+	errs := make(chan error)
+	for _, s := range strings.Split(ports, ",") {
+		port, err := middlebox.ParsePort(s)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		address := net.JoinHostPort(host, strconv.Itoa(port.Port))
+		if port.Protocol == "udp" {
+			pc, err := net.ListenPacket("udp", address)
+			if err != nil {
+				log.Fatalf("Error: %v", err)
+			}
+			fmt.Printf("Echo endpoint: udp://%s\n", pc.LocalAddr())
+			go func() { errs <- server.ServeUDP(pc) }()
+			continue
+		}
+		ln, err := net.Listen("tcp", address)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		fmt.Printf("Echo endpoint: tcp://%s\n", ln.Addr())
+		go func() { errs <- server.ServeTCP(ln) }()
+	}
+
+	if err := <-errs; err != nil && !errors.Is(err, net.ErrClosed) {
+		log.Fatalf("Error: %v", err)
+	}
+}
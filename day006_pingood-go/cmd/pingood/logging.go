@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/checker"
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/evidence"
+)
+
+// setupLogging sends the checker's log to stderr at level and, when an
+// evidence bundle is being written, everything down to debug into it.
+func setupLogging(level string, bundle *evidence.Bundle) error {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("invalid -log-level %q: %w", level, err)
+	}
+	handlers := teeHandler{slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: lvl})}
+	if bundle != nil {
+		handlers = append(handlers, bundle.Handler())
+	}
+	checker.SetLogger(slog.New(handlers))
+	return nil
+}
+
+// teeHandler passes each record to every handler that wants its level.
+type teeHandler []slog.Handler
+
+func (t teeHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range t {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t teeHandler) Handle(ctx context.Context, r slog.Record) error {
+	var errs []string
+	for _, h := range t {
+		if !h.Enabled(ctx, r.Level) {
+			continue
+		}
+		if err := h.Handle(ctx, r.Clone()); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to write log: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func (t teeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(teeHandler, len(t))
+	for i, h := range t {
+		handlers[i] = h.WithAttrs(attrs)
+	}
+	return handlers
+}
+
+func (t teeHandler) WithGroup(name string) slog.Handler {
+	handlers := make(teeHandler, len(t))
+	for i, h := range t {
+		handlers[i] = h.WithGroup(name)
+	}
+	return handlers
+}
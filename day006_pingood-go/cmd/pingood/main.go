@@ -1,227 +1,181 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
-	"runtime"
+	"os"
 	"time"
 
 	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/checker"
-	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/config"
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/evidence"
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/otlp"
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/report"
+	"github.com/junenu/solo-hackathon/day006_pingood-go/pingood"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "agent":
+			runAgent(os.Args[2:])
+			return
+		case "coordinator":
+			runCoordinator(os.Args[2:])
+			return
+		case "throughput-server":
+			runThroughputServer(os.Args[2:])
+			return
+		case "echo-server":
+			runEchoServer(os.Args[2:])
+			return
+		case "schedule":
+			runSchedule(os.Args[2:])
+			return
+		case "status":
+			runStatus(os.Args[2:])
+			return
+		case "otlp-collector":
+			runOTLPCollector(os.Args[2:])
+			return
+		}
+	}
+
 	var (
-		iface      string
-		configPath string
+		iface       string
+		configPath  string
+		recordDir   string
+		replayDir   string
+		htmlPath    string
+		historyDir  string
+		logLevel    string
+		evidenceDir string
+		captureDir  string
 	)
 
-	flag.StringVar(&iface, "i", getDefaultInterface(), "Network interface to check")
+	flag.StringVar(&iface, "i", pingood.DefaultInterface(), "Network interface to check")
 	flag.StringVar(&configPath, "c", "conf.yaml", "Path to configuration file")
+	flag.StringVar(&recordDir, "record", "", "Record external command output as fixtures into this directory")
+	flag.StringVar(&replayDir, "replay", "", "Replay external command output from fixtures in this directory")
+	flag.StringVar(&htmlPath, "html", "", "Also write a self-contained HTML report to this file")
+	flag.StringVar(&historyDir, "history", "", "Keep every run as JSON in this directory and chart earlier runs in the HTML report")
+	flag.StringVar(&logLevel, "log-level", "warn", "Log checks to stderr at this level: debug, info, warn or error")
+	flag.StringVar(&evidenceDir, "evidence", "", "Save raw command output, the debug log and every section into a timestamped tar.gz bundle in this directory")
+	flag.StringVar(&captureDir, "capture", "", "Capture the packets of each check into a pcap file per check in this directory (Linux, needs root or CAP_NET_RAW)")
 	flag.Parse()
 
-	cfg, err := config.LoadConfig(configPath)
+	cfg, err := pingood.LoadConfig(configPath)
 	if err != nil {
 		log.Printf("Warning: Failed to load config file: %v. Using default configuration.", err)
-		cfg = config.DefaultConfig()
+		cfg = pingood.DefaultConfig()
 	}
 
-	netChecker := checker.New()
-
-	fmt.Printf("=== Network Diagnostics Tool (pingood-go) ===\n")
-	fmt.Printf("Platform: %s/%s\n", runtime.GOOS, runtime.GOARCH)
-	fmt.Printf("Interface: %s\n", iface)
-	fmt.Printf("Time: %s\n\n", time.Now().Format("2006-01-02 15:04:05"))
-
-	runDiagnostics(netChecker, cfg, iface)
-}
-
-func getDefaultInterface() string {
-	switch runtime.GOOS {
-	case "darwin":
-		return "en0"
-	case "linux":
-		return "eth0"
-	default:
-		return "eth0"
-	}
-}
-
-func runDiagnostics(nc checker.NetChecker, cfg *config.Config, iface string) {
-	fmt.Println("1. IP Address Check")
-	fmt.Println("==================")
-	ipv4, ipv6, err := nc.GetIPAddresses(iface)
+	commands, err := newCommandRunner(recordDir, replayDir)
 	if err != nil {
-		fmt.Printf("❌ Failed to get IP addresses: %v\n", err)
-	} else {
-		if ipv4 != "" {
-			fmt.Printf("✅ IPv4: %s\n", ipv4)
-		} else {
-			fmt.Printf("❌ IPv4: Not found\n")
-		}
-		if ipv6 != "" {
-			fmt.Printf("✅ IPv6: %s\n", ipv6)
-		} else {
-			fmt.Printf("❌ IPv6: Not found\n")
-		}
+		log.Fatalf("Error: %v", err)
 	}
-	fmt.Println()
+	capture := checker.NewCapturingRunner(commands)
 
-	fmt.Println("2. Default Gateway Check")
-	fmt.Println("========================")
-	gateway, err := nc.GetDefaultGateway(iface)
+	exporter, err := newOTLPExporter(cfg, iface)
 	if err != nil {
-		fmt.Printf("❌ Failed to get default gateway: %v\n", err)
-	} else {
-		fmt.Printf("✅ Gateway: %s\n", gateway)
+		log.Fatalf("Error: %v", err)
 	}
-	fmt.Println()
 
-	fmt.Println("3. ICMP Ping Test (IPv4)")
-	fmt.Println("========================")
-	pingResults, err := nc.PingTest(cfg.PingTargetsIPv4, cfg.PingCount, cfg.PingInterval, false)
-	if err != nil {
-		fmt.Printf("❌ Ping test failed: %v\n", err)
-	} else {
-		for _, result := range pingResults {
-			if result.Success {
-				fmt.Printf("✅ %s: %.1f%% packet loss, RTT min/avg/max = %.1f/%.1f/%.1f ms\n",
-					result.Target, result.PacketLoss,
-					float64(result.MinRTT)/float64(time.Millisecond),
-					float64(result.AvgRTT)/float64(time.Millisecond),
-					float64(result.MaxRTT)/float64(time.Millisecond))
-			} else {
-				fmt.Printf("❌ %s: Failed", result.Target)
-				if result.Error != nil {
-					fmt.Printf(" - %v", result.Error)
-				}
-				fmt.Println()
-			}
+	var bundle *evidence.Bundle
+	if evidenceDir != "" {
+		if bundle, err = evidence.New(evidenceDir, time.Now()); err != nil {
+			log.Fatalf("Error: %v", err)
 		}
 	}
-	fmt.Println()
+	if err := setupLogging(logLevel, bundle); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
 
-	fmt.Println("4. ICMP Ping Test (IPv6)")
-	fmt.Println("========================")
-	pingResults6, err := nc.PingTest(cfg.PingTargetsIPv6, cfg.PingCount, cfg.PingInterval, true)
+	runner := &pingood.Runner{Config: cfg, Interface: iface, Commands: capture, CaptureDir: captureDir}
+	diagnostics, err := runner.Start(context.Background())
 	if err != nil {
-		fmt.Printf("❌ IPv6 ping test failed: %v\n", err)
-	} else {
-		for _, result := range pingResults6 {
-			if result.Success {
-				fmt.Printf("✅ %s: %.1f%% packet loss, RTT min/avg/max = %.1f/%.1f/%.1f ms\n",
-					result.Target, result.PacketLoss,
-					float64(result.MinRTT)/float64(time.Millisecond),
-					float64(result.AvgRTT)/float64(time.Millisecond),
-					float64(result.MaxRTT)/float64(time.Millisecond))
-			} else {
-				fmt.Printf("❌ %s: Failed", result.Target)
-				if result.Error != nil {
-					fmt.Printf(" - %v", result.Error)
-				}
-				fmt.Println()
+		log.Fatalf("Error: %v", err)
+	}
+	header := diagnostics.Header()
+	header.WriteHeader(os.Stdout)
+	run := otlp.NewRun("pingood", header.StartedAt)
+
+	// Next holds the following check back until a section is filed, so the
+	// commands captured so far are exactly the ones behind it.
+	saved, n := 0, 0
+	for section, ok := diagnostics.Next(); ok; section, ok = diagnostics.Next() {
+		n++
+		run.Add(section, time.Now())
+		report.WriteSection(os.Stdout, n, section)
+		if bundle != nil {
+			captured := capture.Captured()
+			if err := bundle.AddSection(section, captured[saved:]); err != nil {
+				log.Printf("Warning: %v", err)
 			}
+			saved = len(captured)
 		}
 	}
-	fmt.Println()
+	rep := diagnostics.Wait()
 
-	fmt.Println("5. Traceroute Test")
-	fmt.Println("==================")
-	traceResult, err := nc.Traceroute(cfg.TracerouteTarget, cfg.TracerouteCount, cfg.TracerouteInterval, cfg.ViaNetworkDevices)
-	if err != nil {
-		fmt.Printf("❌ Traceroute failed: %v\n", err)
-	} else {
-		fmt.Printf("Target: %s\n", traceResult.Target)
-		fmt.Printf("Hops:\n")
-		for _, hop := range traceResult.Hops {
-			fmt.Printf("  %2d. %s (%s)", hop.Number, hop.Address, hop.Name)
-			if len(hop.RTT) > 0 {
-				fmt.Printf(" -")
-				for _, rtt := range hop.RTT {
-					fmt.Printf(" %.1f ms", float64(rtt)/float64(time.Millisecond))
-				}
-			}
-			fmt.Println()
-		}
-		fmt.Printf("\nExpected network devices:\n")
-		for device, passed := range traceResult.PassesExpected {
-			if passed {
-				fmt.Printf("  ✅ %s: Passed\n", device)
-			} else {
-				fmt.Printf("  ❌ %s: Not found\n", device)
-			}
-		}
+	fmt.Println("=== Diagnostics Complete ===")
+
+	for _, f := range capture.Captured() {
+		rep.Commands = append(rep.Commands, report.Command{Command: f.Command, ExitCode: f.ExitCode, Error: f.Error, Stdout: f.Stdout, Stderr: f.Stderr})
 	}
-	fmt.Println()
 
-	fmt.Println("6. DNS Resolution Test (A Records)")
-	fmt.Println("==================================")
-	dnsResults, err := nc.CheckDNS(cfg.DomainARecords, "A")
-	if err != nil {
-		fmt.Printf("❌ DNS check failed: %v\n", err)
-	} else {
-		for _, result := range dnsResults {
-			if result.Success {
-				fmt.Printf("✅ %s: %v\n", result.Domain, result.Records)
-			} else {
-				fmt.Printf("❌ %s: Failed", result.Domain)
-				if result.Error != nil {
-					fmt.Printf(" - %v", result.Error)
-				}
-				fmt.Println()
-			}
-		}
+	if exporter != nil {
+		exportRun(context.Background(), exporter, run)
 	}
-	fmt.Println()
 
-	fmt.Println("7. DNS Resolution Test (AAAA Records)")
-	fmt.Println("=====================================")
-	dnsResults6, err := nc.CheckDNS(cfg.DomainAAAARecords, "AAAA")
-	if err != nil {
-		fmt.Printf("❌ DNS IPv6 check failed: %v\n", err)
-	} else {
-		for _, result := range dnsResults6 {
-			if result.Success {
-				fmt.Printf("✅ %s: %v\n", result.Domain, result.Records)
-			} else {
-				fmt.Printf("❌ %s: Failed", result.Domain)
-				if result.Error != nil {
-					fmt.Printf(" - %v", result.Error)
-				}
-				fmt.Println()
-			}
+	var history []*report.Report
+	if historyDir != "" {
+		if history, err = report.LoadHistory(historyDir); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		if err := report.SaveHistory(historyDir, rep); err != nil {
+			log.Printf("Warning: %v", err)
 		}
 	}
-	fmt.Println()
-
-	fmt.Println("8. HTTP Connectivity Test (IPv4)")
-	fmt.Println("=================================")
-	httpResult, err := nc.CheckHTTP(cfg.HTTPIPv4Target, false)
-	if err != nil {
-		fmt.Printf("❌ %s: Failed - %v\n", httpResult.URL, err)
-	} else {
-		if httpResult.Success {
-			fmt.Printf("✅ %s: Status %d, Time %.2fs\n", httpResult.URL, httpResult.StatusCode, httpResult.Duration.Seconds())
-		} else {
-			fmt.Printf("❌ %s: Status %d\n", httpResult.URL, httpResult.StatusCode)
+	if htmlPath != "" {
+		if err := writeHTMLReport(htmlPath, rep, history); err != nil {
+			log.Fatalf("Error: %v", err)
 		}
+		fmt.Printf("HTML report written to %s\n", htmlPath)
 	}
-	fmt.Println()
+	if bundle != nil {
+		if err := bundle.WriteReport(rep); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		path, err := bundle.Archive()
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		fmt.Printf("Evidence bundle written to %s\n", path)
+	}
+}
 
-	fmt.Println("9. HTTP Connectivity Test (IPv6)")
-	fmt.Println("=================================")
-	httpResult6, err := nc.CheckHTTP(cfg.HTTPIPv6Target, true)
+func writeHTMLReport(path string, rep *report.Report, history []*report.Report) error {
+	f, err := os.Create(path)
 	if err != nil {
-		fmt.Printf("❌ %s: Failed - %v\n", httpResult6.URL, err)
-	} else {
-		if httpResult6.Success {
-			fmt.Printf("✅ %s: Status %d, Time %.2fs\n", httpResult6.URL, httpResult6.StatusCode, httpResult6.Duration.Seconds())
-		} else {
-			fmt.Printf("❌ %s: Status %d\n", httpResult6.URL, httpResult6.StatusCode)
-		}
+		return fmt.Errorf("failed to create HTML report: %w", err)
+	}
+	if err := report.WriteHTML(f, rep, history); err != nil {
+		f.Close()
+		return err
 	}
-	fmt.Println()
+	return f.Close()
+}
 
-	fmt.Println("=== Diagnostics Complete ===")
-}
\ No newline at end of file
+func newCommandRunner(recordDir, replayDir string) (checker.CommandRunner, error) {
+	switch {
+	case recordDir != "" && replayDir != "":
+		return nil, fmt.Errorf("-record and -replay cannot be used together")
+	case replayDir != "":
+		return checker.LoadReplayRunner(replayDir)
+	case recordDir != "":
+		return checker.NewRecordingRunner(checker.ExecRunner{}, recordDir), nil
+	default:
+		return checker.ExecRunner{}, nil
+	}
+}
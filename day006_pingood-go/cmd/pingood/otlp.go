@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/config"
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/otlp"
+	"github.com/junenu/solo-hackathon/day006_pingood-go/pingood"
+)
+
+// newOTLPExporter builds the exporter configured by OTLP_*, or returns nil
+// when OTLP_ENDPOINT is not set.
+func newOTLPExporter(cfg *pingood.Config, iface string) (*otlp.Exporter, error) {
+	if cfg.OTLPEndpoint == "" {
+		return nil, nil
+	}
+	protocol, err := otlp.ParseProtocol(cfg.OTLPProtocol)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := make(map[string]string, len(cfg.OTLPHeaders))
+	for key, value := range cfg.OTLPHeaders {
+		headers[key] = config.ExpandEnv(value)
+	}
+
+	var tlsConfig *tls.Config
+	if cfg.OTLPCABundle != "" {
+		pool, err := loadCertPool(cfg.OTLPCABundle)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig = &tls.Config{RootCAs: pool}
+	}
+
+	serviceName := cfg.OTLPServiceName
+	if serviceName == "" {
+		serviceName = "pingood"
+	}
+	resource := []otlp.KeyValue{
+		{Key: "service.name", Value: serviceName},
+		{Key: "os.type", Value: runtime.GOOS},
+		{Key: "pingood.interface", Value: iface},
+	}
+	if host, err := os.Hostname(); err == nil {
+		resource = append(resource, otlp.KeyValue{Key: "host.name", Value: host})
+	}
+	resource = append(resource, otlp.Attributes(cfg.OTLPResourceAttrs)...)
+
+	return &otlp.Exporter{
+		Endpoint:  cfg.OTLPEndpoint,
+		Protocol:  protocol,
+		Headers:   headers,
+		Resource:  resource,
+		TLSConfig: tlsConfig,
+		Timeout:   time.Duration(cfg.OTLPTimeout * float64(time.Second)),
+	}, nil
+}
+
+// exportRun sends the trace and metrics of a run. Export failures are only
+// logged so they never fail the checks themselves.
+func exportRun(ctx context.Context, exporter *otlp.Exporter, run *otlp.Run) {
+	if err := exporter.ExportTraces(ctx, run.Spans()); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	if err := exporter.ExportMetrics(ctx, run.Metrics()); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+func runOTLPCollector(args []string) {
+	fs := flag.NewFlagSet("otlp-collector", flag.ExitOnError)
+	var listen string
+	fs.StringVar(&listen, "listen", "127.0.0.1:4318", "Address to accept OTLP/HTTP and cleartext OTLP/gRPC exports on")
+	fs.Parse(args)
+
+	ln, err := net.Listen("tcp", listen)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	var mu sync.Mutex
+	collector := &otlp.Collector{OnExport: func(e otlp.Export) {
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Printf("%s ", time.Now().Format("2006-01-02 15:04:05"))
+		otlp.WriteExport(os.Stdout, e)
+	}}
+	fmt.Printf("OTLP collector stand-in: http://%s (OTLP/HTTP and gRPC)\n", ln.Addr())
+	if err := collector.Serve(ln); err != nil && !errors.Is(err, net.ErrClosed) {
+		log.Fatalf("Error: %v", err)
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/otlp"
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/report"
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/schedule"
+	"github.com/junenu/solo-hackathon/day006_pingood-go/pingood"
+)
+
+func runSchedule(args []string) {
+	fs := flag.NewFlagSet("schedule", flag.ExitOnError)
+	var (
+		iface      string
+		configPath string
+		statePath  string
+		logLevel   string
+	)
+	fs.StringVar(&iface, "i", pingood.DefaultInterface(), "Network interface to check")
+	fs.StringVar(&configPath, "c", "conf.yaml", "Path to configuration file")
+	fs.StringVar(&statePath, "state", "", "File the schedule status is saved to for \"pingood status\" (default SCHEDULE_STATE_FILE or the user cache directory)")
+	fs.StringVar(&logLevel, "log-level", "warn", "Log checks to stderr at this level: debug, info, warn or error")
+	fs.Parse(args)
+
+	cfg, err := pingood.LoadConfig(configPath)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if statePath, err = scheduleStatePath(statePath, cfg); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := setupLogging(logLevel, nil); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	exporter, err := newOTLPExporter(cfg, iface)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	jobs, err := scheduledJobs(&pingood.Runner{Config: cfg, Interface: iface}, exporter)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	scheduler := schedule.New(jobs...)
+
+	var mu sync.Mutex
+	save := func() {
+		state := schedule.State{PID: os.Getpid(), Updated: time.Now(), Jobs: scheduler.Status()}
+		if err := schedule.SaveState(statePath, state); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+	scheduler.OnRun = func(run schedule.Run) {
+		mu.Lock()
+		defer mu.Unlock()
+		stamp := run.Started.Format("2006-01-02 15:04:05")
+		if run.Skipped {
+			fmt.Printf("%s ⏭️  %s skipped: the previous run is still going\n", stamp, run.Job)
+		} else {
+			icon := run.Outcome.Status.Icon()
+			if icon == "" {
+				icon = "ℹ️ "
+			}
+			fmt.Printf("%s %s %s (%s)", stamp, icon, run.Job, run.Duration.Round(time.Millisecond))
+			if run.Outcome.Summary != "" {
+				fmt.Printf(": %s", run.Outcome.Summary)
+			}
+			fmt.Println()
+		}
+		save()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	scheduler.OnStart = func() {
+		mu.Lock()
+		defer mu.Unlock()
+		save()
+	}
+
+	fmt.Printf("Scheduling %d checks, status in %s\n", len(jobs), statePath)
+	scheduler.Run(ctx)
+	save()
+}
+
+func runStatus(args []string) {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	var configPath, statePath string
+	fs.StringVar(&configPath, "c", "conf.yaml", "Path to configuration file")
+	fs.StringVar(&statePath, "state", "", "Schedule status file (default SCHEDULE_STATE_FILE or the user cache directory)")
+	fs.Parse(args)
+
+	cfg, err := pingood.LoadConfig(configPath)
+	if err != nil {
+		cfg = pingood.DefaultConfig()
+	}
+	if statePath, err = scheduleStatePath(statePath, cfg); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	state, err := schedule.LoadState(statePath)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	schedule.WriteStatus(os.Stdout, state, time.Now())
+}
+
+func scheduleStatePath(flagValue string, cfg *pingood.Config) (string, error) {
+	switch {
+	case flagValue != "":
+		return flagValue, nil
+	case cfg.ScheduleStateFile != "":
+		return cfg.ScheduleStateFile, nil
+	}
+	return schedule.DefaultStatePath()
+}
+
+// scheduledJobs turns SCHEDULE into jobs. Each job keeps the latest
+// sections of every check, so checks that build on others, such as
+// ipv6_readiness, see the most recent results. With an exporter, every run
+// is exported as a trace of its own.
+func scheduledJobs(runner *pingood.Runner, exporter *otlp.Exporter) ([]schedule.Job, error) {
+	if len(runner.Config.Schedule) == 0 {
+		return nil, fmt.Errorf("no SCHEDULE configured")
+	}
+	configured, err := runner.Checks()
+	if err != nil {
+		return nil, err
+	}
+	checks := make(map[string]pingood.Check)
+	var names []string
+	for _, c := range configured {
+		checks[c.Name] = c
+		names = append(names, c.Name)
+	}
+
+	latest := &latestSections{byCheck: make(map[string][]report.Section)}
+	var jobs []schedule.Job
+	seen := make(map[string]bool)
+	for _, entry := range runner.Config.Schedule {
+		c, ok := checks[entry.Check]
+		if !ok {
+			return nil, fmt.Errorf("unknown or unconfigured check %q in SCHEDULE (available: %s)", entry.Check, strings.Join(names, ", "))
+		}
+		if seen[entry.Check] {
+			return nil, fmt.Errorf("check %q is scheduled more than once", entry.Check)
+		}
+		seen[entry.Check] = true
+		spec, err := schedule.Parse(entry.Cron)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CRON for %s: %w", entry.Check, err)
+		}
+		jobs = append(jobs, schedule.Job{
+			Name:     entry.Check,
+			Spec:     entry.Cron,
+			Schedule: spec,
+			Jitter:   time.Duration(entry.Jitter * float64(time.Second)),
+			Run: func(ctx context.Context) schedule.Outcome {
+				var sections []report.Section
+				run := otlp.NewRun("pingood "+c.Name, time.Now())
+				c.Run(ctx, latest.report(), func(section report.Section) {
+					sections = append(sections, section)
+					run.Add(section, time.Now())
+				})
+				latest.set(c.Name, sections)
+				if exporter != nil {
+					exportRun(ctx, exporter, run)
+				}
+				return sectionsOutcome(sections)
+			},
+		})
+	}
+	return jobs, nil
+}
+
+type latestSections struct {
+	mu      sync.Mutex
+	order   []string
+	byCheck map[string][]report.Section
+}
+
+func (l *latestSections) set(check string, sections []report.Section) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.byCheck[check]; !ok {
+		l.order = append(l.order, check)
+	}
+	l.byCheck[check] = sections
+}
+
+func (l *latestSections) report() *report.Report {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	rep := &report.Report{}
+	for _, check := range l.order {
+		for _, section := range l.byCheck[check] {
+			rep.Add(section)
+		}
+	}
+	return rep
+}
+
+// sectionsOutcome fails if any section failed and names what failed.
+func sectionsOutcome(sections []report.Section) schedule.Outcome {
+	outcome := schedule.Outcome{Status: report.StatusInfo}
+	var passed int
+	var failures []string
+	for _, section := range sections {
+		switch section.Status() {
+		case report.StatusFail:
+			outcome.Status = report.StatusFail
+		case report.StatusPass:
+			if outcome.Status == report.StatusInfo {
+				outcome.Status = report.StatusPass
+			}
+		}
+		if section.Error != "" {
+			failures = append(failures, fmt.Sprintf("%s: %s", section.Title, section.Error))
+			continue
+		}
+		var failed []string
+		for _, item := range section.Items {
+			switch item.Status {
+			case report.StatusPass:
+				passed++
+			case report.StatusFail:
+				failed = append(failed, item.Name)
+			}
+		}
+		if len(failed) > 0 {
+			failures = append(failures, fmt.Sprintf("%s: %s failed", section.Title, strings.Join(failed, ", ")))
+		}
+	}
+	switch {
+	case len(failures) > 0:
+		outcome.Summary = strings.Join(failures, "; ")
+	case passed > 0:
+		outcome.Summary = fmt.Sprintf("%d passed", passed)
+	}
+	return outcome
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/throughput"
+)
+
+func runThroughputServer(args []string) {
+	fs := flag.NewFlagSet("throughput-server", flag.ExitOnError)
+	var (
+		listen      string
+		tcpListen   string
+		maxDuration time.Duration
+		maxBytes    int64
+		token       string
+	)
+	fs.StringVar(&listen, "listen", ":8081", "Address for the HTTP endpoint (empty to disable)")
+	fs.StringVar(&tcpListen, "tcp", "", "Address for the raw TCP endpoint, e.g. :8082")
+	fs.DurationVar(&maxDuration, "max-duration", time.Minute, "Longest a single transfer may run")
+	fs.Int64Var(&maxBytes, "max-bytes", 10<<30, "Most bytes a single transfer may send or receive")
+	fs.StringVar(&token, "token", os.Getenv("PINGOOD_THROUGHPUT_TOKEN"), "Shared token clients must present (default $PINGOOD_THROUGHPUT_TOKEN)")
+	fs.Parse(args)
+
+	if listen == "" && tcpListen == "" {
+		log.Fatalf("Error: at least one of -listen or -tcp is required")
+	}
+
+	server, err := throughput.NewServer()
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	server.MaxDuration = maxDuration
+	server.MaxBytes = maxBytes
+	server.Token = token
+	if token == "" {
+		log.Printf("Warning: No token set; anyone who can reach the server can run transfers against it")
+	}
+
+	errs := make(chan error, 2)
+	if tcpListen != "" {
+		ln, err := net.Listen("tcp", tcpListen)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		fmt.Printf("Throughput TCP endpoint: tcp://%s\n", ln.Addr())
+		go func() { errs <- server.ServeTCP(ln) }()
+	}
+	if listen != "" {
+		ln, err := net.Listen("tcp", listen)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		fmt.Printf("Throughput HTTP endpoint: http://%s\n", ln.Addr())
+		httpServer := &http.Server{Handler: server, ReadHeaderTimeout: 10 * time.Second}
+		go func() { errs <- httpServer.Serve(ln) }()
+	}
+
+	if err := <-errs; err != nil && !errors.Is(err, net.ErrClosed) {
+		log.Fatalf("Error: %v", err)
+	}
+}
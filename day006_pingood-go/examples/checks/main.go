@@ -0,0 +1,61 @@
+// Command checks runs a few pingood checks from a program of its own and
+// exits non-zero when one of them fails, for use in deployment scripts:
+//
+//	go run ./examples/checks -c conf.yaml gateway dns http
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/junenu/solo-hackathon/day006_pingood-go/pingood"
+)
+
+func main() {
+	configPath := flag.String("c", "", "Configuration file (default: built-in configuration)")
+	iface := flag.String("i", pingood.DefaultInterface(), "Network interface to check")
+	flag.Parse()
+
+	cfg := pingood.DefaultConfig()
+	if *configPath != "" {
+		var err error
+		if cfg, err = pingood.LoadConfig(*configPath); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	runner := &pingood.Runner{Config: cfg, Interface: *iface, Only: flag.Args()}
+	failed := false
+	_, err := runner.Run(ctx, func(section pingood.Section) {
+		if section.ID == "diagnosis" {
+			for _, item := range section.Items {
+				fmt.Printf("diagnosis: %s\n", item.Summary)
+			}
+			return
+		}
+		status := section.Status()
+		failed = failed || status == pingood.StatusFail
+		fmt.Printf("%-4s %s\n", status, section.Title)
+		for _, item := range section.Items {
+			if item.Status == pingood.StatusFail {
+				fmt.Printf("     %s: %s\n", item.Name, item.Summary)
+			}
+		}
+		if section.Error != "" {
+			fmt.Printf("     %s\n", section.Error)
+		}
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,66 @@
+// Command healthz serves pingood checks over HTTP. Each request to
+// /healthz runs the checks and streams every section as a line of JSON as
+// soon as it is ready, followed by a Pingood-Status trailer of "pass" or
+// "fail". Closing the connection stops the run.
+//
+//	go run ./examples/healthz -listen :8080 -checks gateway,dns
+//	curl -N localhost:8080/healthz
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/junenu/solo-hackathon/day006_pingood-go/pingood"
+)
+
+func main() {
+	listen := flag.String("listen", "127.0.0.1:8080", "Address to serve on")
+	checks := flag.String("checks", "gateway,dns,http", "Comma-separated checks to run per request")
+	iface := flag.String("i", pingood.DefaultInterface(), "Network interface to check")
+	flag.Parse()
+
+	runner := &pingood.Runner{Config: pingood.DefaultConfig(), Interface: *iface, Only: strings.Split(*checks, ",")}
+	if _, err := runner.Checks(); err != nil {
+		log.Fatal(err)
+	}
+
+	// The checks share the host's network; one run at a time keeps them
+	// from skewing each other's latencies.
+	var mu sync.Mutex
+	http.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		run, err := runner.Start(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		enc := json.NewEncoder(w)
+		flusher, _ := w.(http.Flusher)
+
+		// The status code goes out with the first line, before the results
+		// are known, so the outcome comes in a trailer.
+		w.Header().Set("Trailer", "Pingood-Status")
+		status := pingood.StatusPass
+		for section, ok := run.Next(); ok; section, ok = run.Next() {
+			if section.ID != "diagnosis" && section.Status() == pingood.StatusFail {
+				status = pingood.StatusFail
+			}
+			enc.Encode(section)
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		w.Header().Set("Pingood-Status", string(status))
+	})
+
+	log.Printf("Serving http://%s/healthz", *listen)
+	log.Fatal(http.ListenAndServe(*listen, nil))
+}
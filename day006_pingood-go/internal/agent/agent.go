@@ -0,0 +1,264 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/config"
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/report"
+)
+
+// RunFunc runs the diagnostics for a job, passing each finished section to
+// emit, and returns the complete report. It must stop when ctx is done.
+type RunFunc func(ctx context.Context, cfg *config.Config, emit func(report.Section)) (*report.Report, error)
+
+// Agent connects to a coordinator and runs the jobs it receives.
+type Agent struct {
+	CoordinatorURL string
+	Token          string
+	Registration   Registration
+	Client         *http.Client
+	RetryInterval  time.Duration
+	Run            RunFunc
+}
+
+var (
+	errNotRegistered = errors.New("agent is not registered with the coordinator")
+	errUnknownJob    = errors.New("coordinator no longer waits for the job")
+)
+
+// Serve registers with the coordinator and processes jobs until ctx is
+// cancelled. Connection failures are retried so agents survive coordinator
+// restarts.
+func (a *Agent) Serve(ctx context.Context) error {
+	if a.Client == nil {
+		a.Client = &http.Client{Timeout: 2 * time.Minute}
+	}
+	if a.RetryInterval == 0 {
+		a.RetryInterval = 5 * time.Second
+	}
+
+	registered := false
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if !registered {
+			if err := a.post(ctx, RegisterPath, a.Registration); err != nil {
+				log.Printf("Warning: Failed to register with coordinator: %v", err)
+				a.wait(ctx)
+				continue
+			}
+			log.Printf("Registered with %s as %s", a.CoordinatorURL, a.Registration.Name)
+			registered = true
+		}
+
+		job, err := a.poll(ctx)
+		switch {
+		case errors.Is(err, errNotRegistered):
+			registered = false
+			continue
+		case err != nil:
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Printf("Warning: Failed to poll coordinator: %v", err)
+			registered = false
+			a.wait(ctx)
+			continue
+		case job == nil:
+			continue
+		}
+
+		a.runJob(ctx, job)
+	}
+}
+
+func (a *Agent) runJob(ctx context.Context, job *Job) {
+	log.Printf("Running job %s", job.ID)
+
+	completion := Completion{JobID: job.ID, Agent: a.Registration.Name}
+	if job.Config == nil {
+		completion.Error = "job has no config"
+	} else if err := checkJobConfig(job.Config); err != nil {
+		log.Printf("Warning: Refusing job %s: %v", job.ID, err)
+		completion.Error = err.Error()
+	} else {
+		// The coordinator forgets a job it gave up on; stop running it then.
+		jobCtx, cancel := context.WithCancel(ctx)
+		rep, err := a.Run(jobCtx, job.Config, func(section report.Section) {
+			update := SectionUpdate{JobID: job.ID, Agent: a.Registration.Name, Section: section}
+			err := a.post(ctx, SectionPath, update)
+			switch {
+			case errors.Is(err, errUnknownJob):
+				cancel()
+			case err != nil:
+				log.Printf("Warning: Failed to send section %s: %v", section.ID, err)
+			}
+		})
+		stopped := jobCtx.Err() != nil
+		cancel()
+		switch {
+		case ctx.Err() != nil:
+			// Shutting down; there is no one to deliver the result to.
+			log.Printf("Stopped job %s: %v", job.ID, ctx.Err())
+			return
+		case stopped:
+			log.Printf("Stopped job %s: the coordinator no longer waits for it", job.ID)
+			return
+		case err != nil:
+			completion.Error = err.Error()
+		case rep == nil:
+			completion.Error = "run produced no report"
+		default:
+			rep.Agent = a.Registration.Name
+			completion.Report = rep
+		}
+	}
+
+	if err := a.post(ctx, CompletePath, completion); err != nil {
+		log.Printf("Warning: Failed to deliver result of job %s: %v", job.ID, err)
+	}
+}
+
+// checkJobConfig refuses the settings a coordinator must not use on an
+// agent. Whoever can queue a job would otherwise read the agent's
+// environment through ${NAME} in secrets sent to a server of their
+// choosing, and read or write files on the agent through path settings.
+func checkJobConfig(cfg *config.Config) error {
+	var refused []string
+	for name, path := range map[string]string{
+		"ASN_DATABASE":          cfg.ASNDatabase,
+		"NEIGHBOR_HISTORY_FILE": cfg.NeighborHistoryFile,
+		"TLS_CA_BUNDLE":         cfg.TLSCABundle,
+		"SCHEDULE_STATE_FILE":   cfg.ScheduleStateFile,
+		"OTLP_CA_BUNDLE":        cfg.OTLPCABundle,
+	} {
+		if path != "" {
+			refused = append(refused, name)
+		}
+	}
+	if config.HasEnvReference(cfg.ThroughputToken) {
+		refused = append(refused, "THROUGHPUT_TOKEN ${NAME} reference")
+	}
+	for key, value := range cfg.OTLPHeaders {
+		if config.HasEnvReference(value) {
+			refused = append(refused, "OTLP_HEADERS "+key)
+		}
+	}
+	for _, check := range cfg.HTTPChecks {
+		name := check.Name
+		if name == "" {
+			name = check.URL
+		}
+		if check.ClientCert != "" || check.ClientKey != "" || check.CABundle != "" {
+			refused = append(refused, fmt.Sprintf("HTTP_CHECKS %s: CLIENT_CERT, CLIENT_KEY or CA_BUNDLE", name))
+		}
+		secrets := []string{check.BasicAuthPassword, check.BearerToken}
+		for _, value := range check.Headers {
+			secrets = append(secrets, value)
+		}
+		for _, value := range secrets {
+			if config.HasEnvReference(value) {
+				refused = append(refused, fmt.Sprintf("HTTP_CHECKS %s: ${NAME} reference", name))
+				break
+			}
+		}
+	}
+	if len(refused) == 0 {
+		return nil
+	}
+	sort.Strings(refused)
+	return fmt.Errorf("job config uses settings agents only take from their own files: %s", strings.Join(refused, "; "))
+}
+
+func (a *Agent) poll(ctx context.Context) (*Job, error) {
+	endpoint := a.endpoint(PollPath) + "?agent=" + url.QueryEscape(a.Registration.Name)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	a.authorize(req)
+
+	resp, err := a.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var job Job
+		if err := json.NewDecoder(resp.Body).Decode(&job); err != nil {
+			return nil, fmt.Errorf("invalid job: %w", err)
+		}
+		return &job, nil
+	case http.StatusNoContent:
+		return nil, nil
+	case http.StatusNotFound:
+		return nil, errNotRegistered
+	default:
+		return nil, responseError(resp)
+	}
+}
+
+func (a *Agent) post(ctx context.Context, path string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.endpoint(path), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	a.authorize(req)
+
+	resp, err := a.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound && path != RegisterPath:
+		return fmt.Errorf("%w: %v", errUnknownJob, responseError(resp))
+	case resp.StatusCode/100 != 2:
+		return responseError(resp)
+	}
+	return nil
+}
+
+func (a *Agent) endpoint(path string) string {
+	return strings.TrimRight(a.CoordinatorURL, "/") + path
+}
+
+func (a *Agent) authorize(req *http.Request) {
+	if a.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+a.Token)
+	}
+}
+
+func (a *Agent) wait(ctx context.Context) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(a.RetryInterval):
+	}
+}
+
+func responseError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("coordinator returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+}
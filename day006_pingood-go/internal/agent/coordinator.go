@@ -0,0 +1,312 @@
+package agent
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/config"
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/report"
+)
+
+// Coordinator is the HTTP side of the protocol. Agents register with it and
+// poll for jobs; Dispatch hands a config to selected agents and collects
+// their reports.
+type Coordinator struct {
+	// Token is the shared token agents must present. Without one every
+	// request is refused unless Insecure is set.
+	Token string
+	// Insecure accepts agents without a token, for trusted test networks.
+	Insecure    bool
+	PollTimeout time.Duration
+
+	mu     sync.Mutex
+	agents map[string]*agentState
+	jobs   map[string]*jobState
+}
+
+type agentState struct {
+	Registration
+	jobs chan Job
+}
+
+type jobState struct {
+	onSection func(agent string, section report.Section)
+	pending   map[string]bool
+	reports   map[string]*report.Report
+	errors    map[string]string
+	done      chan struct{}
+}
+
+func NewCoordinator(token string) *Coordinator {
+	return &Coordinator{
+		Token:       token,
+		PollTimeout: 30 * time.Second,
+		agents:      make(map[string]*agentState),
+		jobs:        make(map[string]*jobState),
+	}
+}
+
+func (c *Coordinator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !c.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch {
+	case r.URL.Path == RegisterPath && r.Method == http.MethodPost:
+		c.handleRegister(w, r)
+	case r.URL.Path == PollPath && r.Method == http.MethodGet:
+		c.handlePoll(w, r)
+	case r.URL.Path == SectionPath && r.Method == http.MethodPost:
+		c.handleSection(w, r)
+	case r.URL.Path == CompletePath && r.Method == http.MethodPost:
+		c.handleComplete(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (c *Coordinator) authorized(r *http.Request) bool {
+	if c.Token == "" {
+		return c.Insecure
+	}
+	got := []byte(r.Header.Get("Authorization"))
+	return subtle.ConstantTimeCompare(got, []byte("Bearer "+c.Token)) == 1
+}
+
+func (c *Coordinator) handleRegister(w http.ResponseWriter, r *http.Request) {
+	var reg Registration
+	if err := json.NewDecoder(r.Body).Decode(&reg); err != nil || reg.Name == "" {
+		http.Error(w, "invalid registration", http.StatusBadRequest)
+		return
+	}
+
+	c.mu.Lock()
+	state, ok := c.agents[reg.Name]
+	if !ok {
+		state = &agentState{jobs: make(chan Job, 8)}
+		c.agents[reg.Name] = state
+	}
+	state.Registration = reg
+	c.mu.Unlock()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *Coordinator) handlePoll(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("agent")
+
+	c.mu.Lock()
+	state, ok := c.agents[name]
+	c.mu.Unlock()
+	if !ok {
+		// Tells the agent to register again, e.g. after a coordinator restart.
+		http.Error(w, "unknown agent", http.StatusNotFound)
+		return
+	}
+
+	timer := time.NewTimer(c.PollTimeout)
+	defer timer.Stop()
+
+	select {
+	case job := <-state.jobs:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(job)
+	case <-timer.C:
+		w.WriteHeader(http.StatusNoContent)
+	case <-r.Context().Done():
+	}
+}
+
+func (c *Coordinator) handleSection(w http.ResponseWriter, r *http.Request) {
+	var update SectionUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, "invalid section update", http.StatusBadRequest)
+		return
+	}
+
+	// Only an agent the job was sent to, and that has not completed it,
+	// may add to its results.
+	c.mu.Lock()
+	job, ok := c.jobs[update.JobID]
+	ok = ok && job.pending[update.Agent]
+	c.mu.Unlock()
+	if !ok {
+		http.Error(w, "unknown job", http.StatusNotFound)
+		return
+	}
+
+	if job.onSection != nil {
+		job.onSection(update.Agent, update.Section)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *Coordinator) handleComplete(w http.ResponseWriter, r *http.Request) {
+	var completion Completion
+	if err := json.NewDecoder(r.Body).Decode(&completion); err != nil {
+		http.Error(w, "invalid completion", http.StatusBadRequest)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	job, ok := c.jobs[completion.JobID]
+	if !ok || !job.pending[completion.Agent] {
+		http.Error(w, "unknown job", http.StatusNotFound)
+		return
+	}
+
+	delete(job.pending, completion.Agent)
+	if completion.Error != "" {
+		job.errors[completion.Agent] = completion.Error
+	} else {
+		job.reports[completion.Agent] = completion.Report
+	}
+	if len(job.pending) == 0 {
+		close(job.done)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Agents returns the registered agents sorted by name.
+func (c *Coordinator) Agents() []Registration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var regs []Registration
+	for _, state := range c.agents {
+		regs = append(regs, state.Registration)
+	}
+	sort.Slice(regs, func(i, j int) bool { return regs[i].Name < regs[j].Name })
+	return regs
+}
+
+// WaitForAgents blocks until every named agent has registered. With no
+// names it waits for the context to end and returns whoever registered.
+func (c *Coordinator) WaitForAgents(ctx context.Context, names []string) ([]string, error) {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		registered := make(map[string]bool)
+		for _, reg := range c.Agents() {
+			registered[reg.Name] = true
+		}
+
+		if len(names) > 0 {
+			var missing []string
+			for _, name := range names {
+				if !registered[name] {
+					missing = append(missing, name)
+				}
+			}
+			if len(missing) == 0 {
+				return names, nil
+			}
+			select {
+			case <-ctx.Done():
+				return nil, fmt.Errorf("agents did not register in time: %v", missing)
+			case <-ticker.C:
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			var all []string
+			for _, reg := range c.Agents() {
+				all = append(all, reg.Name)
+			}
+			if len(all) == 0 {
+				return nil, fmt.Errorf("no agents registered")
+			}
+			return all, nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Dispatch sends cfg to the given agents and waits for all of them to
+// report back. Sections are passed to onSection as they arrive, possibly from
+// several agents at once. On timeout the reports received so far are
+// returned together with the error.
+func (c *Coordinator) Dispatch(ctx context.Context, cfg *config.Config, agents []string, onSection func(agent string, section report.Section)) (map[string]*report.Report, map[string]string, error) {
+	id, err := newJobID()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	job := &jobState{
+		onSection: onSection,
+		pending:   make(map[string]bool),
+		reports:   make(map[string]*report.Report),
+		errors:    make(map[string]string),
+		done:      make(chan struct{}),
+	}
+
+	c.mu.Lock()
+	var queues []chan Job
+	for _, name := range agents {
+		state, ok := c.agents[name]
+		if !ok {
+			c.mu.Unlock()
+			return nil, nil, fmt.Errorf("agent %s is not registered", name)
+		}
+		job.pending[name] = true
+		queues = append(queues, state.jobs)
+	}
+	c.jobs[id] = job
+	c.mu.Unlock()
+
+	for _, queue := range queues {
+		select {
+		case queue <- Job{ID: id, Config: cfg}:
+		case <-ctx.Done():
+			c.mu.Lock()
+			delete(c.jobs, id)
+			c.mu.Unlock()
+			return nil, nil, ctx.Err()
+		}
+	}
+
+	var waitErr error
+	select {
+	case <-job.done:
+	case <-ctx.Done():
+		waitErr = ctx.Err()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.jobs, id)
+
+	if waitErr != nil {
+		var missing []string
+		for name := range job.pending {
+			missing = append(missing, name)
+		}
+		sort.Strings(missing)
+		waitErr = fmt.Errorf("no result from %v: %w", missing, waitErr)
+	}
+
+	return job.reports, job.errors, waitErr
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate job id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,17 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+)
+
+func jsonBody(t *testing.T, v interface{}) io.Reader {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("Failed to encode body: %v", err)
+	}
+	return bytes.NewReader(data)
+}
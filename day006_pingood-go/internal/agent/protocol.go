@@ -0,0 +1,45 @@
+// Package agent lets pingood instances on different hosts run the same
+// diagnostics on behalf of a coordinator. Agents connect out to the
+// coordinator over HTTP(S), so they work from behind NAT and firewalls:
+//
+//	POST /api/v1/register  agent announces itself
+//	GET  /api/v1/poll      agent long-polls for its next job
+//	POST /api/v1/section   agent streams a finished report section
+//	POST /api/v1/complete  agent delivers the final report
+package agent
+
+import (
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/config"
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/report"
+)
+
+const (
+	RegisterPath = "/api/v1/register"
+	PollPath     = "/api/v1/poll"
+	SectionPath  = "/api/v1/section"
+	CompletePath = "/api/v1/complete"
+)
+
+type Registration struct {
+	Name      string `json:"name"`
+	Platform  string `json:"platform"`
+	Interface string `json:"interface"`
+}
+
+type Job struct {
+	ID     string         `json:"id"`
+	Config *config.Config `json:"config"`
+}
+
+type SectionUpdate struct {
+	JobID   string         `json:"job_id"`
+	Agent   string         `json:"agent"`
+	Section report.Section `json:"section"`
+}
+
+type Completion struct {
+	JobID  string         `json:"job_id"`
+	Agent  string         `json:"agent"`
+	Report *report.Report `json:"report,omitempty"`
+	Error  string         `json:"error,omitempty"`
+}
@@ -0,0 +1,243 @@
+// Package analysis turns a finished report into a ranked list of likely
+// root causes. Knowledge lives in rules: each rule lists conditions on
+// sections or items of the report and the diagnosis to show when all of
+// them hold, so new failure patterns can be added without code changes.
+//
+// A condition is "<ref> <op> <value>", where ref is a section ID such as
+// dns_a, or an item within it such as gateway[Gateway]. With == and != the
+// value is a state:
+//
+//	pass      every checked item passed
+//	fail      every checked item failed, or the section errored
+//	degraded  some items passed and some failed
+//	info      nothing was checked, only information shown
+//	missing   the section or item is not in the report
+//
+// With =~ the value is a regular expression matched against the section
+// error and the summaries and details of the selected items; !~ holds when
+// nothing matches.
+package analysis
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/report"
+)
+
+type State string
+
+const (
+	StatePass     State = "pass"
+	StateFail     State = "fail"
+	StateDegraded State = "degraded"
+	StateInfo     State = "info"
+	StateMissing  State = "missing"
+)
+
+// Rule is one failure pattern. Higher Priority ranks a finding earlier;
+// rules closer to the root of the dependency chain should score higher.
+type Rule struct {
+	Name      string
+	Priority  int
+	When      []string
+	Diagnosis string
+	Hint      string
+}
+
+type Finding struct {
+	Rule Rule
+	// Evidence explains each condition that held.
+	Evidence []string
+}
+
+type Engine struct {
+	rules []compiledRule
+}
+
+type compiledRule struct {
+	rule       Rule
+	conditions []condition
+}
+
+type condition struct {
+	expr    string
+	section string
+	item    string
+	op      string
+	state   State
+	pattern *regexp.Regexp
+}
+
+var conditionRe = regexp.MustCompile(`^\s*([a-z0-9_]+)(?:\[([^\]]+)\])?\s*(==|!=|=~|!~)\s*(.+?)\s*$`)
+
+// NewEngine compiles rules. A later rule with the same name as an earlier
+// one replaces it, so configured rules can override DefaultRules; a later
+// rule without conditions removes it.
+func NewEngine(rules ...Rule) (*Engine, error) {
+	e := &Engine{}
+	index := make(map[string]int)
+
+	for _, rule := range rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("rule %q has no name", rule.Diagnosis)
+		}
+		if len(rule.When) == 0 {
+			i, ok := index[rule.Name]
+			if !ok {
+				return nil, fmt.Errorf("rule %s has no conditions", rule.Name)
+			}
+			e.rules = append(e.rules[:i], e.rules[i+1:]...)
+			delete(index, rule.Name)
+			for name, j := range index {
+				if j > i {
+					index[name] = j - 1
+				}
+			}
+			continue
+		}
+		compiled := compiledRule{rule: rule}
+		for _, expr := range rule.When {
+			c, err := parseCondition(expr)
+			if err != nil {
+				return nil, fmt.Errorf("rule %s: %w", rule.Name, err)
+			}
+			compiled.conditions = append(compiled.conditions, c)
+		}
+
+		if i, ok := index[rule.Name]; ok {
+			e.rules[i] = compiled
+			continue
+		}
+		index[rule.Name] = len(e.rules)
+		e.rules = append(e.rules, compiled)
+	}
+
+	return e, nil
+}
+
+func parseCondition(expr string) (condition, error) {
+	matches := conditionRe.FindStringSubmatch(expr)
+	if matches == nil {
+		return condition{}, fmt.Errorf("invalid condition %q", expr)
+	}
+	c := condition{expr: strings.TrimSpace(expr), section: matches[1], item: matches[2], op: matches[3]}
+	value := strings.Trim(matches[4], `'"`)
+
+	if c.op == "=~" || c.op == "!~" {
+		pattern, err := regexp.Compile(value)
+		if err != nil {
+			return condition{}, fmt.Errorf("invalid pattern in %q: %w", expr, err)
+		}
+		c.pattern = pattern
+		return c, nil
+	}
+
+	switch State(value) {
+	case StatePass, StateFail, StateDegraded, StateInfo, StateMissing:
+		c.state = State(value)
+	default:
+		return condition{}, fmt.Errorf("unknown state %q in %q", value, expr)
+	}
+	return c, nil
+}
+
+// Analyze returns the findings whose conditions all hold, highest priority
+// first. Rules with equal priority keep their order.
+func (e *Engine) Analyze(r *report.Report) []Finding {
+	var findings []Finding
+
+	for _, rule := range e.rules {
+		finding := Finding{Rule: rule.rule}
+		matched := true
+		for _, c := range rule.conditions {
+			evidence, ok := c.eval(r)
+			if !ok {
+				matched = false
+				break
+			}
+			finding.Evidence = append(finding.Evidence, evidence)
+		}
+		if matched {
+			findings = append(findings, finding)
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Rule.Priority > findings[j].Rule.Priority
+	})
+	return findings
+}
+
+func (c condition) eval(r *report.Report) (string, bool) {
+	section, found := r.Section(c.section)
+	var items []report.Item
+	if found {
+		items = section.Items
+		if c.item != "" {
+			item, ok := section.Item(c.item)
+			found = ok
+			items = []report.Item{item}
+		}
+	}
+
+	if c.pattern != nil {
+		var texts []string
+		if found {
+			texts = append(texts, section.Error)
+			for _, item := range items {
+				texts = append(texts, item.Summary)
+				texts = append(texts, item.Details...)
+			}
+		}
+		for _, text := range texts {
+			if c.pattern.MatchString(text) {
+				return fmt.Sprintf("%s: %q", c.ref(), strings.TrimSpace(text)), c.op == "=~"
+			}
+		}
+		return fmt.Sprintf("%s does not match %s", c.ref(), c.pattern), c.op == "!~"
+	}
+
+	state := StateMissing
+	if found {
+		state = stateOf(section.Error, items)
+	}
+	if (state == c.state) != (c.op == "==") {
+		return "", false
+	}
+	return fmt.Sprintf("%s is %s", c.ref(), state), true
+}
+
+func (c condition) ref() string {
+	if c.item != "" {
+		return fmt.Sprintf("%s[%s]", c.section, c.item)
+	}
+	return c.section
+}
+
+func stateOf(sectionError string, items []report.Item) State {
+	if sectionError != "" {
+		return StateFail
+	}
+	var passed, failed int
+	for _, item := range items {
+		switch item.Status {
+		case report.StatusPass:
+			passed++
+		case report.StatusFail:
+			failed++
+		}
+	}
+	switch {
+	case failed > 0 && passed > 0:
+		return StateDegraded
+	case failed > 0:
+		return StateFail
+	case passed > 0:
+		return StatePass
+	default:
+		return StateInfo
+	}
+}
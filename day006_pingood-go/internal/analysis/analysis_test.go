@@ -0,0 +1,225 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/report"
+)
+
+func pass(name string) report.Item { return report.Item{Name: name, Status: report.StatusPass} }
+func fail(name, summary string) report.Item {
+	return report.Item{Name: name, Status: report.StatusFail, Summary: summary}
+}
+
+func buildReport(sections map[string][]report.Item) *report.Report {
+	r := &report.Report{}
+	for _, id := range []string{"wireless", "ip", "gateway", "ping_ipv4", "ping_ipv6", "snmp", "dns_a", "dns_aaaa", "http_ipv4", "http_ipv6", "http_protocols", "tls_interception", "middlebox"} {
+		if items, ok := sections[id]; ok {
+			r.Add(report.Section{ID: id, Items: items})
+		}
+	}
+	return r
+}
+
+func healthy() map[string][]report.Item {
+	return map[string][]report.Item{
+		"ip":        {pass("IPv4"), pass("IPv6")},
+		"gateway":   {pass("Gateway"), pass("IPv6 Gateway")},
+		"ping_ipv4": {pass("8.8.8.8"), pass("1.1.1.1")},
+		"ping_ipv6": {pass("2001:4860:4860::8888")},
+		"dns_a":     {pass("google.com")},
+		"dns_aaaa":  {pass("google.com")},
+		"http_ipv4": {pass("https://www.google.com")},
+		"http_ipv6": {pass("https://ipv6.google.com")},
+	}
+}
+
+func TestDefaultRules(t *testing.T) {
+	engine, err := NewEngine(DefaultRules...)
+	if err != nil {
+		t.Fatalf("DefaultRules do not compile: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(map[string][]report.Item)
+		want   []string
+	}{
+		{
+			name:   "healthy",
+			modify: func(map[string][]report.Item) {},
+		},
+		{
+			name: "no address",
+			modify: func(s map[string][]report.Item) {
+				s["ip"] = []report.Item{fail("IPv4", "Not found"), fail("IPv6", "Not found")}
+				s["gateway"] = []report.Item{fail("Gateway", "Failed")}
+				s["ping_ipv4"] = []report.Item{fail("8.8.8.8", "Failed")}
+				s["dns_a"] = []report.Item{fail("google.com", "Failed - exit status 9")}
+			},
+			want: []string{"no-address"},
+		},
+		{
+			name: "gateway down hides everything downstream",
+			modify: func(s map[string][]report.Item) {
+				s["gateway"] = []report.Item{fail("Gateway", "Failed"), pass("IPv6 Gateway")}
+				s["ping_ipv4"] = []report.Item{fail("8.8.8.8", "Failed")}
+				s["dns_a"] = []report.Item{fail("google.com", "Failed - exit status 9")}
+			},
+			want: []string{"gateway-unreachable"},
+		},
+		{
+			name: "resolver timing out",
+			modify: func(s map[string][]report.Item) {
+				s["dns_a"] = []report.Item{fail("google.com", "Failed - command failed: dig: exit status 9")}
+				s["dns_aaaa"] = []report.Item{fail("google.com", "Failed - command failed: dig: exit status 9")}
+			},
+			want: []string{"resolver-timeout"},
+		},
+		{
+			name: "resolver refusing",
+			modify: func(s map[string][]report.Item) {
+				s["dns_a"] = []report.Item{fail("google.com", "Failed")}
+			},
+			want: []string{"resolver-failure"},
+		},
+		{
+			name: "broken IPv6 and partial loss",
+			modify: func(s map[string][]report.Item) {
+				s["ping_ipv4"] = []report.Item{pass("8.8.8.8"), fail("1.1.1.1", "Failed")}
+				s["ping_ipv6"] = []report.Item{fail("2001:4860:4860::8888", "Failed")}
+			},
+			want: []string{"ipv6-broken", "partial-loss"},
+		},
+		{
+			name: "weak Wi-Fi",
+			modify: func(s map[string][]report.Item) {
+				s["wireless"] = []report.Item{pass("Association"), fail("Link quality", "signal -82 dBm (weak)")}
+				s["ping_ipv4"] = []report.Item{pass("8.8.8.8"), fail("1.1.1.1", "Failed")}
+			},
+			want: []string{"weak-wifi", "partial-loss"},
+		},
+		{
+			name: "uplink down on a path device",
+			modify: func(s map[string][]report.Item) {
+				s["snmp"] = []report.Item{{Name: "router", Status: report.StatusFail, Summary: "core-rtr1, up 12d 3h, 2 interface(s), 1 down, 0 errors", Details: []string{"ge-0/0/1: admin up, oper down"}}}
+				s["ping_ipv4"] = []report.Item{pass("8.8.8.8"), fail("1.1.1.1", "Failed")}
+			},
+			want: []string{"path-device-interface-down", "partial-loss"},
+		},
+		{
+			name: "captive portal",
+			modify: func(s map[string][]report.Item) {
+				s["http_ipv4"] = []report.Item{fail("https://www.google.com", "Status 302")}
+			},
+			want: []string{"http-blocked"},
+		},
+		{
+			name: "QUIC blocked",
+			modify: func(s map[string][]report.Item) {
+				s["http_protocols"] = []report.Item{fail("https://www.google.com", "HTTP/1.1 40 ms, HTTP/2 35 ms, HTTP/3 failed - HTTP/3 fails while HTTP over TCP works (UDP/443 blocked?)")}
+			},
+			want: []string{"quic-blocked"},
+		},
+		{
+			name: "TLS interception and transparent proxy",
+			modify: func(s map[string][]report.Item) {
+				s["tls_interception"] = []report.Item{fail("www.example.com:443", `Certificate does not match any pinned fingerprint: issued by "Corp Proxy CA", TLS is intercepted`)}
+				s["middlebox"] = []report.Item{fail("tcp/80", "tcp/80: proxied, the connection is terminated or rewritten on the way")}
+			},
+			want: []string{"tls-interception", "transparent-proxy"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sections := healthy()
+			tt.modify(sections)
+
+			var got []string
+			for _, finding := range engine.Analyze(buildReport(sections)) {
+				got = append(got, finding.Rule.Name)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Expected findings %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestConditions(t *testing.T) {
+	r := &report.Report{}
+	r.Add(report.Section{ID: "dns_a", Items: []report.Item{pass("example.com"), fail("intranet.local", "Failed - NXDOMAIN")}})
+	r.Add(report.Section{ID: "http_ipv4", Error: "curl not found"})
+	r.Add(report.Section{ID: "traceroute", Items: []report.Item{{Name: "hop 1", Status: report.StatusInfo}}})
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"dns_a == degraded", true},
+		{"dns_a[example.com] == pass", true},
+		{"dns_a[intranet.local] == fail", true},
+		{"dns_a[intranet.local] =~ NXDOMAIN", true},
+		{"dns_a[example.com] =~ NXDOMAIN", false},
+		{"dns_a !~ SERVFAIL", true},
+		{"http_ipv4 == fail", true},
+		{"http_ipv4 =~ 'not found'", true},
+		{"traceroute == info", true},
+		{"ping_ipv6 == missing", true},
+		{"ping_ipv6 != missing", false},
+		{"ping_ipv6 !~ anything", true},
+		{"dns_a[other.example] == missing", true},
+	}
+
+	for _, tt := range tests {
+		c, err := parseCondition(tt.expr)
+		if err != nil {
+			t.Errorf("parseCondition(%q) failed: %v", tt.expr, err)
+			continue
+		}
+		if _, got := c.eval(r); got != tt.want {
+			t.Errorf("%q = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+
+	for _, expr := range []string{"dns_a", "dns_a == broken", "dns_a =~ (", "DNS == pass"} {
+		if _, err := parseCondition(expr); err == nil {
+			t.Errorf("Expected %q to be rejected", expr)
+		}
+	}
+}
+
+func TestEngineOverrides(t *testing.T) {
+	r := buildReport(map[string][]report.Item{"dns_a": {fail("google.com", "Failed")}})
+
+	engine, err := NewEngine(
+		Rule{Name: "dns", Priority: 10, When: []string{"dns_a == fail"}, Diagnosis: "built in"},
+		Rule{Name: "other", Priority: 5, When: []string{"dns_a != pass"}, Diagnosis: "other"},
+		Rule{Name: "dns", Priority: 1, When: []string{"dns_a == fail"}, Diagnosis: "ours"},
+		Rule{Name: "custom", Priority: 20, When: []string{"dns_a =~ Failed"}, Diagnosis: "custom"},
+		Rule{Name: "other"},
+	)
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+
+	findings := engine.Analyze(r)
+	if len(findings) != 2 {
+		t.Fatalf("Expected 2 findings, got %+v", findings)
+	}
+	if findings[0].Rule.Name != "custom" || findings[1].Rule.Diagnosis != "ours" {
+		t.Errorf("Expected custom rule first and the override second, got %+v", findings)
+	}
+	if strings.Join(findings[1].Evidence, ", ") != "dns_a is fail" {
+		t.Errorf("Unexpected evidence: %v", findings[1].Evidence)
+	}
+
+	if _, err := NewEngine(Rule{Name: "unknown"}); err == nil {
+		t.Error("Expected a rule without conditions and nothing to disable to be rejected")
+	}
+	if _, err := NewEngine(Rule{Name: "bad", When: []string{"dns_a ?? pass"}}); err == nil {
+		t.Error("Expected an invalid condition to be rejected")
+	}
+}
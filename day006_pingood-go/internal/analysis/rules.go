@@ -0,0 +1,155 @@
+package analysis
+
+// DefaultRules follow the dependency chain link → address → gateway →
+// Internet → DNS → HTTP, once for IPv4 and once for IPv6. Each rule only
+// fires when the step before it works, so the first failing step is what
+// gets reported rather than everything downstream of it.
+var DefaultRules = []Rule{
+	{
+		Name:      "wifi-disconnected",
+		Priority:  105,
+		When:      []string{"wireless[Association] == fail"},
+		Diagnosis: "The Wi-Fi interface is not associated with an access point",
+		Hint:      "Check that the SSID is in range and the credentials are correct",
+	},
+	{
+		Name:      "weak-wifi",
+		Priority:  97,
+		When:      []string{"wireless[Association] == pass", "wireless[Link quality] == fail"},
+		Diagnosis: "Weak Wi-Fi signal or high retry rate: radio problem, not the network",
+		Hint:      "Move closer to the access point or switch to a less crowded channel before blaming the network",
+	},
+	{
+		Name:      "no-address",
+		Priority:  100,
+		When:      []string{"ip == fail"},
+		Diagnosis: "The interface has no usable IP address: link down or address assignment failed",
+		Hint:      "Check the cable or Wi-Fi association, then the DHCP server or static configuration",
+	},
+	{
+		Name:      "no-ipv4-address",
+		Priority:  95,
+		When:      []string{"ip[IPv4] == fail", "ip[IPv6] == pass"},
+		Diagnosis: "Only IPv6 is configured: no IPv4 address on the interface",
+		Hint:      "Check the DHCP lease section; on IPv6-only networks make sure DNS64/NAT64 is provided",
+	},
+	{
+		Name:      "rogue-dhcp",
+		Priority:  93,
+		When:      []string{"dhcp[Probe answered by lease server] == fail"},
+		Diagnosis: "A DHCP server other than the one that issued the lease answered: possible rogue DHCP server",
+		Hint:      "Find the answering server's port on the switch and enable DHCP snooping",
+	},
+	{
+		Name:      "dhcp-mismatch",
+		Priority:  92,
+		When:      []string{"ip[IPv4] == pass", "dhcp[Address matches interface] == fail"},
+		Diagnosis: "The interface address differs from the DHCP lease: static configuration or a second DHCP client",
+		Hint:      "Check for a manually configured address or another network manager on the host",
+	},
+	{
+		Name:      "gateway-unreachable",
+		Priority:  90,
+		When:      []string{"ip[IPv4] == pass", "gateway[Gateway] == fail"},
+		Diagnosis: "IPv4 address present but the default gateway does not respond: local network or gateway problem",
+		Hint:      "Check the switch port, VLAN and the gateway itself; some gateways drop ICMP, so confirm with the neighbor entries",
+	},
+	{
+		Name:      "upstream-down",
+		Priority:  80,
+		When:      []string{"gateway[Gateway] == pass", "ping_ipv4 == fail"},
+		Diagnosis: "Gateway reachable but no IPv4 Internet host answers: upstream or ISP problem",
+		Hint:      "Check the WAN link on the router and the traceroute for the last responding hop",
+	},
+	{
+		Name:      "path-device-interface-down",
+		Priority:  85,
+		When:      []string{"snmp =~ admin up, oper"},
+		Diagnosis: "A network device on the expected path has an enabled interface that is down",
+		Hint:      "Check the interfaces listed in the SNMP section; a down uplink explains loss or a changed traceroute path",
+	},
+	{
+		Name:      "resolver-timeout",
+		Priority:  75,
+		When:      []string{"gateway[Gateway] == pass", "ping_ipv4 != fail", "dns_a == fail", `dns_a =~ exit status 9|timed out|no servers could be reached`},
+		Diagnosis: "Gateway reachable but DNS timing out: resolver problem",
+		Hint:      "Check the resolvers handed out by DHCP (see the DHCP lease section) and whether UDP/TCP 53 is blocked",
+	},
+	{
+		Name:      "resolver-failure",
+		Priority:  70,
+		When:      []string{"ping_ipv4 != fail", "dns_a == fail", `dns_a !~ exit status 9|timed out|no servers could be reached`},
+		Diagnosis: "Internet reachable but name resolution fails: resolver problem",
+		Hint:      "Try another resolver, e.g. dig @1.1.1.1, to tell a broken local resolver from filtering",
+	},
+	{
+		Name:      "http-blocked",
+		Priority:  60,
+		When:      []string{"ping_ipv4 == pass", "dns_a == pass", "http_ipv4 == fail"},
+		Diagnosis: "DNS and ICMP work but HTTP over IPv4 fails: proxy, firewall or captive portal",
+		Hint:      "Open the URL in a browser to spot a captive portal, and check proxy settings",
+	},
+	{
+		Name:      "quic-blocked",
+		Priority:  40,
+		When:      []string{"http_protocols =~ HTTP/3 fails while HTTP over TCP works"},
+		Diagnosis: "HTTP over TCP works but HTTP/3 fails: UDP/443 (QUIC) is blocked or dropped",
+		Hint:      "Browsers fall back to TCP after a delay; check firewall and middlebox rules for outbound UDP 443",
+	},
+	{
+		Name:      "tls-interception",
+		Priority:  58,
+		When:      []string{"tls_interception =~ does not match any pinned fingerprint"},
+		Diagnosis: "TLS connections are intercepted: an endpoint presented a certificate other than the pinned one",
+		Hint:      "Look up the issuer in the TLS interception section; it names the proxy or security product re-signing traffic",
+	},
+	{
+		Name:      "transparent-proxy",
+		Priority:  35,
+		When:      []string{"middlebox =~ proxied|intercepted"},
+		Diagnosis: "A transparent proxy or firewall answers for the echo endpoint on some ports",
+		Hint:      "Compare the TTL, MSS and client address of the affected ports with the baseline in the middlebox section",
+	},
+	{
+		Name:      "no-ipv6",
+		Priority:  50,
+		When:      []string{"ip[IPv4] == pass", "ip[IPv6] == fail"},
+		Diagnosis: "No global IPv6 address: the network does not offer IPv6",
+		Hint:      "Expected on IPv4-only networks; otherwise check router advertisements in the gateway section",
+	},
+	{
+		Name:      "ipv6-broken",
+		Priority:  65,
+		When:      []string{"ip[IPv6] == pass", "ping_ipv6 == fail", "ping_ipv4 != fail"},
+		Diagnosis: "IPv6 address assigned but the IPv6 Internet is unreachable: broken IPv6 upstream",
+		Hint:      "Applications may stall before falling back to IPv4; check the IPv6 gateway and router advertisements",
+	},
+	{
+		Name:      "aaaa-filtered",
+		Priority:  45,
+		When:      []string{"dns_a == pass", "dns_aaaa == fail"},
+		Diagnosis: "A lookups work but AAAA lookups fail: the resolver filters or breaks AAAA queries",
+		Hint:      "Compare with dig AAAA against a public resolver",
+	},
+	{
+		Name:      "http-ipv6-blocked",
+		Priority:  55,
+		When:      []string{"ping_ipv6 == pass", "dns_aaaa == pass", "http_ipv6 == fail"},
+		Diagnosis: "IPv6 reachable but HTTP over IPv6 fails: IPv6 firewall or path MTU problem",
+		Hint:      "Large packets may be dropped; check ICMPv6 Packet Too Big filtering",
+	},
+	{
+		Name:      "partial-loss",
+		Priority:  30,
+		When:      []string{"ping_ipv4 == degraded"},
+		Diagnosis: "Some IPv4 targets are unreachable or over their thresholds: partial outage or congestion",
+		Hint:      "Compare the failing targets' traceroutes; a shared hop points at the culprit",
+	},
+	{
+		Name:      "partial-dns",
+		Priority:  25,
+		When:      []string{"dns_a == degraded"},
+		Diagnosis: "Some names fail to resolve: split-horizon DNS or a filtering resolver",
+		Hint:      "Check whether the failing names are internal or blocked by policy",
+	},
+}
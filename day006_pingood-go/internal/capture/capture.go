@@ -0,0 +1,97 @@
+// Package capture records the packets a check sends and receives, using
+// AF_PACKET on Linux, writes them as pcap files and summarises what went
+// wrong on the wire: TCP retransmissions, resets and ICMP errors.
+package capture
+
+import (
+	"sync"
+	"time"
+)
+
+// Snaplen is how much of each packet is kept.
+const Snaplen = 65535
+
+// maxPackets bounds the memory a capture uses; later packets are counted
+// in Dropped but not kept.
+const maxPackets = 100000
+
+// source reads the IP packets of an interface. read returns n == 0 when no
+// packet arrived for a short while, so a stopped capture notices quickly.
+// length is the packet's length on the wire.
+type source interface {
+	read(b []byte) (n, length int, err error)
+	close() error
+}
+
+// Capture records the packets matching its rules until Stop.
+type Capture struct {
+	rules []Rule
+	src   source
+	stop  chan struct{}
+	done  chan error
+
+	mu      sync.Mutex
+	packets []Packet
+	dropped int
+}
+
+// Start captures the IP packets on iface that match rules. It needs root
+// or CAP_NET_RAW.
+func Start(iface string, rules []Rule) (*Capture, error) {
+	src, err := openSource(iface)
+	if err != nil {
+		return nil, err
+	}
+	return start(src, rules), nil
+}
+
+func start(src source, rules []Rule) *Capture {
+	c := &Capture{rules: rules, src: src, stop: make(chan struct{}), done: make(chan error, 1)}
+	go func() { c.done <- c.loop() }()
+	return c
+}
+
+func (c *Capture) loop() error {
+	buf := make([]byte, Snaplen)
+	for {
+		select {
+		case <-c.stop:
+			return nil
+		default:
+		}
+		n, length, err := c.src.read(buf)
+		if err != nil {
+			return err
+		}
+		if n == 0 || !Match(c.rules, buf[:n]) {
+			continue
+		}
+		c.mu.Lock()
+		if len(c.packets) < maxPackets {
+			c.packets = append(c.packets, Packet{Time: time.Now(), Data: append([]byte(nil), buf[:n]...), Length: length})
+		} else {
+			c.dropped++
+		}
+		c.mu.Unlock()
+	}
+}
+
+// Stop ends the capture and returns the packets it recorded.
+func (c *Capture) Stop() ([]Packet, error) {
+	close(c.stop)
+	err := <-c.done
+	if cerr := c.src.close(); err == nil {
+		err = cerr
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.packets, err
+}
+
+// Dropped is how many matching packets were not kept because the capture
+// was full.
+func (c *Capture) Dropped() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.dropped
+}
@@ -0,0 +1,60 @@
+package capture
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// packetSource is an AF_PACKET socket in cooked mode, so packets come
+// without a link-layer header whatever the interface is.
+type packetSource struct {
+	fd       int
+	loopback bool
+}
+
+func htons(v uint16) uint16 {
+	return v<<8 | v>>8
+}
+
+func openSource(iface string) (source, error) {
+	ifi, err := net.InterfaceByName(iface)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find interface %s: %w", iface, err)
+	}
+	fd, err := syscall.Socket(syscall.AF_PACKET, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, int(htons(syscall.ETH_P_ALL)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open packet socket (needs root or CAP_NET_RAW): %w", err)
+	}
+	if err := syscall.Bind(fd, &syscall.SockaddrLinklayer{Protocol: htons(syscall.ETH_P_ALL), Ifindex: ifi.Index}); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("failed to bind packet socket to %s: %w", iface, err)
+	}
+	timeout := syscall.NsecToTimeval(int64(100 * 1e6))
+	if err := syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &timeout); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("failed to set packet socket timeout: %w", err)
+	}
+	return &packetSource{fd: fd, loopback: ifi.Flags&net.FlagLoopback != 0}, nil
+}
+
+func (s *packetSource) read(b []byte) (int, int, error) {
+	// MSG_TRUNC makes the length the one on the wire even when b is shorter.
+	length, from, err := syscall.Recvfrom(s.fd, b, syscall.MSG_TRUNC)
+	if errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EINTR) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read packet: %w", err)
+	}
+	// The loopback interface shows every packet both as sent and received.
+	if ll, ok := from.(*syscall.SockaddrLinklayer); ok && s.loopback && ll.Pkttype == syscall.PACKET_OUTGOING {
+		return 0, 0, nil
+	}
+	return min(length, len(b)), length, nil
+}
+
+func (s *packetSource) close() error {
+	return syscall.Close(s.fd)
+}
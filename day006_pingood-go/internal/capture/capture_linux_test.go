@@ -0,0 +1,79 @@
+package capture
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestCaptureLoopback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	port := netip.MustParseAddrPort(server.Listener.Addr().String()).Port()
+
+	// A port nothing listens on, for a reset and a port unreachable.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := netip.MustParseAddrPort(l.Addr().String()).Port()
+	l.Close()
+
+	c, err := Start("lo", []Rule{{Protocol: TCP, Port: port}, {Port: closed}})
+	if errors.Is(err, syscall.EPERM) || errors.Is(err, syscall.EACCES) {
+		t.Skipf("No permission to capture: %v", err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if conn, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(int(closed)))); err == nil {
+		conn.Close()
+	}
+	if conn, err := net.Dial("udp", net.JoinHostPort("127.0.0.1", strconv.Itoa(int(closed)))); err == nil {
+		conn.Write([]byte("ping"))
+		conn.Close()
+	}
+	// Unrelated traffic on the same interface stays out.
+	if conn, err := net.Dial("udp", "127.0.0.1:9"); err == nil {
+		conn.Write([]byte("ping"))
+		conn.Close()
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	packets, err := c.Stop()
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary := Summarize(packets)
+	var flows []string
+	for _, f := range summary.Flows {
+		flows = append(flows, f.String())
+	}
+	if len(summary.Flows) != 3 {
+		t.Errorf("Expected the HTTP, reset and UDP flows, got %v", flows)
+	}
+	if summary.Resets != 1 {
+		t.Errorf("Expected 1 reset, got %d in %v", summary.Resets, flows)
+	}
+	if len(summary.ICMPErrors) != 1 || !strings.HasPrefix(summary.ICMPErrors[0].String(), "port unreachable from 127.0.0.1 for udp") {
+		t.Errorf("Expected a port unreachable, got %v", summary.ICMPErrors)
+	}
+	if summary.Retransmissions != 0 {
+		t.Errorf("Expected no retransmissions on loopback, got %d", summary.Retransmissions)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package capture
+
+import "errors"
+
+func openSource(iface string) (source, error) {
+	return nil, errors.New("packet capture needs AF_PACKET, which only Linux has")
+}
@@ -0,0 +1,248 @@
+package capture
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func ipPacket(src, dst string, protocol byte, l4 []byte) []byte {
+	s, d := netip.MustParseAddr(src), netip.MustParseAddr(dst)
+	if s.Is4() {
+		b := make([]byte, 20, 20+len(l4))
+		b[0] = 0x45
+		binary.BigEndian.PutUint16(b[2:], uint16(20+len(l4)))
+		b[8], b[9] = 64, protocol
+		copy(b[12:], s.AsSlice())
+		copy(b[16:], d.AsSlice())
+		return append(b, l4...)
+	}
+	b := make([]byte, 40, 40+len(l4))
+	b[0] = 0x60
+	binary.BigEndian.PutUint16(b[4:], uint16(len(l4)))
+	b[6], b[7] = protocol, 64
+	copy(b[8:], s.AsSlice())
+	copy(b[24:], d.AsSlice())
+	return append(b, l4...)
+}
+
+func tcpSegment(sport, dport uint16, seq uint32, flags byte, payload int) []byte {
+	b := make([]byte, 20+payload)
+	binary.BigEndian.PutUint16(b[0:], sport)
+	binary.BigEndian.PutUint16(b[2:], dport)
+	binary.BigEndian.PutUint32(b[4:], seq)
+	b[12], b[13] = 5<<4, flags
+	return b
+}
+
+func udpDatagram(sport, dport uint16) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint16(b[0:], sport)
+	binary.BigEndian.PutUint16(b[2:], dport)
+	return b
+}
+
+func icmpMessageBody(typ, code byte, quoted []byte) []byte {
+	return append([]byte{typ, code, 0, 0, 0, 0, 0, 0}, quoted...)
+}
+
+func packets(data ...[]byte) []Packet {
+	start := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	var ps []Packet
+	for i, d := range data {
+		ps = append(ps, Packet{Time: start.Add(time.Duration(i) * time.Millisecond), Data: d, Length: len(d)})
+	}
+	return ps
+}
+
+func TestSummarize(t *testing.T) {
+	const client, server = "192.0.2.10", "198.51.100.1"
+	probe := ipPacket(client, "203.0.113.9", 17, udpDatagram(33434, 33435))
+	summary := Summarize(packets(
+		ipPacket(client, server, 6, tcpSegment(50000, 443, 1000, flagSYN, 0)),
+		ipPacket(client, server, 6, tcpSegment(50000, 443, 1000, flagSYN, 0)), // SYN retransmitted
+		ipPacket(server, client, 6, tcpSegment(443, 50000, 5000, flagSYN|0x10, 0)),
+		ipPacket(client, server, 6, tcpSegment(50000, 443, 1001, 0x10, 100)),
+		ipPacket(client, server, 6, tcpSegment(50000, 443, 1101, 0x10, 100)),
+		ipPacket(client, server, 6, tcpSegment(50000, 443, 1001, 0x10, 100)), // retransmitted
+		ipPacket(client, server, 6, tcpSegment(50000, 443, 1200, 0x10, 1)),   // keep-alive
+		ipPacket(server, client, 6, tcpSegment(443, 50000, 5001, flagRST, 0)),
+		ipPacket(client, "203.0.113.9", 17, udpDatagram(33434, 33435)),
+		ipPacket("10.0.0.1", client, 1, icmpMessageBody(11, 0, probe[:28])),
+		ipPacket("2001:db8::1", "2001:db8::2", 58, icmpMessageBody(128, 0, nil)),
+		ipPacket("2001:db8::ff", "2001:db8::1", 58, icmpMessageBody(1, 4, ipPacket("2001:db8::1", "2001:db8::ff", 17, udpDatagram(40000, 53)))),
+	))
+
+	if summary.Packets != 12 || summary.Retransmissions != 2 || summary.Resets != 1 {
+		t.Errorf("Expected 12 packets, 2 retransmissions and 1 reset, got %+v", summary)
+	}
+	var flows []string
+	for _, f := range summary.Flows {
+		flows = append(flows, f.String())
+	}
+	want := []string{
+		"tcp 192.0.2.10:50000 → 198.51.100.1:443",
+		"udp 192.0.2.10:33434 → 203.0.113.9:33435",
+		"icmp 2001:db8::1 → 2001:db8::2",
+	}
+	if !reflect.DeepEqual(flows, want) {
+		t.Errorf("Expected flows %v, got %v", want, flows)
+	}
+	if f := summary.Flows[0]; f.Packets != 8 || f.Retransmissions != 2 || f.Resets != 1 {
+		t.Errorf("Unexpected TCP flow %+v", f)
+	}
+
+	var errors []string
+	for _, e := range summary.ICMPErrors {
+		errors = append(errors, e.String())
+	}
+	want = []string{
+		"TTL exceeded in transit from 10.0.0.1 for udp 192.0.2.10:33434 → 203.0.113.9:33435",
+		"port unreachable from 2001:db8::ff for udp [2001:db8::1]:40000 → [2001:db8::ff]:53",
+	}
+	if !reflect.DeepEqual(errors, want) {
+		t.Errorf("Expected ICMP errors %v, got %v", want, errors)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	dns := ipPacket("192.0.2.10", "192.0.2.53", 17, udpDatagram(40000, 53))
+	https := ipPacket("192.0.2.10", "198.51.100.1", 6, tcpSegment(50000, 443, 1, flagSYN, 0))
+	ping := ipPacket("2001:db8::1", "2001:4860:4860::8888", 58, icmpMessageBody(128, 0, nil))
+	unreachable := ipPacket("192.0.2.53", "192.0.2.10", 1, icmpMessageBody(3, 3, dns[:28]))
+
+	for _, tt := range []struct {
+		rule Rule
+		want []bool // dns, https, ping, unreachable
+	}{
+		{Rule{}, []bool{true, true, true, true}},
+		{Rule{Protocol: UDP, Port: 53}, []bool{true, false, false, true}},
+		{Rule{Protocol: TCP, Host: netip.MustParseAddr("198.51.100.1")}, []bool{false, true, false, false}},
+		{Rule{Protocol: ICMP, Host: netip.MustParseAddr("2001:4860:4860::8888")}, []bool{false, false, true, false}},
+		{Rule{Port: 443}, []bool{false, true, false, false}},
+		{Rule{Host: netip.MustParseAddr("::ffff:192.0.2.53")}, []bool{true, false, false, true}},
+	} {
+		for i, data := range [][]byte{dns, https, ping, unreachable} {
+			if got := Match([]Rule{tt.rule}, data); got != tt.want[i] {
+				t.Errorf("%s matching packet %d: expected %v, got %v", tt.rule, i, tt.want[i], got)
+			}
+		}
+	}
+	if Match(nil, []byte{0x00, 0x01, 0x08, 0x00}) {
+		t.Error("Expected a non-IP packet not to match")
+	}
+}
+
+func TestParseTruncated(t *testing.T) {
+	// Cut after the ports, as in the 8 bytes an ICMPv4 error must quote.
+	h, ok := parse(ipPacket("192.0.2.10", "198.51.100.1", 6, tcpSegment(50000, 443, 1, flagSYN, 100))[:28])
+	if !ok || h.protocol != TCP || h.srcPort != 50000 || h.dstPort != 443 || h.tcpFlags != 0 {
+		t.Errorf("Unexpected header %+v", h)
+	}
+	if _, ok := parse(make([]byte, 10)); ok {
+		t.Error("Expected a short packet to fail")
+	}
+}
+
+func TestWritePcap(t *testing.T) {
+	data := ipPacket("192.0.2.10", "192.0.2.53", 17, udpDatagram(40000, 53))
+	var b bytes.Buffer
+	if err := WritePcap(&b, []Packet{{Time: time.Unix(1700000000, 123), Data: data, Length: 1500}}); err != nil {
+		t.Fatal(err)
+	}
+	out := b.Bytes()
+	if len(out) != 24+16+len(data) {
+		t.Fatalf("Unexpected pcap length %d", len(out))
+	}
+	le := binary.LittleEndian
+	if le.Uint32(out[0:]) != pcapMagicNanoseconds || le.Uint32(out[20:]) != linkTypeRaw {
+		t.Errorf("Unexpected pcap header % x", out[:24])
+	}
+	if le.Uint32(out[24:]) != 1700000000 || le.Uint32(out[28:]) != 123 || le.Uint32(out[32:]) != uint32(len(data)) || le.Uint32(out[36:]) != 1500 {
+		t.Errorf("Unexpected record header % x", out[24:40])
+	}
+	if !bytes.Equal(out[40:], data) {
+		t.Error("Expected the packet after its record header")
+	}
+}
+
+// fakeSource hands out packets, then reports nothing until closed.
+type fakeSource struct {
+	packets [][]byte
+	closed  bool
+}
+
+func (s *fakeSource) read(b []byte) (int, int, error) {
+	if len(s.packets) == 0 {
+		time.Sleep(time.Millisecond)
+		return 0, 0, nil
+	}
+	n := copy(b, s.packets[0])
+	length := len(s.packets[0])
+	s.packets = s.packets[1:]
+	return n, length, nil
+}
+
+func (s *fakeSource) close() error {
+	s.closed = true
+	return nil
+}
+
+func TestCapture(t *testing.T) {
+	dns := ipPacket("192.0.2.10", "192.0.2.53", 17, udpDatagram(40000, 53))
+	https := ipPacket("192.0.2.10", "198.51.100.1", 6, tcpSegment(50000, 443, 1, flagSYN, 0))
+	src := &fakeSource{packets: [][]byte{dns, https, dns}}
+	c := start(src, []Rule{{Protocol: UDP, Port: 53}})
+	for deadline := time.Now().Add(time.Second); ; {
+		c.mu.Lock()
+		n := len(c.packets)
+		c.mu.Unlock()
+		if n == 2 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	got, err := c.Stop()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || !bytes.Equal(got[0].Data, dns) || !src.closed {
+		t.Errorf("Expected the two DNS packets and a closed source, got %d packets", len(got))
+	}
+	if !strings.HasPrefix(Summarize(got).Flows[0].String(), "udp 192.0.2.10:40000") {
+		t.Errorf("Unexpected flows %v", Summarize(got).Flows)
+	}
+}
+
+func TestParseSegment(t *testing.T) {
+	synAck := tcpSegment(443, 50000, 1, flagSYN|0x10, 0)
+	options := []byte{2, 4, 0x05, 0x64, 4, 2, 8, 10, 0, 0, 0, 1, 0, 0, 0, 0, 1, 3, 3, 7}
+	synAck = append(synAck[:20], options...)
+	synAck[12] = byte((20+len(options))/4) << 4
+	binary.BigEndian.PutUint16(synAck[14:], 65160)
+	data := ipPacket("198.51.100.1", "192.0.2.10", 6, synAck)
+
+	s, ok := ParseSegment(data)
+	if !ok {
+		t.Fatal("Expected a TCP segment")
+	}
+	want := Segment{
+		Src:     netip.MustParseAddrPort("198.51.100.1:443"),
+		Dst:     netip.MustParseAddrPort("192.0.2.10:50000"),
+		TTL:     64,
+		Flags:   flagSYN | 0x10,
+		Window:  65160,
+		MSS:     1380,
+		Options: "mss,sack,ts,nop,ws",
+	}
+	if s != want || !s.SYN() || !s.ACK() || s.RST() {
+		t.Errorf("Expected %+v, got %+v", want, s)
+	}
+	if _, ok := ParseSegment(ipPacket("192.0.2.10", "192.0.2.53", 17, udpDatagram(40000, 53))); ok {
+		t.Error("Expected UDP not to be a segment")
+	}
+}
@@ -0,0 +1,291 @@
+package capture
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"time"
+)
+
+// Protocols a Rule can name. ICMP covers ICMPv6 too.
+const (
+	TCP  = "tcp"
+	UDP  = "udp"
+	ICMP = "icmp"
+)
+
+// Rule is a 5-tuple pattern for the traffic of a check. It matches
+// packets in either direction: Host may be the source or the destination,
+// Port the source or the destination port. Zero fields match anything.
+// ICMP errors match when the packet they quote does, so a "port
+// unreachable" or a traceroute's "time exceeded" from a router stays with
+// the flow it is about.
+type Rule struct {
+	Protocol string
+	Host     netip.Addr
+	Port     uint16
+}
+
+func (r Rule) String() string {
+	protocol, host, port := r.Protocol, "*", "*"
+	if protocol == "" {
+		protocol = "ip"
+	}
+	if r.Host.IsValid() {
+		host = r.Host.String()
+	}
+	if r.Port != 0 {
+		port = strconv.Itoa(int(r.Port))
+	}
+	return fmt.Sprintf("%s host %s port %s", protocol, host, port)
+}
+
+func (r Rule) match(h *header) bool {
+	if r.Protocol != "" && r.Protocol != h.protocol {
+		return false
+	}
+	if r.Host.IsValid() && r.Host.Unmap() != h.src && r.Host.Unmap() != h.dst {
+		return false
+	}
+	if r.Port != 0 && (!h.hasPorts() || (r.Port != h.srcPort && r.Port != h.dstPort)) {
+		return false
+	}
+	return true
+}
+
+// Match reports whether an IP packet matches any of rules. Without rules
+// every IP packet matches.
+func Match(rules []Rule, data []byte) bool {
+	h, ok := parse(data)
+	if !ok {
+		return false
+	}
+	if len(rules) == 0 {
+		return true
+	}
+	for _, r := range rules {
+		if r.match(&h) || (h.quoted != nil && r.match(h.quoted)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Packet is an IP packet as it was captured.
+type Packet struct {
+	Time time.Time
+	Data []byte
+	// Length is the length of the packet on the wire, more than len(Data)
+	// when the packet was cut at Snaplen.
+	Length int
+}
+
+// TCP flags.
+const (
+	flagFIN = 0x01
+	flagSYN = 0x02
+	flagRST = 0x04
+)
+
+// header is what the summaries need from the IP and transport headers.
+type header struct {
+	protocol         string
+	ipv6             bool
+	ttl              int
+	src, dst         netip.Addr
+	srcPort, dstPort uint16
+
+	tcpFlags byte
+	seq      uint32
+	// tcp is the TCP header, options included, as far as it was captured.
+	tcp []byte
+	// payload is the TCP payload length, from the IP header so that it
+	// holds for truncated packets too.
+	payload int
+
+	icmpType, icmpCode byte
+	// quoted is the start of the packet an ICMP error is about.
+	quoted *header
+}
+
+func (h *header) hasPorts() bool {
+	return h.protocol == TCP || h.protocol == UDP
+}
+
+// parse reads the headers of an IP packet. It accepts packets cut short,
+// as quoted in ICMP errors, as long as the IP header is complete.
+func parse(data []byte) (header, bool) {
+	var h header
+	if len(data) == 0 {
+		return h, false
+	}
+	var next byte
+	var rest []byte
+	var length int
+	switch data[0] >> 4 {
+	case 4:
+		ihl := int(data[0]&0x0f) * 4
+		if ihl < 20 || len(data) < ihl {
+			return h, false
+		}
+		h.ttl = int(data[8])
+		h.src = netip.AddrFrom4([4]byte(data[12:16]))
+		h.dst = netip.AddrFrom4([4]byte(data[16:20]))
+		next = data[9]
+		rest = data[ihl:]
+		length = int(binary.BigEndian.Uint16(data[2:4])) - ihl
+		if binary.BigEndian.Uint16(data[6:8])&0x1fff != 0 {
+			// A later fragment carries no transport header.
+			h.protocol = strconv.Itoa(int(next))
+			return h, true
+		}
+	case 6:
+		if len(data) < 40 {
+			return h, false
+		}
+		h.ipv6 = true
+		h.ttl = int(data[7])
+		h.src = netip.AddrFrom16([16]byte(data[8:24]))
+		h.dst = netip.AddrFrom16([16]byte(data[24:40]))
+		next = data[6]
+		rest = data[40:]
+		length = int(binary.BigEndian.Uint16(data[4:6]))
+		for done := false; !done; {
+			switch next {
+			case 0, 43, 60: // hop-by-hop, routing, destination options
+				if len(rest) < 8 {
+					return h, true
+				}
+				n := (int(rest[1]) + 1) * 8
+				if len(rest) < n {
+					return h, true
+				}
+				next, rest, length = rest[0], rest[n:], length-n
+			case 44: // fragment
+				if len(rest) < 8 {
+					return h, true
+				}
+				if binary.BigEndian.Uint16(rest[2:4])&0xfff8 != 0 {
+					h.protocol = strconv.Itoa(int(rest[0]))
+					return h, true
+				}
+				next, rest, length = rest[0], rest[8:], length-8
+			default:
+				done = true
+			}
+		}
+	default:
+		return h, false
+	}
+
+	switch next {
+	case 6:
+		h.protocol = TCP
+		if len(rest) >= 4 {
+			h.srcPort = binary.BigEndian.Uint16(rest[0:2])
+			h.dstPort = binary.BigEndian.Uint16(rest[2:4])
+		}
+		if len(rest) >= 20 {
+			h.seq = binary.BigEndian.Uint32(rest[4:8])
+			h.tcpFlags = rest[13]
+			h.payload = max(length-int(rest[12]>>4)*4, 0)
+			h.tcp = rest[:min(int(rest[12]>>4)*4, len(rest))]
+		}
+	case 17:
+		h.protocol = UDP
+		if len(rest) >= 4 {
+			h.srcPort = binary.BigEndian.Uint16(rest[0:2])
+			h.dstPort = binary.BigEndian.Uint16(rest[2:4])
+		}
+	case 1, 58:
+		h.protocol = ICMP
+		if len(rest) >= 2 {
+			h.icmpType, h.icmpCode = rest[0], rest[1]
+		}
+		if h.isICMPError() && len(rest) > 8 {
+			if quoted, ok := parse(rest[8:]); ok {
+				h.quoted = &quoted
+			}
+		}
+	default:
+		h.protocol = strconv.Itoa(int(next))
+	}
+	return h, true
+}
+
+func (h *header) isICMPError() bool {
+	if h.protocol != ICMP {
+		return false
+	}
+	if h.ipv6 {
+		return h.icmpType >= 1 && h.icmpType <= 4
+	}
+	switch h.icmpType {
+	case 3, 4, 11, 12:
+		return true
+	}
+	return false
+}
+
+// icmpMessage names an ICMP error as RFC 792 and RFC 4443 do.
+func icmpMessage(ipv6 bool, typ, code byte) string {
+	if ipv6 {
+		switch typ {
+		case 1:
+			switch code {
+			case 0:
+				return "no route to destination"
+			case 1:
+				return "communication administratively prohibited"
+			case 3:
+				return "address unreachable"
+			case 4:
+				return "port unreachable"
+			case 5:
+				return "source address failed ingress/egress policy"
+			case 6:
+				return "reject route to destination"
+			}
+			return "destination unreachable"
+		case 2:
+			return "packet too big"
+		case 3:
+			if code == 1 {
+				return "fragment reassembly time exceeded"
+			}
+			return "hop limit exceeded in transit"
+		case 4:
+			return "parameter problem"
+		}
+	} else {
+		switch typ {
+		case 3:
+			switch code {
+			case 0:
+				return "network unreachable"
+			case 1:
+				return "host unreachable"
+			case 2:
+				return "protocol unreachable"
+			case 3:
+				return "port unreachable"
+			case 4:
+				return "fragmentation needed"
+			case 9, 10, 13:
+				return "communication administratively prohibited"
+			}
+			return "destination unreachable"
+		case 4:
+			return "source quench"
+		case 11:
+			if code == 1 {
+				return "fragment reassembly time exceeded"
+			}
+			return "TTL exceeded in transit"
+		case 12:
+			return "parameter problem"
+		}
+	}
+	return fmt.Sprintf("type %d code %d", typ, code)
+}
@@ -0,0 +1,64 @@
+package capture
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	pcapMagicNanoseconds = 0xa1b23c4d
+	// linkTypeRaw is raw IPv4 or IPv6 without a link-layer header.
+	linkTypeRaw = 101
+)
+
+// WritePcap writes packets as a pcap file, with nanosecond timestamps, that
+// tcpdump and Wireshark read.
+func WritePcap(w io.Writer, packets []Packet) error {
+	header := make([]byte, 24)
+	binary.LittleEndian.PutUint32(header[0:], pcapMagicNanoseconds)
+	binary.LittleEndian.PutUint16(header[4:], 2)
+	binary.LittleEndian.PutUint16(header[6:], 4)
+	binary.LittleEndian.PutUint32(header[16:], Snaplen)
+	binary.LittleEndian.PutUint32(header[20:], linkTypeRaw)
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	record := make([]byte, 16)
+	for _, p := range packets {
+		binary.LittleEndian.PutUint32(record[0:], uint32(p.Time.Unix()))
+		binary.LittleEndian.PutUint32(record[4:], uint32(p.Time.Nanosecond()))
+		binary.LittleEndian.PutUint32(record[8:], uint32(len(p.Data)))
+		binary.LittleEndian.PutUint32(record[12:], uint32(max(p.Length, len(p.Data))))
+		if _, err := w.Write(record); err != nil {
+			return err
+		}
+		if _, err := w.Write(p.Data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WritePcapFile writes packets to a pcap file at path.
+func WritePcapFile(path string, packets []Packet) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create pcap file: %w", err)
+	}
+	w := bufio.NewWriter(f)
+	if err := WritePcap(w, packets); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write pcap file: %w", err)
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write pcap file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write pcap file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package capture
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// Segment is what a captured TCP segment tells about the stack that sent
+// it. Transparent proxies give themselves away when the handshake on one
+// port differs from the one on another.
+type Segment struct {
+	Src, Dst netip.AddrPort
+	// TTL is the IPv4 TTL or the IPv6 hop limit.
+	TTL    int
+	Flags  byte
+	Window int
+	// MSS is the maximum segment size option, 0 without one.
+	MSS int
+	// Options are the kinds of the TCP options in order, such as
+	// "mss,sack,ts,nop,ws".
+	Options string
+}
+
+func (s Segment) SYN() bool { return s.Flags&flagSYN != 0 }
+
+func (s Segment) ACK() bool { return s.Flags&0x10 != 0 }
+
+func (s Segment) RST() bool { return s.Flags&flagRST != 0 }
+
+// ParseSegment reads the TCP segment in an IP packet.
+func ParseSegment(data []byte) (Segment, bool) {
+	h, ok := parse(data)
+	if !ok || h.protocol != TCP || len(h.tcp) < 20 {
+		return Segment{}, false
+	}
+	s := Segment{
+		Src:    netip.AddrPortFrom(h.src, h.srcPort),
+		Dst:    netip.AddrPortFrom(h.dst, h.dstPort),
+		TTL:    h.ttl,
+		Flags:  h.tcpFlags,
+		Window: int(binary.BigEndian.Uint16(h.tcp[14:16])),
+	}
+	var kinds []string
+	for opts := h.tcp[20:]; len(opts) > 0; {
+		kind := opts[0]
+		if kind == 0 {
+			kinds = append(kinds, "eol")
+			break
+		}
+		if kind == 1 {
+			kinds = append(kinds, "nop")
+			opts = opts[1:]
+			continue
+		}
+		if len(opts) < 2 || int(opts[1]) < 2 || int(opts[1]) > len(opts) {
+			break
+		}
+		option := opts[:opts[1]]
+		switch kind {
+		case 2:
+			kinds = append(kinds, "mss")
+			if len(option) == 4 {
+				s.MSS = int(binary.BigEndian.Uint16(option[2:4]))
+			}
+		case 3:
+			kinds = append(kinds, "ws")
+		case 4:
+			kinds = append(kinds, "sack")
+		case 8:
+			kinds = append(kinds, "ts")
+		default:
+			kinds = append(kinds, strconv.Itoa(int(kind)))
+		}
+		opts = opts[len(option):]
+	}
+	s.Options = strings.Join(kinds, ",")
+	return s, true
+}
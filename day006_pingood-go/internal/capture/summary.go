@@ -0,0 +1,143 @@
+package capture
+
+import (
+	"fmt"
+	"net/netip"
+	"time"
+)
+
+// Summary is what was seen on the wire during a capture.
+type Summary struct {
+	Packets int
+	Bytes   int
+	// Flows are in the order their first packet was seen. ICMP errors are
+	// not flows of their own; they are in ICMPErrors.
+	Flows           []Flow
+	Retransmissions int
+	Resets          int
+	ICMPErrors      []ICMPError
+}
+
+// Flow is the packets of one 5-tuple in both directions. Src is the side
+// that sent the first packet. Pings have no ports.
+type Flow struct {
+	Protocol        string
+	Src, Dst        netip.AddrPort
+	Packets         int
+	Bytes           int
+	Retransmissions int
+	Resets          int
+}
+
+func (f Flow) String() string {
+	return formatFlow(f.Protocol, f.Src, f.Dst)
+}
+
+// ICMPError is an ICMP error message and the flow it is about.
+type ICMPError struct {
+	Time    time.Time
+	From    netip.Addr
+	Message string
+	// About is the flow of the packet the error quotes, if it quoted one.
+	About string
+}
+
+func (e ICMPError) String() string {
+	if e.About == "" {
+		return fmt.Sprintf("%s from %s", e.Message, e.From)
+	}
+	return fmt.Sprintf("%s from %s for %s", e.Message, e.From, e.About)
+}
+
+func formatFlow(protocol string, src, dst netip.AddrPort) string {
+	if src.Port() == 0 && dst.Port() == 0 {
+		return fmt.Sprintf("%s %s → %s", protocol, src.Addr(), dst.Addr())
+	}
+	return fmt.Sprintf("%s %s → %s", protocol, src, dst)
+}
+
+type flowKey struct {
+	protocol string
+	a, b     netip.AddrPort
+}
+
+// tcpSender follows the sequence numbers one side of a TCP flow has sent.
+type tcpSender struct {
+	end  uint32
+	seen bool
+}
+
+// Summarize counts flows, TCP retransmissions, resets and ICMP errors. A
+// segment is a retransmission when it carries nothing past the highest
+// sequence number its sender already sent, except for keep-alives.
+func Summarize(packets []Packet) Summary {
+	var s Summary
+	flows := make(map[flowKey]int)
+	senders := make(map[flowKey]*tcpSender)
+	for _, p := range packets {
+		h, ok := parse(p.Data)
+		if !ok {
+			continue
+		}
+		s.Packets++
+		s.Bytes += p.Length
+
+		if h.isICMPError() {
+			e := ICMPError{Time: p.Time, From: h.src, Message: icmpMessage(h.ipv6, h.icmpType, h.icmpCode)}
+			if q := h.quoted; q != nil {
+				e.About = formatFlow(q.protocol, netip.AddrPortFrom(q.src, q.srcPort), netip.AddrPortFrom(q.dst, q.dstPort))
+			}
+			s.ICMPErrors = append(s.ICMPErrors, e)
+			continue
+		}
+
+		src, dst := netip.AddrPortFrom(h.src, h.srcPort), netip.AddrPortFrom(h.dst, h.dstPort)
+		i, ok := flows[flowKey{h.protocol, src, dst}]
+		if !ok {
+			i, ok = flows[flowKey{h.protocol, dst, src}]
+		}
+		if !ok {
+			i = len(s.Flows)
+			flows[flowKey{h.protocol, src, dst}] = i
+			s.Flows = append(s.Flows, Flow{Protocol: h.protocol, Src: src, Dst: dst})
+		}
+		flow := &s.Flows[i]
+		flow.Packets++
+		flow.Bytes += p.Length
+
+		if h.protocol != TCP {
+			continue
+		}
+		if h.tcpFlags&flagRST != 0 {
+			flow.Resets++
+			s.Resets++
+			continue
+		}
+		length := uint32(h.payload)
+		if h.tcpFlags&flagSYN != 0 {
+			length++
+		}
+		if h.tcpFlags&flagFIN != 0 {
+			length++
+		}
+		if length == 0 {
+			continue
+		}
+		key := flowKey{h.protocol, src, dst}
+		sender := senders[key]
+		if sender == nil {
+			sender = &tcpSender{}
+			senders[key] = sender
+		}
+		end := h.seq + length
+		keepAlive := h.payload <= 1 && h.tcpFlags&(flagSYN|flagFIN) == 0 && end == sender.end
+		switch {
+		case sender.seen && int32(end-sender.end) <= 0 && !keepAlive:
+			flow.Retransmissions++
+			s.Retransmissions++
+		case !sender.seen || int32(end-sender.end) > 0:
+			sender.end, sender.seen = end, true
+		}
+	}
+	return s
+}
@@ -0,0 +1,138 @@
+package checker
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Assertion is a threshold on a named metric, written in the config as
+// e.g. "p95 < 100ms", "loss <= 1%", "download_mbps >= 50Mbps",
+// "signal >= -70dBm" or "duplicates == 0". Units are optional and are not
+// converted, but one that does not match the metric is an error.
+type Assertion struct {
+	Expr   string
+	Metric string
+	Op     string
+	Value  float64
+}
+
+type AssertionResult struct {
+	Assertion Assertion
+	Actual    float64
+	Passed    bool
+	Error     error
+}
+
+var assertionRe = regexp.MustCompile(`^\s*([a-z0-9_]+)\s*(<=|>=|==|!=|<|>)\s*(-?\d+(?:\.\d+)?)\s*(ms|%|[Mm]bps|dBm)?\s*$`)
+
+func ParseAssertion(expr string) (Assertion, error) {
+	matches := assertionRe.FindStringSubmatch(expr)
+	if matches == nil {
+		return Assertion{}, fmt.Errorf("invalid assertion %q: expected \"<metric> <op> <value>\"", expr)
+	}
+
+	value, err := strconv.ParseFloat(matches[3], 64)
+	if err != nil {
+		return Assertion{}, fmt.Errorf("invalid assertion %q: %w", expr, err)
+	}
+
+	if unit := matches[4]; unit != "" {
+		want := metricUnit(matches[1])
+		switch {
+		case want == "":
+			return Assertion{}, fmt.Errorf("invalid assertion %q: %s has no unit", expr, matches[1])
+		case !strings.EqualFold(unit, want):
+			return Assertion{}, fmt.Errorf("invalid assertion %q: %s is in %s, not %s", expr, matches[1], want, unit)
+		}
+	}
+
+	return Assertion{
+		Expr:   strings.TrimSpace(expr),
+		Metric: matches[1],
+		Op:     matches[2],
+		Value:  value,
+	}, nil
+}
+
+// metricUnit returns the unit the checks report metric in, or "" for counts
+// and other unitless values.
+func metricUnit(metric string) string {
+	switch metric {
+	case "loss", "retry_rate":
+		return "%"
+	case "min", "avg", "max", "stddev", "jitter", "p50", "p95", "p99":
+		return "ms"
+	case "signal", "noise":
+		return "dBm"
+	case "tx_bitrate", "rx_bitrate":
+		return "Mbps"
+	}
+	switch {
+	case strings.HasSuffix(metric, "_ms"), strings.HasSuffix(metric, "_latency"), strings.HasSuffix(metric, "_bufferbloat"):
+		return "ms"
+	case strings.HasSuffix(metric, "_mbps"):
+		return "Mbps"
+	}
+	return ""
+}
+
+func ParseAssertions(exprs []string) ([]Assertion, error) {
+	var assertions []Assertion
+	for _, expr := range exprs {
+		assertion, err := ParseAssertion(expr)
+		if err != nil {
+			return nil, err
+		}
+		assertions = append(assertions, assertion)
+	}
+	return assertions, nil
+}
+
+func (a Assertion) Evaluate(metrics map[string]float64) AssertionResult {
+	result := AssertionResult{Assertion: a}
+
+	actual, ok := metrics[a.Metric]
+	if !ok {
+		names := make([]string, 0, len(metrics))
+		for name := range metrics {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		result.Error = fmt.Errorf("unknown metric %q (available: %s)", a.Metric, strings.Join(names, ", "))
+		return result
+	}
+	result.Actual = actual
+
+	switch a.Op {
+	case "<":
+		result.Passed = actual < a.Value
+	case "<=":
+		result.Passed = actual <= a.Value
+	case ">":
+		result.Passed = actual > a.Value
+	case ">=":
+		result.Passed = actual >= a.Value
+	case "==":
+		result.Passed = actual == a.Value
+	case "!=":
+		result.Passed = actual != a.Value
+	}
+
+	return result
+}
+
+func EvaluateAssertions(assertions []Assertion, metrics map[string]float64) ([]AssertionResult, bool) {
+	passed := true
+	var results []AssertionResult
+	for _, assertion := range assertions {
+		result := assertion.Evaluate(metrics)
+		if !result.Passed {
+			passed = false
+		}
+		results = append(results, result)
+	}
+	return results, passed
+}
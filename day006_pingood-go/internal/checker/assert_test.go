@@ -0,0 +1,110 @@
+package checker
+
+import "testing"
+
+func TestParseAssertion(t *testing.T) {
+	tests := []struct {
+		expr   string
+		metric string
+		op     string
+		value  float64
+	}{
+		{"p95 < 100ms", "p95", "<", 100},
+		{"loss<=1%", "loss", "<=", 1},
+		{"  out_of_order == 0 ", "out_of_order", "==", 0},
+		{"jitter >= 2.5", "jitter", ">=", 2.5},
+		{"download_mbps >= 50Mbps", "download_mbps", ">=", 50},
+		{"signal >= -70dBm", "signal", ">=", -70},
+	}
+
+	for _, tt := range tests {
+		assertion, err := ParseAssertion(tt.expr)
+		if err != nil {
+			t.Errorf("ParseAssertion(%q) failed: %v", tt.expr, err)
+			continue
+		}
+		if assertion.Metric != tt.metric || assertion.Op != tt.op || assertion.Value != tt.value {
+			t.Errorf("ParseAssertion(%q) = %+v", tt.expr, assertion)
+		}
+	}
+
+	for _, expr := range []string{"", "p95", "p95 ~ 10", "< 10", "p95 < fast"} {
+		if _, err := ParseAssertion(expr); err == nil {
+			t.Errorf("Expected error for %q", expr)
+		}
+	}
+}
+
+func TestParseAssertionUnits(t *testing.T) {
+	tests := []struct {
+		expr string
+		ok   bool
+	}{
+		{"loss < 5%", true},
+		{"loss < 5ms", false},
+		{"p95 < 100ms", true},
+		{"p95 < 100%", false},
+		{"duration_ms < 500ms", true},
+		{"upload_bufferbloat < 100ms", true},
+		{"download_mbps >= 50mbps", true},
+		{"download_mbps >= 50ms", false},
+		{"tx_bitrate >= 100Mbps", true},
+		{"signal >= -70dBm", true},
+		{"signal >= -70%", false},
+		{"retry_rate <= 10%", true},
+		{"duplicates == 0ms", false},
+		{"snr >= 25dBm", false},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseAssertion(tt.expr)
+		if tt.ok && err != nil {
+			t.Errorf("ParseAssertion(%q) failed: %v", tt.expr, err)
+		}
+		if !tt.ok && err == nil {
+			t.Errorf("Expected a unit error for %q", tt.expr)
+		}
+	}
+}
+
+func TestEvaluateAssertions(t *testing.T) {
+	assertions, err := ParseAssertions([]string{"loss < 5", "jitter <= 30ms", "p99 < 50"})
+	if err != nil {
+		t.Fatalf("ParseAssertions failed: %v", err)
+	}
+
+	metrics := map[string]float64{"loss": 0, "jitter": 30, "p99": 80}
+	results, passed := EvaluateAssertions(assertions, metrics)
+
+	if passed {
+		t.Error("Expected overall failure because p99 exceeds threshold")
+	}
+	if !results[0].Passed || !results[1].Passed || results[2].Passed {
+		t.Errorf("Unexpected assertion results: %+v", results)
+	}
+	if results[2].Actual != 80 {
+		t.Errorf("Expected actual value 80, got %v", results[2].Actual)
+	}
+
+	unknown, _ := ParseAssertion("mos > 4")
+	if result := unknown.Evaluate(metrics); result.Error == nil || result.Passed {
+		t.Errorf("Expected unknown metric to fail with error, got %+v", result)
+	}
+}
+
+func TestPingResultMetricsFromParsedOutput(t *testing.T) {
+	output := `PING 8.8.8.8 (8.8.8.8) 56(84) bytes of data.
+64 bytes from 8.8.8.8: icmp_seq=1 ttl=117 time=10.0 ms
+64 bytes from 8.8.8.8: icmp_seq=2 ttl=117 time=50.0 ms
+
+--- 8.8.8.8 ping statistics ---
+2 packets transmitted, 2 received, 0% packet loss, time 1001ms
+rtt min/avg/max/mdev = 10.000/30.000/50.000/20.000 ms`
+
+	assertions, _ := ParseAssertions([]string{"p95 < 40ms", "avg <= 30"})
+	results, passed := EvaluateAssertions(assertions, parsePingOutput(output).Metrics())
+
+	if passed || results[0].Passed || !results[1].Passed {
+		t.Errorf("Expected only p95 assertion to fail, got %+v", results)
+	}
+}
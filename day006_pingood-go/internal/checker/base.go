@@ -1,124 +1,189 @@
 package checker
 
 import (
-	"bufio"
-	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os/exec"
-	"regexp"
 	"runtime"
 	"strings"
 	"time"
 )
 
-type BaseChecker struct{}
+type BaseChecker struct {
+	runner CommandRunner
+	// ctx cancels the commands; nil means they run to completion.
+	ctx context.Context
+}
 
 func New() NetChecker {
-	switch runtime.GOOS {
+	return NewForPlatform(runtime.GOOS, ExecRunner{})
+}
+
+// NewForPlatform returns the checker for goos that runs its external
+// commands through runner instead of executing them directly.
+func NewForPlatform(goos string, runner CommandRunner) NetChecker {
+	switch goos {
 	case "linux":
-		return &LinuxChecker{BaseChecker{}}
+		return &LinuxChecker{BaseChecker{runner: runner}}
 	case "darwin":
-		return &MacChecker{BaseChecker{}}
+		return &MacChecker{BaseChecker{runner: runner}}
 	default:
-		return &LinuxChecker{BaseChecker{}}
+		return &LinuxChecker{BaseChecker{runner: runner}}
 	}
 }
 
 func (b *BaseChecker) executeCommand(name string, args ...string) (string, error) {
-	cmd := exec.Command(name, args...)
-	var out bytes.Buffer
-	var stderr bytes.Buffer
-	cmd.Stdout = &out
-	cmd.Stderr = &stderr
+	runner := b.runner
+	if runner == nil {
+		runner = ExecRunner{}
+	}
 	
-	err := cmd.Run()
+	start := time.Now()
+	out, stderr, err := runContext(b.context(), runner, name, args...)
+	elapsed := time.Since(start)
+	command := CommandLine(name, args...)
 	if err != nil {
-		return "", fmt.Errorf("command failed: %s: %w, stderr: %s", name, err, stderr.String())
+		var exitErr *exec.ExitError
+		var replayErr *ExitError
+		// Non-zero exits and missing optional tools are expected; only a
+		// command that should have run but could not is a warning.
+		level := slog.LevelWarn
+		if errors.As(err, &exitErr) || errors.As(err, &replayErr) || errors.Is(err, exec.ErrNotFound) {
+			level = slog.LevelInfo
+		}
+		logger(b.context()).Log(b.context(), level, "command failed", "command", command, "duration_ms", milliseconds(elapsed), "error", err, "stderr", strings.TrimSpace(stderr))
+		return out, fmt.Errorf("command failed: %s: %w, stderr: %s", name, err, stderr)
 	}
+	logger(b.context()).Debug("command finished", "command", command, "duration_ms", milliseconds(elapsed), "stdout_bytes", len(out))
 	
-	return out.String(), nil
+	return out, nil
 }
 
-func (b *BaseChecker) parsePingOutput(output string) PingResult {
-	result := PingResult{Success: false}
-	
-	lines := strings.Split(output, "\n")
-	for _, line := range lines {
-		if strings.Contains(line, "packet loss") {
-			re := regexp.MustCompile(`(\d+(?:\.\d+)?)% packet loss`)
-			matches := re.FindStringSubmatch(line)
-			if len(matches) > 1 {
-				fmt.Sscanf(matches[1], "%f", &result.PacketLoss)
-				result.Success = result.PacketLoss < 100
-			}
-		}
-		
-		if strings.Contains(line, "min/avg/max") {
-			re := regexp.MustCompile(`(\d+\.?\d*)/(\d+\.?\d*)/(\d+\.?\d*)`)
-			matches := re.FindStringSubmatch(line)
-			if len(matches) > 3 {
-				var min, avg, max float64
-				fmt.Sscanf(matches[1], "%f", &min)
-				fmt.Sscanf(matches[2], "%f", &avg)
-				fmt.Sscanf(matches[3], "%f", &max)
-				result.MinRTT = time.Duration(min * float64(time.Millisecond))
-				result.AvgRTT = time.Duration(avg * float64(time.Millisecond))
-				result.MaxRTT = time.Duration(max * float64(time.Millisecond))
-			}
-		}
+// CheckHTTP is the same on every platform.
+func (b *BaseChecker) CheckHTTP(check HTTPCheck) (HTTPResult, error) {
+	return RunHTTPCheck(b.context(), check)
+}
+
+func (b *BaseChecker) context() context.Context {
+	if b.ctx == nil {
+		return context.Background()
 	}
-	
-	return result
+	return b.ctx
 }
 
-func (b *BaseChecker) parseTracerouteOutput(output string, expected map[string]string) TracerouteResult {
-	result := TracerouteResult{
-		Success:        true,
-		PassesExpected: make(map[string]bool),
+// WithContext returns a copy of nc whose commands and requests are
+// cancelled when ctx is done. nc must come from New or NewForPlatform.
+func WithContext(nc NetChecker, ctx context.Context) NetChecker {
+	switch c := nc.(type) {
+	case *LinuxChecker:
+		copied := *c
+		copied.ctx = ctx
+		return &copied
+	case *MacChecker:
+		copied := *c
+		copied.ctx = ctx
+		return &copied
+	default:
+		return nc
 	}
-	
-	for device := range expected {
-		result.PassesExpected[device] = false
+}
+func logPingResult(ctx context.Context, result PingResult) {
+	rtts := make([]time.Duration, 0, len(result.Replies))
+	for _, reply := range result.Replies {
+		rtts = append(rtts, reply.RTT)
 	}
-	
-	scanner := bufio.NewScanner(strings.NewReader(output))
-	hopNumber := 0
-	
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.TrimSpace(line) == "" {
-			continue
-		}
-		
-		re := regexp.MustCompile(`^\s*(\d+)\s+([^\s]+)\s+\(([^)]+)\)`)
-		matches := re.FindStringSubmatch(line)
-		
-		if len(matches) > 3 {
-			hopNumber++
-			hop := Hop{
-				Number:  hopNumber,
-				Name:    matches[2],
-				Address: matches[3],
-			}
-			
-			rtts := regexp.MustCompile(`(\d+\.?\d*)\s*ms`).FindAllStringSubmatch(line, -1)
-			for _, rtt := range rtts {
-				if len(rtt) > 1 {
-					var ms float64
-					fmt.Sscanf(rtt[1], "%f", &ms)
-					hop.RTT = append(hop.RTT, time.Duration(ms*float64(time.Millisecond)))
-				}
-			}
-			
-			result.Hops = append(result.Hops, hop)
-			
-			for device, addr := range expected {
-				if hop.Address == addr || hop.Name == addr {
-					result.PassesExpected[device] = true
-				}
-			}
-		}
+	log := logger(ctx).With("target", result.Target)
+	log.Debug("ping replies", "rtt_ms", millisecondsList(rtts), "icmp_errors", len(result.ICMPErrors))
+	if result.Error != nil || !result.Success {
+		log.Info("ping failed", "transmitted", result.Transmitted, "received", result.Received, "loss", result.PacketLoss, "error", result.Error)
+		return
 	}
-	
-	return result
-}
\ No newline at end of file
+	log.Info("ping finished", "transmitted", result.Transmitted, "received", result.Received, "loss", result.PacketLoss, "avg_rtt_ms", milliseconds(result.AvgRTT))
+}
+
+func logTracerouteResult(ctx context.Context, result TracerouteResult) {
+	for _, hop := range result.Hops {
+		logger(ctx).Debug("traceroute hop", "target", result.Target, "hop", hop.Number, "address", hop.Address, "rtt_ms", millisecondsList(hop.RTT))
+	}
+	if result.Error != nil {
+		logger(ctx).Info("traceroute failed", "target", result.Target, "hops", len(result.Hops), "error", result.Error)
+		return
+	}
+	logger(ctx).Info("traceroute finished", "target", result.Target, "hops", len(result.Hops))
+}
+
+func logDNSResult(ctx context.Context, result DNSResult) {
+	if !result.Success {
+		logger(ctx).Info("dns lookup failed", "domain", result.Domain, "type", result.RecordType, "error", result.Error)
+		return
+	}
+	logger(ctx).Info("dns lookup finished", "domain", result.Domain, "type", result.RecordType, "records", result.Records)
+}
+
+func logGatewayResult(ctx context.Context, result GatewayResult) {
+	log := logger(ctx).With("interface", result.Interface)
+	for _, neighbor := range result.Neighbors {
+		log.Debug("gateway neighbor", "ip", neighbor.IP, "mac", neighbor.MAC, "state", neighbor.State)
+	}
+	for _, ra := range result.RouterAdvertisements {
+		log.Debug("router advertisement", "router", ra.Router, "lifetime", ra.Lifetime.String(), "prefixes", len(ra.Prefixes), "source", ra.Source)
+	}
+	for _, warning := range result.Warnings {
+		log.Info("gateway warning", "warning", warning)
+	}
+	log.Info("gateway check finished", "ipv4_gateway", result.IPv4Gateway, "ipv6_gateways", result.IPv6Gateways, "router_advertisements", len(result.RouterAdvertisements))
+}
+
+func logAddresses(ctx context.Context, iface, ipv4, ipv6 string, err error) {
+	if err != nil {
+		logger(ctx).Info("ip address lookup failed", "interface", iface, "error", err)
+		return
+	}
+	logger(ctx).Info("ip addresses", "interface", iface, "ipv4", ipv4, "ipv6", ipv6)
+}
+
+func logDefaultGateway(ctx context.Context, iface, gateway string, err error) {
+	if err != nil {
+		logger(ctx).Info("default gateway lookup failed", "interface", iface, "error", err)
+		return
+	}
+	logger(ctx).Info("default gateway", "interface", iface, "gateway", gateway)
+}
+
+func logDHCPLease(ctx context.Context, iface string, lease DHCPLease, err error) {
+	log := logger(ctx).With("interface", iface)
+	if err != nil {
+		log.Info("dhcp lease lookup failed", "error", err)
+		return
+	}
+	log.Info("dhcp lease", "source", lease.Source, "address", lease.Address, "server", lease.Server, "routers", lease.Routers,
+		"dns", lease.DNS, "lease_time", lease.LeaseTime.String(), "expiry", lease.Expiry)
+}
+
+func logRouteLookup(ctx context.Context, route RouteLookup, err error) {
+	log := logger(ctx).With("destination", route.Destination, "address", route.Address)
+	if err != nil {
+		log.Info("route lookup failed", "error", err)
+		return
+	}
+	log.Info("route lookup finished", "interface", route.Interface, "gateway", route.Gateway, "source", route.Source)
+}
+
+func logWirelessInfo(ctx context.Context, iface string, info WirelessInfo, err error) {
+	log := logger(ctx).With("interface", iface)
+	switch {
+	case errors.Is(err, ErrNotWireless), errors.Is(err, errors.ErrUnsupported):
+		log.Debug("wireless check skipped", "reason", err)
+		return
+	case err != nil:
+		log.Info("wireless check failed", "error", err)
+		return
+	case !info.Connected:
+		log.Info("wireless not connected", "source", info.Source)
+		return
+	}
+	log.Info("wireless link", "source", info.Source, "ssid", info.SSID, "bssid", info.BSSID, "frequency_mhz", info.Frequency,
+		"signal_dbm", info.Signal, "noise_dbm", info.Noise, "tx_bitrate_mbps", info.TxBitrate, "rx_bitrate_mbps", info.RxBitrate, "tx_retries", info.TxRetries)
+}
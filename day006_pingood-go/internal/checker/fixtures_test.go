@@ -0,0 +1,209 @@
+package checker
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	fixturePingTarget  = "8.8.8.8"
+	fixturePing6Target = "2001:4860:4860::8888"
+	fixtureTraceTarget = "8.8.8.8"
+	fixtureDomain      = "google.com"
+)
+
+type pingExpectation struct {
+	success    bool
+	loss       float64
+	avgRTT     time.Duration
+	replies    int
+	icmpErrors int
+}
+
+type fixtureScenario struct {
+	iface     string
+	ipv4      string
+	ipv6      string
+	gateway   string
+	ping      *pingExpectation
+	ping6     *pingExpectation
+	traceErr  bool
+	hops      []string
+	aRecords  []string
+	dnsFailed bool
+}
+
+// fixtureScenarios maps each directory under testdata/fixtures to what the
+// checkers are expected to extract from it. The directory name prefix up to
+// the first "-" selects the platform.
+var fixtureScenarios = map[string]fixtureScenario{
+	"linux-ubuntu-22.04": {
+		iface:    "eth0",
+		ipv4:     "192.168.1.23",
+		ipv6:     "2001:db8:1::23",
+		gateway:  "192.168.1.1",
+		ping:     &pingExpectation{success: true, loss: 0, avgRTT: 6403 * time.Microsecond, replies: 3},
+		ping6:    &pingExpectation{success: true, loss: 0, avgRTT: 7150 * time.Microsecond, replies: 3},
+		hops:     []string{"192.168.1.1", "10.0.0.1", "", "72.14.204.118", "8.8.8.8"},
+		aRecords: []string{"142.250.207.14"},
+	},
+	"linux-alpine-3.19": {
+		iface:    "eth0",
+		ipv4:     "172.17.0.2",
+		gateway:  "172.17.0.1",
+		ping:     &pingExpectation{success: true, loss: 0, avgRTT: 7217 * time.Microsecond, replies: 3},
+		ping6:    &pingExpectation{success: false},
+		hops:     []string{"172.17.0.1", "192.168.1.1", "", "10.0.0.1"},
+		aRecords: []string{"142.250.196.110"},
+	},
+	"linux-debian-12-de_DE": {
+		iface:    "eth0",
+		ipv4:     "10.20.30.40",
+		gateway:  "10.20.30.1",
+		ping:     &pingExpectation{success: true, loss: 0, avgRTT: 11501 * time.Microsecond, replies: 3},
+		ping6:    &pingExpectation{success: false},
+		hops:     []string{"10.20.30.1", "10.0.0.1", "8.8.8.8"},
+		aRecords: []string{"142.250.185.78"},
+	},
+	"linux-fedora-40-minimal": {
+		iface:     "eth0",
+		ipv4:      "192.168.122.50",
+		gateway:   "192.168.122.1",
+		ping:      &pingExpectation{success: true, loss: 33.3333, avgRTT: 10035 * time.Microsecond, replies: 2},
+		ping6:     &pingExpectation{success: false},
+		traceErr:  true,
+		dnsFailed: true,
+	},
+	"darwin-macos-14": {
+		iface:    "en0",
+		ipv4:     "192.168.0.12",
+		ipv6:     "2001:db8:2::9f8e",
+		gateway:  "192.168.0.1",
+		ping:     &pingExpectation{success: true, loss: 0, avgRTT: 12452 * time.Microsecond, replies: 3},
+		ping6:    &pingExpectation{success: true, loss: 0, avgRTT: 14200 * time.Microsecond, replies: 3},
+		hops:     []string{"192.168.0.1", "", "10.0.0.1", "8.8.8.8"},
+		aRecords: []string{"142.251.42.206"},
+	},
+	"linux-ubuntu-24.04-upstream-down": {
+		iface:     "eth0",
+		ipv4:      "192.168.1.77",
+		gateway:   "192.168.1.1",
+		ping:      &pingExpectation{success: false, icmpErrors: 3},
+		ping6:     &pingExpectation{success: false},
+		hops:      []string{"192.168.1.1", "192.168.1.1"},
+		dnsFailed: true,
+	},
+}
+
+func loadFixtureChecker(t *testing.T, scenario string) NetChecker {
+	t.Helper()
+
+	runner, err := LoadReplayRunner(filepath.Join("testdata", "fixtures", scenario))
+	if err != nil {
+		t.Fatalf("Failed to load fixtures: %v", err)
+	}
+	platform, _, _ := strings.Cut(scenario, "-")
+	return NewForPlatform(platform, runner)
+}
+
+func TestFixtureCorpus(t *testing.T) {
+	dirs, err := os.ReadDir(filepath.Join("testdata", "fixtures"))
+	if err != nil {
+		t.Fatalf("Failed to list fixtures: %v", err)
+	}
+
+	for _, dir := range dirs {
+		if _, ok := fixtureScenarios[dir.Name()]; !ok {
+			t.Errorf("Fixture directory %s has no scenario expectations", dir.Name())
+		}
+	}
+
+	for name, want := range fixtureScenarios {
+		name, want := name, want
+		t.Run(name, func(t *testing.T) {
+			nc := loadFixtureChecker(t, name)
+
+			ipv4, ipv6, err := nc.GetIPAddresses(want.iface)
+			if err != nil {
+				t.Fatalf("GetIPAddresses failed: %v", err)
+			}
+			if ipv4 != want.ipv4 {
+				t.Errorf("Expected IPv4=%s, got %s", want.ipv4, ipv4)
+			}
+			if ipv6 != want.ipv6 {
+				t.Errorf("Expected IPv6=%s, got %s", want.ipv6, ipv6)
+			}
+
+			gateway, err := nc.GetDefaultGateway(want.iface)
+			if err != nil {
+				t.Fatalf("GetDefaultGateway failed: %v", err)
+			}
+			if gateway != want.gateway {
+				t.Errorf("Expected gateway=%s, got %s", want.gateway, gateway)
+			}
+
+			checkPing(t, nc, fixturePingTarget, false, want.ping)
+			checkPing(t, nc, fixturePing6Target, true, want.ping6)
+
+			traceResult, err := nc.Traceroute(fixtureTraceTarget, 3, 1.0, nil)
+			if (err != nil) != want.traceErr {
+				t.Errorf("Expected traceroute error=%v, got %v", want.traceErr, err)
+			}
+			if !want.traceErr {
+				var hops []string
+				for _, hop := range traceResult.Hops {
+					hops = append(hops, hop.Address)
+				}
+				if strings.Join(hops, ",") != strings.Join(want.hops, ",") {
+					t.Errorf("Expected hops %q, got %q", want.hops, hops)
+				}
+			}
+
+			dnsResults, err := nc.CheckDNS([]string{fixtureDomain}, "A")
+			if err != nil {
+				t.Fatalf("CheckDNS failed: %v", err)
+			}
+			if dnsResults[0].Success == want.dnsFailed {
+				t.Errorf("Expected DNS success=%v, got %v (%v)", !want.dnsFailed, dnsResults[0].Success, dnsResults[0].Error)
+			}
+			if strings.Join(dnsResults[0].Records, ",") != strings.Join(want.aRecords, ",") {
+				t.Errorf("Expected A records %v, got %v", want.aRecords, dnsResults[0].Records)
+			}
+		})
+	}
+}
+
+func checkPing(t *testing.T, nc NetChecker, target string, ipv6 bool, want *pingExpectation) {
+	t.Helper()
+	if want == nil {
+		return
+	}
+
+	results, err := nc.PingTest([]string{target}, 3, 0.5, ipv6)
+	if err != nil {
+		t.Fatalf("PingTest failed: %v", err)
+	}
+	result := results[0]
+
+	if result.Success != want.success {
+		t.Errorf("%s: expected success=%v, got %v (%v)", target, want.success, result.Success, result.Error)
+	}
+	if len(result.Replies) != want.replies {
+		t.Errorf("%s: expected %d replies, got %d", target, want.replies, len(result.Replies))
+	}
+	if len(result.ICMPErrors) != want.icmpErrors {
+		t.Errorf("%s: expected %d ICMP errors, got %d", target, want.icmpErrors, len(result.ICMPErrors))
+	}
+	if !want.success {
+		return
+	}
+	if result.PacketLoss != want.loss {
+		t.Errorf("%s: expected %.4f%% packet loss, got %.4f%%", target, want.loss, result.PacketLoss)
+	}
+	if abs(result.AvgRTT-want.avgRTT) > time.Microsecond {
+		t.Errorf("%s: expected AvgRTT=%v, got %v", target, want.avgRTT, result.AvgRTT)
+	}
+}
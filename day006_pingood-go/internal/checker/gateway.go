@@ -0,0 +1,505 @@
+package checker
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// InfiniteLifetime is used for RA lifetimes advertised as 0xffffffff.
+const InfiniteLifetime = time.Duration(0xffffffff) * time.Second
+
+var (
+	routeViaRe     = regexp.MustCompile(`\bvia\s+(?:inet6\s+)?(\S+)`)
+	routeExpiresRe = regexp.MustCompile(`\bexpires\s+(\d+)sec`)
+	routePrefRe    = regexp.MustCompile(`\bpref\s+(\S+)`)
+	routeMTURe     = regexp.MustCompile(`\bmtu\s+(\d+)`)
+	arpingReplyRe  = regexp.MustCompile(`(?i)reply from\s+(\S+)\s+\[([0-9a-f:]+)\]`)
+	arpEntryRe     = regexp.MustCompile(`\(([^)]+)\) at (\S+)`)
+	ndpKeyValueRe  = regexp.MustCompile(`(\w+)=([^,\s]*)`)
+	rdiscLineRe    = regexp.MustCompile(`^\s*([^:]+?)\s*:\s*(.*)$`)
+	rdiscSecondsRe = regexp.MustCompile(`^(\d+)`)
+)
+
+// scopedAddress appends the interface zone to IPv6 link-local addresses so
+// they can be passed to ping and friends.
+func scopedAddress(ip, iface string) string {
+	if strings.HasPrefix(strings.ToLower(ip), "fe80:") && !strings.Contains(ip, "%") {
+		return ip + "%" + iface
+	}
+	return ip
+}
+
+func unscopedAddress(ip string) string {
+	addr, _, _ := strings.Cut(ip, "%")
+	return addr
+}
+
+// normalizeMAC turns the BSD short form (0:1:2:a:b:c) and upper-case
+// arping output into the usual lower-case, zero-padded notation.
+func normalizeMAC(mac string) string {
+	parts := strings.Split(strings.ToLower(mac), ":")
+	if len(parts) != 6 {
+		return strings.ToLower(mac)
+	}
+	for i, part := range parts {
+		if len(part) == 1 {
+			parts[i] = "0" + part
+		}
+	}
+	return strings.Join(parts, ":")
+}
+
+// parseRouteGateways returns every next hop of `ip route show default`,
+// including the nexthop lines of multipath routes.
+func parseRouteGateways(output string) []string {
+	var gateways []string
+	for _, matches := range routeViaRe.FindAllStringSubmatch(output, -1) {
+		gateways = append(gateways, matches[1])
+	}
+	return gateways
+}
+
+// parseRouteGetGateway reads the gateway from BSD `route -n get` output.
+func parseRouteGetGateway(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "gateway:") {
+			fields := strings.Fields(line)
+			if len(fields) >= 2 {
+				return fields[1]
+			}
+		}
+	}
+	return ""
+}
+
+// parseIPNeigh parses `ip neigh show` lines such as
+// "fe80::1 lladdr 00:11:22:33:44:55 router REACHABLE".
+func parseIPNeigh(output string) []NeighborEntry {
+	var entries []NeighborEntry
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		entry := NeighborEntry{IP: fields[0], State: fields[len(fields)-1]}
+		for i := 1; i < len(fields); i++ {
+			switch fields[i] {
+			case "lladdr":
+				if i+1 < len(fields) {
+					entry.MAC = normalizeMAC(fields[i+1])
+				}
+			case "router":
+				entry.Router = true
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// parseArpOutput parses BSD `arp -n` lines such as
+// "? (192.168.0.1) at 0:11:22:33:44:55 on en0 ifscope [ethernet]". BSD arp
+// does not report reachability, so State is only set for incomplete entries.
+func parseArpOutput(output string) []NeighborEntry {
+	var entries []NeighborEntry
+	for _, line := range strings.Split(output, "\n") {
+		matches := arpEntryRe.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+
+		entry := NeighborEntry{IP: matches[1]}
+		if matches[2] == "(incomplete)" {
+			entry.State = "INCOMPLETE"
+		} else {
+			entry.MAC = normalizeMAC(matches[2])
+			if strings.Contains(line, "permanent") {
+				entry.State = "PERMANENT"
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+var ndpStates = map[string]string{
+	"R": "REACHABLE",
+	"S": "STALE",
+	"D": "DELAY",
+	"P": "PROBE",
+	"I": "INCOMPLETE",
+	"N": "NONE",
+}
+
+// parseNDPNeighbors parses the table printed by `ndp -an`.
+func parseNDPNeighbors(output string) []NeighborEntry {
+	var entries []NeighborEntry
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 5 || fields[0] == "Neighbor" {
+			continue
+		}
+
+		entry := NeighborEntry{IP: unscopedAddress(fields[0]), State: ndpStates[fields[4]]}
+		if fields[1] != "(incomplete)" {
+			entry.MAC = normalizeMAC(fields[1])
+		}
+		if len(fields) > 5 && strings.Contains(fields[5], "R") {
+			entry.Router = true
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// parseArping returns the distinct MAC addresses that answered each IP in
+// iputils arping output. More than one MAC for an address means some other
+// host is using it too.
+func parseArping(output string) map[string][]string {
+	replies := make(map[string][]string)
+	for _, matches := range arpingReplyRe.FindAllStringSubmatch(output, -1) {
+		ip, mac := matches[1], normalizeMAC(matches[2])
+		if !containsString(replies[ip], mac) {
+			replies[ip] = append(replies[ip], mac)
+		}
+	}
+	return replies
+}
+
+// parseRdisc6 parses the output of `rdisc6 -m`, which prints one block per
+// router advertisement, each ending in "from <router>".
+func parseRdisc6(output string) []RouterAdvertisement {
+	var ras []RouterAdvertisement
+	var current RouterAdvertisement
+	var prefix *RAPrefix
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+
+		if strings.HasPrefix(trimmed, "from ") {
+			current.Router = strings.TrimSpace(strings.TrimPrefix(trimmed, "from "))
+			current.Source = "rdisc6"
+			ras = append(ras, current)
+			current = RouterAdvertisement{}
+			prefix = nil
+			continue
+		}
+
+		matches := rdiscLineRe.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+		key, value := matches[1], strings.TrimSpace(matches[2])
+
+		switch key {
+		case "Router lifetime":
+			current.Lifetime = parseRdiscSeconds(value)
+		case "Router preference":
+			current.Preference = value
+		case "MTU":
+			if fields := strings.Fields(value); len(fields) > 0 {
+				current.MTU, _ = strconv.Atoi(fields[0])
+			}
+		case "Source link-layer address":
+			current.MAC = normalizeMAC(value)
+		case "Prefix":
+			current.Prefixes = append(current.Prefixes, RAPrefix{Prefix: value})
+			prefix = &current.Prefixes[len(current.Prefixes)-1]
+		case "On-link":
+			if prefix != nil {
+				prefix.OnLink = value == "Yes"
+			}
+		case "Autonomous address conf.":
+			if prefix != nil {
+				prefix.Autonomous = value == "Yes"
+			}
+		case "Valid time":
+			if prefix != nil {
+				prefix.ValidLifetime = parseRdiscSeconds(value)
+			}
+		case "Pref. time":
+			if prefix != nil {
+				prefix.PreferredLifetime = parseRdiscSeconds(value)
+			}
+		}
+	}
+	return ras
+}
+
+func parseRdiscSeconds(value string) time.Duration {
+	if strings.HasPrefix(value, "infinite") {
+		return InfiniteLifetime
+	}
+	matches := rdiscSecondsRe.FindStringSubmatch(value)
+	if matches == nil {
+		return 0
+	}
+	seconds, _ := strconv.Atoi(matches[1])
+	return time.Duration(seconds) * time.Second
+}
+
+// parseKernelRA rebuilds router advertisements from the routes the Linux
+// kernel installed from them (`ip -6 route show proto ra`). The kernel does
+// not remember which router announced a prefix, so prefixes are attached to
+// the router only when there is exactly one.
+func parseKernelRA(output string) []RouterAdvertisement {
+	var ras []RouterAdvertisement
+	var prefixes []RAPrefix
+	var multipath RouterAdvertisement
+
+	for _, line := range strings.Split(output, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+
+		ra := RouterAdvertisement{Source: "kernel"}
+		if matches := routeExpiresRe.FindStringSubmatch(trimmed); matches != nil {
+			seconds, _ := strconv.Atoi(matches[1])
+			ra.Lifetime = time.Duration(seconds) * time.Second
+		}
+		if matches := routePrefRe.FindStringSubmatch(trimmed); matches != nil {
+			ra.Preference = matches[1]
+		}
+		if matches := routeMTURe.FindStringSubmatch(trimmed); matches != nil {
+			ra.MTU, _ = strconv.Atoi(matches[1])
+		}
+		via := routeViaRe.FindStringSubmatch(trimmed)
+
+		switch {
+		case strings.HasPrefix(trimmed, "default") && via == nil:
+			multipath = ra
+		case strings.HasPrefix(trimmed, "nexthop") && via != nil:
+			ra = multipath
+			ra.Router = via[1]
+			ras = append(ras, ra)
+		case strings.HasPrefix(trimmed, "default"):
+			ra.Router = via[1]
+			ras = append(ras, ra)
+		case strings.Contains(strings.Fields(trimmed)[0], "/"):
+			prefixes = append(prefixes, RAPrefix{
+				Prefix:        strings.Fields(trimmed)[0],
+				OnLink:        true,
+				ValidLifetime: ra.Lifetime,
+			})
+		}
+	}
+
+	if len(prefixes) > 0 {
+		if len(ras) == 1 {
+			ras[0].Prefixes = prefixes
+		} else {
+			ras = append(ras, RouterAdvertisement{Prefixes: prefixes, Source: "kernel"})
+		}
+	}
+	return ras
+}
+
+// parseNDPRouters parses `ndp -rn` lines such as
+// "fe80::1%en0 if=en0, flags=, pref=medium, expire=29m55s", keeping the
+// routers on iface.
+func parseNDPRouters(output, iface string) []RouterAdvertisement {
+	var ras []RouterAdvertisement
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || !strings.Contains(line, "if="+iface+",") {
+			continue
+		}
+
+		ra := RouterAdvertisement{Router: unscopedAddress(fields[0]), Source: "ndp"}
+		for _, kv := range ndpKeyValueRe.FindAllStringSubmatch(line, -1) {
+			switch kv[1] {
+			case "pref":
+				ra.Preference = kv[2]
+			case "expire":
+				ra.Lifetime = parseNDPDuration(kv[2])
+			}
+		}
+		ras = append(ras, ra)
+	}
+	return ras
+}
+
+// parseNDPPrefixes parses `ndp -pn` and attaches each prefix on iface to the
+// routers listed under "advertised by". Prefixes from unknown routers get an
+// entry of their own.
+func parseNDPPrefixes(output, iface string, ras []RouterAdvertisement) []RouterAdvertisement {
+	var prefix *RAPrefix
+	advertisedBy := false
+
+	attach := func(router string) {
+		for i := range ras {
+			if ras[i].Router == router {
+				ras[i].Prefixes = append(ras[i].Prefixes, *prefix)
+				return
+			}
+		}
+		ras = append(ras, RouterAdvertisement{Router: router, Prefixes: []RAPrefix{*prefix}, Source: "ndp"})
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		trimmed := strings.TrimSpace(line)
+		fields := strings.Fields(trimmed)
+		switch {
+		case len(fields) == 0:
+			continue
+		case strings.Contains(fields[0], "/") && strings.Contains(trimmed, "if="):
+			prefix = nil
+			advertisedBy = false
+			if fields[len(fields)-1] == "if="+iface {
+				prefix = &RAPrefix{Prefix: fields[0]}
+			}
+		case prefix != nil && strings.HasPrefix(trimmed, "flags="):
+			for _, kv := range ndpKeyValueRe.FindAllStringSubmatch(trimmed, -1) {
+				switch kv[1] {
+				case "flags":
+					prefix.OnLink = strings.Contains(kv[2], "L")
+					prefix.Autonomous = strings.Contains(kv[2], "A")
+				case "vltime":
+					prefix.ValidLifetime = parseNDPDuration(kv[2])
+				case "pltime":
+					prefix.PreferredLifetime = parseNDPDuration(kv[2])
+				}
+			}
+		case trimmed == "advertised by":
+			advertisedBy = true
+		case trimmed == "No advertising router":
+			if prefix != nil {
+				attach("")
+			}
+		case advertisedBy && prefix != nil:
+			attach(unscopedAddress(fields[0]))
+		}
+	}
+	return ras
+}
+
+// parseNDPDuration accepts plain seconds ("2592000"), Go-style durations
+// with an optional day part ("29d23h59m58s") and "infinity".
+func parseNDPDuration(value string) time.Duration {
+	if value == "infinity" {
+		return InfiniteLifetime
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+
+	var days time.Duration
+	if before, after, found := strings.Cut(value, "d"); found {
+		n, err := strconv.Atoi(before)
+		if err != nil {
+			return 0
+		}
+		days = time.Duration(n) * 24 * time.Hour
+		value = after
+	}
+	if value == "" {
+		return days
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0
+	}
+	return days + d
+}
+
+// NeighborHistory remembers the MAC address last seen for each gateway so
+// that a changed MAC can be reported on the next run.
+type NeighborHistory struct {
+	Entries map[string]NeighborRecord `json:"entries"`
+}
+
+type NeighborRecord struct {
+	MAC       string    `json:"mac"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+type MACChange struct {
+	Interface string
+	IP        string
+	OldMAC    string
+	NewMAC    string
+	LastSeen  time.Time
+}
+
+// LoadNeighborHistory reads the history file. A missing file is not an
+// error and yields an empty history.
+func LoadNeighborHistory(path string) (*NeighborHistory, error) {
+	history := &NeighborHistory{Entries: make(map[string]NeighborRecord)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return history, nil
+	}
+	if err != nil {
+		return history, fmt.Errorf("failed to read neighbor history: %w", err)
+	}
+	if err := json.Unmarshal(data, history); err != nil {
+		return history, fmt.Errorf("failed to parse neighbor history: %w", err)
+	}
+	if history.Entries == nil {
+		history.Entries = make(map[string]NeighborRecord)
+	}
+	return history, nil
+}
+
+func (h *NeighborHistory) Save(path string) error {
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode neighbor history: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create neighbor history directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write neighbor history: %w", err)
+	}
+	return nil
+}
+
+// Update records the MACs of the given neighbors and returns the ones that
+// differ from the previous run. Entries without a MAC are ignored.
+func (h *NeighborHistory) Update(iface string, neighbors []NeighborEntry, now time.Time) []MACChange {
+	var changes []MACChange
+	for _, neighbor := range neighbors {
+		if neighbor.MAC == "" {
+			continue
+		}
+
+		key := iface + "/" + neighbor.IP
+		previous, ok := h.Entries[key]
+		switch {
+		case !ok:
+			h.Entries[key] = NeighborRecord{MAC: neighbor.MAC, FirstSeen: now, LastSeen: now}
+		case previous.MAC != neighbor.MAC:
+			changes = append(changes, MACChange{
+				Interface: iface,
+				IP:        neighbor.IP,
+				OldMAC:    previous.MAC,
+				NewMAC:    neighbor.MAC,
+				LastSeen:  previous.LastSeen,
+			})
+			h.Entries[key] = NeighborRecord{MAC: neighbor.MAC, FirstSeen: now, LastSeen: now}
+		default:
+			previous.LastSeen = now
+			h.Entries[key] = previous
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].IP < changes[j].IP })
+	return changes
+}
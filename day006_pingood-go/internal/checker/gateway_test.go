@@ -0,0 +1,251 @@
+package checker
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckGatewayFixtures(t *testing.T) {
+	tests := []struct {
+		scenario     string
+		iface        string
+		ipv6Gateways []string
+		neighbors    []string
+		duplicates   map[string]int
+		raRouter     string
+		raPrefix     string
+		raSource     string
+		warnings     int
+	}{
+		{
+			scenario:     "linux-ubuntu-22.04",
+			iface:        "eth0",
+			ipv6Gateways: []string{"fe80::1"},
+			neighbors:    []string{"192.168.1.1 00:11:22:33:44:55 REACHABLE", "fe80::1 00:11:22:33:44:55 REACHABLE router"},
+			raRouter:     "fe80::1",
+			raPrefix:     "2001:db8:1::/64",
+			raSource:     "rdisc6",
+		},
+		{
+			scenario:   "linux-ubuntu-24.04-upstream-down",
+			iface:      "eth0",
+			neighbors:  []string{"192.168.1.1 3c:22:fb:0a:0b:0c STALE"},
+			duplicates: map[string]int{"192.168.1.1": 2},
+		},
+		{
+			scenario:     "darwin-macos-14",
+			iface:        "en0",
+			ipv6Gateways: []string{"fe80::1%en0"},
+			neighbors:    []string{"192.168.0.1 00:1e:2a:b3:c4:d5 ", "fe80::1 00:1e:2a:b3:c4:d5 STALE router"},
+			raRouter:     "fe80::1",
+			raPrefix:     "2001:db8:2::/64",
+			raSource:     "ndp",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.scenario, func(t *testing.T) {
+			nc := loadFixtureChecker(t, tt.scenario)
+
+			result, err := nc.CheckGateway(tt.iface, 3, 0.5)
+			if err != nil {
+				t.Fatalf("CheckGateway failed: %v", err)
+			}
+
+			if strings.Join(result.IPv6Gateways, ",") != strings.Join(tt.ipv6Gateways, ",") {
+				t.Errorf("Expected IPv6 gateways %v, got %v", tt.ipv6Gateways, result.IPv6Gateways)
+			}
+			if len(result.Ping) != 1+len(tt.ipv6Gateways) {
+				t.Fatalf("Expected %d gateway pings, got %d", 1+len(tt.ipv6Gateways), len(result.Ping))
+			}
+			for _, ping := range result.Ping {
+				if !ping.Success {
+					t.Errorf("Expected ping to %s to succeed: %v", ping.Target, ping.Error)
+				}
+			}
+
+			var neighbors []string
+			for _, n := range result.Neighbors {
+				entry := n.IP + " " + n.MAC + " " + n.State
+				if n.Router {
+					entry += " router"
+				}
+				neighbors = append(neighbors, entry)
+			}
+			if strings.Join(neighbors, "|") != strings.Join(tt.neighbors, "|") {
+				t.Errorf("Expected neighbors %q, got %q", tt.neighbors, neighbors)
+			}
+
+			for ip, macs := range result.ARPReplies {
+				if want := tt.duplicates[ip]; len(macs) > 1 && len(macs) != want {
+					t.Errorf("Expected %d MACs for %s, got %v", want, ip, macs)
+				}
+			}
+			for ip, want := range tt.duplicates {
+				if len(result.ARPReplies[ip]) != want {
+					t.Errorf("Expected %d MACs for %s, got %v", want, ip, result.ARPReplies[ip])
+				}
+			}
+
+			if tt.raRouter == "" {
+				if len(result.RouterAdvertisements) != 0 {
+					t.Errorf("Expected no router advertisements, got %+v", result.RouterAdvertisements)
+				}
+				return
+			}
+			if len(result.RouterAdvertisements) != 1 {
+				t.Fatalf("Expected 1 router advertisement, got %+v", result.RouterAdvertisements)
+			}
+			ra := result.RouterAdvertisements[0]
+			if ra.Router != tt.raRouter || ra.Source != tt.raSource {
+				t.Errorf("Expected RA from %s via %s, got %s via %s", tt.raRouter, tt.raSource, ra.Router, ra.Source)
+			}
+			if len(ra.Prefixes) != 1 || ra.Prefixes[0].Prefix != tt.raPrefix || !ra.Prefixes[0].Autonomous {
+				t.Errorf("Expected autonomous prefix %s, got %+v", tt.raPrefix, ra.Prefixes)
+			}
+		})
+	}
+}
+
+func TestParseRdisc6(t *testing.T) {
+	output := `Soliciting ff02::2 (ff02::2) on eth0...
+
+Hop limit                 :           64 (      0x40)
+Router preference         :         high
+Router lifetime           :         1800 (0x00000708) seconds
+ MTU                      :         1480 bytes (valid)
+ Prefix                   : 2001:db8:a::/64
+  On-link                 :          Yes
+  Autonomous address conf.:          Yes
+  Valid time              :     infinite (0xffffffff)
+  Pref. time              :     infinite (0xffffffff)
+ from fe80::a
+
+Hop limit                 :           64 (      0x40)
+Router preference         :          low
+Router lifetime           :            0 (0x00000000) seconds
+ Source link-layer address: 02:00:00:00:00:0B
+ from fe80::b
+`
+
+	ras := parseRdisc6(output)
+	if len(ras) != 2 {
+		t.Fatalf("Expected 2 router advertisements, got %d", len(ras))
+	}
+
+	if ras[0].Router != "fe80::a" || ras[0].Preference != "high" || ras[0].MTU != 1480 || ras[0].Lifetime != 30*time.Minute {
+		t.Errorf("Unexpected first RA: %+v", ras[0])
+	}
+	if len(ras[0].Prefixes) != 1 || ras[0].Prefixes[0].ValidLifetime != InfiniteLifetime {
+		t.Errorf("Expected infinite prefix lifetime, got %+v", ras[0].Prefixes)
+	}
+	if ras[1].Router != "fe80::b" || ras[1].Lifetime != 0 || ras[1].MAC != "02:00:00:00:00:0b" || len(ras[1].Prefixes) != 0 {
+		t.Errorf("Unexpected second RA: %+v", ras[1])
+	}
+
+	// A truncated MTU line must not stop the parse.
+	ras = parseRdisc6("Router lifetime           :         1800 (0x00000708) seconds\n MTU                      :\n from fe80::c\n")
+	if len(ras) != 1 || ras[0].Router != "fe80::c" || ras[0].MTU != 0 {
+		t.Errorf("Unexpected RA with an empty MTU: %+v", ras)
+	}
+}
+
+func TestParseKernelRA(t *testing.T) {
+	t.Run("single router", func(t *testing.T) {
+		ras := parseKernelRA(`2001:db8:1::/64 proto ra metric 100 expires 86397sec pref medium
+default via fe80::1 proto ra metric 100 expires 1797sec mtu 1492 pref high
+`)
+		if len(ras) != 1 {
+			t.Fatalf("Expected 1 RA, got %+v", ras)
+		}
+		if ras[0].Router != "fe80::1" || ras[0].Lifetime != 1797*time.Second || ras[0].MTU != 1492 || ras[0].Preference != "high" {
+			t.Errorf("Unexpected RA: %+v", ras[0])
+		}
+		if len(ras[0].Prefixes) != 1 || ras[0].Prefixes[0].Prefix != "2001:db8:1::/64" {
+			t.Errorf("Expected prefix to be attached to the router, got %+v", ras[0].Prefixes)
+		}
+	})
+
+	t.Run("multipath", func(t *testing.T) {
+		ras := parseKernelRA(`2001:db8:1::/64 proto ra metric 100 expires 86397sec pref medium
+default proto ra metric 1024 expires 1795sec pref medium
+	nexthop via fe80::1 dev eth0 weight 1
+	nexthop via fe80::2 dev eth0 weight 1
+`)
+		if len(ras) != 3 {
+			t.Fatalf("Expected 2 routers and 1 prefix entry, got %+v", ras)
+		}
+		if ras[0].Router != "fe80::1" || ras[1].Router != "fe80::2" || ras[1].Lifetime != 1795*time.Second {
+			t.Errorf("Unexpected routers: %+v", ras[:2])
+		}
+		if ras[2].Router != "" || len(ras[2].Prefixes) != 1 {
+			t.Errorf("Expected unattributed prefix entry, got %+v", ras[2])
+		}
+	})
+}
+
+func TestParseNDPDuration(t *testing.T) {
+	tests := map[string]time.Duration{
+		"2592000":      30 * 24 * time.Hour,
+		"29m42s":       29*time.Minute + 42*time.Second,
+		"6d23h59m42s":  7*24*time.Hour - 18*time.Second,
+		"infinity":     InfiniteLifetime,
+		"never":        0,
+		"29d":          29 * 24 * time.Hour,
+		"not-a-number": 0,
+	}
+
+	for input, want := range tests {
+		if got := parseNDPDuration(input); got != want {
+			t.Errorf("parseNDPDuration(%q) = %v, want %v", input, got, want)
+		}
+	}
+}
+
+func TestNeighborHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pingood", "neighbors.json")
+	first := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+
+	history, err := LoadNeighborHistory(path)
+	if err != nil {
+		t.Fatalf("Loading a missing history should not fail: %v", err)
+	}
+	changes := history.Update("eth0", []NeighborEntry{
+		{IP: "192.168.1.1", MAC: "00:11:22:33:44:55"},
+		{IP: "192.168.1.2", State: "INCOMPLETE"},
+	}, first)
+	if len(changes) != 0 {
+		t.Errorf("Expected no changes on first run, got %+v", changes)
+	}
+	if err := history.Save(path); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	history, err = LoadNeighborHistory(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(history.Entries) != 1 {
+		t.Errorf("Expected entries without MAC to be skipped, got %+v", history.Entries)
+	}
+
+	changes = history.Update("eth0", []NeighborEntry{{IP: "192.168.1.1", MAC: "00:11:22:33:44:55"}}, first.Add(time.Hour))
+	if len(changes) != 0 {
+		t.Errorf("Expected no changes for the same MAC, got %+v", changes)
+	}
+
+	changes = history.Update("eth0", []NeighborEntry{{IP: "192.168.1.1", MAC: "66:77:88:99:aa:bb"}}, first.Add(2*time.Hour))
+	if len(changes) != 1 {
+		t.Fatalf("Expected 1 change, got %+v", changes)
+	}
+	change := changes[0]
+	if change.OldMAC != "00:11:22:33:44:55" || change.NewMAC != "66:77:88:99:aa:bb" || !change.LastSeen.Equal(first.Add(time.Hour)) {
+		t.Errorf("Unexpected change: %+v", change)
+	}
+
+	if changes := history.Update("wlan0", []NeighborEntry{{IP: "192.168.1.1", MAC: "00:11:22:33:44:55"}}, first); len(changes) != 0 {
+		t.Errorf("Expected interfaces to be tracked separately, got %+v", changes)
+	}
+}
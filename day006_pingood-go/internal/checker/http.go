@@ -0,0 +1,673 @@
+package checker
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/h3"
+)
+
+// maxHTTPBody is how much of a response body is kept for assertions.
+const maxHTTPBody = 1 << 20
+
+// Family selects the address family an HTTP check connects over.
+type Family string
+
+const (
+	// FamilyDual tries IPv6 and IPv4 as in Happy Eyeballs (RFC 6555).
+	FamilyDual Family = "dual"
+	FamilyIPv4 Family = "ipv4"
+	FamilyIPv6 Family = "ipv6"
+)
+
+// RedirectPolicy says which redirects an HTTP check follows. A redirect
+// that is not followed becomes the response the check evaluates.
+type RedirectPolicy string
+
+const (
+	RedirectFollow   RedirectPolicy = "follow"
+	RedirectNone     RedirectPolicy = "none"
+	RedirectSameHost RedirectPolicy = "same-host"
+)
+
+// Protocol forces the HTTP version a check speaks. HTTP/2 and HTTP/3
+// need an https URL since they are negotiated with ALPN.
+type Protocol string
+
+const (
+	ProtocolAuto  Protocol = ""
+	ProtocolHTTP1 Protocol = "http/1.1"
+	ProtocolHTTP2 Protocol = "h2"
+	ProtocolHTTP3 Protocol = "h3"
+)
+
+// Name returns the protocol as it appears in a response, e.g. "HTTP/2".
+func (p Protocol) Name() string {
+	switch p {
+	case ProtocolHTTP1:
+		return "HTTP/1.1"
+	case ProtocolHTTP2:
+		return "HTTP/2"
+	case ProtocolHTTP3:
+		return "HTTP/3"
+	}
+	return "auto"
+}
+
+const defaultMaxRedirects = 10
+
+// ParseFamily accepts "ipv4"/"v4"/"4", "ipv6"/"v6"/"6" and
+// "dual"/"dual-stack"; empty is dual.
+func ParseFamily(s string) (Family, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "dual", "dual-stack", "dualstack":
+		return FamilyDual, nil
+	case "ipv4", "v4", "4":
+		return FamilyIPv4, nil
+	case "ipv6", "v6", "6":
+		return FamilyIPv6, nil
+	}
+	return "", fmt.Errorf("unknown address family %q", s)
+}
+
+// ParseRedirectPolicy accepts "follow", "none" and "same-host"; empty is
+// follow.
+func ParseRedirectPolicy(s string) (RedirectPolicy, error) {
+	switch policy := RedirectPolicy(strings.ToLower(strings.TrimSpace(s))); policy {
+	case "":
+		return RedirectFollow, nil
+	case RedirectFollow, RedirectNone, RedirectSameHost:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown redirect policy %q", s)
+}
+
+// ParseProtocol accepts "http/1.1"/"http1"/"h1", "h2"/"http2"/"http/2"
+// and "h3"/"http3"/"http/3"; empty or "auto" lets the server choose
+// between HTTP/1.1 and HTTP/2.
+func ParseProtocol(s string) (Protocol, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "auto":
+		return ProtocolAuto, nil
+	case "http/1.1", "http1.1", "http1", "h1", "1.1":
+		return ProtocolHTTP1, nil
+	case "h2", "http2", "http/2", "2":
+		return ProtocolHTTP2, nil
+	case "h3", "http3", "http/3", "3", "quic":
+		return ProtocolHTTP3, nil
+	}
+	return "", fmt.Errorf("unknown HTTP protocol %q", s)
+}
+
+// HTTPCheck is a request to send and what the response must look like.
+// Expected values in JSON and ResponseHeaders are compared exactly unless
+// written as /regexp/.
+type HTTPCheck struct {
+	URL    string
+	Family Family
+	// Redirects defaults to RedirectFollow; MaxRedirects to 10.
+	Redirects    RedirectPolicy
+	MaxRedirects int
+	Protocol     Protocol
+
+	Method  string
+	Headers map[string]string
+	Body    string
+
+	// ExpectStatus holds codes such as "204" or classes such as "2xx";
+	// empty means 2xx.
+	ExpectStatus    []string
+	BodyContains    []string
+	BodyMatches     []string
+	JSON            map[string]string
+	ResponseHeaders map[string]string
+
+	BasicAuthUser     string
+	BasicAuthPassword string
+	BearerToken       string
+	ClientCert        string
+	ClientKey         string
+	CABundle          string
+	Timeout           time.Duration
+}
+
+// Redirect is one redirect response an HTTP check followed.
+type Redirect struct {
+	StatusCode int
+	Location   string
+}
+
+// HTTPCheckResult is the outcome of one expectation of an HTTPCheck.
+type HTTPCheckResult struct {
+	Check  string
+	Passed bool
+	Actual string
+}
+
+// RunHTTPCheck sends check's request and evaluates every expectation
+// against the response. result.Success is true only if all of them hold.
+// An error means no response was received.
+func RunHTTPCheck(ctx context.Context, check HTTPCheck) (HTTPResult, error) {
+	log := logger(ctx).With("url", check.URL)
+	trace := &httpTrace{log: log}
+	result, err := runHTTPCheck(ctx, check, trace)
+	result.Trace = trace.snapshot()
+
+	switch {
+	case err != nil:
+		log.Info("http request failed", "duration_ms", milliseconds(result.Duration), "error", err)
+	case !result.Success:
+		log.Info("http assertions failed", "status", result.StatusCode, "proto", result.Proto, "remote", result.RemoteAddr, "duration_ms", milliseconds(result.Duration))
+	default:
+		log.Info("http request finished", "status", result.StatusCode, "proto", result.Proto, "remote", result.RemoteAddr, "duration_ms", milliseconds(result.Duration))
+	}
+	return result, err
+}
+
+func runHTTPCheck(ctx context.Context, check HTTPCheck, trace *httpTrace) (HTTPResult, error) {
+	result := HTTPResult{URL: check.URL}
+
+	client, err := newHTTPClient(check, &result)
+	if err != nil {
+		result.Error = err
+		return result, err
+	}
+	defer client.CloseIdleConnections()
+	req, err := newHTTPRequest(ctx, check)
+	if err != nil {
+		result.Error = err
+		return result, err
+	}
+
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace.clientTrace(func(info httptrace.GotConnInfo) {
+		result.RemoteAddr = info.Conn.RemoteAddr().String()
+	})))
+
+	start := time.Now()
+	trace.start = start
+	result.Started = start
+	resp, err := client.Do(req)
+	if err != nil {
+		result.Duration = time.Since(start)
+		result.Error = err
+		return result, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxHTTPBody))
+	result.Duration = time.Since(start)
+	if err != nil {
+		result.Error = fmt.Errorf("failed to read response body: %w", err)
+		return result, result.Error
+	}
+
+	result.StatusCode = resp.StatusCode
+	result.Proto = resp.Proto
+	if err := checkProtocol(check.Protocol, resp); err != nil {
+		result.Error = err
+		return result, err
+	}
+	result.Headers = resp.Header
+	result.BodySize = len(body)
+	result.Checks = evaluateHTTPCheck(check, resp, body)
+	result.Success = true
+	for _, c := range result.Checks {
+		if !c.Passed {
+			result.Success = false
+		}
+	}
+	return result, nil
+}
+
+// httpTrace collects the HTTP trace events of one check. Dial attempts
+// race each other, so events can arrive from several goroutines, and even
+// after the request has finished.
+type httpTrace struct {
+	log   *slog.Logger
+	start time.Time
+
+	mu     sync.Mutex
+	events []HTTPTraceEvent
+}
+
+func (t *httpTrace) add(event, detail string, err error) {
+	at := time.Since(t.start)
+	e := HTTPTraceEvent{At: at, Event: event, Detail: detail}
+	attrs := []any{"event", event, "at_ms", milliseconds(at), "detail", detail}
+	if err != nil {
+		e.Error = err.Error()
+		attrs = append(attrs, "error", err)
+	}
+	t.log.Debug("http trace", attrs...)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.events = append(t.events, e)
+}
+
+func (t *httpTrace) snapshot() []HTTPTraceEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]HTTPTraceEvent(nil), t.events...)
+}
+
+func (t *httpTrace) clientTrace(gotConn func(httptrace.GotConnInfo)) *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(info httptrace.DNSStartInfo) {
+			t.add("dns_start", info.Host, nil)
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			addrs := make([]string, 0, len(info.Addrs))
+			for _, addr := range info.Addrs {
+				addrs = append(addrs, addr.String())
+			}
+			t.add("dns_done", strings.Join(addrs, ","), info.Err)
+		},
+		ConnectStart: func(network, addr string) {
+			t.add("connect_start", network+" "+addr, nil)
+		},
+		ConnectDone: func(network, addr string, err error) {
+			t.add("connect_done", network+" "+addr, err)
+		},
+		TLSHandshakeStart: func() {
+			t.add("tls_start", "", nil)
+		},
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			detail := ""
+			if err == nil {
+				detail = strings.TrimSpace(tls.VersionName(state.Version) + " " + state.NegotiatedProtocol)
+			}
+			t.add("tls_done", detail, err)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			gotConn(info)
+			detail := info.Conn.RemoteAddr().String()
+			if info.Reused {
+				detail += " reused"
+			}
+			t.add("got_conn", detail, nil)
+		},
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			t.add("wrote_request", "", info.Err)
+		},
+		GotFirstResponseByte: func() {
+			t.add("first_byte", "", nil)
+		},
+	}
+}
+
+// HTTPPhase is a timed step of an HTTP request, Start and End after the
+// request started: "dns", "connect" (one per address tried), "tls" or
+// "http", which runs from getting a connection until the response was
+// read or the next redirect began.
+type HTTPPhase struct {
+	Name   string
+	Start  time.Duration
+	End    time.Duration
+	Detail string
+	Error  string
+}
+
+// Phases pairs up the trace events of r. Phases still open when the
+// request ended get r's error, if any.
+func (r HTTPResult) Phases() []HTTPPhase {
+	var phases []HTTPPhase
+	open := make(map[string]int)
+	begin := func(key, name string, e HTTPTraceEvent) {
+		open[key] = len(phases)
+		phases = append(phases, HTTPPhase{Name: name, Start: e.At, Detail: e.Detail})
+	}
+	finish := func(key string, e HTTPTraceEvent) *HTTPPhase {
+		i, ok := open[key]
+		if !ok {
+			return nil
+		}
+		delete(open, key)
+		phases[i].End = e.At
+		phases[i].Error = e.Error
+		return &phases[i]
+	}
+
+	for _, e := range r.Trace {
+		switch e.Event {
+		case "dns_start":
+			finish("http", e)
+			begin("dns", "dns", e)
+		case "dns_done":
+			if p := finish("dns", e); p != nil && e.Detail != "" {
+				p.Detail += " " + e.Detail
+			}
+		case "connect_start":
+			finish("http", e)
+			begin("connect "+e.Detail, "connect", e)
+		case "connect_done":
+			finish("connect "+e.Detail, e)
+		case "tls_start":
+			begin("tls", "tls", e)
+		case "tls_done":
+			if p := finish("tls", e); p != nil {
+				p.Detail = e.Detail
+			}
+		case "got_conn":
+			finish("http", HTTPTraceEvent{At: e.At})
+			begin("http", "http", e)
+		}
+	}
+
+	var failure string
+	if r.Error != nil {
+		failure = r.Error.Error()
+	}
+	for _, i := range open {
+		phases[i].End = max(r.Duration, phases[i].Start)
+		phases[i].Error = failure
+	}
+	return phases
+}
+
+// newHTTPClient builds a client for check that records the redirects it
+// follows in result.
+func newHTTPClient(check HTTPCheck, result *HTTPResult) (*http.Client, error) {
+	network := "tcp"
+	switch check.Family {
+	case "", FamilyDual:
+	case FamilyIPv4:
+		network = "tcp4"
+	case FamilyIPv6:
+		network = "tcp6"
+	default:
+		return nil, fmt.Errorf("unknown address family %q", check.Family)
+	}
+	policy := check.Redirects
+	if policy == "" {
+		policy = RedirectFollow
+	}
+	if _, err := ParseRedirectPolicy(string(policy)); err != nil {
+		return nil, err
+	}
+	maxRedirects := check.MaxRedirects
+	if maxRedirects <= 0 {
+		maxRedirects = defaultMaxRedirects
+	}
+
+	tlsConfig := &tls.Config{}
+	if check.CABundle != "" {
+		pem, err := os.ReadFile(check.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", check.CABundle)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if check.ClientCert != "" || check.ClientKey != "" {
+		cert, err := tls.LoadX509KeyPair(check.ClientCert, check.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	timeout := check.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	transport, err := newHTTPTransport(check.Protocol, network, tlsConfig, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			switch {
+			case policy == RedirectNone:
+				return http.ErrUseLastResponse
+			case policy == RedirectSameHost && req.URL.Host != via[0].URL.Host:
+				return http.ErrUseLastResponse
+			case len(via) > maxRedirects:
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			result.Redirects = append(result.Redirects, Redirect{StatusCode: req.Response.StatusCode, Location: req.URL.String()})
+			return nil
+		},
+	}, nil
+}
+
+// newHTTPTransport returns a transport that speaks only protocol, or
+// HTTP/1.1 and HTTP/2 as the server prefers for ProtocolAuto.
+func newHTTPTransport(protocol Protocol, network string, tlsConfig *tls.Config, timeout time.Duration) (http.RoundTripper, error) {
+	if protocol == ProtocolHTTP3 {
+		return &h3.Transport{TLSClientConfig: tlsConfig, Network: strings.Replace(network, "tcp", "udp", 1)}, nil
+	}
+	dialer := &net.Dialer{Timeout: timeout}
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, addr string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, addr)
+		},
+		TLSClientConfig:   tlsConfig,
+		ForceAttemptHTTP2: true,
+		Proxy:             http.ProxyFromEnvironment,
+		// Each check times a fresh connection, and a kept-alive one would
+		// outlive the check with nothing left to close it.
+		DisableKeepAlives: true,
+	}
+	switch protocol {
+	case ProtocolAuto:
+	case ProtocolHTTP1:
+		// A non-nil empty map keeps the transport from upgrading to HTTP/2.
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	case ProtocolHTTP2:
+		tlsConfig.NextProtos = []string{"h2"}
+	default:
+		return nil, fmt.Errorf("unknown HTTP protocol %q", protocol)
+	}
+	return transport, nil
+}
+
+// checkProtocol fails a forced HTTP/2 check whose server fell back to
+// HTTP/1.1, which the transport would otherwise do silently.
+func checkProtocol(protocol Protocol, resp *http.Response) error {
+	want := map[Protocol]int{ProtocolHTTP1: 1, ProtocolHTTP2: 2, ProtocolHTTP3: 3}[protocol]
+	if want != 0 && resp.ProtoMajor != want {
+		return fmt.Errorf("server answered over %s instead of %s", resp.Proto, protocol.Name())
+	}
+	return nil
+}
+
+func newHTTPRequest(ctx context.Context, check HTTPCheck) (*http.Request, error) {
+	method := strings.ToUpper(check.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	var body io.Reader
+	if check.Body != "" {
+		body = strings.NewReader(check.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, check.URL, body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid HTTP request: %w", err)
+	}
+
+	for name, value := range check.Headers {
+		if strings.EqualFold(name, "Host") {
+			req.Host = value
+			continue
+		}
+		req.Header.Set(name, value)
+	}
+	switch {
+	case check.BearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+check.BearerToken)
+	case check.BasicAuthUser != "":
+		req.SetBasicAuth(check.BasicAuthUser, check.BasicAuthPassword)
+	}
+	return req, nil
+}
+
+func evaluateHTTPCheck(check HTTPCheck, resp *http.Response, body []byte) []HTTPCheckResult {
+	var results []HTTPCheckResult
+
+	expect := check.ExpectStatus
+	if len(expect) == 0 {
+		expect = []string{"2xx"}
+	}
+	results = append(results, HTTPCheckResult{
+		Check:  "status in " + strings.Join(expect, ", "),
+		Passed: statusMatches(resp.StatusCode, expect),
+		Actual: strconv.Itoa(resp.StatusCode),
+	})
+
+	for _, name := range sortedStringKeys(check.ResponseHeaders) {
+		want := check.ResponseHeaders[name]
+		values, present := resp.Header[http.CanonicalHeaderKey(name)]
+		actual := strings.Join(values, ", ")
+		if !present {
+			actual = "missing"
+		}
+		results = append(results, HTTPCheckResult{
+			Check:  fmt.Sprintf("header %s = %s", name, want),
+			Passed: present && valueMatches(want, actual),
+			Actual: actual,
+		})
+	}
+
+	for _, want := range check.BodyContains {
+		results = append(results, HTTPCheckResult{
+			Check:  fmt.Sprintf("body contains %q", want),
+			Passed: bytes.Contains(body, []byte(want)),
+		})
+	}
+	for _, pattern := range check.BodyMatches {
+		result := HTTPCheckResult{Check: fmt.Sprintf("body matches /%s/", pattern)}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			result.Actual = fmt.Sprintf("invalid pattern: %v", err)
+		} else {
+			result.Passed = re.Match(body)
+		}
+		results = append(results, result)
+	}
+
+	if len(check.JSON) > 0 {
+		var doc interface{}
+		err := json.Unmarshal(body, &doc)
+		for _, path := range sortedStringKeys(check.JSON) {
+			want := check.JSON[path]
+			result := HTTPCheckResult{Check: fmt.Sprintf("json %s = %s", path, want)}
+			if err != nil {
+				result.Actual = "body is not JSON"
+			} else if value, ok := jsonPath(doc, path); !ok {
+				result.Actual = "missing"
+			} else {
+				result.Actual = jsonText(value)
+				result.Passed = valueMatches(want, result.Actual)
+			}
+			results = append(results, result)
+		}
+	}
+	return results
+}
+
+func statusMatches(code int, expect []string) bool {
+	actual := strconv.Itoa(code)
+	for _, want := range expect {
+		want = strings.ToLower(strings.TrimSpace(want))
+		if len(want) == 3 && strings.HasSuffix(want, "xx") && actual[0] == want[0] {
+			return true
+		}
+		if want == actual {
+			return true
+		}
+	}
+	return false
+}
+
+// valueMatches compares exactly, or as a regular expression when want is
+// written as /pattern/.
+func valueMatches(want, actual string) bool {
+	if len(want) >= 2 && strings.HasPrefix(want, "/") && strings.HasSuffix(want, "/") {
+		re, err := regexp.Compile(want[1 : len(want)-1])
+		return err == nil && re.MatchString(actual)
+	}
+	return want == actual
+}
+
+var jsonIndexRe = regexp.MustCompile(`^([^\[]*)((?:\[\d+\])*)$`)
+
+// jsonPath follows a dotted path such as "checks.db.status" or
+// "$.items[0].name" through a decoded JSON document.
+func jsonPath(doc interface{}, path string) (interface{}, bool) {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	if path == "" {
+		return doc, true
+	}
+
+	current := doc
+	for _, part := range strings.Split(path, ".") {
+		matches := jsonIndexRe.FindStringSubmatch(part)
+		if matches == nil {
+			return nil, false
+		}
+		if key := matches[1]; key != "" {
+			object, ok := current.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			if current, ok = object[key]; !ok {
+				return nil, false
+			}
+		}
+		for _, index := range regexp.MustCompile(`\d+`).FindAllString(matches[2], -1) {
+			array, ok := current.([]interface{})
+			i, _ := strconv.Atoi(index)
+			if !ok || i >= len(array) {
+				return nil, false
+			}
+			current = array[i]
+		}
+	}
+	return current, true
+}
+
+func jsonText(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	encoded, _ := json.Marshal(value)
+	return string(encoded)
+}
+
+func sortedStringKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,497 @@
+package checker
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/h3"
+)
+
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/private" {
+		if user, pass, ok := r.BasicAuth(); !(ok && user == "admin" && pass == "secret") && r.Header.Get("Authorization") != "Bearer t0ken" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	}
+	if r.Method == http.MethodPost {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Echo", string(body))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Request-Id", r.Header.Get("X-Request-Id"))
+	io.WriteString(w, `{"status":"ok","version":"1.4.2","checks":[{"name":"db","up":true,"latency":3.5}]}`)
+}
+
+func failedChecks(result HTTPResult) []string {
+	var failed []string
+	for _, c := range result.Checks {
+		if !c.Passed {
+			failed = append(failed, c.Check+" (actual "+c.Actual+")")
+		}
+	}
+	return failed
+}
+
+func TestRunHTTPCheck(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(healthHandler))
+	defer server.Close()
+
+	tests := []struct {
+		name   string
+		check  HTTPCheck
+		failed []string
+	}{
+		{
+			name: "all assertions hold",
+			check: HTTPCheck{
+				URL:          server.URL + "/health",
+				Headers:      map[string]string{"X-Request-Id": "pingood"},
+				BodyContains: []string{`"status":"ok"`},
+				BodyMatches:  []string{`"version":"1\.\d+`},
+				JSON: map[string]string{
+					"status":            "ok",
+					"$.checks[0].up":    "true",
+					"checks[0].latency": "3.5",
+					"checks[0].name":    "/^d/",
+					"version":           "/^1\\./",
+				},
+				ResponseHeaders: map[string]string{
+					"content-type": "/json/",
+					"X-Request-Id": "pingood",
+				},
+			},
+		},
+		{
+			name: "failing assertions",
+			check: HTTPCheck{
+				URL:             server.URL + "/health",
+				ExpectStatus:    []string{"204"},
+				BodyContains:    []string{"degraded"},
+				JSON:            map[string]string{"status": "degraded", "checks[1].up": "true"},
+				ResponseHeaders: map[string]string{"Cache-Control": "no-store"},
+			},
+			failed: []string{
+				"status in 204 (actual 200)",
+				"header Cache-Control = no-store (actual missing)",
+				`body contains "degraded" (actual )`,
+				"json checks[1].up = true (actual missing)",
+				"json status = degraded (actual ok)",
+			},
+		},
+		{
+			name: "method and body",
+			check: HTTPCheck{
+				URL:             server.URL + "/echo",
+				Method:          "post",
+				Body:            "ping",
+				ResponseHeaders: map[string]string{"X-Echo": "ping"},
+			},
+		},
+		{
+			name:   "unauthorized",
+			check:  HTTPCheck{URL: server.URL + "/private", ExpectStatus: []string{"2xx", "3xx"}},
+			failed: []string{"status in 2xx, 3xx (actual 401)"},
+		},
+		{
+			name:  "basic auth",
+			check: HTTPCheck{URL: server.URL + "/private", BasicAuthUser: "admin", BasicAuthPassword: "secret"},
+		},
+		{
+			name:  "bearer token",
+			check: HTTPCheck{URL: server.URL + "/private", BearerToken: "t0ken"},
+		},
+		{
+			name:  "expected error status",
+			check: HTTPCheck{URL: server.URL + "/private", ExpectStatus: []string{"401", "403"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := RunHTTPCheck(context.Background(), tt.check)
+			if err != nil {
+				t.Fatalf("RunHTTPCheck failed: %v", err)
+			}
+			failed := failedChecks(result)
+			if strings.Join(failed, "\n") != strings.Join(tt.failed, "\n") {
+				t.Errorf("Expected failures %q, got %q", tt.failed, failed)
+			}
+			if result.Success != (len(tt.failed) == 0) {
+				t.Errorf("Unexpected success %v", result.Success)
+			}
+		})
+	}
+}
+
+func TestRunHTTPCheckNotJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "OK")
+	}))
+	defer server.Close()
+
+	result, err := RunHTTPCheck(context.Background(), HTTPCheck{URL: server.URL, JSON: map[string]string{"status": "ok"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Success || len(result.Checks) != 2 || result.Checks[1].Actual != "body is not JSON" {
+		t.Errorf("Unexpected result %+v", result.Checks)
+	}
+	if result.BodySize != 2 {
+		t.Errorf("Expected a body size of 2, got %d", result.BodySize)
+	}
+}
+
+func TestRunHTTPCheckTrace(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(healthHandler))
+	defer server.Close()
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := RunHTTPCheck(context.Background(), HTTPCheck{URL: server.URL, CABundle: caFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []string
+	for i, event := range result.Trace {
+		if i > 0 && event.At < result.Trace[i-1].At {
+			t.Errorf("Events out of order: %+v", result.Trace)
+		}
+		events = append(events, event.Event)
+	}
+	want := "connect_start,connect_done,tls_start,tls_done,got_conn,wrote_request,first_byte"
+	if strings.Join(events, ",") != want {
+		t.Errorf("Expected events %s, got %s", want, strings.Join(events, ","))
+	}
+	if last := result.Trace[len(result.Trace)-1]; last.At > result.Duration {
+		t.Errorf("First byte at %v after the request took %v", last.At, result.Duration)
+	}
+	var phases []string
+	for _, phase := range result.Phases() {
+		phases = append(phases, phase.Name)
+	}
+	if strings.Join(phases, ",") != "connect,tls,http" {
+		t.Errorf("Expected connect, tls and http phases, got %v", phases)
+	}
+}
+
+func TestHTTPResultPhases(t *testing.T) {
+	ms := func(n int) time.Duration { return time.Duration(n) * time.Millisecond }
+	result := HTTPResult{
+		Duration: ms(100),
+		Error:    errors.New("context deadline exceeded"),
+		Trace: []HTTPTraceEvent{
+			{At: ms(0), Event: "dns_start", Detail: "example.com"},
+			{At: ms(5), Event: "dns_done", Detail: "192.0.2.1,2001:db8::1"},
+			{At: ms(5), Event: "connect_start", Detail: "tcp [2001:db8::1]:443"},
+			{At: ms(10), Event: "connect_start", Detail: "tcp 192.0.2.1:443"},
+			{At: ms(20), Event: "connect_done", Detail: "tcp 192.0.2.1:443"},
+			{At: ms(20), Event: "tls_start"},
+			{At: ms(30), Event: "tls_done", Detail: "TLS 1.3 h2"},
+			{At: ms(30), Event: "got_conn", Detail: "192.0.2.1:443"},
+			{At: ms(40), Event: "first_byte"},
+			// A redirect to another host that never answers.
+			{At: ms(50), Event: "dns_start", Detail: "www.example.com"},
+			{At: ms(55), Event: "dns_done", Error: "no such host"},
+		},
+	}
+	want := []HTTPPhase{
+		{Name: "dns", Start: ms(0), End: ms(5), Detail: "example.com 192.0.2.1,2001:db8::1"},
+		{Name: "connect", Start: ms(5), End: ms(100), Detail: "tcp [2001:db8::1]:443", Error: "context deadline exceeded"},
+		{Name: "connect", Start: ms(10), End: ms(20), Detail: "tcp 192.0.2.1:443"},
+		{Name: "tls", Start: ms(20), End: ms(30), Detail: "TLS 1.3 h2"},
+		{Name: "http", Start: ms(30), End: ms(50), Detail: "192.0.2.1:443"},
+		{Name: "dns", Start: ms(50), End: ms(55), Detail: "www.example.com", Error: "no such host"},
+	}
+	if got := result.Phases(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected phases\n%+v\ngot\n%+v", want, got)
+	}
+}
+
+func TestRunHTTPCheckClientCertificate(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.TLS.PeerCertificates[0].Subject.Organization[0])
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+
+	dir := t.TempDir()
+	writePEM := func(name, blockType string, der []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	// The test server's own certificate doubles as the client certificate.
+	cert := server.TLS.Certificates[0]
+	key, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile := writePEM("client.crt", "CERTIFICATE", cert.Certificate[0])
+	keyFile := writePEM("client.key", "PRIVATE KEY", key)
+	caFile := writePEM("ca.crt", "CERTIFICATE", server.Certificate().Raw)
+
+	if _, err := RunHTTPCheck(context.Background(), HTTPCheck{URL: server.URL}); err == nil {
+		t.Error("Expected the test CA to be untrusted without CA_BUNDLE")
+	}
+	if _, err := RunHTTPCheck(context.Background(), HTTPCheck{URL: server.URL, CABundle: caFile}); err == nil {
+		t.Error("Expected the handshake to fail without a client certificate")
+	}
+
+	result, err := RunHTTPCheck(context.Background(), HTTPCheck{
+		URL:          server.URL,
+		CABundle:     caFile,
+		ClientCert:   certFile,
+		ClientKey:    keyFile,
+		BodyContains: []string{"Acme Co"},
+	})
+	if err != nil {
+		t.Fatalf("RunHTTPCheck failed: %v", err)
+	}
+	if !result.Success {
+		t.Errorf("Expected success, got %q", failedChecks(result))
+	}
+}
+
+func TestRunHTTPCheckRedirects(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/old":
+			http.Redirect(w, r, "/moved", http.StatusMovedPermanently)
+		case "/moved":
+			http.Redirect(w, r, "/final", http.StatusFound)
+		case "/away":
+			// Same server, different host name.
+			http.Redirect(w, r, strings.Replace("http://"+r.Host+"/final", "127.0.0.1", "localhost", 1), http.StatusFound)
+		case "/final":
+			io.WriteString(w, "done")
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name      string
+		check     HTTPCheck
+		status    int
+		redirects []string
+		err       string
+	}{
+		{
+			name:      "follow",
+			check:     HTTPCheck{URL: server.URL + "/old"},
+			status:    200,
+			redirects: []string{"301 " + server.URL + "/moved", "302 " + server.URL + "/final"},
+		},
+		{
+			name:   "none",
+			check:  HTTPCheck{URL: server.URL + "/old", Redirects: RedirectNone, ExpectStatus: []string{"3xx"}},
+			status: 301,
+		},
+		{
+			name:      "same host",
+			check:     HTTPCheck{URL: server.URL + "/moved", Redirects: RedirectSameHost},
+			status:    200,
+			redirects: []string{"302 " + server.URL + "/final"},
+		},
+		{
+			name:   "other host",
+			check:  HTTPCheck{URL: server.URL + "/away", Redirects: RedirectSameHost},
+			status: 302,
+		},
+		{
+			name:  "too many",
+			check: HTTPCheck{URL: server.URL + "/old", MaxRedirects: 1},
+			err:   "stopped after 1 redirects",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := RunHTTPCheck(context.Background(), tt.check)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("Expected error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RunHTTPCheck failed: %v", err)
+			}
+			if result.StatusCode != tt.status {
+				t.Errorf("Expected status %d, got %d", tt.status, result.StatusCode)
+			}
+			var redirects []string
+			for _, r := range result.Redirects {
+				redirects = append(redirects, fmt.Sprintf("%d %s", r.StatusCode, r.Location))
+			}
+			if strings.Join(redirects, "\n") != strings.Join(tt.redirects, "\n") {
+				t.Errorf("Expected redirects %q, got %q", tt.redirects, redirects)
+			}
+		})
+	}
+}
+
+func TestRunHTTPCheckFamily(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	result, err := RunHTTPCheck(context.Background(), HTTPCheck{URL: server.URL, Family: FamilyIPv4})
+	if err != nil {
+		t.Fatalf("RunHTTPCheck failed: %v", err)
+	}
+	if result.RemoteAddr != server.Listener.Addr().String() {
+		t.Errorf("Expected to connect to %s, got %q", server.Listener.Addr(), result.RemoteAddr)
+	}
+	if _, err := RunHTTPCheck(context.Background(), HTTPCheck{URL: server.URL, Family: FamilyIPv6}); err == nil {
+		t.Error("Expected an IPv4-only server to be unreachable over IPv6")
+	}
+	if _, err := RunHTTPCheck(context.Background(), HTTPCheck{URL: server.URL, Family: "ipx"}); err == nil {
+		t.Error("Expected an unknown family to be rejected")
+	}
+}
+
+func TestRunHTTPCheckClosesConnections(t *testing.T) {
+	closed := make(chan struct{}, 1)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(healthHandler))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateClosed {
+			closed <- struct{}{}
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	if _, err := RunHTTPCheck(context.Background(), HTTPCheck{URL: server.URL}); err != nil {
+		t.Fatalf("RunHTTPCheck failed: %v", err)
+	}
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Error("Expected the check to close its connection")
+	}
+}
+
+func TestRunHTTPCheckProtocol(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Proto)
+	})
+	server := httptest.NewUnstartedServer(handler)
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	// Serve HTTP/3 on the same port over UDP, as real servers do.
+	pc, err := net.ListenPacket("udp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	quic := &h3.Server{Handler: handler, TLSConfig: &tls.Config{Certificates: server.TLS.Certificates}}
+	done := make(chan struct{})
+	go func() {
+		quic.Serve(pc)
+		close(done)
+	}()
+	defer func() {
+		pc.Close()
+		<-done
+	}()
+
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for protocol, want := range map[Protocol]string{
+		ProtocolAuto:  "HTTP/2.0",
+		ProtocolHTTP1: "HTTP/1.1",
+		ProtocolHTTP2: "HTTP/2.0",
+		ProtocolHTTP3: "HTTP/3.0",
+	} {
+		result, err := RunHTTPCheck(context.Background(), HTTPCheck{URL: server.URL, CABundle: caFile, Protocol: protocol, BodyContains: []string{want}})
+		if err != nil {
+			t.Errorf("%s: RunHTTPCheck failed: %v", protocol.Name(), err)
+			continue
+		}
+		if result.Proto != want || !result.Success {
+			t.Errorf("%s: expected a passing check over %s, got %s %q", protocol.Name(), want, result.Proto, failedChecks(result))
+		}
+		if result.RemoteAddr == "" {
+			t.Errorf("%s: expected the remote address to be recorded", protocol.Name())
+		}
+	}
+
+	http1 := httptest.NewTLSServer(handler)
+	defer http1.Close()
+	if _, err := RunHTTPCheck(context.Background(), HTTPCheck{URL: http1.URL, CABundle: caFile, Protocol: ProtocolHTTP2}); err == nil || !strings.Contains(err.Error(), "instead of HTTP/2") {
+		t.Errorf("Expected a server without HTTP/2 to fail, got %v", err)
+	}
+	if _, err := RunHTTPCheck(context.Background(), HTTPCheck{URL: http1.URL, CABundle: caFile, Protocol: ProtocolHTTP3, Timeout: 2 * time.Second}); err == nil {
+		t.Error("Expected a server without HTTP/3 to fail")
+	}
+}
+
+func TestParseProtocol(t *testing.T) {
+	for input, want := range map[string]Protocol{"": ProtocolAuto, "HTTP/1.1": ProtocolHTTP1, "http2": ProtocolHTTP2, "h3": ProtocolHTTP3, "QUIC": ProtocolHTTP3} {
+		if got, err := ParseProtocol(input); err != nil || got != want {
+			t.Errorf("ParseProtocol(%q) = %q, %v", input, got, err)
+		}
+	}
+	if _, err := ParseProtocol("spdy"); err == nil {
+		t.Error("Expected spdy to be rejected")
+	}
+}
+
+func TestParseFamily(t *testing.T) {
+	for input, want := range map[string]Family{"": FamilyDual, "dual-stack": FamilyDual, "v4": FamilyIPv4, "IPv6": FamilyIPv6, "6": FamilyIPv6} {
+		if got, err := ParseFamily(input); err != nil || got != want {
+			t.Errorf("ParseFamily(%q) = %q, %v", input, got, err)
+		}
+	}
+	if _, err := ParseFamily("ipv5"); err == nil {
+		t.Error("Expected ipv5 to be rejected")
+	}
+	if _, err := ParseRedirectPolicy("sometimes"); err == nil {
+		t.Error("Expected an unknown redirect policy to be rejected")
+	}
+}
+
+func TestJSONPath(t *testing.T) {
+	doc := map[string]interface{}{
+		"a": []interface{}{[]interface{}{1.0, 2.0}},
+		"b": map[string]interface{}{"c": nil},
+	}
+	if v, ok := jsonPath(doc, "a[0][1]"); !ok || jsonText(v) != "2" {
+		t.Errorf("a[0][1] = %v, %v", v, ok)
+	}
+	if v, ok := jsonPath(doc, "$.b.c"); !ok || jsonText(v) != "null" {
+		t.Errorf("b.c = %v, %v", v, ok)
+	}
+	if v, ok := jsonPath(doc, "b"); !ok || jsonText(v) != `{"c":null}` {
+		t.Errorf("b = %v, %v", v, ok)
+	}
+	for _, missing := range []string{"a[1]", "a.b", "b.c.d", "x", "a[0][x]"} {
+		if _, ok := jsonPath(doc, missing); ok {
+			t.Errorf("Expected %s to be missing", missing)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package checker
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"strings"
+	"time"
+)
+
+// ipv4onlyAddrs are the only addresses of ipv4only.arpa, which has no AAAA
+// record, so any AAAA answer for it was synthesized by DNS64 (RFC 7050).
+var ipv4onlyAddrs = []netip.Addr{netip.AddrFrom4([4]byte{192, 0, 0, 170}), netip.AddrFrom4([4]byte{192, 0, 0, 171})}
+
+// DetectNAT64 looks up the AAAA records of ipv4only.arpa and returns the
+// NAT64 prefix the resolver synthesized them with. It returns false if the
+// resolver does not do DNS64.
+func DetectNAT64(ctx context.Context, resolver *net.Resolver) (netip.Prefix, bool, error) {
+	addrs, err := resolver.LookupNetIP(ctx, "ip6", "ipv4only.arpa")
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return netip.Prefix{}, false, nil
+		}
+		return netip.Prefix{}, false, fmt.Errorf("failed to look up ipv4only.arpa: %w", err)
+	}
+	for _, addr := range addrs {
+		if prefix, ok := nat64Prefix(addr); ok {
+			logger(ctx).Info("dns64 detected", "address", addr, "prefix", prefix)
+			return prefix, true, nil
+		}
+	}
+	logger(ctx).Debug("no dns64 prefix found", "addresses", addrs)
+	return netip.Prefix{}, false, nil
+}
+
+// nat64Prefix finds one of ipv4onlyAddrs embedded in addr at any of the
+// RFC 6052 prefix lengths, which skip bits 64-71.
+func nat64Prefix(addr netip.Addr) (netip.Prefix, bool) {
+	if !addr.Is6() || addr.Is4In6() {
+		return netip.Prefix{}, false
+	}
+	b := addr.As16()
+	layouts := []struct {
+		bits  int
+		bytes [4]int
+	}{
+		{96, [4]int{12, 13, 14, 15}},
+		{64, [4]int{9, 10, 11, 12}},
+		{56, [4]int{7, 9, 10, 11}},
+		{48, [4]int{6, 7, 9, 10}},
+		{40, [4]int{5, 6, 7, 9}},
+		{32, [4]int{4, 5, 6, 7}},
+	}
+	for _, layout := range layouts {
+		if layout.bits < 96 && b[8] != 0 {
+			continue
+		}
+		var v4 [4]byte
+		for i, pos := range layout.bytes {
+			v4[i] = b[pos]
+		}
+		for _, known := range ipv4onlyAddrs {
+			if netip.AddrFrom4(v4) == known {
+				prefix, _ := addr.Prefix(layout.bits)
+				return prefix, true
+			}
+		}
+	}
+	return netip.Prefix{}, false
+}
+
+// NameServers returns the nameserver addresses in a resolv.conf file.
+func NameServers(path string) ([]netip.Addr, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	defer f.Close()
+
+	var servers []netip.Addr
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "nameserver" {
+			continue
+		}
+		if addr, err := netip.ParseAddr(fields[1]); err == nil {
+			servers = append(servers, addr)
+		}
+	}
+	return servers, scanner.Err()
+}
+
+// DNSProbe is the answer of one DNS server to a single query.
+type DNSProbe struct {
+	Server  netip.Addr
+	RTT     time.Duration
+	Rcode   int
+	Answers int
+	Error   error
+}
+
+// ProbeDNSServer sends one AAAA query for name to server over UDP. Any
+// response, even an error code, shows the server is reachable.
+func ProbeDNSServer(ctx context.Context, server netip.Addr, name string, timeout time.Duration) DNSProbe {
+	probe := probeDNSServerAt(ctx, netip.AddrPortFrom(server, 53), name, timeout)
+	if probe.Error != nil {
+		logger(ctx).Info("dns server probe failed", "server", server, "name", name, "error", probe.Error)
+	} else {
+		logger(ctx).Info("dns server probe finished", "server", server, "name", name, "rtt_ms", milliseconds(probe.RTT), "rcode", probe.Rcode, "answers", probe.Answers)
+	}
+	return probe
+}
+
+func probeDNSServerAt(ctx context.Context, server netip.AddrPort, name string, timeout time.Duration) DNSProbe {
+	probe := DNSProbe{Server: server.Addr()}
+	query, id := dnsQuery(name, 28)
+
+	var d net.Dialer
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	conn, err := d.DialContext(ctx, "udp", server.String())
+	if err != nil {
+		probe.Error = err
+		return probe
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	start := time.Now()
+	if _, err := conn.Write(query); err != nil {
+		probe.Error = err
+		return probe
+	}
+	buf := make([]byte, 4096)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			probe.Error = err
+			return probe
+		}
+		if n < 12 || binary.BigEndian.Uint16(buf) != id || buf[2]&0x80 == 0 {
+			continue
+		}
+		probe.RTT = time.Since(start)
+		probe.Rcode = int(buf[3] & 0x0f)
+		probe.Answers = int(binary.BigEndian.Uint16(buf[6:8]))
+		return probe
+	}
+}
+
+// dnsQuery builds a recursive query for name and returns it with its ID.
+func dnsQuery(name string, qtype uint16) ([]byte, uint16) {
+	var idBytes [2]byte
+	rand.Read(idBytes[:])
+	id := binary.BigEndian.Uint16(idBytes[:])
+
+	b := binary.BigEndian.AppendUint16(nil, id)
+	b = append(b, 0x01, 0x00, 0, 1, 0, 0, 0, 0, 0, 0)
+	for _, label := range strings.Split(strings.Trim(name, "."), ".") {
+		if label == "" {
+			continue
+		}
+		b = append(b, byte(len(label)))
+		b = append(b, label...)
+	}
+	b = append(b, 0)
+	b = binary.BigEndian.AppendUint16(b, qtype)
+	return binary.BigEndian.AppendUint16(b, 1), id
+}
+
+// FamilyPreference records which address family a dual-stack connection
+// ends up on and how each family does on its own.
+type FamilyPreference struct {
+	Address string
+	// Chosen is "IPv6" or "IPv4" for the connection made with Happy
+	// Eyeballs (RFC 8305), or empty if it failed.
+	Chosen    string
+	ChosenErr error
+	IPv6      time.Duration
+	IPv6Err   error
+	IPv4      time.Duration
+	IPv4Err   error
+}
+
+// ProbeFamilyPreference connects to address (host:port) over TCP three
+// times: as a dual-stack client would, then over IPv6 and IPv4 only.
+func ProbeFamilyPreference(ctx context.Context, address string, timeout time.Duration) FamilyPreference {
+	pref := FamilyPreference{Address: address}
+	dial := func(network string) (net.Conn, time.Duration, error) {
+		d := net.Dialer{Timeout: timeout}
+		start := time.Now()
+		conn, err := d.DialContext(ctx, network, address)
+		return conn, time.Since(start), err
+	}
+
+	if conn, _, err := dial("tcp"); err != nil {
+		pref.ChosenErr = err
+	} else {
+		if addr, ok := conn.RemoteAddr().(*net.TCPAddr); ok && addr.IP.To4() == nil {
+			pref.Chosen = "IPv6"
+		} else {
+			pref.Chosen = "IPv4"
+		}
+		conn.Close()
+	}
+	for _, family := range []struct {
+		network string
+		rtt     *time.Duration
+		err     *error
+	}{{"tcp6", &pref.IPv6, &pref.IPv6Err}, {"tcp4", &pref.IPv4, &pref.IPv4Err}} {
+		conn, rtt, err := dial(family.network)
+		if err != nil {
+			*family.err = err
+			continue
+		}
+		*family.rtt = rtt
+		conn.Close()
+	}
+	logger(ctx).Info("address family preference", "address", address, "chosen", pref.Chosen, "ipv6_ms", milliseconds(pref.IPv6), "ipv6_error", pref.IPv6Err, "ipv4_ms", milliseconds(pref.IPv4), "ipv4_error", pref.IPv4Err)
+	return pref
+}
@@ -0,0 +1,160 @@
+package checker
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNAT64Prefix(t *testing.T) {
+	tests := []struct {
+		addr string
+		want string
+	}{
+		{"64:ff9b::c000:aa", "64:ff9b::/96"},
+		{"64:ff9b::192.0.0.171", "64:ff9b::/96"},
+		{"2001:db8:122:344:c0:0:aa00:0", "2001:db8:122:344::/64"},
+		{"2001:db8:c000:aa::", "2001:db8::/32"},
+		{"2001:db8::1", ""},
+		{"::ffff:192.0.0.170", ""},
+	}
+	for _, tt := range tests {
+		prefix, ok := nat64Prefix(netip.MustParseAddr(tt.addr))
+		got := ""
+		if ok {
+			got = prefix.String()
+		}
+		if got != tt.want {
+			t.Errorf("nat64Prefix(%s) = %q, want %q", tt.addr, got, tt.want)
+		}
+	}
+}
+
+// serveDNS answers every query on a loopback UDP port, with aaaa as the
+// answer to AAAA queries. It returns the server address.
+func serveDNS(t *testing.T, network, address string, aaaa []netip.Addr) netip.AddrPort {
+	t.Helper()
+	pc, err := net.ListenPacket(network, address)
+	if err != nil {
+		t.Skipf("cannot listen on %s: %v", address, err)
+	}
+	t.Cleanup(func() { pc.Close() })
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			// The question ends four bytes after its name's root label.
+			end := 12
+			for end < n && buf[end] != 0 {
+				end += int(buf[end]) + 1
+			}
+			end += 5
+			if end > n {
+				continue
+			}
+			qtype := binary.BigEndian.Uint16(buf[end-4:])
+			var answers []netip.Addr
+			if qtype == 28 {
+				answers = aaaa
+			}
+			resp := append([]byte(nil), buf[:2]...)
+			resp = append(resp, 0x81, 0x80, 0, 1)
+			resp = binary.BigEndian.AppendUint16(resp, uint16(len(answers)))
+			resp = append(resp, 0, 0, 0, 0)
+			resp = append(resp, buf[12:end]...)
+			for _, a := range answers {
+				resp = append(resp, 0xc0, 12, 0, 28, 0, 1, 0, 0, 0, 60, 0, 16)
+				b := a.As16()
+				resp = append(resp, b[:]...)
+			}
+			pc.WriteTo(resp, addr)
+		}
+	}()
+	return netip.MustParseAddrPort(pc.LocalAddr().String())
+}
+
+func resolverFor(server netip.AddrPort) *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "udp", server.String())
+		},
+	}
+}
+
+func TestDetectNAT64(t *testing.T) {
+	dns64 := serveDNS(t, "udp4", "127.0.0.1:0", []netip.Addr{netip.MustParseAddr("64:ff9b::c000:aa")})
+	prefix, ok, err := DetectNAT64(context.Background(), resolverFor(dns64))
+	if err != nil || !ok || prefix.String() != "64:ff9b::/96" {
+		t.Errorf("Expected 64:ff9b::/96, got %s %v %v", prefix, ok, err)
+	}
+
+	plain := serveDNS(t, "udp4", "127.0.0.1:0", nil)
+	if _, ok, err := DetectNAT64(context.Background(), resolverFor(plain)); ok || err != nil {
+		t.Errorf("Expected no DNS64 from a plain resolver, got %v %v", ok, err)
+	}
+}
+
+func TestProbeDNSServer(t *testing.T) {
+	server := serveDNS(t, "udp6", "[::1]:0", []netip.Addr{netip.MustParseAddr("2001:db8::1")})
+	probe := probeDNSServerAt(context.Background(), server, "example.com", time.Second)
+	if probe.Error != nil || probe.Answers != 1 || probe.Rcode != 0 {
+		t.Errorf("Expected one answer, got %+v", probe)
+	}
+
+	if probe := probeDNSServerAt(context.Background(), netip.AddrPortFrom(server.Addr(), 1), "example.com", 200*time.Millisecond); probe.Error == nil {
+		t.Error("Expected an unreachable server to fail")
+	}
+}
+
+func TestNameServers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resolv.conf")
+	conf := "# generated\nsearch example.com\nnameserver 192.168.1.1\nnameserver fe80::1%eth0\nnameserver 2001:4860:4860::8888\noptions edns0\n"
+	if err := os.WriteFile(path, []byte(conf), 0644); err != nil {
+		t.Fatal(err)
+	}
+	servers, err := NameServers(path)
+	if err != nil {
+		t.Fatalf("NameServers failed: %v", err)
+	}
+	want := []string{"192.168.1.1", "fe80::1%eth0", "2001:4860:4860::8888"}
+	if len(servers) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, servers)
+	}
+	for i := range want {
+		if servers[i].String() != want[i] {
+			t.Errorf("Expected %s, got %s", want[i], servers[i])
+		}
+	}
+}
+
+func TestProbeFamilyPreference(t *testing.T) {
+	ln, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		t.Skipf("no IPv6 loopback: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	pref := ProbeFamilyPreference(context.Background(), ln.Addr().String(), time.Second)
+	if pref.Chosen != "IPv6" || pref.IPv6Err != nil || pref.IPv4Err == nil {
+		t.Errorf("Expected an IPv6-only connection, got %+v", pref)
+	}
+}
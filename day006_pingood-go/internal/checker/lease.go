@@ -0,0 +1,206 @@
+package checker
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dhclientLeaseFiles are the places Debian, Ubuntu and RHEL-style systems
+// keep ISC dhclient leases. %s is replaced with the interface name.
+var dhclientLeaseFiles = []string{
+	"/var/lib/dhcp/dhclient.%s.leases",
+	"/var/lib/dhcp/dhclient.leases",
+	"/var/lib/dhclient/dhclient-%s.leases",
+	"/var/lib/dhclient/dhclient.leases",
+}
+
+var (
+	dhclientExpireRe = regexp.MustCompile(`^expire\s+(?:epoch\s+(\d+)|\d\s+(\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}))`)
+	ipconfigOptionRe = regexp.MustCompile(`^(\w+) \([\w_]+\): (.*)$`)
+)
+
+// parseDHCPOptionLines reads the option names dhclient scripts use
+// (ip_address, routers, dhcp_lease_time, ...). Both `dhcpcd -U` and
+// `nmcli -f DHCP4 device show` print leases this way, nmcli with a
+// "DHCP4.OPTION[n]:" prefix and " = " as separator.
+func parseDHCPOptionLines(output, source string) (DHCPLease, bool) {
+	lease := DHCPLease{Source: source}
+
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, "DHCP4.OPTION") {
+			_, line, _ = strings.Cut(line, ":")
+		}
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		value = strings.Trim(strings.TrimSpace(value), `'"`)
+
+		switch key {
+		case "ip_address":
+			lease.Address = value
+		case "subnet_mask":
+			lease.SubnetMask = value
+		case "dhcp_server_identifier":
+			lease.Server = value
+		case "routers":
+			lease.Routers = strings.Fields(value)
+		case "domain_name_servers":
+			lease.DNS = strings.Fields(value)
+		case "domain_name":
+			lease.Domain = value
+		case "dhcp_lease_time":
+			lease.LeaseTime = parseSeconds(value)
+		case "expiry":
+			if epoch, err := strconv.ParseInt(value, 10, 64); err == nil {
+				lease.Expiry = time.Unix(epoch, 0)
+			}
+		}
+	}
+
+	return lease, lease.Address != ""
+}
+
+// parseNetworkdLease reads a systemd-networkd lease file from
+// /run/systemd/netif/leases.
+func parseNetworkdLease(output string) (DHCPLease, bool) {
+	lease := DHCPLease{Source: "systemd-networkd"}
+
+	for _, line := range strings.Split(output, "\n") {
+		key, value, found := strings.Cut(strings.TrimSpace(line), "=")
+		if !found {
+			continue
+		}
+
+		switch key {
+		case "ADDRESS":
+			lease.Address = value
+		case "NETMASK":
+			lease.SubnetMask = value
+		case "SERVER_ADDRESS":
+			lease.Server = value
+		case "ROUTER":
+			lease.Routers = strings.Fields(value)
+		case "DNS":
+			lease.DNS = strings.Fields(value)
+		case "DOMAINNAME":
+			lease.Domain = value
+		case "LIFETIME":
+			lease.LeaseTime = parseSeconds(value)
+		}
+	}
+
+	return lease, lease.Address != ""
+}
+
+// parseDhclientLeases returns the last lease for iface in an ISC dhclient
+// lease file, which is the one currently in use.
+func parseDhclientLeases(output, iface string) (DHCPLease, bool) {
+	var last DHCPLease
+	var current DHCPLease
+	var currentIface string
+	found := false
+
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSuffix(strings.TrimSpace(line), ";")
+
+		switch {
+		case strings.HasPrefix(line, "lease {"):
+			current = DHCPLease{Source: "dhclient"}
+			currentIface = ""
+		case line == "}":
+			if currentIface == iface && current.Address != "" {
+				last = current
+				found = true
+			}
+		case strings.HasPrefix(line, "interface "):
+			currentIface = strings.Trim(strings.TrimPrefix(line, "interface "), `"`)
+		case strings.HasPrefix(line, "fixed-address "):
+			current.Address = strings.TrimPrefix(line, "fixed-address ")
+		case strings.HasPrefix(line, "option "):
+			name, value, _ := strings.Cut(strings.TrimPrefix(line, "option "), " ")
+			value = strings.Trim(value, `"`)
+			switch name {
+			case "subnet-mask":
+				current.SubnetMask = value
+			case "routers":
+				current.Routers = splitList(value)
+			case "domain-name-servers":
+				current.DNS = splitList(value)
+			case "domain-name":
+				current.Domain = value
+			case "dhcp-server-identifier":
+				current.Server = value
+			case "dhcp-lease-time":
+				current.LeaseTime = parseSeconds(value)
+			}
+		case strings.HasPrefix(line, "expire "):
+			matches := dhclientExpireRe.FindStringSubmatch(line)
+			switch {
+			case matches == nil:
+			case matches[1] != "":
+				epoch, _ := strconv.ParseInt(matches[1], 10, 64)
+				current.Expiry = time.Unix(epoch, 0)
+			default:
+				current.Expiry, _ = time.Parse("2006/01/02 15:04:05", matches[2])
+			}
+		}
+	}
+
+	return last, found
+}
+
+// parseIpconfigPacket reads the DHCP packet macOS prints for
+// `ipconfig getpacket <iface>`.
+func parseIpconfigPacket(output string) (DHCPLease, bool) {
+	lease := DHCPLease{Source: "ipconfig"}
+
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "yiaddr = ") {
+			lease.Address = strings.TrimPrefix(line, "yiaddr = ")
+			continue
+		}
+
+		matches := ipconfigOptionRe.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+		value := strings.Trim(matches[2], "{}")
+
+		switch matches[1] {
+		case "server_identifier":
+			lease.Server = value
+		case "subnet_mask":
+			lease.SubnetMask = value
+		case "router":
+			lease.Routers = splitList(value)
+		case "domain_name_server":
+			lease.DNS = splitList(value)
+		case "domain_name":
+			lease.Domain = value
+		case "lease_time":
+			if seconds, err := strconv.ParseInt(value, 0, 64); err == nil {
+				lease.LeaseTime = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+
+	return lease, lease.Address != "" && lease.Address != "0.0.0.0"
+}
+
+func parseSeconds(value string) time.Duration {
+	seconds, err := strconv.Atoi(value)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// splitList splits comma- or space-separated address lists.
+func splitList(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' })
+}
@@ -0,0 +1,125 @@
+package checker
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckDHCPFixtures(t *testing.T) {
+	tests := []struct {
+		scenario string
+		iface    string
+		want     DHCPLease
+		wantErr  bool
+	}{
+		{
+			scenario: "linux-ubuntu-22.04",
+			iface:    "eth0",
+			want: DHCPLease{Source: "systemd-networkd", Address: "192.168.1.23", SubnetMask: "255.255.255.0", Server: "192.168.1.1",
+				Routers: []string{"192.168.1.1"}, DNS: []string{"192.168.1.1", "8.8.8.8"}, Domain: "home.lan", LeaseTime: 24 * time.Hour},
+		},
+		{
+			scenario: "linux-debian-12-de_DE",
+			iface:    "eth0",
+			want: DHCPLease{Source: "dhclient", Address: "10.20.30.40", SubnetMask: "255.255.255.0", Server: "10.20.30.1",
+				Routers: []string{"10.20.30.1"}, DNS: []string{"10.20.30.1", "9.9.9.9"}, Domain: "büro.example", LeaseTime: time.Hour,
+				Expiry: time.Date(2024, 5, 1, 9, 43, 10, 0, time.UTC)},
+		},
+		{
+			scenario: "linux-fedora-40-minimal",
+			iface:    "eth0",
+			want: DHCPLease{Source: "NetworkManager", Address: "192.168.122.50", SubnetMask: "255.255.255.0", Server: "192.168.122.1",
+				Routers: []string{"192.168.122.1"}, DNS: []string{"192.168.122.1"}, LeaseTime: time.Hour, Expiry: time.Unix(1714557790, 0)},
+		},
+		{
+			scenario: "darwin-macos-14",
+			iface:    "en0",
+			want: DHCPLease{Source: "ipconfig", Address: "192.168.0.12", SubnetMask: "255.255.255.0", Server: "192.168.0.1",
+				Routers: []string{"192.168.0.1"}, DNS: []string{"192.168.0.1", "1.1.1.1"}, Domain: "home.arpa", LeaseTime: 24 * time.Hour},
+		},
+		{
+			scenario: "linux-alpine-3.19",
+			iface:    "eth0",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.scenario, func(t *testing.T) {
+			nc := loadFixtureChecker(t, tt.scenario)
+
+			lease, err := nc.CheckDHCP(tt.iface)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error=%v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr {
+				return
+			}
+			assertLease(t, lease, tt.want)
+		})
+	}
+}
+
+func TestParseDHCPOptionLinesDhcpcd(t *testing.T) {
+	output := `broadcast_address=192.168.1.255
+dhcp_lease_time=7200
+dhcp_message_type=5
+dhcp_server_identifier=192.168.1.254
+domain_name='lan'
+domain_name_servers='192.168.1.254 1.1.1.1'
+ip_address=192.168.1.77
+network_number=192.168.1.0
+routers=192.168.1.254
+subnet_cidr=24
+subnet_mask=255.255.255.0
+`
+	lease, ok := parseDHCPOptionLines(output, "dhcpcd")
+	if !ok {
+		t.Fatal("Expected lease to be found")
+	}
+	assertLease(t, lease, DHCPLease{Source: "dhcpcd", Address: "192.168.1.77", SubnetMask: "255.255.255.0", Server: "192.168.1.254",
+		Routers: []string{"192.168.1.254"}, DNS: []string{"192.168.1.254", "1.1.1.1"}, Domain: "lan", LeaseTime: 2 * time.Hour})
+
+	if _, ok := parseDHCPOptionLines("", "dhcpcd"); ok {
+		t.Error("Expected empty output to yield no lease")
+	}
+}
+
+func TestParseDhclientEpochExpiry(t *testing.T) {
+	output := `lease {
+  interface "wlan0";
+  fixed-address 192.168.8.20;
+  option routers 192.168.8.1;
+  expire epoch 1714557790; # Wed May 01 10:03:10 2024
+}
+`
+	lease, ok := parseDhclientLeases(output, "wlan0")
+	if !ok {
+		t.Fatal("Expected lease to be found")
+	}
+	if !lease.Expiry.Equal(time.Unix(1714557790, 0)) {
+		t.Errorf("Expected expiry from epoch, got %v", lease.Expiry)
+	}
+	if _, ok := parseDhclientLeases(output, "eth0"); ok {
+		t.Error("Expected no lease for another interface")
+	}
+}
+
+func assertLease(t *testing.T, got, want DHCPLease) {
+	t.Helper()
+
+	if got.Source != want.Source || got.Address != want.Address || got.SubnetMask != want.SubnetMask ||
+		got.Server != want.Server || got.Domain != want.Domain || got.LeaseTime != want.LeaseTime {
+		t.Errorf("Expected lease %+v, got %+v", want, got)
+	}
+	if strings.Join(got.Routers, ",") != strings.Join(want.Routers, ",") {
+		t.Errorf("Expected routers %v, got %v", want.Routers, got.Routers)
+	}
+	if strings.Join(got.DNS, ",") != strings.Join(want.DNS, ",") {
+		t.Errorf("Expected DNS %v, got %v", want.DNS, got.DNS)
+	}
+	if !got.Expiry.Equal(want.Expiry) {
+		t.Errorf("Expected expiry %v, got %v", want.Expiry, got.Expiry)
+	}
+}
@@ -2,8 +2,6 @@ package checker
 
 import (
 	"fmt"
-	"net"
-	"net/http"
 	"regexp"
 	"strings"
 	"time"
@@ -14,6 +12,12 @@ type LinuxChecker struct {
 }
 
 func (l *LinuxChecker) GetIPAddresses(iface string) (string, string, error) {
+	ipv4, ipv6, err := l.ipAddresses(iface)
+	logAddresses(l.context(), iface, ipv4, ipv6, err)
+	return ipv4, ipv6, err
+}
+
+func (l *LinuxChecker) ipAddresses(iface string) (string, string, error) {
 	var ipv4, ipv6 string
 	
 	output, err := l.executeCommand("ip", "addr", "show", iface)
@@ -48,6 +52,12 @@ func (l *LinuxChecker) GetIPAddresses(iface string) (string, string, error) {
 }
 
 func (l *LinuxChecker) GetDefaultGateway(iface string) (string, error) {
+	gateway, err := l.defaultGateway(iface)
+	logDefaultGateway(l.context(), iface, gateway, err)
+	return gateway, err
+}
+
+func (l *LinuxChecker) defaultGateway(iface string) (string, error) {
 	output, err := l.executeCommand("ip", "route", "show", "default", "dev", iface)
 	if err != nil {
 		return "", fmt.Errorf("failed to get default gateway: %w", err)
@@ -81,6 +91,7 @@ func (l *LinuxChecker) PingTest(targets []string, count int, interval float64, i
 			result.Success = false
 		}
 		
+		logPingResult(l.context(), result)
 		results = append(results, result)
 		
 		time.Sleep(time.Duration(interval) * time.Second)
@@ -92,13 +103,16 @@ func (l *LinuxChecker) PingTest(targets []string, count int, interval float64, i
 func (l *LinuxChecker) Traceroute(target string, count int, interval float64, expected map[string]string) (TracerouteResult, error) {
 	output, err := l.executeCommand("traceroute", "-n", "-q", fmt.Sprintf("%d", count), target)
 	if err != nil {
-		return TracerouteResult{Target: target, Success: false, Error: err}, err
+		result := TracerouteResult{Target: target, Success: false, Error: err}
+		logTracerouteResult(l.context(), result)
+		return result, err
 	}
 	
 	result := l.parseTracerouteOutput(output, expected)
 	result.Target = target
+	logTracerouteResult(l.context(), result)
 	
-	return result, nil
+	return result, result.Error
 }
 
 func (l *LinuxChecker) CheckDNS(domains []string, recordType string) ([]DNSResult, error) {
@@ -128,45 +142,178 @@ func (l *LinuxChecker) CheckDNS(domains []string, recordType string) ([]DNSResul
 			}
 		}
 		
+		logDNSResult(l.context(), result)
 		results = append(results, result)
 	}
 	
 	return results, nil
 }
 
-func (l *LinuxChecker) CheckHTTP(url string, ipv6 bool) (HTTPResult, error) {
-	result := HTTPResult{URL: url}
+func (l *LinuxChecker) CheckGateway(iface string, count int, interval float64) (GatewayResult, error) {
+	result := GatewayResult{Interface: iface, ARPReplies: make(map[string][]string)}
 	
-	dialer := &net.Dialer{
-		Timeout: 30 * time.Second,
+	if gateway, err := l.GetDefaultGateway(iface); err == nil {
+		result.IPv4Gateway = gateway
+	}
+	if output, err := l.executeCommand("ip", "-6", "route", "show", "default", "dev", iface); err == nil {
+		result.IPv6Gateways = parseRouteGateways(output)
 	}
 	
-	if ipv6 {
-		dialer.FallbackDelay = -1
+	if result.IPv4Gateway == "" && len(result.IPv6Gateways) == 0 {
+		return result, fmt.Errorf("no default gateway found for interface %s", iface)
+	}
+	
+	if result.IPv4Gateway != "" {
+		pings, _ := l.PingTest([]string{result.IPv4Gateway}, count, interval, false)
+		result.Ping = append(result.Ping, pings...)
+		
+		output, err := l.executeCommand("ip", "neigh", "show", result.IPv4Gateway, "dev", iface)
+		if err != nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("failed to read ARP entry: %v", err))
+		}
+		result.Neighbors = append(result.Neighbors, parseIPNeigh(output)...)
+		
+		// arping exits non-zero when nobody answers, so only its output matters.
+		output, err = l.executeCommand("arping", "-c", fmt.Sprintf("%d", count), "-I", iface, result.IPv4Gateway)
+		replies := parseArping(output)
+		if len(replies) == 0 && err != nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("duplicate address check skipped: %v", err))
+		}
+		for ip, macs := range replies {
+			result.ARPReplies[ip] = macs
+		}
+	}
+	
+	for _, gateway := range result.IPv6Gateways {
+		pings, _ := l.PingTest([]string{scopedAddress(gateway, iface)}, count, interval, true)
+		result.Ping = append(result.Ping, pings...)
+		
+		output, err := l.executeCommand("ip", "-6", "neigh", "show", gateway, "dev", iface)
+		if err != nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("failed to read NDP entry: %v", err))
+		}
+		result.Neighbors = append(result.Neighbors, parseIPNeigh(output)...)
+	}
+	
+	output, err := l.executeCommand("rdisc6", "-m", iface)
+	result.RouterAdvertisements = parseRdisc6(output)
+	if len(result.RouterAdvertisements) == 0 {
+		kernel, kernelErr := l.executeCommand("ip", "-6", "route", "show", "proto", "ra", "dev", iface)
+		if kernelErr == nil {
+			result.RouterAdvertisements = parseKernelRA(kernel)
+		}
+		if err != nil && len(result.RouterAdvertisements) > 0 {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("no router advertisement received (%v), showing what the kernel learned", err))
+		}
+	}
+	
+	logGatewayResult(l.context(), result)
+	return result, nil
+}
+
+// CheckDHCP asks each DHCP client Linux distributions commonly use for its
+// lease, in order: NetworkManager, systemd-networkd, dhcpcd and dhclient.
+func (l *LinuxChecker) CheckDHCP(iface string) (DHCPLease, error) {
+	lease, err := l.dhcpLease(iface)
+	logDHCPLease(l.context(), iface, lease, err)
+	return lease, err
+}
+
+func (l *LinuxChecker) dhcpLease(iface string) (DHCPLease, error) {
+	if output, err := l.executeCommand("nmcli", "-t", "-f", "DHCP4", "device", "show", iface); err == nil {
+		if lease, ok := parseDHCPOptionLines(output, "NetworkManager"); ok {
+			return lease, nil
+		}
 	}
 	
-	transport := &http.Transport{
-		DialContext: dialer.DialContext,
+	if ifindex, err := l.executeCommand("cat", "/sys/class/net/"+iface+"/ifindex"); err == nil {
+		output, err := l.executeCommand("cat", "/run/systemd/netif/leases/"+strings.TrimSpace(ifindex))
+		if err == nil {
+			if lease, ok := parseNetworkdLease(output); ok {
+				return lease, nil
+			}
+		}
 	}
 	
-	client := &http.Client{
-		Transport: transport,
-		Timeout:   30 * time.Second,
+	if output, err := l.executeCommand("dhcpcd", "-U", iface); err == nil {
+		if lease, ok := parseDHCPOptionLines(output, "dhcpcd"); ok {
+			return lease, nil
+		}
 	}
 	
-	start := time.Now()
-	resp, err := client.Get(url)
-	result.Duration = time.Since(start)
+	for _, pattern := range dhclientLeaseFiles {
+		path := pattern
+		if strings.Contains(pattern, "%s") {
+			path = fmt.Sprintf(pattern, iface)
+		}
+		output, err := l.executeCommand("cat", path)
+		if err != nil {
+			continue
+		}
+		if lease, ok := parseDhclientLeases(output, iface); ok {
+			return lease, nil
+		}
+	}
 	
+	return DHCPLease{}, fmt.Errorf("no DHCP lease found for interface %s", iface)
+}
+
+func (l *LinuxChecker) LookupRoute(destination string) (RouteLookup, error) {
+	route, err := l.lookupRoute(destination)
+	logRouteLookup(l.context(), route, err)
+	return route, err
+}
+
+func (l *LinuxChecker) lookupRoute(destination string) (RouteLookup, error) {
+	address, err := routeLookupAddress(destination)
 	if err != nil {
-		result.Error = err
-		result.Success = false
-		return result, err
+		return RouteLookup{Destination: destination}, err
+	}
+	
+	output, err := l.executeCommand("ip", "route", "get", address)
+	if err != nil {
+		return RouteLookup{Destination: destination, Address: address}, fmt.Errorf("failed to look up route: %w", err)
 	}
-	defer resp.Body.Close()
 	
-	result.StatusCode = resp.StatusCode
-	result.Success = resp.StatusCode >= 200 && resp.StatusCode < 300
+	route, err := parseIPRouteGet(output)
+	route.Destination = destination
+	route.Address = address
 	
-	return result, nil
-}
\ No newline at end of file
+	return route, err
+}
+
+// CheckWireless reads the link through iw, the nl80211 command line client,
+// and adds the link quality and noise level from /proc/net/wireless. Without
+// iw only the /proc values are available.
+func (l *LinuxChecker) CheckWireless(iface string) (WirelessInfo, error) {
+	info, err := l.wirelessInfo(iface)
+	logWirelessInfo(l.context(), iface, info, err)
+	return info, err
+}
+
+func (l *LinuxChecker) wirelessInfo(iface string) (WirelessInfo, error) {
+	procOutput, _ := l.executeCommand("cat", "/proc/net/wireless")
+	info, listed := parseProcWireless(procOutput, iface)
+	
+	output, err := l.executeCommand("iw", "dev", iface, "link")
+	if err != nil {
+		if listed {
+			return info, nil
+		}
+		return WirelessInfo{Interface: iface}, ErrNotWireless
+	}
+	
+	info.Interface = iface
+	info.Source = "iw"
+	info.Connected = false
+	parseIwLink(output, &info)
+	if !info.Connected {
+		return info, nil
+	}
+	
+	if output, err := l.executeCommand("iw", "dev", iface, "station", "get", info.BSSID); err == nil {
+		parseIwStation(output, &info)
+	}
+	
+	return info, nil
+}
@@ -0,0 +1,63 @@
+package checker
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// The checker logs what it runs and what it sees at these levels:
+//
+//	Debug: raw details such as command timings, ping replies, traceroute hops
+//	       and HTTP trace events
+//	Info:  the outcome of each check, failed ones included
+//	Warn:  a check that could not run at all, such as a missing command
+var currentLogger atomic.Pointer[slog.Logger]
+
+func init() {
+	currentLogger.Store(slog.New(discardHandler{}))
+}
+
+// SetLogger sends the checker's log to l. Nothing is logged until it is
+// called.
+func SetLogger(l *slog.Logger) {
+	currentLogger.Store(l)
+}
+
+type loggerKey struct{}
+
+// ContextWithLogger returns a copy of ctx that sends the log of the checks
+// run with it to l instead of the logger set with SetLogger.
+func ContextWithLogger(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// logger returns the logger of ctx, or the one set with SetLogger.
+func logger(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok && l != nil {
+		return l
+	}
+	return currentLogger.Load()
+}
+
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+// milliseconds converts a duration to the float milliseconds used in logs,
+// so JSON logs do not end up with nanosecond integers.
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+func millisecondsList(durations []time.Duration) []float64 {
+	ms := make([]float64, len(durations))
+	for i, d := range durations {
+		ms[i] = milliseconds(d)
+	}
+	return ms
+}
@@ -0,0 +1,124 @@
+package checker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// captureLog sends the checker's log to a buffer for the rest of the test
+// and returns a function that decodes what was logged.
+func captureLog(t *testing.T) func() []map[string]interface{} {
+	t.Helper()
+	var buf bytes.Buffer
+	previous := currentLogger.Load()
+	SetLogger(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	t.Cleanup(func() { SetLogger(previous) })
+
+	return func() []map[string]interface{} {
+		var records []map[string]interface{}
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			if line == "" {
+				continue
+			}
+			var record map[string]interface{}
+			if err := json.Unmarshal([]byte(line), &record); err != nil {
+				t.Fatalf("Invalid log line %q: %v", line, err)
+			}
+			records = append(records, record)
+		}
+		return records
+	}
+}
+
+func TestExecuteCommandLogs(t *testing.T) {
+	records := captureLog(t)
+
+	(&BaseChecker{runner: stubRunner{stdout: "ok\n"}}).executeCommand("ip", "addr", "show", "eth0")
+	(&BaseChecker{runner: stubRunner{stderr: "unknown host", err: &ExitError{Code: 2}}}).executeCommand("ping", "-c", "1", "no.such.host")
+	(&BaseChecker{runner: stubRunner{err: errors.New("permission denied")}}).executeCommand("arping", "192.168.1.1")
+
+	got := records()
+	want := []struct{ level, msg, command string }{
+		{"DEBUG", "command finished", "ip addr show eth0"},
+		{"INFO", "command failed", "ping -c 1 no.such.host"},
+		{"WARN", "command failed", "arping 192.168.1.1"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d records, got %v", len(want), got)
+	}
+	for i, w := range want {
+		if got[i]["level"] != w.level || got[i]["msg"] != w.msg || got[i]["command"] != w.command {
+			t.Errorf("Expected %s %q for %s, got %v", w.level, w.msg, w.command, got[i])
+		}
+	}
+	if got[1]["stderr"] != "unknown host" {
+		t.Errorf("Expected stderr in the log, got %v", got[1])
+	}
+}
+
+func TestLogPingResult(t *testing.T) {
+	records := captureLog(t)
+
+	result := parsePingOutput(`PING 8.8.8.8 (8.8.8.8) 56(84) bytes of data.
+64 bytes from 8.8.8.8: icmp_seq=1 ttl=117 time=9.81 ms
+64 bytes from 8.8.8.8: icmp_seq=2 ttl=117 time=10.2 ms
+
+--- 8.8.8.8 ping statistics ---
+2 packets transmitted, 2 received, 0% packet loss, time 1001ms
+rtt min/avg/max/mdev = 9.810/10.005/10.200/0.195 ms`)
+	result.Target = "8.8.8.8"
+	logPingResult(context.Background(), result)
+
+	got := records()
+	if len(got) != 2 || got[0]["msg"] != "ping replies" || got[1]["msg"] != "ping finished" {
+		t.Fatalf("Unexpected records %v", got)
+	}
+	if rtts, _ := got[0]["rtt_ms"].([]interface{}); len(rtts) != 2 || rtts[0] != 9.81 || rtts[1] != 10.2 {
+		t.Errorf("Expected the RTT of each reply, got %v", got[0]["rtt_ms"])
+	}
+}
+
+func TestLinuxCheckerLogsParsedResults(t *testing.T) {
+	records := captureLog(t)
+
+	linux := &LinuxChecker{BaseChecker{runner: NewReplayRunner(
+		Fixture{Command: "ip route get 8.8.8.8", Stdout: "8.8.8.8 via 192.168.1.1 dev eth0 src 192.168.1.10 uid 1000\n"},
+		Fixture{Command: "nmcli -t -f DHCP4 device show eth0", Stdout: "DHCP4.OPTION[1]:ip_address = 192.168.1.10\nDHCP4.OPTION[2]:dhcp_server_identifier = 192.168.1.1\n"},
+	)}}
+	linux.LookupRoute("8.8.8.8")
+	linux.CheckDHCP("eth0")
+	linux.CheckWireless("eth0")
+
+	byMsg := make(map[string]map[string]interface{})
+	for _, record := range records() {
+		byMsg[record["msg"].(string)] = record
+	}
+	if route := byMsg["route lookup finished"]; route == nil || route["interface"] != "eth0" || route["gateway"] != "192.168.1.1" {
+		t.Errorf("Expected the parsed route in the log, got %v", route)
+	}
+	if lease := byMsg["dhcp lease"]; lease == nil || lease["address"] != "192.168.1.10" || lease["source"] != "NetworkManager" {
+		t.Errorf("Expected the parsed lease in the log, got %v", lease)
+	}
+	if skipped := byMsg["wireless check skipped"]; skipped == nil || skipped["interface"] != "eth0" {
+		t.Errorf("Expected the wired interface to be logged as skipped, got %v", skipped)
+	}
+}
+
+func TestContextWithLogger(t *testing.T) {
+	global := captureLog(t)
+	var buf bytes.Buffer
+	ctx := ContextWithLogger(context.Background(), slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	(&BaseChecker{runner: stubRunner{stdout: "ok\n"}, ctx: ctx}).executeCommand("ip", "addr", "show", "eth0")
+	if !strings.Contains(buf.String(), `"command":"ip addr show eth0"`) {
+		t.Errorf("Expected the command in the context's log, got %q", buf.String())
+	}
+	if records := global(); len(records) != 0 {
+		t.Errorf("Expected nothing in the global log, got %v", records)
+	}
+}
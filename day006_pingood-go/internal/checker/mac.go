@@ -1,9 +1,8 @@
 package checker
 
 import (
+	"errors"
 	"fmt"
-	"net"
-	"net/http"
 	"strings"
 	"time"
 )
@@ -13,6 +12,12 @@ type MacChecker struct {
 }
 
 func (m *MacChecker) GetIPAddresses(iface string) (string, string, error) {
+	ipv4, ipv6, err := m.ipAddresses(iface)
+	logAddresses(m.context(), iface, ipv4, ipv6, err)
+	return ipv4, ipv6, err
+}
+
+func (m *MacChecker) ipAddresses(iface string) (string, string, error) {
 	var ipv4, ipv6 string
 	
 	output, err := m.executeCommand("ifconfig", iface)
@@ -47,6 +52,12 @@ func (m *MacChecker) GetIPAddresses(iface string) (string, string, error) {
 }
 
 func (m *MacChecker) GetDefaultGateway(iface string) (string, error) {
+	gateway, err := m.defaultGateway(iface)
+	logDefaultGateway(m.context(), iface, gateway, err)
+	return gateway, err
+}
+
+func (m *MacChecker) defaultGateway(iface string) (string, error) {
 	output, err := m.executeCommand("route", "-n", "get", "default")
 	if err != nil {
 		return "", fmt.Errorf("failed to get default gateway: %w", err)
@@ -85,6 +96,7 @@ func (m *MacChecker) PingTest(targets []string, count int, interval float64, ipv
 			result.Success = false
 		}
 		
+		logPingResult(m.context(), result)
 		results = append(results, result)
 		
 		time.Sleep(time.Duration(interval) * time.Second)
@@ -96,13 +108,16 @@ func (m *MacChecker) PingTest(targets []string, count int, interval float64, ipv
 func (m *MacChecker) Traceroute(target string, count int, interval float64, expected map[string]string) (TracerouteResult, error) {
 	output, err := m.executeCommand("traceroute", "-n", "-q", fmt.Sprintf("%d", count), target)
 	if err != nil {
-		return TracerouteResult{Target: target, Success: false, Error: err}, err
+		result := TracerouteResult{Target: target, Success: false, Error: err}
+		logTracerouteResult(m.context(), result)
+		return result, err
 	}
 	
 	result := m.parseTracerouteOutput(output, expected)
 	result.Target = target
+	logTracerouteResult(m.context(), result)
 	
-	return result, nil
+	return result, result.Error
 }
 
 func (m *MacChecker) CheckDNS(domains []string, recordType string) ([]DNSResult, error) {
@@ -132,45 +147,124 @@ func (m *MacChecker) CheckDNS(domains []string, recordType string) ([]DNSResult,
 			}
 		}
 		
+		logDNSResult(m.context(), result)
 		results = append(results, result)
 	}
 	
 	return results, nil
 }
 
-func (m *MacChecker) CheckHTTP(url string, ipv6 bool) (HTTPResult, error) {
-	result := HTTPResult{URL: url}
+func (m *MacChecker) CheckGateway(iface string, count int, interval float64) (GatewayResult, error) {
+	result := GatewayResult{Interface: iface, ARPReplies: make(map[string][]string)}
 	
-	dialer := &net.Dialer{
-		Timeout: 30 * time.Second,
+	if gateway, err := m.GetDefaultGateway(iface); err == nil {
+		result.IPv4Gateway = gateway
+	}
+	if output, err := m.executeCommand("route", "-n", "get", "-inet6", "default"); err == nil {
+		if gateway := parseRouteGetGateway(output); gateway != "" {
+			result.IPv6Gateways = append(result.IPv6Gateways, gateway)
+		}
 	}
 	
-	if ipv6 {
-		dialer.FallbackDelay = -1
+	if result.IPv4Gateway == "" && len(result.IPv6Gateways) == 0 {
+		return result, fmt.Errorf("no default gateway found")
+	}
+	
+	if result.IPv4Gateway != "" {
+		pings, _ := m.PingTest([]string{result.IPv4Gateway}, count, interval, false)
+		result.Ping = append(result.Ping, pings...)
+		
+		output, err := m.executeCommand("arp", "-n", result.IPv4Gateway)
+		if err != nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("failed to read ARP entry: %v", err))
+		}
+		result.Neighbors = append(result.Neighbors, parseArpOutput(output)...)
+	}
+	
+	if len(result.IPv6Gateways) > 0 {
+		for _, gateway := range result.IPv6Gateways {
+			pings, _ := m.PingTest([]string{scopedAddress(gateway, iface)}, count, interval, true)
+			result.Ping = append(result.Ping, pings...)
+		}
+		
+		output, err := m.executeCommand("ndp", "-an")
+		if err != nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("failed to read NDP entries: %v", err))
+		}
+		for _, entry := range parseNDPNeighbors(output) {
+			for _, gateway := range result.IPv6Gateways {
+				if entry.IP == unscopedAddress(gateway) {
+					result.Neighbors = append(result.Neighbors, entry)
+				}
+			}
+		}
 	}
 	
-	transport := &http.Transport{
-		DialContext: dialer.DialContext,
+	if output, err := m.executeCommand("ndp", "-rn"); err == nil {
+		result.RouterAdvertisements = parseNDPRouters(output, iface)
+	}
+	if output, err := m.executeCommand("ndp", "-pn"); err == nil {
+		result.RouterAdvertisements = parseNDPPrefixes(output, iface, result.RouterAdvertisements)
 	}
 	
-	client := &http.Client{
-		Transport: transport,
-		Timeout:   30 * time.Second,
+	logGatewayResult(m.context(), result)
+	return result, nil
+}
+
+func (m *MacChecker) CheckDHCP(iface string) (DHCPLease, error) {
+	lease, err := m.dhcpLease(iface)
+	logDHCPLease(m.context(), iface, lease, err)
+	return lease, err
+}
+
+func (m *MacChecker) dhcpLease(iface string) (DHCPLease, error) {
+	output, err := m.executeCommand("ipconfig", "getpacket", iface)
+	if err != nil {
+		return DHCPLease{}, fmt.Errorf("no DHCP lease found for interface %s: %w", iface, err)
 	}
 	
-	start := time.Now()
-	resp, err := client.Get(url)
-	result.Duration = time.Since(start)
+	lease, ok := parseIpconfigPacket(output)
+	if !ok {
+		return DHCPLease{}, fmt.Errorf("no DHCP lease found for interface %s", iface)
+	}
 	
+	return lease, nil
+}
+
+func (m *MacChecker) LookupRoute(destination string) (RouteLookup, error) {
+	route, err := m.lookupRoute(destination)
+	logRouteLookup(m.context(), route, err)
+	return route, err
+}
+
+func (m *MacChecker) lookupRoute(destination string) (RouteLookup, error) {
+	address, err := routeLookupAddress(destination)
 	if err != nil {
-		result.Error = err
-		result.Success = false
-		return result, err
+		return RouteLookup{Destination: destination}, err
+	}
+	
+	args := []string{"-n", "get"}
+	if strings.Contains(address, ":") {
+		args = append(args, "-inet6")
+	}
+	output, err := m.executeCommand("route", append(args, address)...)
+	if err != nil {
+		return RouteLookup{Destination: destination, Address: address}, fmt.Errorf("failed to look up route: %w", err)
 	}
-	defer resp.Body.Close()
 	
-	result.StatusCode = resp.StatusCode
-	result.Success = resp.StatusCode >= 200 && resp.StatusCode < 300
+	route, err := parseBSDRouteGet(output)
+	route.Destination = destination
+	route.Address = address
 	
-	return result, nil
-}
\ No newline at end of file
+	return route, err
+}
+
+func (m *MacChecker) CheckWireless(iface string) (WirelessInfo, error) {
+	info, err := m.wirelessInfo(iface)
+	logWirelessInfo(m.context(), iface, info, err)
+	return info, err
+}
+
+func (m *MacChecker) wirelessInfo(iface string) (WirelessInfo, error) {
+	return WirelessInfo{Interface: iface}, fmt.Errorf("wireless check is not available on macOS: %w", errors.ErrUnsupported)
+}
@@ -0,0 +1,345 @@
+package checker
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrUnrecognizedOutput is reported when a command's output does not match
+// any of the formats the parsers know about.
+var ErrUnrecognizedOutput = errors.New("unrecognized output format")
+
+// Ping output differs between iputils, busybox, BSD/macOS and Windows, and
+// some implementations translate their summary lines. The patterns below are
+// matched line by line and cover all of them.
+var (
+	pingLossRe     = regexp.MustCompile(`(\d+(?:[.,]\d+)?)\s*%`)
+	pingLossWordRe = regexp.MustCompile(`(?i)packet loss|\bloss\b|verlust|perte|perdus|pérdida|perdidos|perdita|損失|丢失|丟失`)
+	pingCountRe    = regexp.MustCompile(`\d+`)
+	pingDupCountRe = regexp.MustCompile(`\+(\d+) duplicates`)
+	pingRTTRe      = regexp.MustCompile(`min/avg/max(?:/(?:mdev|stddev|std-dev))?\s*=\s*(\d+(?:\.\d+)?)/(\d+(?:\.\d+)?)/(\d+(?:\.\d+)?)(?:/(\d+(?:\.\d+)?))?\s*ms`)
+	pingWinRTTRe   = regexp.MustCompile(`=\s*(\d+)\s*ms`)
+	pingTimeRe     = regexp.MustCompile(`(?i)(?:time|zeit|temps|tiempo|tempo|時間)\s*[=<]\s*(\d+(?:\.\d+)?)\s*ms`)
+	pingSeqRe      = regexp.MustCompile(`(?:icmp_seq|seq)[=\s](\d+)`)
+	pingTTLRe      = regexp.MustCompile(`(?i)\b(?:ttl|hlim)=(\d+)`)
+	pingFromRe     = regexp.MustCompile(`(?i)\b(?:from|von)\s+(\S+?)(?:\s+\(([^)]+)\))?[:,]?\s`)
+	pingErrorRe    = regexp.MustCompile(`(?i)destination (?:host|net|network|port|protocol) unreachable|(?:time to live|ttl) exceeded|ttl expired in transit|network is unreachable|host is unreachable`)
+)
+
+func (b *BaseChecker) parsePingOutput(output string) PingResult {
+	return parsePingOutput(output)
+}
+
+func parsePingOutput(output string) PingResult {
+	result := PingResult{Success: false}
+	sawSummary := false
+	sawRTT := false
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if strings.Contains(line, "%") && pingLossWordRe.MatchString(line) {
+			if parsePingSummary(line, &result) {
+				sawSummary = true
+			}
+			continue
+		}
+
+		if matches := pingRTTRe.FindStringSubmatch(line); matches != nil {
+			result.MinRTT = parseMillis(matches[1])
+			result.AvgRTT = parseMillis(matches[2])
+			result.MaxRTT = parseMillis(matches[3])
+			if matches[4] != "" {
+				result.StdDevRTT = parseMillis(matches[4])
+			}
+			sawRTT = true
+			continue
+		}
+
+		if matches := pingWinRTTRe.FindAllStringSubmatch(line, -1); len(matches) == 3 && !pingTimeRe.MatchString(line) {
+			// Windows prints minimum, maximum and average in that order.
+			result.MinRTT = parseMillis(matches[0][1])
+			result.MaxRTT = parseMillis(matches[1][1])
+			result.AvgRTT = parseMillis(matches[2][1])
+			sawRTT = true
+			continue
+		}
+
+		if message := pingErrorRe.FindString(line); message != "" {
+			result.ICMPErrors = append(result.ICMPErrors, PingError{
+				From:    pingFrom(line),
+				Seq:     pingSeq(line),
+				Message: message,
+			})
+			continue
+		}
+
+		if matches := pingTimeRe.FindStringSubmatch(line); matches != nil {
+			reply := PingReply{
+				From:      pingFrom(line),
+				Seq:       pingSeq(line),
+				TTL:       -1,
+				RTT:       parseMillis(matches[1]),
+				Duplicate: strings.Contains(line, "(DUP!)"),
+			}
+			if ttl := pingTTLRe.FindStringSubmatch(line); ttl != nil {
+				reply.TTL, _ = strconv.Atoi(ttl[1])
+			}
+			result.Replies = append(result.Replies, reply)
+		}
+	}
+
+	if !sawSummary && !sawRTT && len(result.Replies) == 0 && len(result.ICMPErrors) == 0 {
+		result.Error = fmt.Errorf("ping: %w", ErrUnrecognizedOutput)
+		return result
+	}
+
+	result.computeStats()
+
+	if sawSummary {
+		result.Success = result.PacketLoss < 100
+	} else {
+		result.Success = len(result.Replies) > 0
+	}
+	// Windows counts "Destination host unreachable" as a received packet.
+	if len(result.Replies) == 0 && len(result.ICMPErrors) > 0 {
+		result.Success = false
+	}
+
+	return result
+}
+
+func parsePingSummary(line string, result *PingResult) bool {
+	loss := pingLossRe.FindStringSubmatch(line)
+	if loss == nil {
+		return false
+	}
+	result.PacketLoss, _ = strconv.ParseFloat(strings.Replace(loss[1], ",", ".", 1), 64)
+
+	// Every supported format lists transmitted before received.
+	counts := pingCountRe.FindAllString(line, -1)
+	if len(counts) >= 2 {
+		result.Transmitted, _ = strconv.Atoi(counts[0])
+		result.Received, _ = strconv.Atoi(counts[1])
+	}
+	if dup := pingDupCountRe.FindStringSubmatch(line); dup != nil {
+		result.Duplicates, _ = strconv.Atoi(dup[1])
+	}
+
+	return true
+}
+
+func pingFrom(line string) string {
+	matches := pingFromRe.FindStringSubmatch(line + " ")
+	if matches == nil {
+		return ""
+	}
+	if matches[2] != "" {
+		return matches[2]
+	}
+	return matches[1]
+}
+
+func pingSeq(line string) int {
+	matches := pingSeqRe.FindStringSubmatch(line)
+	if matches == nil {
+		return -1
+	}
+	seq, _ := strconv.Atoi(matches[1])
+	return seq
+}
+
+func parseMillis(s string) time.Duration {
+	ms, _ := strconv.ParseFloat(s, 64)
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+var (
+	traceHopRe      = regexp.MustCompile(`^\s*(\d+)\s+(\S.*)$`)
+	traceTimeoutRe  = regexp.MustCompile(`(?i)request timed out\.?`)
+	traceBracketsRe = regexp.MustCompile(`^[(\[](.+)[)\]]$`)
+)
+
+func (b *BaseChecker) parseTracerouteOutput(output string, expected map[string]string) TracerouteResult {
+	return parseTracerouteOutput(output, expected)
+}
+
+// parseTracerouteOutput understands traceroute output with and without -n,
+// busybox and BSD variants, unresponsive probes, multiple responders per hop,
+// !H/!N style annotations and Windows tracert.
+func parseTracerouteOutput(output string, expected map[string]string) TracerouteResult {
+	result := TracerouteResult{
+		Success:        true,
+		PassesExpected: make(map[string]bool),
+	}
+
+	for device := range expected {
+		result.PassesExpected[device] = false
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		if matches := traceHopRe.FindStringSubmatch(line); matches != nil {
+			number, _ := strconv.Atoi(matches[1])
+			hop := Hop{Number: number}
+			parseHopProbes(&hop, matches[2])
+			result.Hops = append(result.Hops, hop)
+			continue
+		}
+
+		// BSD traceroute puts additional responders for the same hop on
+		// indented continuation lines.
+		if len(result.Hops) > 0 && (line[0] == ' ' || line[0] == '\t') {
+			fields := strings.Fields(line)
+			if net.ParseIP(strings.Trim(fields[0], "()[]")) != nil {
+				parseHopProbes(&result.Hops[len(result.Hops)-1], line)
+			}
+		}
+	}
+
+	if len(result.Hops) == 0 {
+		result.Success = false
+		result.Error = fmt.Errorf("traceroute: %w", ErrUnrecognizedOutput)
+		return result
+	}
+
+	result.CheckExpected(expected)
+	return result
+}
+
+// CheckExpected recomputes PassesExpected, for instance after the hops have
+// been enriched with names and ASNs.
+func (r *TracerouteResult) CheckExpected(expected map[string]string) {
+	r.PassesExpected = make(map[string]bool)
+	for device, want := range expected {
+		r.PassesExpected[device] = false
+		for _, hop := range r.Hops {
+			if HopMatches(hop, want) {
+				r.PassesExpected[device] = true
+				break
+			}
+		}
+	}
+}
+
+// HopMatches reports whether hop is the expected device. want is an exact
+// address or name, a CIDR prefix such as 10.0.0.0/8, a glob such as
+// *.isp.example.net, or an AS number such as AS15169, which only matches
+// enriched hops.
+func HopMatches(hop Hop, want string) bool {
+	if hop.Address == "" {
+		return false
+	}
+	if strings.ContainsAny(want, "*?[") {
+		for _, candidate := range append([]string{hop.Name}, hop.Addresses...) {
+			if matched, _ := path.Match(strings.ToLower(want), strings.ToLower(candidate)); matched {
+				return true
+			}
+		}
+		return false
+	}
+	if asn, ok := parseASN(want); ok {
+		return hop.ASN != 0 && hop.ASN == asn
+	}
+	if prefix, err := netip.ParsePrefix(want); err == nil {
+		for _, address := range hop.Addresses {
+			if addr, err := netip.ParseAddr(address); err == nil && prefix.Contains(addr.Unmap()) {
+				return true
+			}
+		}
+		return false
+	}
+	return hop.Name == want || containsString(hop.Addresses, want)
+}
+
+// IsHopPattern reports whether want, as HopMatches takes it, matches a set
+// of hops rather than naming one device: a glob, a CIDR prefix or an AS
+// number.
+func IsHopPattern(want string) bool {
+	if strings.ContainsAny(want, "*?[") {
+		return true
+	}
+	if _, ok := parseASN(want); ok {
+		return true
+	}
+	_, err := netip.ParsePrefix(want)
+	return err == nil
+}
+
+func parseASN(s string) (int, bool) {
+	if len(s) < 3 || !strings.EqualFold(s[:2], "AS") {
+		return 0, false
+	}
+	asn, err := strconv.Atoi(s[2:])
+	return asn, err == nil && asn > 0
+}
+
+func parseHopProbes(hop *Hop, text string) {
+	text = traceTimeoutRe.ReplaceAllString(text, "")
+	fields := strings.Fields(text)
+
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+
+		switch {
+		case field == "*":
+			continue
+		case strings.HasPrefix(field, "!"):
+			hop.Flags = append(hop.Flags, field)
+			continue
+		}
+
+		value := strings.TrimSuffix(strings.TrimPrefix(field, "<"), "ms")
+		if ms, err := strconv.ParseFloat(value, 64); err == nil {
+			if strings.HasSuffix(field, "ms") || (i+1 < len(fields) && fields[i+1] == "ms") {
+				hop.RTT = append(hop.RTT, time.Duration(ms*float64(time.Millisecond)))
+				if !strings.HasSuffix(field, "ms") {
+					i++
+				}
+				continue
+			}
+		}
+
+		name := field
+		address := field
+		if i+1 < len(fields) {
+			if matches := traceBracketsRe.FindStringSubmatch(fields[i+1]); matches != nil {
+				address = matches[1]
+				i++
+			}
+		}
+		if hop.Address == "" {
+			hop.Address = address
+			hop.Name = name
+		}
+		if !containsString(hop.Addresses, address) {
+			hop.Addresses = append(hop.Addresses, address)
+		}
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
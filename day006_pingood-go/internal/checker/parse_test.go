@@ -0,0 +1,335 @@
+package checker
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParsePingOutputVariants(t *testing.T) {
+	tests := []struct {
+		name        string
+		output      string
+		success     bool
+		loss        float64
+		transmitted int
+		received    int
+		avgRTT      time.Duration
+		stdDevRTT   time.Duration
+		replies     int
+		duplicates  int
+		firstTTL    int
+		firstSeq    int
+	}{
+		{
+			name: "iputils with duplicates",
+			output: `PING 192.168.1.255 (192.168.1.255) 56(84) bytes of data.
+64 bytes from 192.168.1.10: icmp_seq=1 ttl=64 time=0.512 ms
+64 bytes from 192.168.1.11: icmp_seq=1 ttl=64 time=0.733 ms (DUP!)
+64 bytes from 192.168.1.10: icmp_seq=2 ttl=64 time=0.498 ms
+
+--- 192.168.1.255 ping statistics ---
+2 packets transmitted, 2 received, +1 duplicates, 0% packet loss, time 1001ms
+rtt min/avg/max/mdev = 0.498/0.581/0.733/0.107 ms`,
+			success: true, transmitted: 2, received: 2,
+			avgRTT: 581 * time.Microsecond, stdDevRTT: 107 * time.Microsecond,
+			replies: 3, duplicates: 1, firstTTL: 64, firstSeq: 1,
+		},
+		{
+			name: "busybox",
+			output: `PING 1.1.1.1 (1.1.1.1): 56 data bytes
+64 bytes from 1.1.1.1: seq=0 ttl=57 time=4.210 ms
+64 bytes from 1.1.1.1: seq=1 ttl=57 time=4.388 ms
+
+--- 1.1.1.1 ping statistics ---
+2 packets transmitted, 2 packets received, 0% packet loss
+round-trip min/avg/max = 4.210/4.299/4.388 ms`,
+			success: true, transmitted: 2, received: 2,
+			avgRTT: 4299 * time.Microsecond, stdDevRTT: 89 * time.Microsecond, replies: 2, firstTTL: 57, firstSeq: 0,
+		},
+		{
+			name: "macOS ping6",
+			output: `PING6(56=40+8+8 bytes) 2001:db8::1 --> 2606:4700:4700::1111
+16 bytes from 2606:4700:4700::1111, icmp_seq=0 hlim=58 time=9.512 ms
+Request timeout for icmp_seq 1
+
+--- 2606:4700:4700::1111 ping6 statistics ---
+2 packets transmitted, 1 packets received, 50.0% packet loss
+round-trip min/avg/max/std-dev = 9.512/9.512/9.512/0.000 ms`,
+			success: true, loss: 50, transmitted: 2, received: 1,
+			avgRTT: 9512 * time.Microsecond, replies: 1, firstTTL: 58, firstSeq: 0,
+		},
+		{
+			name: "Windows",
+			output: `
+Pinging 8.8.8.8 with 32 bytes of data:
+Reply from 8.8.8.8: bytes=32 time=7ms TTL=117
+Reply from 8.8.8.8: bytes=32 time<1ms TTL=117
+Request timed out.
+Reply from 8.8.8.8: bytes=32 time=6ms TTL=117
+
+Ping statistics for 8.8.8.8:
+    Packets: Sent = 4, Received = 3, Lost = 1 (25% loss),
+Approximate round trip times in milli-seconds:
+    Minimum = 1ms, Maximum = 7ms, Average = 4ms`,
+			success: true, loss: 25, transmitted: 4, received: 3,
+			avgRTT: 4 * time.Millisecond, stdDevRTT: 2625 * time.Microsecond, replies: 3, firstTTL: 117, firstSeq: -1,
+		},
+		{
+			name: "Windows Japanese",
+			output: `
+8.8.8.8 に ping を送信しています 32 バイトのデータ:
+8.8.8.8 からの応答: バイト数 =32 時間 =5ms TTL=117
+8.8.8.8 からの応答: バイト数 =32 時間 =6ms TTL=117
+
+8.8.8.8 の ping 統計:
+    パケット数: 送信 = 2、受信 = 2、損失 = 0 (0% の損失)、
+ラウンド トリップの概算時間 (ミリ秒):
+    最小 = 5ms、最大 = 6ms、平均 = 5ms`,
+			success: true, transmitted: 2, received: 2,
+			avgRTT: 5 * time.Millisecond, stdDevRTT: 500 * time.Microsecond, replies: 2, firstTTL: 117, firstSeq: -1,
+		},
+		{
+			name: "iputils French locale",
+			output: `PING 9.9.9.9 (9.9.9.9) 56(84) octets de données.
+64 octets de 9.9.9.9 : icmp_seq=1 ttl=58 temps=10.1 ms
+
+--- statistiques ping 9.9.9.9 ---
+1 paquets transmis, 1 reçus, 0 % paquets perdus, temps 0 ms
+rtt min/avg/max/mdev = 10.100/10.100/10.100/0.000 ms`,
+			success: true, transmitted: 1, received: 1,
+			avgRTT: 10100 * time.Microsecond, replies: 1, firstTTL: 58, firstSeq: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := parsePingOutput(tt.output)
+
+			if result.Error != nil {
+				t.Fatalf("Unexpected error: %v", result.Error)
+			}
+			if result.Success != tt.success {
+				t.Errorf("Expected success=%v, got %v", tt.success, result.Success)
+			}
+			if result.PacketLoss != tt.loss {
+				t.Errorf("Expected %.1f%% packet loss, got %.1f%%", tt.loss, result.PacketLoss)
+			}
+			if result.Transmitted != tt.transmitted || result.Received != tt.received {
+				t.Errorf("Expected %d/%d transmitted/received, got %d/%d",
+					tt.transmitted, tt.received, result.Transmitted, result.Received)
+			}
+			if abs(result.AvgRTT-tt.avgRTT) > time.Microsecond {
+				t.Errorf("Expected AvgRTT=%v, got %v", tt.avgRTT, result.AvgRTT)
+			}
+			if abs(result.StdDevRTT-tt.stdDevRTT) > time.Microsecond {
+				t.Errorf("Expected StdDevRTT=%v, got %v", tt.stdDevRTT, result.StdDevRTT)
+			}
+			if len(result.Replies) != tt.replies {
+				t.Fatalf("Expected %d replies, got %d", tt.replies, len(result.Replies))
+			}
+			if result.Duplicates != tt.duplicates {
+				t.Errorf("Expected %d duplicates, got %d", tt.duplicates, result.Duplicates)
+			}
+			metrics := result.Metrics()
+			if metrics["duplicates"] != float64(tt.duplicates) || abs(time.Duration(metrics["stddev"]*float64(time.Millisecond))-tt.stdDevRTT) > time.Microsecond {
+				t.Errorf("Expected the metrics to match the result, got stddev %v and duplicates %v", metrics["stddev"], metrics["duplicates"])
+			}
+			if result.Replies[0].TTL != tt.firstTTL {
+				t.Errorf("Expected first reply TTL=%d, got %d", tt.firstTTL, result.Replies[0].TTL)
+			}
+			if result.Replies[0].Seq != tt.firstSeq {
+				t.Errorf("Expected first reply seq=%d, got %d", tt.firstSeq, result.Replies[0].Seq)
+			}
+		})
+	}
+}
+
+func TestParsePingOutputICMPErrors(t *testing.T) {
+	windows := `
+Pinging 10.1.2.3 with 32 bytes of data:
+Reply from 192.168.1.1: Destination host unreachable.
+Reply from 192.168.1.1: Destination host unreachable.
+
+Ping statistics for 10.1.2.3:
+    Packets: Sent = 2, Received = 2, Lost = 0 (0% loss),`
+
+	result := parsePingOutput(windows)
+
+	if result.Success {
+		t.Error("Expected unreachable replies not to count as success")
+	}
+	if len(result.ICMPErrors) != 2 {
+		t.Fatalf("Expected 2 ICMP errors, got %d", len(result.ICMPErrors))
+	}
+	if result.ICMPErrors[0].From != "192.168.1.1" {
+		t.Errorf("Expected error from 192.168.1.1, got %q", result.ICMPErrors[0].From)
+	}
+	if result.ICMPErrors[0].Message != "Destination host unreachable" {
+		t.Errorf("Unexpected error message %q", result.ICMPErrors[0].Message)
+	}
+
+	ttlExceeded := `PING 8.8.8.8 (8.8.8.8) 56(84) bytes of data.
+From 10.0.0.1 icmp_seq=1 Time to live exceeded
+
+--- 8.8.8.8 ping statistics ---
+1 packets transmitted, 0 received, +1 errors, 100% packet loss, time 0ms`
+
+	result = parsePingOutput(ttlExceeded)
+	if len(result.ICMPErrors) != 1 || result.ICMPErrors[0].Seq != 1 {
+		t.Errorf("Expected one ICMP error for seq 1, got %+v", result.ICMPErrors)
+	}
+}
+
+func TestParsePingOutputUnrecognized(t *testing.T) {
+	result := parsePingOutput("something went wrong\nnothing to see here\n")
+
+	if result.Success {
+		t.Error("Expected unrecognized output not to succeed")
+	}
+	if !errors.Is(result.Error, ErrUnrecognizedOutput) {
+		t.Errorf("Expected ErrUnrecognizedOutput, got %v", result.Error)
+	}
+}
+
+func TestParseTracerouteOutputVariants(t *testing.T) {
+	tests := []struct {
+		name      string
+		output    string
+		hops      []string
+		secondRTT int
+		flags     []string
+	}{
+		{
+			name: "numeric with timeouts and multiple responders",
+			output: `traceroute to 8.8.8.8 (8.8.8.8), 30 hops max, 60 byte packets
+ 1  192.168.1.1  0.412 ms  0.376 ms  0.351 ms
+ 2  * * *
+ 3  72.14.204.118  6.215 ms  6.302 ms 108.170.242.241  6.587 ms`,
+			hops:      []string{"192.168.1.1", "", "72.14.204.118"},
+			secondRTT: 0,
+		},
+		{
+			name: "BSD continuation lines",
+			output: ` 1  192.168.0.1  2.123 ms  1.876 ms  1.902 ms
+ 2  10.0.0.1  8.765 ms
+    10.0.0.2  8.234 ms  8.111 ms`,
+			hops:      []string{"192.168.0.1", "10.0.0.1"},
+			secondRTT: 3,
+		},
+		{
+			name: "annotations",
+			output: ` 1  192.168.1.1  0.402 ms  0.371 ms  0.366 ms
+ 2  192.168.1.1  3002.114 ms !H  3002.091 ms !H`,
+			hops:      []string{"192.168.1.1", "192.168.1.1"},
+			secondRTT: 2,
+			flags:     []string{"!H", "!H"},
+		},
+		{
+			name: "Windows tracert",
+			output: `
+Tracing route to dns.google [8.8.8.8]
+over a maximum of 30 hops:
+
+  1    <1 ms    <1 ms    <1 ms  192.168.1.1
+  2     *        *        *     Request timed out.
+  3     6 ms     6 ms     6 ms  dns.google [8.8.8.8]
+
+Trace complete.`,
+			hops:      []string{"192.168.1.1", "", "8.8.8.8"},
+			secondRTT: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := parseTracerouteOutput(tt.output, nil)
+
+			if result.Error != nil {
+				t.Fatalf("Unexpected error: %v", result.Error)
+			}
+			if len(result.Hops) != len(tt.hops) {
+				t.Fatalf("Expected %d hops, got %d", len(tt.hops), len(result.Hops))
+			}
+			for i, addr := range tt.hops {
+				if result.Hops[i].Address != addr {
+					t.Errorf("Hop %d: expected address %q, got %q", i+1, addr, result.Hops[i].Address)
+				}
+			}
+			if len(result.Hops[1].RTT) != tt.secondRTT {
+				t.Errorf("Expected %d RTTs on hop 2, got %d", tt.secondRTT, len(result.Hops[1].RTT))
+			}
+			if len(result.Hops[1].Flags) != len(tt.flags) {
+				t.Errorf("Expected flags %v on hop 2, got %v", tt.flags, result.Hops[1].Flags)
+			}
+		})
+	}
+}
+
+func TestParseTracerouteOutputMatchesAnyResponder(t *testing.T) {
+	output := ` 1  192.168.1.1  0.412 ms  0.376 ms  0.351 ms
+ 2  10.0.0.1  3.1 ms 10.0.0.2  3.2 ms  3.3 ms`
+
+	result := parseTracerouteOutput(output, map[string]string{"backup": "10.0.0.2"})
+
+	if !result.PassesExpected["backup"] {
+		t.Error("Expected device answering a later probe to be found")
+	}
+	if result.Hops[1].Name != "10.0.0.1" {
+		t.Errorf("Expected hop name to be first responder, got %s", result.Hops[1].Name)
+	}
+	if len(result.Hops[1].Addresses) != 2 {
+		t.Errorf("Expected 2 responders on hop 2, got %v", result.Hops[1].Addresses)
+	}
+}
+
+func TestParseTracerouteOutputUnrecognized(t *testing.T) {
+	result := parseTracerouteOutput("traceroute: unknown host example.invalid\n", nil)
+
+	if result.Success {
+		t.Error("Expected unrecognized output not to succeed")
+	}
+	if !errors.Is(result.Error, ErrUnrecognizedOutput) {
+		t.Errorf("Expected ErrUnrecognizedOutput, got %v", result.Error)
+	}
+}
+
+func TestHopMatches(t *testing.T) {
+	hop := Hop{Address: "10.0.0.1", Name: "core1.example.net", Addresses: []string{"10.0.0.1", "10.0.0.5"}, ASN: 64500}
+
+	tests := []struct {
+		want  string
+		match bool
+	}{
+		{"10.0.0.5", true},
+		{"core1.example.net", true},
+		{"*.EXAMPLE.net", true},
+		{"10.0.0.?", true},
+		{"*.example.org", false},
+		{"10.0.0.0/24", true},
+		{"192.168.0.0/16", false},
+		{"AS64500", true},
+		{"as64500", true},
+		{"AS15169", false},
+		{"10.0.0.2", false},
+	}
+	for _, tt := range tests {
+		if got := HopMatches(hop, tt.want); got != tt.match {
+			t.Errorf("HopMatches(%q) = %v, want %v", tt.want, got, tt.match)
+		}
+	}
+
+	if HopMatches(Hop{Addresses: []string{"10.0.0.1"}}, "AS0") {
+		t.Error("Expected a hop without ASN not to match")
+	}
+
+	result := parseTracerouteOutput(" 1  192.168.1.1  0.4 ms\n 2  72.14.204.118  6.2 ms\n", map[string]string{"google": "AS15169", "lan": "192.168.0.0/16"})
+	if result.PassesExpected["google"] || !result.PassesExpected["lan"] {
+		t.Errorf("Unexpected expectations before enrichment: %v", result.PassesExpected)
+	}
+	result.Hops[1].ASN = 15169
+	result.CheckExpected(map[string]string{"google": "AS15169", "lan": "192.168.0.0/16"})
+	if !result.PassesExpected["google"] {
+		t.Error("Expected the ASN to match once the hop is enriched")
+	}
+}
@@ -0,0 +1,149 @@
+package checker
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PathRule constrains the route a traceroute took. Traverse lists hop
+// patterns that must appear in this order, not necessarily adjacent; Avoid
+// lists patterns no hop may match. Both only consider hops FirstHop to
+// LastHop, where 0 leaves that end open. Patterns are those of HopMatches.
+type PathRule struct {
+	Name     string
+	Traverse []string
+	Avoid    []string
+	FirstHop int
+	LastHop  int
+}
+
+type PathRuleResult struct {
+	Rule   PathRule
+	Passed bool
+	// Reason explains which part of the rule held or failed, naming hops.
+	Reason string
+}
+
+// ParseHopRange reads "3", "1-5", "4-" or "-5". An empty string is the
+// whole path.
+func ParseHopRange(s string) (first, last int, err error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, 0, nil
+	}
+	from, to, isRange := strings.Cut(s, "-")
+	if !isRange {
+		to = from
+	}
+	if from = strings.TrimSpace(from); from != "" {
+		if first, err = strconv.Atoi(from); err != nil || first < 1 {
+			return 0, 0, fmt.Errorf("invalid hop range %q", s)
+		}
+	}
+	if to = strings.TrimSpace(to); to != "" {
+		if last, err = strconv.Atoi(to); err != nil || last < 1 {
+			return 0, 0, fmt.Errorf("invalid hop range %q", s)
+		}
+	}
+	if first > 0 && last > 0 && last < first {
+		return 0, 0, fmt.Errorf("invalid hop range %q", s)
+	}
+	return first, last, nil
+}
+
+func (r PathRule) inRange(hop Hop) bool {
+	return (r.FirstHop == 0 || hop.Number >= r.FirstHop) && (r.LastHop == 0 || hop.Number <= r.LastHop)
+}
+
+func (r PathRule) rangeText() string {
+	switch {
+	case r.FirstHop == 0 && r.LastHop == 0:
+		return "in the path"
+	case r.FirstHop == r.LastHop:
+		return fmt.Sprintf("at hop %d", r.FirstHop)
+	case r.LastHop == 0:
+		return fmt.Sprintf("from hop %d on", r.FirstHop)
+	default:
+		first := r.FirstHop
+		if first == 0 {
+			first = 1
+		}
+		return fmt.Sprintf("within hops %d-%d", first, r.LastHop)
+	}
+}
+
+// EvaluatePathRule checks hops against rule. Matching the Traverse patterns
+// greedily, each at the earliest hop after the previous match, finds an
+// ordered match whenever one exists.
+func EvaluatePathRule(rule PathRule, hops []Hop) PathRuleResult {
+	result := PathRuleResult{Rule: rule}
+	var traversed []string
+
+	next := 0
+	for _, pattern := range rule.Traverse {
+		found := -1
+		for i := next; i < len(hops); i++ {
+			if rule.inRange(hops[i]) && HopMatches(hops[i], pattern) {
+				found = i
+				break
+			}
+		}
+		if found < 0 {
+			result.Reason = explainMissing(rule, hops, pattern, next, traversed)
+			return result
+		}
+		traversed = append(traversed, fmt.Sprintf("%s at hop %d", pattern, hops[found].Number))
+		next = found + 1
+	}
+
+	for _, pattern := range rule.Avoid {
+		for _, hop := range hops {
+			if rule.inRange(hop) && HopMatches(hop, pattern) {
+				result.Reason = fmt.Sprintf("must not traverse %s, but hop %d (%s) matches", pattern, hop.Number, hopLabel(hop))
+				return result
+			}
+		}
+	}
+
+	result.Passed = true
+	var reasons []string
+	if len(traversed) > 0 {
+		reasons = append(reasons, "traversed "+strings.Join(traversed, ", then "))
+	}
+	if len(rule.Avoid) > 0 {
+		reasons = append(reasons, fmt.Sprintf("avoided %s %s", strings.Join(rule.Avoid, ", "), rule.rangeText()))
+	}
+	result.Reason = strings.Join(reasons, "; ")
+	return result
+}
+
+// explainMissing says why pattern had no match at or after hops[next]:
+// it only matched before the previous pattern, only outside the hop range,
+// or nowhere.
+func explainMissing(rule PathRule, hops []Hop, pattern string, next int, traversed []string) string {
+	prefix := ""
+	if len(traversed) > 0 {
+		prefix = fmt.Sprintf("traversed %s, but ", strings.Join(traversed, ", then "))
+	}
+
+	for i, hop := range hops {
+		if !HopMatches(hop, pattern) {
+			continue
+		}
+		if i < next && rule.inRange(hop) {
+			return fmt.Sprintf("%s%s only matches hop %d, before %s", prefix, pattern, hop.Number, rule.Traverse[len(traversed)-1])
+		}
+		if !rule.inRange(hop) {
+			return fmt.Sprintf("%s%s matches hop %d, which is not %s", prefix, pattern, hop.Number, rule.rangeText())
+		}
+	}
+	return fmt.Sprintf("%s%s not found %s", prefix, pattern, rule.rangeText())
+}
+
+func hopLabel(hop Hop) string {
+	if hop.Name != "" && hop.Name != hop.Address {
+		return fmt.Sprintf("%s %s", hop.Address, hop.Name)
+	}
+	return hop.Address
+}
@@ -0,0 +1,109 @@
+package checker
+
+import (
+	"testing"
+)
+
+func testPath() []Hop {
+	hop := func(n int, address, name string, asn int) Hop {
+		return Hop{Number: n, Address: address, Name: name, Addresses: []string{address}, ASN: asn}
+	}
+	return []Hop{
+		hop(1, "192.168.1.1", "router.lan", 0),
+		hop(2, "10.0.0.1", "10.0.0.1", 0),
+		{Number: 3},
+		hop(4, "203.0.113.9", "ae1.edge1.isp.example.net", 64500),
+		hop(5, "198.51.100.7", "xe-0.transit.example.org", 64666),
+		hop(6, "8.8.8.8", "dns.google", 15169),
+	}
+}
+
+func TestEvaluatePathRule(t *testing.T) {
+	tests := []struct {
+		name   string
+		rule   PathRule
+		passed bool
+		reason string
+	}{
+		{
+			name:   "ordered traversal",
+			rule:   PathRule{Traverse: []string{"192.168.0.0/16", "*.isp.example.net", "AS15169"}},
+			passed: true,
+			reason: "traversed 192.168.0.0/16 at hop 1, then *.isp.example.net at hop 4, then AS15169 at hop 6",
+		},
+		{
+			name:   "within a hop range",
+			rule:   PathRule{Traverse: []string{"10.0.0.0/8", "*.isp.example.net"}, FirstHop: 1, LastHop: 5},
+			passed: true,
+			reason: "traversed 10.0.0.0/8 at hop 2, then *.isp.example.net at hop 4",
+		},
+		{
+			name:   "wrong order",
+			rule:   PathRule{Traverse: []string{"*.isp.example.net", "10.0.0.1"}},
+			reason: "traversed *.isp.example.net at hop 4, but 10.0.0.1 only matches hop 2, before *.isp.example.net",
+		},
+		{
+			name:   "outside the hop range",
+			rule:   PathRule{Traverse: []string{"10.0.0.1", "dns.google"}, FirstHop: 1, LastHop: 5},
+			reason: "traversed 10.0.0.1 at hop 2, but dns.google matches hop 6, which is not within hops 1-5",
+		},
+		{
+			name:   "missing",
+			rule:   PathRule{Traverse: []string{"*.core.example.net"}},
+			reason: "*.core.example.net not found in the path",
+		},
+		{
+			name:   "must not traverse",
+			rule:   PathRule{Traverse: []string{"AS15169"}, Avoid: []string{"10.255.0.0/16", "AS64666"}},
+			reason: "must not traverse AS64666, but hop 5 (198.51.100.7 xe-0.transit.example.org) matches",
+		},
+		{
+			name:   "avoided",
+			rule:   PathRule{Avoid: []string{"*.transit.example.org"}, LastHop: 4},
+			passed: true,
+			reason: "avoided *.transit.example.org within hops 1-4",
+		},
+		{
+			name:   "unanswered hops never match globs",
+			rule:   PathRule{Avoid: []string{"*"}, FirstHop: 3, LastHop: 3},
+			passed: true,
+			reason: "avoided * at hop 3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := EvaluatePathRule(tt.rule, testPath())
+			if result.Passed != tt.passed {
+				t.Errorf("Expected passed=%v, got %v (%s)", tt.passed, result.Passed, result.Reason)
+			}
+			if result.Reason != tt.reason {
+				t.Errorf("Expected reason %q, got %q", tt.reason, result.Reason)
+			}
+		})
+	}
+}
+
+func TestParseHopRange(t *testing.T) {
+	tests := []struct {
+		input       string
+		first, last int
+	}{
+		{"", 0, 0},
+		{"3", 3, 3},
+		{"1-5", 1, 5},
+		{"4-", 4, 0},
+		{"-5", 0, 5},
+	}
+	for _, tt := range tests {
+		first, last, err := ParseHopRange(tt.input)
+		if err != nil || first != tt.first || last != tt.last {
+			t.Errorf("ParseHopRange(%q) = %d, %d, %v", tt.input, first, last, err)
+		}
+	}
+	for _, bad := range []string{"0", "5-1", "a-b", "1-x"} {
+		if _, _, err := ParseHopRange(bad); err == nil {
+			t.Errorf("Expected %q to be rejected", bad)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package checker
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+)
+
+var (
+	routeDevRe = regexp.MustCompile(`\bdev\s+(\S+)`)
+	routeSrcRe = regexp.MustCompile(`\bsrc\s+(\S+)`)
+)
+
+// routeLookupAddress turns a destination into the address to look up. For
+// a prefix that is the first host address, so "10.0.0.0/8" asks for
+// 10.0.0.1.
+func routeLookupAddress(destination string) (string, error) {
+	if !strings.Contains(destination, "/") {
+		ip := net.ParseIP(destination)
+		if ip == nil {
+			return "", fmt.Errorf("invalid destination %q: expected an IP address or prefix", destination)
+		}
+		return ip.String(), nil
+	}
+
+	_, network, err := net.ParseCIDR(destination)
+	if err != nil {
+		return "", fmt.Errorf("invalid destination %q: %w", destination, err)
+	}
+	ip := network.IP
+	if ip.To4() != nil {
+		ip = ip.To4()
+	}
+	ones, bits := network.Mask.Size()
+	if bits-ones >= 2 {
+		ip = append(net.IP(nil), ip...)
+		ip[len(ip)-1]++
+	}
+	return ip.String(), nil
+}
+
+// parseIPRouteGet parses `ip route get` output such as
+// "10.1.2.3 via 10.8.0.1 dev wg0 src 10.8.0.2 uid 1000".
+func parseIPRouteGet(output string) (RouteLookup, error) {
+	var route RouteLookup
+
+	line := strings.TrimSpace(strings.Split(strings.TrimSpace(output), "\n")[0])
+	if line == "" {
+		return route, fmt.Errorf("empty route lookup: %w", ErrUnrecognizedOutput)
+	}
+	for _, kind := range []string{"unreachable", "prohibit", "blackhole"} {
+		if strings.HasPrefix(line, kind+" ") {
+			return route, fmt.Errorf("destination is %s", kind)
+		}
+	}
+
+	if matches := routeDevRe.FindStringSubmatch(line); matches != nil {
+		route.Interface = matches[1]
+	}
+	if matches := routeViaRe.FindStringSubmatch(line); matches != nil {
+		route.Gateway = matches[1]
+	}
+	if matches := routeSrcRe.FindStringSubmatch(line); matches != nil {
+		route.Source = matches[1]
+	}
+	if route.Interface == "" {
+		return route, fmt.Errorf("no interface in %q: %w", line, ErrUnrecognizedOutput)
+	}
+	return route, nil
+}
+
+// parseBSDRouteGet parses `route -n get` output. Directly connected
+// destinations have no gateway line.
+func parseBSDRouteGet(output string) (RouteLookup, error) {
+	var route RouteLookup
+
+	for _, line := range strings.Split(output, "\n") {
+		key, value, found := strings.Cut(strings.TrimSpace(line), ":")
+		if !found {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch key {
+		case "gateway":
+			route.Gateway = value
+		case "interface":
+			route.Interface = value
+		}
+	}
+	if route.Interface == "" {
+		return route, fmt.Errorf("no interface in route lookup: %w", ErrUnrecognizedOutput)
+	}
+	return route, nil
+}
+
+// MatchRoute compares a route with the expected interface and next hop and
+// returns a description of every mismatch. Either expectation may be empty,
+// and a leading "!" means the route must not use it, e.g. "!utun3" for
+// traffic that has to bypass the tunnel.
+func MatchRoute(route RouteLookup, iface, via string) []string {
+	var mismatches []string
+
+	if iface != "" {
+		negate := strings.HasPrefix(iface, "!")
+		want := strings.TrimPrefix(iface, "!")
+		if (route.Interface == want) == negate {
+			if negate {
+				mismatches = append(mismatches, fmt.Sprintf("uses interface %s", want))
+			} else {
+				mismatches = append(mismatches, fmt.Sprintf("expected interface %s, got %s", want, route.Interface))
+			}
+		}
+	}
+
+	if via != "" {
+		negate := strings.HasPrefix(via, "!")
+		want := strings.TrimPrefix(via, "!")
+		if sameAddress(route.Gateway, want) == negate {
+			got := route.Gateway
+			if got == "" {
+				got = "a direct route"
+			}
+			if negate {
+				mismatches = append(mismatches, fmt.Sprintf("uses next hop %s", want))
+			} else {
+				mismatches = append(mismatches, fmt.Sprintf("expected next hop %s, got %s", want, got))
+			}
+		}
+	}
+
+	return mismatches
+}
+
+func sameAddress(a, b string) bool {
+	a, b = unscopedAddress(a), unscopedAddress(b)
+	ipA, ipB := net.ParseIP(a), net.ParseIP(b)
+	if ipA != nil && ipB != nil {
+		return ipA.Equal(ipB)
+	}
+	return a == b
+}
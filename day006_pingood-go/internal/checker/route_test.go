@@ -0,0 +1,102 @@
+package checker
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLookupRouteFixtures(t *testing.T) {
+	tests := []struct {
+		scenario    string
+		destination string
+		iface       string
+		gateway     string
+		wantErr     bool
+	}{
+		{"linux-ubuntu-22.04", "10.0.0.0/8", "wg0", "10.8.0.1", false},
+		{"linux-ubuntu-22.04", "8.8.8.8", "eth0", "192.168.1.1", false},
+		{"linux-ubuntu-22.04", "fd12:3456::/48", "wg0", "fd00:8::1", false},
+		{"linux-ubuntu-22.04", "2001:4860:4860::8888", "eth0", "fe80::1", false},
+		{"linux-ubuntu-22.04", "198.51.100.1", "", "", true},
+		{"darwin-macos-14", "10.0.0.0/8", "utun3", "", false},
+		{"darwin-macos-14", "8.8.8.8", "en0", "192.168.0.1", false},
+		{"darwin-macos-14", "2001:4860:4860::8888", "en0", "fe80::1%en0", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.scenario+"/"+tt.destination, func(t *testing.T) {
+			nc := loadFixtureChecker(t, tt.scenario)
+
+			route, err := nc.LookupRoute(tt.destination)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error=%v, got %v", tt.wantErr, err)
+			}
+			if route.Destination != tt.destination {
+				t.Errorf("Expected destination %s, got %s", tt.destination, route.Destination)
+			}
+			if route.Interface != tt.iface || route.Gateway != tt.gateway {
+				t.Errorf("Expected dev %s via %s, got dev %s via %s", tt.iface, tt.gateway, route.Interface, route.Gateway)
+			}
+		})
+	}
+}
+
+func TestRouteLookupAddress(t *testing.T) {
+	tests := map[string]string{
+		"10.0.0.0/8":      "10.0.0.1",
+		"192.168.5.7/24":  "192.168.5.1",
+		"10.1.1.1/32":     "10.1.1.1",
+		"10.1.1.0/31":     "10.1.1.0",
+		"fd12:3456::/48":  "fd12:3456::1",
+		"2001:db8::42":    "2001:db8::42",
+		"198.51.100.1":    "198.51.100.1",
+		"::ffff:10.0.0.1": "10.0.0.1",
+	}
+	for destination, want := range tests {
+		got, err := routeLookupAddress(destination)
+		if err != nil {
+			t.Errorf("routeLookupAddress(%q) failed: %v", destination, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("routeLookupAddress(%q) = %s, want %s", destination, got, want)
+		}
+	}
+
+	for _, invalid := range []string{"intranet.example.com", "10.0.0.0/33", ""} {
+		if _, err := routeLookupAddress(invalid); err == nil {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+}
+
+func TestMatchRoute(t *testing.T) {
+	tunnel := RouteLookup{Interface: "wg0", Gateway: "10.8.0.1"}
+	direct := RouteLookup{Interface: "eth0", Gateway: "fe80::1%eth0"}
+
+	tests := []struct {
+		name       string
+		route      RouteLookup
+		iface, via string
+		mismatches []string
+	}{
+		{"interface matches", tunnel, "wg0", "", nil},
+		{"next hop matches", tunnel, "", "10.8.0.1", nil},
+		{"both match", tunnel, "wg0", "10.8.0.1", nil},
+		{"interface differs", direct, "wg0", "", []string{"expected interface wg0, got eth0"}},
+		{"must bypass tunnel", tunnel, "!wg0", "", []string{"uses interface wg0"}},
+		{"bypasses tunnel", direct, "!wg0", "", nil},
+		{"scoped next hop", direct, "", "fe80::1", nil},
+		{"direct route", RouteLookup{Interface: "utun3"}, "", "10.8.0.1", []string{"expected next hop 10.8.0.1, got a direct route"}},
+		{"avoid next hop", tunnel, "", "!10.8.0.1", []string{"uses next hop 10.8.0.1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := MatchRoute(tt.route, tt.iface, tt.via)
+			if strings.Join(got, "|") != strings.Join(tt.mismatches, "|") {
+				t.Errorf("Expected %q, got %q", tt.mismatches, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,385 @@
+package checker
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// CommandRunner executes an external command and returns what it wrote to
+// stdout and stderr. A non-nil error means the command could not be started
+// or exited with a non-zero status.
+type CommandRunner interface {
+	Run(name string, args ...string) (stdout string, stderr string, err error)
+}
+
+// ContextRunner is a CommandRunner that can stop a command when ctx is
+// done, such as a traceroute that would otherwise run to its last hop.
+type ContextRunner interface {
+	CommandRunner
+	RunContext(ctx context.Context, name string, args ...string) (stdout string, stderr string, err error)
+}
+
+// runContext runs a command through runner, cancelled with ctx if runner
+// supports it. Either way nothing new starts once ctx is done.
+func runContext(ctx context.Context, runner CommandRunner, name string, args ...string) (string, string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", "", err
+	}
+	if r, ok := runner.(ContextRunner); ok {
+		return r.RunContext(ctx, name, args...)
+	}
+	return runner.Run(name, args...)
+}
+
+type ExecRunner struct{}
+
+func (r ExecRunner) Run(name string, args ...string) (string, string, error) {
+	return r.RunContext(context.Background(), name, args...)
+}
+
+func (ExecRunner) RunContext(ctx context.Context, name string, args ...string) (string, string, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	var out bytes.Buffer
+	var stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	return out.String(), stderr.String(), err
+}
+
+// ExitError is returned by ReplayRunner for fixtures recorded with a
+// non-zero exit status. Its message matches the one produced by os/exec.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+type Fixture struct {
+	Command  string
+	ExitCode int
+	Error    string
+	Stdout   string
+	Stderr   string
+}
+
+func (f Fixture) result() (string, string, error) {
+	switch {
+	case f.Error != "":
+		return f.Stdout, f.Stderr, errors.New(f.Error)
+	case f.ExitCode != 0:
+		return f.Stdout, f.Stderr, &ExitError{Code: f.ExitCode}
+	default:
+		return f.Stdout, f.Stderr, nil
+	}
+}
+
+// CommandLine renders a command the way it is stored in fixture files.
+func CommandLine(name string, args ...string) string {
+	parts := make([]string, 0, len(args)+1)
+	for _, arg := range append([]string{name}, args...) {
+		if arg == "" || strings.ContainsAny(arg, " \t\"'") {
+			arg = strconv.Quote(arg)
+		}
+		parts = append(parts, arg)
+	}
+	return strings.Join(parts, " ")
+}
+
+func newFixture(command, stdout, stderr string, err error) Fixture {
+	fixture := Fixture{
+		Command: command,
+		Stdout:  stdout,
+		Stderr:  stderr,
+	}
+	if err != nil {
+		var exitErr *exec.ExitError
+		var replayErr *ExitError
+		switch {
+		case errors.As(err, &exitErr):
+			fixture.ExitCode = exitErr.ExitCode()
+		case errors.As(err, &replayErr):
+			fixture.ExitCode = replayErr.Code
+		default:
+			fixture.Error = err.Error()
+		}
+	}
+	return fixture
+}
+
+// CapturingRunner passes commands through to another runner and keeps
+// every invocation in memory, in the order they ran, so reports can show
+// the raw output behind each check.
+type CapturingRunner struct {
+	Runner CommandRunner
+
+	mu       sync.Mutex
+	captured []Fixture
+}
+
+func NewCapturingRunner(runner CommandRunner) *CapturingRunner {
+	return &CapturingRunner{Runner: runner}
+}
+
+func (r *CapturingRunner) Run(name string, args ...string) (string, string, error) {
+	return r.RunContext(context.Background(), name, args...)
+}
+
+func (r *CapturingRunner) RunContext(ctx context.Context, name string, args ...string) (string, string, error) {
+	stdout, stderr, err := runContext(ctx, r.Runner, name, args...)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.captured = append(r.captured, newFixture(CommandLine(name, args...), stdout, stderr, err))
+
+	return stdout, stderr, err
+}
+
+func (r *CapturingRunner) Captured() []Fixture {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Fixture(nil), r.captured...)
+}
+
+// RecordingRunner passes commands through to another runner and saves every
+// invocation as a fixture file in Dir.
+type RecordingRunner struct {
+	Runner CommandRunner
+	Dir    string
+
+	mu sync.Mutex
+}
+
+func NewRecordingRunner(runner CommandRunner, dir string) *RecordingRunner {
+	return &RecordingRunner{Runner: runner, Dir: dir}
+}
+
+func (r *RecordingRunner) Run(name string, args ...string) (string, string, error) {
+	return r.RunContext(context.Background(), name, args...)
+}
+
+func (r *RecordingRunner) RunContext(ctx context.Context, name string, args ...string) (string, string, error) {
+	stdout, stderr, err := runContext(ctx, r.Runner, name, args...)
+	fixture := newFixture(CommandLine(name, args...), stdout, stderr, err)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if saveErr := SaveFixture(r.Dir, fixture); saveErr != nil {
+		return stdout, stderr, fmt.Errorf("failed to record fixture: %w", saveErr)
+	}
+
+	return stdout, stderr, err
+}
+
+// ReplayRunner serves previously recorded fixtures instead of running
+// commands. Commands without a fixture fail.
+type ReplayRunner struct {
+	fixtures map[string]Fixture
+}
+
+func NewReplayRunner(fixtures ...Fixture) *ReplayRunner {
+	r := &ReplayRunner{fixtures: make(map[string]Fixture)}
+	for _, f := range fixtures {
+		r.fixtures[f.Command] = f
+	}
+	return r
+}
+
+func LoadReplayRunner(dir string) (*ReplayRunner, error) {
+	fixtures, err := LoadFixtures(dir)
+	if err != nil {
+		return nil, err
+	}
+	return NewReplayRunner(fixtures...), nil
+}
+
+func (r *ReplayRunner) Run(name string, args ...string) (string, string, error) {
+	key := CommandLine(name, args...)
+	fixture, ok := r.fixtures[key]
+	if !ok {
+		return "", "", fmt.Errorf("no fixture recorded for %q", key)
+	}
+	return fixture.result()
+}
+
+var fixtureNameUnsafe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// fixtureFileName keeps the command readable in the name and adds a hash
+// of it, so that commands which sanitize or truncate to the same text do
+// not overwrite each other.
+func fixtureFileName(command string) string {
+	name := strings.Trim(fixtureNameUnsafe.ReplaceAllString(command, "_"), "_")
+	if len(name) > 100 {
+		name = name[:100]
+	}
+	sum := sha256.Sum256([]byte(command))
+	return fmt.Sprintf("%s-%x.txt", name, sum[:4])
+}
+
+// Fixture files look like this:
+//
+//	$ ping -c 3 -i 0.5 8.8.8.8
+//	exit: 0
+//	-- stdout (215 bytes) --
+//	...
+//	-- stderr (0 bytes) --
+//
+// An "error:" line replaces "exit:" when the command could not be started.
+// Each output is followed by a newline unless it already ends with one, so
+// the markers stay on their own lines. Markers without a length, as in
+// hand-written fixtures, are read up to the next marker line instead.
+var fixtureMarkerRe = regexp.MustCompile(`^-- (stdout|stderr)(?: \((\d+) bytes\))? --$`)
+
+func SaveFixture(dir string, f Fixture) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, f.FileName()), f.Bytes(), 0644)
+}
+
+// FileName is the name SaveFixture stores f under.
+func (f Fixture) FileName() string {
+	return fixtureFileName(f.Command)
+}
+
+// Bytes renders f in the fixture file format.
+func (f Fixture) Bytes() []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "$ %s\n", f.Command)
+	if f.Error != "" {
+		fmt.Fprintf(&buf, "error: %s\n", f.Error)
+	} else {
+		fmt.Fprintf(&buf, "exit: %d\n", f.ExitCode)
+	}
+	for _, section := range []struct{ name, output string }{{"stdout", f.Stdout}, {"stderr", f.Stderr}} {
+		fmt.Fprintf(&buf, "-- %s (%d bytes) --\n", section.name, len(section.output))
+		buf.WriteString(section.output)
+		if section.output != "" && !strings.HasSuffix(section.output, "\n") {
+			buf.WriteString("\n")
+		}
+	}
+	return buf.Bytes()
+}
+
+func ParseFixture(data string) (Fixture, error) {
+	var f Fixture
+
+	reader := bufio.NewReader(strings.NewReader(data))
+	header, _ := reader.ReadString('\n')
+	if !strings.HasPrefix(header, "$ ") {
+		return f, fmt.Errorf("fixture does not start with a command line")
+	}
+	f.Command = strings.TrimSpace(strings.TrimPrefix(header, "$ "))
+
+	status, _ := reader.ReadString('\n')
+	status = strings.TrimSpace(status)
+	switch {
+	case strings.HasPrefix(status, "exit:"):
+		code, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(status, "exit:")))
+		if err != nil {
+			return f, fmt.Errorf("invalid exit status %q: %w", status, err)
+		}
+		f.ExitCode = code
+	case strings.HasPrefix(status, "error:"):
+		f.Error = strings.TrimSpace(strings.TrimPrefix(status, "error:"))
+	default:
+		return f, fmt.Errorf("missing exit status for %q", f.Command)
+	}
+
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return f, err
+	}
+	rest := string(body)
+	for _, section := range []struct {
+		name   string
+		output *string
+	}{{"stdout", &f.Stdout}, {"stderr", &f.Stderr}} {
+		line, after, _ := strings.Cut(rest, "\n")
+		marker := fixtureMarkerRe.FindStringSubmatch(line)
+		if marker == nil || marker[1] != section.name {
+			return f, fmt.Errorf("missing %s section for %q", section.name, f.Command)
+		}
+		if marker[2] == "" {
+			*section.output, rest = splitUnframedOutput(after)
+			continue
+		}
+		size, err := strconv.Atoi(marker[2])
+		if err != nil || size > len(after) {
+			return f, fmt.Errorf("%s of %q is shorter than its %s bytes", section.name, f.Command, marker[2])
+		}
+		*section.output, rest = after[:size], after[size:]
+		if size > 0 && !strings.HasSuffix(*section.output, "\n") {
+			if !strings.HasPrefix(rest, "\n") {
+				return f, fmt.Errorf("%s of %q is longer than its %d bytes", section.name, f.Command, size)
+			}
+			rest = rest[1:]
+		}
+	}
+	if rest != "" {
+		return f, fmt.Errorf("unexpected data after the stderr of %q", f.Command)
+	}
+
+	return f, nil
+}
+
+// splitUnframedOutput returns the output up to the next marker line and
+// what follows it. The stderr marker is the only one after stdout, so a
+// hand-written stdout must not contain a line that looks like it.
+func splitUnframedOutput(data string) (string, string) {
+	for i := 0; i < len(data); {
+		end := strings.IndexByte(data[i:], '\n')
+		line := data[i:]
+		if end >= 0 {
+			line = data[i : i+end]
+		}
+		if fixtureMarkerRe.MatchString(line) {
+			return data[:i], data[i:]
+		}
+		if end < 0 {
+			break
+		}
+		i += end + 1
+	}
+	return data, ""
+}
+
+func LoadFixtures(dir string) ([]Fixture, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.txt"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no fixtures found in %s", dir)
+	}
+
+	var fixtures []Fixture
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read fixture: %w", err)
+		}
+		fixture, err := ParseFixture(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse fixture %s: %w", filepath.Base(path), err)
+		}
+		fixtures = append(fixtures, fixture)
+	}
+
+	return fixtures, nil
+}
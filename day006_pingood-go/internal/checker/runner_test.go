@@ -0,0 +1,152 @@
+package checker
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type stubRunner struct {
+	stdout string
+	stderr string
+	err    error
+}
+
+func (s stubRunner) Run(name string, args ...string) (string, string, error) {
+	return s.stdout, s.stderr, s.err
+}
+
+func TestRecordThenReplay(t *testing.T) {
+	dir := t.TempDir()
+
+	recorder := NewRecordingRunner(stubRunner{
+		stdout: "default via 192.168.1.1 proto dhcp\n",
+	}, dir)
+	if _, _, err := recorder.Run("ip", "route", "show", "default", "dev", "eth0"); err != nil {
+		t.Fatalf("Recording failed: %v", err)
+	}
+
+	recorder.Runner = stubRunner{stderr: "ping: unknown host\n", err: &ExitError{Code: 2}}
+	recorder.Run("ping", "-c", "3", "no such host")
+
+	recorder.Runner = stubRunner{err: errors.New(`exec: "dig": executable file not found in $PATH`)}
+	recorder.Run("dig", "+short", "example.com", "A")
+
+	replay, err := LoadReplayRunner(dir)
+	if err != nil {
+		t.Fatalf("LoadReplayRunner failed: %v", err)
+	}
+
+	stdout, _, err := replay.Run("ip", "route", "show", "default", "dev", "eth0")
+	if err != nil {
+		t.Fatalf("Expected replay to succeed, got %v", err)
+	}
+	if stdout != "default via 192.168.1.1 proto dhcp\n" {
+		t.Errorf("Unexpected replayed stdout: %q", stdout)
+	}
+
+	_, stderr, err := replay.Run("ping", "-c", "3", "no such host")
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 2 {
+		t.Errorf("Expected exit status 2, got %v", err)
+	}
+	if stderr != "ping: unknown host\n" {
+		t.Errorf("Unexpected replayed stderr: %q", stderr)
+	}
+
+	_, _, err = replay.Run("dig", "+short", "example.com", "A")
+	if err == nil || err.Error() != `exec: "dig": executable file not found in $PATH` {
+		t.Errorf("Expected start error to be replayed, got %v", err)
+	}
+
+	if _, _, err := replay.Run("traceroute", "-n", "8.8.8.8"); err == nil {
+		t.Error("Expected error for command without fixture")
+	}
+}
+
+func TestFixtureRoundTrip(t *testing.T) {
+	long := strings.Repeat("x", 150)
+	fixtures := []Fixture{
+		{Command: "printf no-newline", Stdout: "no trailing newline", Stderr: "warning without newline"},
+		{Command: "cat marker.txt", Stdout: "before\n-- stderr --\nafter\n-- stdout (3 bytes) --\n", Stderr: "-- stderr --\n"},
+		{Command: "echo " + long + " a", Stdout: "a\n"},
+		{Command: "echo " + long + " b", Stdout: "b\n"},
+		{Command: "echo a/b", Stdout: "slash\n"},
+		{Command: "echo a_b", Stdout: "underscore\n"},
+		{Command: "false", ExitCode: 1},
+		{Command: "missing", Error: "executable file not found", Stdout: "\n\n"},
+	}
+
+	dir := t.TempDir()
+	for _, f := range fixtures {
+		if err := SaveFixture(dir, f); err != nil {
+			t.Fatalf("SaveFixture failed: %v", err)
+		}
+	}
+	loaded, err := LoadFixtures(dir)
+	if err != nil {
+		t.Fatalf("LoadFixtures failed: %v", err)
+	}
+	if len(loaded) != len(fixtures) {
+		t.Fatalf("Expected %d fixture files, got %d", len(fixtures), len(loaded))
+	}
+	byCommand := make(map[string]Fixture)
+	for _, f := range loaded {
+		byCommand[f.Command] = f
+	}
+	for _, want := range fixtures {
+		if got := byCommand[want.Command]; got != want {
+			t.Errorf("Expected %+v to round-trip, got %+v", want, got)
+		}
+	}
+}
+
+func TestParseFixtureWithoutLengths(t *testing.T) {
+	f, err := ParseFixture("$ ip route show\nexit: 0\n-- stdout --\ndefault via 192.168.1.1\n-- stderr --\nwarning\n")
+	if err != nil {
+		t.Fatalf("ParseFixture failed: %v", err)
+	}
+	if f.Stdout != "default via 192.168.1.1\n" || f.Stderr != "warning\n" {
+		t.Errorf("Unexpected fixture: %+v", f)
+	}
+
+	for _, data := range []string{
+		"$ true\nexit: 0\n-- stdout (10 bytes) --\nshort\n",
+		"$ true\nexit: 0\n-- stdout (2 bytes) --\nlonger\n-- stderr (0 bytes) --\n",
+		"$ true\nexit: 0\n-- stderr (0 bytes) --\n",
+	} {
+		if _, err := ParseFixture(data); err == nil {
+			t.Errorf("Expected error for %q", data)
+		}
+	}
+}
+
+func TestExecuteCommandUsesRunner(t *testing.T) {
+	base := &BaseChecker{runner: stubRunner{stderr: "boom", err: &ExitError{Code: 1}}}
+
+	_, err := base.executeCommand("ping", "8.8.8.8")
+	if err == nil {
+		t.Fatal("Expected error from failing command")
+	}
+	if err.Error() != "command failed: ping: exit status 1, stderr: boom" {
+		t.Errorf("Unexpected error message: %v", err)
+	}
+}
+
+func TestCapturingRunner(t *testing.T) {
+	capture := NewCapturingRunner(stubRunner{stdout: "PING 8.8.8.8\n"})
+	capture.Run("ping", "-c", "1", "8.8.8.8")
+	capture.Runner = stubRunner{stderr: "connection timed out\n", err: &ExitError{Code: 9}}
+	capture.Run("dig", "+short", "example.com", "A")
+
+	captured := capture.Captured()
+	if len(captured) != 2 {
+		t.Fatalf("Expected 2 captured commands, got %d", len(captured))
+	}
+	if captured[0].Command != "ping -c 1 8.8.8.8" || captured[0].Stdout != "PING 8.8.8.8\n" || captured[0].ExitCode != 0 {
+		t.Errorf("Unexpected first capture: %+v", captured[0])
+	}
+	if captured[1].ExitCode != 9 || captured[1].Stderr != "connection timed out\n" {
+		t.Errorf("Unexpected second capture: %+v", captured[1])
+	}
+}
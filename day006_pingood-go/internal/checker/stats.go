@@ -0,0 +1,107 @@
+package checker
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// computeStats fills r.Stats from r.Replies, and StdDevRTT and Duplicates
+// where the summary line did not give them.
+func (r *PingResult) computeStats() {
+	var stats PingStats
+	var duplicates int
+
+	seen := make(map[int]bool)
+	maxSeq := -1
+	for _, reply := range r.Replies {
+		if reply.Seq >= 0 {
+			if reply.Duplicate || seen[reply.Seq] {
+				duplicates++
+				continue
+			}
+			seen[reply.Seq] = true
+			if reply.Seq < maxSeq {
+				stats.OutOfOrder++
+			} else {
+				maxSeq = reply.Seq
+			}
+		} else if reply.Duplicate {
+			duplicates++
+			continue
+		}
+		stats.Samples = append(stats.Samples, reply.RTT)
+	}
+	if duplicates > r.Duplicates {
+		r.Duplicates = duplicates
+	}
+	r.Stats = stats
+
+	if len(stats.Samples) == 0 {
+		return
+	}
+
+	var sum float64
+	for _, sample := range stats.Samples {
+		sum += float64(sample)
+	}
+	mean := sum / float64(len(stats.Samples))
+
+	var variance float64
+	for _, sample := range stats.Samples {
+		variance += (float64(sample) - mean) * (float64(sample) - mean)
+	}
+	if r.StdDevRTT == 0 {
+		r.StdDevRTT = time.Duration(math.Sqrt(variance / float64(len(stats.Samples))))
+	}
+
+	// RFC 3550 section 6.4.1 interarrival jitter, using the difference between
+	// consecutive round-trip times as the transit time difference.
+	var jitter float64
+	for i := 1; i < len(stats.Samples); i++ {
+		d := math.Abs(float64(stats.Samples[i] - stats.Samples[i-1]))
+		jitter += (d - jitter) / 16
+	}
+	stats.Jitter = time.Duration(jitter)
+
+	sorted := append([]time.Duration(nil), stats.Samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	stats.P50 = percentile(sorted, 50)
+	stats.P95 = percentile(sorted, 95)
+	stats.P99 = percentile(sorted, 99)
+	r.Stats = stats
+}
+
+// percentile uses the nearest-rank method on already sorted samples.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// Metrics exposes the result as named values for threshold assertions.
+// Times are in milliseconds and loss is in percent.
+func (r PingResult) Metrics() map[string]float64 {
+	return map[string]float64{
+		"loss":         r.PacketLoss,
+		"min":          durationMillis(r.MinRTT),
+		"avg":          durationMillis(r.AvgRTT),
+		"max":          durationMillis(r.MaxRTT),
+		"stddev":       durationMillis(r.StdDevRTT),
+		"jitter":       durationMillis(r.Stats.Jitter),
+		"p50":          durationMillis(r.Stats.P50),
+		"p95":          durationMillis(r.Stats.P95),
+		"p99":          durationMillis(r.Stats.P99),
+		"transmitted":  float64(r.Transmitted),
+		"received":     float64(r.Received),
+		"duplicates":   float64(r.Duplicates),
+		"out_of_order": float64(r.Stats.OutOfOrder),
+		"icmp_errors":  float64(len(r.ICMPErrors)),
+	}
+}
+
+func durationMillis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,73 @@
+package checker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestComputePingStats(t *testing.T) {
+	ms := func(v float64) time.Duration { return time.Duration(v * float64(time.Millisecond)) }
+
+	replies := []PingReply{
+		{Seq: 1, RTT: ms(10)},
+		{Seq: 3, RTT: ms(30)},
+		{Seq: 2, RTT: ms(20)},
+		{Seq: 2, RTT: ms(21), Duplicate: true},
+		{Seq: 4, RTT: ms(40)},
+		{Seq: 4, RTT: ms(41)},
+	}
+
+	result := PingResult{Replies: replies}
+	result.computeStats()
+	stats := result.Stats
+
+	if len(stats.Samples) != 4 {
+		t.Fatalf("Expected 4 samples, got %d", len(stats.Samples))
+	}
+	if result.Duplicates != 2 {
+		t.Errorf("Expected 2 duplicates, got %d", result.Duplicates)
+	}
+	if stats.OutOfOrder != 1 {
+		t.Errorf("Expected 1 out-of-order reply, got %d", stats.OutOfOrder)
+	}
+	if stats.P50 != ms(20) || stats.P95 != ms(40) || stats.P99 != ms(40) {
+		t.Errorf("Unexpected percentiles p50=%v p95=%v p99=%v", stats.P50, stats.P95, stats.P99)
+	}
+
+	// Samples 10, 30, 20, 40: mean 25, population stddev sqrt(125).
+	if abs(result.StdDevRTT-ms(11.180)) > time.Microsecond {
+		t.Errorf("Expected StdDevRTT≈11.180ms, got %v", result.StdDevRTT)
+	}
+
+	// |D| = 20, 10, 20 fed through J += (|D| - J) / 16.
+	j := 20.0 / 16
+	j += (10 - j) / 16
+	j += (20 - j) / 16
+	if abs(stats.Jitter-ms(j)) > time.Microsecond {
+		t.Errorf("Expected Jitter≈%.3fms, got %v", j, stats.Jitter)
+	}
+}
+
+func TestComputePingStatsWithoutSequenceNumbers(t *testing.T) {
+	replies := []PingReply{
+		{Seq: -1, RTT: 5 * time.Millisecond},
+		{Seq: -1, RTT: 7 * time.Millisecond},
+	}
+
+	result := PingResult{Replies: replies}
+	result.computeStats()
+
+	if len(result.Stats.Samples) != 2 || result.Stats.OutOfOrder != 0 || result.Duplicates != 0 {
+		t.Errorf("Unexpected stats for replies without seq: %+v", result)
+	}
+}
+
+func TestComputePingStatsEmpty(t *testing.T) {
+	var result PingResult
+	result.computeStats()
+	stats := result.Stats
+
+	if result.StdDevRTT != 0 || stats.P95 != 0 || stats.Jitter != 0 || len(stats.Samples) != 0 {
+		t.Errorf("Expected zero stats, got %+v", stats)
+	}
+}
@@ -1,6 +1,7 @@
 package checker
 
 import (
+	"net/http"
 	"time"
 )
 
@@ -10,7 +11,11 @@ type NetChecker interface {
 	PingTest(targets []string, count int, interval float64, ipv6 bool) ([]PingResult, error)
 	Traceroute(target string, count int, interval float64, expected map[string]string) (TracerouteResult, error)
 	CheckDNS(domains []string, recordType string) ([]DNSResult, error)
-	CheckHTTP(url string, ipv6 bool) (HTTPResult, error)
+	CheckHTTP(check HTTPCheck) (HTTPResult, error)
+	CheckGateway(iface string, count int, interval float64) (GatewayResult, error)
+	CheckDHCP(iface string) (DHCPLease, error)
+	LookupRoute(destination string) (RouteLookup, error)
+	CheckWireless(iface string) (WirelessInfo, error)
 }
 
 type PingResult struct {
@@ -20,9 +25,47 @@ type PingResult struct {
 	MinRTT      time.Duration
 	MaxRTT      time.Duration
 	AvgRTT      time.Duration
+	StdDevRTT   time.Duration
+	Transmitted int
+	Received    int
+	Duplicates  int
+	Replies     []PingReply
+	ICMPErrors  []PingError
+	Stats       PingStats
 	Error       error
 }
 
+// PingStats is computed from the individual replies rather than taken from
+// the summary line, so it is available for every ping implementation.
+// The standard deviation and duplicate count live on PingResult, which
+// prefers the ping summary line's figures when it prints them.
+type PingStats struct {
+	Samples    []time.Duration
+	Jitter     time.Duration
+	P50        time.Duration
+	P95        time.Duration
+	P99        time.Duration
+	OutOfOrder int
+}
+
+// PingReply is a single echo reply line. Seq and TTL are -1 when the ping
+// implementation does not print them.
+type PingReply struct {
+	From      string
+	Seq       int
+	TTL       int
+	RTT       time.Duration
+	Duplicate bool
+}
+
+// PingError is an ICMP error reported instead of an echo reply, such as
+// "Destination Host Unreachable".
+type PingError struct {
+	From    string
+	Seq     int
+	Message string
+}
+
 type TracerouteResult struct {
 	Target          string
 	Success         bool
@@ -32,10 +75,16 @@ type TracerouteResult struct {
 }
 
 type Hop struct {
-	Number  int
-	Address string
-	RTT     []time.Duration
-	Name    string
+	Number    int
+	Address   string
+	RTT       []time.Duration
+	Name      string
+	Addresses []string
+	Flags     []string
+	// ASN, Org and Scope are only set once the hop has been enriched.
+	ASN   int
+	Org   string
+	Scope string
 }
 
 type DNSResult struct {
@@ -50,6 +99,114 @@ type HTTPResult struct {
 	URL        string
 	StatusCode int
 	Success    bool
+	Started    time.Time
 	Duration   time.Duration
 	Error      error
-}
\ No newline at end of file
+
+	// RemoteAddr is the address the final response came from.
+	RemoteAddr string
+	Redirects  []Redirect
+	Proto      string
+	Headers    http.Header
+	BodySize   int
+	Checks     []HTTPCheckResult
+	// Trace is the timeline of the request, redirects included.
+	Trace []HTTPTraceEvent
+}
+
+// HTTPTraceEvent is one step of an HTTP request, such as "dns_done" or
+// "first_byte", At after the request started. Error is set when the step
+// failed.
+type HTTPTraceEvent struct {
+	At     time.Duration
+	Event  string
+	Detail string
+	Error  string
+}
+
+// GatewayResult describes the health of the first hop: whether the default
+// gateways answer, what the neighbor cache says about them and which routers
+// advertise themselves on the link.
+type GatewayResult struct {
+	Interface            string
+	IPv4Gateway          string
+	IPv6Gateways         []string
+	Ping                 []PingResult
+	Neighbors            []NeighborEntry
+	ARPReplies           map[string][]string
+	RouterAdvertisements []RouterAdvertisement
+	Warnings             []string
+}
+
+// NeighborEntry is an ARP or NDP cache entry. State uses the Linux names
+// (REACHABLE, STALE, FAILED, ...) on every platform.
+type NeighborEntry struct {
+	IP     string
+	MAC    string
+	State  string
+	Router bool
+}
+
+// RouterAdvertisement is what is known about one IPv6 router, either from a
+// solicited RA or from what the kernel learned. Source says which.
+type RouterAdvertisement struct {
+	Router     string
+	MAC        string
+	Lifetime   time.Duration
+	Preference string
+	MTU        int
+	Prefixes   []RAPrefix
+	Source     string
+}
+
+type RAPrefix struct {
+	Prefix            string
+	OnLink            bool
+	Autonomous        bool
+	ValidLifetime     time.Duration
+	PreferredLifetime time.Duration
+}
+
+// DHCPLease is the DHCPv4 lease currently held by an interface, as reported
+// by whichever DHCP client manages it. Source names that client.
+type DHCPLease struct {
+	Source     string
+	Address    string
+	SubnetMask string
+	Server     string
+	Routers    []string
+	DNS        []string
+	Domain     string
+	LeaseTime  time.Duration
+	Expiry     time.Time
+}
+
+// RouteLookup is the route the kernel would pick for Address. Destination is
+// what was asked for, which may be a prefix.
+type RouteLookup struct {
+	Destination string
+	Address     string
+	Interface   string
+	Gateway     string
+	Source      string
+}
+
+// WirelessInfo describes the Wi-Fi association of an interface. Values that
+// the source could not provide are zero; Source names where they came from.
+type WirelessInfo struct {
+	Interface   string
+	Source      string
+	Connected   bool
+	SSID        string
+	BSSID       string
+	Frequency   int
+	Channel     int
+	Signal      int
+	Noise       int
+	LinkQuality int
+	TxBitrate   float64
+	RxBitrate   float64
+	TxPackets   int
+	TxRetries   int
+	TxFailed    int
+}
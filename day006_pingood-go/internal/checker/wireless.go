@@ -0,0 +1,200 @@
+package checker
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ErrNotWireless is returned by CheckWireless for interfaces without a
+// radio, such as Ethernet ports.
+var ErrNotWireless = errors.New("not a wireless interface")
+
+var (
+	iwConnectedRe = regexp.MustCompile(`^Connected to ([0-9a-fA-F:]{17})`)
+	iwBitrateRe   = regexp.MustCompile(`^([\d.]+) MBit/s`)
+	iwSignalRe    = regexp.MustCompile(`^(-?\d+)`)
+)
+
+// Band names the Wi-Fi band of Frequency.
+func (w WirelessInfo) Band() string {
+	switch {
+	case w.Frequency >= 2400 && w.Frequency < 2500:
+		return "2.4GHz"
+	case w.Frequency >= 5150 && w.Frequency < 5925:
+		return "5GHz"
+	case w.Frequency >= 5925 && w.Frequency < 7125:
+		return "6GHz"
+	default:
+		return ""
+	}
+}
+
+// RetryRate is the share of transmitted frames that had to be retried, in
+// percent.
+func (w WirelessInfo) RetryRate() float64 {
+	if w.TxPackets == 0 {
+		return 0
+	}
+	return float64(w.TxRetries) / float64(w.TxPackets) * 100
+}
+
+// Metrics exposes the link values under the names wireless assertions use.
+// Only values the source provided are included.
+func (w WirelessInfo) Metrics() map[string]float64 {
+	metrics := make(map[string]float64)
+	if w.Signal != 0 {
+		metrics["signal"] = float64(w.Signal)
+	}
+	if w.Noise != 0 {
+		metrics["noise"] = float64(w.Noise)
+		if w.Signal != 0 {
+			metrics["snr"] = float64(w.Signal - w.Noise)
+		}
+	}
+	if w.LinkQuality != 0 {
+		metrics["link_quality"] = float64(w.LinkQuality)
+	}
+	if w.TxBitrate != 0 {
+		metrics["tx_bitrate"] = w.TxBitrate
+	}
+	if w.RxBitrate != 0 {
+		metrics["rx_bitrate"] = w.RxBitrate
+	}
+	if w.TxPackets != 0 {
+		metrics["retry_rate"] = w.RetryRate()
+		metrics["tx_failed"] = float64(w.TxFailed)
+	}
+	return metrics
+}
+
+// frequencyToChannel maps a centre frequency in MHz to its IEEE channel
+// number.
+func frequencyToChannel(freq int) int {
+	switch {
+	case freq == 2484:
+		return 14
+	case freq >= 2412 && freq < 2484:
+		return (freq - 2407) / 5
+	case freq >= 5925 && freq < 7125:
+		return (freq - 5950) / 5
+	case freq >= 5000 && freq < 5925:
+		return (freq - 5000) / 5
+	default:
+		return 0
+	}
+}
+
+// parseProcWireless reads the line for iface from /proc/net/wireless. The
+// file only lists wireless interfaces, so a missing line means iface has no
+// radio. Values are followed by a "." when they were updated since the last
+// read; a noise level of -256 means the driver does not report one.
+func parseProcWireless(output, iface string) (WirelessInfo, bool) {
+	for _, line := range strings.Split(output, "\n") {
+		name, rest, found := strings.Cut(strings.TrimSpace(line), ":")
+		if !found || name != iface {
+			continue
+		}
+
+		info := WirelessInfo{Interface: iface, Source: "/proc/net/wireless"}
+		fields := strings.Fields(rest)
+		value := func(i int) int {
+			if i >= len(fields) {
+				return 0
+			}
+			n, _ := strconv.ParseFloat(strings.TrimSuffix(fields[i], "."), 64)
+			return int(n)
+		}
+		info.LinkQuality = value(1)
+		// Some drivers report the level as an unsigned byte.
+		if info.Signal = value(2); info.Signal > 63 {
+			info.Signal -= 256
+		}
+		if noise := value(3); noise > -256 && noise != 0 {
+			info.Noise = noise
+		}
+		info.Connected = info.Signal != 0
+		return info, true
+	}
+	return WirelessInfo{}, false
+}
+
+// parseIwLink reads `iw dev <iface> link`.
+func parseIwLink(output string, info *WirelessInfo) {
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if matches := iwConnectedRe.FindStringSubmatch(line); matches != nil {
+			info.Connected = true
+			info.BSSID = strings.ToLower(matches[1])
+			continue
+		}
+
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch key {
+		case "SSID":
+			info.SSID = value
+		case "freq":
+			if freq, err := strconv.ParseFloat(value, 64); err == nil {
+				info.Frequency = int(freq)
+				info.Channel = frequencyToChannel(info.Frequency)
+			}
+		default:
+			parseIwValue(key, value, info)
+		}
+	}
+}
+
+// parseIwStation reads `iw dev <iface> station get <bssid>`, which adds the
+// retry counters to what `iw link` shows.
+func parseIwStation(output string, info *WirelessInfo) {
+	for _, line := range strings.Split(output, "\n") {
+		key, value, found := strings.Cut(strings.TrimSpace(line), ":")
+		if !found {
+			continue
+		}
+		parseIwValue(key, strings.TrimSpace(value), info)
+	}
+}
+
+func parseIwValue(key, value string, info *WirelessInfo) {
+	switch key {
+	case "signal":
+		if matches := iwSignalRe.FindStringSubmatch(value); matches != nil {
+			info.Signal, _ = strconv.Atoi(matches[1])
+		}
+	case "tx bitrate":
+		if matches := iwBitrateRe.FindStringSubmatch(value); matches != nil {
+			info.TxBitrate, _ = strconv.ParseFloat(matches[1], 64)
+		}
+	case "rx bitrate":
+		if matches := iwBitrateRe.FindStringSubmatch(value); matches != nil {
+			info.RxBitrate, _ = strconv.ParseFloat(matches[1], 64)
+		}
+	case "tx packets":
+		info.TxPackets, _ = strconv.Atoi(value)
+	case "tx retries":
+		info.TxRetries, _ = strconv.Atoi(value)
+	case "tx failed":
+		info.TxFailed, _ = strconv.Atoi(value)
+	}
+}
+
+// SignalQuality puts a signal level in dBm into the words Wi-Fi vendors
+// commonly use.
+func SignalQuality(dBm int) string {
+	switch {
+	case dBm >= -50:
+		return "excellent"
+	case dBm >= -60:
+		return "good"
+	case dBm >= -70:
+		return "fair"
+	default:
+		return "weak"
+	}
+}
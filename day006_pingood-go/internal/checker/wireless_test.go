@@ -0,0 +1,94 @@
+package checker
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckWirelessFixtures(t *testing.T) {
+	tests := []struct {
+		scenario string
+		iface    string
+		want     WirelessInfo
+		err      error
+	}{
+		{
+			scenario: "linux-ubuntu-22.04",
+			iface:    "wlan0",
+			want: WirelessInfo{
+				Interface: "wlan0", Source: "iw", Connected: true,
+				SSID: "office-5g", BSSID: "3c:22:fb:0a:0b:0c", Frequency: 5180, Channel: 36,
+				Signal: -67, LinkQuality: 43, TxBitrate: 390, RxBitrate: 433.3,
+				TxPackets: 12034, TxRetries: 1543, TxFailed: 27,
+			},
+		},
+		{
+			scenario: "linux-ubuntu-22.04",
+			iface:    "eth0",
+			want:     WirelessInfo{Interface: "eth0"},
+			err:      ErrNotWireless,
+		},
+		{
+			scenario: "linux-ubuntu-24.04-upstream-down",
+			iface:    "wlp0s20f3",
+			want:     WirelessInfo{Interface: "wlp0s20f3", Source: "iw"},
+		},
+		{
+			scenario: "linux-debian-12-de_DE",
+			iface:    "wlan0",
+			want:     WirelessInfo{Interface: "wlan0", Source: "/proc/net/wireless", Connected: true, Signal: -85, Noise: -95, LinkQuality: 25},
+		},
+		{
+			scenario: "linux-fedora-40-minimal",
+			iface:    "eth0",
+			want:     WirelessInfo{Interface: "eth0"},
+			err:      ErrNotWireless,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.scenario+"/"+tt.iface, func(t *testing.T) {
+			nc := loadFixtureChecker(t, tt.scenario)
+
+			info, err := nc.CheckWireless(tt.iface)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Expected error %v, got %v", tt.err, err)
+			}
+			if info != tt.want {
+				t.Errorf("Unexpected wireless info:\n got %+v\nwant %+v", info, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckWirelessMac(t *testing.T) {
+	nc := loadFixtureChecker(t, "darwin-macos-14")
+	if _, err := nc.CheckWireless("en0"); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("Expected ErrUnsupported on macOS, got %v", err)
+	}
+}
+
+func TestWirelessInfoDerived(t *testing.T) {
+	channels := map[int]int{2412: 1, 2437: 6, 2484: 14, 5180: 36, 5825: 165, 5955: 1, 6115: 33, 900: 0}
+	for freq, want := range channels {
+		if got := frequencyToChannel(freq); got != want {
+			t.Errorf("frequencyToChannel(%d) = %d, want %d", freq, got, want)
+		}
+	}
+
+	info := WirelessInfo{Frequency: 2437, Signal: -72, Noise: -92, TxPackets: 200, TxRetries: 30}
+	if info.Band() != "2.4GHz" || SignalQuality(info.Signal) != "weak" {
+		t.Errorf("Unexpected band %q or quality %q", info.Band(), SignalQuality(info.Signal))
+	}
+	metrics := info.Metrics()
+	if metrics["retry_rate"] != 15 || metrics["snr"] != 20 {
+		t.Errorf("Unexpected metrics: %v", metrics)
+	}
+	if _, ok := metrics["tx_bitrate"]; ok {
+		t.Error("Expected missing values to be left out of the metrics")
+	}
+
+	if info, ok := parseProcWireless(" wlan0: 0000   60.  190.  -256  0 0 0 0 0 0\n", "wlan0"); !ok || info.Signal != -66 || info.Noise != 0 {
+		t.Errorf("Expected unsigned level to be converted, got %+v", info)
+	}
+}
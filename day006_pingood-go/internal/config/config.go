@@ -3,23 +3,195 @@ package config
 import (
 	"fmt"
 	"os"
+	"regexp"
 
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	PingCount          int               `yaml:"PING_COUNT"`
-	PingInterval       float64           `yaml:"PING_INTERVAL"`
-	PingTargetsIPv4    []string          `yaml:"PING_TARGETS_IPV4"`
-	PingTargetsIPv6    []string          `yaml:"PING_TARGETS_IPV6"`
-	TracerouteCount    int               `yaml:"TRACEROUTE_COUNT"`
-	TracerouteInterval float64           `yaml:"TRACEROUTE_INTERVAL"`
-	TracerouteTarget   string            `yaml:"TRACEROUTE_TARGET"`
-	ViaNetworkDevices  map[string]string `yaml:"VIA_NW_DEVICES"`
-	DomainARecords     []string          `yaml:"DOMAIN_A_RECORDS"`
-	DomainAAAARecords  []string          `yaml:"DOMAIN_AAAA_RECORDS"`
-	HTTPIPv4Target     string            `yaml:"HTTP_IPV4_TARGET"`
-	HTTPIPv6Target     string            `yaml:"HTTP_IPV6_TARGET"`
+	PingCount            int                  `yaml:"PING_COUNT"`
+	PingInterval         float64              `yaml:"PING_INTERVAL"`
+	PingTargetsIPv4      []string             `yaml:"PING_TARGETS_IPV4"`
+	PingTargetsIPv6      []string             `yaml:"PING_TARGETS_IPV6"`
+	PingAssertions       []string             `yaml:"PING_ASSERTIONS"`
+	TracerouteCount      int                  `yaml:"TRACEROUTE_COUNT"`
+	TracerouteInterval   float64              `yaml:"TRACEROUTE_INTERVAL"`
+	TracerouteTarget     string               `yaml:"TRACEROUTE_TARGET"`
+	TracerouteReverseDNS bool                 `yaml:"TRACEROUTE_REVERSE_DNS"`
+	ASNDatabase          string               `yaml:"ASN_DATABASE"`
+	TraceroutePathRules  []PathRule           `yaml:"TRACEROUTE_PATH_RULES"`
+	ViaNetworkDevices    map[string]string    `yaml:"VIA_NW_DEVICES"`
+	DomainARecords       []string             `yaml:"DOMAIN_A_RECORDS"`
+	DomainAAAARecords    []string             `yaml:"DOMAIN_AAAA_RECORDS"`
+	HTTPIPv4Target       string               `yaml:"HTTP_IPV4_TARGET"`
+	HTTPIPv6Target       string               `yaml:"HTTP_IPV6_TARGET"`
+	HTTPTargets          []HTTPTarget         `yaml:"HTTP_TARGETS"`
+	HTTPChecks           []HTTPCheck          `yaml:"HTTP_CHECKS"`
+	HTTPProtocolTargets  []HTTPProtocolTarget `yaml:"HTTP_PROTOCOL_TARGETS"`
+	IPv6ReadinessTarget  string               `yaml:"IPV6_READINESS_TARGET"`
+	NeighborHistoryFile  string               `yaml:"NEIGHBOR_HISTORY_FILE"`
+	DHCPProbe            string               `yaml:"DHCP_PROBE"`
+	DHCPServer           string               `yaml:"DHCP_SERVER"`
+	RouteExpectations    []RouteExpectation   `yaml:"ROUTE_EXPECTATIONS"`
+	ThroughputEndpoint   string               `yaml:"THROUGHPUT_ENDPOINT"`
+	ThroughputDuration   float64              `yaml:"THROUGHPUT_DURATION"`
+	ThroughputStreams    int                  `yaml:"THROUGHPUT_STREAMS"`
+	ThroughputToken      string               `yaml:"THROUGHPUT_TOKEN"`
+	ThroughputAsserts    []string             `yaml:"THROUGHPUT_ASSERTIONS"`
+	TLSAuditEndpoints    []TLSEndpoint        `yaml:"TLS_AUDIT_ENDPOINTS"`
+	TLSCABundle          string               `yaml:"TLS_CA_BUNDLE"`
+	TLSExpiryWarnDays    int                  `yaml:"TLS_EXPIRY_WARNING_DAYS"`
+	TLSMinVersion        string               `yaml:"TLS_MIN_VERSION"`
+	TLSPins              []TLSPin             `yaml:"TLS_PINS"`
+	MiddleboxEchoHost    string               `yaml:"MIDDLEBOX_ECHO_HOST"`
+	MiddleboxBaseline    string               `yaml:"MIDDLEBOX_BASELINE_PORT"`
+	MiddleboxPorts       []string             `yaml:"MIDDLEBOX_PORTS"`
+	MiddleboxTimeout     float64              `yaml:"MIDDLEBOX_TIMEOUT"`
+	AnalysisRules        []AnalysisRule       `yaml:"ANALYSIS_RULES"`
+	WiFiAssertions       []string             `yaml:"WIFI_ASSERTIONS"`
+	SNMPVersion          string               `yaml:"SNMP_VERSION"`
+	SNMPCommunity        string               `yaml:"SNMP_COMMUNITY"`
+	SNMPPort             int                  `yaml:"SNMP_PORT"`
+	SNMPUser             string               `yaml:"SNMP_USER"`
+	SNMPAuthProtocol     string               `yaml:"SNMP_AUTH_PROTOCOL"`
+	SNMPAuthPassword     string               `yaml:"SNMP_AUTH_PASSWORD"`
+	SNMPPrivProtocol     string               `yaml:"SNMP_PRIV_PROTOCOL"`
+	SNMPPrivPassword     string               `yaml:"SNMP_PRIV_PASSWORD"`
+	Schedule             []ScheduledCheck     `yaml:"SCHEDULE"`
+	ScheduleStateFile    string               `yaml:"SCHEDULE_STATE_FILE"`
+	OTLPEndpoint         string               `yaml:"OTLP_ENDPOINT"`
+	OTLPProtocol         string               `yaml:"OTLP_PROTOCOL"`
+	OTLPHeaders          map[string]string    `yaml:"OTLP_HEADERS"`
+	OTLPCABundle         string               `yaml:"OTLP_CA_BUNDLE"`
+	OTLPTimeout          float64              `yaml:"OTLP_TIMEOUT"`
+	OTLPServiceName      string               `yaml:"OTLP_SERVICE_NAME"`
+	OTLPResourceAttrs    map[string]string    `yaml:"OTLP_RESOURCE_ATTRIBUTES"`
+}
+
+// RouteExpectation describes which interface or next hop traffic to
+// Destination (an address or prefix) should use. Prefix either with "!" to
+// require that it is not used.
+type RouteExpectation struct {
+	Destination string `yaml:"DESTINATION"`
+	Interface   string `yaml:"INTERFACE"`
+	Via         string `yaml:"VIA"`
+}
+
+// PathRule constrains the traceroute path: the TRAVERSE patterns must be
+// crossed in order and none of the AVOID patterns may be. HOPS limits both
+// to a range such as "1-5". Patterns are addresses, names, CIDRs, globs or
+// AS numbers, as in VIA_NW_DEVICES.
+type PathRule struct {
+	Name     string   `yaml:"NAME"`
+	Traverse []string `yaml:"TRAVERSE"`
+	Avoid    []string `yaml:"AVOID"`
+	Hops     string   `yaml:"HOPS"`
+}
+
+// HTTPTarget is a URL to fetch over FAMILY: "ipv4", "ipv6" or "dual"
+// (Happy Eyeballs, the default). REDIRECTS is "follow" (the default),
+// "none" or "same-host"; MAX_REDIRECTS defaults to 10. PROTOCOL forces
+// "http/1.1", "h2" or "h3"; by default the server picks HTTP/1.1 or HTTP/2.
+type HTTPTarget struct {
+	URL          string `yaml:"URL"`
+	Family       string `yaml:"FAMILY"`
+	Redirects    string `yaml:"REDIRECTS"`
+	MaxRedirects int    `yaml:"MAX_REDIRECTS"`
+	Protocol     string `yaml:"PROTOCOL"`
+}
+
+// HTTPProtocolTarget is a URL fetched once per protocol in PROTOCOLS
+// (default http/1.1, h2 and h3) to compare their success and latency.
+type HTTPProtocolTarget struct {
+	URL       string   `yaml:"URL"`
+	Family    string   `yaml:"FAMILY"`
+	Protocols []string `yaml:"PROTOCOLS"`
+	Timeout   float64  `yaml:"TIMEOUT"`
+}
+
+// HTTPCheck is a request to send and the response it must get. FAMILY,
+// REDIRECTS, MAX_REDIRECTS and PROTOCOL are as in HTTPTarget. Values in
+// JSON and RESPONSE_HEADERS are compared exactly unless written as /regexp/.
+// HEADERS, BASIC_AUTH_PASSWORD and BEARER_TOKEN may refer to environment
+// variables as ${NAME} so secrets stay out of the file.
+type HTTPCheck struct {
+	Name              string            `yaml:"NAME"`
+	URL               string            `yaml:"URL"`
+	Family            string            `yaml:"FAMILY"`
+	Redirects         string            `yaml:"REDIRECTS"`
+	MaxRedirects      int               `yaml:"MAX_REDIRECTS"`
+	Protocol          string            `yaml:"PROTOCOL"`
+	Method            string            `yaml:"METHOD"`
+	Headers           map[string]string `yaml:"HEADERS"`
+	Body              string            `yaml:"BODY"`
+	ExpectStatus      []string          `yaml:"EXPECT_STATUS"`
+	BodyContains      []string          `yaml:"BODY_CONTAINS"`
+	BodyMatches       []string          `yaml:"BODY_MATCHES"`
+	JSON              map[string]string `yaml:"JSON"`
+	ResponseHeaders   map[string]string `yaml:"RESPONSE_HEADERS"`
+	BasicAuthUser     string            `yaml:"BASIC_AUTH_USER"`
+	BasicAuthPassword string            `yaml:"BASIC_AUTH_PASSWORD"`
+	BearerToken       string            `yaml:"BEARER_TOKEN"`
+	ClientCert        string            `yaml:"CLIENT_CERT"`
+	ClientKey         string            `yaml:"CLIENT_KEY"`
+	CABundle          string            `yaml:"CA_BUNDLE"`
+	Timeout           float64           `yaml:"TIMEOUT"`
+	Assertions        []string          `yaml:"ASSERTIONS"`
+}
+
+// TLSEndpoint is a host:port to audit. STARTTLS is "smtp" or "imap" for
+// services that upgrade a plain connection; SERVER_NAME overrides the name
+// sent in SNI and checked against the certificate.
+type TLSEndpoint struct {
+	Address    string `yaml:"ADDRESS"`
+	ServerName string `yaml:"SERVER_NAME"`
+	StartTLS   string `yaml:"STARTTLS"`
+}
+
+// TLSPin is a TLS endpoint and the SHA-256 fingerprints of certificates it
+// is known to present, in hex or as "sha256/<base64>" of the public key.
+// Presenting none of them means the connection is intercepted.
+type TLSPin struct {
+	Address      string   `yaml:"ADDRESS"`
+	ServerName   string   `yaml:"SERVER_NAME"`
+	Fingerprints []string `yaml:"FINGERPRINTS"`
+}
+
+// AnalysisRule adds a root-cause rule to the built-in ones, or replaces the
+// built-in rule with the same NAME. A rule without WHEN disables it.
+type AnalysisRule struct {
+	Name      string   `yaml:"NAME"`
+	Priority  int      `yaml:"PRIORITY"`
+	When      []string `yaml:"WHEN"`
+	Diagnosis string   `yaml:"DIAGNOSIS"`
+	Hint      string   `yaml:"HINT"`
+}
+
+// ScheduledCheck runs CHECK, such as "dns" or "traceroute", whenever CRON
+// matches, up to JITTER seconds late. CRON has five fields, six with
+// seconds first, or is a descriptor such as "@hourly" or "@every 30s".
+type ScheduledCheck struct {
+	Check  string  `yaml:"CHECK"`
+	Cron   string  `yaml:"CRON"`
+	Jitter float64 `yaml:"JITTER"`
+}
+
+var envReference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// ExpandEnv replaces ${NAME} in s with the value of the environment
+// variable NAME, or nothing if it is unset. Unlike os.ExpandEnv it leaves
+// a bare $ alone, as in a password like "pa$$word", and a config pushed
+// by a coordinator cannot read an agent's variables with $NAME.
+func ExpandEnv(s string) string {
+	return envReference.ReplaceAllStringFunc(s, func(ref string) string {
+		return os.Getenv(ref[2 : len(ref)-1])
+	})
+}
+
+// HasEnvReference reports whether s refers to an environment variable as
+// ${NAME}, which ExpandEnv would replace.
+func HasEnvReference(s string) bool {
+	return envReference.MatchString(s)
 }
 
 func LoadConfig(path string) (*Config, error) {
@@ -18,17 +18,90 @@ PING_TARGETS_IPV4:
   - '1.1.1.1'
 PING_TARGETS_IPV6:
   - '2001:4860:4860::8888'
+PING_ASSERTIONS:
+  - 'loss < 1%'
+  - 'jitter < 30ms'
 TRACEROUTE_COUNT: 2
 TRACEROUTE_INTERVAL: 0.5
 TRACEROUTE_TARGET: '8.8.8.8'
 VIA_NW_DEVICES:
   router: '192.168.1.1'
+TRACEROUTE_PATH_RULES:
+  - NAME: 'via-isp'
+    TRAVERSE: ['10.0.0.0/8', '*.isp.example.net']
+    AVOID: ['AS64666']
+    HOPS: '1-5'
 DOMAIN_A_RECORDS:
   - 'example.com'
 DOMAIN_AAAA_RECORDS:
   - 'ipv6.example.com'
 HTTP_IPV4_TARGET: 'https://example.com'
-HTTP_IPV6_TARGET: 'https://ipv6.example.com'`
+HTTP_IPV6_TARGET: 'https://ipv6.example.com'
+HTTP_TARGETS:
+  - URL: 'https://www.example.com'
+    FAMILY: 'dual'
+  - URL: 'http://portal.example.com'
+    FAMILY: 'ipv4'
+    REDIRECTS: 'none'
+  - URL: 'https://login.example.com'
+    REDIRECTS: 'same-host'
+    MAX_REDIRECTS: 3
+    PROTOCOL: 'h3'
+HTTP_CHECKS:
+  - NAME: 'api-health'
+    URL: 'https://api.example.com/healthz'
+    METHOD: 'POST'
+    HEADERS:
+      X-Api-Key: '${API_KEY}'
+    EXPECT_STATUS: [200, '3xx']
+    JSON:
+      'checks[0].status': 'ok'
+    BEARER_TOKEN: '${API_TOKEN}'
+    TIMEOUT: 2.5
+HTTP_PROTOCOL_TARGETS:
+  - URL: 'https://www.example.com'
+  - URL: 'https://cdn.example.com'
+    FAMILY: 'ipv6'
+    PROTOCOLS: ['h2', 'h3']
+    TIMEOUT: 5
+IPV6_READINESS_TARGET: 'https://dual.example.com'
+ROUTE_EXPECTATIONS:
+  - DESTINATION: '10.0.0.0/8'
+    INTERFACE: 'wg0'
+  - DESTINATION: '8.8.8.8'
+    INTERFACE: '!wg0'
+    VIA: '192.168.1.1'
+TLS_AUDIT_ENDPOINTS:
+  - ADDRESS: 'mail.example.com:587'
+    STARTTLS: 'smtp'
+  - ADDRESS: '192.0.2.10:636'
+    SERVER_NAME: 'ldap.example.com'
+TLS_PINS:
+  - ADDRESS: 'www.example.com:443'
+    FINGERPRINTS: ['sha256/YLh1dUR9y6Kja30RrAn7JKnbQG/uEtLMkBgFF2Fuihg=']
+MIDDLEBOX_ECHO_HOST: 'echo.example.com'
+MIDDLEBOX_BASELINE_PORT: 'tcp/8443'
+MIDDLEBOX_PORTS: ['tcp/25', 'udp/53']
+SCHEDULE:
+  - CHECK: 'dns'
+    CRON: '*/30 * * * * *'
+  - CHECK: 'throughput'
+    CRON: '@hourly'
+    JITTER: 300
+SCHEDULE_STATE_FILE: '/var/lib/pingood/schedule.json'
+OTLP_ENDPOINT: 'https://otel.example.com:4317'
+OTLP_PROTOCOL: 'grpc'
+OTLP_HEADERS:
+  Authorization: 'Bearer ${OTLP_TOKEN}'
+OTLP_TIMEOUT: 5
+OTLP_RESOURCE_ATTRIBUTES:
+  site: 'tokyo-office'
+ANALYSIS_RULES:
+  - NAME: 'proxy-down'
+    PRIORITY: 85
+    WHEN:
+      - 'http_ipv4 =~ proxy'
+    DIAGNOSIS: 'Corporate proxy unreachable'`
 
 	err := os.WriteFile(configPath, []byte(configContent), 0644)
 	if err != nil {
@@ -53,9 +126,107 @@ HTTP_IPV6_TARGET: 'https://ipv6.example.com'`
 		t.Errorf("Expected PingTargetsIPv4=%v, got %v", expectedIPv4, cfg.PingTargetsIPv4)
 	}
 
+	expectedAssertions := []string{"loss < 1%", "jitter < 30ms"}
+	if !reflect.DeepEqual(cfg.PingAssertions, expectedAssertions) {
+		t.Errorf("Expected PingAssertions=%v, got %v", expectedAssertions, cfg.PingAssertions)
+	}
+
 	if cfg.ViaNetworkDevices["router"] != "192.168.1.1" {
 		t.Errorf("Expected router='192.168.1.1', got %s", cfg.ViaNetworkDevices["router"])
 	}
+
+	expectedPathRules := []PathRule{
+		{Name: "via-isp", Traverse: []string{"10.0.0.0/8", "*.isp.example.net"}, Avoid: []string{"AS64666"}, Hops: "1-5"},
+	}
+	if !reflect.DeepEqual(cfg.TraceroutePathRules, expectedPathRules) {
+		t.Errorf("Expected TraceroutePathRules=%v, got %v", expectedPathRules, cfg.TraceroutePathRules)
+	}
+
+	expectedTargets := []HTTPTarget{
+		{URL: "https://www.example.com", Family: "dual"},
+		{URL: "http://portal.example.com", Family: "ipv4", Redirects: "none"},
+		{URL: "https://login.example.com", Redirects: "same-host", MaxRedirects: 3, Protocol: "h3"},
+	}
+	if !reflect.DeepEqual(cfg.HTTPTargets, expectedTargets) {
+		t.Errorf("Expected HTTPTargets=%v, got %v", expectedTargets, cfg.HTTPTargets)
+	}
+
+	expectedHTTP := []HTTPCheck{{
+		Name:         "api-health",
+		URL:          "https://api.example.com/healthz",
+		Method:       "POST",
+		Headers:      map[string]string{"X-Api-Key": "${API_KEY}"},
+		ExpectStatus: []string{"200", "3xx"},
+		JSON:         map[string]string{"checks[0].status": "ok"},
+		BearerToken:  "${API_TOKEN}",
+		Timeout:      2.5,
+	}}
+	if !reflect.DeepEqual(cfg.HTTPChecks, expectedHTTP) {
+		t.Errorf("Expected HTTPChecks=%+v, got %+v", expectedHTTP, cfg.HTTPChecks)
+	}
+
+	expectedProtocols := []HTTPProtocolTarget{
+		{URL: "https://www.example.com"},
+		{URL: "https://cdn.example.com", Family: "ipv6", Protocols: []string{"h2", "h3"}, Timeout: 5},
+	}
+	if !reflect.DeepEqual(cfg.HTTPProtocolTargets, expectedProtocols) {
+		t.Errorf("Expected HTTPProtocolTargets=%+v, got %+v", expectedProtocols, cfg.HTTPProtocolTargets)
+	}
+
+	if cfg.IPv6ReadinessTarget != "https://dual.example.com" {
+		t.Errorf("Expected IPv6ReadinessTarget=https://dual.example.com, got %s", cfg.IPv6ReadinessTarget)
+	}
+
+	expectedRoutes := []RouteExpectation{
+		{Destination: "10.0.0.0/8", Interface: "wg0"},
+		{Destination: "8.8.8.8", Interface: "!wg0", Via: "192.168.1.1"},
+	}
+	if !reflect.DeepEqual(cfg.RouteExpectations, expectedRoutes) {
+		t.Errorf("Expected RouteExpectations=%v, got %v", expectedRoutes, cfg.RouteExpectations)
+	}
+
+	expectedTLS := []TLSEndpoint{
+		{Address: "mail.example.com:587", StartTLS: "smtp"},
+		{Address: "192.0.2.10:636", ServerName: "ldap.example.com"},
+	}
+	if !reflect.DeepEqual(cfg.TLSAuditEndpoints, expectedTLS) {
+		t.Errorf("Expected TLSAuditEndpoints=%v, got %v", expectedTLS, cfg.TLSAuditEndpoints)
+	}
+
+	expectedPins := []TLSPin{
+		{Address: "www.example.com:443", Fingerprints: []string{"sha256/YLh1dUR9y6Kja30RrAn7JKnbQG/uEtLMkBgFF2Fuihg="}},
+	}
+	if !reflect.DeepEqual(cfg.TLSPins, expectedPins) {
+		t.Errorf("Expected TLSPins=%v, got %v", expectedPins, cfg.TLSPins)
+	}
+	if cfg.MiddleboxEchoHost != "echo.example.com" || cfg.MiddleboxBaseline != "tcp/8443" || !reflect.DeepEqual(cfg.MiddleboxPorts, []string{"tcp/25", "udp/53"}) {
+		t.Errorf("Unexpected middlebox settings %q %q %v", cfg.MiddleboxEchoHost, cfg.MiddleboxBaseline, cfg.MiddleboxPorts)
+	}
+
+	expectedSchedule := []ScheduledCheck{
+		{Check: "dns", Cron: "*/30 * * * * *"},
+		{Check: "throughput", Cron: "@hourly", Jitter: 300},
+	}
+	if !reflect.DeepEqual(cfg.Schedule, expectedSchedule) {
+		t.Errorf("Expected Schedule=%v, got %v", expectedSchedule, cfg.Schedule)
+	}
+	if cfg.ScheduleStateFile != "/var/lib/pingood/schedule.json" {
+		t.Errorf("Expected ScheduleStateFile=/var/lib/pingood/schedule.json, got %s", cfg.ScheduleStateFile)
+	}
+
+	if cfg.OTLPEndpoint != "https://otel.example.com:4317" || cfg.OTLPProtocol != "grpc" || cfg.OTLPTimeout != 5 {
+		t.Errorf("Unexpected OTLP settings %q %q %v", cfg.OTLPEndpoint, cfg.OTLPProtocol, cfg.OTLPTimeout)
+	}
+	if cfg.OTLPHeaders["Authorization"] != "Bearer ${OTLP_TOKEN}" || cfg.OTLPResourceAttrs["site"] != "tokyo-office" {
+		t.Errorf("Unexpected OTLP headers %v or resource attributes %v", cfg.OTLPHeaders, cfg.OTLPResourceAttrs)
+	}
+
+	expectedRules := []AnalysisRule{
+		{Name: "proxy-down", Priority: 85, When: []string{"http_ipv4 =~ proxy"}, Diagnosis: "Corporate proxy unreachable"},
+	}
+	if !reflect.DeepEqual(cfg.AnalysisRules, expectedRules) {
+		t.Errorf("Expected AnalysisRules=%v, got %v", expectedRules, cfg.AnalysisRules)
+	}
 }
 
 func TestLoadConfigFileNotFound(t *testing.T) {
@@ -103,4 +274,20 @@ func TestDefaultConfig(t *testing.T) {
 	if cfg.HTTPIPv4Target != "https://www.google.com" {
 		t.Errorf("Expected default HTTPIPv4Target='https://www.google.com', got %s", cfg.HTTPIPv4Target)
 	}
-}
\ No newline at end of file
+}
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("PINGOOD_TEST_TOKEN", "secret")
+	for input, want := range map[string]string{
+		"Bearer ${PINGOOD_TEST_TOKEN}": "Bearer secret",
+		"pa$$word":                     "pa$$word",
+		"$2b$10$abcdefghijklmnop":      "$2b$10$abcdefghijklmnop",
+		"$PINGOOD_TEST_TOKEN":          "$PINGOOD_TEST_TOKEN",
+		"${PINGOOD_TEST_UNSET}x":       "x",
+		"${not a name}":                "${not a name}",
+	} {
+		if got := ExpandEnv(input); got != want {
+			t.Errorf("Expected %q for %q, got %q", want, input, got)
+		}
+	}
+}
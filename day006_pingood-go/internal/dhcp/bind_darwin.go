@@ -0,0 +1,14 @@
+package dhcp
+
+import (
+	"net"
+	"syscall"
+)
+
+func bindToInterface(fd uintptr, iface string) error {
+	ifi, err := net.InterfaceByName(iface)
+	if err != nil {
+		return err
+	}
+	return syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_BOUND_IF, ifi.Index)
+}
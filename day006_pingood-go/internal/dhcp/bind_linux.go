@@ -0,0 +1,7 @@
+package dhcp
+
+import "syscall"
+
+func bindToInterface(fd uintptr, iface string) error {
+	return syscall.BindToDevice(int(fd), iface)
+}
@@ -0,0 +1,12 @@
+//go:build !linux && !darwin
+
+package dhcp
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func bindToInterface(fd uintptr, iface string) error {
+	return fmt.Errorf("not supported on %s", runtime.GOOS)
+}
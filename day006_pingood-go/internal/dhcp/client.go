@@ -0,0 +1,127 @@
+package dhcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"time"
+)
+
+// Client sends a single request and waits for the matching reply. The zero
+// value broadcasts to port 67 and listens on port 68, which needs root or
+// CAP_NET_BIND_SERVICE.
+type Client struct {
+	Server    string
+	LocalAddr string
+	// Interface, when set, sends the request and takes replies on that
+	// interface only, as a broadcast otherwise leaves by whichever one the
+	// routing table picks. Binding uses SO_BINDTODEVICE on Linux, which
+	// needs root or CAP_NET_RAW, and IP_BOUND_IF on macOS.
+	Interface string
+	Timeout   time.Duration
+}
+
+// Discover asks any server on the link for an offer. The offer is never
+// accepted, so no address is allocated.
+func (c *Client) Discover(mac net.HardwareAddr) (*Message, error) {
+	req, err := NewRequest(Discover, mac, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.exchange(req, Offer)
+}
+
+// Inform asks for the configuration that goes with an address the client
+// already has.
+func (c *Client) Inform(mac net.HardwareAddr, ip net.IP) (*Message, error) {
+	if ip.To4() == nil {
+		return nil, fmt.Errorf("DHCPINFORM needs an IPv4 address, got %v", ip)
+	}
+	req, err := NewRequest(Inform, mac, ip)
+	if err != nil {
+		return nil, err
+	}
+	return c.exchange(req, Ack)
+}
+
+func (c *Client) exchange(req *Message, want MessageType) (*Message, error) {
+	server := c.Server
+	if server == "" {
+		server = "255.255.255.255:67"
+	}
+	local := c.LocalAddr
+	if local == "" {
+		local = ":68"
+	}
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+
+	raddr, err := net.ResolveUDPAddr("udp4", server)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DHCP server address: %w", err)
+	}
+	var lc net.ListenConfig
+	if c.Interface != "" {
+		lc.Control = func(network, address string, raw syscall.RawConn) error {
+			return c.bind(raw)
+		}
+	}
+	conn, err := lc.ListenPacket(context.Background(), "udp4", local)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", local, err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.WriteTo(req.Marshal(), raddr); err != nil {
+		return nil, fmt.Errorf("failed to send DHCP%s: %w", req.Type(), err)
+	}
+
+	if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 1500)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				return nil, fmt.Errorf("no DHCP%s received within %s", want, timeout)
+			}
+			return nil, fmt.Errorf("failed to read DHCP reply: %w", err)
+		}
+
+		reply, err := Parse(buf[:n])
+		if err != nil || reply.Op != opReply || reply.XID != req.XID {
+			// Someone else's traffic on a shared port.
+			continue
+		}
+		switch reply.Type() {
+		case want:
+			return reply, nil
+		case Nak:
+			return reply, ErrNak
+		}
+	}
+}
+
+// bind restricts the socket to c.Interface.
+func (c *Client) bind(raw syscall.RawConn) error {
+	var err error
+	if ctrlErr := raw.Control(func(fd uintptr) {
+		err = bindToInterface(fd, c.Interface)
+	}); ctrlErr != nil {
+		return ctrlErr
+	}
+	switch {
+	case errors.Is(err, os.ErrPermission):
+		return fmt.Errorf("binding to interface %s needs root or CAP_NET_RAW: %w", c.Interface, err)
+	case err != nil:
+		return fmt.Errorf("failed to bind to interface %s: %w", c.Interface, err)
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+// Package dhcp implements just enough of DHCPv4 (RFC 2131) to ask a server
+// what it would hand out: a DISCOVER that is never followed by a REQUEST, or
+// an INFORM for an address that is already configured. Neither allocates a
+// lease.
+package dhcp
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+type MessageType byte
+
+const (
+	Discover MessageType = 1
+	Offer    MessageType = 2
+	Request  MessageType = 3
+	Decline  MessageType = 4
+	Ack      MessageType = 5
+	Nak      MessageType = 6
+	Release  MessageType = 7
+	Inform   MessageType = 8
+)
+
+func (t MessageType) String() string {
+	names := map[MessageType]string{
+		Discover: "DISCOVER", Offer: "OFFER", Request: "REQUEST", Decline: "DECLINE",
+		Ack: "ACK", Nak: "NAK", Release: "RELEASE", Inform: "INFORM",
+	}
+	if name, ok := names[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("TYPE%d", byte(t))
+}
+
+const (
+	OptionSubnetMask    byte = 1
+	OptionRouter        byte = 3
+	OptionDNS           byte = 6
+	OptionDomainName    byte = 15
+	OptionLeaseTime     byte = 51
+	OptionMessageType   byte = 53
+	OptionServerID      byte = 54
+	OptionParameterList byte = 55
+	OptionEnd           byte = 255
+	optionPad           byte = 0
+)
+
+const (
+	opRequest     = 1
+	opReply       = 2
+	flagBroadcast = 0x8000
+	headerLength  = 236
+)
+
+var magicCookie = []byte{99, 130, 83, 99}
+
+// ErrNak is returned when the server refuses the request.
+var ErrNak = errors.New("server answered with DHCPNAK")
+
+type Message struct {
+	Op        byte
+	XID       uint32
+	Flags     uint16
+	ClientIP  net.IP
+	YourIP    net.IP
+	ServerIP  net.IP
+	RelayIP   net.IP
+	ClientMAC net.HardwareAddr
+	Options   map[byte][]byte
+}
+
+// NewRequest builds a client message of the given type asking for the
+// options pingood reports on.
+func NewRequest(t MessageType, mac net.HardwareAddr, clientIP net.IP) (*Message, error) {
+	xid := make([]byte, 4)
+	if _, err := rand.Read(xid); err != nil {
+		return nil, fmt.Errorf("failed to generate transaction id: %w", err)
+	}
+
+	m := &Message{
+		Op:        opRequest,
+		XID:       binary.BigEndian.Uint32(xid),
+		ClientIP:  clientIP,
+		ClientMAC: mac,
+		Options: map[byte][]byte{
+			OptionMessageType: {byte(t)},
+			OptionParameterList: {
+				OptionSubnetMask, OptionRouter, OptionDNS, OptionDomainName, OptionLeaseTime, OptionServerID,
+			},
+		},
+	}
+	if clientIP == nil {
+		// Without an address the reply has to be broadcast back to us.
+		m.Flags = flagBroadcast
+	}
+	return m, nil
+}
+
+func (m *Message) Marshal() []byte {
+	b := make([]byte, headerLength, headerLength+64)
+	b[0] = m.Op
+	b[1] = 1 // Ethernet
+	b[2] = 6
+	binary.BigEndian.PutUint32(b[4:8], m.XID)
+	binary.BigEndian.PutUint16(b[10:12], m.Flags)
+	copy(b[12:16], m.ClientIP.To4())
+	copy(b[16:20], m.YourIP.To4())
+	copy(b[20:24], m.ServerIP.To4())
+	copy(b[24:28], m.RelayIP.To4())
+	copy(b[28:44], m.ClientMAC)
+
+	b = append(b, magicCookie...)
+	// Message type goes first; some servers insist on it.
+	if t, ok := m.Options[OptionMessageType]; ok {
+		b = append(b, OptionMessageType, byte(len(t)))
+		b = append(b, t...)
+	}
+	for code := 1; code < int(OptionEnd); code++ {
+		value, ok := m.Options[byte(code)]
+		if !ok || byte(code) == OptionMessageType {
+			continue
+		}
+		b = append(b, byte(code), byte(len(value)))
+		b = append(b, value...)
+	}
+	return append(b, OptionEnd)
+}
+
+func Parse(data []byte) (*Message, error) {
+	if len(data) < headerLength+len(magicCookie) {
+		return nil, fmt.Errorf("packet too short: %d bytes", len(data))
+	}
+	if string(data[headerLength:headerLength+4]) != string(magicCookie) {
+		return nil, errors.New("missing DHCP magic cookie")
+	}
+
+	hlen := int(data[2])
+	if hlen > 16 {
+		hlen = 16
+	}
+	m := &Message{
+		Op:        data[0],
+		XID:       binary.BigEndian.Uint32(data[4:8]),
+		Flags:     binary.BigEndian.Uint16(data[10:12]),
+		ClientIP:  net.IP(append([]byte(nil), data[12:16]...)),
+		YourIP:    net.IP(append([]byte(nil), data[16:20]...)),
+		ServerIP:  net.IP(append([]byte(nil), data[20:24]...)),
+		RelayIP:   net.IP(append([]byte(nil), data[24:28]...)),
+		ClientMAC: net.HardwareAddr(append([]byte(nil), data[28:28+hlen]...)),
+		Options:   make(map[byte][]byte),
+	}
+
+	options := data[headerLength+4:]
+	for i := 0; i < len(options); {
+		code := options[i]
+		if code == OptionEnd {
+			break
+		}
+		if code == optionPad {
+			i++
+			continue
+		}
+		if i+1 >= len(options) || i+2+int(options[i+1]) > len(options) {
+			return nil, fmt.Errorf("truncated option %d", code)
+		}
+		length := int(options[i+1])
+		// Long options may be split over several instances (RFC 3396).
+		m.Options[code] = append(m.Options[code], options[i+2:i+2+length]...)
+		i += 2 + length
+	}
+	return m, nil
+}
+
+func (m *Message) Type() MessageType {
+	if t := m.Options[OptionMessageType]; len(t) == 1 {
+		return MessageType(t[0])
+	}
+	return 0
+}
+
+func (m *Message) ServerID() net.IP {
+	ips := m.ips(OptionServerID)
+	if len(ips) == 0 {
+		return nil
+	}
+	return ips[0]
+}
+
+func (m *Message) SubnetMask() net.IP {
+	ips := m.ips(OptionSubnetMask)
+	if len(ips) == 0 {
+		return nil
+	}
+	return ips[0]
+}
+
+func (m *Message) Routers() []net.IP {
+	return m.ips(OptionRouter)
+}
+
+func (m *Message) DNS() []net.IP {
+	return m.ips(OptionDNS)
+}
+
+func (m *Message) DomainName() string {
+	return string(m.Options[OptionDomainName])
+}
+
+func (m *Message) LeaseTime() time.Duration {
+	value := m.Options[OptionLeaseTime]
+	if len(value) != 4 {
+		return 0
+	}
+	return time.Duration(binary.BigEndian.Uint32(value)) * time.Second
+}
+
+func (m *Message) ips(code byte) []net.IP {
+	value := m.Options[code]
+	var ips []net.IP
+	for i := 0; i+4 <= len(value); i += 4 {
+		ips = append(ips, net.IP(append([]byte(nil), value[i:i+4]...)))
+	}
+	return ips
+}
@@ -0,0 +1,185 @@
+package dhcp
+
+import (
+	"errors"
+	"net"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// standInServer answers every DISCOVER with an OFFER and every INFORM with
+// an ACK, replying to the sender's address instead of port 68 so tests can
+// run without privileges.
+func standInServer(t *testing.T, replyType func(MessageType) MessageType) string {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to start stand-in server: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			req, err := Parse(buf[:n])
+			if err != nil {
+				continue
+			}
+
+			reply := &Message{
+				Op:        opReply,
+				XID:       req.XID,
+				ClientMAC: req.ClientMAC,
+				ServerIP:  net.IPv4(192, 168, 1, 1),
+				Options: map[byte][]byte{
+					OptionMessageType: {byte(replyType(req.Type()))},
+					OptionServerID:    {192, 168, 1, 1},
+					OptionSubnetMask:  {255, 255, 255, 0},
+					OptionRouter:      {192, 168, 1, 1},
+					OptionDNS:         {192, 168, 1, 1, 8, 8, 8, 8},
+					OptionDomainName:  []byte("home.lan"),
+				},
+			}
+			if req.Type() == Discover {
+				reply.YourIP = net.IPv4(192, 168, 1, 50)
+				reply.Options[OptionLeaseTime] = []byte{0, 1, 0x51, 0x80}
+			}
+
+			// A reply for another transaction must be ignored by the client.
+			stray := *reply
+			stray.XID++
+			conn.WriteTo(stray.Marshal(), addr)
+			conn.WriteTo(reply.Marshal(), addr)
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+func answer(t MessageType) MessageType {
+	if t == Discover {
+		return Offer
+	}
+	return Ack
+}
+
+func TestDiscover(t *testing.T) {
+	client := &Client{Server: standInServer(t, answer), LocalAddr: "127.0.0.1:0", Timeout: 2 * time.Second}
+	mac, _ := net.ParseMAC("52:54:00:12:34:56")
+
+	offer, err := client.Discover(mac)
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+
+	if offer.Type() != Offer {
+		t.Errorf("Expected OFFER, got %s", offer.Type())
+	}
+	if !offer.YourIP.Equal(net.IPv4(192, 168, 1, 50)) {
+		t.Errorf("Expected offered address 192.168.1.50, got %v", offer.YourIP)
+	}
+	if offer.ClientMAC.String() != mac.String() {
+		t.Errorf("Expected client MAC %s, got %s", mac, offer.ClientMAC)
+	}
+	if !offer.ServerID().Equal(net.IPv4(192, 168, 1, 1)) {
+		t.Errorf("Unexpected server id %v", offer.ServerID())
+	}
+	if dns := offer.DNS(); len(dns) != 2 || !dns[1].Equal(net.IPv4(8, 8, 8, 8)) {
+		t.Errorf("Unexpected DNS servers %v", dns)
+	}
+	if offer.DomainName() != "home.lan" {
+		t.Errorf("Expected domain home.lan, got %q", offer.DomainName())
+	}
+	if offer.LeaseTime() != 24*time.Hour {
+		t.Errorf("Expected 24h lease, got %v", offer.LeaseTime())
+	}
+}
+
+func TestInform(t *testing.T) {
+	client := &Client{Server: standInServer(t, answer), LocalAddr: "127.0.0.1:0", Timeout: 2 * time.Second}
+	mac, _ := net.ParseMAC("52:54:00:12:34:56")
+
+	ack, err := client.Inform(mac, net.IPv4(192, 168, 1, 23))
+	if err != nil {
+		t.Fatalf("Inform failed: %v", err)
+	}
+	if ack.Type() != Ack {
+		t.Errorf("Expected ACK, got %s", ack.Type())
+	}
+	if routers := ack.Routers(); len(routers) != 1 || !routers[0].Equal(net.IPv4(192, 168, 1, 1)) {
+		t.Errorf("Unexpected routers %v", routers)
+	}
+	if ack.LeaseTime() != 0 {
+		t.Errorf("INFORM replies carry no lease time, got %v", ack.LeaseTime())
+	}
+
+	if _, err := client.Inform(mac, net.ParseIP("2001:db8::1")); err == nil {
+		t.Error("Expected INFORM with an IPv6 address to fail")
+	}
+}
+
+func TestNakAndTimeout(t *testing.T) {
+	mac, _ := net.ParseMAC("52:54:00:12:34:56")
+
+	nak := &Client{Server: standInServer(t, func(MessageType) MessageType { return Nak }), LocalAddr: "127.0.0.1:0", Timeout: 2 * time.Second}
+	if _, err := nak.Inform(mac, net.IPv4(192, 168, 1, 23)); err != ErrNak {
+		t.Errorf("Expected ErrNak, got %v", err)
+	}
+
+	silent, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer silent.Close()
+
+	client := &Client{Server: silent.LocalAddr().String(), LocalAddr: "127.0.0.1:0", Timeout: 100 * time.Millisecond}
+	if _, err := client.Discover(mac); err == nil {
+		t.Error("Expected timeout error")
+	}
+}
+
+func TestBindToInterface(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the loopback interface is named lo on Linux only")
+	}
+	mac, _ := net.ParseMAC("52:54:00:12:34:56")
+
+	missing := &Client{Server: "127.0.0.1:67", LocalAddr: "127.0.0.1:0", Interface: "pingood-missing0"}
+	if _, err := missing.Discover(mac); err == nil || !strings.Contains(err.Error(), "pingood-missing0") {
+		t.Errorf("Expected an error naming the interface, got %v", err)
+	}
+
+	client := &Client{Server: standInServer(t, answer), LocalAddr: "127.0.0.1:0", Interface: "lo", Timeout: 2 * time.Second}
+	_, err := client.Discover(mac)
+	if errors.Is(err, os.ErrPermission) {
+		if !strings.Contains(err.Error(), "CAP_NET_RAW") {
+			t.Errorf("Expected the error to say what is missing, got %v", err)
+		}
+		t.Skip("binding to an interface needs root or CAP_NET_RAW")
+	}
+	if err != nil {
+		t.Errorf("Discover on lo failed: %v", err)
+	}
+}
+
+func TestParseRejectsGarbage(t *testing.T) {
+	if _, err := Parse([]byte{1, 2, 3}); err == nil {
+		t.Error("Expected short packet to be rejected")
+	}
+
+	req, _ := NewRequest(Discover, net.HardwareAddr{1, 2, 3, 4, 5, 6}, nil)
+	data := req.Marshal()
+	data[len(data)-1] = OptionDNS
+	data = append(data, 8, 1, 1)
+	if _, err := Parse(data); err == nil {
+		t.Error("Expected truncated option to be rejected")
+	}
+}
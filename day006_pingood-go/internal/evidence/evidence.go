@@ -0,0 +1,204 @@
+// Package evidence keeps the raw material behind a pingood run so it can be
+// attached to an escalation: the output of every external command, the
+// debug log with packet timings and HTTP traces, and each section as JSON,
+// in one directory per check. The bundle is packed into a tar.gz at the end.
+package evidence
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/checker"
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/report"
+)
+
+// Bundle is an evidence directory being filled. A bundle looks like this:
+//
+//	pingood-evidence-20240102-150405/
+//	  01-ip/section.json
+//	  01-ip/log.jsonl
+//	  01-ip/commands/01-ip_addr_show_eth0.txt
+//	  ...
+//	  report.json
+//	  report.txt
+//	  log.jsonl
+//
+// Log records and commands belong to the section added after them, which
+// holds as long as checks run one after another.
+type Bundle struct {
+	Dir string
+
+	mu       sync.Mutex
+	log      bytes.Buffer
+	sections int
+}
+
+// New creates the bundle directory for a run started at started in parent.
+func New(parent string, started time.Time) (*Bundle, error) {
+	dir := filepath.Join(parent, "pingood-evidence-"+started.Format("20060102-150405"))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create evidence directory: %w", err)
+	}
+	return &Bundle{Dir: dir}, nil
+}
+
+// Handler returns a handler that keeps every record, debug included, as
+// JSON for the next section added.
+func (b *Bundle) Handler() slog.Handler {
+	return slog.NewJSONHandler(logWriter{b}, &slog.HandlerOptions{Level: slog.LevelDebug})
+}
+
+type logWriter struct{ b *Bundle }
+
+// Write is called once per record by slog.JSONHandler.
+func (w logWriter) Write(p []byte) (int, error) {
+	w.b.mu.Lock()
+	defer w.b.mu.Unlock()
+	return w.b.log.Write(p)
+}
+
+// takeLog returns the records logged since the last call.
+func (b *Bundle) takeLog() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	data := append([]byte(nil), b.log.Bytes()...)
+	b.log.Reset()
+	return data
+}
+
+// AddSection stores section with the commands run and records logged for
+// it since the previous section.
+func (b *Bundle) AddSection(section report.Section, commands []checker.Fixture) error {
+	b.mu.Lock()
+	b.sections++
+	dir := filepath.Join(b.Dir, fmt.Sprintf("%02d-%s", b.sections, section.ID))
+	b.mu.Unlock()
+
+	if err := writeJSON(filepath.Join(dir, "section.json"), section); err != nil {
+		return err
+	}
+	if data := b.takeLog(); len(data) > 0 {
+		if err := writeFile(filepath.Join(dir, "log.jsonl"), data); err != nil {
+			return err
+		}
+	}
+	for i, command := range commands {
+		path := filepath.Join(dir, "commands", fmt.Sprintf("%02d-%s", i+1, command.FileName()))
+		if err := writeFile(path, command.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteReport stores the whole report, as JSON and as printed, and what
+// was logged after the last section.
+func (b *Bundle) WriteReport(rep *report.Report) error {
+	if err := writeJSON(filepath.Join(b.Dir, "report.json"), rep); err != nil {
+		return err
+	}
+	var text bytes.Buffer
+	rep.WriteHeader(&text)
+	for i, section := range rep.Sections {
+		report.WriteSection(&text, i+1, section)
+	}
+	if err := writeFile(filepath.Join(b.Dir, "report.txt"), text.Bytes()); err != nil {
+		return err
+	}
+	if data := b.takeLog(); len(data) > 0 {
+		return writeFile(filepath.Join(b.Dir, "log.jsonl"), data)
+	}
+	return nil
+}
+
+// Archive packs the bundle directory into a tar.gz next to it and returns
+// its path.
+func (b *Bundle) Archive() (string, error) {
+	path := b.Dir + ".tar.gz"
+	f, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to create evidence archive: %w", err)
+	}
+	if err := writeArchive(f, b.Dir); err != nil {
+		f.Close()
+		return "", fmt.Errorf("failed to write evidence archive: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("failed to write evidence archive: %w", err)
+	}
+	return path, nil
+}
+
+func writeArchive(w io.Writer, dir string) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	root := filepath.Dir(dir)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(name)
+		if d.IsDir() {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		_, err = tw.Write(data)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", filepath.Base(path), err)
+	}
+	return writeFile(path, data)
+}
+
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create evidence directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write evidence: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package evidence
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/checker"
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/report"
+)
+
+func readArchive(t *testing.T, path string) map[string]string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string)
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[header.Name] = string(data)
+	}
+}
+
+func TestBundle(t *testing.T) {
+	started := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	bundle, err := New(t.TempDir(), started)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(bundle.Dir) != "pingood-evidence-20240102-150405" {
+		t.Errorf("Unexpected bundle directory %s", bundle.Dir)
+	}
+	log := slog.New(bundle.Handler())
+
+	ping := checker.Fixture{Command: "ping -c 3 8.8.8.8", Stdout: "3 packets transmitted, 3 received\n"}
+	log.Debug("ping replies", "target", "8.8.8.8", "rtt_ms", []float64{9.8, 10.1, 9.9})
+	pingSection := report.Section{ID: "ping_ipv4", Title: "ICMP Ping Test (IPv4)", Items: []report.Item{{Name: "8.8.8.8", Status: report.StatusPass, Samples: []float64{9.8, 10.1, 9.9}}}}
+	if err := bundle.AddSection(pingSection, []checker.Fixture{ping}); err != nil {
+		t.Fatal(err)
+	}
+	if err := bundle.AddSection(report.Section{ID: "diagnosis", Title: "Diagnosis"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	log.Info("after the last section")
+
+	rep := &report.Report{Platform: "linux/amd64", Interface: "eth0", StartedAt: started}
+	rep.Add(pingSection)
+	if err := bundle.WriteReport(rep); err != nil {
+		t.Fatal(err)
+	}
+	path, err := bundle.Archive()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != bundle.Dir+".tar.gz" {
+		t.Errorf("Unexpected archive path %s", path)
+	}
+
+	files := readArchive(t, path)
+	prefix := "pingood-evidence-20240102-150405/"
+	command := files[prefix+"01-ping_ipv4/commands/01-"+ping.FileName()]
+	if fixture, err := checker.ParseFixture(command); err != nil || fixture.Stdout != ping.Stdout {
+		t.Errorf("Expected the ping output to round-trip, got %q (%v)", command, err)
+	}
+	if !strings.Contains(files[prefix+"01-ping_ipv4/log.jsonl"], `"rtt_ms":[9.8,10.1,9.9]`) {
+		t.Errorf("Expected the ping replies in the section log, got %q", files[prefix+"01-ping_ipv4/log.jsonl"])
+	}
+	if !strings.Contains(files[prefix+"01-ping_ipv4/section.json"], `"samples": [`) {
+		t.Errorf("Expected the section with its samples, got %q", files[prefix+"01-ping_ipv4/section.json"])
+	}
+	if _, ok := files[prefix+"02-diagnosis/log.jsonl"]; ok {
+		t.Error("Expected no log for a section that logged nothing")
+	}
+	if !strings.Contains(files[prefix+"log.jsonl"], "after the last section") {
+		t.Errorf("Expected records after the last section in the top-level log, got %q", files[prefix+"log.jsonl"])
+	}
+	if !strings.Contains(files[prefix+"report.txt"], "Interface: eth0") || files[prefix+"report.json"] == "" {
+		t.Error("Expected the report as text and JSON")
+	}
+}
@@ -0,0 +1,401 @@
+// Package h2c speaks HTTP/2 over cleartext TCP with prior knowledge (RFC
+// 9113, Section 3.3), which net/http only speaks over TLS.
+//
+// It exists for unary gRPC calls to plaintext endpoints such as an
+// OpenTelemetry collector on port 4317: requests and responses are
+// buffered whole, the client makes one request per connection, and both
+// ends advertise flow control windows large enough that they never have to
+// update them.
+package h2c
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/hpack"
+)
+
+// HTTP/2 frame types, flags and settings (RFC 9113, Section 6).
+const (
+	frameData         = 0x0
+	frameHeaders      = 0x1
+	frameRSTStream    = 0x3
+	frameSettings     = 0x4
+	framePing         = 0x6
+	frameGoAway       = 0x7
+	frameWindowUpdate = 0x8
+	frameContinuation = 0x9
+
+	flagEndStream  = 0x1
+	flagAck        = 0x1
+	flagEndHeaders = 0x4
+	flagPadded     = 0x8
+	flagPriority   = 0x20
+
+	settingEnablePush        = 0x2
+	settingInitialWindowSize = 0x4
+
+	preface = "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"
+
+	// maxFrameSize is the frame size every peer accepts; we neither send
+	// nor accept larger frames.
+	maxFrameSize  = 16384
+	defaultWindow = 65535
+	// receiveWindow is what we let the peer send on a stream and on the
+	// connection without ever sending WINDOW_UPDATE.
+	receiveWindow = 1 << 30
+
+	maxHeaderBlock = 64 << 10
+)
+
+// hopHeaders are not allowed in HTTP/2 (RFC 9113, Section 8.2.2).
+var hopHeaders = map[string]bool{
+	"connection":        true,
+	"host":              true,
+	"keep-alive":        true,
+	"proxy-connection":  true,
+	"transfer-encoding": true,
+	"upgrade":           true,
+}
+
+type frame struct {
+	typ     byte
+	flags   byte
+	stream  uint32
+	payload []byte
+}
+
+type stream struct {
+	id     uint32
+	window int64
+	// header is the request or final response header; trailer is the
+	// header block that ends the stream after DATA, if any.
+	header  []hpack.HeaderField
+	trailer []hpack.HeaderField
+	data    bytes.Buffer
+	ended   bool
+	err     error
+}
+
+// conn is one end of an HTTP/2 connection. It is driven by a single
+// goroutine that reads frames whenever it has to wait for something.
+type conn struct {
+	nc       net.Conn
+	r        *bufio.Reader
+	w        *bufio.Writer
+	dec      *hpack.Decoder
+	isServer bool
+
+	initialWindow int64
+	window        int64
+	streams       map[uint32]*stream
+}
+
+func newConn(nc net.Conn, isServer bool) *conn {
+	return &conn{
+		nc:            nc,
+		r:             bufio.NewReader(nc),
+		w:             bufio.NewWriter(nc),
+		dec:           hpack.NewDecoder(hpack.DefaultTableSize),
+		isServer:      isServer,
+		initialWindow: defaultWindow,
+		window:        defaultWindow,
+		streams:       make(map[uint32]*stream),
+	}
+}
+
+// writeSettings sends our SETTINGS and opens the connection window.
+func (c *conn) writeSettings() error {
+	var settings []byte
+	settings = appendSetting(settings, settingEnablePush, 0)
+	settings = appendSetting(settings, settingInitialWindowSize, receiveWindow)
+	c.writeFrame(frameSettings, 0, 0, settings)
+	c.writeFrame(frameWindowUpdate, 0, 0, binary.BigEndian.AppendUint32(nil, receiveWindow-defaultWindow))
+	return c.w.Flush()
+}
+
+func appendSetting(b []byte, id uint16, value uint32) []byte {
+	b = binary.BigEndian.AppendUint16(b, id)
+	return binary.BigEndian.AppendUint32(b, value)
+}
+
+func (c *conn) writeFrame(typ, flags byte, streamID uint32, payload []byte) {
+	var header [9]byte
+	header[0], header[1], header[2] = byte(len(payload)>>16), byte(len(payload)>>8), byte(len(payload))
+	header[3], header[4] = typ, flags
+	binary.BigEndian.PutUint32(header[5:], streamID)
+	c.w.Write(header[:])
+	c.w.Write(payload)
+}
+
+func (c *conn) readFrame() (frame, error) {
+	var header [9]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		return frame{}, err
+	}
+	length := int(header[0])<<16 | int(header[1])<<8 | int(header[2])
+	if length > maxFrameSize {
+		return frame{}, fmt.Errorf("h2c: frame of %d bytes is larger than allowed", length)
+	}
+	f := frame{typ: header[3], flags: header[4], stream: binary.BigEndian.Uint32(header[5:]) & 0x7fffffff}
+	f.payload = make([]byte, length)
+	if _, err := io.ReadFull(c.r, f.payload); err != nil {
+		return frame{}, err
+	}
+	return f, nil
+}
+
+// writeHeaders sends a header block on a stream, in CONTINUATION frames
+// if it does not fit in one.
+func (c *conn) writeHeaders(streamID uint32, fields []hpack.HeaderField, endStream bool) error {
+	var block []byte
+	for _, f := range fields {
+		block = hpack.AppendField(block, f)
+	}
+	typ, flags := byte(frameHeaders), byte(0)
+	if endStream {
+		flags |= flagEndStream
+	}
+	for {
+		chunk := block
+		if len(chunk) > maxFrameSize {
+			chunk = chunk[:maxFrameSize]
+		}
+		block = block[len(chunk):]
+		if len(block) == 0 {
+			flags |= flagEndHeaders
+		}
+		c.writeFrame(typ, flags, streamID, chunk)
+		if len(block) == 0 {
+			return c.w.Flush()
+		}
+		typ, flags = frameContinuation, 0
+	}
+}
+
+// writeData sends data on s as flow control allows, reading frames while
+// it waits for the peer to open its windows.
+func (c *conn) writeData(s *stream, data []byte, endStream bool) error {
+	for {
+		n := int64(len(data))
+		n = min(n, int64(maxFrameSize), c.window, s.window)
+		if n <= 0 && len(data) > 0 {
+			if err := c.w.Flush(); err != nil {
+				return err
+			}
+			if err := c.step(); err != nil {
+				return err
+			}
+			if s.err != nil {
+				return s.err
+			}
+			continue
+		}
+		var flags byte
+		if endStream && n == int64(len(data)) {
+			flags = flagEndStream
+		}
+		c.writeFrame(frameData, flags, s.id, data[:n])
+		c.window -= n
+		s.window -= n
+		data = data[n:]
+		if len(data) == 0 {
+			return c.w.Flush()
+		}
+	}
+}
+
+// step reads and handles one frame, or one header block with its
+// CONTINUATION frames.
+func (c *conn) step() error {
+	f, err := c.readFrame()
+	if err != nil {
+		return err
+	}
+	switch f.typ {
+	case frameSettings:
+		return c.handleSettings(f)
+	case framePing:
+		if f.flags&flagAck == 0 {
+			c.writeFrame(framePing, flagAck, 0, f.payload)
+			return c.w.Flush()
+		}
+	case frameWindowUpdate:
+		if len(f.payload) != 4 {
+			return errors.New("h2c: invalid WINDOW_UPDATE frame")
+		}
+		increment := int64(binary.BigEndian.Uint32(f.payload) & 0x7fffffff)
+		if f.stream == 0 {
+			c.window += increment
+		} else if s := c.streams[f.stream]; s != nil {
+			s.window += increment
+		}
+	case frameGoAway:
+		if len(f.payload) < 8 {
+			return errors.New("h2c: invalid GOAWAY frame")
+		}
+		last := binary.BigEndian.Uint32(f.payload) & 0x7fffffff
+		code := binary.BigEndian.Uint32(f.payload[4:])
+		for id, s := range c.streams {
+			if id > last && !s.ended {
+				s.err = fmt.Errorf("h2c: connection closed by peer (%s)", errorCode(code))
+			}
+		}
+	case frameRSTStream:
+		if s := c.streams[f.stream]; s != nil && len(f.payload) == 4 {
+			s.err = fmt.Errorf("h2c: stream reset by peer (%s)", errorCode(binary.BigEndian.Uint32(f.payload)))
+		}
+	case frameHeaders:
+		return c.handleHeaders(f)
+	case frameData:
+		return c.handleData(f)
+	case frameContinuation:
+		return errors.New("h2c: unexpected CONTINUATION frame")
+	}
+	return nil
+}
+
+func (c *conn) handleSettings(f frame) error {
+	if f.flags&flagAck != 0 {
+		return nil
+	}
+	if len(f.payload)%6 != 0 {
+		return errors.New("h2c: invalid SETTINGS frame")
+	}
+	for p := f.payload; len(p) > 0; p = p[6:] {
+		value := binary.BigEndian.Uint32(p[2:])
+		switch binary.BigEndian.Uint16(p) {
+		case settingInitialWindowSize:
+			delta := int64(value) - c.initialWindow
+			c.initialWindow = int64(value)
+			for _, s := range c.streams {
+				s.window += delta
+			}
+		}
+	}
+	c.writeFrame(frameSettings, flagAck, 0, nil)
+	return c.w.Flush()
+}
+
+func (c *conn) handleHeaders(f frame) error {
+	payload, err := unpad(f)
+	if err != nil {
+		return err
+	}
+	if f.flags&flagPriority != 0 {
+		if len(payload) < 5 {
+			return errors.New("h2c: invalid HEADERS frame")
+		}
+		payload = payload[5:]
+	}
+	block := append([]byte(nil), payload...)
+	for flags := f.flags; flags&flagEndHeaders == 0; {
+		next, err := c.readFrame()
+		if err != nil {
+			return err
+		}
+		if next.typ != frameContinuation || next.stream != f.stream {
+			return errors.New("h2c: header block interrupted")
+		}
+		if block = append(block, next.payload...); len(block) > maxHeaderBlock {
+			return errors.New("h2c: header block too large")
+		}
+		flags = next.flags
+	}
+	// The block must be decoded even for an unknown stream to keep the
+	// dynamic table in sync.
+	fields, err := c.dec.Decode(block)
+	if err != nil {
+		return err
+	}
+
+	s := c.streams[f.stream]
+	if s == nil {
+		if !c.isServer || f.stream%2 == 0 {
+			return nil
+		}
+		s = c.open(f.stream)
+	}
+	switch {
+	case s.header == nil || informational(s.header):
+		s.header = fields
+	default:
+		s.trailer = fields
+	}
+	if f.flags&flagEndStream != 0 {
+		s.ended = true
+	}
+	return nil
+}
+
+func (c *conn) handleData(f frame) error {
+	data, err := unpad(f)
+	if err != nil {
+		return err
+	}
+	s := c.streams[f.stream]
+	if s == nil {
+		return nil
+	}
+	s.data.Write(data)
+	if f.flags&flagEndStream != 0 {
+		s.ended = true
+	}
+	return nil
+}
+
+func (c *conn) open(id uint32) *stream {
+	s := &stream{id: id, window: c.initialWindow}
+	c.streams[id] = s
+	return s
+}
+
+// close tells the peer we are done and closes the connection.
+func (c *conn) close() {
+	payload := binary.BigEndian.AppendUint32(nil, 0)
+	payload = binary.BigEndian.AppendUint32(payload, 0)
+	c.writeFrame(frameGoAway, 0, 0, payload)
+	c.w.Flush()
+	c.nc.Close()
+}
+
+func unpad(f frame) ([]byte, error) {
+	if f.flags&flagPadded == 0 {
+		return f.payload, nil
+	}
+	if len(f.payload) == 0 || int(f.payload[0]) >= len(f.payload) {
+		return nil, errors.New("h2c: invalid padding")
+	}
+	return f.payload[1 : len(f.payload)-int(f.payload[0])], nil
+}
+
+// informational reports whether a response header block is a 1xx
+// response, which is followed by the real one.
+func informational(fields []hpack.HeaderField) bool {
+	for _, f := range fields {
+		if f.Name == ":status" {
+			return len(f.Value) == 3 && f.Value[0] == '1'
+		}
+	}
+	return false
+}
+
+var errorCodes = []string{
+	"NO_ERROR", "PROTOCOL_ERROR", "INTERNAL_ERROR", "FLOW_CONTROL_ERROR",
+	"SETTINGS_TIMEOUT", "STREAM_CLOSED", "FRAME_SIZE_ERROR", "REFUSED_STREAM",
+	"CANCEL", "COMPRESSION_ERROR", "CONNECT_ERROR", "ENHANCE_YOUR_CALM",
+	"INADEQUATE_SECURITY", "HTTP_1_1_REQUIRED",
+}
+
+func errorCode(code uint32) string {
+	if int(code) < len(errorCodes) {
+		return errorCodes[code]
+	}
+	return "error code " + strconv.FormatUint(uint64(code), 10)
+}
@@ -0,0 +1,109 @@
+package h2c
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func startServer(t *testing.T, handler http.HandlerFunc) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go (&Server{Handler: handler}).Serve(l)
+	return "http://" + l.Addr().String()
+}
+
+func TestRoundTrip(t *testing.T) {
+	// Larger than the default window and a frame, in both directions.
+	payload := bytes.Repeat([]byte("0123456789abcdef"), 10000)
+	longHeader := strings.Repeat("h", 20000)
+
+	url := startServer(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Method != http.MethodPost || r.URL.Path != "/echo" || r.ProtoMajor != 2 {
+			t.Errorf("Unexpected request %s %s %s", r.Method, r.URL.Path, r.Proto)
+		}
+		if r.Header.Get("X-Long") != longHeader {
+			t.Errorf("Long header did not arrive intact")
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Trailer", "X-Checksum")
+		w.Write(body)
+		w.Header().Set("X-Checksum", "ok")
+		w.Header().Set(http.TrailerPrefix+"X-Extra", "more")
+	})
+
+	req, _ := http.NewRequest(http.MethodPost, url+"/echo", bytes.NewReader(payload))
+	req.Header.Set("X-Long", longHeader)
+	req.Header.Set("Connection", "close")
+	resp, err := (&Transport{}).RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK || resp.Proto != "HTTP/2.0" {
+		t.Errorf("Expected 200 over HTTP/2.0, got %d over %s", resp.StatusCode, resp.Proto)
+	}
+	if !bytes.Equal(body, payload) {
+		t.Errorf("Expected the %d byte payload echoed, got %d bytes", len(payload), len(body))
+	}
+	if got := resp.Header.Get("Content-Type"); got != "application/octet-stream" {
+		t.Errorf("Expected the content type, got %q", got)
+	}
+	if resp.Trailer.Get("X-Checksum") != "ok" || resp.Trailer.Get("X-Extra") != "more" {
+		t.Errorf("Expected both trailers, got %v", resp.Trailer)
+	}
+}
+
+func TestRoundTripHeadersOnly(t *testing.T) {
+	url := startServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Grpc-Status", "12")
+		w.WriteHeader(http.StatusOK)
+	})
+	req, _ := http.NewRequest(http.MethodGet, url+"/", nil)
+	resp, err := (&Transport{}).RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	if resp.Header.Get("Grpc-Status") != "12" || resp.ContentLength != 0 {
+		t.Errorf("Unexpected response %v", resp.Header)
+	}
+}
+
+func TestRoundTripErrors(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	if _, err := (&Transport{}).RoundTrip(req); err == nil {
+		t.Error("Expected https to be refused")
+	}
+
+	// A server that accepts but never answers.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		nc, err := l.Accept()
+		if err == nil {
+			defer nc.Close()
+			io.Copy(io.Discard, nc)
+		}
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	req, _ = http.NewRequestWithContext(ctx, http.MethodGet, "http://"+l.Addr().String()+"/", nil)
+	if _, err := (&Transport{}).RoundTrip(req); err != context.DeadlineExceeded {
+		t.Errorf("Expected the deadline to end the request, got %v", err)
+	}
+}
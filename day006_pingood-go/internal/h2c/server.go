@@ -0,0 +1,171 @@
+package h2c
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/hpack"
+)
+
+// Server answers HTTP/2 requests sent with prior knowledge. It is meant
+// for tests and stand-ins: it handles one request at a time per
+// connection and buffers whole requests and responses. Handlers set
+// trailers the way they do with net/http, by announcing them in the
+// "Trailer" header or with http.TrailerPrefix.
+type Server struct {
+	Handler http.Handler
+}
+
+// Serve accepts connections on l until accepting fails, for example
+// because l was closed.
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		nc, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go s.ServeConn(nc)
+	}
+}
+
+// ServeConn serves one connection until the client closes it.
+func (s *Server) ServeConn(nc net.Conn) {
+	c := newConn(nc, true)
+	defer nc.Close()
+
+	start := make([]byte, len(preface))
+	if _, err := io.ReadFull(c.r, start); err != nil || string(start) != preface {
+		return
+	}
+	if err := c.writeSettings(); err != nil {
+		return
+	}
+	for {
+		if err := c.step(); err != nil {
+			return
+		}
+		for _, id := range c.endedStreams() {
+			err := s.respond(c, c.streams[id])
+			delete(c.streams, id)
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+// endedStreams returns the streams whose request is complete, oldest
+// first.
+func (c *conn) endedStreams() []uint32 {
+	var ids []uint32
+	for id, st := range c.streams {
+		if st.ended && st.err == nil {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func (s *Server) respond(c *conn, st *stream) error {
+	path := fieldValue(st.header, ":path")
+	authority := fieldValue(st.header, ":authority")
+	req, err := http.NewRequestWithContext(context.Background(), fieldValue(st.header, ":method"), "http://"+authority+path, bytes.NewReader(st.data.Bytes()))
+	if err != nil {
+		c.writeFrame(frameRSTStream, 0, st.id, []byte{0, 0, 0, 1})
+		return c.w.Flush()
+	}
+	req.Proto, req.ProtoMajor, req.ProtoMinor = "HTTP/2.0", 2, 0
+	req.Header = headerOf(st.header)
+	req.ContentLength = int64(st.data.Len())
+	req.RemoteAddr = c.nc.RemoteAddr().String()
+	req.RequestURI = path
+
+	w := &responseWriter{header: http.Header{}}
+	s.Handler.ServeHTTP(w, req)
+	w.WriteHeader(http.StatusOK)
+
+	fields := []hpack.HeaderField{{Name: ":status", Value: strconv.Itoa(w.status)}}
+	fields = appendHeader(fields, w.sent, true)
+	trailer := w.trailer()
+	body := w.body.Bytes()
+
+	if err := c.writeHeaders(st.id, fields, len(body) == 0 && len(trailer) == 0); err != nil {
+		return err
+	}
+	if len(body) > 0 {
+		if err := c.writeData(st, body, len(trailer) == 0); err != nil {
+			return err
+		}
+	}
+	if len(trailer) > 0 {
+		return c.writeHeaders(st.id, appendHeader(nil, trailer, false), true)
+	}
+	return nil
+}
+
+func appendHeader(fields []hpack.HeaderField, h http.Header, skipTrailer bool) []hpack.HeaderField {
+	for name, values := range h {
+		name = strings.ToLower(name)
+		if hopHeaders[name] || (skipTrailer && name == "trailer") || strings.HasPrefix(name, strings.ToLower(http.TrailerPrefix)) {
+			continue
+		}
+		for _, v := range values {
+			fields = append(fields, hpack.HeaderField{Name: name, Value: v})
+		}
+	}
+	return fields
+}
+
+type responseWriter struct {
+	header http.Header
+	// sent is the header as it was when the status was written.
+	sent   http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *responseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.sent != nil {
+		return
+	}
+	w.status = status
+	w.sent = w.header.Clone()
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(b)
+}
+
+// trailer collects the trailers announced in the "Trailer" header and
+// those set with http.TrailerPrefix.
+func (w *responseWriter) trailer() http.Header {
+	trailer := http.Header{}
+	for _, names := range w.sent.Values("Trailer") {
+		for _, name := range strings.Split(names, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			for _, v := range w.header.Values(name) {
+				trailer.Add(name, v)
+			}
+		}
+	}
+	for name, values := range w.header {
+		if key, ok := strings.CutPrefix(name, http.TrailerPrefix); ok {
+			for _, v := range values {
+				trailer.Add(key, v)
+			}
+		}
+	}
+	return trailer
+}
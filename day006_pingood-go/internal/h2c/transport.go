@@ -0,0 +1,159 @@
+package h2c
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/hpack"
+)
+
+// Transport is an http.RoundTripper that sends each request over HTTP/2
+// on a new cleartext TCP connection. The whole response, trailers
+// included, is read before RoundTrip returns. It ignores proxies.
+type Transport struct {
+	// DialContext dials the connection; nil means net.Dialer.
+	DialContext func(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		defer req.Body.Close()
+	}
+	if req.URL.Scheme != "http" {
+		return nil, fmt.Errorf("h2c requires http, got %q", req.URL.Scheme)
+	}
+	ctx := req.Context()
+	port := req.URL.Port()
+	if port == "" {
+		port = "80"
+	}
+
+	var content []byte
+	if req.Body != nil {
+		var err error
+		if content, err = io.ReadAll(req.Body); err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+	}
+
+	dial := t.DialContext
+	if dial == nil {
+		var d net.Dialer
+		dial = d.DialContext
+	}
+	nc, err := dial(ctx, "tcp", net.JoinHostPort(req.URL.Hostname(), port))
+	if err != nil {
+		return nil, err
+	}
+	stop := context.AfterFunc(ctx, func() { nc.Close() })
+	defer stop()
+
+	c := newConn(nc, false)
+	defer c.close()
+	s, err := c.request(req, content)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+
+	status, err := strconv.Atoi(fieldValue(s.header, ":status"))
+	if err != nil {
+		return nil, fmt.Errorf("h2c: invalid :status in response")
+	}
+	resp := &http.Response{
+		Status:        strconv.Itoa(status) + " " + http.StatusText(status),
+		StatusCode:    status,
+		Proto:         "HTTP/2.0",
+		ProtoMajor:    2,
+		Header:        headerOf(s.header),
+		Trailer:       headerOf(s.trailer),
+		ContentLength: int64(s.data.Len()),
+		Request:       req,
+		Body:          io.NopCloser(bytes.NewReader(s.data.Bytes())),
+	}
+	return resp, nil
+}
+
+// request sends req on stream 1 and reads until the response ends.
+func (c *conn) request(req *http.Request, content []byte) (*stream, error) {
+	if _, err := c.w.WriteString(preface); err != nil {
+		return nil, err
+	}
+	if err := c.writeSettings(); err != nil {
+		return nil, err
+	}
+
+	authority := req.Host
+	if authority == "" {
+		authority = req.URL.Host
+	}
+	fields := []hpack.HeaderField{
+		{Name: ":method", Value: req.Method},
+		{Name: ":scheme", Value: "http"},
+		{Name: ":authority", Value: authority},
+		{Name: ":path", Value: req.URL.RequestURI()},
+	}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if hopHeaders[name] {
+			continue
+		}
+		for _, v := range values {
+			// TE may only say that trailers are welcome.
+			if name == "te" && v != "trailers" {
+				continue
+			}
+			fields = append(fields, hpack.HeaderField{Name: name, Value: v})
+		}
+	}
+	if len(content) > 0 {
+		fields = append(fields, hpack.HeaderField{Name: "content-length", Value: strconv.Itoa(len(content))})
+	}
+
+	s := c.open(1)
+	if err := c.writeHeaders(s.id, fields, len(content) == 0); err != nil {
+		return nil, err
+	}
+	if len(content) > 0 {
+		if err := c.writeData(s, content, true); err != nil {
+			return nil, err
+		}
+	}
+	for !s.ended && s.err == nil {
+		if err := c.step(); err != nil {
+			return nil, fmt.Errorf("failed to read response: %w", err)
+		}
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s, nil
+}
+
+func fieldValue(fields []hpack.HeaderField, name string) string {
+	for _, f := range fields {
+		if f.Name == name {
+			return f.Value
+		}
+	}
+	return ""
+}
+
+// headerOf turns the regular fields of a header block into an http.Header.
+func headerOf(fields []hpack.HeaderField) http.Header {
+	h := http.Header{}
+	for _, f := range fields {
+		if !strings.HasPrefix(f.Name, ":") {
+			h.Add(f.Name, f.Value)
+		}
+	}
+	return h
+}
@@ -0,0 +1,957 @@
+package h3
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Frame types (RFC 9000, Section 19).
+const (
+	framePadding            = 0x00
+	framePing               = 0x01
+	frameAck                = 0x02
+	frameAckECN             = 0x03
+	frameResetStream        = 0x04
+	frameStopSending        = 0x05
+	frameCrypto             = 0x06
+	frameNewToken           = 0x07
+	frameStream             = 0x08 // 0x08-0x0f: OFF, LEN and FIN bits
+	frameMaxData            = 0x10
+	frameMaxStreamData      = 0x11
+	frameMaxStreamsBidi     = 0x12
+	frameMaxStreamsUni      = 0x13
+	frameDataBlocked        = 0x14
+	frameStreamDataBlocked  = 0x15
+	frameStreamsBlockedBidi = 0x16
+	frameStreamsBlockedUni  = 0x17
+	frameNewConnectionID    = 0x18
+	frameRetireConnectionID = 0x19
+	framePathChallenge      = 0x1a
+	framePathResponse       = 0x1b
+	frameConnectionClose    = 0x1c
+	frameApplicationClose   = 0x1d
+	frameHandshakeDone      = 0x1e
+)
+
+// Flow control limits we grant the peer. They are never raised, which is
+// plenty for one diagnostic request.
+const (
+	maxConnectionData = 16 << 20
+	maxStreamData     = 16 << 20
+	maxPeerStreams    = 100
+	idleTimeout       = 30 * time.Second
+)
+
+// errNoError is the HTTP/3 application error code for a clean close.
+const errNoError = 0x100
+
+// frame is a frame that has to be retransmitted if its packet is lost.
+type frame struct {
+	typ    byte
+	stream uint64
+	offset uint64
+	data   []byte
+	fin    bool
+}
+
+// maxFrameOverhead is the most bytes a CRYPTO or STREAM frame adds around
+// its data.
+const maxFrameOverhead = 1 + 8 + 8 + 8
+
+func (f frame) append(b []byte) []byte {
+	switch f.typ {
+	case frameCrypto:
+		b = append(b, frameCrypto)
+		b = appendVarint(b, f.offset)
+		b = appendVarint(b, uint64(len(f.data)))
+		return append(b, f.data...)
+	case frameStream:
+		typ := byte(frameStream | 0x04 | 0x02) // OFF and LEN
+		if f.fin {
+			typ |= 0x01
+		}
+		b = append(b, typ)
+		b = appendVarint(b, f.stream)
+		b = appendVarint(b, f.offset)
+		b = appendVarint(b, uint64(len(f.data)))
+		return append(b, f.data...)
+	default:
+		return append(b, f.typ)
+	}
+}
+
+// split cuts f so it fits in room bytes, returning the part to send now
+// and the rest, if any.
+func (f frame) split(room int) (frame, *frame, bool) {
+	if f.typ != frameCrypto && f.typ != frameStream {
+		return f, nil, room >= 1
+	}
+	n := room - maxFrameOverhead
+	if n <= 0 {
+		return f, nil, false
+	}
+	if n >= len(f.data) {
+		return f, nil, true
+	}
+	rest := frame{typ: f.typ, stream: f.stream, offset: f.offset + uint64(n), data: f.data[n:], fin: f.fin}
+	f.data, f.fin = f.data[:n], false
+	return f, &rest, true
+}
+
+type sentPacket struct {
+	frames []frame
+	time   time.Time
+}
+
+// rangeSet holds the packet numbers received in a space, as sorted
+// disjoint inclusive ranges.
+type rangeSet [][2]uint64
+
+func (r *rangeSet) add(pn uint64) {
+	s := *r
+	i := sort.Search(len(s), func(i int) bool { return s[i][1]+1 >= pn })
+	switch {
+	case i < len(s) && s[i][0] <= pn && pn <= s[i][1]:
+		return
+	case i < len(s) && s[i][1]+1 == pn:
+		s[i][1] = pn
+		if i+1 < len(s) && s[i+1][0] == pn+1 {
+			s[i][1] = s[i+1][1]
+			s = append(s[:i+1], s[i+2:]...)
+		}
+	case i < len(s) && s[i][0] == pn+1:
+		s[i][0] = pn
+	default:
+		s = append(s, [2]uint64{})
+		copy(s[i+1:], s[i:])
+		s[i] = [2]uint64{pn, pn}
+	}
+	// Old ranges only make ACK frames longer.
+	if len(s) > 32 {
+		s = s[len(s)-32:]
+	}
+	*r = s
+}
+
+func (r rangeSet) contains(pn uint64) bool {
+	for _, rng := range r {
+		if rng[0] <= pn && pn <= rng[1] {
+			return true
+		}
+	}
+	return false
+}
+
+func appendAck(b []byte, r rangeSet) []byte {
+	last := r[len(r)-1]
+	b = append(b, frameAck)
+	b = appendVarint(b, last[1])
+	b = appendVarint(b, 0)
+	b = appendVarint(b, uint64(len(r)-1))
+	b = appendVarint(b, last[1]-last[0])
+	for i := len(r) - 2; i >= 0; i-- {
+		b = appendVarint(b, r[i+1][0]-r[i][1]-2)
+		b = appendVarint(b, r[i][1]-r[i][0])
+	}
+	return b
+}
+
+// recvBuffer reassembles data that may arrive out of order.
+type recvBuffer struct {
+	offset  uint64
+	pending map[uint64][]byte
+}
+
+// push stores data at offset and returns whatever became contiguous.
+func (r *recvBuffer) push(offset uint64, data []byte) []byte {
+	if r.pending == nil {
+		r.pending = map[uint64][]byte{}
+	}
+	if end := offset + uint64(len(data)); end > r.offset {
+		if existing, ok := r.pending[offset]; !ok || len(existing) < len(data) {
+			r.pending[offset] = append([]byte(nil), data...)
+		}
+	}
+
+	var out []byte
+	for progress := true; progress; {
+		progress = false
+		for off, d := range r.pending {
+			if off > r.offset {
+				continue
+			}
+			delete(r.pending, off)
+			if end := off + uint64(len(d)); end > r.offset {
+				out = append(out, d[r.offset-off:]...)
+				r.offset = end
+				progress = true
+			}
+		}
+	}
+	return out
+}
+
+// space is one packet number space and its encryption level.
+type space struct {
+	seal, open  *keys
+	nextPN      uint64
+	largestRecv int64
+	received    rangeSet
+	ackNeeded   bool
+	sent        map[uint64]*sentPacket
+	pending     []frame
+	cryptoSent  uint64
+	crypto      recvBuffer
+	discarded   bool
+}
+
+// stream is one QUIC stream. Reads block on the connection's condition
+// variable.
+type stream struct {
+	c         *conn
+	id        uint64
+	recv      recvBuffer
+	buf       []byte
+	finalSize int64
+	sendOff   uint64
+}
+
+func (s *stream) Read(p []byte) (int, error) {
+	c := s.c
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(s.buf) == 0 {
+		if s.finalSize >= 0 && s.recv.offset == uint64(s.finalSize) {
+			return 0, io.EOF
+		}
+		if c.err != nil {
+			return 0, c.err
+		}
+		c.cond.Wait()
+	}
+	n := copy(p, s.buf)
+	s.buf = s.buf[n:]
+	return n, nil
+}
+
+// write queues data on the stream, ending it if fin is set.
+func (s *stream) write(data []byte, fin bool) error {
+	c := s.c
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	c.spaces[levelApplication].pending = append(c.spaces[levelApplication].pending,
+		frame{typ: frameStream, stream: s.id, offset: s.sendOff, data: append([]byte(nil), data...), fin: fin})
+	s.sendOff += uint64(len(data))
+	c.flush()
+	return nil
+}
+
+// conn is one end of a QUIC connection. A single mutex guards all of its
+// state; incoming datagrams, the loss timer and stream calls all take it.
+type conn struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	isClient bool
+	send     func([]byte) error
+	tls      *tls.QUICConn
+
+	srcID, dstID, origDstID []byte
+	token                   []byte
+	gotPeerID, retried      bool
+	handshakeComplete       bool
+
+	spaces  [levelCount]space
+	streams map[uint64]*stream
+	// nextStream holds the next ID of our own bidirectional and
+	// unidirectional streams.
+	nextStream [2]uint64
+
+	srtt     time.Duration
+	ptoCount int
+	lastSent time.Time
+	timer    *time.Timer
+	// early holds 1-RTT packets that arrived before their keys.
+	early [][]byte
+
+	err error
+	// onClose runs once when the connection ends.
+	onClose func()
+}
+
+func newConn(isClient bool, send func([]byte) error) *conn {
+	c := &conn{isClient: isClient, send: send, streams: map[uint64]*stream{}}
+	c.cond = sync.NewCond(&c.mu)
+	for i := range c.spaces {
+		c.spaces[i].largestRecv = -1
+		c.spaces[i].sent = map[uint64]*sentPacket{}
+	}
+	if isClient {
+		c.nextStream = [2]uint64{0, 2}
+	} else {
+		c.nextStream = [2]uint64{1, 3}
+	}
+	c.timer = time.AfterFunc(time.Hour, c.onTimer)
+	return c
+}
+
+func newConnectionID() []byte {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return id
+}
+
+func (c *conn) transportParameters() []byte {
+	var b []byte
+	param := func(id uint64, value []byte) {
+		b = appendVarint(b, id)
+		b = appendVarint(b, uint64(len(value)))
+		b = append(b, value...)
+	}
+	integer := func(id, v uint64) {
+		param(id, appendVarint(nil, v))
+	}
+	if !c.isClient {
+		param(0x00, c.origDstID)
+	}
+	integer(0x01, uint64(idleTimeout/time.Millisecond))
+	integer(0x04, maxConnectionData)
+	integer(0x05, maxStreamData)
+	integer(0x06, maxStreamData)
+	integer(0x07, maxStreamData)
+	if !c.isClient {
+		integer(0x08, maxPeerStreams)
+	}
+	integer(0x09, maxPeerStreams)
+	param(0x0f, c.srcID)
+	return b
+}
+
+// start begins the TLS handshake; the lock must be held.
+func (c *conn) start(ctx context.Context, config *tls.Config) error {
+	config = config.Clone()
+	config.MinVersion = tls.VersionTLS13
+	config.NextProtos = []string{"h3"}
+	if c.isClient {
+		c.tls = tls.QUICClient(&tls.QUICConfig{TLSConfig: config})
+	} else {
+		c.tls = tls.QUICServer(&tls.QUICConfig{TLSConfig: config})
+	}
+	c.tls.SetTransportParameters(c.transportParameters())
+	c.spaces[levelInitial].seal, c.spaces[levelInitial].open = initialKeys(c.origDstID, c.isClient)
+	if err := c.tls.Start(ctx); err != nil {
+		return fmt.Errorf("failed to start TLS handshake: %w", err)
+	}
+	if err := c.handleTLSEvents(); err != nil {
+		return err
+	}
+	c.flush()
+	return nil
+}
+
+func levelOf(level tls.QUICEncryptionLevel) int {
+	switch level {
+	case tls.QUICEncryptionLevelInitial:
+		return levelInitial
+	case tls.QUICEncryptionLevelHandshake:
+		return levelHandshake
+	case tls.QUICEncryptionLevelApplication:
+		return levelApplication
+	}
+	return -1
+}
+
+func (c *conn) handleTLSEvents() error {
+	for {
+		e := c.tls.NextEvent()
+		level := levelOf(e.Level)
+		switch e.Kind {
+		case tls.QUICNoEvent:
+			return nil
+		case tls.QUICSetReadSecret, tls.QUICSetWriteSecret:
+			if level < 0 {
+				continue
+			}
+			k, err := newKeys(e.Suite, e.Data)
+			if err != nil {
+				return err
+			}
+			if e.Kind == tls.QUICSetReadSecret {
+				c.spaces[level].open = k
+			} else {
+				c.spaces[level].seal = k
+			}
+		case tls.QUICWriteData:
+			sp := &c.spaces[level]
+			sp.pending = append(sp.pending, frame{typ: frameCrypto, offset: sp.cryptoSent, data: append([]byte(nil), e.Data...)})
+			sp.cryptoSent += uint64(len(e.Data))
+		case tls.QUICHandshakeDone:
+			c.handshakeComplete = true
+			if !c.isClient {
+				c.spaces[levelApplication].pending = append(c.spaces[levelApplication].pending, frame{typ: frameHandshakeDone})
+				c.openControlStream()
+			}
+			c.cond.Broadcast()
+		}
+	}
+}
+
+// openControlStream sends the HTTP/3 control stream with empty SETTINGS.
+func (c *conn) openControlStream() {
+	id := c.nextStream[1]
+	c.nextStream[1] += 4
+	data := appendVarint(nil, streamTypeControl)
+	data = appendH3Frame(data, h3FrameSettings, nil)
+	c.spaces[levelApplication].pending = append(c.spaces[levelApplication].pending, frame{typ: frameStream, stream: id, data: data})
+	c.streams[id] = &stream{c: c, id: id, finalSize: -1, sendOff: uint64(len(data))}
+}
+
+// openStream opens a bidirectional stream; the lock must not be held.
+func (c *conn) openStream() *stream {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := &stream{c: c, id: c.nextStream[0], finalSize: -1}
+	c.nextStream[0] += 4
+	c.streams[s.id] = s
+	return s
+}
+
+// handleDatagram processes every packet in a datagram; the lock must be
+// held. It returns streams the peer opened.
+func (c *conn) handleDatagram(b []byte) []*stream {
+	opened := c.handlePackets(b)
+	// The handshake may just have produced the keys for 1-RTT packets
+	// that overtook it.
+	if c.spaces[levelApplication].open != nil {
+		early := c.early
+		c.early = nil
+		for _, datagram := range early {
+			opened = append(opened, c.handlePackets(datagram)...)
+		}
+	}
+	c.flush()
+	return opened
+}
+
+func (c *conn) handlePackets(b []byte) []*stream {
+	var opened []*stream
+	for len(b) > 0 && c.err == nil {
+		n, streams, err := c.handlePacket(b)
+		opened = append(opened, streams...)
+		if errors.Is(err, errPeerClosed) {
+			c.fail(err)
+		} else if err != nil {
+			if !errors.Is(err, errUndecryptable) && !errors.Is(err, errMalformed) {
+				c.close(err)
+			}
+			break
+		}
+		b = b[n:]
+	}
+	return opened
+}
+
+func (c *conn) handlePacket(b []byte) (int, []*stream, error) {
+	if b[0]&0x80 == 0 {
+		sp := &c.spaces[levelApplication]
+		if sp.open == nil {
+			if len(c.early) < 8 {
+				c.early = append(c.early, append([]byte(nil), b...))
+			}
+			return len(b), nil, nil
+		}
+		if len(b) < 1+len(c.srcID) {
+			return 0, nil, errMalformed
+		}
+		pn, payload, err := sp.open.open(b, 1+len(c.srcID), sp.largestRecv)
+		if err != nil {
+			return 0, nil, err
+		}
+		streams, err := c.handlePayload(levelApplication, pn, payload)
+		return len(b), streams, err
+	}
+
+	p, err := parseLongHeader(b)
+	if err != nil {
+		return 0, nil, err
+	}
+	switch {
+	case p.version == 0:
+		if c.isClient && !c.gotPeerID {
+			return 0, nil, errors.New("server does not support QUIC version 1")
+		}
+		return p.end, nil, nil
+	case p.version != quicVersion1:
+		return p.end, nil, nil
+	case p.typ == packetRetry:
+		return p.end, nil, c.handleRetry(b, p)
+	case p.typ == packetZeroRTT:
+		return p.end, nil, nil
+	}
+
+	level := levelInitial
+	if p.typ == packetHandshake {
+		level = levelHandshake
+	}
+	sp := &c.spaces[level]
+	if sp.open == nil || sp.discarded {
+		return p.end, nil, nil
+	}
+	pn, payload, err := sp.open.open(b[:p.end], p.pnOffset, sp.largestRecv)
+	if err != nil {
+		return p.end, nil, nil
+	}
+	if c.isClient && !c.gotPeerID {
+		c.dstID = append([]byte(nil), p.srcID...)
+		c.gotPeerID = true
+	}
+	if !c.isClient && level == levelHandshake {
+		c.discard(levelInitial)
+	}
+	streams, err := c.handlePayload(level, pn, payload)
+	return p.end, streams, err
+}
+
+func (c *conn) handleRetry(b []byte, p longPacket) error {
+	if !c.isClient || c.retried || c.gotPeerID || len(p.token) <= 16 || !verifyRetry(b[:p.end], c.origDstID) {
+		return nil
+	}
+	c.retried = true
+	c.token = append([]byte(nil), p.token[:len(p.token)-16]...)
+	c.dstID = append([]byte(nil), p.srcID...)
+
+	// Resend the ClientHello under keys derived from the new ID.
+	sp := &c.spaces[levelInitial]
+	sp.seal, sp.open = initialKeys(c.dstID, true)
+	c.requeue(levelInitial)
+	return nil
+}
+
+func (c *conn) handlePayload(level int, pn uint64, payload []byte) ([]*stream, error) {
+	sp := &c.spaces[level]
+	if int64(pn) > sp.largestRecv {
+		sp.largestRecv = int64(pn)
+	}
+	if sp.received.contains(pn) {
+		return nil, nil
+	}
+	sp.received.add(pn)
+
+	var opened []*stream
+	for len(payload) > 0 {
+		typ, n := readVarint(payload)
+		if n == 0 {
+			return nil, errMalformed
+		}
+		payload = payload[n:]
+		if typ != framePadding && typ != frameAck && typ != frameAckECN && typ != frameConnectionClose && typ != frameApplicationClose {
+			sp.ackNeeded = true
+		}
+
+		var fields []uint64
+		read := func(count int) bool {
+			for i := 0; i < count; i++ {
+				v, n := readVarint(payload)
+				if n == 0 {
+					return false
+				}
+				fields = append(fields, v)
+				payload = payload[n:]
+			}
+			return true
+		}
+		take := func(length uint64) []byte {
+			if uint64(len(payload)) < length {
+				return nil
+			}
+			data := payload[:length]
+			payload = payload[length:]
+			return data
+		}
+
+		switch {
+		case typ == framePadding, typ == framePing, typ == frameHandshakeDone:
+			if typ == frameHandshakeDone && c.isClient {
+				c.discard(levelHandshake)
+			}
+		case typ == frameAck || typ == frameAckECN:
+			if !read(4) || fields[3] > fields[0] {
+				return nil, errMalformed
+			}
+			smallest := fields[0] - fields[3]
+			ranges := [][2]uint64{{smallest, fields[0]}}
+			for i := uint64(0); i < fields[2]; i++ {
+				if !read(2) {
+					return nil, errMalformed
+				}
+				gap, length := fields[len(fields)-2], fields[len(fields)-1]
+				if smallest < gap+2 || smallest-gap-2 < length {
+					return nil, errMalformed
+				}
+				largest := smallest - gap - 2
+				smallest = largest - length
+				ranges = append(ranges, [2]uint64{smallest, largest})
+			}
+			if typ == frameAckECN && !read(3) {
+				return nil, errMalformed
+			}
+			c.handleAck(level, fields[0], ranges)
+		case typ == frameCrypto:
+			if !read(2) {
+				return nil, errMalformed
+			}
+			data := take(fields[1])
+			if data == nil && fields[1] > 0 {
+				return nil, errMalformed
+			}
+			if in := sp.crypto.push(fields[0], data); len(in) > 0 {
+				if err := c.tls.HandleData(tlsLevel(level), in); err != nil {
+					return nil, fmt.Errorf("TLS handshake failed: %w", err)
+				}
+				if err := c.handleTLSEvents(); err != nil {
+					return nil, err
+				}
+			}
+		case typ >= frameStream && typ <= frameStream|0x07:
+			if !read(1) {
+				return nil, errMalformed
+			}
+			id, offset := fields[0], uint64(0)
+			if typ&0x04 != 0 {
+				if !read(1) {
+					return nil, errMalformed
+				}
+				offset = fields[1]
+			}
+			length := uint64(len(payload))
+			if typ&0x02 != 0 {
+				if !read(1) {
+					return nil, errMalformed
+				}
+				length = fields[len(fields)-1]
+			}
+			data := take(length)
+			if data == nil && length > 0 {
+				return nil, errMalformed
+			}
+			if s := c.receiveStream(id, offset, data, typ&0x01 != 0); s != nil {
+				opened = append(opened, s)
+			}
+		case typ == frameResetStream:
+			if !read(3) {
+				return nil, errMalformed
+			}
+			if s, ok := c.streams[fields[0]]; ok {
+				s.finalSize = int64(s.recv.offset)
+				s.buf = nil
+				c.cond.Broadcast()
+			}
+		case typ == frameStopSending, typ == frameMaxStreamData, typ == frameStreamDataBlocked:
+			if !read(2) {
+				return nil, errMalformed
+			}
+		case typ == frameNewToken:
+			if !read(1) || take(fields[0]) == nil {
+				return nil, errMalformed
+			}
+		case typ >= frameMaxData && typ <= frameStreamsBlockedUni, typ == frameRetireConnectionID:
+			if !read(1) {
+				return nil, errMalformed
+			}
+		case typ == frameNewConnectionID:
+			if !read(2) || len(payload) < 1 || take(uint64(payload[0])+1+16) == nil {
+				return nil, errMalformed
+			}
+		case typ == framePathChallenge, typ == framePathResponse:
+			if take(8) == nil {
+				return nil, errMalformed
+			}
+		case typ == frameConnectionClose, typ == frameApplicationClose:
+			count := 2
+			if typ == frameConnectionClose {
+				count = 3
+			}
+			if !read(count) {
+				return nil, errMalformed
+			}
+			reason := string(take(fields[count-1]))
+			if typ == frameApplicationClose && fields[0] == errNoError {
+				return opened, errPeerClosed
+			}
+			return opened, fmt.Errorf("%w: error 0x%x %s", errPeerClosed, fields[0], reason)
+		default:
+			return nil, fmt.Errorf("unknown frame type 0x%x", typ)
+		}
+	}
+	return opened, nil
+}
+
+func tlsLevel(level int) tls.QUICEncryptionLevel {
+	switch level {
+	case levelHandshake:
+		return tls.QUICEncryptionLevelHandshake
+	case levelApplication:
+		return tls.QUICEncryptionLevelApplication
+	}
+	return tls.QUICEncryptionLevelInitial
+}
+
+// receiveStream stores stream data and returns the stream if the peer
+// just opened it as a bidirectional stream.
+func (c *conn) receiveStream(id, offset uint64, data []byte, fin bool) *stream {
+	s, ok := c.streams[id]
+	var opened *stream
+	if !ok {
+		peerInitiated := (id&0x01 == 0) != c.isClient
+		if !peerInitiated {
+			return nil
+		}
+		s = &stream{c: c, id: id, finalSize: -1}
+		c.streams[id] = s
+		if id&0x02 == 0 {
+			opened = s
+		}
+	}
+	if fin {
+		s.finalSize = int64(offset) + int64(len(data))
+	}
+	in := s.recv.push(offset, data)
+	// Unidirectional streams from the peer carry SETTINGS and QPACK
+	// instructions we have no use for.
+	if id&0x02 == 0 {
+		s.buf = append(s.buf, in...)
+	}
+	c.cond.Broadcast()
+	return opened
+}
+
+func (c *conn) handleAck(level int, largest uint64, ranges [][2]uint64) {
+	sp := &c.spaces[level]
+	if p, ok := sp.sent[largest]; ok {
+		rtt := time.Since(p.time)
+		if c.srtt == 0 {
+			c.srtt = rtt
+		} else {
+			c.srtt = (7*c.srtt + rtt) / 8
+		}
+	}
+	for pn := range sp.sent {
+		for _, r := range ranges {
+			if r[0] <= pn && pn <= r[1] {
+				delete(sp.sent, pn)
+				c.ptoCount = 0
+				break
+			}
+		}
+	}
+}
+
+// discard drops a space once its keys are no longer needed.
+func (c *conn) discard(level int) {
+	c.spaces[level] = space{discarded: true, largestRecv: -1, sent: map[uint64]*sentPacket{}}
+}
+
+// requeue moves the frames of every unacknowledged packet in level back
+// to the front of the send queue.
+func (c *conn) requeue(level int) {
+	sp := &c.spaces[level]
+	pns := make([]uint64, 0, len(sp.sent))
+	for pn := range sp.sent {
+		pns = append(pns, pn)
+	}
+	sort.Slice(pns, func(i, j int) bool { return pns[i] < pns[j] })
+	var frames []frame
+	for _, pn := range pns {
+		frames = append(frames, sp.sent[pn].frames...)
+	}
+	sp.pending = append(frames, sp.pending...)
+	sp.sent = map[uint64]*sentPacket{}
+}
+
+func (c *conn) pto() time.Duration {
+	pto := time.Second
+	if c.srtt > 0 {
+		pto = 3*c.srtt + 25*time.Millisecond
+	}
+	return pto << c.ptoCount
+}
+
+func (c *conn) onTimer() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+	outstanding := false
+	for level := range c.spaces {
+		if len(c.spaces[level].sent) > 0 {
+			outstanding = true
+			c.requeue(level)
+		}
+	}
+	if !outstanding {
+		return
+	}
+	c.ptoCount++
+	c.flush()
+}
+
+// flush sends everything queued; the lock must be held.
+func (c *conn) flush() {
+	if c.err != nil {
+		return
+	}
+	for level := range c.spaces {
+		sp := &c.spaces[level]
+		for sp.seal != nil && !sp.discarded && (sp.ackNeeded || len(sp.pending) > 0) {
+			sent, err := c.sendPacket(level)
+			if err != nil {
+				c.fail(err)
+				return
+			}
+			if !sent {
+				break
+			}
+		}
+	}
+
+	outstanding := false
+	for level := range c.spaces {
+		outstanding = outstanding || len(c.spaces[level].sent) > 0
+	}
+	if outstanding {
+		c.timer.Reset(time.Until(c.lastSent.Add(c.pto())))
+	}
+}
+
+// sendPacket sends one packet from level's queue and reports whether
+// there was anything to send.
+func (c *conn) sendPacket(level int) (bool, error) {
+	sp := &c.spaces[level]
+	room := maxDatagram - c.headerLen(level) - 16
+
+	var payload []byte
+	if sp.ackNeeded && len(sp.received) > 0 {
+		payload = appendAck(payload, sp.received)
+	}
+	sp.ackNeeded = false
+	var sent []frame
+	for len(sp.pending) > 0 {
+		now, rest, ok := sp.pending[0].split(room - len(payload))
+		if !ok {
+			break
+		}
+		payload = now.append(payload)
+		sent = append(sent, now)
+		if rest != nil {
+			sp.pending[0] = *rest
+			break
+		}
+		sp.pending = sp.pending[1:]
+	}
+	if len(payload) == 0 {
+		return false, nil
+	}
+	if level == levelInitial {
+		// Datagrams with Initial packets are padded so the path is known
+		// to carry full-sized QUIC packets.
+		payload = append(payload, make([]byte, room-len(payload))...)
+	}
+
+	pn, packet := c.protect(level, payload)
+	if err := c.send(packet); err != nil {
+		return false, fmt.Errorf("failed to send packet: %w", err)
+	}
+	if len(sent) > 0 {
+		c.lastSent = time.Now()
+		sp.sent[pn] = &sentPacket{frames: sent, time: c.lastSent}
+	}
+	if c.isClient && level == levelHandshake && !c.spaces[levelInitial].discarded {
+		c.discard(levelInitial)
+	}
+	return true, nil
+}
+
+func (c *conn) headerLen(level int) int {
+	switch level {
+	case levelInitial:
+		return longHeaderLen(packetInitial, c.dstID, c.srcID, c.token)
+	case levelHandshake:
+		return longHeaderLen(packetHandshake, c.dstID, c.srcID, nil)
+	}
+	return 1 + len(c.dstID) + pnLength
+}
+
+// protect numbers payload as the next packet of level and seals it.
+func (c *conn) protect(level int, payload []byte) (uint64, []byte) {
+	sp := &c.spaces[level]
+	pn := sp.nextPN
+	sp.nextPN++
+	var header []byte
+	var pnOffset int
+	switch level {
+	case levelInitial:
+		header, pnOffset = longHeader(packetInitial, c.dstID, c.srcID, c.token, len(payload), pn)
+	case levelHandshake:
+		header, pnOffset = longHeader(packetHandshake, c.dstID, c.srcID, nil, len(payload), pn)
+	default:
+		header, pnOffset = shortHeader(c.dstID, pn)
+	}
+	return pn, sp.seal.seal(header, pnOffset, pn, payload)
+}
+
+var errPeerClosed = errors.New("connection closed by peer")
+
+// fail ends the connection with err without telling the peer; the lock
+// must be held.
+func (c *conn) fail(err error) {
+	if c.err == nil {
+		c.err = err
+		c.timer.Stop()
+		if c.tls != nil {
+			c.tls.Close()
+		}
+		c.cond.Broadcast()
+		if c.onClose != nil {
+			c.onClose()
+		}
+	}
+}
+
+// close sends CONNECTION_CLOSE at the highest level we have keys for and
+// ends the connection with err; the lock must be held.
+func (c *conn) close(err error) {
+	if c.err != nil {
+		return
+	}
+	for level := levelCount - 1; level >= 0; level-- {
+		if c.spaces[level].seal == nil || c.spaces[level].discarded {
+			continue
+		}
+		var payload []byte
+		if level == levelApplication {
+			payload = append(payload, frameApplicationClose)
+			payload = appendVarint(payload, errNoError)
+		} else {
+			payload = append(payload, frameConnectionClose)
+			payload = appendVarint(payload, 0)
+			payload = appendVarint(payload, 0)
+		}
+		// An empty reason, padded so the header protection sample fits.
+		payload = append(payload, 0, 0, 0, 0)
+		_, packet := c.protect(level, payload)
+		c.send(packet)
+		break
+	}
+	c.fail(err)
+}
@@ -0,0 +1,144 @@
+// Package h3 is a small HTTP/3 client and test server built on a minimal
+// QUIC version 1 implementation (RFC 9000, 9001, 9114 and 9204) and
+// crypto/tls.
+//
+// It exists so pingood can tell whether HTTP/3 works on a network, where
+// the usual failure is UDP/443 being dropped: it makes one request per
+// connection, relies on generous initial flow control limits instead of
+// updating them, only supports AES-GCM cipher suites and uses QPACK
+// without a dynamic table.
+package h3
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// HTTP/3 frame and stream types (RFC 9114, Sections 6.2 and 7.2).
+const (
+	h3FrameData     = 0x00
+	h3FrameHeaders  = 0x01
+	h3FrameSettings = 0x04
+
+	streamTypeControl = 0x00
+
+	maxFieldSection = 64 << 10
+)
+
+func appendH3Frame(b []byte, typ uint64, payload []byte) []byte {
+	b = appendVarint(b, typ)
+	b = appendVarint(b, uint64(len(payload)))
+	return append(b, payload...)
+}
+
+func readVarintFrom(r io.ByteReader) (uint64, error) {
+	first, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	v := uint64(first & 0x3f)
+	for i := 1; i < 1<<(first>>6); i++ {
+		c, err := r.ReadByte()
+		if err != nil {
+			return 0, io.ErrUnexpectedEOF
+		}
+		v = v<<8 | uint64(c)
+	}
+	return v, nil
+}
+
+func readFrameHeader(r *bufio.Reader) (typ, length uint64, err error) {
+	if typ, err = readVarintFrom(r); err != nil {
+		return 0, 0, err
+	}
+	if length, err = readVarintFrom(r); err != nil {
+		return 0, 0, io.ErrUnexpectedEOF
+	}
+	return typ, length, nil
+}
+
+// readHeaders skips to the next HEADERS frame on a request stream and
+// decodes it.
+func readHeaders(r *bufio.Reader) ([]Field, error) {
+	for {
+		typ, length, err := readFrameHeader(r)
+		if err != nil {
+			if err == io.EOF {
+				err = errors.New("stream ended before HEADERS")
+			}
+			return nil, err
+		}
+		if typ != h3FrameHeaders {
+			if _, err := io.CopyN(io.Discard, r, int64(length)); err != nil {
+				return nil, io.ErrUnexpectedEOF
+			}
+			continue
+		}
+		if length > maxFieldSection {
+			return nil, fmt.Errorf("field section of %d bytes is too large", length)
+		}
+		block := make([]byte, length)
+		if _, err := io.ReadFull(r, block); err != nil {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return decodeFields(block)
+	}
+}
+
+// statusOf returns the :status pseudo-header of a response.
+func statusOf(fields []Field) (int, error) {
+	for _, f := range fields {
+		if f.Name == ":status" {
+			status, err := strconv.Atoi(f.Value)
+			if err != nil || status < 100 || status > 999 {
+				return 0, fmt.Errorf("invalid status %q", f.Value)
+			}
+			return status, nil
+		}
+	}
+	return 0, errors.New("response has no :status")
+}
+
+// body reads the payload of the DATA frames on a stream, skipping other
+// frames such as trailers.
+type body struct {
+	r         *bufio.Reader
+	remaining uint64
+	onClose   func()
+}
+
+func (b *body) Read(p []byte) (int, error) {
+	for b.remaining == 0 {
+		typ, length, err := readFrameHeader(b.r)
+		if err != nil {
+			return 0, err
+		}
+		if typ == h3FrameData {
+			b.remaining = length
+			continue
+		}
+		if _, err := io.CopyN(io.Discard, b.r, int64(length)); err != nil {
+			return 0, io.ErrUnexpectedEOF
+		}
+	}
+	if uint64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.r.Read(p)
+	b.remaining -= uint64(n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (b *body) Close() error {
+	if b.onClose != nil {
+		b.onClose()
+		b.onClose = nil
+	}
+	return nil
+}
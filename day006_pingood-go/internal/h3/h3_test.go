@@ -0,0 +1,205 @@
+package h3
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startServer serves handler over HTTP/3 on a loopback UDP port and
+// returns its base URL and a transport that trusts it.
+func startServer(t *testing.T, handler http.Handler) (string, *Transport) {
+	t.Helper()
+	// Borrow the httptest certificate, which is valid for 127.0.0.1.
+	tlsServer := httptest.NewTLSServer(handler)
+	cert := tlsServer.TLS.Certificates[0]
+	roots := x509.NewCertPool()
+	roots.AddCert(tlsServer.Certificate())
+	tlsServer.Close()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &Server{Handler: handler, TLSConfig: &tls.Config{Certificates: []tls.Certificate{cert}}}
+	done := make(chan struct{})
+	go func() {
+		server.Serve(pc)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		pc.Close()
+		<-done
+	})
+	return "https://" + pc.LocalAddr().String(), &Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}
+}
+
+func TestRoundTrip(t *testing.T) {
+	url, transport := startServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Method", r.Method)
+		w.Header().Set("X-Proto", r.Proto)
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		io.WriteString(w, r.Header.Get("X-Test")+":"+string(body))
+	}))
+	client := &http.Client{Transport: transport, Timeout: 5 * time.Second}
+
+	req, _ := http.NewRequest(http.MethodPost, url+"/echo?q=1", strings.NewReader(strings.Repeat("a", 5000)))
+	req.Header.Set("X-Test", "hello")
+	var remote net.Addr
+	req = req.WithContext(httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) { remote = info.Conn.RemoteAddr() },
+	}))
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("Failed to read body: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Proto != "HTTP/3.0" {
+		t.Errorf("Expected 200 over HTTP/3.0, got %d over %s", resp.StatusCode, resp.Proto)
+	}
+	if want := "hello:" + strings.Repeat("a", 5000); string(body) != want {
+		t.Errorf("Unexpected body of %d bytes", len(body))
+	}
+	if resp.Header.Get("X-Method") != "POST" || resp.Header.Get("X-Proto") != "HTTP/3.0" {
+		t.Errorf("Unexpected headers %v", resp.Header)
+	}
+	if resp.TLS == nil || resp.TLS.NegotiatedProtocol != "h3" {
+		t.Errorf("Expected h3 to be negotiated, got %+v", resp.TLS)
+	}
+	if remote == nil || "https://"+remote.String() != url {
+		t.Errorf("Expected GotConn with the server address, got %v", remote)
+	}
+
+	resp, err = client.Get(url + "/missing")
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404, got %d", resp.StatusCode)
+	}
+}
+
+func TestRoundTripErrors(t *testing.T) {
+	url, _ := startServer(t, http.NotFoundHandler())
+	client := &http.Client{Transport: &Transport{}, Timeout: 5 * time.Second}
+	if _, err := client.Get(url); err == nil || !strings.Contains(err.Error(), "certificate") {
+		t.Errorf("Expected an untrusted certificate error, got %v", err)
+	}
+	if _, err := client.Get("http://127.0.0.1/"); err == nil {
+		t.Error("Expected http URLs to be rejected")
+	}
+
+	// Nothing listens here, so the request must fail rather than hang.
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := pc.LocalAddr().String()
+	pc.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "https://"+addr+"/", nil)
+	if _, err := (&Transport{}).RoundTrip(req); err == nil {
+		t.Error("Expected an unreachable server to fail")
+	}
+}
+
+func TestInitialKeys(t *testing.T) {
+	// RFC 9001, Appendix A.1.
+	dstID, _ := hex.DecodeString("8394c8f03e515708")
+	initial := hkdfExtract(sha256.New, dstID, initialSalt)
+	client := hkdfExpandLabel(sha256.New, initial, "client in", 32)
+	for label, want := range map[string]string{
+		"quic key": "1f369613dd76d5467730efcbe3b1a22d",
+		"quic iv":  "fa044b2f42a3fd3b46fb255c",
+		"quic hp":  "9f50449e04a0e810283a1e9933adedd2",
+	} {
+		got := hkdfExpandLabel(sha256.New, client, label, len(want)/2)
+		if hex.EncodeToString(got) != want {
+			t.Errorf("Expected %s %s, got %x", label, want, got)
+		}
+	}
+}
+
+func TestPacketProtection(t *testing.T) {
+	seal, _ := initialKeys([]byte{1, 2, 3, 4, 5, 6, 7, 8}, true)
+	_, open := initialKeys([]byte{1, 2, 3, 4, 5, 6, 7, 8}, false)
+	header, pnOffset := shortHeader([]byte{9, 9, 9, 9}, 1000)
+	packet := seal.seal(header, pnOffset, 1000, []byte("payload"))
+	pn, payload, err := open.open(packet, pnOffset, 998)
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	if pn != 1000 || string(payload) != "payload" {
+		t.Errorf("Expected packet 1000 with payload, got %d %q", pn, payload)
+	}
+}
+
+func TestDecodePacketNumber(t *testing.T) {
+	// RFC 9000, Appendix A.3.
+	if pn := decodePacketNumber(0xa82f30ea, 0x9b32, 16); pn != 0xa82f9b32 {
+		t.Errorf("Expected 0xa82f9b32, got %#x", pn)
+	}
+}
+
+func TestVarint(t *testing.T) {
+	for _, v := range []uint64{0, 37, 15293, 494878333, 151288809941952652} {
+		b := appendVarint(nil, v)
+		got, n := readVarint(b)
+		if got != v || n != len(b) {
+			t.Errorf("Expected %d to round-trip, got %d (%d bytes)", v, got, n)
+		}
+	}
+	if _, n := readVarint([]byte{0x80, 1}); n != 0 {
+		t.Error("Expected a truncated varint to be rejected")
+	}
+}
+
+func TestFields(t *testing.T) {
+	fields := []Field{{":status", "200"}, {"content-type", "text/plain"}, {"x-long", strings.Repeat("v", 300)}}
+	got, err := decodeFields(encodeFields(fields))
+	if err != nil {
+		t.Fatalf("decodeFields failed: %v", err)
+	}
+	if len(got) != len(fields) {
+		t.Fatalf("Expected %d fields, got %d", len(fields), len(got))
+	}
+	for i := range fields {
+		if got[i] != fields[i] {
+			t.Errorf("Expected %v, got %v", fields[i], got[i])
+		}
+	}
+
+	// Indexed :status 200, then :path with a Huffman-coded literal value.
+	huffman, _ := hex.DecodeString("f1e3c2e5f23a6ba0ab90f4ff")
+	block := append([]byte{0, 0, 0xc0 | 25, 0x50 | 1, 0x80 | byte(len(huffman))}, huffman...)
+	got, err = decodeFields(block)
+	if err != nil {
+		t.Fatalf("decodeFields failed: %v", err)
+	}
+	if len(got) != 2 || got[0] != (Field{":status", "200"}) || got[1] != (Field{":path", "www.example.com"}) {
+		t.Errorf("Unexpected fields %v", got)
+	}
+
+	if _, err := decodeFields([]byte{1, 0}); err != errDynamicTable {
+		t.Errorf("Expected errDynamicTable, got %v", err)
+	}
+}
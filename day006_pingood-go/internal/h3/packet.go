@@ -0,0 +1,341 @@
+package h3
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+)
+
+const (
+	quicVersion1 = 0x00000001
+
+	// maxDatagram is the smallest datagram size every QUIC path supports,
+	// and the size client Initial packets are padded to.
+	maxDatagram = 1200
+
+	packetInitial   = 0
+	packetZeroRTT   = 1
+	packetHandshake = 2
+	packetRetry     = 3
+
+	// Every packet number we send takes four bytes, which keeps the header
+	// protection sample inside even an empty payload's AEAD tag.
+	pnLength = 4
+)
+
+// Encryption levels, as packet number spaces.
+const (
+	levelInitial = iota
+	levelHandshake
+	levelApplication
+	levelCount
+)
+
+var initialSalt = []byte{
+	0x38, 0x76, 0x2c, 0xf7, 0xf5, 0x59, 0x34, 0xb3, 0x4d, 0x17,
+	0x9a, 0xe6, 0xa4, 0xc8, 0x0c, 0xad, 0xcc, 0xbb, 0x7f, 0x0a,
+}
+
+// Retry integrity key and nonce for QUIC v1 (RFC 9001, Section 5.8).
+var (
+	retryKey   = []byte{0xbe, 0x0c, 0x69, 0x0b, 0x9f, 0x66, 0x57, 0x5a, 0x1d, 0x76, 0x6b, 0x54, 0xe3, 0x68, 0xc8, 0x4e}
+	retryNonce = []byte{0x46, 0x15, 0x99, 0xd3, 0x5d, 0x63, 0x2b, 0xf2, 0x23, 0x98, 0x25, 0xbb}
+)
+
+func appendVarint(b []byte, v uint64) []byte {
+	switch {
+	case v < 1<<6:
+		return append(b, byte(v))
+	case v < 1<<14:
+		return binary.BigEndian.AppendUint16(b, uint16(v)|0x4000)
+	case v < 1<<30:
+		return binary.BigEndian.AppendUint32(b, uint32(v)|0x80000000)
+	default:
+		return binary.BigEndian.AppendUint64(b, v|0xc000000000000000)
+	}
+}
+
+func varintLen(v uint64) int {
+	return len(appendVarint(nil, v))
+}
+
+// readVarint returns the value and its length, or a length of 0 if b is
+// too short.
+func readVarint(b []byte) (uint64, int) {
+	if len(b) == 0 {
+		return 0, 0
+	}
+	n := 1 << (b[0] >> 6)
+	if len(b) < n {
+		return 0, 0
+	}
+	v := uint64(b[0] & 0x3f)
+	for _, c := range b[1:n] {
+		v = v<<8 | uint64(c)
+	}
+	return v, n
+}
+
+func hkdfExtract(h func() hash.Hash, secret, salt []byte) []byte {
+	mac := hmac.New(h, salt)
+	mac.Write(secret)
+	return mac.Sum(nil)
+}
+
+// hkdfExpandLabel is HKDF-Expand-Label from TLS 1.3 with an empty context.
+func hkdfExpandLabel(h func() hash.Hash, secret []byte, label string, length int) []byte {
+	info := binary.BigEndian.AppendUint16(nil, uint16(length))
+	info = append(info, byte(len("tls13 ")+len(label)))
+	info = append(info, "tls13 "...)
+	info = append(info, label...)
+	info = append(info, 0)
+
+	var out, t []byte
+	for counter := byte(1); len(out) < length; counter++ {
+		mac := hmac.New(h, secret)
+		mac.Write(t)
+		mac.Write(info)
+		mac.Write([]byte{counter})
+		t = mac.Sum(nil)
+		out = append(out, t...)
+	}
+	return out[:length]
+}
+
+// keys protects or removes protection from packets in one direction.
+type keys struct {
+	aead cipher.AEAD
+	iv   []byte
+	hp   cipher.Block
+}
+
+func newKeys(suite uint16, secret []byte) (*keys, error) {
+	h, keyLen := sha256.New, 16
+	switch suite {
+	case tls.TLS_AES_128_GCM_SHA256:
+	case tls.TLS_AES_256_GCM_SHA384:
+		h, keyLen = sha512.New384, 32
+	default:
+		return nil, fmt.Errorf("unsupported cipher suite %s", tls.CipherSuiteName(suite))
+	}
+
+	block, err := aes.NewCipher(hkdfExpandLabel(h, secret, "quic key", keyLen))
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	hp, err := aes.NewCipher(hkdfExpandLabel(h, secret, "quic hp", keyLen))
+	if err != nil {
+		return nil, err
+	}
+	return &keys{aead: aead, iv: hkdfExpandLabel(h, secret, "quic iv", 12), hp: hp}, nil
+}
+
+// initialKeys derives the Initial keys both ends compute from the
+// connection ID the client first sent to.
+func initialKeys(dstID []byte, isClient bool) (seal, open *keys) {
+	initial := hkdfExtract(sha256.New, dstID, initialSalt)
+	client := hkdfExpandLabel(sha256.New, initial, "client in", 32)
+	server := hkdfExpandLabel(sha256.New, initial, "server in", 32)
+	clientKeys, _ := newKeys(tls.TLS_AES_128_GCM_SHA256, client)
+	serverKeys, _ := newKeys(tls.TLS_AES_128_GCM_SHA256, server)
+	if isClient {
+		return clientKeys, serverKeys
+	}
+	return serverKeys, clientKeys
+}
+
+func (k *keys) nonce(pn uint64) []byte {
+	nonce := append([]byte(nil), k.iv...)
+	for i := 0; i < 8; i++ {
+		nonce[len(nonce)-1-i] ^= byte(pn >> (8 * i))
+	}
+	return nonce
+}
+
+func (k *keys) headerMask(sample []byte) []byte {
+	mask := make([]byte, aes.BlockSize)
+	k.hp.Encrypt(mask, sample)
+	return mask
+}
+
+// seal encrypts payload behind header, which ends with the packet number
+// at pnOffset, and applies header protection.
+func (k *keys) seal(header []byte, pnOffset int, pn uint64, payload []byte) []byte {
+	packet := k.aead.Seal(header, k.nonce(pn), payload, header)
+	mask := k.headerMask(packet[pnOffset+4 : pnOffset+4+16])
+	if packet[0]&0x80 != 0 {
+		packet[0] ^= mask[0] & 0x0f
+	} else {
+		packet[0] ^= mask[0] & 0x1f
+	}
+	for i := 0; i < pnLength; i++ {
+		packet[pnOffset+i] ^= mask[1+i]
+	}
+	return packet
+}
+
+var errUndecryptable = errors.New("packet cannot be decrypted")
+
+// open removes header and packet protection from packet in place and
+// returns its packet number and payload. largest is the largest packet
+// number received so far in this space, or -1.
+func (k *keys) open(packet []byte, pnOffset int, largest int64) (uint64, []byte, error) {
+	if len(packet) < pnOffset+4+16 {
+		return 0, nil, errUndecryptable
+	}
+	mask := k.headerMask(packet[pnOffset+4 : pnOffset+4+16])
+	if packet[0]&0x80 != 0 {
+		packet[0] ^= mask[0] & 0x0f
+	} else {
+		packet[0] ^= mask[0] & 0x1f
+	}
+	length := int(packet[0]&0x03) + 1
+	var truncated uint64
+	for i := 0; i < length; i++ {
+		packet[pnOffset+i] ^= mask[1+i]
+		truncated = truncated<<8 | uint64(packet[pnOffset+i])
+	}
+	pn := decodePacketNumber(largest, truncated, length*8)
+
+	header := packet[:pnOffset+length]
+	payload, err := k.aead.Open(packet[pnOffset+length:pnOffset+length], k.nonce(pn), packet[pnOffset+length:], header)
+	if err != nil {
+		return 0, nil, errUndecryptable
+	}
+	return pn, payload, nil
+}
+
+// decodePacketNumber follows RFC 9000, Appendix A.3.
+func decodePacketNumber(largest int64, truncated uint64, bits int) uint64 {
+	expected := uint64(largest + 1)
+	window := uint64(1) << bits
+	half := window / 2
+	candidate := (expected &^ (window - 1)) | truncated
+	switch {
+	case candidate+half <= expected && candidate < 1<<62-window:
+		return candidate + window
+	case candidate > expected+half && candidate >= window:
+		return candidate - window
+	}
+	return candidate
+}
+
+// longHeader starts a long header packet whose Length field is always
+// two bytes, so its size is known before the payload is.
+func longHeader(typ byte, dstID, srcID, token []byte, payloadLen int, pn uint64) ([]byte, int) {
+	b := []byte{0xc0 | typ<<4 | (pnLength - 1)}
+	b = binary.BigEndian.AppendUint32(b, quicVersion1)
+	b = append(b, byte(len(dstID)))
+	b = append(b, dstID...)
+	b = append(b, byte(len(srcID)))
+	b = append(b, srcID...)
+	if typ == packetInitial {
+		b = appendVarint(b, uint64(len(token)))
+		b = append(b, token...)
+	}
+	b = binary.BigEndian.AppendUint16(b, uint16(pnLength+payloadLen+16)|0x4000)
+	pnOffset := len(b)
+	return binary.BigEndian.AppendUint32(b, uint32(pn)), pnOffset
+}
+
+func longHeaderLen(typ byte, dstID, srcID, token []byte) int {
+	n := 1 + 4 + 1 + len(dstID) + 1 + len(srcID) + 2 + pnLength
+	if typ == packetInitial {
+		n += varintLen(uint64(len(token))) + len(token)
+	}
+	return n
+}
+
+func shortHeader(dstID []byte, pn uint64) ([]byte, int) {
+	b := []byte{0x40 | (pnLength - 1)}
+	b = append(b, dstID...)
+	pnOffset := len(b)
+	return binary.BigEndian.AppendUint32(b, uint32(pn)), pnOffset
+}
+
+// longPacket is the unprotected part of a long header.
+type longPacket struct {
+	typ          byte
+	version      uint32
+	dstID, srcID []byte
+	token        []byte
+	pnOffset     int
+	// end is where the packet stops within the datagram.
+	end int
+}
+
+var errMalformed = errors.New("malformed packet")
+
+func parseLongHeader(b []byte) (longPacket, error) {
+	var p longPacket
+	if len(b) < 7 {
+		return p, errMalformed
+	}
+	p.typ = b[0] >> 4 & 0x03
+	p.version = binary.BigEndian.Uint32(b[1:5])
+	i := 5
+	readID := func() []byte {
+		if i >= len(b) || int(b[i]) > 20 || i+1+int(b[i]) > len(b) {
+			i = -1
+			return nil
+		}
+		id := b[i+1 : i+1+int(b[i])]
+		i += 1 + int(b[i])
+		return id
+	}
+	if p.dstID = readID(); i < 0 {
+		return p, errMalformed
+	}
+	if p.srcID = readID(); i < 0 {
+		return p, errMalformed
+	}
+	if p.version == 0 {
+		// Version Negotiation: the rest is the list of versions.
+		p.end = len(b)
+		return p, nil
+	}
+	if p.typ == packetRetry {
+		p.token = b[i:]
+		p.end = len(b)
+		return p, nil
+	}
+	if p.typ == packetInitial {
+		length, n := readVarint(b[i:])
+		if n == 0 || uint64(len(b)-i-n) < length {
+			return p, errMalformed
+		}
+		p.token = b[i+n : i+n+int(length)]
+		i += n + int(length)
+	}
+	length, n := readVarint(b[i:])
+	if n == 0 || uint64(len(b)-i-n) < length {
+		return p, errMalformed
+	}
+	p.pnOffset = i + n
+	p.end = p.pnOffset + int(length)
+	return p, nil
+}
+
+// verifyRetry checks a Retry packet's integrity tag against the
+// connection ID the client originally sent to.
+func verifyRetry(packet, origDstID []byte) bool {
+	if len(packet) < 16 {
+		return false
+	}
+	block, _ := aes.NewCipher(retryKey)
+	aead, _ := cipher.NewGCM(block)
+	pseudo := append([]byte{byte(len(origDstID))}, origDstID...)
+	pseudo = append(pseudo, packet[:len(packet)-16]...)
+	return hmac.Equal(aead.Seal(nil, retryNonce, nil, pseudo), packet[len(packet)-16:])
+}
@@ -0,0 +1,200 @@
+package h3
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/hpack"
+)
+
+// Field is one header or pseudo-header line.
+type Field struct {
+	Name, Value string
+}
+
+// staticTable is the QPACK static table (RFC 9204, Appendix A).
+var staticTable = [...]Field{
+	{":authority", ""},
+	{":path", "/"},
+	{"age", "0"},
+	{"content-disposition", ""},
+	{"content-length", "0"},
+	{"cookie", ""},
+	{"date", ""},
+	{"etag", ""},
+	{"if-modified-since", ""},
+	{"if-none-match", ""},
+	{"last-modified", ""},
+	{"link", ""},
+	{"location", ""},
+	{"referer", ""},
+	{"set-cookie", ""},
+	{":method", "CONNECT"},
+	{":method", "DELETE"},
+	{":method", "GET"},
+	{":method", "HEAD"},
+	{":method", "OPTIONS"},
+	{":method", "POST"},
+	{":method", "PUT"},
+	{":scheme", "http"},
+	{":scheme", "https"},
+	{":status", "103"},
+	{":status", "200"},
+	{":status", "304"},
+	{":status", "404"},
+	{":status", "503"},
+	{"accept", "*/*"},
+	{"accept", "application/dns-message"},
+	{"accept-encoding", "gzip, deflate, br"},
+	{"accept-ranges", "bytes"},
+	{"access-control-allow-headers", "cache-control"},
+	{"access-control-allow-headers", "content-type"},
+	{"access-control-allow-origin", "*"},
+	{"cache-control", "max-age=0"},
+	{"cache-control", "max-age=2592000"},
+	{"cache-control", "max-age=604800"},
+	{"cache-control", "no-cache"},
+	{"cache-control", "no-store"},
+	{"cache-control", "public, max-age=31536000"},
+	{"content-encoding", "br"},
+	{"content-encoding", "gzip"},
+	{"content-type", "application/dns-message"},
+	{"content-type", "application/javascript"},
+	{"content-type", "application/json"},
+	{"content-type", "application/x-www-form-urlencoded"},
+	{"content-type", "image/gif"},
+	{"content-type", "image/jpeg"},
+	{"content-type", "image/png"},
+	{"content-type", "text/css"},
+	{"content-type", "text/html; charset=utf-8"},
+	{"content-type", "text/plain"},
+	{"content-type", "text/plain;charset=utf-8"},
+	{"range", "bytes=0-"},
+	{"strict-transport-security", "max-age=31536000"},
+	{"strict-transport-security", "max-age=31536000; includesubdomains"},
+	{"strict-transport-security", "max-age=31536000; includesubdomains; preload"},
+	{"vary", "accept-encoding"},
+	{"vary", "origin"},
+	{"x-content-type-options", "nosniff"},
+	{"x-xss-protection", "1; mode=block"},
+	{":status", "100"},
+	{":status", "204"},
+	{":status", "206"},
+	{":status", "302"},
+	{":status", "400"},
+	{":status", "403"},
+	{":status", "421"},
+	{":status", "425"},
+	{":status", "500"},
+	{"accept-language", ""},
+	{"access-control-allow-credentials", "FALSE"},
+	{"access-control-allow-credentials", "TRUE"},
+	{"access-control-allow-headers", "*"},
+	{"access-control-allow-methods", "get"},
+	{"access-control-allow-methods", "get, post, options"},
+	{"access-control-allow-methods", "options"},
+	{"access-control-expose-headers", "content-length"},
+	{"access-control-request-headers", "content-type"},
+	{"access-control-request-method", "get"},
+	{"access-control-request-method", "post"},
+	{"alt-svc", "clear"},
+	{"authorization", ""},
+	{"content-security-policy", "script-src 'none'; object-src 'none'; base-uri 'none'"},
+	{"early-data", "1"},
+	{"expect-ct", ""},
+	{"forwarded", ""},
+	{"if-range", ""},
+	{"origin", ""},
+	{"purpose", "prefetch"},
+	{"server", ""},
+	{"timing-allow-origin", "*"},
+	{"upgrade-insecure-requests", "1"},
+	{"user-agent", ""},
+	{"x-forwarded-for", ""},
+	{"x-frame-options", "deny"},
+	{"x-frame-options", "sameorigin"},
+}
+
+var errDynamicTable = errors.New("qpack: dynamic table references are not supported")
+
+// encodeFields writes a field section as literals only, so it never needs
+// the dynamic table or Huffman coding.
+func encodeFields(fields []Field) []byte {
+	// Required Insert Count and Delta Base are both zero.
+	b := []byte{0, 0}
+	for _, f := range fields {
+		b = hpack.AppendInt(b, 0x20, 3, uint64(len(f.Name)))
+		b = append(b, f.Name...)
+		b = hpack.AppendInt(b, 0x00, 7, uint64(len(f.Value)))
+		b = append(b, f.Value...)
+	}
+	return b
+}
+
+// decodeFields reads a field section that uses at most the static table,
+// which is all a peer may use while our dynamic table capacity is zero.
+func decodeFields(b []byte) ([]Field, error) {
+	insertCount, b, err := hpack.ReadInt(b, 8)
+	if err != nil {
+		return nil, err
+	}
+	if insertCount != 0 {
+		return nil, errDynamicTable
+	}
+	if _, b, err = hpack.ReadInt(b, 7); err != nil {
+		return nil, err
+	}
+
+	var fields []Field
+	for len(b) > 0 {
+		var f Field
+		switch {
+		case b[0]&0x80 != 0:
+			// Indexed field line.
+			if b[0]&0x40 == 0 {
+				return nil, errDynamicTable
+			}
+			var index uint64
+			if index, b, err = hpack.ReadInt(b, 6); err != nil {
+				return nil, err
+			}
+			if f, err = staticField(index); err != nil {
+				return nil, err
+			}
+		case b[0]&0x40 != 0:
+			// Literal field line with name reference.
+			if b[0]&0x10 == 0 {
+				return nil, errDynamicTable
+			}
+			var index uint64
+			if index, b, err = hpack.ReadInt(b, 4); err != nil {
+				return nil, err
+			}
+			if f, err = staticField(index); err != nil {
+				return nil, err
+			}
+			if f.Value, b, err = hpack.ReadString(b, 7); err != nil {
+				return nil, err
+			}
+		case b[0]&0x20 != 0:
+			// Literal field line with literal name.
+			if f.Name, b, err = hpack.ReadString(b, 3); err != nil {
+				return nil, err
+			}
+			if f.Value, b, err = hpack.ReadString(b, 7); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, errDynamicTable
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+func staticField(index uint64) (Field, error) {
+	if index >= uint64(len(staticTable)) {
+		return Field{}, fmt.Errorf("qpack: invalid static table index %d", index)
+	}
+	return staticTable[index], nil
+}
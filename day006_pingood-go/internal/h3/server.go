@@ -0,0 +1,193 @@
+package h3
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Server answers HTTP/3 requests. It is meant for tests: it serves one
+// request per stream from Handler, buffers whole responses and does not
+// limit how much it sends to unvalidated addresses.
+type Server struct {
+	Handler   http.Handler
+	TLSConfig *tls.Config
+}
+
+// Serve handles QUIC connections arriving on pc until reading from it
+// fails, for example because it was closed.
+func (s *Server) Serve(pc net.PacketConn) error {
+	var mu sync.Mutex
+	conns := map[string]*conn{}
+	defer func() {
+		mu.Lock()
+		all := make([]*conn, 0, len(conns))
+		for _, c := range conns {
+			all = append(all, c)
+		}
+		mu.Unlock()
+		for _, c := range all {
+			c.mu.Lock()
+			c.close(errClosed)
+			c.mu.Unlock()
+		}
+	}()
+
+	buf := make([]byte, 64<<10)
+	for {
+		n, addr, err := pc.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		b := append([]byte(nil), buf[:n]...)
+
+		var dstID []byte
+		var p longPacket
+		if b[0]&0x80 != 0 {
+			if p, err = parseLongHeader(b); err != nil || p.version != quicVersion1 {
+				continue
+			}
+			dstID = p.dstID
+		} else if len(b) > 8 {
+			dstID = b[1:9]
+		}
+		mu.Lock()
+		c := conns[string(dstID)]
+		mu.Unlock()
+		if c == nil {
+			if b[0]&0x80 == 0 || p.typ != packetInitial || len(b) < maxDatagram || len(dstID) < 8 {
+				continue
+			}
+			if c, err = s.accept(pc, addr, p, &mu, conns); err != nil {
+				continue
+			}
+		}
+
+		c.mu.Lock()
+		opened := c.handleDatagram(b)
+		c.mu.Unlock()
+		for _, st := range opened {
+			go s.serveStream(c, st, addr)
+		}
+	}
+}
+
+// accept starts the server side of a connection for a client Initial.
+func (s *Server) accept(pc net.PacketConn, addr net.Addr, p longPacket, mu *sync.Mutex, conns map[string]*conn) (*conn, error) {
+	c := newConn(false, func(b []byte) error {
+		_, err := pc.WriteTo(b, addr)
+		return err
+	})
+	c.origDstID = append([]byte(nil), p.dstID...)
+	c.dstID = append([]byte(nil), p.srcID...)
+	c.srcID = newConnectionID()
+	c.onClose = func() {
+		mu.Lock()
+		delete(conns, string(c.origDstID))
+		delete(conns, string(c.srcID))
+		mu.Unlock()
+	}
+
+	c.mu.Lock()
+	err := c.start(context.Background(), s.TLSConfig)
+	c.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	mu.Lock()
+	conns[string(c.origDstID)] = c
+	conns[string(c.srcID)] = c
+	mu.Unlock()
+	return c, nil
+}
+
+func (s *Server) serveStream(c *conn, st *stream, addr net.Addr) {
+	r := bufio.NewReader(st)
+	fields, err := readHeaders(r)
+	if err != nil {
+		return
+	}
+	req := &http.Request{
+		Proto:         "HTTP/3.0",
+		ProtoMajor:    3,
+		Header:        http.Header{},
+		ContentLength: -1,
+		RemoteAddr:    addr.String(),
+		Body:          &body{r: r},
+	}
+	for _, f := range fields {
+		switch f.Name {
+		case ":method":
+			req.Method = f.Value
+		case ":authority":
+			req.Host = f.Value
+		case ":path":
+			req.RequestURI = f.Value
+		case ":scheme":
+		default:
+			req.Header.Add(f.Name, f.Value)
+		}
+	}
+	if req.URL, err = url.ParseRequestURI(req.RequestURI); err != nil {
+		st.write(appendH3Frame(nil, h3FrameHeaders, encodeFields([]Field{{":status", "400"}})), true)
+		return
+	}
+	req.URL.Scheme, req.URL.Host = "https", req.Host
+	if n, err := strconv.ParseInt(req.Header.Get("Content-Length"), 10, 64); err == nil {
+		req.ContentLength = n
+	}
+	c.mu.Lock()
+	state := c.tls.ConnectionState()
+	c.mu.Unlock()
+	req.TLS = &state
+
+	w := &responseWriter{header: http.Header{}}
+	s.Handler.ServeHTTP(w, req)
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	respFields := []Field{{":status", strconv.Itoa(w.status)}}
+	for name, values := range w.header {
+		name = strings.ToLower(name)
+		if hopHeaders[name] {
+			continue
+		}
+		for _, v := range values {
+			respFields = append(respFields, Field{name, v})
+		}
+	}
+	data := appendH3Frame(nil, h3FrameHeaders, encodeFields(respFields))
+	if w.body.Len() > 0 {
+		data = appendH3Frame(data, h3FrameData, w.body.Bytes())
+	}
+	st.write(data, true)
+}
+
+// responseWriter buffers a handler's whole response.
+type responseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *responseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(b)
+}
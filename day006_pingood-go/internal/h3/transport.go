@@ -0,0 +1,200 @@
+package h3
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"strings"
+)
+
+// Transport is an http.RoundTripper that sends each request over HTTP/3
+// on a new QUIC connection, which is closed with the response body. It
+// ignores proxies.
+type Transport struct {
+	TLSClientConfig *tls.Config
+	// Network is "udp" (the default), "udp4" or "udp6".
+	Network string
+}
+
+var errClosed = errors.New("connection closed")
+
+// hopHeaders are not allowed in HTTP/3 (RFC 9114, Section 4.2).
+var hopHeaders = map[string]bool{
+	"connection":        true,
+	"host":              true,
+	"keep-alive":        true,
+	"proxy-connection":  true,
+	"transfer-encoding": true,
+	"upgrade":           true,
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		defer req.Body.Close()
+	}
+	if req.URL.Scheme != "https" {
+		return nil, fmt.Errorf("HTTP/3 requires https, got %q", req.URL.Scheme)
+	}
+	ctx := req.Context()
+	port := req.URL.Port()
+	if port == "" {
+		port = "443"
+	}
+	network := t.Network
+	if network == "" {
+		network = "udp"
+	}
+
+	var content []byte
+	if req.Body != nil {
+		var err error
+		if content, err = io.ReadAll(req.Body); err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+	}
+
+	var d net.Dialer
+	udp, err := d.DialContext(ctx, network, net.JoinHostPort(req.URL.Hostname(), port))
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{}
+	if t.TLSClientConfig != nil {
+		config = t.TLSClientConfig.Clone()
+	}
+	if config.ServerName == "" {
+		config.ServerName = req.URL.Hostname()
+	}
+
+	c := newConn(true, func(b []byte) error {
+		_, err := udp.Write(b)
+		return err
+	})
+	c.onClose = func() { udp.Close() }
+	c.srcID = newConnectionID()
+	c.origDstID = newConnectionID()
+	c.dstID = c.origDstID
+	go c.readLoop(udp)
+
+	shutdown := func(err error) {
+		c.mu.Lock()
+		c.close(err)
+		c.mu.Unlock()
+	}
+	stop := context.AfterFunc(ctx, func() { shutdown(ctx.Err()) })
+
+	c.mu.Lock()
+	err = c.start(ctx, config)
+	for err == nil && !c.handshakeComplete && c.err == nil {
+		c.cond.Wait()
+	}
+	if err == nil {
+		err = c.err
+	}
+	var state tls.ConnectionState
+	if err == nil {
+		state = c.tls.ConnectionState()
+		c.openControlStream()
+		c.flush()
+	}
+	c.mu.Unlock()
+	if err != nil {
+		stop()
+		shutdown(err)
+		return nil, err
+	}
+	if trace := httptrace.ContextClientTrace(ctx); trace != nil && trace.GotConn != nil {
+		trace.GotConn(httptrace.GotConnInfo{Conn: udp})
+	}
+
+	authority := req.Host
+	if authority == "" {
+		authority = req.URL.Host
+	}
+	fields := []Field{
+		{":method", req.Method},
+		{":scheme", "https"},
+		{":authority", authority},
+		{":path", req.URL.RequestURI()},
+	}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if hopHeaders[name] {
+			continue
+		}
+		for _, v := range values {
+			fields = append(fields, Field{name, v})
+		}
+	}
+	data := appendH3Frame(nil, h3FrameHeaders, encodeFields(fields))
+	if len(content) > 0 {
+		data = appendH3Frame(data, h3FrameData, content)
+	}
+
+	s := c.openStream()
+	if err := s.write(data, true); err != nil {
+		stop()
+		return nil, err
+	}
+	r := bufio.NewReader(s)
+	var status int
+	var respFields []Field
+	for status < 200 {
+		if respFields, err = readHeaders(r); err == nil {
+			status, err = statusOf(respFields)
+		}
+		if err != nil {
+			stop()
+			shutdown(errClosed)
+			return nil, fmt.Errorf("failed to read response headers: %w", err)
+		}
+	}
+
+	resp := &http.Response{
+		Status:        strconv.Itoa(status) + " " + http.StatusText(status),
+		StatusCode:    status,
+		Proto:         "HTTP/3.0",
+		ProtoMajor:    3,
+		Header:        http.Header{},
+		ContentLength: -1,
+		Request:       req,
+		TLS:           &state,
+		Body: &body{r: r, onClose: func() {
+			stop()
+			shutdown(errClosed)
+		}},
+	}
+	for _, f := range respFields {
+		if !strings.HasPrefix(f.Name, ":") {
+			resp.Header.Add(f.Name, f.Value)
+		}
+	}
+	if n, err := strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64); err == nil {
+		resp.ContentLength = n
+	}
+	return resp, nil
+}
+
+// readLoop feeds datagrams from a connected socket into the connection
+// until the socket fails or is closed.
+func (c *conn) readLoop(r io.Reader) {
+	buf := make([]byte, 64<<10)
+	for {
+		n, err := r.Read(buf)
+		c.mu.Lock()
+		if err != nil {
+			c.fail(err)
+			c.mu.Unlock()
+			return
+		}
+		c.handleDatagram(append([]byte(nil), buf[:n]...))
+		c.mu.Unlock()
+	}
+}
@@ -0,0 +1,190 @@
+package hopinfo
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ASN is the autonomous system announcing an address.
+type ASN struct {
+	Number  int
+	Org     string
+	Country string
+}
+
+func (a ASN) String() string {
+	if a.Number == 0 {
+		return ""
+	}
+	if a.Org == "" {
+		return fmt.Sprintf("AS%d", a.Number)
+	}
+	return fmt.Sprintf("AS%d %s", a.Number, a.Org)
+}
+
+// Database maps addresses to ASNs offline.
+type Database struct {
+	v4, v6 []asnRange
+}
+
+type asnRange struct {
+	start, end netip.Addr
+	// maxEnd is the highest end of this and all earlier ranges, so a
+	// lookup knows when to stop walking back through nested ranges.
+	maxEnd netip.Addr
+	asn    ASN
+}
+
+// LoadDatabase reads a database file, gzip-compressed if the name ends in
+// .gz. See ParseDatabase for the formats.
+func LoadDatabase(path string) (*Database, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ASN database: %w", err)
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ASN database: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+	return ParseDatabase(r)
+}
+
+// ParseDatabase reads one range per line, either in the iptoasn.com TSV
+// layout
+//
+//	1.0.0.0	1.0.0.255	13335	US	CLOUDFLARENET
+//
+// or as a prefix, ASN and organisation:
+//
+//	8.8.8.0/24 AS15169 GOOGLE
+//
+// Lines starting with # and ranges with ASN 0 (not routed) are skipped.
+// When ranges nest, the most specific one wins.
+func ParseDatabase(r io.Reader) (*Database, error) {
+	db := &Database{}
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		entry, err := parseRange(text)
+		if err != nil {
+			return nil, fmt.Errorf("ASN database line %d: %w", line, err)
+		}
+		if entry.asn.Number == 0 {
+			continue
+		}
+		if entry.start.Is4() {
+			db.v4 = append(db.v4, entry)
+		} else {
+			db.v6 = append(db.v6, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read ASN database: %w", err)
+	}
+
+	for _, ranges := range [][]asnRange{db.v4, db.v6} {
+		sort.SliceStable(ranges, func(i, j int) bool {
+			return ranges[i].start.Less(ranges[j].start)
+		})
+		for i := range ranges {
+			ranges[i].maxEnd = ranges[i].end
+			if i > 0 && ranges[i-1].maxEnd.Compare(ranges[i].end) > 0 {
+				ranges[i].maxEnd = ranges[i-1].maxEnd
+			}
+		}
+	}
+	return db, nil
+}
+
+func parseRange(text string) (asnRange, error) {
+	var entry asnRange
+	first, rest := cutField(text)
+
+	if prefix, err := netip.ParsePrefix(first); err == nil {
+		prefix = prefix.Masked()
+		entry.start = prefix.Addr()
+		entry.end = lastAddr(prefix)
+	} else {
+		var last string
+		last, rest = cutField(rest)
+		if entry.start, err = netip.ParseAddr(first); err != nil {
+			return entry, fmt.Errorf("invalid range start %q", first)
+		}
+		if entry.end, err = netip.ParseAddr(last); err != nil || entry.end.Less(entry.start) || entry.end.Is4() != entry.start.Is4() {
+			return entry, fmt.Errorf("invalid range end %q", last)
+		}
+	}
+
+	number, rest := cutField(rest)
+	n, err := strconv.Atoi(strings.TrimPrefix(strings.ToUpper(number), "AS"))
+	if err != nil {
+		return entry, fmt.Errorf("invalid ASN %q", number)
+	}
+	entry.asn.Number = n
+
+	// Only the TSV layout has a country column: a two-letter code followed
+	// by a tab.
+	if country, org, found := strings.Cut(rest, "\t"); found && len(strings.TrimSpace(country)) == 2 {
+		entry.asn.Country = strings.TrimSpace(country)
+		rest = org
+	}
+	entry.asn.Org = strings.TrimSpace(rest)
+	return entry, nil
+}
+
+func cutField(s string) (string, string) {
+	s = strings.TrimLeft(s, " \t")
+	i := strings.IndexAny(s, " \t")
+	if i < 0 {
+		return s, ""
+	}
+	return s[:i], strings.TrimLeft(s[i:], " \t")
+}
+
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	b := prefix.Addr().AsSlice()
+	for bit := prefix.Bits(); bit < len(b)*8; bit++ {
+		b[bit/8] |= 0x80 >> (bit % 8)
+	}
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
+
+// Lookup returns the most specific range containing addr.
+func (d *Database) Lookup(addr netip.Addr) (ASN, bool) {
+	addr = addr.Unmap()
+	ranges := d.v6
+	if addr.Is4() {
+		ranges = d.v4
+	}
+
+	i := sort.Search(len(ranges), func(i int) bool {
+		return addr.Less(ranges[i].start)
+	}) - 1
+	for ; i >= 0 && !ranges[i].maxEnd.Less(addr); i-- {
+		if !ranges[i].end.Less(addr) {
+			return ranges[i].asn, true
+		}
+	}
+	return ASN{}, false
+}
@@ -0,0 +1,122 @@
+// Package hopinfo adds what a bare traceroute address does not say: its
+// reverse DNS name, the autonomous system that announces it, and whether it
+// is a private or carrier-grade NAT address that no AS announces.
+package hopinfo
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Info is what is known about one address. Empty fields were not found.
+type Info struct {
+	Address string
+	Name    string
+	Scope   string
+	ASN     ASN
+}
+
+var scopes = []struct {
+	prefix netip.Prefix
+	name   string
+}{
+	{netip.MustParsePrefix("10.0.0.0/8"), "private"},
+	{netip.MustParsePrefix("172.16.0.0/12"), "private"},
+	{netip.MustParsePrefix("192.168.0.0/16"), "private"},
+	{netip.MustParsePrefix("100.64.0.0/10"), "cgnat"},
+	{netip.MustParsePrefix("169.254.0.0/16"), "link-local"},
+	{netip.MustParsePrefix("127.0.0.0/8"), "loopback"},
+	{netip.MustParsePrefix("fc00::/7"), "private"},
+	{netip.MustParsePrefix("fe80::/10"), "link-local"},
+	{netip.MustParsePrefix("::1/128"), "loopback"},
+}
+
+// Scope names the special-purpose range addr belongs to: private (RFC 1918
+// and IPv6 ULA), cgnat (RFC 6598 shared address space), link-local or
+// loopback. It returns "" for everything else.
+func Scope(addr netip.Addr) string {
+	addr = addr.Unmap()
+	for _, scope := range scopes {
+		if scope.prefix.Contains(addr) {
+			return scope.name
+		}
+	}
+	return ""
+}
+
+// Resolver is satisfied by *net.Resolver.
+type Resolver interface {
+	LookupAddr(ctx context.Context, addr string) ([]string, error)
+}
+
+// Enricher looks up addresses. PTR lookups are skipped without a Resolver
+// and AS lookups without a Database.
+type Enricher struct {
+	Database    *Database
+	Resolver    Resolver
+	Timeout     time.Duration
+	Concurrency int
+}
+
+// Lookup returns Info for each parseable address. PTR lookups run
+// concurrently, each bounded by Timeout; a failed lookup leaves Name empty.
+func (e *Enricher) Lookup(ctx context.Context, addresses []string) map[string]Info {
+	timeout := e.Timeout
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	concurrency := e.Concurrency
+	if concurrency <= 0 {
+		concurrency = 8
+	}
+
+	infos := make(map[string]Info)
+	for _, address := range addresses {
+		addr, err := netip.ParseAddr(address)
+		if err != nil {
+			continue
+		}
+		info := Info{Address: address, Scope: Scope(addr)}
+		if e.Database != nil {
+			info.ASN, _ = e.Database.Lookup(addr)
+		}
+		infos[address] = info
+	}
+	if e.Resolver == nil {
+		return infos
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+	names := make(map[string]string)
+	for address := range infos {
+		wg.Add(1)
+		go func(address string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			lookupCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			found, err := e.Resolver.LookupAddr(lookupCtx, address)
+			if err != nil || len(found) == 0 {
+				return
+			}
+			mu.Lock()
+			names[address] = strings.TrimSuffix(found[0], ".")
+			mu.Unlock()
+		}(address)
+	}
+	wg.Wait()
+
+	for address, name := range names {
+		info := infos[address]
+		info.Name = name
+		infos[address] = info
+	}
+	return infos
+}
@@ -0,0 +1,140 @@
+package hopinfo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testDatabase = `# start	end	asn	country	org
+1.0.0.0	1.0.0.255	13335	US	CLOUDFLARENET
+1.0.4.0	1.0.7.255	38803	AU	GTELECOM-AUSTRALIA Gtelecom Pty Ltd
+1.0.8.0	1.0.15.255	0	None	Not routed
+8.0.0.0/8 AS3356 LEVEL3
+8.8.8.0/24 AS15169 GOOGLE
+2001:4860::/32 15169 GOOGLE
+`
+
+func TestDatabaseLookup(t *testing.T) {
+	db, err := ParseDatabase(strings.NewReader(testDatabase))
+	if err != nil {
+		t.Fatalf("ParseDatabase failed: %v", err)
+	}
+
+	tests := []struct {
+		addr string
+		want string
+	}{
+		{"1.0.0.1", "AS13335 CLOUDFLARENET"},
+		{"1.0.5.9", "AS38803 GTELECOM-AUSTRALIA Gtelecom Pty Ltd"},
+		{"1.0.9.1", ""},
+		{"8.8.8.8", "AS15169 GOOGLE"},
+		{"8.8.9.1", "AS3356 LEVEL3"},
+		{"9.0.0.1", ""},
+		{"2001:4860:4860::8888", "AS15169 GOOGLE"},
+		{"::ffff:8.8.8.8", "AS15169 GOOGLE"},
+		{"2606:4700::1111", ""},
+	}
+	for _, tt := range tests {
+		asn, _ := db.Lookup(netip.MustParseAddr(tt.addr))
+		if asn.String() != tt.want {
+			t.Errorf("Lookup(%s) = %q, want %q", tt.addr, asn, tt.want)
+		}
+	}
+	if asn, _ := db.Lookup(netip.MustParseAddr("1.0.0.1")); asn.Country != "US" {
+		t.Errorf("Expected the TSV country column to be kept, got %+v", asn)
+	}
+
+	for _, bad := range []string{"1.0.0.0 x", "1.0.0.9\t1.0.0.0\t1\tUS\tX", "8.8.8.0/24 ASX GOOGLE"} {
+		if _, err := ParseDatabase(strings.NewReader(bad)); err == nil {
+			t.Errorf("Expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestLoadDatabaseGzip(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte(testDatabase))
+	gz.Close()
+
+	path := filepath.Join(t.TempDir(), "ip2asn-combined.tsv.gz")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	db, err := LoadDatabase(path)
+	if err != nil {
+		t.Fatalf("LoadDatabase failed: %v", err)
+	}
+	if asn, ok := db.Lookup(netip.MustParseAddr("8.8.8.8")); !ok || asn.Number != 15169 {
+		t.Errorf("Unexpected lookup result %+v", asn)
+	}
+}
+
+func TestScope(t *testing.T) {
+	for addr, want := range map[string]string{
+		"192.168.1.1": "private",
+		"172.31.0.1":  "private",
+		"172.32.0.1":  "",
+		"100.64.0.1":  "cgnat",
+		"100.128.0.1": "",
+		"fd00::1":     "private",
+		"fe80::1":     "link-local",
+		"8.8.8.8":     "",
+	} {
+		if got := Scope(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("Scope(%s) = %q, want %q", addr, got, want)
+		}
+	}
+}
+
+type fakeResolver map[string]string
+
+func (f fakeResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	if addr == "10.0.0.1" {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	if name, ok := f[addr]; ok {
+		return []string{name}, nil
+	}
+	return nil, errors.New("no PTR record")
+}
+
+func TestEnricherLookup(t *testing.T) {
+	db, _ := ParseDatabase(strings.NewReader(testDatabase))
+	enricher := &Enricher{
+		Database: db,
+		Resolver: fakeResolver{"192.168.1.1": "router.lan.", "8.8.8.8": "dns.google."},
+		Timeout:  50 * time.Millisecond,
+	}
+
+	start := time.Now()
+	infos := enricher.Lookup(context.Background(), []string{"192.168.1.1", "10.0.0.1", "8.8.8.8", "100.64.0.1", "*"})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected a slow PTR lookup to be cut off, took %s", elapsed)
+	}
+
+	if len(infos) != 4 {
+		t.Fatalf("Expected 4 addresses, got %+v", infos)
+	}
+	if info := infos["192.168.1.1"]; info.Name != "router.lan" || info.Scope != "private" {
+		t.Errorf("Unexpected info %+v", info)
+	}
+	if info := infos["8.8.8.8"]; info.Name != "dns.google" || info.ASN.Number != 15169 {
+		t.Errorf("Unexpected info %+v", info)
+	}
+	if info := infos["10.0.0.1"]; info.Name != "" {
+		t.Errorf("Expected no name after a timeout, got %+v", info)
+	}
+	if info := infos["100.64.0.1"]; info.Scope != "cgnat" {
+		t.Errorf("Unexpected info %+v", info)
+	}
+}
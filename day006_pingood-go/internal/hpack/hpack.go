@@ -0,0 +1,313 @@
+// Package hpack implements HTTP/2 header compression (RFC 7541). Its
+// integer, string and Huffman coding are also used by QPACK.
+//
+// The encoder only writes literals that are never added to the dynamic
+// table, so a peer never has to keep state for us; the decoder supports
+// everything a peer may send.
+package hpack
+
+import (
+	"errors"
+	"fmt"
+)
+
+// HeaderField is one header or pseudo-header line.
+type HeaderField struct {
+	Name, Value string
+}
+
+// size is what the field counts against the dynamic table size (RFC 7541,
+// Section 4.1).
+func (f HeaderField) size() int {
+	return len(f.Name) + len(f.Value) + 32
+}
+
+// staticTable is the HPACK static table (RFC 7541, Appendix A); index 1
+// is the first entry.
+var staticTable = [...]HeaderField{
+	{":authority", ""},
+	{":method", "GET"},
+	{":method", "POST"},
+	{":path", "/"},
+	{":path", "/index.html"},
+	{":scheme", "http"},
+	{":scheme", "https"},
+	{":status", "200"},
+	{":status", "204"},
+	{":status", "206"},
+	{":status", "304"},
+	{":status", "400"},
+	{":status", "404"},
+	{":status", "500"},
+	{"accept-charset", ""},
+	{"accept-encoding", "gzip, deflate"},
+	{"accept-language", ""},
+	{"accept-ranges", ""},
+	{"accept", ""},
+	{"access-control-allow-origin", ""},
+	{"age", ""},
+	{"allow", ""},
+	{"authorization", ""},
+	{"cache-control", ""},
+	{"content-disposition", ""},
+	{"content-encoding", ""},
+	{"content-language", ""},
+	{"content-length", ""},
+	{"content-location", ""},
+	{"content-range", ""},
+	{"content-type", ""},
+	{"cookie", ""},
+	{"date", ""},
+	{"etag", ""},
+	{"expect", ""},
+	{"expires", ""},
+	{"from", ""},
+	{"host", ""},
+	{"if-match", ""},
+	{"if-modified-since", ""},
+	{"if-none-match", ""},
+	{"if-range", ""},
+	{"if-unmodified-since", ""},
+	{"last-modified", ""},
+	{"link", ""},
+	{"location", ""},
+	{"max-forwards", ""},
+	{"proxy-authenticate", ""},
+	{"proxy-authorization", ""},
+	{"range", ""},
+	{"referer", ""},
+	{"refresh", ""},
+	{"retry-after", ""},
+	{"server", ""},
+	{"set-cookie", ""},
+	{"strict-transport-security", ""},
+	{"transfer-encoding", ""},
+	{"user-agent", ""},
+	{"vary", ""},
+	{"via", ""},
+	{"www-authenticate", ""},
+}
+
+// DefaultTableSize is the dynamic table size both ends start with.
+const DefaultTableSize = 4096
+
+// Decoder decodes the header blocks of one connection, which share a
+// dynamic table.
+type Decoder struct {
+	// dynamic holds the newest entry first.
+	dynamic []HeaderField
+	size    int
+	maxSize int
+	// limit is the largest size the peer may pick for the table, which is
+	// what we advertised in SETTINGS_HEADER_TABLE_SIZE.
+	limit int
+}
+
+func NewDecoder(limit int) *Decoder {
+	return &Decoder{maxSize: limit, limit: limit}
+}
+
+// Decode reads a complete header block, after any CONTINUATION frames
+// have been joined.
+func (d *Decoder) Decode(b []byte) ([]HeaderField, error) {
+	var fields []HeaderField
+	for len(b) > 0 {
+		var err error
+		switch {
+		case b[0]&0x80 != 0:
+			// Indexed header field.
+			var index uint64
+			if index, b, err = ReadInt(b, 7); err != nil {
+				return nil, err
+			}
+			f, err := d.field(index)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, f)
+		case b[0]&0xc0 == 0x40:
+			// Literal with incremental indexing.
+			var f HeaderField
+			if f, b, err = d.literal(b, 6); err != nil {
+				return nil, err
+			}
+			d.add(f)
+			fields = append(fields, f)
+		case b[0]&0xe0 == 0x20:
+			// Dynamic table size update.
+			var size uint64
+			if size, b, err = ReadInt(b, 5); err != nil {
+				return nil, err
+			}
+			if size > uint64(d.limit) {
+				return nil, fmt.Errorf("hpack: table size %d is above the limit %d", size, d.limit)
+			}
+			d.maxSize = int(size)
+			d.evict()
+		default:
+			// Literal without indexing or never indexed.
+			var f HeaderField
+			if f, b, err = d.literal(b, 4); err != nil {
+				return nil, err
+			}
+			fields = append(fields, f)
+		}
+	}
+	return fields, nil
+}
+
+func (d *Decoder) literal(b []byte, n uint) (HeaderField, []byte, error) {
+	var f HeaderField
+	index, b, err := ReadInt(b, n)
+	if err != nil {
+		return f, nil, err
+	}
+	if index == 0 {
+		if f.Name, b, err = ReadString(b, 7); err != nil {
+			return f, nil, err
+		}
+	} else {
+		named, err := d.field(index)
+		if err != nil {
+			return f, nil, err
+		}
+		f.Name = named.Name
+	}
+	f.Value, b, err = ReadString(b, 7)
+	return f, b, err
+}
+
+func (d *Decoder) field(index uint64) (HeaderField, error) {
+	switch {
+	case index == 0:
+		return HeaderField{}, errors.New("hpack: index 0 is not allowed")
+	case index <= uint64(len(staticTable)):
+		return staticTable[index-1], nil
+	case index-uint64(len(staticTable))-1 < uint64(len(d.dynamic)):
+		return d.dynamic[index-uint64(len(staticTable))-1], nil
+	}
+	return HeaderField{}, fmt.Errorf("hpack: invalid table index %d", index)
+}
+
+func (d *Decoder) add(f HeaderField) {
+	d.dynamic = append([]HeaderField{f}, d.dynamic...)
+	d.size += f.size()
+	d.evict()
+}
+
+// evict drops the oldest entries until the table fits. An entry larger
+// than the whole table empties it.
+func (d *Decoder) evict() {
+	for d.size > d.maxSize && len(d.dynamic) > 0 {
+		last := d.dynamic[len(d.dynamic)-1]
+		d.dynamic = d.dynamic[:len(d.dynamic)-1]
+		d.size -= last.size()
+	}
+}
+
+// AppendField encodes f as a literal without indexing with a literal name,
+// which leaves the peer's dynamic table alone.
+func AppendField(b []byte, f HeaderField) []byte {
+	b = append(b, 0)
+	b = AppendInt(b, 0, 7, uint64(len(f.Name)))
+	b = append(b, f.Name...)
+	b = AppendInt(b, 0, 7, uint64(len(f.Value)))
+	return append(b, f.Value...)
+}
+
+// AppendInt encodes v as an n-bit prefix integer (RFC 7541, Section 5.1)
+// whose first byte also carries the bits in first.
+func AppendInt(b []byte, first byte, n uint, v uint64) []byte {
+	max := uint64(1)<<n - 1
+	if v < max {
+		return append(b, first|byte(v))
+	}
+	b = append(b, first|byte(max))
+	for v -= max; v >= 0x80; v >>= 7 {
+		b = append(b, byte(v)|0x80)
+	}
+	return append(b, byte(v))
+}
+
+// ErrTruncated is returned when a header block ends in the middle of a
+// field.
+var ErrTruncated = errors.New("hpack: truncated header block")
+
+// ReadInt reads an n-bit prefix integer and returns what follows it.
+func ReadInt(b []byte, n uint) (uint64, []byte, error) {
+	if len(b) == 0 {
+		return 0, nil, ErrTruncated
+	}
+	max := uint64(1)<<n - 1
+	v := uint64(b[0]) & max
+	b = b[1:]
+	if v < max {
+		return v, b, nil
+	}
+	for shift := uint(0); shift < 63; shift += 7 {
+		if len(b) == 0 {
+			return 0, nil, ErrTruncated
+		}
+		c := b[0]
+		b = b[1:]
+		v += uint64(c&0x7f) << shift
+		if c&0x80 == 0 {
+			return v, b, nil
+		}
+	}
+	return 0, nil, errors.New("hpack: integer overflow")
+}
+
+// ReadString reads a string literal whose length has an n-bit prefix and
+// whose Huffman flag is the bit just above it.
+func ReadString(b []byte, n uint) (string, []byte, error) {
+	if len(b) == 0 {
+		return "", nil, ErrTruncated
+	}
+	huffman := b[0]&(1<<n) != 0
+	length, b, err := ReadInt(b, n)
+	if err != nil {
+		return "", nil, err
+	}
+	if uint64(len(b)) < length {
+		return "", nil, ErrTruncated
+	}
+	s, b := b[:length], b[length:]
+	if !huffman {
+		return string(s), b, nil
+	}
+	decoded, err := HuffmanDecode(s)
+	return decoded, b, err
+}
+
+var huffmanSymbols = func() map[uint64]byte {
+	m := make(map[uint64]byte, len(huffmanCodes))
+	for sym, code := range huffmanCodes {
+		m[uint64(huffmanCodeLengths[sym])<<32|uint64(code)] = byte(sym)
+	}
+	return m
+}()
+
+// HuffmanDecode decodes a Huffman-coded string literal.
+func HuffmanDecode(b []byte) (string, error) {
+	var out []byte
+	var code uint64
+	var length uint
+	for _, c := range b {
+		for bit := 7; bit >= 0; bit-- {
+			code = code<<1 | uint64(c>>uint(bit)&1)
+			length++
+			if sym, ok := huffmanSymbols[uint64(length)<<32|code]; ok {
+				out = append(out, sym)
+				code, length = 0, 0
+			} else if length > 30 {
+				return "", errors.New("hpack: invalid Huffman code")
+			}
+		}
+	}
+	// Leftover bits must be a prefix of EOS, which is all ones.
+	if length > 7 || code != 1<<length-1 {
+		return "", errors.New("hpack: invalid Huffman padding")
+	}
+	return string(out), nil
+}
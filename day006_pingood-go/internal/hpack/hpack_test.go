@@ -0,0 +1,106 @@
+package hpack
+
+import (
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func decodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// TestDecoderRequests decodes the Huffman-coded requests of RFC 7541,
+// Appendix C.4, which build on each other through the dynamic table.
+func TestDecoderRequests(t *testing.T) {
+	d := NewDecoder(DefaultTableSize)
+	blocks := []struct {
+		hex  string
+		want []HeaderField
+	}{
+		{"8286 8441 8cf1 e3c2 e5f2 3a6b a0ab 90f4 ff", []HeaderField{
+			{":method", "GET"}, {":scheme", "http"}, {":path", "/"}, {":authority", "www.example.com"},
+		}},
+		{"8286 84be 5886 a8eb 1064 9cbf", []HeaderField{
+			{":method", "GET"}, {":scheme", "http"}, {":path", "/"}, {":authority", "www.example.com"}, {"cache-control", "no-cache"},
+		}},
+		{"8287 85bf 4088 25a8 49e9 5ba9 7d7f 8925 a849 e95b b8e8 b4bf", []HeaderField{
+			{":method", "GET"}, {":scheme", "https"}, {":path", "/index.html"}, {":authority", "www.example.com"}, {"custom-key", "custom-value"},
+		}},
+	}
+	for i, block := range blocks {
+		got, err := d.Decode(decodeHex(t, block.hex))
+		if err != nil {
+			t.Fatalf("block %d: Decode failed: %v", i+1, err)
+		}
+		if !reflect.DeepEqual(got, block.want) {
+			t.Errorf("block %d: expected %v, got %v", i+1, block.want, got)
+		}
+	}
+	if d.size != 164 {
+		t.Errorf("Expected a dynamic table size of 164, got %d", d.size)
+	}
+}
+
+// TestDecoderEviction decodes the responses of RFC 7541, Appendix C.6,
+// with a 256 byte table, so older entries are evicted.
+func TestDecoderEviction(t *testing.T) {
+	d := NewDecoder(256)
+	first := decodeHex(t, "4882 6402 5885 aec3 771a 4b61 96d0 7abe 9410 54d4 44a8 2005 9504 0b81 66e0 82a6 2d1b ff6e 919d 29ad 1718 63c7 8f0b 97c8 e9ae 82ae 43d3")
+	if _, err := d.Decode(first); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	got, err := d.Decode(decodeHex(t, "4883 640e ffc1 c0bf"))
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	want := []HeaderField{
+		{":status", "307"},
+		{"cache-control", "private"},
+		{"date", "Mon, 21 Oct 2013 20:13:21 GMT"},
+		{"location", "https://www.example.com"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	if len(d.dynamic) != 4 || d.size != 222 {
+		t.Errorf("Expected 4 entries of 222 bytes, got %d of %d", len(d.dynamic), d.size)
+	}
+
+	if _, err := d.Decode([]byte{0x3f, 0xe2, 0x1f}); err == nil {
+		t.Error("Expected a table size above the limit to fail")
+	}
+}
+
+func TestAppendFieldRoundTrip(t *testing.T) {
+	fields := []HeaderField{
+		{":status", "200"},
+		{"content-type", "application/grpc"},
+		{"x-long", strings.Repeat("v", 300)},
+	}
+	var block []byte
+	for _, f := range fields {
+		block = AppendField(block, f)
+	}
+	d := NewDecoder(DefaultTableSize)
+	got, err := d.Decode(block)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, fields) {
+		t.Errorf("Expected %v, got %v", fields, got)
+	}
+	if len(d.dynamic) != 0 {
+		t.Errorf("Expected literals to leave the dynamic table empty, got %v", d.dynamic)
+	}
+
+	if _, err := d.Decode(block[:len(block)-1]); err != ErrTruncated {
+		t.Errorf("Expected ErrTruncated, got %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+// Package middlebox looks for devices between pingood and the Internet
+// that intercept traffic: transparent proxies and firewalls that answer
+// for the real server, found by comparing TCP handshakes and echo replies
+// on several ports of a self-hosted echo endpoint, and TLS interception,
+// found by comparing certificates with pinned fingerprints.
+package middlebox
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// The echo protocol: the client sends a request line with a random nonce
+// and the server answers with a Reply as JSON, over TCP or UDP, on every
+// port it listens on. Something that answers without the nonce is not the
+// echo server.
+const requestPrefix = "PINGOOD "
+
+// Reply is what the echo server saw of a probe.
+type Reply struct {
+	Nonce string `json:"nonce"`
+	// Client is the address the connection came from, after any NAT or
+	// proxy on the way.
+	Client string `json:"client"`
+	Port   int    `json:"port"`
+	// MSS is the maximum segment size the server's TCP stack uses towards
+	// the client, which follows the MSS option of the client's SYN as it
+	// arrived. Zero for UDP or where it is unknown.
+	MSS int `json:"mss,omitempty"`
+}
+
+func newNonce() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func parseReply(data []byte, nonce string) (Reply, error) {
+	var reply Reply
+	if err := json.Unmarshal(data, &reply); err != nil || reply.Nonce == "" {
+		return reply, fmt.Errorf("unexpected answer %q", truncate(strings.TrimSpace(string(data)), 40))
+	}
+	if reply.Nonce != nonce {
+		return reply, fmt.Errorf("answer for another probe (nonce %s)", reply.Nonce)
+	}
+	return reply, nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
+
+// EchoServer answers probes. Run it on the ports to test, including one
+// that nothing on the way intercepts to serve as the baseline.
+type EchoServer struct {
+	// Timeout bounds how long a client may take to send its request; zero
+	// means 10 seconds.
+	Timeout time.Duration
+}
+
+func (s *EchoServer) timeout() time.Duration {
+	if s.Timeout <= 0 {
+		return 10 * time.Second
+	}
+	return s.Timeout
+}
+
+// ServeTCP answers probes on l until it is closed.
+func (s *EchoServer) ServeTCP(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go s.serveConn(conn)
+	}
+}
+
+func (s *EchoServer) serveConn(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(s.timeout()))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	nonce, ok := strings.CutPrefix(strings.TrimSpace(line), requestPrefix)
+	if err != nil || !ok {
+		return
+	}
+	reply := Reply{Nonce: nonce, Client: conn.RemoteAddr().String(), Port: addrPort(conn.LocalAddr())}
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		reply.MSS = tcpMSS(tcp)
+	}
+	data, _ := json.Marshal(reply)
+	conn.Write(append(data, '\n'))
+}
+
+// ServeUDP answers probes on pc until it is closed.
+func (s *EchoServer) ServeUDP(pc net.PacketConn) error {
+	buf := make([]byte, 1500)
+	for {
+		n, addr, err := pc.ReadFrom(buf)
+		if errors.Is(err, net.ErrClosed) {
+			return err
+		}
+		if err != nil {
+			continue
+		}
+		nonce, ok := strings.CutPrefix(strings.TrimSpace(string(buf[:n])), requestPrefix)
+		if !ok {
+			continue
+		}
+		data, _ := json.Marshal(Reply{Nonce: nonce, Client: addr.String(), Port: addrPort(pc.LocalAddr())})
+		pc.WriteTo(data, addr)
+	}
+}
+
+func addrPort(addr net.Addr) int {
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		return a.Port
+	case *net.UDPAddr:
+		return a.Port
+	}
+	return 0
+}
@@ -0,0 +1,250 @@
+package middlebox
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/capture"
+)
+
+func TestParsePort(t *testing.T) {
+	for input, want := range map[string]Port{
+		"443":     {"tcp", 443},
+		"tcp/25":  {"tcp", 25},
+		"UDP/53 ": {"udp", 53},
+	} {
+		got, err := ParsePort(input)
+		if err != nil || got != want {
+			t.Errorf("ParsePort(%q) = %v, %v, want %v", input, got, err, want)
+		}
+	}
+	for _, input := range []string{"", "icmp/1", "tcp/0", "udp/70000", "tcp/http"} {
+		if _, err := ParsePort(input); err == nil {
+			t.Errorf("ParsePort(%q): expected an error", input)
+		}
+	}
+}
+
+func listenTCP(t *testing.T, serve func(net.Conn)) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serve(conn)
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestProber(t *testing.T) {
+	server := &EchoServer{}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go server.ServeTCP(ln)
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	go server.ServeUDP(pc)
+
+	// A second echo port that is open like the baseline.
+	ln2, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln2.Close()
+	go server.ServeTCP(ln2)
+
+	impostor := listenTCP(t, func(conn net.Conn) {
+		defer conn.Close()
+		conn.Write([]byte("HTTP/1.1 403 Forbidden\r\n\r\n"))
+	})
+	silent := listenTCP(t, func(conn net.Conn) {
+		time.Sleep(time.Second)
+		conn.Close()
+	})
+	closed, _ := net.Listen("tcp", "127.0.0.1:0")
+	closedPort := closed.Addr().(*net.TCPAddr).Port
+	closed.Close()
+
+	baseline := Port{"tcp", ln.Addr().(*net.TCPAddr).Port}
+	ports := []Port{
+		{"tcp", ln2.Addr().(*net.TCPAddr).Port},
+		{"udp", pc.LocalAddr().(*net.UDPAddr).Port},
+		{"tcp", impostor},
+		{"tcp", silent},
+		{"tcp", closedPort},
+	}
+	prober := Prober{Host: "127.0.0.1", Timeout: 300 * time.Millisecond}
+	report, err := prober.Run(context.Background(), baseline, ports)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Baseline.Verdict != Open || report.Baseline.Reply == nil || report.Baseline.Reply.Port != baseline.Port {
+		t.Fatalf("Unexpected baseline %+v", report.Baseline)
+	}
+	want := []Verdict{Open, Open, Intercepted, Intercepted, Blocked}
+	for i, r := range report.Results {
+		if r.Verdict != want[i] {
+			t.Errorf("%s: expected %s, got %s (%v, %v)", r.Port, want[i], r.Verdict, r.Err, r.Issues)
+		}
+	}
+	if r := report.Results[1]; r.Reply == nil || r.Reply.Port != ports[1].Port || r.Reply.MSS != 0 {
+		t.Errorf("Unexpected UDP reply %+v", r.Reply)
+	}
+	if issues := strings.Join(report.Results[2].Issues, "\n"); !strings.Contains(issues, "something other than the echo server") {
+		t.Errorf("Unexpected issues %q", issues)
+	}
+	if issues := strings.Join(report.Results[3].Issues, "\n"); !strings.Contains(issues, "never answered") {
+		t.Errorf("Unexpected issues %q", issues)
+	}
+
+	if _, err := prober.Run(context.Background(), Port{"tcp", closedPort}, ports[:1]); err == nil {
+		t.Error("Expected an error when the baseline does not answer")
+	}
+}
+
+func TestCompare(t *testing.T) {
+	segment := func(ttl, mss int, options string) *capture.Segment {
+		return &capture.Segment{TTL: ttl, Flags: 0x12, Window: 65160, MSS: mss, Options: options}
+	}
+	baseline := Result{
+		Verdict:   Open,
+		Reply:     &Reply{Client: "203.0.113.7:40000", MSS: 1448},
+		Handshake: segment(52, 1460, "mss,sack,ts,nop,ws"),
+	}
+
+	same := Result{Verdict: Open, Reply: &Reply{Client: "203.0.113.7:40001", MSS: 1448}, Handshake: segment(52, 1460, "mss,sack,ts,nop,ws")}
+	compare(&same, baseline)
+	if same.Verdict != Open || len(same.Issues) != 0 {
+		t.Errorf("Expected open, got %s %v", same.Verdict, same.Issues)
+	}
+
+	proxied := Result{Verdict: Open, Reply: &Reply{Client: "198.51.100.1:3128", MSS: 1460}, Handshake: segment(64, 1380, "mss")}
+	compare(&proxied, baseline)
+	if proxied.Verdict != Proxied || len(proxied.Issues) != 5 {
+		t.Errorf("Expected proxied with 5 issues, got %s %q", proxied.Verdict, proxied.Issues)
+	}
+
+	reset := Result{Verdict: Blocked, Handshake: &capture.Segment{TTL: 63, Flags: 0x14}}
+	compare(&reset, baseline)
+	if reset.Verdict != Blocked || len(reset.Issues) != 1 || !strings.Contains(reset.Issues[0], "TTL 63") {
+		t.Errorf("Unexpected reset result %s %q", reset.Verdict, reset.Issues)
+	}
+}
+
+func TestFirstAnswers(t *testing.T) {
+	server := netip.MustParseAddr("192.0.2.80")
+	packet := func(src, dst string, flags byte, ttl byte) capture.Packet {
+		tcp := make([]byte, 20)
+		s, d := netip.MustParseAddrPort(src), netip.MustParseAddrPort(dst)
+		tcp[0], tcp[1] = byte(s.Port()>>8), byte(s.Port())
+		tcp[2], tcp[3] = byte(d.Port()>>8), byte(d.Port())
+		tcp[12], tcp[13] = 5<<4, flags
+		ip := make([]byte, 20, 40)
+		ip[0], ip[2], ip[3], ip[8], ip[9] = 0x45, 0, 40, ttl, 6
+		copy(ip[12:16], s.Addr().AsSlice())
+		copy(ip[16:20], d.Addr().AsSlice())
+		return capture.Packet{Data: append(ip, tcp...)}
+	}
+	answers := firstAnswers([]capture.Packet{
+		packet("192.0.2.10:50000", "192.0.2.80:443", 0x02, 64),
+		packet("192.0.2.80:443", "192.0.2.10:50000", 0x12, 52),
+		packet("192.0.2.80:443", "192.0.2.10:50000", 0x10, 52),
+		packet("192.0.2.80:25", "192.0.2.10:50001", 0x14, 63),
+		packet("192.0.2.80:25", "192.0.2.10:50001", 0x14, 50),
+	}, server)
+	if len(answers) != 2 || answers[443].TTL != 52 || !answers[443].SYN() || answers[25].TTL != 63 || !answers[25].RST() {
+		t.Errorf("Unexpected answers %+v", answers)
+	}
+}
+
+func selfSigned(t *testing.T, name string) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: cert}
+}
+
+func TestCheckPin(t *testing.T) {
+	cert := selfSigned(t, "pinned.example")
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				conn.(*tls.Conn).Handshake()
+				conn.Close()
+			}()
+		}
+	}()
+
+	fingerprint := Fingerprint(cert.Leaf)
+	colons := strings.ToLower(fingerprint[:2]) + ":" + fingerprint[2:]
+	other := Fingerprint(selfSigned(t, "other.example").Leaf)
+	for _, tc := range []struct {
+		fingerprints []string
+		intercepted  bool
+	}{
+		{[]string{other, colons}, false},
+		{[]string{KeyFingerprint(cert.Leaf)}, false},
+		{[]string{other}, true},
+	} {
+		pin := Pin{Address: ln.Addr().String(), ServerName: "pinned.example", Fingerprints: tc.fingerprints}
+		result, err := CheckPin(context.Background(), pin, time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Intercepted() != tc.intercepted || result.Trusted || len(result.Chain) != 1 {
+			t.Errorf("%v: expected intercepted=%v, got %+v", tc.fingerprints, tc.intercepted, result)
+		}
+	}
+
+	if _, err := CheckPin(context.Background(), Pin{Address: ln.Addr().String()}, time.Second); err == nil {
+		t.Error("Expected an error without fingerprints")
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package middlebox
+
+import "net"
+
+func tcpMSS(conn *net.TCPConn) int {
+	return 0
+}
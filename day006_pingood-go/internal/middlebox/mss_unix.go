@@ -0,0 +1,24 @@
+//go:build linux || darwin
+
+package middlebox
+
+import (
+	"net"
+	"syscall"
+)
+
+// tcpMSS returns the maximum segment size of conn, 0 if it is unknown.
+func tcpMSS(conn *net.TCPConn) int {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return 0
+	}
+	var mss int
+	raw.Control(func(fd uintptr) {
+		mss, err = syscall.GetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_MAXSEG)
+	})
+	if err != nil {
+		return 0
+	}
+	return mss
+}
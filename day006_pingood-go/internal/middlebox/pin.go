@@ -0,0 +1,120 @@
+package middlebox
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// Pin is a TLS endpoint and the fingerprints of the certificates it is
+// known to present. A fingerprint is the SHA-256 of a certificate in hex,
+// colons allowed, as "openssl x509 -fingerprint -sha256" prints it, or of
+// its public key as "sha256/<base64>", as curl's --pinnedpubkey takes it.
+// Pinning the key of an intermediate CA survives leaf renewals.
+type Pin struct {
+	Address      string
+	ServerName   string
+	Fingerprints []string
+}
+
+// PinResult is what an endpoint presented.
+type PinResult struct {
+	Pin Pin
+	// Chain is the certificate chain the server sent, leaf first.
+	Chain []*x509.Certificate
+	// Matched is the fingerprint that matched, empty if none did.
+	Matched string
+	// Trusted reports whether the chain verifies against the system
+	// roots. An interception that is trusted anyway has its CA installed
+	// on this host.
+	Trusted bool
+}
+
+// Intercepted reports whether the endpoint presented no pinned certificate,
+// which means something on the way terminated TLS.
+func (r PinResult) Intercepted() bool {
+	return r.Matched == ""
+}
+
+// Fingerprint is the SHA-256 of cert in the hex form Pin accepts.
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// KeyFingerprint is the SHA-256 of cert's public key in the "sha256/"
+// form Pin accepts.
+func KeyFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return "sha256/" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// CheckPin connects to pin.Address and compares the certificates it
+// presents with the pinned fingerprints. Only connection failures are
+// errors.
+func CheckPin(ctx context.Context, pin Pin, timeout time.Duration) (PinResult, error) {
+	result := PinResult{Pin: pin}
+	if len(pin.Fingerprints) == 0 {
+		return result, fmt.Errorf("no fingerprints pinned for %s", pin.Address)
+	}
+	serverName := pin.ServerName
+	if serverName == "" {
+		host, _, err := net.SplitHostPort(pin.Address)
+		if err != nil {
+			return result, fmt.Errorf("invalid address %q: %w", pin.Address, err)
+		}
+		serverName = host
+	}
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	dialer := tls.Dialer{Config: &tls.Config{ServerName: serverName, InsecureSkipVerify: true}}
+	conn, err := dialer.DialContext(ctx, "tcp", pin.Address)
+	if err != nil {
+		return result, fmt.Errorf("failed to connect: %w", err)
+	}
+	defer conn.Close()
+	result.Chain = conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(result.Chain) == 0 {
+		return result, fmt.Errorf("server sent no certificate")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range result.Chain[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err = result.Chain[0].Verify(x509.VerifyOptions{DNSName: serverName, Intermediates: intermediates})
+	result.Trusted = err == nil
+
+	for _, cert := range result.Chain {
+		for _, fingerprint := range []string{Fingerprint(cert), KeyFingerprint(cert)} {
+			for _, pinned := range pin.Fingerprints {
+				if normalizeFingerprint(pinned) == normalizeFingerprint(fingerprint) {
+					result.Matched = pinned
+					return result, nil
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
+// normalizeFingerprint makes hex fingerprints comparable however they are
+// written; base64 is case sensitive and kept as is.
+func normalizeFingerprint(s string) string {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(strings.ToLower(s), "sha256/") {
+		return "sha256/" + s[len("sha256/"):]
+	}
+	return strings.ToUpper(strings.NewReplacer(":", "", " ", "").Replace(s))
+}
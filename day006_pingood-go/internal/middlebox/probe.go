@@ -0,0 +1,292 @@
+package middlebox
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/capture"
+)
+
+// Port is a protocol and port of the echo endpoint.
+type Port struct {
+	Protocol string
+	Port     int
+}
+
+func (p Port) String() string {
+	return fmt.Sprintf("%s/%d", p.Protocol, p.Port)
+}
+
+// ParsePort accepts "443", "tcp/443" or "udp/53"; a bare port is TCP.
+func ParsePort(s string) (Port, error) {
+	protocol, number, ok := strings.Cut(strings.ToLower(strings.TrimSpace(s)), "/")
+	if !ok {
+		protocol, number = "tcp", protocol
+	}
+	if protocol != "tcp" && protocol != "udp" {
+		return Port{}, fmt.Errorf("invalid port %q: protocol must be tcp or udp", s)
+	}
+	n, err := strconv.Atoi(number)
+	if err != nil || n < 1 || n > 65535 {
+		return Port{}, fmt.Errorf("invalid port %q", s)
+	}
+	return Port{Protocol: protocol, Port: n}, nil
+}
+
+// Verdict is what a probe found on the way to a port.
+type Verdict string
+
+const (
+	// Open means the echo server answered as it does on the baseline port.
+	Open Verdict = "open"
+	// Proxied means the echo server answered, but the connection was
+	// terminated or rewritten on the way: a transparent proxy.
+	Proxied Verdict = "proxied"
+	// Intercepted means something other than the echo server answered.
+	Intercepted Verdict = "intercepted"
+	// Blocked means the connection was refused or reset.
+	Blocked Verdict = "blocked"
+	// Filtered means nothing answered.
+	Filtered Verdict = "filtered"
+)
+
+// Result is the outcome of probing one port.
+type Result struct {
+	Port    Port
+	Verdict Verdict
+	// Reply is the echo server's answer, nil unless it answered.
+	Reply *Reply
+	// RTT is how long the TCP handshake or the UDP exchange took.
+	RTT time.Duration
+	// Handshake is the server's answer to the SYN as captured: the SYN-ACK,
+	// or the reset that refused the connection. Nil for UDP or without a
+	// capture.
+	Handshake *capture.Segment
+	Err       error
+	// Issues explain a verdict other than Open.
+	Issues []string
+}
+
+// Prober probes ports of an echo endpoint and compares each with a
+// baseline port that nothing intercepts.
+type Prober struct {
+	// Host runs the echo server on the baseline and probed ports.
+	Host string
+	// Timeout bounds each probe; zero means 5 seconds.
+	Timeout time.Duration
+	// Interface, when set, captures the TCP handshakes on it so that the
+	// TTL, window and options of the SYN-ACKs can be compared. Capturing
+	// needs Linux and root or CAP_NET_RAW; without it only the echo
+	// replies are compared.
+	Interface string
+}
+
+// Report is the outcome of a run of probes.
+type Report struct {
+	Address  netip.Addr
+	Baseline Result
+	Results  []Result
+	// CaptureError is why the handshakes could not be captured.
+	CaptureError error
+}
+
+// Run probes baseline and then ports. It fails when the baseline port does
+// not answer, as nothing can be compared without it.
+func (p Prober) Run(ctx context.Context, baseline Port, ports []Port) (Report, error) {
+	var report Report
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", p.Host)
+	if err != nil {
+		return report, fmt.Errorf("failed to resolve %s: %w", p.Host, err)
+	}
+	report.Address = addrs[0].Unmap()
+
+	var capt *capture.Capture
+	if p.Interface != "" {
+		capt, report.CaptureError = capture.Start(p.Interface, []capture.Rule{{Protocol: capture.TCP, Host: report.Address}})
+	}
+
+	report.Baseline = p.probe(ctx, report.Address, baseline)
+	for _, port := range ports {
+		report.Results = append(report.Results, p.probe(ctx, report.Address, port))
+	}
+
+	if capt != nil {
+		packets, err := capt.Stop()
+		if err != nil {
+			report.CaptureError = err
+		}
+		handshakes := firstAnswers(packets, report.Address)
+		for _, r := range append([]*Result{&report.Baseline}, resultRefs(report.Results)...) {
+			if s, ok := handshakes[r.Port.Port]; ok && r.Port.Protocol == "tcp" {
+				r.Handshake = &s
+			}
+		}
+	}
+
+	if report.Baseline.Verdict != Open {
+		return report, fmt.Errorf("baseline port %s did not answer: %v", baseline, report.Baseline.Err)
+	}
+	for i := range report.Results {
+		compare(&report.Results[i], report.Baseline)
+	}
+	return report, nil
+}
+
+func resultRefs(results []Result) []*Result {
+	refs := make([]*Result, len(results))
+	for i := range results {
+		refs[i] = &results[i]
+	}
+	return refs
+}
+
+// firstAnswers returns, per server port, the first segment the server
+// sent in answer to a SYN: a SYN-ACK or a reset.
+func firstAnswers(packets []capture.Packet, server netip.Addr) map[int]capture.Segment {
+	answers := map[int]capture.Segment{}
+	for _, packet := range packets {
+		s, ok := capture.ParseSegment(packet.Data)
+		if !ok || s.Src.Addr() != server || !(s.SYN() || s.RST()) {
+			continue
+		}
+		if _, seen := answers[int(s.Src.Port())]; !seen {
+			answers[int(s.Src.Port())] = s
+		}
+	}
+	return answers
+}
+
+func (p Prober) probe(ctx context.Context, addr netip.Addr, port Port) Result {
+	timeout := p.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	result := Result{Port: port}
+	address := net.JoinHostPort(addr.String(), strconv.Itoa(port.Port))
+	nonce := newNonce()
+	start := time.Now()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, port.Protocol, address)
+	if err != nil {
+		result.Verdict, result.Err = failure(err), err
+		return result
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if port.Protocol == "tcp" {
+		result.RTT = time.Since(start)
+	}
+
+	if _, err := conn.Write([]byte(requestPrefix + nonce + "\n")); err != nil {
+		result.Verdict, result.Err = failure(err), err
+		return result
+	}
+	var data []byte
+	if port.Protocol == "tcp" {
+		data, err = bufio.NewReader(conn).ReadBytes('\n')
+		if len(data) > 0 {
+			err = nil
+		}
+	} else {
+		buf := make([]byte, 1500)
+		var n int
+		n, err = conn.Read(buf)
+		data = buf[:n]
+		result.RTT = time.Since(start)
+	}
+	switch {
+	case err != nil && port.Protocol == "udp":
+		result.Verdict, result.Err = failure(err), err
+		return result
+	case err != nil:
+		// The handshake completed, so something accepted the connection
+		// and then stayed silent or hung up: the typical firewall that
+		// accepts everything, or one that resets what it inspected.
+		result.Verdict, result.Err = Intercepted, err
+		if failure(err) == Filtered {
+			result.Issues = append(result.Issues, "connection accepted but the echo server never answered")
+		} else {
+			result.Issues = append(result.Issues, fmt.Sprintf("connection accepted and then closed without an answer: %v", err))
+		}
+		return result
+	}
+
+	reply, err := parseReply(data, nonce)
+	if err != nil {
+		result.Verdict, result.Err = Intercepted, err
+		result.Issues = append(result.Issues, "answered by something other than the echo server: "+err.Error())
+		return result
+	}
+	result.Verdict, result.Reply = Open, &reply
+	return result
+}
+
+// failure turns a probe error into a verdict: a timeout means nothing
+// answered; a refusal, reset or ICMP unreachable means something did.
+func failure(err error) Verdict {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) && netErr.Timeout() {
+		return Filtered
+	}
+	return Blocked
+}
+
+// compare checks an answered probe against the baseline: the same server
+// should see the same client address and MSS and send the same handshake
+// on every port. A difference means a device on the way answers the
+// handshake itself and relays the connection.
+func compare(r *Result, baseline Result) {
+	if r.Verdict == Blocked && r.Handshake != nil && baseline.Handshake != nil && r.Handshake.TTL != baseline.Handshake.TTL {
+		r.Issues = append(r.Issues, fmt.Sprintf("reset arrived with TTL %d, the baseline SYN-ACK with %d: rejected by a device on the way, not the server", r.Handshake.TTL, baseline.Handshake.TTL))
+	}
+	if r.Verdict != Open {
+		return
+	}
+	if r.Reply != nil && baseline.Reply != nil {
+		if client, base := clientHost(r.Reply.Client), clientHost(baseline.Reply.Client); client != base {
+			r.Issues = append(r.Issues, fmt.Sprintf("server saw the connection from %s, the baseline from %s", client, base))
+		}
+		if r.Reply.MSS != 0 && baseline.Reply.MSS != 0 && r.Reply.MSS != baseline.Reply.MSS {
+			r.Issues = append(r.Issues, fmt.Sprintf("server-side MSS %d, baseline %d: the SYN was rewritten on the way", r.Reply.MSS, baseline.Reply.MSS))
+		}
+	}
+	if r.Handshake != nil && baseline.Handshake != nil && r.Handshake.SYN() && baseline.Handshake.SYN() {
+		h, b := r.Handshake, baseline.Handshake
+		if h.TTL != b.TTL {
+			r.Issues = append(r.Issues, fmt.Sprintf("SYN-ACK TTL %d, baseline %d", h.TTL, b.TTL))
+		}
+		if h.MSS != b.MSS {
+			r.Issues = append(r.Issues, fmt.Sprintf("SYN-ACK MSS %d, baseline %d", h.MSS, b.MSS))
+		}
+		if h.Options != b.Options {
+			r.Issues = append(r.Issues, fmt.Sprintf("SYN-ACK options %s, baseline %s", h.Options, b.Options))
+		}
+		if h.Window != b.Window {
+			r.Issues = append(r.Issues, fmt.Sprintf("SYN-ACK window %d, baseline %d", h.Window, b.Window))
+		}
+	}
+	if len(r.Issues) > 0 {
+		r.Verdict = Proxied
+	}
+}
+
+func clientHost(address string) string {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return address
+	}
+	return host
+}
@@ -0,0 +1,306 @@
+package otlp
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/h2c"
+)
+
+// Export is what one export request carried.
+type Export struct {
+	Protocol Protocol
+	// Signal is "traces" or "metrics".
+	Signal   string
+	Header   http.Header
+	Resource []KeyValue
+	Spans    []Span
+	Metrics  []Metric
+}
+
+// Collector is a stand-in for an OpenTelemetry collector that accepts
+// OTLP/HTTP and OTLP/gRPC exports of traces and metrics and keeps what they
+// carried. It is for tests and for trying an exporter configuration
+// without a real collector.
+type Collector struct {
+	// OnExport is called with every accepted export.
+	OnExport func(Export)
+
+	mu      sync.Mutex
+	exports []Export
+}
+
+// Exports returns everything received so far.
+func (c *Collector) Exports() []Export {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Export(nil), c.exports...)
+}
+
+// Spans returns the spans of every export received so far.
+func (c *Collector) Spans() []Span {
+	var spans []Span
+	for _, e := range c.Exports() {
+		spans = append(spans, e.Spans...)
+	}
+	return spans
+}
+
+// Metrics returns the metrics of every export received so far.
+func (c *Collector) Metrics() []Metric {
+	var metrics []Metric
+	for _, e := range c.Exports() {
+		metrics = append(metrics, e.Metrics...)
+	}
+	return metrics
+}
+
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 16<<20))
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		c.serveGRPC(w, r, body)
+		return
+	}
+
+	export := Export{Protocol: ProtocolHTTP, Header: r.Header}
+	switch r.URL.Path {
+	case "/v1/traces":
+		export.Signal = "traces"
+	case "/v1/metrics":
+		export.Signal = "metrics"
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if ct := r.Header.Get("Content-Type"); ct != "application/x-protobuf" {
+		c.httpError(w, http.StatusUnsupportedMediaType, fmt.Sprintf("unsupported content type %q", ct))
+		return
+	}
+	if err := export.decode(body); err != nil {
+		c.httpError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	c.add(export)
+	// An empty response means nothing was rejected.
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.WriteHeader(http.StatusOK)
+}
+
+func (c *Collector) httpError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.WriteHeader(status)
+	w.Write(encodeStatus(grpcInvalidArgument, message))
+}
+
+// gRPC status codes the collector answers with, also used in the
+// google.rpc.Status of OTLP/HTTP errors.
+const (
+	grpcInvalidArgument = 3
+	grpcUnimplemented   = 12
+)
+
+func (c *Collector) serveGRPC(w http.ResponseWriter, r *http.Request, body []byte) {
+	w.Header().Set("Content-Type", "application/grpc")
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+	fail := func(code int, message string) {
+		w.WriteHeader(http.StatusOK)
+		w.Header().Set("Grpc-Status", strconv.Itoa(code))
+		w.Header().Set("Grpc-Message", url.PathEscape(message))
+	}
+
+	export := Export{Protocol: ProtocolGRPC, Header: r.Header}
+	switch r.URL.Path {
+	case grpcTraceMethod:
+		export.Signal = "traces"
+	case grpcMetricsMethod:
+		export.Signal = "metrics"
+	default:
+		fail(grpcUnimplemented, "unknown method "+r.URL.Path)
+		return
+	}
+	if len(body) < 5 || body[0] != 0 || int(binary.BigEndian.Uint32(body[1:])) != len(body)-5 {
+		fail(grpcInvalidArgument, "invalid or compressed gRPC message")
+		return
+	}
+	if err := export.decode(body[5:]); err != nil {
+		fail(grpcInvalidArgument, err.Error())
+		return
+	}
+	c.add(export)
+	// An empty response message means nothing was rejected.
+	w.Write([]byte{0, 0, 0, 0, 0})
+	w.Header().Set("Grpc-Status", "0")
+}
+
+// WriteExport prints an export for people: spans as a tree with their
+// durations and statuses, metrics with their data points.
+func WriteExport(w io.Writer, e Export) {
+	fmt.Fprintf(w, "%s %s", e.Protocol, e.Signal)
+	if len(e.Resource) > 0 {
+		fmt.Fprintf(w, " from %s", formatAttributes(e.Resource))
+	}
+	fmt.Fprintln(w)
+
+	children := make(map[SpanID][]Span)
+	known := make(map[SpanID]bool)
+	for _, s := range e.Spans {
+		known[s.SpanID] = true
+	}
+	var roots []Span
+	for _, s := range e.Spans {
+		if s.ParentSpanID.IsZero() || !known[s.ParentSpanID] {
+			roots = append(roots, s)
+			continue
+		}
+		children[s.ParentSpanID] = append(children[s.ParentSpanID], s)
+	}
+	var writeSpan func(s Span, depth int)
+	writeSpan = func(s Span, depth int) {
+		indent := strings.Repeat("  ", depth+1)
+		fmt.Fprintf(w, "%s%s %s", indent, s.Name, s.End.Sub(s.Start).Round(time.Microsecond))
+		if s.Status == StatusError {
+			fmt.Fprint(w, " ERROR")
+			if s.StatusMessage != "" {
+				fmt.Fprintf(w, ": %s", s.StatusMessage)
+			}
+		}
+		fmt.Fprintln(w)
+		if len(s.Attributes) > 0 {
+			fmt.Fprintf(w, "%s  %s\n", indent, formatAttributes(s.Attributes))
+		}
+		for _, event := range s.Events {
+			fmt.Fprintf(w, "%s  * %s %s\n", indent, event.Name, formatAttributes(event.Attributes))
+		}
+		for _, child := range children[s.SpanID] {
+			writeSpan(child, depth+1)
+		}
+	}
+	for _, s := range roots {
+		fmt.Fprintf(w, "  trace %s\n", s.TraceID)
+		writeSpan(s, 1)
+	}
+
+	for _, m := range e.Metrics {
+		unit := ""
+		if m.Unit != "" && m.Unit != "1" {
+			unit = " " + m.Unit
+		}
+		for _, p := range m.Gauge {
+			fmt.Fprintf(w, "  %s %g%s {%s}\n", m.Name, p.Value, unit, formatAttributes(p.Attributes))
+		}
+		for _, p := range m.Histogram {
+			fmt.Fprintf(w, "  %s count=%d sum=%g%s min=%g max=%g {%s}\n", m.Name, p.Count, p.Sum, unit, p.Min, p.Max, formatAttributes(p.Attributes))
+		}
+	}
+}
+
+func (e *Export) decode(body []byte) error {
+	var err error
+	if e.Signal == "traces" {
+		e.Resource, e.Spans, err = decodeTraces(body)
+	} else {
+		e.Resource, e.Metrics, err = decodeMetrics(body)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to decode %s: %w", e.Signal, err)
+	}
+	return nil
+}
+
+func (c *Collector) add(export Export) {
+	c.mu.Lock()
+	c.exports = append(c.exports, export)
+	c.mu.Unlock()
+	if c.OnExport != nil {
+		c.OnExport(export)
+	}
+}
+
+// Serve accepts OTLP/HTTP and cleartext OTLP/gRPC on the same listener,
+// telling them apart by the HTTP/2 connection preface, until l is closed.
+func (c *Collector) Serve(l net.Listener) error {
+	http1 := &connListener{conns: make(chan net.Conn), addr: l.Addr(), done: make(chan struct{})}
+	defer http1.Close()
+	go (&http.Server{Handler: c}).Serve(http1)
+	h2 := &h2c.Server{Handler: c}
+
+	for {
+		nc, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			pc := &peekedConn{Conn: nc, r: bufio.NewReader(nc)}
+			start, err := pc.r.Peek(len(http2Preface))
+			if err != nil && len(start) == 0 {
+				nc.Close()
+				return
+			}
+			if string(start) == http2Preface {
+				h2.ServeConn(pc)
+				return
+			}
+			select {
+			case http1.conns <- pc:
+			case <-http1.done:
+				nc.Close()
+			}
+		}()
+	}
+}
+
+const http2Preface = "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"
+
+// peekedConn reads through the buffer that was used to look at the start
+// of the connection.
+type peekedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *peekedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+// connListener hands connections accepted elsewhere to an http.Server.
+type connListener struct {
+	conns chan net.Conn
+	addr  net.Addr
+	once  sync.Once
+	done  chan struct{}
+}
+
+func (l *connListener) Accept() (net.Conn, error) {
+	select {
+	case nc := <-l.conns:
+		return nc, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *connListener) Close() error {
+	l.once.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *connListener) Addr() net.Addr {
+	return l.addr
+}
@@ -0,0 +1,250 @@
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/h2c"
+)
+
+type Protocol string
+
+const (
+	ProtocolHTTP Protocol = "http/protobuf"
+	ProtocolGRPC Protocol = "grpc"
+)
+
+// ParseProtocol reads an OTLP protocol name as used by OTEL_EXPORTER_OTLP_PROTOCOL.
+// An empty name means OTLP/HTTP.
+func ParseProtocol(s string) (Protocol, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "http", "http/protobuf":
+		return ProtocolHTTP, nil
+	case "grpc":
+		return ProtocolGRPC, nil
+	}
+	return "", fmt.Errorf("unknown OTLP protocol %q (use http/protobuf or grpc)", s)
+}
+
+// gRPC methods of the OTLP collector services.
+const (
+	grpcTraceMethod   = "/opentelemetry.proto.collector.trace.v1.TraceService/Export"
+	grpcMetricsMethod = "/opentelemetry.proto.collector.metrics.v1.MetricsService/Export"
+)
+
+// Exporter sends spans and metrics to a collector. Endpoint is the base
+// URL of the collector, such as http://localhost:4318 for OTLP/HTTP or
+// http://localhost:4317 for gRPC; https uses TLS, http sends gRPC over
+// cleartext HTTP/2. Exports are not retried. The connections an Exporter
+// keeps open are reused across exports; set the fields before the first.
+type Exporter struct {
+	Endpoint string
+	Protocol Protocol
+	// Headers are sent with every export, for example for authentication.
+	Headers map[string]string
+	// Resource describes what the telemetry is about, such as service.name
+	// and host.name.
+	Resource  []KeyValue
+	TLSConfig *tls.Config
+	// Timeout bounds each export; zero means 10 seconds.
+	Timeout time.Duration
+
+	clientOnce sync.Once
+	client     *http.Client
+}
+
+// idleTimeout closes a kept connection that a schedule leaves unused.
+const idleTimeout = 90 * time.Second
+
+// httpClient returns the client every export goes through, built on the
+// first one so that connections to the collector are kept and reused.
+func (e *Exporter) httpClient(base *url.URL) *http.Client {
+	e.clientOnce.Do(func() {
+		var transport http.RoundTripper
+		switch {
+		case e.Protocol == ProtocolGRPC && base.Scheme == "http":
+			transport = &h2c.Transport{}
+		case e.Protocol == ProtocolGRPC:
+			transport = &http.Transport{TLSClientConfig: e.TLSConfig, ForceAttemptHTTP2: true, IdleConnTimeout: idleTimeout}
+		default:
+			transport = &http.Transport{
+				Proxy:             http.ProxyFromEnvironment,
+				TLSClientConfig:   e.TLSConfig,
+				ForceAttemptHTTP2: true,
+				IdleConnTimeout:   idleTimeout,
+			}
+		}
+		e.client = &http.Client{Transport: transport}
+	})
+	return e.client
+}
+
+func (e *Exporter) ExportTraces(ctx context.Context, spans []Span) error {
+	if len(spans) == 0 {
+		return nil
+	}
+	body := encodeTraces(e.Resource, spans)
+	if err := e.export(ctx, "/v1/traces", grpcTraceMethod, body); err != nil {
+		return fmt.Errorf("failed to export %d spans: %w", len(spans), err)
+	}
+	return nil
+}
+
+func (e *Exporter) ExportMetrics(ctx context.Context, metrics []Metric) error {
+	if len(metrics) == 0 {
+		return nil
+	}
+	body := encodeMetrics(e.Resource, metrics)
+	if err := e.export(ctx, "/v1/metrics", grpcMetricsMethod, body); err != nil {
+		return fmt.Errorf("failed to export %d metrics: %w", len(metrics), err)
+	}
+	return nil
+}
+
+func (e *Exporter) export(ctx context.Context, httpPath, grpcMethod string, body []byte) error {
+	base, err := url.Parse(e.Endpoint)
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return fmt.Errorf("invalid OTLP endpoint %q: it must start with http:// or https://", e.Endpoint)
+	}
+	timeout := e.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if e.Protocol == ProtocolGRPC {
+		return e.exportGRPC(ctx, base, grpcMethod, body)
+	}
+	return e.exportHTTP(ctx, base, httpPath, body)
+}
+
+func (e *Exporter) exportHTTP(ctx context.Context, base *url.URL, path string, body []byte) error {
+	endpoint := base.JoinPath(path)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range e.Headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+
+	resp, err := e.httpClient(base).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		if message := decodeStatusMessage(data); message != "" && strings.Contains(resp.Header.Get("Content-Type"), "protobuf") {
+			return fmt.Errorf("collector returned %s: %s", resp.Status, message)
+		}
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+	return partialSuccess(data)
+}
+
+func (e *Exporter) exportGRPC(ctx context.Context, base *url.URL, method string, body []byte) error {
+	endpoint := base.JoinPath(method)
+	// Length-Prefixed-Message: not compressed, then the message length.
+	frame := make([]byte, 5, 5+len(body))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(body)))
+	frame = append(frame, body...)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.String(), bytes.NewReader(frame))
+	if err != nil {
+		return err
+	}
+	for k, v := range e.Headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("Te", "trailers")
+
+	resp, err := e.httpClient(base).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.ProtoMajor != 2 {
+		return fmt.Errorf("gRPC needs HTTP/2, but the collector answered over %s", resp.Proto)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+
+	// A failed call may come as headers only, without trailers.
+	status, message := resp.Trailer.Get("Grpc-Status"), resp.Trailer.Get("Grpc-Message")
+	if status == "" {
+		status, message = resp.Header.Get("Grpc-Status"), resp.Header.Get("Grpc-Message")
+	}
+	code, err := strconv.Atoi(status)
+	if err != nil {
+		return fmt.Errorf("collector sent no valid grpc-status")
+	}
+	if code != 0 {
+		if unescaped, err := url.PathUnescape(message); err == nil {
+			message = unescaped
+		}
+		return &GRPCError{Code: code, Message: message}
+	}
+	if len(data) < 5 {
+		return nil
+	}
+	return partialSuccess(data[5:])
+}
+
+// GRPCError is a gRPC call that failed with a non-OK status.
+type GRPCError struct {
+	Code    int
+	Message string
+}
+
+var grpcCodes = []string{
+	"OK", "CANCELLED", "UNKNOWN", "INVALID_ARGUMENT", "DEADLINE_EXCEEDED",
+	"NOT_FOUND", "ALREADY_EXISTS", "PERMISSION_DENIED", "RESOURCE_EXHAUSTED",
+	"FAILED_PRECONDITION", "ABORTED", "OUT_OF_RANGE", "UNIMPLEMENTED",
+	"INTERNAL", "UNAVAILABLE", "DATA_LOSS", "UNAUTHENTICATED",
+}
+
+func (e *GRPCError) Error() string {
+	name := strconv.Itoa(e.Code)
+	if e.Code >= 0 && e.Code < len(grpcCodes) {
+		name = grpcCodes[e.Code]
+	}
+	if e.Message == "" {
+		return "collector returned gRPC status " + name
+	}
+	return fmt.Sprintf("collector returned gRPC status %s: %s", name, e.Message)
+}
+
+// partialSuccess turns a response that rejected part of an export into an
+// error.
+func partialSuccess(response []byte) error {
+	rejected, message, err := decodePartialSuccess(response)
+	if err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	if rejected > 0 {
+		return fmt.Errorf("collector rejected %d items: %s", rejected, message)
+	}
+	return nil
+}
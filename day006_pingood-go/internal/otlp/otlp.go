@@ -0,0 +1,157 @@
+// Package otlp exports check results as OpenTelemetry traces and metrics
+// over OTLP/HTTP (binary protobuf) and OTLP/gRPC, and has a collector
+// stand-in to test against. Only the parts of the OTLP protocol that
+// pingood sends are implemented; the protobuf messages are encoded by hand
+// to keep the dependencies to the standard library.
+package otlp
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ScopeName is the instrumentation scope of everything pingood exports.
+const ScopeName = "github.com/junenu/solo-hackathon/day006_pingood-go"
+
+type TraceID [16]byte
+
+type SpanID [8]byte
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+
+func (id SpanID) String() string { return hex.EncodeToString(id[:]) }
+
+func (id SpanID) IsZero() bool { return id == SpanID{} }
+
+func NewTraceID() TraceID {
+	var id TraceID
+	rand.Read(id[:])
+	return id
+}
+
+func NewSpanID() SpanID {
+	var id SpanID
+	rand.Read(id[:])
+	return id
+}
+
+// KeyValue is an attribute. Value is a string, bool, int64 or float64.
+type KeyValue struct {
+	Key   string
+	Value any
+}
+
+// Attributes turns a map into attributes sorted by key.
+func Attributes(m map[string]string) []KeyValue {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	kvs := make([]KeyValue, 0, len(keys))
+	for _, k := range keys {
+		kvs = append(kvs, KeyValue{k, m[k]})
+	}
+	return kvs
+}
+
+func formatAttributes(kvs []KeyValue) string {
+	parts := make([]string, 0, len(kvs))
+	for _, kv := range kvs {
+		parts = append(parts, fmt.Sprintf("%s=%v", kv.Key, kv.Value))
+	}
+	return strings.Join(parts, " ")
+}
+
+type SpanKind int
+
+// Span kinds as numbered in the OTLP protobuf.
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindClient   SpanKind = 3
+)
+
+type StatusCode int
+
+// Span status codes as numbered in the OTLP protobuf.
+const (
+	StatusUnset StatusCode = 0
+	StatusOK    StatusCode = 1
+	StatusError StatusCode = 2
+)
+
+type Span struct {
+	TraceID       TraceID
+	SpanID        SpanID
+	ParentSpanID  SpanID
+	Name          string
+	Kind          SpanKind
+	Start, End    time.Time
+	Attributes    []KeyValue
+	Events        []Event
+	Status        StatusCode
+	StatusMessage string
+}
+
+// Event is something that happened at one moment during a span.
+type Event struct {
+	Time       time.Time
+	Name       string
+	Attributes []KeyValue
+}
+
+// Metric is a gauge if it has Gauge points and a histogram if it has
+// Histogram points.
+type Metric struct {
+	Name        string
+	Description string
+	Unit        string
+	Gauge       []NumberPoint
+	Histogram   []HistogramPoint
+}
+
+type NumberPoint struct {
+	Time       time.Time
+	Value      float64
+	Attributes []KeyValue
+}
+
+// HistogramPoint counts values into buckets: Counts[i] is the number of
+// values at most Bounds[i], and the last count those above every bound.
+type HistogramPoint struct {
+	Start, Time time.Time
+	Count       uint64
+	Sum         float64
+	Min, Max    float64
+	Bounds      []float64
+	Counts      []uint64
+	Attributes  []KeyValue
+}
+
+// NewHistogramPoint counts values into the buckets bounds defines.
+func NewHistogramPoint(start, end time.Time, values, bounds []float64, attributes []KeyValue) HistogramPoint {
+	p := HistogramPoint{
+		Start:      start,
+		Time:       end,
+		Count:      uint64(len(values)),
+		Bounds:     bounds,
+		Counts:     make([]uint64, len(bounds)+1),
+		Attributes: attributes,
+	}
+	for i, v := range values {
+		p.Sum += v
+		if i == 0 || v < p.Min {
+			p.Min = v
+		}
+		if i == 0 || v > p.Max {
+			p.Max = v
+		}
+		bucket := sort.SearchFloat64s(bounds, v)
+		p.Counts[bucket]++
+	}
+	return p
+}
@@ -0,0 +1,382 @@
+package otlp
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/h2c"
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/report"
+)
+
+var testStart = time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+
+var testTraceID, rootID, childID = NewTraceID(), NewSpanID(), NewSpanID()
+
+func testSpans() []Span {
+	return []Span{
+		{
+			TraceID:    testTraceID,
+			SpanID:     rootID,
+			Name:       "pingood",
+			Kind:       SpanKindInternal,
+			Start:      testStart,
+			End:        testStart.Add(2 * time.Second),
+			Attributes: []KeyValue{{"pingood.interface", "eth0"}, {"pingood.count", int64(3)}, {"pingood.ok", false}, {"pingood.ratio", 0.5}},
+			Status:     StatusError,
+			Events:     []Event{{Time: testStart.Add(time.Second), Name: "8.8.8.8", Attributes: []KeyValue{{"pingood.status", "pass"}}}},
+		},
+		{
+			TraceID:       testTraceID,
+			SpanID:        childID,
+			ParentSpanID:  rootID,
+			Name:          "dns",
+			Kind:          SpanKindClient,
+			Start:         testStart.Add(time.Millisecond),
+			End:           testStart.Add(5 * time.Millisecond),
+			Status:        StatusError,
+			StatusMessage: "no such host",
+		},
+	}
+}
+
+func testMetrics() []Metric {
+	attributes := []KeyValue{{"pingood.check", "ping"}, {"pingood.item", "8.8.8.8"}}
+	return []Metric{
+		{Name: "pingood.ping.avg", Unit: "ms", Gauge: []NumberPoint{{Time: testStart, Value: 12.5, Attributes: attributes}}},
+		{Name: "pingood.ping.loss", Unit: "%", Gauge: []NumberPoint{{Time: testStart, Value: 0, Attributes: attributes}}},
+		{Name: "pingood.rtt", Unit: "ms", Description: "Round-trip times", Histogram: []HistogramPoint{
+			NewHistogramPoint(testStart, testStart.Add(time.Second), []float64{0.5, 3, 3, 40, 5000}, rttBounds, attributes),
+		}},
+	}
+}
+
+// sameTimes makes decoded times comparable with DeepEqual.
+func sameTimes[T any](t *testing.T, got, want T) {
+	t.Helper()
+	if !reflect.DeepEqual(normalize(got), normalize(want)) {
+		t.Errorf("Expected\n%+v\ngot\n%+v", want, got)
+	}
+}
+
+func normalize(v any) any {
+	switch v := v.(type) {
+	case []Span:
+		out := append([]Span(nil), v...)
+		for i := range out {
+			out[i].Start, out[i].End = out[i].Start.UTC(), out[i].End.UTC()
+			out[i].Events = append([]Event(nil), out[i].Events...)
+			for j := range out[i].Events {
+				out[i].Events[j].Time = out[i].Events[j].Time.UTC()
+			}
+		}
+		return out
+	case []Metric:
+		out := append([]Metric(nil), v...)
+		for i := range out {
+			out[i].Gauge = append([]NumberPoint(nil), out[i].Gauge...)
+			for j := range out[i].Gauge {
+				out[i].Gauge[j].Time = out[i].Gauge[j].Time.UTC()
+			}
+			out[i].Histogram = append([]HistogramPoint(nil), out[i].Histogram...)
+			for j := range out[i].Histogram {
+				p := &out[i].Histogram[j]
+				p.Start, p.Time = p.Start.UTC(), p.Time.UTC()
+			}
+		}
+		return out
+	}
+	return v
+}
+
+func TestEncodeDecode(t *testing.T) {
+	resource := []KeyValue{{"service.name", "pingood"}}
+	gotResource, spans, err := decodeTraces(encodeTraces(resource, testSpans()))
+	if err != nil {
+		t.Fatalf("decodeTraces failed: %v", err)
+	}
+	if !reflect.DeepEqual(gotResource, resource) {
+		t.Errorf("Expected resource %v, got %v", resource, gotResource)
+	}
+	sameTimes(t, spans, testSpans())
+
+	_, metrics, err := decodeMetrics(encodeMetrics(resource, testMetrics()))
+	if err != nil {
+		t.Fatalf("decodeMetrics failed: %v", err)
+	}
+	sameTimes(t, metrics, testMetrics())
+
+	if _, _, err := decodeTraces([]byte{0x0a, 0x05, 0x01}); err == nil {
+		t.Error("Expected a truncated request to fail")
+	}
+}
+
+func TestNewHistogramPoint(t *testing.T) {
+	p := NewHistogramPoint(testStart, testStart, []float64{0.5, 1, 3, 3, 40, 5000}, []float64{1, 5, 100}, nil)
+	if want := []uint64{2, 2, 1, 1}; !reflect.DeepEqual(p.Counts, want) {
+		t.Errorf("Expected counts %v, got %v", want, p.Counts)
+	}
+	if p.Count != 6 || p.Sum != 5047.5 || p.Min != 0.5 || p.Max != 5000 {
+		t.Errorf("Unexpected point %+v", p)
+	}
+}
+
+func exportBoth(t *testing.T, e *Exporter) {
+	t.Helper()
+	ctx := context.Background()
+	if err := e.ExportTraces(ctx, testSpans()); err != nil {
+		t.Fatalf("ExportTraces failed: %v", err)
+	}
+	if err := e.ExportMetrics(ctx, testMetrics()); err != nil {
+		t.Fatalf("ExportMetrics failed: %v", err)
+	}
+}
+
+func checkCollected(t *testing.T, c *Collector, protocol Protocol) {
+	t.Helper()
+	exports := c.Exports()
+	if len(exports) != 2 || exports[0].Signal != "traces" || exports[1].Signal != "metrics" {
+		t.Fatalf("Expected a traces and a metrics export, got %+v", exports)
+	}
+	for _, e := range exports {
+		if e.Protocol != protocol {
+			t.Errorf("Expected %s, got %s", protocol, e.Protocol)
+		}
+		if got := e.Header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("Expected the configured header, got %q", got)
+		}
+		if len(e.Resource) != 1 || e.Resource[0].Value != "pingood" {
+			t.Errorf("Unexpected resource %v", e.Resource)
+		}
+	}
+	sameTimes(t, c.Spans(), testSpans())
+	sameTimes(t, c.Metrics(), testMetrics())
+}
+
+func newTestExporter(endpoint string, protocol Protocol) *Exporter {
+	return &Exporter{
+		Endpoint: endpoint,
+		Protocol: protocol,
+		Headers:  map[string]string{"Authorization": "Bearer secret"},
+		Resource: []KeyValue{{"service.name", "pingood"}},
+	}
+}
+
+func TestExportHTTP(t *testing.T) {
+	c := &Collector{}
+	server := httptest.NewServer(c)
+	defer server.Close()
+
+	exportBoth(t, newTestExporter(server.URL, ProtocolHTTP))
+	checkCollected(t, c, ProtocolHTTP)
+}
+
+func TestExportReusesConnections(t *testing.T) {
+	var mu sync.Mutex
+	conns := 0
+	server := httptest.NewUnstartedServer(&Collector{})
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mu.Lock()
+			conns++
+			mu.Unlock()
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	e := newTestExporter(server.URL, ProtocolHTTP)
+	for i := 0; i < 3; i++ {
+		exportBoth(t, e)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if conns != 1 {
+		t.Errorf("Expected the exports to share 1 connection, got %d", conns)
+	}
+}
+
+func TestExportGRPC(t *testing.T) {
+	c := &Collector{}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go c.Serve(l)
+
+	exportBoth(t, newTestExporter("http://"+l.Addr().String(), ProtocolGRPC))
+	checkCollected(t, c, ProtocolGRPC)
+
+	// The same listener takes OTLP/HTTP too.
+	if err := newTestExporter("http://"+l.Addr().String(), ProtocolHTTP).ExportTraces(context.Background(), testSpans()); err != nil {
+		t.Errorf("OTLP/HTTP on the shared listener failed: %v", err)
+	}
+}
+
+func TestExportGRPCOverTLS(t *testing.T) {
+	c := &Collector{}
+	server := httptest.NewUnstartedServer(c)
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	e := newTestExporter(server.URL, ProtocolGRPC)
+	e.TLSConfig = server.Client().Transport.(*http.Transport).TLSClientConfig
+	exportBoth(t, e)
+	checkCollected(t, c, ProtocolGRPC)
+
+	e = newTestExporter(server.URL, ProtocolGRPC)
+	e.TLSConfig = &tls.Config{}
+	if err := e.ExportTraces(context.Background(), testSpans()); err == nil {
+		t.Error("Expected an untrusted certificate to fail")
+	}
+}
+
+func TestExportErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/metrics" {
+			// A partial success rejecting two data points.
+			w.Header().Set("Content-Type", "application/x-protobuf")
+			w.Write(appendBytes(nil, 1, appendString(appendUint(nil, 1, 2), 2, "unknown unit")))
+			return
+		}
+		(&Collector{}).httpError(w, http.StatusBadRequest, "bad spans")
+	}))
+	defer server.Close()
+	e := newTestExporter(server.URL, ProtocolHTTP)
+	if err := e.ExportTraces(context.Background(), testSpans()); err == nil || !strings.Contains(err.Error(), "400 Bad Request: bad spans") {
+		t.Errorf("Expected the collector's message, got %v", err)
+	}
+	if err := e.ExportMetrics(context.Background(), testMetrics()); err == nil || !strings.Contains(err.Error(), "rejected 2 items: unknown unit") {
+		t.Errorf("Expected the partial success as an error, got %v", err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go (&h2c.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Grpc-Status", "14")
+		w.Header().Set("Grpc-Message", "collector%20is%20shutting%20down")
+	})}).Serve(l)
+	err = newTestExporter("http://"+l.Addr().String(), ProtocolGRPC).ExportTraces(context.Background(), testSpans())
+	var grpcErr *GRPCError
+	if !errors.As(err, &grpcErr) || grpcErr.Code != 14 || err.Error() != "failed to export 2 spans: collector returned gRPC status UNAVAILABLE: collector is shutting down" {
+		t.Errorf("Expected UNAVAILABLE, got %v", err)
+	}
+
+	if err := newTestExporter("localhost:4317", ProtocolGRPC).ExportTraces(context.Background(), testSpans()); err == nil {
+		t.Error("Expected an endpoint without a scheme to fail")
+	}
+}
+
+func TestParseProtocol(t *testing.T) {
+	for name, want := range map[string]Protocol{"": ProtocolHTTP, "http/protobuf": ProtocolHTTP, "gRPC": ProtocolGRPC} {
+		if got, err := ParseProtocol(name); err != nil || got != want {
+			t.Errorf("ParseProtocol(%q) = %q, %v", name, got, err)
+		}
+	}
+	if _, err := ParseProtocol("http/json"); err == nil {
+		t.Error("Expected http/json to be unsupported")
+	}
+}
+
+func TestRun(t *testing.T) {
+	run := NewRun("pingood", testStart, KeyValue{"pingood.interface", "eth0"})
+	run.Add(report.Section{ID: "ping", Title: "Ping Test", Items: []report.Item{
+		{Name: "8.8.8.8", Status: report.StatusPass, Metrics: map[string]float64{"avg": 12.5, "loss": 0}, Samples: []float64{12, 13}},
+		{Name: "1.1.1.1", Status: report.StatusFail, Summary: "100% loss", Metrics: map[string]float64{"loss": 100}},
+	}}, testStart.Add(3*time.Second))
+	run.Add(report.Section{ID: "http", Title: "HTTP Test", Items: []report.Item{
+		{Name: "https://example.com", Status: report.StatusPass, Metrics: map[string]float64{"duration_ms": 80}, Timings: []report.Timing{
+			{Name: "dns", Start: testStart.Add(3010 * time.Millisecond), Duration: 10 * time.Millisecond, Detail: "example.com 192.0.2.1"},
+			{Name: "connect", Start: testStart.Add(3020 * time.Millisecond), Duration: 20 * time.Millisecond},
+			{Name: "tls", Start: testStart.Add(3040 * time.Millisecond), Duration: 30 * time.Millisecond},
+			{Name: "http", Start: testStart.Add(3070 * time.Millisecond), Duration: 20 * time.Millisecond},
+		}},
+	}}, testStart.Add(4*time.Second))
+
+	spans := run.Spans()
+	var names []string
+	byName := map[string]Span{}
+	for _, s := range spans {
+		names = append(names, s.Name)
+		byName[s.Name] = s
+		if s.TraceID != spans[0].TraceID {
+			t.Errorf("Span %s is in another trace", s.Name)
+		}
+	}
+	if want := "pingood,ping,http,https://example.com,dns,connect,tls,http"; strings.Join(names, ",") != want {
+		t.Fatalf("Expected spans %s, got %s", want, strings.Join(names, ","))
+	}
+	root, ping, request := spans[0], spans[1], spans[3]
+	if root.Status != StatusError || root.StatusMessage != "failed checks: ping" || !root.End.Equal(testStart.Add(4*time.Second)) {
+		t.Errorf("Unexpected root span %+v", root)
+	}
+	if ping.ParentSpanID != root.SpanID || ping.StatusMessage != "1.1.1.1 failed" || len(ping.Events) != 2 || !ping.End.Equal(testStart.Add(3*time.Second)) {
+		t.Errorf("Unexpected ping span %+v", ping)
+	}
+	if request.ParentSpanID != spans[2].SpanID || request.Kind != SpanKindClient || !request.Start.Equal(testStart.Add(3010*time.Millisecond)) || !request.End.Equal(testStart.Add(3090*time.Millisecond)) {
+		t.Errorf("Unexpected request span %+v", request)
+	}
+	if spans[4].ParentSpanID != request.SpanID || spans[4].Attributes[0].Value != "example.com 192.0.2.1" {
+		t.Errorf("Unexpected dns span %+v", spans[4])
+	}
+
+	metrics := run.Metrics()
+	got := map[string]Metric{}
+	names = nil
+	for _, m := range metrics {
+		got[m.Name] = m
+		names = append(names, m.Name)
+	}
+	if want := "pingood.check.duration,pingood.check.status,pingood.http.duration,pingood.ping.avg,pingood.ping.loss,pingood.rtt"; strings.Join(names, ",") != want {
+		t.Fatalf("Expected metrics %s, got %s", want, strings.Join(names, ","))
+	}
+	if m := got["pingood.ping.loss"]; m.Unit != "%" || len(m.Gauge) != 2 || m.Gauge[1].Value != 100 {
+		t.Errorf("Unexpected loss metric %+v", m)
+	}
+	if m := got["pingood.http.duration"]; m.Unit != "ms" || m.Gauge[0].Value != 80 {
+		t.Errorf("Unexpected duration metric %+v", m)
+	}
+	if m := got["pingood.check.status"]; len(m.Gauge) != 3 || m.Gauge[1].Value != 0 {
+		t.Errorf("Unexpected status metric %+v", m)
+	}
+	if m := got["pingood.check.duration"]; m.Gauge[0].Value != 3000 || m.Gauge[1].Value != 1000 {
+		t.Errorf("Unexpected check durations %+v", m)
+	}
+	if m := got["pingood.rtt"]; len(m.Histogram) != 1 || m.Histogram[0].Count != 2 {
+		t.Errorf("Unexpected RTT histogram %+v", m)
+	}
+}
+
+func TestWriteExport(t *testing.T) {
+	var b strings.Builder
+	WriteExport(&b, Export{Protocol: ProtocolGRPC, Signal: "traces", Resource: []KeyValue{{"service.name", "pingood"}}, Spans: testSpans()})
+	WriteExport(&b, Export{Protocol: ProtocolHTTP, Signal: "metrics", Metrics: testMetrics()})
+	want := `grpc traces from service.name=pingood
+  trace ` + testTraceID.String() + `
+    pingood 2s ERROR
+      pingood.interface=eth0 pingood.count=3 pingood.ok=false pingood.ratio=0.5
+      * 8.8.8.8 pingood.status=pass
+      dns 4ms ERROR: no such host
+http/protobuf metrics
+  pingood.ping.avg 12.5 ms {pingood.check=ping pingood.item=8.8.8.8}
+  pingood.ping.loss 0 % {pingood.check=ping pingood.item=8.8.8.8}
+  pingood.rtt count=5 sum=5046.5 ms min=0.5 max=5000 {pingood.check=ping pingood.item=8.8.8.8}
+`
+	if b.String() != want {
+		t.Errorf("Expected\n%s\ngot\n%s", want, b.String())
+	}
+}
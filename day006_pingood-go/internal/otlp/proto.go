@@ -0,0 +1,603 @@
+package otlp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Protobuf wire types.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+func appendVarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+func appendTag(b []byte, field, wire int) []byte {
+	return appendVarint(b, uint64(field)<<3|uint64(wire))
+}
+
+// appendBytes writes a length-delimited field: a string, bytes or an
+// embedded message.
+func appendBytes(b []byte, field int, v []byte) []byte {
+	b = appendTag(b, field, wireBytes)
+	b = appendVarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+func appendString(b []byte, field int, s string) []byte {
+	if s == "" {
+		return b
+	}
+	return appendBytes(b, field, []byte(s))
+}
+
+func appendUint(b []byte, field int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = appendTag(b, field, wireVarint)
+	return appendVarint(b, v)
+}
+
+func appendFixed64(b []byte, field int, v uint64) []byte {
+	b = appendTag(b, field, wireFixed64)
+	return binary.LittleEndian.AppendUint64(b, v)
+}
+
+func appendDouble(b []byte, field int, v float64) []byte {
+	return appendFixed64(b, field, math.Float64bits(v))
+}
+
+func appendTime(b []byte, field int, t time.Time) []byte {
+	if t.IsZero() {
+		return b
+	}
+	return appendFixed64(b, field, uint64(t.UnixNano()))
+}
+
+func appendKeyValue(b []byte, field int, kv KeyValue) []byte {
+	var value []byte
+	switch v := kv.Value.(type) {
+	case string:
+		value = appendBytes(value, 1, []byte(v))
+	case bool:
+		value = appendTag(value, 2, wireVarint)
+		if v {
+			value = appendVarint(value, 1)
+		} else {
+			value = appendVarint(value, 0)
+		}
+	case int:
+		value = appendTag(value, 3, wireVarint)
+		value = appendVarint(value, uint64(v))
+	case int64:
+		value = appendTag(value, 3, wireVarint)
+		value = appendVarint(value, uint64(v))
+	case float64:
+		value = appendDouble(value, 4, v)
+	default:
+		value = appendBytes(value, 1, []byte(fmt.Sprint(v)))
+	}
+	var msg []byte
+	msg = appendString(msg, 1, kv.Key)
+	msg = appendBytes(msg, 2, value)
+	return appendBytes(b, field, msg)
+}
+
+func appendKeyValues(b []byte, field int, kvs []KeyValue) []byte {
+	for _, kv := range kvs {
+		b = appendKeyValue(b, field, kv)
+	}
+	return b
+}
+
+// appendResource and appendScope write the resource and the scope that
+// wrap the spans or metrics of an export request.
+func appendResource(b []byte, resource []KeyValue) []byte {
+	return appendBytes(b, 1, appendKeyValues(nil, 1, resource))
+}
+
+func appendScope(b []byte) []byte {
+	return appendBytes(b, 1, appendString(nil, 1, ScopeName))
+}
+
+// encodeTraces builds an ExportTraceServiceRequest.
+func encodeTraces(resource []KeyValue, spans []Span) []byte {
+	scopeSpans := appendScope(nil)
+	for _, s := range spans {
+		scopeSpans = appendBytes(scopeSpans, 2, encodeSpan(s))
+	}
+	resourceSpans := appendResource(nil, resource)
+	resourceSpans = appendBytes(resourceSpans, 2, scopeSpans)
+	return appendBytes(nil, 1, resourceSpans)
+}
+
+func encodeSpan(s Span) []byte {
+	var b []byte
+	b = appendBytes(b, 1, s.TraceID[:])
+	b = appendBytes(b, 2, s.SpanID[:])
+	if !s.ParentSpanID.IsZero() {
+		b = appendBytes(b, 4, s.ParentSpanID[:])
+	}
+	b = appendString(b, 5, s.Name)
+	b = appendUint(b, 6, uint64(s.Kind))
+	b = appendTime(b, 7, s.Start)
+	b = appendTime(b, 8, s.End)
+	b = appendKeyValues(b, 9, s.Attributes)
+	for _, e := range s.Events {
+		var event []byte
+		event = appendTime(event, 1, e.Time)
+		event = appendString(event, 2, e.Name)
+		event = appendKeyValues(event, 3, e.Attributes)
+		b = appendBytes(b, 11, event)
+	}
+	if s.Status != StatusUnset || s.StatusMessage != "" {
+		var status []byte
+		status = appendString(status, 2, s.StatusMessage)
+		status = appendUint(status, 3, uint64(s.Status))
+		b = appendBytes(b, 15, status)
+	}
+	return b
+}
+
+// encodeMetrics builds an ExportMetricsServiceRequest.
+func encodeMetrics(resource []KeyValue, metrics []Metric) []byte {
+	scopeMetrics := appendScope(nil)
+	for _, m := range metrics {
+		scopeMetrics = appendBytes(scopeMetrics, 2, encodeMetric(m))
+	}
+	resourceMetrics := appendResource(nil, resource)
+	resourceMetrics = appendBytes(resourceMetrics, 2, scopeMetrics)
+	return appendBytes(nil, 1, resourceMetrics)
+}
+
+// aggregationCumulative is AGGREGATION_TEMPORALITY_CUMULATIVE.
+const aggregationCumulative = 2
+
+func encodeMetric(m Metric) []byte {
+	var b []byte
+	b = appendString(b, 1, m.Name)
+	b = appendString(b, 2, m.Description)
+	b = appendString(b, 3, m.Unit)
+	switch {
+	case len(m.Histogram) > 0:
+		var histogram []byte
+		for _, p := range m.Histogram {
+			histogram = appendBytes(histogram, 1, encodeHistogramPoint(p))
+		}
+		histogram = appendUint(histogram, 2, aggregationCumulative)
+		b = appendBytes(b, 9, histogram)
+	default:
+		var gauge []byte
+		for _, p := range m.Gauge {
+			var point []byte
+			point = appendTime(point, 3, p.Time)
+			point = appendDouble(point, 4, p.Value)
+			point = appendKeyValues(point, 7, p.Attributes)
+			gauge = appendBytes(gauge, 1, point)
+		}
+		b = appendBytes(b, 5, gauge)
+	}
+	return b
+}
+
+func encodeHistogramPoint(p HistogramPoint) []byte {
+	var b []byte
+	b = appendTime(b, 2, p.Start)
+	b = appendTime(b, 3, p.Time)
+	b = appendFixed64(b, 4, p.Count)
+	b = appendDouble(b, 5, p.Sum)
+	var counts, bounds []byte
+	for _, c := range p.Counts {
+		counts = binary.LittleEndian.AppendUint64(counts, c)
+	}
+	for _, bound := range p.Bounds {
+		bounds = binary.LittleEndian.AppendUint64(bounds, math.Float64bits(bound))
+	}
+	b = appendBytes(b, 6, counts)
+	b = appendBytes(b, 7, bounds)
+	b = appendKeyValues(b, 9, p.Attributes)
+	if p.Count > 0 {
+		b = appendDouble(b, 11, p.Min)
+		b = appendDouble(b, 12, p.Max)
+	}
+	return b
+}
+
+// protoField is one decoded field. Varints and fixed-width values are in
+// num, length-delimited ones in bytes.
+type protoField struct {
+	field int
+	wire  int
+	num   uint64
+	bytes []byte
+}
+
+var errProtoTruncated = errors.New("truncated protobuf message")
+
+func readVarint(b []byte) (uint64, []byte, error) {
+	var v uint64
+	for shift := uint(0); shift < 64; shift += 7 {
+		if len(b) == 0 {
+			return 0, nil, errProtoTruncated
+		}
+		c := b[0]
+		b = b[1:]
+		v |= uint64(c&0x7f) << shift
+		if c&0x80 == 0 {
+			return v, b, nil
+		}
+	}
+	return 0, nil, errors.New("protobuf varint overflows")
+}
+
+func parseFields(b []byte) ([]protoField, error) {
+	var fields []protoField
+	for len(b) > 0 {
+		tag, rest, err := readVarint(b)
+		if err != nil {
+			return nil, err
+		}
+		b = rest
+		f := protoField{field: int(tag >> 3), wire: int(tag & 7)}
+		switch f.wire {
+		case wireVarint:
+			if f.num, b, err = readVarint(b); err != nil {
+				return nil, err
+			}
+		case wireFixed64:
+			if len(b) < 8 {
+				return nil, errProtoTruncated
+			}
+			f.num, b = binary.LittleEndian.Uint64(b), b[8:]
+		case wireFixed32:
+			if len(b) < 4 {
+				return nil, errProtoTruncated
+			}
+			f.num, b = uint64(binary.LittleEndian.Uint32(b)), b[4:]
+		case wireBytes:
+			var length uint64
+			if length, b, err = readVarint(b); err != nil {
+				return nil, err
+			}
+			if uint64(len(b)) < length {
+				return nil, errProtoTruncated
+			}
+			f.bytes, b = b[:length], b[length:]
+		default:
+			return nil, fmt.Errorf("unsupported protobuf wire type %d", f.wire)
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// messages returns the embedded messages in field, parsed.
+func messages(fields []protoField, field int) ([][]protoField, error) {
+	var msgs [][]protoField
+	for _, f := range fields {
+		if f.field != field || f.wire != wireBytes {
+			continue
+		}
+		msg, err := parseFields(f.bytes)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+func decodeTime(v uint64) time.Time {
+	if v == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(v))
+}
+
+func decodeKeyValues(fields []protoField, field int) ([]KeyValue, error) {
+	msgs, err := messages(fields, field)
+	if err != nil {
+		return nil, err
+	}
+	var kvs []KeyValue
+	for _, msg := range msgs {
+		var kv KeyValue
+		for _, f := range msg {
+			switch f.field {
+			case 1:
+				kv.Key = string(f.bytes)
+			case 2:
+				value, err := parseFields(f.bytes)
+				if err != nil {
+					return nil, err
+				}
+				for _, v := range value {
+					switch v.field {
+					case 1:
+						kv.Value = string(v.bytes)
+					case 2:
+						kv.Value = v.num != 0
+					case 3:
+						kv.Value = int64(v.num)
+					case 4:
+						kv.Value = math.Float64frombits(v.num)
+					}
+				}
+			}
+		}
+		kvs = append(kvs, kv)
+	}
+	return kvs, nil
+}
+
+// resourceAndScoped walks the ResourceSpans or ResourceMetrics of an
+// export request and calls item for every span or metric, with the
+// resource attributes.
+func resourceAndScoped(b []byte, item func(resource []KeyValue, msg []protoField) error) error {
+	fields, err := parseFields(b)
+	if err != nil {
+		return err
+	}
+	resources, err := messages(fields, 1)
+	if err != nil {
+		return err
+	}
+	for _, rf := range resources {
+		var resource []KeyValue
+		rs, err := messages(rf, 1)
+		if err != nil {
+			return err
+		}
+		for _, r := range rs {
+			attrs, err := decodeKeyValues(r, 1)
+			if err != nil {
+				return err
+			}
+			resource = append(resource, attrs...)
+		}
+		scopes, err := messages(rf, 2)
+		if err != nil {
+			return err
+		}
+		for _, scope := range scopes {
+			items, err := messages(scope, 2)
+			if err != nil {
+				return err
+			}
+			for _, msg := range items {
+				if err := item(resource, msg); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// decodeTraces reads an ExportTraceServiceRequest.
+func decodeTraces(b []byte) (resource []KeyValue, spans []Span, err error) {
+	err = resourceAndScoped(b, func(r []KeyValue, msg []protoField) error {
+		resource = r
+		var s Span
+		for _, f := range msg {
+			switch f.field {
+			case 1:
+				copy(s.TraceID[:], f.bytes)
+			case 2:
+				copy(s.SpanID[:], f.bytes)
+			case 4:
+				copy(s.ParentSpanID[:], f.bytes)
+			case 5:
+				s.Name = string(f.bytes)
+			case 6:
+				s.Kind = SpanKind(f.num)
+			case 7:
+				s.Start = decodeTime(f.num)
+			case 8:
+				s.End = decodeTime(f.num)
+			case 15:
+				status, err := parseFields(f.bytes)
+				if err != nil {
+					return err
+				}
+				for _, sf := range status {
+					switch sf.field {
+					case 2:
+						s.StatusMessage = string(sf.bytes)
+					case 3:
+						s.Status = StatusCode(sf.num)
+					}
+				}
+			}
+		}
+		var err error
+		if s.Attributes, err = decodeKeyValues(msg, 9); err != nil {
+			return err
+		}
+		events, err := messages(msg, 11)
+		if err != nil {
+			return err
+		}
+		for _, ef := range events {
+			var e Event
+			for _, f := range ef {
+				switch f.field {
+				case 1:
+					e.Time = decodeTime(f.num)
+				case 2:
+					e.Name = string(f.bytes)
+				}
+			}
+			if e.Attributes, err = decodeKeyValues(ef, 3); err != nil {
+				return err
+			}
+			s.Events = append(s.Events, e)
+		}
+		spans = append(spans, s)
+		return nil
+	})
+	return resource, spans, err
+}
+
+// decodeMetrics reads an ExportMetricsServiceRequest. Sums are read as
+// gauges.
+func decodeMetrics(b []byte) (resource []KeyValue, metrics []Metric, err error) {
+	err = resourceAndScoped(b, func(r []KeyValue, msg []protoField) error {
+		resource = r
+		var m Metric
+		for _, f := range msg {
+			switch f.field {
+			case 1:
+				m.Name = string(f.bytes)
+			case 2:
+				m.Description = string(f.bytes)
+			case 3:
+				m.Unit = string(f.bytes)
+			case 5, 7:
+				data, err := parseFields(f.bytes)
+				if err != nil {
+					return err
+				}
+				dps, err := messages(data, 1)
+				if err != nil {
+					return err
+				}
+				for _, dp := range dps {
+					p, err := decodeNumberPoint(dp)
+					if err != nil {
+						return err
+					}
+					m.Gauge = append(m.Gauge, p)
+				}
+			case 9:
+				data, err := parseFields(f.bytes)
+				if err != nil {
+					return err
+				}
+				dps, err := messages(data, 1)
+				if err != nil {
+					return err
+				}
+				for _, dp := range dps {
+					p, err := decodeHistogramPoint(dp)
+					if err != nil {
+						return err
+					}
+					m.Histogram = append(m.Histogram, p)
+				}
+			}
+		}
+		metrics = append(metrics, m)
+		return nil
+	})
+	return resource, metrics, err
+}
+
+func decodeNumberPoint(fields []protoField) (NumberPoint, error) {
+	var p NumberPoint
+	for _, f := range fields {
+		switch f.field {
+		case 3:
+			p.Time = decodeTime(f.num)
+		case 4:
+			p.Value = math.Float64frombits(f.num)
+		case 6:
+			p.Value = float64(int64(f.num))
+		}
+	}
+	var err error
+	p.Attributes, err = decodeKeyValues(fields, 7)
+	return p, err
+}
+
+func decodeHistogramPoint(fields []protoField) (HistogramPoint, error) {
+	var p HistogramPoint
+	for _, f := range fields {
+		switch {
+		case f.field == 2:
+			p.Start = decodeTime(f.num)
+		case f.field == 3:
+			p.Time = decodeTime(f.num)
+		case f.field == 4:
+			p.Count = f.num
+		case f.field == 5:
+			p.Sum = math.Float64frombits(f.num)
+		case f.field == 6 && f.wire == wireBytes:
+			for b := f.bytes; len(b) >= 8; b = b[8:] {
+				p.Counts = append(p.Counts, binary.LittleEndian.Uint64(b))
+			}
+		case f.field == 6:
+			p.Counts = append(p.Counts, f.num)
+		case f.field == 7 && f.wire == wireBytes:
+			for b := f.bytes; len(b) >= 8; b = b[8:] {
+				p.Bounds = append(p.Bounds, math.Float64frombits(binary.LittleEndian.Uint64(b)))
+			}
+		case f.field == 7:
+			p.Bounds = append(p.Bounds, math.Float64frombits(f.num))
+		case f.field == 11:
+			p.Min = math.Float64frombits(f.num)
+		case f.field == 12:
+			p.Max = math.Float64frombits(f.num)
+		}
+	}
+	var err error
+	p.Attributes, err = decodeKeyValues(fields, 9)
+	return p, err
+}
+
+// decodePartialSuccess reads the partial_success of an export response:
+// how many spans or data points the collector rejected, and why.
+func decodePartialSuccess(b []byte) (rejected int64, message string, err error) {
+	fields, err := parseFields(b)
+	if err != nil {
+		return 0, "", err
+	}
+	partial, err := messages(fields, 1)
+	if err != nil {
+		return 0, "", err
+	}
+	for _, msg := range partial {
+		for _, f := range msg {
+			switch f.field {
+			case 1:
+				rejected = int64(f.num)
+			case 2:
+				message = string(f.bytes)
+			}
+		}
+	}
+	return rejected, message, nil
+}
+
+// encodeStatus builds a google.rpc.Status, the body of OTLP/HTTP errors.
+func encodeStatus(code int, message string) []byte {
+	var b []byte
+	b = appendUint(b, 1, uint64(code))
+	return appendString(b, 2, message)
+}
+
+func decodeStatusMessage(b []byte) string {
+	fields, err := parseFields(b)
+	if err != nil {
+		return ""
+	}
+	for _, f := range fields {
+		if f.field == 2 && f.wire == wireBytes {
+			return string(f.bytes)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,276 @@
+package otlp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/report"
+)
+
+// rttBounds are the histogram buckets of pingood.rtt, in milliseconds.
+var rttBounds = []float64{1, 2, 5, 10, 20, 50, 100, 200, 500, 1000, 2000}
+
+// Run is one pingood run, or one scheduled run of a check, to export as a
+// trace. Sections are added as they finish, so their spans cover the time
+// each check took.
+type Run struct {
+	Name       string
+	Start      time.Time
+	Attributes []KeyValue
+
+	sections []timedSection
+	last     time.Time
+}
+
+type timedSection struct {
+	report.Section
+	start, end time.Time
+}
+
+func NewRun(name string, start time.Time, attributes ...KeyValue) *Run {
+	return &Run{Name: name, Start: start, Attributes: attributes, last: start}
+}
+
+// Add records a section that finished at end and started when the section
+// before it finished.
+func (r *Run) Add(section report.Section, end time.Time) {
+	r.sections = append(r.sections, timedSection{Section: section, start: r.last, end: end})
+	r.last = end
+}
+
+// Spans returns the trace of the run: a root span with a span per section.
+// Items with timings, such as HTTP requests, get a span of their own with
+// a child span per timing; other items become events of their section's
+// span.
+func (r *Run) Spans() []Span {
+	traceID := NewTraceID()
+	root := Span{
+		TraceID:    traceID,
+		SpanID:     NewSpanID(),
+		Name:       r.Name,
+		Kind:       SpanKindInternal,
+		Start:      r.Start,
+		End:        r.last,
+		Attributes: r.Attributes,
+	}
+	var failed []string
+	spans := []Span{root}
+	for _, s := range r.sections {
+		section := Span{
+			TraceID:      traceID,
+			SpanID:       NewSpanID(),
+			ParentSpanID: root.SpanID,
+			Name:         s.ID,
+			Kind:         SpanKindInternal,
+			Start:        s.start,
+			End:          s.end,
+			Attributes: []KeyValue{
+				{"pingood.check", s.ID},
+				{"pingood.title", s.Title},
+				{"pingood.result", string(s.Status())},
+			},
+		}
+		if s.Summary != "" {
+			section.Attributes = append(section.Attributes, KeyValue{"pingood.summary", s.Summary})
+		}
+		switch s.Status() {
+		case report.StatusPass:
+			section.Status = StatusOK
+		case report.StatusFail:
+			section.Status, section.StatusMessage = StatusError, failureMessage(s.Section)
+			failed = append(failed, s.ID)
+		}
+
+		var children []Span
+		for _, item := range s.Items {
+			if len(item.Timings) == 0 {
+				section.Events = append(section.Events, Event{Time: s.end, Name: item.Name, Attributes: itemAttributes(item)})
+				continue
+			}
+			children = append(children, itemSpans(traceID, section.SpanID, item)...)
+		}
+		spans = append(spans, section)
+		spans = append(spans, children...)
+	}
+	if len(failed) > 0 {
+		spans[0].Status = StatusError
+		spans[0].StatusMessage = "failed checks: " + strings.Join(failed, ", ")
+	}
+	return spans
+}
+
+// itemSpans returns a span for item covering all its timings, and a child
+// span for each of them.
+func itemSpans(traceID TraceID, parent SpanID, item report.Item) []Span {
+	span := Span{
+		TraceID:      traceID,
+		SpanID:       NewSpanID(),
+		ParentSpanID: parent,
+		Name:         item.Name,
+		Kind:         SpanKindClient,
+		Attributes:   itemAttributes(item),
+	}
+	switch item.Status {
+	case report.StatusPass:
+		span.Status = StatusOK
+	case report.StatusFail:
+		span.Status, span.StatusMessage = StatusError, item.Summary
+	}
+	spans := []Span{span}
+	for _, timing := range item.Timings {
+		end := timing.Start.Add(timing.Duration)
+		if spans[0].Start.IsZero() || timing.Start.Before(spans[0].Start) {
+			spans[0].Start = timing.Start
+		}
+		if end.After(spans[0].End) {
+			spans[0].End = end
+		}
+		child := Span{
+			TraceID:      traceID,
+			SpanID:       NewSpanID(),
+			ParentSpanID: span.SpanID,
+			Name:         timing.Name,
+			Kind:         SpanKindInternal,
+			Start:        timing.Start,
+			End:          end,
+		}
+		if timing.Detail != "" {
+			child.Attributes = []KeyValue{{"pingood.detail", timing.Detail}}
+		}
+		if timing.Error != "" {
+			child.Status, child.StatusMessage = StatusError, timing.Error
+		}
+		spans = append(spans, child)
+	}
+	return spans
+}
+
+func itemAttributes(item report.Item) []KeyValue {
+	kvs := []KeyValue{
+		{"pingood.item", item.Name},
+		{"pingood.result", string(item.Status)},
+	}
+	if item.Summary != "" {
+		kvs = append(kvs, KeyValue{"pingood.summary", item.Summary})
+	}
+	for _, kv := range Attributes(item.Attributes) {
+		kvs = append(kvs, KeyValue{"pingood." + kv.Key, kv.Value})
+	}
+	keys := make([]string, 0, len(item.Metrics))
+	for key := range item.Metrics {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		kvs = append(kvs, KeyValue{"pingood." + key, item.Metrics[key]})
+	}
+	return kvs
+}
+
+// failureMessage names what failed in a section.
+func failureMessage(section report.Section) string {
+	if section.Error != "" {
+		return section.Error
+	}
+	var failed []string
+	for _, item := range section.Items {
+		if item.Status == report.StatusFail {
+			failed = append(failed, item.Name)
+		}
+	}
+	return fmt.Sprintf("%s failed", strings.Join(failed, ", "))
+}
+
+// Metrics returns, for every section, a gauge per item metric named
+// pingood.<check>.<metric>, the pingood.check.status of each item that
+// passed (1) or failed (0), the pingood.check.duration of the section and a
+// pingood.rtt histogram for items with RTT samples.
+func (r *Run) Metrics() []Metric {
+	byName := make(map[string]*Metric)
+	var names []string
+	metric := func(name, unit, description string) *Metric {
+		m, ok := byName[name]
+		if !ok {
+			m = &Metric{Name: name, Unit: unit, Description: description}
+			byName[name] = m
+			names = append(names, name)
+		}
+		return m
+	}
+
+	for _, s := range r.sections {
+		check := KeyValue{"pingood.check", s.ID}
+		duration := metric("pingood.check.duration", "ms", "How long the check took")
+		duration.Gauge = append(duration.Gauge, NumberPoint{Time: s.end, Value: float64(s.end.Sub(s.start)) / float64(time.Millisecond), Attributes: []KeyValue{check}})
+
+		status := metric("pingood.check.status", "1", "1 if the check passed, 0 if it failed")
+		if s.Error != "" {
+			status.Gauge = append(status.Gauge, NumberPoint{Time: s.end, Value: 0, Attributes: []KeyValue{check}})
+		}
+		for _, item := range s.Items {
+			attributes := []KeyValue{check, {"pingood.item", item.Name}}
+			switch item.Status {
+			case report.StatusPass:
+				status.Gauge = append(status.Gauge, NumberPoint{Time: s.end, Value: 1, Attributes: attributes})
+			case report.StatusFail:
+				status.Gauge = append(status.Gauge, NumberPoint{Time: s.end, Value: 0, Attributes: attributes})
+			}
+
+			keys := make([]string, 0, len(item.Metrics))
+			for key := range item.Metrics {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				name, unit := metricName(key)
+				m := metric("pingood."+s.ID+"."+name, unit, "")
+				m.Gauge = append(m.Gauge, NumberPoint{Time: s.end, Value: item.Metrics[key], Attributes: attributes})
+			}
+
+			if len(item.Samples) > 0 {
+				rtt := metric("pingood.rtt", "ms", "Round-trip times measured by the check")
+				rtt.Histogram = append(rtt.Histogram, NewHistogramPoint(s.start, s.end, item.Samples, rttBounds, attributes))
+			}
+		}
+	}
+
+	sort.Strings(names)
+	metrics := make([]Metric, 0, len(names))
+	for _, name := range names {
+		if m := byName[name]; len(m.Gauge) > 0 || len(m.Histogram) > 0 {
+			metrics = append(metrics, *m)
+		}
+	}
+	return metrics
+}
+
+// millisecondMetrics are the ping statistics, which are in milliseconds
+// without saying so in their name.
+var millisecondMetrics = map[string]bool{
+	"min": true, "avg": true, "max": true, "stddev": true, "jitter": true,
+	"p50": true, "p95": true, "p99": true,
+}
+
+// metricName takes the unit out of a report metric name, such as
+// "duration_ms", and returns it in UCUM as OTLP expects.
+func metricName(key string) (name, unit string) {
+	for _, suffix := range []struct{ suffix, unit string }{
+		{"_ms", "ms"},
+		{"_s", "s"},
+		{"_mbps", "Mbit/s"},
+		{"_bytes", "By"},
+	} {
+		if name, ok := strings.CutSuffix(key, suffix.suffix); ok {
+			return name, suffix.unit
+		}
+	}
+	switch {
+	case key == "loss":
+		return key, "%"
+	case millisecondMetrics[key]:
+		return key, "ms"
+	}
+	return key, "1"
+}
@@ -0,0 +1,292 @@
+// Package readiness grades how ready a host is for IPv6. It combines the
+// sections pingood already ran (addresses, router advertisements, AAAA
+// lookups, IPv6-only HTTP) with a few IPv6-specific probes into one score,
+// and explains every point that was lost.
+package readiness
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/checker"
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/report"
+)
+
+// Input is what the scorecard is computed from. Report holds the sections
+// already run; the rest are the results of the IPv6 probes.
+type Input struct {
+	Report *report.Report
+
+	// Route is the route to a global IPv6 destination.
+	Route    checker.RouteLookup
+	RouteErr error
+
+	// Resolvers are the IPv6 DNS servers, each queried over IPv6.
+	Resolvers    []checker.DNSProbe
+	ResolversErr error
+
+	NAT64      netip.Prefix
+	NAT64Found bool
+	NAT64Err   error
+
+	// Preference is nil when there is no dual-stack target to try.
+	Preference *checker.FamilyPreference
+}
+
+// Criterion is one graded aspect. A criterion with Status info could not
+// be assessed and does not count towards the score.
+type Criterion struct {
+	Name        string
+	Points, Max int
+	Status      report.Status
+	Explanation string
+}
+
+type Scorecard struct {
+	Criteria []Criterion
+	// Score is the percentage of the points available from the assessed
+	// criteria.
+	Score   int
+	Grade   string
+	Verdict string
+}
+
+var grades = []struct {
+	min            int
+	grade, verdict string
+}{
+	{90, "A", "IPv6-ready"},
+	{75, "B", "mostly IPv6-ready"},
+	{50, "C", "partially IPv6-ready"},
+	{25, "D", "IPv6 barely works"},
+	{0, "F", "not IPv6-ready"},
+}
+
+// Evaluate grades in. Each criterion is worth a fixed number of points:
+// address 20, router advertisement and route 15, AAAA lookups 15, DNS over
+// IPv6 10, IPv6-only HTTP 20, DNS64/NAT64 10 and Happy Eyeballs 10.
+func Evaluate(in Input) Scorecard {
+	var card Scorecard
+	card.Criteria = []Criterion{
+		globalAddress(in),
+		defaultRoute(in),
+		fromSection(in.Report, "dns_aaaa", "AAAA resolution", 15,
+			"every AAAA lookup succeeded",
+			"some AAAA lookups failed",
+			"AAAA lookups fail, so clients never try IPv6",
+			"no DOMAIN_AAAA_RECORDS configured"),
+		dnsTransport(in),
+		fromSection(in.Report, "http_ipv6", "IPv6-only HTTP", 20,
+			"HTTP over IPv6 works",
+			"some HTTP targets fail over IPv6",
+			"HTTP over IPv6 fails: an IPv6-only client could not browse",
+			"no HTTP_IPV6_TARGET configured"),
+		nat64(in),
+		happyEyeballs(in),
+	}
+
+	points, max := 0, 0
+	for _, c := range card.Criteria {
+		if c.Status != report.StatusInfo {
+			points += c.Points
+			max += c.Max
+		}
+	}
+	if max > 0 {
+		card.Score = points * 100 / max
+	}
+	for _, g := range grades {
+		if card.Score >= g.min {
+			card.Grade, card.Verdict = g.grade, g.verdict
+			break
+		}
+	}
+	return card
+}
+
+func graded(name string, points, max int, explanation string) Criterion {
+	status := report.StatusPass
+	if points < max {
+		status = report.StatusFail
+	}
+	return Criterion{Name: name, Points: points, Max: max, Status: status, Explanation: explanation}
+}
+
+func skipped(name string, max int, explanation string) Criterion {
+	return Criterion{Name: name, Max: max, Status: report.StatusInfo, Explanation: explanation}
+}
+
+func item(r *report.Report, section, name string) (report.Item, bool) {
+	if r == nil {
+		return report.Item{}, false
+	}
+	s, ok := r.Section(section)
+	if !ok {
+		return report.Item{}, false
+	}
+	return s.Item(name)
+}
+
+func hasIPv4(r *report.Report) bool {
+	ipv4, ok := item(r, "ip", "IPv4")
+	return ok && ipv4.Status == report.StatusPass
+}
+
+var ulaPrefix = netip.MustParsePrefix("fc00::/7")
+
+func globalAddress(in Input) Criterion {
+	const name, max = "Global address", 20
+	ipv6, ok := item(in.Report, "ip", "IPv6")
+	if !ok {
+		return skipped(name, max, "the IP address check did not run")
+	}
+	addr, err := netip.ParseAddr(ipv6.Summary)
+	switch {
+	case ipv6.Status != report.StatusPass || err != nil:
+		return graded(name, 0, max, "no IPv6 address besides link-local: the network does not offer IPv6 or SLAAC/DHCPv6 failed")
+	case ulaPrefix.Contains(addr):
+		return graded(name, 5, max, fmt.Sprintf("only a unique local address (%s), which cannot reach the Internet", addr))
+	case !addr.IsGlobalUnicast():
+		return graded(name, 0, max, fmt.Sprintf("%s is not a global unicast address", addr))
+	}
+	return graded(name, max, max, fmt.Sprintf("global address %s", addr))
+}
+
+func defaultRoute(in Input) Criterion {
+	const name, max = "Router advertisement and default route", 15
+	var routers []string
+	if in.Report != nil {
+		if gateway, ok := in.Report.Section("gateway"); ok {
+			for _, it := range gateway.Items {
+				if strings.HasPrefix(it.Name, "RA ") {
+					routers = append(routers, strings.TrimPrefix(it.Name, "RA "))
+				}
+			}
+		}
+	}
+	route := in.RouteErr == nil && in.Route.Gateway != ""
+	switch {
+	case route && len(routers) > 0:
+		return graded(name, max, max, fmt.Sprintf("router advertisement from %s, default route via %s", strings.Join(routers, ", "), in.Route.Gateway))
+	case route:
+		return graded(name, 12, max, fmt.Sprintf("default route via %s, but no router advertisement was seen: static configuration or RAs not captured", in.Route.Gateway))
+	case len(routers) > 0:
+		return graded(name, 5, max, fmt.Sprintf("router advertisement from %s, but no IPv6 default route: the RA may have a router lifetime of 0", strings.Join(routers, ", ")))
+	}
+	explanation := "no router advertisement and no IPv6 default route"
+	if in.RouteErr != nil {
+		explanation += fmt.Sprintf(" (%v)", in.RouteErr)
+	}
+	return graded(name, 0, max, explanation)
+}
+
+// fromSection grades a criterion by the state of a section: full points
+// if every item passed, half if only some did.
+func fromSection(r *report.Report, id, name string, max int, pass, partial, fail, missing string) Criterion {
+	var section report.Section
+	ok := false
+	if r != nil {
+		section, ok = r.Section(id)
+	}
+	if !ok {
+		return skipped(name, max, missing)
+	}
+	var passed, failed int
+	for _, it := range section.Items {
+		switch it.Status {
+		case report.StatusPass:
+			passed++
+		case report.StatusFail:
+			failed++
+		}
+	}
+	switch {
+	case section.Error != "":
+		return graded(name, 0, max, fmt.Sprintf("%s (%s)", fail, section.Error))
+	case passed == 0 && failed == 0:
+		return skipped(name, max, missing)
+	case failed == 0:
+		return graded(name, max, max, pass)
+	case passed > 0:
+		return graded(name, max/2, max, fmt.Sprintf("%s (%d of %d)", partial, failed, passed+failed))
+	}
+	return graded(name, 0, max, fail)
+}
+
+func dnsTransport(in Input) Criterion {
+	const name, max = "DNS over IPv6", 10
+	if in.ResolversErr != nil {
+		return skipped(name, max, in.ResolversErr.Error())
+	}
+	if len(in.Resolvers) == 0 {
+		return graded(name, 0, max, "no IPv6 DNS server configured: fine while IPv4 works, but an IPv6-only client could not resolve names (check RDNSS in the RA or DHCPv6)")
+	}
+	var ok, failed []string
+	for _, probe := range in.Resolvers {
+		if probe.Error != nil {
+			failed = append(failed, fmt.Sprintf("%s (%v)", probe.Server, probe.Error))
+		} else {
+			ok = append(ok, fmt.Sprintf("%s (%.0f ms)", probe.Server, float64(probe.RTT.Microseconds())/1000))
+		}
+	}
+	switch {
+	case len(failed) == 0:
+		return graded(name, max, max, "IPv6 DNS servers answer over IPv6: "+strings.Join(ok, ", "))
+	case len(ok) > 0:
+		return graded(name, max/2, max, "some IPv6 DNS servers do not answer: "+strings.Join(failed, ", "))
+	}
+	return graded(name, 0, max, "no IPv6 DNS server answers: "+strings.Join(failed, ", "))
+}
+
+func nat64(in Input) Criterion {
+	const name, max = "DNS64/NAT64", 10
+	ipv4 := hasIPv4(in.Report)
+	switch {
+	case in.NAT64Found && ipv4:
+		return graded(name, max, max, fmt.Sprintf("DNS64 synthesizes addresses in %s, although the host also has IPv4", in.NAT64))
+	case in.NAT64Found:
+		return graded(name, max, max, fmt.Sprintf("DNS64 synthesizes addresses in %s, so IPv4-only sites are reachable through NAT64", in.NAT64))
+	case in.NAT64Err != nil:
+		return skipped(name, max, in.NAT64Err.Error())
+	case ipv4:
+		return graded(name, max, max, "no DNS64, and none needed since the host has IPv4")
+	}
+	return graded(name, 0, max, "IPv6-only without DNS64: IPv4-only sites are unreachable")
+}
+
+func happyEyeballs(in Input) Criterion {
+	const name, max = "Happy Eyeballs preference", 10
+	p := in.Preference
+	if p == nil {
+		return skipped(name, max, "no dual-stack target to connect to")
+	}
+	timing := fmt.Sprintf("IPv6 %s, IPv4 %s", connectTime(p.IPv6, p.IPv6Err), connectTime(p.IPv4, p.IPv4Err))
+	switch {
+	case p.Chosen == "IPv6":
+		return graded(name, max, max, fmt.Sprintf("connections to %s use IPv6 (%s)", p.Address, timing))
+	case p.IPv6Err != nil && noAddress(p.IPv6Err):
+		return skipped(name, max, fmt.Sprintf("%s has no IPv6 address", p.Address))
+	case p.Chosen == "IPv4" && p.IPv6Err != nil:
+		return graded(name, 0, max, fmt.Sprintf("connections to %s fall back to IPv4 because IPv6 fails (%s): clients wait for IPv6 before falling back", p.Address, timing))
+	case p.Chosen == "IPv4":
+		return graded(name, max/2, max, fmt.Sprintf("connections to %s use IPv4 although IPv6 works (%s): IPv6 is slower or address selection prefers IPv4", p.Address, timing))
+	}
+	return graded(name, 0, max, fmt.Sprintf("cannot connect to %s: %v", p.Address, p.ChosenErr))
+}
+
+func connectTime(rtt time.Duration, err error) string {
+	if err != nil {
+		return "failed"
+	}
+	return fmt.Sprintf("%.0f ms", float64(rtt.Microseconds())/1000)
+}
+
+func noAddress(err error) bool {
+	var addrErr *net.AddrError
+	var dnsErr *net.DNSError
+	return errors.As(err, &addrErr) && addrErr.Err == "no suitable address found" || errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
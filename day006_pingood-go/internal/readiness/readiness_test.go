@@ -0,0 +1,151 @@
+package readiness
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/checker"
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/report"
+)
+
+func buildReport(ipv4, ipv6 string, sections ...report.Section) *report.Report {
+	r := &report.Report{}
+	ip := report.Section{ID: "ip"}
+	for _, addr := range []struct{ name, value string }{{"IPv4", ipv4}, {"IPv6", ipv6}} {
+		if addr.value != "" {
+			ip.Items = append(ip.Items, report.Item{Name: addr.name, Status: report.StatusPass, Summary: addr.value})
+		} else {
+			ip.Items = append(ip.Items, report.Item{Name: addr.name, Status: report.StatusFail, Summary: "Not found"})
+		}
+	}
+	r.Add(ip)
+	for _, s := range sections {
+		r.Add(s)
+	}
+	return r
+}
+
+func section(id string, statuses ...report.Status) report.Section {
+	s := report.Section{ID: id}
+	for i, status := range statuses {
+		s.Items = append(s.Items, report.Item{Name: string(rune('a' + i)), Status: status})
+	}
+	return s
+}
+
+func criterion(card Scorecard, name string) Criterion {
+	for _, c := range card.Criteria {
+		if c.Name == name {
+			return c
+		}
+	}
+	return Criterion{}
+}
+
+func TestEvaluate(t *testing.T) {
+	gateway := report.Section{ID: "gateway", Items: []report.Item{{Name: "RA fe80::1", Status: report.StatusInfo}}}
+	healthyDNS := []checker.DNSProbe{{Server: netip.MustParseAddr("2001:db8::53"), RTT: 3 * time.Millisecond}}
+	noAAAA := &net.AddrError{Err: "no suitable address found", Addr: "ipv4only.example"}
+
+	tests := []struct {
+		name  string
+		input Input
+		grade string
+		score int
+		// failed lists the criteria expected to lose points.
+		failed []string
+	}{
+		{
+			name: "dual stack",
+			input: Input{
+				Report:     buildReport("192.0.2.10", "2001:db8::10", gateway, section("dns_aaaa", report.StatusPass), section("http_ipv6", report.StatusPass)),
+				Route:      checker.RouteLookup{Gateway: "fe80::1"},
+				Resolvers:  healthyDNS,
+				Preference: &checker.FamilyPreference{Address: "www.example.com:443", Chosen: "IPv6", IPv6: 10 * time.Millisecond, IPv4: 12 * time.Millisecond},
+			},
+			grade: "A",
+			score: 100,
+		},
+		{
+			name: "IPv4 only",
+			input: Input{
+				Report:     buildReport("192.0.2.10", "", report.Section{ID: "gateway"}, section("dns_aaaa", report.StatusPass), section("http_ipv6", report.StatusFail)),
+				RouteErr:   errors.New("Network is unreachable"),
+				Preference: &checker.FamilyPreference{Address: "www.example.com:443", Chosen: "IPv4", IPv6Err: errors.New("connect: network is unreachable")},
+			},
+			grade:  "D",
+			score:  25,
+			failed: []string{"Global address", "Router advertisement and default route", "DNS over IPv6", "IPv6-only HTTP", "Happy Eyeballs preference"},
+		},
+		{
+			name: "IPv6 only with NAT64",
+			input: Input{
+				Report:     buildReport("", "2001:db8::10", gateway, section("dns_aaaa", report.StatusPass), section("http_ipv6", report.StatusPass)),
+				Route:      checker.RouteLookup{Gateway: "fe80::1"},
+				Resolvers:  healthyDNS,
+				NAT64:      netip.MustParsePrefix("64:ff9b::/96"),
+				NAT64Found: true,
+				Preference: &checker.FamilyPreference{Address: "ipv4only.example:443", Chosen: "IPv4", IPv6Err: noAAAA},
+			},
+			grade: "A",
+			score: 100,
+		},
+		{
+			name: "ULA without DNS64 and partial AAAA",
+			input: Input{
+				Report:    buildReport("", "fd00::10", gateway, section("dns_aaaa", report.StatusPass, report.StatusFail)),
+				Route:     checker.RouteLookup{Gateway: "fe80::1"},
+				Resolvers: append(healthyDNS, checker.DNSProbe{Server: netip.MustParseAddr("2001:db8::54"), Error: errors.New("i/o timeout")}),
+			},
+			grade: "D",
+			// 5 + 15 + 7 + 5 + 0 of 70; HTTP and Happy Eyeballs are skipped.
+			score:  45,
+			failed: []string{"Global address", "AAAA resolution", "DNS over IPv6", "DNS64/NAT64"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card := Evaluate(tt.input)
+			var failed []string
+			for _, c := range card.Criteria {
+				if c.Explanation == "" {
+					t.Errorf("%s has no explanation", c.Name)
+				}
+				if c.Status == report.StatusFail {
+					failed = append(failed, c.Name)
+				}
+			}
+			if strings.Join(failed, ",") != strings.Join(tt.failed, ",") {
+				t.Errorf("Expected %v to lose points, got %v", tt.failed, failed)
+			}
+			if card.Score != tt.score {
+				t.Errorf("Expected score %d, got %d", tt.score, card.Score)
+			}
+			if card.Grade != tt.grade {
+				t.Errorf("Expected grade %s, got %s", tt.grade, card.Grade)
+			}
+		})
+	}
+}
+
+func TestEvaluateExplanations(t *testing.T) {
+	card := Evaluate(Input{
+		Report:     buildReport("", "2001:db8::10"),
+		RouteErr:   errors.New("no route"),
+		Preference: &checker.FamilyPreference{Address: "www.example.com:443", Chosen: "IPv4", IPv6: 80 * time.Millisecond, IPv4: 10 * time.Millisecond},
+	})
+	if c := criterion(card, "DNS64/NAT64"); c.Points != 0 || !strings.Contains(c.Explanation, "IPv4-only sites are unreachable") {
+		t.Errorf("Expected missing DNS64 on an IPv6-only host to fail, got %+v", c)
+	}
+	if c := criterion(card, "Happy Eyeballs preference"); c.Points != 5 || !strings.Contains(c.Explanation, "IPv6 80 ms, IPv4 10 ms") {
+		t.Errorf("Expected half points when IPv4 wins over working IPv6, got %+v", c)
+	}
+	if c := criterion(card, "AAAA resolution"); c.Status != report.StatusInfo {
+		t.Errorf("Expected AAAA resolution to be skipped without a dns_aaaa section, got %+v", c)
+	}
+}
@@ -0,0 +1,123 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// WriteComparison prints the reports side by side, one column per report,
+// so that differences between vantage points stand out.
+func WriteComparison(w io.Writer, reports []*Report) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprint(tw, "CHECK")
+	for _, r := range reports {
+		fmt.Fprintf(tw, "\t%s", r.Agent)
+	}
+	fmt.Fprintln(tw)
+
+	for _, row := range comparisonRows(reports) {
+		if row.item == "" {
+			fmt.Fprint(tw, row.title)
+			for _, r := range reports {
+				section, ok := r.Section(row.section)
+				switch {
+				case !ok:
+					fmt.Fprint(tw, "\t-")
+				case section.Error != "":
+					fmt.Fprint(tw, "\t❌ error")
+				default:
+					fmt.Fprintf(tw, "\t%s", section.Status().Icon())
+				}
+			}
+			fmt.Fprintln(tw)
+			continue
+		}
+
+		fmt.Fprintf(tw, "  %s", row.item)
+		for _, r := range reports {
+			section, _ := r.Section(row.section)
+			item, ok := section.Item(row.item)
+			if !ok {
+				fmt.Fprint(tw, "\t-")
+				continue
+			}
+			fmt.Fprintf(tw, "\t%s", compactCell(item))
+		}
+		fmt.Fprintln(tw)
+	}
+
+	tw.Flush()
+}
+
+type comparisonRow struct {
+	section string
+	title   string
+	item    string
+}
+
+// comparisonRows merges the sections and items of all reports, keeping the
+// order in which they first appear.
+func comparisonRows(reports []*Report) []comparisonRow {
+	var rows []comparisonRow
+	seenSections := make(map[string]bool)
+	seenItems := make(map[string]bool)
+
+	for _, r := range reports {
+		for _, section := range r.Sections {
+			if !seenSections[section.ID] {
+				seenSections[section.ID] = true
+				rows = append(rows, comparisonRow{section: section.ID, title: section.Title})
+			}
+		}
+	}
+
+	var merged []comparisonRow
+	for _, header := range rows {
+		merged = append(merged, header)
+		for _, r := range reports {
+			section, ok := r.Section(header.section)
+			if !ok {
+				continue
+			}
+			for _, item := range section.Items {
+				key := header.section + "\x00" + item.Name
+				if seenItems[key] {
+					continue
+				}
+				seenItems[key] = true
+				merged = append(merged, comparisonRow{section: header.section, item: item.Name})
+			}
+		}
+	}
+
+	return merged
+}
+
+func compactCell(item Item) string {
+	icon := item.Status.Icon()
+	if icon != "" {
+		icon += " "
+	}
+
+	switch {
+	case item.Status == StatusInfo && item.Attributes["address"] != "":
+		return item.Attributes["address"]
+	case item.Status == StatusInfo:
+		return "*"
+	case hasMetric(item, "avg"):
+		return fmt.Sprintf("%s%.1fms loss %.0f%%", icon, item.Metrics["avg"], item.Metrics["loss"])
+	case hasMetric(item, "duration_ms"):
+		return fmt.Sprintf("%s%.0fms", icon, item.Metrics["duration_ms"])
+	case item.Status == StatusFail:
+		return icon + "failed"
+	default:
+		return icon + item.Summary
+	}
+}
+
+func hasMetric(item Item, name string) bool {
+	_, ok := item.Metrics[name]
+	return ok
+}
@@ -0,0 +1,60 @@
+package report
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const historyTimeFormat = "20060102-150405"
+
+// SaveHistory writes r as JSON into dir, named after its start time so the
+// files sort chronologically.
+func SaveHistory(dir string, r *Report) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode report: %w", err)
+	}
+	path := filepath.Join(dir, fmt.Sprintf("pingood-%s.json", r.StartedAt.Format(historyTimeFormat)))
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
+
+// LoadHistory reads the reports saved in dir, oldest first. A missing
+// directory is not an error and yields no reports.
+func LoadHistory(dir string) ([]*Report, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "pingood-*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var reports []*Report
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read report: %w", err)
+		}
+		var r Report
+		if err := json.Unmarshal(data, &r); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", filepath.Base(path), err)
+		}
+		reports = append(reports, &r)
+	}
+
+	sort.SliceStable(reports, func(i, j int) bool {
+		return reports[i].StartedAt.Before(reports[j].StartedAt)
+	})
+	return reports, nil
+}
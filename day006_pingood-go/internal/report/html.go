@@ -0,0 +1,201 @@
+package report
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"strings"
+)
+
+//go:embed report.html.tmpl
+var htmlTemplateText string
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"icon": func(s Status) string { return s.Icon() },
+}).Parse(htmlTemplateText))
+
+// maxHistoryRuns bounds how many earlier runs the HTML report shows.
+const maxHistoryRuns = 30
+
+type htmlPage struct {
+	Report    *Report
+	Addresses []Item
+	Sections  []htmlSection
+	History   *htmlHistory
+}
+
+type htmlSection struct {
+	Section
+	Number int
+	Status Status
+	Passed int
+	Failed int
+	Hops   []htmlHop
+	Items  []htmlItem
+	Chart  template.HTML
+}
+
+type htmlItem struct {
+	Item
+	Chart template.HTML
+}
+
+type htmlHop struct {
+	Number  int
+	Address string
+	Name    string
+	Network string
+	RTT     string
+}
+
+type htmlHistory struct {
+	Columns []string
+	Runs    []htmlRun
+	Chart   template.HTML
+}
+
+type htmlRun struct {
+	Time     string
+	Statuses []Status
+}
+
+// WriteHTML renders r as a single self-contained HTML page: styles and
+// charts are inline, so the file can be attached to a ticket as is.
+// history holds earlier runs, oldest first, and may be empty.
+func WriteHTML(w io.Writer, r *Report, history []*Report) error {
+	page := htmlPage{Report: r}
+
+	if ip, ok := r.Section("ip"); ok {
+		page.Addresses = ip.Items
+	}
+	for i, section := range r.Sections {
+		page.Sections = append(page.Sections, newHTMLSection(i+1, section))
+	}
+	if len(history) > 0 {
+		page.History = newHTMLHistory(append(history, r))
+	}
+
+	if err := htmlTemplate.Execute(w, page); err != nil {
+		return fmt.Errorf("failed to render HTML report: %w", err)
+	}
+	return nil
+}
+
+func newHTMLSection(number int, section Section) htmlSection {
+	hs := htmlSection{Section: section, Number: number, Status: section.Status()}
+
+	var hopRTTs []float64
+	var hopLabels []string
+	for _, item := range section.Items {
+		switch item.Status {
+		case StatusPass:
+			hs.Passed++
+		case StatusFail:
+			hs.Failed++
+		}
+
+		if hop, ok := item.Metrics["hop"]; ok {
+			rtts := make([]string, len(item.Samples))
+			for i, sample := range item.Samples {
+				rtts[i] = fmt.Sprintf("%.1f", sample)
+			}
+			hs.Hops = append(hs.Hops, htmlHop{
+				Number:  int(hop),
+				Address: item.Attributes["address"],
+				Name:    item.Attributes["name"],
+				Network: item.Attributes["network"],
+				RTT:     strings.Join(rtts, " / "),
+			})
+			hopRTTs = append(hopRTTs, mean(item.Samples))
+			hopLabels = append(hopLabels, fmt.Sprint(int(hop)))
+			continue
+		}
+
+		hi := htmlItem{Item: item}
+		if len(item.Samples) > 1 {
+			labels := make([]string, len(item.Samples))
+			for i := range labels {
+				labels[i] = fmt.Sprint(i + 1)
+			}
+			hi.Chart = barChart(item.Samples, labels, "ms")
+		}
+		hs.Items = append(hs.Items, hi)
+	}
+
+	if len(hs.Hops) > 0 {
+		hs.Chart = barChart(hopRTTs, hopLabels, "ms")
+	}
+	return hs
+}
+
+// newHTMLHistory lays out runs as a table of section statuses and charts
+// the average RTT of every item that has one.
+func newHTMLHistory(runs []*Report) *htmlHistory {
+	if len(runs) > maxHistoryRuns {
+		runs = runs[len(runs)-maxHistoryRuns:]
+	}
+	h := &htmlHistory{}
+
+	rows := comparisonRows(runs)
+	var sectionIDs []string
+	for _, row := range rows {
+		if row.item == "" {
+			sectionIDs = append(sectionIDs, row.section)
+			h.Columns = append(h.Columns, row.title)
+		}
+	}
+
+	var labels []string
+	for _, run := range runs {
+		hr := htmlRun{Time: run.StartedAt.Format("2006-01-02 15:04")}
+		for _, id := range sectionIDs {
+			section, ok := run.Section(id)
+			if !ok {
+				hr.Statuses = append(hr.Statuses, "")
+				continue
+			}
+			hr.Statuses = append(hr.Statuses, section.Status())
+		}
+		h.Runs = append(h.Runs, hr)
+		labels = append(labels, run.StartedAt.Format("01-02 15:04"))
+	}
+
+	var series []chartSeries
+	for _, row := range rows {
+		if row.item == "" {
+			continue
+		}
+		s := chartSeries{Name: row.item}
+		found := false
+		for _, run := range runs {
+			section, _ := run.Section(row.section)
+			item, ok := section.Item(row.item)
+			if avg, has := item.Metrics["avg"]; ok && has && item.Metrics["loss"] < 100 {
+				s.Values = append(s.Values, avg)
+				found = true
+			} else {
+				s.Values = append(s.Values, math.NaN())
+			}
+		}
+		if found {
+			series = append(series, s)
+		}
+	}
+	if len(series) > 0 && len(runs) > 1 {
+		h.Chart = lineChart(series, labels, "ms")
+	}
+	return h
+}
+
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return math.NaN()
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
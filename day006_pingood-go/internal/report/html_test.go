@@ -0,0 +1,100 @@
+package report
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sampleReport(started time.Time, avg float64) *Report {
+	r := &Report{Platform: "linux/amd64", Interface: "eth0", StartedAt: started}
+	r.Add(Section{ID: "ip", Title: "IP Address Check", Items: []Item{
+		{Name: "IPv4", Status: StatusPass, Summary: "192.168.1.23"},
+	}})
+	r.Add(Section{ID: "ping_ipv4", Title: "ICMP Ping Test (IPv4)", Items: []Item{
+		{Name: "8.8.8.8", Status: StatusPass, Summary: "Success", Metrics: map[string]float64{"avg": avg, "loss": 0}, Samples: []float64{avg - 1, avg, avg + 1}},
+	}})
+	r.Add(Section{ID: "traceroute", Title: "Traceroute Test", Items: []Item{
+		{Name: "hop 1", Status: StatusInfo, Metrics: map[string]float64{"hop": 1}, Samples: []float64{0.4, 0.5}, Attributes: map[string]string{"address": "192.168.1.1", "name": "router.lan", "network": "private"}},
+		{Name: "hop 2", Status: StatusInfo, Metrics: map[string]float64{"hop": 2}},
+		{Name: "router", Status: StatusFail, Summary: "Not found"},
+	}})
+	r.Commands = []Command{
+		{Command: "ping -c 3 8.8.8.8", Stdout: "64 bytes from 8.8.8.8\n"},
+		{Command: "dig example.com A", ExitCode: 9, Stderr: "<timeout>\n"},
+	}
+	return r
+}
+
+func TestWriteHTML(t *testing.T) {
+	now := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	history := []*Report{
+		sampleReport(now.Add(-2*time.Hour), 12),
+		sampleReport(now.Add(-time.Hour), 15),
+	}
+
+	var buf bytes.Buffer
+	if err := WriteHTML(&buf, sampleReport(now, 10), history); err != nil {
+		t.Fatalf("WriteHTML failed: %v", err)
+	}
+	html := buf.String()
+
+	for _, want := range []string{
+		"<tr><th>IPv4</th><td>192.168.1.23</td></tr>",
+		`<a href="#traceroute">Traceroute Test</a></td><td class="fail">❌ fail</td><td>0</td><td>1</td>`,
+		"<tr><td>1</td><td>192.168.1.1</td><td>router.lan</td><td>private</td><td>0.4 / 0.5</td></tr>",
+		"<tr><td>2</td><td>*</td><td></td><td></td><td></td></tr>",
+		"<title>8.8.8.8 05-01 08:00: 15.0 ms</title>",
+		"<tr><td>2024-05-01 07:00</td>",
+		"<summary>$ dig example.com A <span class=\"fail\">(exit 9)</span></summary>",
+		"&lt;timeout&gt;",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("Expected HTML to contain %q", want)
+		}
+	}
+	if n := strings.Count(html, "<svg"); n != 3 {
+		t.Errorf("Expected 3 charts (ping samples, traceroute, history), got %d", n)
+	}
+	if strings.Contains(html, "<link") || strings.Contains(html, "<script src") {
+		t.Error("Expected the report to be self-contained")
+	}
+}
+
+func TestWriteHTMLWithoutHistory(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteHTML(&buf, sampleReport(time.Now(), 10), nil); err != nil {
+		t.Fatalf("WriteHTML failed: %v", err)
+	}
+	if strings.Contains(buf.String(), `id="history"`) {
+		t.Error("Expected no history section without earlier runs")
+	}
+}
+
+func TestHistoryRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+
+	reports, err := LoadHistory(dir + "/missing")
+	if err != nil || len(reports) != 0 {
+		t.Fatalf("Expected empty history for a missing directory, got %v, %v", reports, err)
+	}
+
+	now := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	for _, started := range []time.Time{now, now.Add(-time.Hour)} {
+		if err := SaveHistory(dir, sampleReport(started, 10)); err != nil {
+			t.Fatalf("SaveHistory failed: %v", err)
+		}
+	}
+
+	reports, err = LoadHistory(dir)
+	if err != nil {
+		t.Fatalf("LoadHistory failed: %v", err)
+	}
+	if len(reports) != 2 || !reports[0].StartedAt.Before(reports[1].StartedAt) {
+		t.Fatalf("Expected 2 reports oldest first, got %d", len(reports))
+	}
+	if len(reports[1].Commands) != 2 || reports[1].Sections[1].Items[0].Metrics["avg"] != 10 {
+		t.Errorf("Report did not survive the round trip: %+v", reports[1])
+	}
+}
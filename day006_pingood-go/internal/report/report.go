@@ -0,0 +1,174 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusPass Status = "pass"
+	StatusFail Status = "fail"
+	StatusInfo Status = "info"
+)
+
+func (s Status) Icon() string {
+	switch s {
+	case StatusPass:
+		return "✅"
+	case StatusFail:
+		return "❌"
+	default:
+		return ""
+	}
+}
+
+// Report is the outcome of one pingood run in a form that can be printed,
+// sent over the wire and compared with runs from other hosts.
+type Report struct {
+	Agent     string    `json:"agent,omitempty"`
+	Platform  string    `json:"platform"`
+	Interface string    `json:"interface"`
+	StartedAt time.Time `json:"started_at"`
+	Sections  []Section `json:"sections"`
+	Commands  []Command `json:"commands,omitempty"`
+}
+
+// Command is the raw output of one external command run during the
+// checks. Error is set when the command could not be started.
+type Command struct {
+	Command  string `json:"command"`
+	ExitCode int    `json:"exit_code"`
+	Error    string `json:"error,omitempty"`
+	Stdout   string `json:"stdout,omitempty"`
+	Stderr   string `json:"stderr,omitempty"`
+}
+
+type Section struct {
+	ID      string `json:"id"`
+	Title   string `json:"title"`
+	Summary string `json:"summary,omitempty"`
+	Error   string `json:"error,omitempty"`
+	// Table is an optional overview printed before the items; the first row
+	// is the header.
+	Table [][]string `json:"table,omitempty"`
+	Items []Item     `json:"items,omitempty"`
+}
+
+// Item is a single line of a section, such as one ping target or one
+// traceroute hop. Info items carry data but never fail a section.
+type Item struct {
+	Name       string             `json:"name"`
+	Status     Status             `json:"status"`
+	Summary    string             `json:"summary"`
+	Details    []string           `json:"details,omitempty"`
+	Metrics    map[string]float64 `json:"metrics,omitempty"`
+	Samples    []float64          `json:"samples,omitempty"`
+	Attributes map[string]string  `json:"attributes,omitempty"`
+	Timings    []Timing           `json:"timings,omitempty"`
+}
+
+// Timing is a timed step of an item, such as the DNS lookup or TLS
+// handshake of an HTTP request.
+type Timing struct {
+	Name     string        `json:"name"`
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+	Detail   string        `json:"detail,omitempty"`
+	Error    string        `json:"error,omitempty"`
+}
+
+func (s Section) Status() Status {
+	if s.Error != "" {
+		return StatusFail
+	}
+	status := StatusInfo
+	for _, item := range s.Items {
+		switch item.Status {
+		case StatusFail:
+			return StatusFail
+		case StatusPass:
+			status = StatusPass
+		}
+	}
+	return status
+}
+
+func (s Section) Item(name string) (Item, bool) {
+	for _, item := range s.Items {
+		if item.Name == name {
+			return item, true
+		}
+	}
+	return Item{}, false
+}
+
+func (r *Report) Section(id string) (Section, bool) {
+	for _, section := range r.Sections {
+		if section.ID == id {
+			return section, true
+		}
+	}
+	return Section{}, false
+}
+
+func (r *Report) Add(section Section) {
+	r.Sections = append(r.Sections, section)
+}
+
+func (r *Report) WriteHeader(w io.Writer) {
+	fmt.Fprintf(w, "=== Network Diagnostics Tool (pingood-go) ===\n")
+	if r.Agent != "" {
+		fmt.Fprintf(w, "Agent: %s\n", r.Agent)
+	}
+	fmt.Fprintf(w, "Platform: %s\n", r.Platform)
+	fmt.Fprintf(w, "Interface: %s\n", r.Interface)
+	fmt.Fprintf(w, "Time: %s\n\n", r.StartedAt.Format("2006-01-02 15:04:05"))
+}
+
+func (r *Report) WriteText(w io.Writer) {
+	r.WriteHeader(w)
+	for i, section := range r.Sections {
+		WriteSection(w, i+1, section)
+	}
+	fmt.Fprintln(w, "=== Diagnostics Complete ===")
+}
+
+// WriteSection prints a section in the numbered terminal layout pingood has
+// always used.
+func WriteSection(w io.Writer, number int, s Section) {
+	heading := fmt.Sprintf("%d. %s", number, s.Title)
+	fmt.Fprintln(w, heading)
+	fmt.Fprintln(w, strings.Repeat("=", len(heading)))
+
+	if s.Summary != "" {
+		fmt.Fprintln(w, s.Summary)
+	}
+	if s.Error != "" {
+		fmt.Fprintf(w, "❌ %s\n", s.Error)
+	}
+	if len(s.Table) > 0 {
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, row := range s.Table {
+			fmt.Fprintf(tw, "  %s\n", strings.Join(row, "\t"))
+		}
+		tw.Flush()
+	}
+	for _, item := range s.Items {
+		if item.Status == StatusInfo {
+			fmt.Fprintf(w, "  %s\n", item.Summary)
+		} else if item.Summary != "" {
+			fmt.Fprintf(w, "%s %s: %s\n", item.Status.Icon(), item.Name, item.Summary)
+		} else {
+			fmt.Fprintf(w, "%s %s\n", item.Status.Icon(), item.Name)
+		}
+		for _, detail := range item.Details {
+			fmt.Fprintf(w, "   %s\n", detail)
+		}
+	}
+	fmt.Fprintln(w)
+}
@@ -0,0 +1,111 @@
+package report
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSectionStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		section Section
+		want    Status
+	}{
+		{"empty", Section{}, StatusInfo},
+		{"info only", Section{Items: []Item{{Status: StatusInfo}}}, StatusInfo},
+		{"pass", Section{Items: []Item{{Status: StatusInfo}, {Status: StatusPass}}}, StatusPass},
+		{"any fail", Section{Items: []Item{{Status: StatusPass}, {Status: StatusFail}}}, StatusFail},
+		{"error", Section{Error: "boom", Items: []Item{{Status: StatusPass}}}, StatusFail},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.section.Status(); got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestWriteSection(t *testing.T) {
+	var buf bytes.Buffer
+	WriteSection(&buf, 3, Section{
+		Title:   "Ping Test (IPv4)",
+		Summary: "Assertions: loss < 1%",
+		Items: []Item{
+			{Name: "8.8.8.8", Status: StatusPass, Summary: "Success", Details: []string{"Avg RTT: 10ms"}},
+			{Name: "hop 1", Status: StatusInfo, Summary: "1: 192.168.1.1"},
+			{Name: "192.168.1.1", Status: StatusFail},
+		},
+	})
+
+	want := "3. Ping Test (IPv4)\n" +
+		"===================\n" +
+		"Assertions: loss < 1%\n" +
+		"✅ 8.8.8.8: Success\n" +
+		"   Avg RTT: 10ms\n" +
+		"  1: 192.168.1.1\n" +
+		"❌ 192.168.1.1\n" +
+		"\n"
+	if buf.String() != want {
+		t.Errorf("Unexpected output:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestWriteSectionTable(t *testing.T) {
+	var buf bytes.Buffer
+	WriteSection(&buf, 1, Section{
+		Title: "TLS",
+		Table: [][]string{
+			{"ENDPOINT", "VERSION"},
+			{"mail.example.com:465", "TLS 1.3"},
+		},
+		Items: []Item{{Name: "mail.example.com:465", Status: StatusPass}},
+	})
+
+	want := "1. TLS\n" +
+		"======\n" +
+		"  ENDPOINT              VERSION\n" +
+		"  mail.example.com:465  TLS 1.3\n" +
+		"✅ mail.example.com:465\n" +
+		"\n"
+	if buf.String() != want {
+		t.Errorf("Unexpected output:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestWriteComparison(t *testing.T) {
+	tokyo := &Report{Agent: "tokyo"}
+	tokyo.Add(Section{ID: "ping_ipv4", Title: "Ping Test (IPv4)", Items: []Item{
+		{Name: "8.8.8.8", Status: StatusPass, Metrics: map[string]float64{"avg": 5.2, "loss": 0}},
+	}})
+	osaka := &Report{Agent: "osaka"}
+	osaka.Add(Section{ID: "ping_ipv4", Title: "Ping Test (IPv4)", Items: []Item{
+		{Name: "8.8.8.8", Status: StatusFail, Metrics: map[string]float64{"avg": 0, "loss": 100}},
+		{Name: "1.1.1.1", Status: StatusPass, Metrics: map[string]float64{"avg": 3, "loss": 0}},
+	}})
+	osaka.Add(Section{ID: "dns_a", Title: "DNS Resolution (A Record)", Error: "dig not found"})
+
+	var buf bytes.Buffer
+	WriteComparison(&buf, []*Report{tokyo, osaka})
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+
+	expected := [][]string{
+		{"CHECK", "tokyo", "osaka"},
+		{"Ping Test (IPv4)", "✅", "❌"},
+		{"8.8.8.8", "✅ 5.2ms loss 0%", "❌ 0.0ms loss 100%"},
+		{"1.1.1.1", "-", "✅ 3.0ms loss 0%"},
+		{"DNS Resolution (A Record)", "-", "❌ error"},
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("Expected %d lines, got %d:\n%s", len(expected), len(lines), buf.String())
+	}
+	for i, cells := range expected {
+		for _, cell := range cells {
+			if !strings.Contains(lines[i], cell) {
+				t.Errorf("Line %d %q does not contain %q", i, lines[i], cell)
+			}
+		}
+	}
+}
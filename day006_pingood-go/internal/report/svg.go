@@ -0,0 +1,127 @@
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"math"
+	"strings"
+)
+
+const (
+	chartWidth  = 560
+	chartHeight = 160
+	chartLeft   = 48
+	chartBottom = 22
+	chartTop    = 10
+)
+
+var chartColors = []string{"#2b6cb0", "#c05621", "#2f855a", "#6b46c1", "#b83280", "#2c7a7b", "#975a16", "#4a5568"}
+
+type chartSeries struct {
+	Name   string
+	Values []float64
+}
+
+// barChart draws one bar per value. NaN values, such as hops that did not
+// answer, leave a gap.
+func barChart(values []float64, labels []string, unit string) template.HTML {
+	top := chartMax(values)
+	plotWidth := float64(chartWidth - chartLeft)
+	plotHeight := float64(chartHeight - chartTop - chartBottom)
+	slot := plotWidth / float64(len(values))
+
+	var b strings.Builder
+	chartStart(&b, top, unit)
+	for i, v := range values {
+		x := float64(chartLeft) + float64(i)*slot
+		if len(values) <= 40 {
+			fmt.Fprintf(&b, `<text x="%.1f" y="%d" text-anchor="middle" class="axis">%s</text>`, x+slot/2, chartHeight-6, template.HTMLEscapeString(labels[i]))
+		}
+		if math.IsNaN(v) {
+			continue
+		}
+		h := v / top * plotHeight
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"><title>%s: %.1f %s</title></rect>`,
+			x+slot*0.15, float64(chartHeight-chartBottom)-h, slot*0.7, h, chartColors[0], template.HTMLEscapeString(labels[i]), v, unit)
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// lineChart draws one line per series over shared x labels, with a legend
+// below the plot. NaN values break the line.
+func lineChart(series []chartSeries, labels []string, unit string) template.HTML {
+	var all []float64
+	for _, s := range series {
+		all = append(all, s.Values...)
+	}
+	top := chartMax(all)
+	plotWidth := float64(chartWidth - chartLeft - 10)
+	plotHeight := float64(chartHeight - chartTop - chartBottom)
+	step := plotWidth / math.Max(float64(len(labels)-1), 1)
+
+	var b strings.Builder
+	chartStart(&b, top, unit)
+	for _, i := range []int{0, len(labels) - 1} {
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" text-anchor="middle" class="axis">%s</text>`, float64(chartLeft)+float64(i)*step, chartHeight-6, template.HTMLEscapeString(labels[i]))
+	}
+	for n, s := range series {
+		color := chartColors[n%len(chartColors)]
+		var points []string
+		flush := func() {
+			if len(points) > 1 {
+				fmt.Fprintf(&b, `<polyline fill="none" stroke="%s" stroke-width="2" points="%s"/>`, color, strings.Join(points, " "))
+			}
+			points = nil
+		}
+		for i, v := range s.Values {
+			if math.IsNaN(v) {
+				flush()
+				continue
+			}
+			x := float64(chartLeft) + float64(i)*step
+			y := float64(chartHeight-chartBottom) - v/top*plotHeight
+			points = append(points, fmt.Sprintf("%.1f,%.1f", x, y))
+			fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="2.5" fill="%s"><title>%s %s: %.1f %s</title></circle>`,
+				x, y, color, template.HTMLEscapeString(s.Name), template.HTMLEscapeString(labels[i]), v, unit)
+		}
+		flush()
+	}
+	b.WriteString(`</svg>`)
+
+	b.WriteString(`<div class="legend">`)
+	for n, s := range series {
+		fmt.Fprintf(&b, `<span><i style="background:%s"></i>%s</span>`, chartColors[n%len(chartColors)], template.HTMLEscapeString(s.Name))
+	}
+	b.WriteString(`</div>`)
+	return template.HTML(b.String())
+}
+
+func chartStart(b *strings.Builder, top float64, unit string) {
+	fmt.Fprintf(b, `<svg class="chart" viewBox="0 0 %d %d" xmlns="http://www.w3.org/2000/svg">`, chartWidth, chartHeight)
+	for _, f := range []float64{0, 0.5, 1} {
+		y := float64(chartHeight-chartBottom) - f*float64(chartHeight-chartTop-chartBottom)
+		fmt.Fprintf(b, `<line x1="%d" x2="%d" y1="%.1f" y2="%.1f" class="grid"/>`, chartLeft, chartWidth, y, y)
+		fmt.Fprintf(b, `<text x="%d" y="%.1f" text-anchor="end" class="axis">%.0f %s</text>`, chartLeft-4, y+4, top*f, unit)
+	}
+}
+
+// chartMax rounds the largest value up so the axis labels stay readable.
+func chartMax(values []float64) float64 {
+	top := 0.0
+	for _, v := range values {
+		if !math.IsNaN(v) && v > top {
+			top = v
+		}
+	}
+	if top <= 0 {
+		return 1
+	}
+	magnitude := math.Pow(10, math.Floor(math.Log10(top)))
+	for _, m := range []float64{1, 2, 5, 10} {
+		if top <= m*magnitude {
+			return m * magnitude
+		}
+	}
+	return top
+}
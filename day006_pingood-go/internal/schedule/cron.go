@@ -0,0 +1,208 @@
+// Package schedule runs checks on their own cadence, each with a cron
+// expression, and keeps track of when they last ran, how that went and
+// when they run next.
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule returns the first time after t a job should run.
+type Schedule interface {
+	Next(t time.Time) time.Time
+}
+
+// Cron is a parsed cron expression. It matches times in the location of
+// the time passed to Next.
+type Cron struct {
+	second, minute, hour, dom, month, dow uint64
+	// domStar and dowStar record whether the day fields were "*": if
+	// both are restricted, either one matching is enough, as in cron(8).
+	domStar, dowStar bool
+}
+
+// every runs a job at a fixed interval after the previous run.
+type every time.Duration
+
+func (e every) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(e))
+}
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 0 1 1 *",
+	"@annually": "0 0 0 1 1 *",
+	"@monthly":  "0 0 0 1 * *",
+	"@weekly":   "0 0 0 * * 0",
+	"@daily":    "0 0 0 * * *",
+	"@midnight": "0 0 0 * * *",
+	"@hourly":   "0 0 * * * *",
+}
+
+type field struct {
+	name     string
+	min, max int
+	names    []string
+}
+
+var fields = []field{
+	{name: "second", min: 0, max: 59},
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}},
+	{name: "day of week", min: 0, max: 7, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}},
+}
+
+// Parse reads a cron expression: the usual five fields (minute, hour, day
+// of month, month, day of week), six with a leading seconds field, one of
+// @yearly, @monthly, @weekly, @daily and @hourly, or "@every <duration>"
+// such as "@every 30s".
+func Parse(expr string) (Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if rest, ok := strings.CutPrefix(expr, "@every "); ok {
+		d, err := time.ParseDuration(strings.TrimSpace(rest))
+		if err != nil {
+			return nil, fmt.Errorf("invalid interval in %q: %w", expr, err)
+		}
+		if d < time.Second {
+			return nil, fmt.Errorf("interval in %q is shorter than a second", expr)
+		}
+		return every(d), nil
+	}
+	if spec, ok := descriptors[strings.ToLower(expr)]; ok {
+		expr = spec
+	}
+
+	parts := strings.Fields(expr)
+	switch len(parts) {
+	case 5:
+		parts = append([]string{"0"}, parts...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("cron expression %q needs 5 or 6 fields, got %d", expr, len(parts))
+	}
+
+	c := &Cron{domStar: isStar(parts[3]), dowStar: isStar(parts[5])}
+	for i, target := range []*uint64{&c.second, &c.minute, &c.hour, &c.dom, &c.month, &c.dow} {
+		bits, err := parseField(parts[i], fields[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s in %q: %w", fields[i].name, expr, err)
+		}
+		*target = bits
+	}
+	// Both 0 and 7 mean Sunday.
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	return c, nil
+}
+
+// parseField turns a comma-separated list of values, ranges and steps
+// ("*/15", "1-5", "mon-fri", "0,30") into a bit per matching value.
+func parseField(s string, f field) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(s, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q", stepPart)
+			}
+			step = n
+		}
+
+		first, last := f.min, f.max
+		switch {
+		case rangePart == "*" || rangePart == "?":
+		case strings.Contains(rangePart, "-"):
+			lo, hi, _ := strings.Cut(rangePart, "-")
+			var err error
+			if first, err = f.value(lo); err != nil {
+				return 0, err
+			}
+			if last, err = f.value(hi); err != nil {
+				return 0, err
+			}
+			if first > last {
+				return 0, fmt.Errorf("range %q is backwards", rangePart)
+			}
+		default:
+			v, err := f.value(rangePart)
+			if err != nil {
+				return 0, err
+			}
+			first = v
+			// "5/10" means from 5 to the end in steps of 10.
+			if !hasStep {
+				last = v
+			}
+		}
+		for v := first; v <= last; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (f field) value(s string) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(s, name) {
+			return i + f.min, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	if v < f.min || v > f.max {
+		return 0, fmt.Errorf("%d is outside %d-%d", v, f.min, f.max)
+	}
+	return v, nil
+}
+
+// Next returns the first matching time after t, or the zero time if none
+// comes within five years (such as for February 30th).
+func (c *Cron) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Second).Add(time.Second)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		year, month, day := t.Date()
+		hour, minute, second := t.Clock()
+		switch {
+		case c.month&(1<<uint(month)) == 0:
+			t = time.Date(year, month+1, 1, 0, 0, 0, 0, loc)
+		case !c.dayMatches(t):
+			t = time.Date(year, month, day+1, 0, 0, 0, 0, loc)
+		case c.hour&(1<<uint(hour)) == 0:
+			t = time.Date(year, month, day, hour+1, 0, 0, 0, loc)
+		case c.minute&(1<<uint(minute)) == 0:
+			t = time.Date(year, month, day, hour, minute+1, 0, 0, loc)
+		case c.second&(1<<uint(second)) == 0:
+			t = time.Date(year, month, day, hour, minute, second+1, 0, loc)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (c *Cron) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// isStar reports whether a day field is unrestricted; like cron(8), "*/2"
+// counts as unrestricted too.
+func isStar(s string) bool {
+	return strings.HasPrefix(s, "*") || s == "?"
+}
@@ -0,0 +1,69 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	start := time.Date(2024, 1, 31, 23, 59, 50, 0, time.UTC) // a Wednesday
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{"*/30 * * * * *", []string{"2024-02-01T00:00:00Z", "2024-02-01T00:00:30Z"}},
+		{"*/10 * * * *", []string{"2024-02-01T00:00:00Z", "2024-02-01T00:10:00Z"}},
+		{"@hourly", []string{"2024-02-01T00:00:00Z", "2024-02-01T01:00:00Z"}},
+		{"@every 45s", []string{"2024-02-01T00:00:35Z", "2024-02-01T00:01:20Z"}},
+		{"30 9 * * mon-fri", []string{"2024-02-01T09:30:00Z", "2024-02-02T09:30:00Z", "2024-02-05T09:30:00Z"}},
+		{"0 0 29 feb *", []string{"2024-02-29T00:00:00Z", "2028-02-29T00:00:00Z"}},
+		// Day of month and day of week both restricted: either matches.
+		{"0 12 1 * 0", []string{"2024-02-01T12:00:00Z", "2024-02-04T12:00:00Z", "2024-02-11T12:00:00Z"}},
+		{"0 0 * * 7", []string{"2024-02-04T00:00:00Z"}},
+		{"15,45 8-10/2 * * *", []string{"2024-02-01T08:15:00Z", "2024-02-01T08:45:00Z", "2024-02-01T10:15:00Z"}},
+		{"5/20 * * * * *", []string{"2024-02-01T00:00:05Z", "2024-02-01T00:00:25Z", "2024-02-01T00:00:45Z", "2024-02-01T00:01:05Z"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			schedule, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			next := start
+			for _, want := range tt.want {
+				next = schedule.Next(next)
+				if got := next.Format(time.RFC3339); got != want {
+					t.Fatalf("Expected %s, got %s", want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* * * 13 *",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"* * * * funday",
+		"@every 10ms",
+		"@every soon",
+	} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Expected %q to be rejected", expr)
+		}
+	}
+}
+
+func TestCronNever(t *testing.T) {
+	schedule, err := Parse("0 0 30 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next := schedule.Next(time.Now()); !next.IsZero() {
+		t.Errorf("Expected February 30th never to come, got %s", next)
+	}
+}
@@ -0,0 +1,187 @@
+package schedule
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/report"
+)
+
+// Outcome is how one run of a job went.
+type Outcome struct {
+	Status  report.Status `json:"status"`
+	Summary string        `json:"summary,omitempty"`
+}
+
+// Job is a check to run whenever Schedule says so. Each run starts up to
+// Jitter later than scheduled, so jobs on the same schedule on many hosts do
+// not hit a target at the same moment.
+type Job struct {
+	Name     string
+	Spec     string
+	Schedule Schedule
+	Jitter   time.Duration
+	Run      func(ctx context.Context) Outcome
+}
+
+// Run is a finished or skipped run of a job, as passed to
+// Scheduler.OnRun.
+type Run struct {
+	Job      string
+	Started  time.Time
+	Duration time.Duration
+	Outcome  Outcome
+	// Skipped is set when the job was due while its previous run was still
+	// going; the run is dropped rather than queued.
+	Skipped bool
+}
+
+// JobStatus is where a job stands, as shown by "pingood status".
+type JobStatus struct {
+	Name         string        `json:"name"`
+	Schedule     string        `json:"schedule"`
+	Next         time.Time     `json:"next"`
+	LastRun      time.Time     `json:"last_run"`
+	LastDuration time.Duration `json:"last_duration"`
+	LastOutcome  Outcome       `json:"last_outcome"`
+	Running      bool          `json:"running"`
+	Runs         int           `json:"runs"`
+	Failures     int           `json:"failures"`
+	Skipped      int           `json:"skipped"`
+}
+
+// Scheduler runs jobs until its context is cancelled. Jobs run
+// concurrently with each other but never with themselves.
+type Scheduler struct {
+	// OnRun is called after every run and every skipped run, from the
+	// job's goroutine.
+	OnRun func(Run)
+	// OnStart is called once every job has its first run scheduled.
+	OnStart func()
+
+	mu     sync.Mutex
+	jobs   []Job
+	status map[string]*JobStatus
+	wg     sync.WaitGroup
+}
+
+func New(jobs ...Job) *Scheduler {
+	s := &Scheduler{jobs: jobs, status: make(map[string]*JobStatus)}
+	for _, job := range jobs {
+		s.status[job.Name] = &JobStatus{Name: job.Name, Schedule: job.Spec}
+	}
+	return s
+}
+
+// Status returns the state of every job, in the order they were added.
+func (s *Scheduler) Status() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	statuses := make([]JobStatus, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		statuses = append(statuses, *s.status[job.Name])
+	}
+	return statuses
+}
+
+// Run starts every job and blocks until ctx is cancelled and the runs in
+// progress have finished.
+func (s *Scheduler) Run(ctx context.Context) {
+	now := time.Now()
+	for _, job := range s.jobs {
+		random := rand.New(rand.NewSource(now.UnixNano() + int64(len(job.Name))))
+		scheduled, due := s.plan(job, now, random)
+		if scheduled.IsZero() {
+			continue
+		}
+		s.wg.Add(1)
+		go s.loop(ctx, job, scheduled, due, random)
+	}
+	if s.OnStart != nil {
+		s.OnStart()
+	}
+	<-ctx.Done()
+	s.wg.Wait()
+}
+
+// plan schedules the run of job after the previous scheduled time and
+// returns when it is scheduled and when, with jitter, it is due. Runs are
+// scheduled from the previous scheduled time, not from when the jittered
+// run started, so jitter does not accumulate.
+func (s *Scheduler) plan(job Job, previous time.Time, random *rand.Rand) (scheduled, due time.Time) {
+	scheduled = job.Schedule.Next(previous)
+	// After a suspend or a very long run, skip what was missed instead of
+	// catching up on every run.
+	if now := time.Now(); !scheduled.IsZero() && scheduled.Before(now) {
+		scheduled = job.Schedule.Next(now)
+	}
+	due = scheduled
+	if job.Jitter > 0 && !due.IsZero() {
+		due = due.Add(time.Duration(random.Int63n(int64(job.Jitter))))
+	}
+	s.update(job.Name, func(st *JobStatus) { st.Next = due })
+	return scheduled, due
+}
+
+func (s *Scheduler) loop(ctx context.Context, job Job, scheduled, due time.Time, random *rand.Rand) {
+	defer s.wg.Done()
+	for !scheduled.IsZero() {
+		timer := time.NewTimer(time.Until(due))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		var running bool
+		s.update(job.Name, func(st *JobStatus) {
+			running = st.Running
+			if running {
+				st.Skipped++
+			} else {
+				st.Running = true
+			}
+		})
+		if running {
+			s.notify(Run{Job: job.Name, Started: time.Now(), Skipped: true})
+		} else {
+			s.wg.Add(1)
+			go s.execute(ctx, job)
+		}
+		scheduled, due = s.plan(job, scheduled, random)
+	}
+}
+
+func (s *Scheduler) execute(ctx context.Context, job Job) {
+	defer s.wg.Done()
+	start := time.Now()
+	outcome := job.Run(ctx)
+	run := Run{Job: job.Name, Started: start, Duration: time.Since(start), Outcome: outcome}
+
+	s.update(job.Name, func(st *JobStatus) {
+		st.Running = false
+		st.Runs++
+		if outcome.Status == report.StatusFail {
+			st.Failures++
+		}
+		st.LastRun = run.Started
+		st.LastDuration = run.Duration
+		st.LastOutcome = outcome
+	})
+	s.notify(run)
+}
+
+func (s *Scheduler) update(name string, f func(*JobStatus)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f(s.status[name])
+}
+
+func (s *Scheduler) notify(run Run) {
+	if s.OnRun != nil {
+		s.OnRun(run)
+	}
+}
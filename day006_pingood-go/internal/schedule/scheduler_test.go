@@ -0,0 +1,127 @@
+package schedule
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/report"
+)
+
+// interval is a schedule finer than "@every" allows, to keep tests short.
+type interval time.Duration
+
+func (i interval) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(i))
+}
+
+func TestSchedulerPreventsOverlap(t *testing.T) {
+	var running, maxRunning int32
+	slow := Job{
+		Name:     "traceroute",
+		Schedule: interval(10 * time.Millisecond),
+		Run: func(ctx context.Context) Outcome {
+			n := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			for {
+				max := atomic.LoadInt32(&maxRunning)
+				if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+					break
+				}
+			}
+			time.Sleep(45 * time.Millisecond)
+			return Outcome{Status: report.StatusFail, Summary: "no route"}
+		},
+	}
+	fast := Job{
+		Name:     "dns",
+		Schedule: interval(10 * time.Millisecond),
+		Run: func(ctx context.Context) Outcome {
+			return Outcome{Status: report.StatusPass}
+		},
+	}
+
+	s := New(slow, fast)
+	var mu sync.Mutex
+	var skipped []string
+	s.OnRun = func(run Run) {
+		mu.Lock()
+		defer mu.Unlock()
+		if run.Skipped {
+			skipped = append(skipped, run.Job)
+		}
+	}
+	started := make(chan struct{})
+	s.OnStart = func() { close(started) }
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	s.Run(ctx)
+
+	select {
+	case <-started:
+	default:
+		t.Error("OnStart was not called")
+	}
+	if maxRunning != 1 {
+		t.Errorf("Expected runs of the same job never to overlap, got %d at once", maxRunning)
+	}
+
+	status := s.Status()
+	if status[0].Name != "traceroute" || status[1].Name != "dns" {
+		t.Fatalf("Expected jobs in the order added, got %+v", status)
+	}
+	slowStatus, fastStatus := status[0], status[1]
+	if slowStatus.Runs == 0 || slowStatus.Skipped == 0 || slowStatus.Failures != slowStatus.Runs {
+		t.Errorf("Expected failed runs and skipped runs of the slow job, got %+v", slowStatus)
+	}
+	if slowStatus.LastOutcome.Summary != "no route" || slowStatus.LastDuration < 45*time.Millisecond {
+		t.Errorf("Expected the last outcome to be kept, got %+v", slowStatus)
+	}
+	if fastStatus.Runs <= slowStatus.Runs || fastStatus.Skipped != 0 || fastStatus.Failures != 0 {
+		t.Errorf("Expected the fast job to run on its own cadence, got %+v", fastStatus)
+	}
+	if slowStatus.Running || fastStatus.Running {
+		t.Error("Expected Run to wait for runs in progress")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for _, job := range skipped {
+		if job != "traceroute" {
+			t.Errorf("Expected only the slow job to be skipped, got %s", job)
+		}
+	}
+}
+
+func TestSchedulerJitter(t *testing.T) {
+	job := Job{Name: "throughput", Schedule: interval(time.Hour), Jitter: 5 * time.Minute}
+	s := New(job)
+	random := rand.New(rand.NewSource(1))
+
+	previous := time.Now()
+	for i := 0; i < 20; i++ {
+		scheduled, due := s.plan(job, previous, random)
+		if !scheduled.Equal(previous.Add(time.Hour)) {
+			t.Fatalf("Expected jitter not to shift the schedule, got %s after %s", scheduled, previous)
+		}
+		if delay := due.Sub(scheduled); delay < 0 || delay >= job.Jitter {
+			t.Errorf("Expected a delay within the jitter, got %s", delay)
+		}
+		if next := s.Status()[0].Next; !next.Equal(due) {
+			t.Errorf("Expected the status to show the jittered time %s, got %s", due, next)
+		}
+		previous = scheduled
+	}
+}
+
+func TestSchedulerSkipsMissedRuns(t *testing.T) {
+	job := Job{Name: "dns", Schedule: interval(time.Minute)}
+	s := New(job)
+	scheduled, _ := s.plan(job, time.Now().Add(-time.Hour), rand.New(rand.NewSource(1)))
+	if scheduled.Before(time.Now()) {
+		t.Errorf("Expected runs missed during a suspend to be dropped, got %s", scheduled)
+	}
+}
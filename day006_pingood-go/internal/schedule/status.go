@@ -0,0 +1,121 @@
+package schedule
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// State is what a running scheduler saves after every run, for "pingood
+// status" to read.
+type State struct {
+	PID     int         `json:"pid"`
+	Updated time.Time   `json:"updated"`
+	Jobs    []JobStatus `json:"jobs"`
+}
+
+// DefaultStatePath returns the file used when no state file is configured.
+func DefaultStatePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate cache directory: %w", err)
+	}
+	return filepath.Join(dir, "pingood", "schedule.json"), nil
+}
+
+// SaveState writes state to path. The file is replaced in one step so
+// readers never see half of it.
+func SaveState(path string, state State) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode schedule state: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".schedule-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to write schedule state: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write schedule state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write schedule state: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write schedule state: %w", err)
+	}
+	return nil
+}
+
+func LoadState(path string) (State, error) {
+	var state State
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return state, fmt.Errorf("failed to read schedule state: %w", err)
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, fmt.Errorf("failed to parse schedule state %s: %w", path, err)
+	}
+	return state, nil
+}
+
+// WriteStatus prints one line per job with its last and next run.
+func WriteStatus(w io.Writer, state State, now time.Time) {
+	fmt.Fprintf(w, "Scheduler (pid %d) last updated %s\n\n", state.PID, relative(state.Updated, now))
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHECK\tSCHEDULE\tLAST RUN\tDURATION\tOUTCOME\tNEXT RUN\tRUNS\tFAILED\tSKIPPED")
+	for _, job := range state.Jobs {
+		last, duration, outcome := "never", "-", "-"
+		if !job.LastRun.IsZero() {
+			last = relative(job.LastRun, now)
+			duration = job.LastDuration.Round(time.Millisecond).String()
+			outcome = string(job.LastOutcome.Status)
+			if icon := job.LastOutcome.Status.Icon(); icon != "" {
+				outcome = icon + " " + outcome
+			}
+		}
+		if job.Running {
+			outcome = "running"
+		}
+		next := "-"
+		switch {
+		case job.Next.IsZero():
+		case job.Next.Before(now):
+			next = relative(job.Next, now) + " (overdue)"
+		default:
+			next = relative(job.Next, now)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%d\t%d\t%d\n", job.Name, job.Schedule, last, duration, outcome, next, job.Runs, job.Failures, job.Skipped)
+	}
+	tw.Flush()
+
+	var summaries []string
+	for _, job := range state.Jobs {
+		if job.LastOutcome.Summary != "" {
+			summaries = append(summaries, fmt.Sprintf("  %s: %s", job.Name, job.LastOutcome.Summary))
+		}
+	}
+	if len(summaries) > 0 {
+		fmt.Fprintf(w, "\nLast outcomes:\n%s\n", strings.Join(summaries, "\n"))
+	}
+}
+
+// relative renders t as a clock time with how long ago or ahead it is.
+func relative(t, now time.Time) string {
+	if d := now.Sub(t); d >= 0 {
+		return fmt.Sprintf("%s (%s ago)", t.Format("15:04:05"), d.Round(time.Second))
+	}
+	return fmt.Sprintf("%s (in %s)", t.Format("15:04:05"), t.Sub(now).Round(time.Second))
+}
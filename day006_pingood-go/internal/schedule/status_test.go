@@ -0,0 +1,61 @@
+package schedule
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/report"
+)
+
+func TestStatus(t *testing.T) {
+	now := time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC)
+	state := State{
+		PID:     4242,
+		Updated: now.Add(-5 * time.Second),
+		Jobs: []JobStatus{
+			{
+				Name: "dns", Schedule: "*/30 * * * * *", Next: now.Add(25 * time.Second),
+				LastRun: now.Add(-5 * time.Second), LastDuration: 120 * time.Millisecond,
+				LastOutcome: Outcome{Status: report.StatusPass, Summary: "4 passed"}, Runs: 10,
+			},
+			{
+				Name: "traceroute", Schedule: "*/10 * * * *", Next: now.Add(-time.Minute),
+				LastRun: now.Add(-11 * time.Minute), LastDuration: 9 * time.Second,
+				LastOutcome: Outcome{Status: report.StatusFail, Summary: "Traceroute Test: router failed"}, Runs: 3, Failures: 1, Skipped: 2,
+			},
+			{Name: "throughput", Schedule: "@hourly", Next: now.Add(time.Hour)},
+		},
+	}
+
+	path := filepath.Join(t.TempDir(), "state", "schedule.json")
+	if err := SaveState(path, state); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, state) {
+		t.Errorf("Expected %+v after a round trip, got %+v", state, loaded)
+	}
+
+	var buf bytes.Buffer
+	WriteStatus(&buf, loaded, now)
+	out := buf.String()
+	for _, want := range []string{
+		"Scheduler (pid 4242) last updated 14:59:55 (5s ago)",
+		"120ms", "✅ pass",
+		"15:00:25 (in 25s)",
+		"14:59:00 (1m0s ago) (overdue)",
+		"never",
+		"traceroute: Traceroute Test: router failed",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in:\n%s", want, out)
+		}
+	}
+}
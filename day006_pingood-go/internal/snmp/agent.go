@@ -0,0 +1,177 @@
+package snmp
+
+import (
+	"errors"
+	"net"
+	"sort"
+	"time"
+)
+
+// Agent answers GET, GETNEXT and GETBULK from a fixed set of variables. It
+// stands in for a network device in tests and lab setups. v2c requests
+// must carry Community; v3 requests must come from one of Users.
+type Agent struct {
+	Community string
+	Users     []User
+	EngineID  []byte
+	Variables []Variable
+
+	started time.Time
+	keys    map[string]*usmKeys
+	reports uint64
+}
+
+// Serve answers requests on conn until it is closed.
+func (a *Agent) Serve(conn net.PacketConn) error {
+	if len(a.EngineID) == 0 {
+		// Local enterprise format with an arbitrary text suffix (RFC 3411).
+		a.EngineID = append([]byte{0x80, 0x00, 0x1f, 0x88, 0x04}, "pingood"...)
+	}
+	a.started = time.Now()
+	a.keys = make(map[string]*usmKeys)
+	for _, user := range a.Users {
+		keys, err := newUSMKeys(user, a.EngineID)
+		if err != nil {
+			return err
+		}
+		a.keys[user.Name] = keys
+	}
+	sort.Slice(a.Variables, func(i, j int) bool {
+		return compareOID(a.Variables[i].OID, a.Variables[j].OID) < 0
+	})
+
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		if reply := a.handle(buf[:n]); reply != nil {
+			_, _ = conn.WriteTo(reply, addr)
+		}
+	}
+}
+
+func (a *Agent) handle(raw []byte) []byte {
+	m, err := parseMessage(raw)
+	if err != nil {
+		return nil
+	}
+
+	if m.version == versionV2c {
+		if m.community != a.Community {
+			return nil
+		}
+		reply := &message{version: versionV2c, community: m.community, pdu: a.respond(m.pdu)}
+		out, _ := reply.marshal(nil)
+		return out
+	}
+
+	boots, now := 1, int(time.Since(a.started).Seconds())
+	reply := &message{
+		version:         versionV3,
+		msgID:           m.msgID,
+		usm:             usmParams{engineID: a.EngineID, boots: boots, time: now, user: m.usm.user},
+		contextEngineID: a.EngineID,
+	}
+	report := func(oid string, keys *usmKeys) []byte {
+		if m.flags&flagReportable == 0 {
+			return nil
+		}
+		a.reports++
+		if keys != nil {
+			reply.flags = flagAuth
+		}
+		reply.pdu = pdu{tag: reportPDU, requestID: m.pdu.requestID, vars: []Variable{{OID: oid, Type: Counter32, Value: a.reports}}}
+		out, _ := reply.marshal(keys)
+		return out
+	}
+
+	if string(m.usm.engineID) != string(a.EngineID) {
+		return report(oidUnknownEngineID, nil)
+	}
+	keys, ok := a.keys[m.usm.user]
+	if !ok {
+		return report("1.3.6.1.6.3.15.1.1.3.0", nil)
+	}
+	if m.flags&(flagAuth|flagPriv) != keys.user.flags() {
+		return report("1.3.6.1.6.3.15.1.1.1.0", nil)
+	}
+	if m.flags&flagAuth != 0 {
+		if err := keys.verify(m); err != nil {
+			return report("1.3.6.1.6.3.15.1.1.5.0", nil)
+		}
+		if m.usm.boots != boots || m.usm.time < now-150 || m.usm.time > now+150 {
+			return report(oidNotInTimeWindow, keys)
+		}
+	}
+	if m.flags&flagPriv != 0 {
+		if err := keys.decrypt(m); err != nil {
+			return report("1.3.6.1.6.3.15.1.1.6.0", nil)
+		}
+	}
+
+	reply.flags = m.flags &^ flagReportable
+	reply.pdu = a.respond(m.pdu)
+	out, _ := reply.marshal(keys)
+	return out
+}
+
+func (a *Agent) respond(req pdu) pdu {
+	resp := pdu{tag: getResponse, requestID: req.requestID}
+
+	switch req.tag {
+	case getRequest:
+		for _, v := range req.vars {
+			resp.vars = append(resp.vars, a.get(v.OID))
+		}
+	case getNextRequest:
+		for _, v := range req.vars {
+			resp.vars = append(resp.vars, a.next(v.OID))
+		}
+	case getBulkRequest:
+		nonRepeaters, repetitions := req.errorStatus, req.errorIndex
+		for i, v := range req.vars {
+			if i < nonRepeaters {
+				resp.vars = append(resp.vars, a.next(v.OID))
+				continue
+			}
+			oid := v.OID
+			for r := 0; r < repetitions; r++ {
+				next := a.next(oid)
+				resp.vars = append(resp.vars, next)
+				if next.Type == EndOfMibView {
+					break
+				}
+				oid = next.OID
+			}
+		}
+	default:
+		// genErr
+		resp.errorStatus = 5
+	}
+	return resp
+}
+
+func (a *Agent) get(oid string) Variable {
+	i := sort.Search(len(a.Variables), func(i int) bool {
+		return compareOID(a.Variables[i].OID, oid) >= 0
+	})
+	if i < len(a.Variables) && compareOID(a.Variables[i].OID, oid) == 0 {
+		return a.Variables[i]
+	}
+	return Variable{OID: oid, Type: NoSuchObject}
+}
+
+func (a *Agent) next(oid string) Variable {
+	i := sort.Search(len(a.Variables), func(i int) bool {
+		return compareOID(a.Variables[i].OID, oid) > 0
+	})
+	if i < len(a.Variables) {
+		return a.Variables[i]
+	}
+	return Variable{OID: oid, Type: EndOfMibView}
+}
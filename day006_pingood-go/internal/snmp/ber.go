@@ -0,0 +1,338 @@
+package snmp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Type is the BER tag of a variable binding's value.
+type Type byte
+
+const (
+	Integer        Type = 0x02
+	OctetString    Type = 0x04
+	Null           Type = 0x05
+	ObjectID       Type = 0x06
+	IPAddress      Type = 0x40
+	Counter32      Type = 0x41
+	Gauge32        Type = 0x42
+	TimeTicks      Type = 0x43
+	Opaque         Type = 0x44
+	Counter64      Type = 0x46
+	NoSuchObject   Type = 0x80
+	NoSuchInstance Type = 0x81
+	EndOfMibView   Type = 0x82
+)
+
+const tagSequence = 0x30
+
+var errTruncated = errors.New("truncated BER data")
+
+// Variable is one variable binding. Value holds an int64 for Integer, a
+// uint64 for counters, gauges and time ticks, a []byte for OctetString and
+// Opaque, a string for ObjectID and a net.IP for IPAddress.
+type Variable struct {
+	OID   string
+	Type  Type
+	Value interface{}
+}
+
+// Int returns numeric values as an int64, or 0 for anything else.
+func (v Variable) Int() int64 {
+	switch value := v.Value.(type) {
+	case int64:
+		return value
+	case uint64:
+		return int64(value)
+	case int:
+		return int64(value)
+	case uint32:
+		return int64(value)
+	}
+	return 0
+}
+
+func (v Variable) String() string {
+	switch value := v.Value.(type) {
+	case []byte:
+		return string(value)
+	case string:
+		return value
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(v.Value)
+}
+
+// Exists is false for the exception values an agent returns in place of
+// an unknown OID.
+func (v Variable) Exists() bool {
+	return v.Type != NoSuchObject && v.Type != NoSuchInstance && v.Type != EndOfMibView
+}
+
+func tlv(tag byte, parts ...[]byte) []byte {
+	n := 0
+	for _, part := range parts {
+		n += len(part)
+	}
+	out := append([]byte{tag}, encodeLength(n)...)
+	for _, part := range parts {
+		out = append(out, part...)
+	}
+	return out
+}
+
+func encodeLength(n int) []byte {
+	if n < 0x80 {
+		return []byte{byte(n)}
+	}
+	var b []byte
+	for ; n > 0; n >>= 8 {
+		b = append([]byte{byte(n)}, b...)
+	}
+	return append([]byte{0x80 | byte(len(b))}, b...)
+}
+
+func encodeInt(tag byte, v int64) []byte {
+	n := 1
+	for i := v; i > 127 || i < -128; i >>= 8 {
+		n++
+	}
+	b := make([]byte, n)
+	for j := n - 1; j >= 0; j-- {
+		b[j] = byte(v)
+		v >>= 8
+	}
+	return tlv(tag, b)
+}
+
+func encodeUint(tag byte, v uint64) []byte {
+	b := []byte{byte(v)}
+	for v >>= 8; v > 0; v >>= 8 {
+		b = append([]byte{byte(v)}, b...)
+	}
+	if b[0]&0x80 != 0 {
+		b = append([]byte{0}, b...)
+	}
+	return tlv(tag, b)
+}
+
+func parseOID(oid string) ([]uint64, error) {
+	parts := strings.Split(strings.TrimPrefix(oid, "."), ".")
+	arcs := make([]uint64, 0, len(parts))
+	for _, part := range parts {
+		arc, err := strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid OID %q", oid)
+		}
+		arcs = append(arcs, arc)
+	}
+	if len(arcs) < 2 || arcs[0] > 2 || (arcs[0] < 2 && arcs[1] >= 40) {
+		return nil, fmt.Errorf("invalid OID %q", oid)
+	}
+	return arcs, nil
+}
+
+func encodeOID(oid string) ([]byte, error) {
+	arcs, err := parseOID(oid)
+	if err != nil {
+		return nil, err
+	}
+	content := base128(arcs[0]*40 + arcs[1])
+	for _, arc := range arcs[2:] {
+		content = append(content, base128(arc)...)
+	}
+	return tlv(byte(ObjectID), content), nil
+}
+
+func base128(v uint64) []byte {
+	b := []byte{byte(v & 0x7f)}
+	for v >>= 7; v > 0; v >>= 7 {
+		b = append([]byte{byte(v&0x7f) | 0x80}, b...)
+	}
+	return b
+}
+
+// compareOID orders OIDs arc by arc, as GETNEXT walks them.
+func compareOID(a, b string) int {
+	arcsA, _ := parseOID(a)
+	arcsB, _ := parseOID(b)
+	for i := 0; i < len(arcsA) && i < len(arcsB); i++ {
+		switch {
+		case arcsA[i] < arcsB[i]:
+			return -1
+		case arcsA[i] > arcsB[i]:
+			return 1
+		}
+	}
+	return len(arcsA) - len(arcsB)
+}
+
+func inSubtree(root, oid string) bool {
+	return strings.HasPrefix(strings.TrimPrefix(oid, "."), strings.TrimPrefix(root, ".")+".")
+}
+
+func readTLV(b []byte) (tag byte, content, rest []byte, err error) {
+	if len(b) < 2 {
+		return 0, nil, nil, errTruncated
+	}
+	tag = b[0]
+	length := int(b[1])
+	offset := 2
+	if length&0x80 != 0 {
+		n := length & 0x7f
+		if n == 0 || n > 3 || len(b) < offset+n {
+			return 0, nil, nil, errTruncated
+		}
+		length = 0
+		for _, c := range b[offset : offset+n] {
+			length = length<<8 | int(c)
+		}
+		offset += n
+	}
+	if len(b)-offset < length {
+		return 0, nil, nil, errTruncated
+	}
+	return tag, b[offset : offset+length], b[offset+length:], nil
+}
+
+func expect(b []byte, want byte) (content, rest []byte, err error) {
+	tag, content, rest, err := readTLV(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	if tag != want {
+		return nil, nil, fmt.Errorf("unexpected BER tag 0x%02x, want 0x%02x", tag, want)
+	}
+	return content, rest, nil
+}
+
+func decodeInt(content []byte) (int64, error) {
+	if len(content) == 0 || len(content) > 8 {
+		return 0, fmt.Errorf("invalid integer length %d", len(content))
+	}
+	v := int64(int8(content[0]))
+	for _, c := range content[1:] {
+		v = v<<8 | int64(c)
+	}
+	return v, nil
+}
+
+func decodeUint(content []byte) (uint64, error) {
+	if len(content) == 0 || len(content) > 9 || (len(content) == 9 && content[0] != 0) {
+		return 0, fmt.Errorf("invalid unsigned length %d", len(content))
+	}
+	var v uint64
+	for _, c := range content {
+		v = v<<8 | uint64(c)
+	}
+	return v, nil
+}
+
+func readInt(b []byte) (int64, []byte, error) {
+	content, rest, err := expect(b, byte(Integer))
+	if err != nil {
+		return 0, nil, err
+	}
+	v, err := decodeInt(content)
+	return v, rest, err
+}
+
+func decodeOID(content []byte) (string, error) {
+	var arcs []uint64
+	var v uint64
+	for i, c := range content {
+		v = v<<7 | uint64(c&0x7f)
+		if c&0x80 == 0 {
+			arcs = append(arcs, v)
+			v = 0
+		} else if i == len(content)-1 {
+			return "", errTruncated
+		}
+	}
+	if len(arcs) == 0 {
+		return "", errTruncated
+	}
+
+	var sb strings.Builder
+	if arcs[0] < 80 {
+		fmt.Fprintf(&sb, "%d.%d", arcs[0]/40, arcs[0]%40)
+	} else {
+		fmt.Fprintf(&sb, "2.%d", arcs[0]-80)
+	}
+	for _, arc := range arcs[1:] {
+		fmt.Fprintf(&sb, ".%d", arc)
+	}
+	return sb.String(), nil
+}
+
+func encodeVariable(v Variable) ([]byte, error) {
+	oid, err := encodeOID(v.OID)
+	if err != nil {
+		return nil, err
+	}
+
+	var value []byte
+	switch v.Type {
+	case Integer:
+		value = encodeInt(byte(Integer), v.Int())
+	case OctetString, Opaque:
+		switch raw := v.Value.(type) {
+		case []byte:
+			value = tlv(byte(v.Type), raw)
+		default:
+			value = tlv(byte(v.Type), []byte(v.String()))
+		}
+	case ObjectID:
+		if value, err = encodeOID(v.String()); err != nil {
+			return nil, err
+		}
+	case IPAddress:
+		ip, _ := v.Value.(net.IP)
+		if ip.To4() == nil {
+			return nil, fmt.Errorf("%s: IpAddress needs an IPv4 address", v.OID)
+		}
+		value = tlv(byte(IPAddress), ip.To4())
+	case Counter32, Gauge32, TimeTicks, Counter64:
+		value = encodeUint(byte(v.Type), uint64(v.Int()))
+	default:
+		value = tlv(byte(v.Type))
+	}
+	return tlv(tagSequence, oid, value), nil
+}
+
+func decodeVariable(b []byte) (Variable, error) {
+	oidContent, rest, err := expect(b, byte(ObjectID))
+	if err != nil {
+		return Variable{}, err
+	}
+	oid, err := decodeOID(oidContent)
+	if err != nil {
+		return Variable{}, err
+	}
+	tag, content, _, err := readTLV(rest)
+	if err != nil {
+		return Variable{}, err
+	}
+
+	v := Variable{OID: oid, Type: Type(tag)}
+	switch v.Type {
+	case Integer:
+		v.Value, err = decodeInt(content)
+	case OctetString, Opaque:
+		v.Value = append([]byte(nil), content...)
+	case ObjectID:
+		v.Value, err = decodeOID(content)
+	case IPAddress:
+		if len(content) != 4 {
+			return v, fmt.Errorf("%s: invalid IpAddress length %d", oid, len(content))
+		}
+		v.Value = net.IP(append([]byte(nil), content...))
+	case Counter32, Gauge32, TimeTicks, Counter64:
+		v.Value, err = decodeUint(content)
+	}
+	return v, err
+}
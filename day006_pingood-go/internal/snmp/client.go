@@ -0,0 +1,278 @@
+// Package snmp implements the part of SNMP v2c and v3 (RFC 3416, RFC 3414)
+// needed to read a device's health: GET and GETBULK walks from a client,
+// and an agent that serves a fixed set of values in their place for tests.
+package snmp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+type Version int
+
+const (
+	V2c Version = versionV2c
+	V3  Version = versionV3
+)
+
+// ParseVersion accepts "2c" and "3".
+func ParseVersion(s string) (Version, error) {
+	switch strings.TrimPrefix(strings.ToLower(s), "v") {
+	case "2c", "2":
+		return V2c, nil
+	case "3":
+		return V3, nil
+	default:
+		return 0, fmt.Errorf("unsupported SNMP version %q (use 2c or 3)", s)
+	}
+}
+
+// Client talks to one agent. Address is host or host:port, port 161 by
+// default. For V3 the agent's engine is discovered on the first request.
+type Client struct {
+	Address   string
+	Version   Version
+	Community string
+	User      User
+	Timeout   time.Duration
+	Retries   int
+
+	conn      net.Conn
+	requestID atomic.Int32
+	engine    *engine
+}
+
+// engine is what a v3 client learned about the agent's SNMP engine.
+type engine struct {
+	id     []byte
+	boots  int
+	time   int
+	synced time.Time
+	keys   *usmKeys
+}
+
+func (e *engine) now() int {
+	return e.time + int(time.Since(e.synced).Seconds())
+}
+
+func (c *Client) Close() error {
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+// Get fetches the given OIDs. OIDs the agent does not know come back with
+// a NoSuchObject or NoSuchInstance type rather than as an error.
+func (c *Client) Get(ctx context.Context, oids ...string) ([]Variable, error) {
+	req := pdu{tag: getRequest}
+	for _, oid := range oids {
+		req.vars = append(req.vars, Variable{OID: oid, Type: Null})
+	}
+	resp, err := c.request(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.errorStatus != 0 {
+		return nil, fmt.Errorf("agent returned %s", errorStatusName(resp.errorStatus))
+	}
+	return resp.vars, nil
+}
+
+// Walk returns every variable below root, using GETBULK.
+func (c *Client) Walk(ctx context.Context, root string) ([]Variable, error) {
+	var vars []Variable
+	current := root
+	for {
+		req := pdu{tag: getBulkRequest, errorIndex: 20, vars: []Variable{{OID: current, Type: Null}}}
+		resp, err := c.request(ctx, req)
+		if err != nil {
+			return vars, err
+		}
+		if resp.errorStatus != 0 {
+			return vars, fmt.Errorf("agent returned %s", errorStatusName(resp.errorStatus))
+		}
+		if len(resp.vars) == 0 {
+			return vars, nil
+		}
+		for _, v := range resp.vars {
+			if v.Type == EndOfMibView || !inSubtree(root, v.OID) {
+				return vars, nil
+			}
+			if compareOID(v.OID, current) <= 0 {
+				return vars, fmt.Errorf("agent returned OID %s out of order after %s", v.OID, current)
+			}
+			vars = append(vars, v)
+			current = v.OID
+		}
+	}
+}
+
+func (c *Client) request(ctx context.Context, req pdu) (pdu, error) {
+	if c.conn == nil {
+		address := c.Address
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			address = net.JoinHostPort(address, "161")
+		}
+		conn, err := net.Dial("udp", address)
+		if err != nil {
+			return pdu{}, fmt.Errorf("failed to connect to %s: %w", address, err)
+		}
+		c.conn = conn
+	}
+
+	if c.Version != V3 {
+		req.requestID = c.requestID.Add(1)
+		m := &message{version: versionV2c, community: c.Community, pdu: req}
+		reply, err := c.exchange(ctx, m, nil, func(reply *message) bool {
+			return reply.version == versionV2c && reply.pdu.requestID == req.requestID
+		})
+		if err != nil {
+			return pdu{}, err
+		}
+		return reply.pdu, nil
+	}
+
+	if c.engine == nil {
+		if err := c.discover(ctx); err != nil {
+			return pdu{}, err
+		}
+	}
+	resynced := false
+	for {
+		req.requestID = c.requestID.Add(1)
+		m := &message{
+			version:         versionV3,
+			msgID:           req.requestID,
+			flags:           c.User.flags() | flagReportable,
+			usm:             usmParams{engineID: c.engine.id, boots: c.engine.boots, time: c.engine.now(), user: c.User.Name},
+			contextEngineID: c.engine.id,
+			pdu:             req,
+		}
+		reply, err := c.exchange(ctx, m, c.engine.keys, func(reply *message) bool {
+			return reply.version == versionV3 && reply.msgID == req.requestID
+		})
+		if err != nil {
+			return pdu{}, err
+		}
+		if reply.pdu.tag != reportPDU {
+			if reply.flags&flagAuth != m.flags&flagAuth {
+				return pdu{}, fmt.Errorf("agent answered below the requested security level")
+			}
+			return reply.pdu, nil
+		}
+
+		oid := ""
+		if len(reply.pdu.vars) > 0 {
+			oid = reply.pdu.vars[0].OID
+		}
+		// The first authenticated request after discovery can fall outside
+		// the time window; the report carries the agent's clock.
+		if oid == oidNotInTimeWindow && !resynced {
+			c.engine.boots, c.engine.time, c.engine.synced = reply.usm.boots, reply.usm.time, time.Now()
+			resynced = true
+			continue
+		}
+		if reason, ok := usmReports[oid]; ok {
+			return pdu{}, fmt.Errorf("agent rejected request: %s", reason)
+		}
+		return pdu{}, fmt.Errorf("agent returned report %s", oid)
+	}
+}
+
+// discover asks for the agent's engine ID, boots and time with an
+// unauthenticated request, then localizes the user's keys to that engine.
+func (c *Client) discover(ctx context.Context) error {
+	id := c.requestID.Add(1)
+	m := &message{version: versionV3, msgID: id, flags: flagReportable, pdu: pdu{tag: getRequest, requestID: id}}
+	reply, err := c.exchange(ctx, m, nil, func(reply *message) bool {
+		return reply.version == versionV3 && reply.msgID == id
+	})
+	if err != nil {
+		return fmt.Errorf("failed to discover SNMP engine: %w", err)
+	}
+	if len(reply.usm.engineID) == 0 {
+		return fmt.Errorf("failed to discover SNMP engine: agent sent no engine ID")
+	}
+
+	keys, err := newUSMKeys(c.User, reply.usm.engineID)
+	if err != nil {
+		return err
+	}
+	c.engine = &engine{id: reply.usm.engineID, boots: reply.usm.boots, time: reply.usm.time, synced: time.Now(), keys: keys}
+	return nil
+}
+
+// exchange sends m and waits for the reply match accepts, resending up to
+// Retries times.
+func (c *Client) exchange(ctx context.Context, m *message, keys *usmKeys, match func(*message) bool) (*message, error) {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	raw, err := m.marshal(keys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	buf := make([]byte, maxMessageSize)
+	for attempt := 0; attempt <= c.Retries; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		deadline := time.Now().Add(timeout)
+		if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+			deadline = d
+		}
+		if err := c.conn.SetDeadline(deadline); err != nil {
+			return nil, err
+		}
+		if _, err := c.conn.Write(raw); err != nil {
+			return nil, fmt.Errorf("failed to send request: %w", err)
+		}
+
+		for {
+			n, err := c.conn.Read(buf)
+			if err != nil {
+				var netErr net.Error
+				if errors.As(err, &netErr) && netErr.Timeout() {
+					break
+				}
+				return nil, fmt.Errorf("failed to read reply: %w", err)
+			}
+			reply, err := parseMessage(buf[:n])
+			if err != nil || !match(reply) {
+				continue
+			}
+			if err := c.open(reply, keys); err != nil {
+				return nil, err
+			}
+			return reply, nil
+		}
+	}
+	return nil, fmt.Errorf("no reply from %s within %s", c.Address, timeout)
+}
+
+// open authenticates and decrypts a v3 reply as its flags require.
+func (c *Client) open(reply *message, keys *usmKeys) error {
+	if reply.version != versionV3 || reply.flags&flagAuth == 0 {
+		return nil
+	}
+	if keys == nil {
+		return errAuthentication
+	}
+	if err := keys.verify(reply); err != nil {
+		return err
+	}
+	if reply.flags&flagPriv != 0 {
+		return keys.decrypt(reply)
+	}
+	return nil
+}
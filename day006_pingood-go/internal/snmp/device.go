@@ -0,0 +1,157 @@
+package snmp
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	oidSysDescr  = "1.3.6.1.2.1.1.1.0"
+	oidSysUpTime = "1.3.6.1.2.1.1.3.0"
+	oidSysName   = "1.3.6.1.2.1.1.5.0"
+
+	oidIfDescr       = "1.3.6.1.2.1.2.2.1.2"
+	oidIfAdminStatus = "1.3.6.1.2.1.2.2.1.7"
+	oidIfOperStatus  = "1.3.6.1.2.1.2.2.1.8"
+	oidIfInDiscards  = "1.3.6.1.2.1.2.2.1.13"
+	oidIfInErrors    = "1.3.6.1.2.1.2.2.1.14"
+	oidIfOutDiscards = "1.3.6.1.2.1.2.2.1.19"
+	oidIfOutErrors   = "1.3.6.1.2.1.2.2.1.20"
+	oidIfName        = "1.3.6.1.2.1.31.1.1.1.1"
+)
+
+// Status is ifAdminStatus or ifOperStatus from the IF-MIB.
+type Status int
+
+const (
+	StatusUp             Status = 1
+	StatusDown           Status = 2
+	StatusTesting        Status = 3
+	StatusUnknown        Status = 4
+	StatusDormant        Status = 5
+	StatusNotPresent     Status = 6
+	StatusLowerLayerDown Status = 7
+)
+
+func (s Status) String() string {
+	names := map[Status]string{
+		StatusUp: "up", StatusDown: "down", StatusTesting: "testing", StatusUnknown: "unknown",
+		StatusDormant: "dormant", StatusNotPresent: "notPresent", StatusLowerLayerDown: "lowerLayerDown",
+	}
+	if name, ok := names[s]; ok {
+		return name
+	}
+	return strconv.Itoa(int(s))
+}
+
+type Device struct {
+	Name        string
+	Description string
+	Uptime      time.Duration
+	Interfaces  []Interface
+}
+
+// Interface is one ifTable row. The counters are totals since the device
+// or interface was last reset.
+type Interface struct {
+	Index       int
+	Name        string
+	AdminStatus Status
+	OperStatus  Status
+	InErrors    uint64
+	OutErrors   uint64
+	InDiscards  uint64
+	OutDiscards uint64
+}
+
+// Down reports an interface that is enabled but not passing traffic.
+// Administratively disabled interfaces are expected to be down.
+func (i Interface) Down() bool {
+	return i.AdminStatus == StatusUp && i.OperStatus != StatusUp && i.OperStatus != StatusDormant
+}
+
+// InterfacesDown lists the interfaces for which Down is true.
+func (d *Device) InterfacesDown() []Interface {
+	var down []Interface
+	for _, iface := range d.Interfaces {
+		if iface.Down() {
+			down = append(down, iface)
+		}
+	}
+	return down
+}
+
+// Poll reads the system group and the interface table.
+func Poll(ctx context.Context, c *Client) (*Device, error) {
+	vars, err := c.Get(ctx, oidSysDescr, oidSysUpTime, oidSysName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query system group: %w", err)
+	}
+	device := &Device{}
+	for _, v := range vars {
+		if !v.Exists() {
+			continue
+		}
+		switch v.OID {
+		case oidSysDescr:
+			device.Description = strings.TrimSpace(v.String())
+		case oidSysUpTime:
+			device.Uptime = time.Duration(v.Int()) * 10 * time.Millisecond
+		case oidSysName:
+			device.Name = v.String()
+		}
+	}
+
+	interfaces := make(map[int]*Interface)
+	columns := []struct {
+		oid string
+		set func(*Interface, Variable)
+	}{
+		{oidIfDescr, func(i *Interface, v Variable) {
+			if i.Name == "" {
+				i.Name = v.String()
+			}
+		}},
+		{oidIfName, func(i *Interface, v Variable) {
+			if name := v.String(); name != "" {
+				i.Name = name
+			}
+		}},
+		{oidIfAdminStatus, func(i *Interface, v Variable) { i.AdminStatus = Status(v.Int()) }},
+		{oidIfOperStatus, func(i *Interface, v Variable) { i.OperStatus = Status(v.Int()) }},
+		{oidIfInDiscards, func(i *Interface, v Variable) { i.InDiscards = uint64(v.Int()) }},
+		{oidIfInErrors, func(i *Interface, v Variable) { i.InErrors = uint64(v.Int()) }},
+		{oidIfOutDiscards, func(i *Interface, v Variable) { i.OutDiscards = uint64(v.Int()) }},
+		{oidIfOutErrors, func(i *Interface, v Variable) { i.OutErrors = uint64(v.Int()) }},
+	}
+	for _, column := range columns {
+		vars, err := c.Walk(ctx, column.oid)
+		if err != nil {
+			return device, fmt.Errorf("failed to walk interface table: %w", err)
+		}
+		for _, v := range vars {
+			index, err := strconv.Atoi(strings.TrimPrefix(v.OID, column.oid+"."))
+			if err != nil {
+				continue
+			}
+			iface, ok := interfaces[index]
+			if !ok {
+				iface = &Interface{Index: index}
+				interfaces[index] = iface
+			}
+			column.set(iface, v)
+		}
+	}
+
+	for _, iface := range interfaces {
+		device.Interfaces = append(device.Interfaces, *iface)
+	}
+	sort.Slice(device.Interfaces, func(i, j int) bool {
+		return device.Interfaces[i].Index < device.Interfaces[j].Index
+	})
+	return device, nil
+}
@@ -0,0 +1,314 @@
+package snmp
+
+import (
+	"fmt"
+)
+
+type pduType byte
+
+const (
+	getRequest     pduType = 0xa0
+	getNextRequest pduType = 0xa1
+	getResponse    pduType = 0xa2
+	getBulkRequest pduType = 0xa5
+	reportPDU      pduType = 0xa8
+)
+
+// pdu is shared by all request types. For GETBULK, errorStatus and
+// errorIndex carry non-repeaters and max-repetitions.
+type pdu struct {
+	tag         pduType
+	requestID   int32
+	errorStatus int
+	errorIndex  int
+	vars        []Variable
+}
+
+var errorStatusNames = []string{"noError", "tooBig", "noSuchName", "badValue", "readOnly", "genErr", "noAccess"}
+
+func errorStatusName(status int) string {
+	if status >= 0 && status < len(errorStatusNames) {
+		return errorStatusNames[status]
+	}
+	return fmt.Sprintf("error %d", status)
+}
+
+func (p pdu) marshal() ([]byte, error) {
+	var bindings []byte
+	for _, v := range p.vars {
+		encoded, err := encodeVariable(v)
+		if err != nil {
+			return nil, err
+		}
+		bindings = append(bindings, encoded...)
+	}
+	return tlv(byte(p.tag),
+		encodeInt(byte(Integer), int64(p.requestID)),
+		encodeInt(byte(Integer), int64(p.errorStatus)),
+		encodeInt(byte(Integer), int64(p.errorIndex)),
+		tlv(tagSequence, bindings),
+	), nil
+}
+
+func parsePDU(b []byte) (pdu, error) {
+	tag, content, _, err := readTLV(b)
+	if err != nil {
+		return pdu{}, err
+	}
+	p := pdu{tag: pduType(tag)}
+
+	var id, status, index int64
+	if id, content, err = readInt(content); err != nil {
+		return p, err
+	}
+	if status, content, err = readInt(content); err != nil {
+		return p, err
+	}
+	if index, content, err = readInt(content); err != nil {
+		return p, err
+	}
+	p.requestID, p.errorStatus, p.errorIndex = int32(id), int(status), int(index)
+
+	bindings, _, err := expect(content, tagSequence)
+	if err != nil {
+		return p, err
+	}
+	for len(bindings) > 0 {
+		var binding []byte
+		if binding, bindings, err = expect(bindings, tagSequence); err != nil {
+			return p, err
+		}
+		v, err := decodeVariable(binding)
+		if err != nil {
+			return p, err
+		}
+		p.vars = append(p.vars, v)
+	}
+	return p, nil
+}
+
+const (
+	versionV2c = 1
+	versionV3  = 3
+
+	flagAuth       byte = 0x01
+	flagPriv       byte = 0x02
+	flagReportable byte = 0x04
+
+	securityModelUSM = 3
+	maxMessageSize   = 65507
+	authParamsLength = 12
+)
+
+// usmParams is the user-based security model header of an SNMPv3 message
+// (RFC 3414).
+type usmParams struct {
+	engineID   []byte
+	boots      int
+	time       int
+	user       string
+	authParams []byte
+	privParams []byte
+}
+
+type message struct {
+	version   int
+	community string
+
+	msgID           int32
+	flags           byte
+	usm             usmParams
+	contextEngineID []byte
+	contextName     string
+	// encrypted holds the scoped PDU of a received message until it is
+	// decrypted; authOffset locates the authentication parameters in raw.
+	encrypted  []byte
+	raw        []byte
+	authOffset int
+
+	pdu pdu
+}
+
+// marshal encodes m, encrypting and signing v3 messages with keys as
+// m.flags requires.
+func (m *message) marshal(keys *usmKeys) ([]byte, error) {
+	pduBytes, err := m.pdu.marshal()
+	if err != nil {
+		return nil, err
+	}
+	if m.version != versionV3 {
+		return tlv(tagSequence,
+			encodeInt(byte(Integer), int64(m.version)),
+			tlv(byte(OctetString), []byte(m.community)),
+			pduBytes,
+		), nil
+	}
+
+	if m.flags&(flagAuth|flagPriv) != 0 && keys == nil {
+		return nil, fmt.Errorf("no keys for an authenticated message")
+	}
+	scoped := tlv(tagSequence,
+		tlv(byte(OctetString), m.contextEngineID),
+		tlv(byte(OctetString), []byte(m.contextName)),
+		pduBytes,
+	)
+	m.usm.privParams = nil
+	if m.flags&flagPriv != 0 {
+		encrypted, salt, err := keys.encrypt(scoped, m.usm.boots, m.usm.time)
+		if err != nil {
+			return nil, err
+		}
+		scoped = tlv(byte(OctetString), encrypted)
+		m.usm.privParams = salt
+	}
+	m.usm.authParams = nil
+	if m.flags&flagAuth != 0 {
+		m.usm.authParams = make([]byte, authParamsLength)
+	}
+
+	before := concat(
+		tlv(byte(OctetString), m.usm.engineID),
+		encodeInt(byte(Integer), int64(m.usm.boots)),
+		encodeInt(byte(Integer), int64(m.usm.time)),
+		tlv(byte(OctetString), []byte(m.usm.user)),
+	)
+	after := concat(
+		tlv(byte(OctetString), m.usm.authParams),
+		tlv(byte(OctetString), m.usm.privParams),
+	)
+	raw := tlv(tagSequence,
+		encodeInt(byte(Integer), versionV3),
+		tlv(tagSequence,
+			encodeInt(byte(Integer), int64(m.msgID)),
+			encodeInt(byte(Integer), maxMessageSize),
+			tlv(byte(OctetString), []byte{m.flags}),
+			encodeInt(byte(Integer), securityModelUSM),
+		),
+		tlv(byte(OctetString), tlv(tagSequence, before, after)),
+		scoped,
+	)
+
+	if m.flags&flagAuth != 0 {
+		// The authentication parameters are the first thing after the user
+		// name; their content starts two bytes into the OCTET STRING.
+		offset := len(raw) - len(scoped) - len(after) + 2
+		copy(raw[offset:], keys.sign(raw))
+	}
+	return raw, nil
+}
+
+func concat(parts ...[]byte) []byte {
+	var out []byte
+	for _, part := range parts {
+		out = append(out, part...)
+	}
+	return out
+}
+
+// parseMessage decodes the envelope of a v2c or v3 message. An encrypted
+// scoped PDU is left in m.encrypted for the caller to decrypt once it has
+// found the user's keys.
+func parseMessage(b []byte) (*message, error) {
+	content, trailing, err := expect(b, tagSequence)
+	if err != nil {
+		return nil, err
+	}
+	b = b[:len(b)-len(trailing)]
+	version, content, err := readInt(content)
+	if err != nil {
+		return nil, err
+	}
+	m := &message{version: int(version)}
+
+	switch m.version {
+	case versionV2c:
+		community, rest, err := expect(content, byte(OctetString))
+		if err != nil {
+			return nil, err
+		}
+		m.community = string(community)
+		m.pdu, err = parsePDU(rest)
+		return m, err
+	case versionV3:
+	default:
+		return nil, fmt.Errorf("unsupported SNMP version %d", version)
+	}
+
+	m.raw = b
+	header, content, err := expect(content, tagSequence)
+	if err != nil {
+		return nil, err
+	}
+	msgID, header, err := readInt(header)
+	if err != nil {
+		return nil, err
+	}
+	m.msgID = int32(msgID)
+	if _, header, err = readInt(header); err != nil {
+		return nil, err
+	}
+	flags, header, err := expect(header, byte(OctetString))
+	if err != nil || len(flags) != 1 {
+		return nil, fmt.Errorf("invalid msgFlags")
+	}
+	m.flags = flags[0]
+	if model, _, err := readInt(header); err != nil || model != securityModelUSM {
+		return nil, fmt.Errorf("unsupported security model")
+	}
+
+	secParams, scoped, err := expect(content, byte(OctetString))
+	if err != nil {
+		return nil, err
+	}
+	sec, _, err := expect(secParams, tagSequence)
+	if err != nil {
+		return nil, err
+	}
+	var boots, engineTime int64
+	var user []byte
+	if m.usm.engineID, sec, err = expect(sec, byte(OctetString)); err != nil {
+		return nil, err
+	}
+	if boots, sec, err = readInt(sec); err != nil {
+		return nil, err
+	}
+	if engineTime, sec, err = readInt(sec); err != nil {
+		return nil, err
+	}
+	if user, sec, err = expect(sec, byte(OctetString)); err != nil {
+		return nil, err
+	}
+	m.usm.boots, m.usm.time, m.usm.user = int(boots), int(engineTime), string(user)
+	if m.usm.authParams, sec, err = expect(sec, byte(OctetString)); err != nil {
+		return nil, err
+	}
+	m.authOffset = len(b) - len(scoped) - len(sec) - len(m.usm.authParams)
+	if m.usm.privParams, _, err = expect(sec, byte(OctetString)); err != nil {
+		return nil, err
+	}
+
+	if m.flags&flagPriv != 0 {
+		if m.encrypted, _, err = expect(scoped, byte(OctetString)); err != nil {
+			return nil, err
+		}
+		return m, nil
+	}
+	return m, m.parseScoped(scoped)
+}
+
+func (m *message) parseScoped(b []byte) error {
+	scoped, _, err := expect(b, tagSequence)
+	if err != nil {
+		return err
+	}
+	var name []byte
+	if m.contextEngineID, scoped, err = expect(scoped, byte(OctetString)); err != nil {
+		return err
+	}
+	if name, scoped, err = expect(scoped, byte(OctetString)); err != nil {
+		return err
+	}
+	m.contextName = string(name)
+	m.pdu, err = parsePDU(scoped)
+	return err
+}
@@ -0,0 +1,174 @@
+package snmp
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBERRoundTrip(t *testing.T) {
+	for _, v := range []int64{0, 1, 127, 128, 255, 256, -1, -128, -129, 1 << 40} {
+		content, _, err := expect(encodeInt(byte(Integer), v), byte(Integer))
+		if err != nil {
+			t.Fatalf("expect failed: %v", err)
+		}
+		if got, _ := decodeInt(content); got != v {
+			t.Errorf("Integer %d decoded as %d", v, got)
+		}
+	}
+
+	for _, oid := range []string{"1.3.6.1.2.1.1.5.0", "1.3.6.1.4.1.2636.3.1.13.1.8.9.1.0.0", "2.999.3"} {
+		encoded, err := encodeOID(oid)
+		if err != nil {
+			t.Fatalf("encodeOID(%q) failed: %v", oid, err)
+		}
+		content, _, _ := expect(encoded, byte(ObjectID))
+		if got, _ := decodeOID(content); got != oid {
+			t.Errorf("OID %s decoded as %s", oid, got)
+		}
+	}
+
+	long := Variable{OID: "1.3.6.1.2.1.1.1.0", Type: OctetString, Value: strings.Repeat("x", 300)}
+	encoded, err := encodeVariable(long)
+	if err != nil {
+		t.Fatalf("encodeVariable failed: %v", err)
+	}
+	binding, _, _ := expect(encoded, tagSequence)
+	if got, err := decodeVariable(binding); err != nil || got.String() != long.String() {
+		t.Errorf("Long OCTET STRING did not survive the round trip: %v", err)
+	}
+
+	if compareOID("1.3.6.1.2.1.2.2.1.2.10", "1.3.6.1.2.1.2.2.1.2.9") <= 0 {
+		t.Error("Expected OIDs to compare arc by arc")
+	}
+}
+
+func testVariables() []Variable {
+	return []Variable{
+		{OID: oidSysDescr, Type: OctetString, Value: "Lab router"},
+		{OID: oidSysUpTime, Type: TimeTicks, Value: uint64(8640000)},
+		{OID: oidSysName, Type: OctetString, Value: "core-rtr1"},
+		{OID: oidIfDescr + ".1", Type: OctetString, Value: "GigabitEthernet0/0"},
+		{OID: oidIfDescr + ".2", Type: OctetString, Value: "GigabitEthernet0/1"},
+		{OID: oidIfDescr + ".3", Type: OctetString, Value: "GigabitEthernet0/2"},
+		{OID: oidIfAdminStatus + ".1", Type: Integer, Value: 1},
+		{OID: oidIfAdminStatus + ".2", Type: Integer, Value: 1},
+		{OID: oidIfAdminStatus + ".3", Type: Integer, Value: 2},
+		{OID: oidIfOperStatus + ".1", Type: Integer, Value: 1},
+		{OID: oidIfOperStatus + ".2", Type: Integer, Value: 2},
+		{OID: oidIfOperStatus + ".3", Type: Integer, Value: 2},
+		{OID: oidIfInErrors + ".1", Type: Counter32, Value: uint64(152)},
+		{OID: oidIfOutErrors + ".1", Type: Counter32, Value: uint64(3)},
+		{OID: oidIfName + ".1", Type: OctetString, Value: "Gi0/0"},
+	}
+}
+
+func startAgent(t *testing.T, agent *Agent) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go agent.Serve(conn)
+	return conn.LocalAddr().String()
+}
+
+func TestPollV2c(t *testing.T) {
+	address := startAgent(t, &Agent{Community: "lab", Variables: testVariables()})
+
+	client := &Client{Address: address, Version: V2c, Community: "lab", Timeout: time.Second}
+	defer client.Close()
+	device, err := Poll(context.Background(), client)
+	if err != nil {
+		t.Fatalf("Poll failed: %v", err)
+	}
+
+	if device.Name != "core-rtr1" || device.Uptime != 24*time.Hour {
+		t.Errorf("Unexpected system group: %+v", device)
+	}
+	if len(device.Interfaces) != 3 {
+		t.Fatalf("Expected 3 interfaces, got %+v", device.Interfaces)
+	}
+	first := device.Interfaces[0]
+	if first.Name != "Gi0/0" || first.InErrors != 152 || first.OutErrors != 3 {
+		t.Errorf("Unexpected first interface: %+v", first)
+	}
+	if down := device.InterfacesDown(); len(down) != 1 || down[0].Name != "GigabitEthernet0/1" {
+		t.Errorf("Expected only the enabled interface to count as down, got %+v", down)
+	}
+
+	vars, err := client.Get(context.Background(), "1.3.6.1.2.1.1.4.0")
+	if err != nil || len(vars) != 1 || vars[0].Exists() {
+		t.Errorf("Expected NoSuchObject for an unknown OID, got %+v, %v", vars, err)
+	}
+
+	wrong := &Client{Address: address, Version: V2c, Community: "public", Timeout: 200 * time.Millisecond}
+	defer wrong.Close()
+	if _, err := Poll(context.Background(), wrong); err == nil {
+		t.Error("Expected a wrong community to get no answer")
+	}
+}
+
+func TestPollV3(t *testing.T) {
+	users := []User{
+		{Name: "auth-only", AuthProtocol: "MD5", AuthPassword: "maplesyrup"},
+		{Name: "sha-aes", AuthProtocol: "SHA", AuthPassword: "authpass123", PrivProtocol: "AES", PrivPassword: "privpass123"},
+		{Name: "md5-des", AuthProtocol: "MD5", AuthPassword: "authpass123", PrivProtocol: "DES", PrivPassword: "privpass123"},
+	}
+	address := startAgent(t, &Agent{Users: users, Variables: testVariables()})
+
+	for _, user := range users {
+		t.Run(user.Name, func(t *testing.T) {
+			client := &Client{Address: address, Version: V3, User: user, Timeout: time.Second}
+			defer client.Close()
+			device, err := Poll(context.Background(), client)
+			if err != nil {
+				t.Fatalf("Poll failed: %v", err)
+			}
+			if device.Name != "core-rtr1" || len(device.Interfaces) != 3 {
+				t.Errorf("Unexpected device: %+v", device)
+			}
+		})
+	}
+
+	tests := []struct {
+		name string
+		user User
+		want string
+	}{
+		{"wrong auth password", User{Name: "sha-aes", AuthProtocol: "SHA", AuthPassword: "wrongpass1", PrivProtocol: "AES", PrivPassword: "privpass123"}, "wrong digest"},
+		{"wrong privacy password", User{Name: "sha-aes", AuthProtocol: "SHA", AuthPassword: "authpass123", PrivProtocol: "AES", PrivPassword: "wrongpass1"}, "decryption error"},
+		{"unknown user", User{Name: "nobody", AuthProtocol: "SHA", AuthPassword: "authpass123"}, "unknown user"},
+		{"lower security level", User{Name: "sha-aes", AuthProtocol: "SHA", AuthPassword: "authpass123"}, "unsupported security level"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &Client{Address: address, Version: V3, User: tt.user, Timeout: time.Second}
+			defer client.Close()
+			_, err := Poll(context.Background(), client)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestLocalizeKey(t *testing.T) {
+	// RFC 3414 A.3.1 test vector.
+	engineID := []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 2}
+	keys, err := newUSMKeys(User{Name: "u", AuthProtocol: "MD5", AuthPassword: "maplesyrup"}, engineID)
+	if err != nil {
+		t.Fatalf("newUSMKeys failed: %v", err)
+	}
+	want := []byte{0x52, 0x6f, 0x5e, 0xed, 0x9f, 0xcc, 0xe2, 0x6f, 0x89, 0x64, 0xc2, 0x93, 0x07, 0x87, 0xd8, 0x2b}
+	if string(keys.authKey) != string(want) {
+		t.Errorf("Expected localized key %x, got %x", want, keys.authKey)
+	}
+
+	if _, err := newUSMKeys(User{Name: "u", AuthProtocol: "SHA", AuthPassword: "short"}, engineID); err == nil {
+		t.Error("Expected a password under 8 characters to be rejected")
+	}
+}
@@ -0,0 +1,231 @@
+package snmp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+	"sync/atomic"
+)
+
+// User holds SNMPv3 credentials. AuthProtocol is "MD5" or "SHA" and
+// PrivProtocol "DES" or "AES" (AES-128); leave them empty for noAuthNoPriv
+// or authNoPriv.
+type User struct {
+	Name         string
+	AuthProtocol string
+	AuthPassword string
+	PrivProtocol string
+	PrivPassword string
+}
+
+func (u User) flags() byte {
+	var flags byte
+	if u.AuthProtocol != "" {
+		flags |= flagAuth
+	}
+	if u.PrivProtocol != "" {
+		flags |= flagPriv
+	}
+	return flags
+}
+
+// Reports an agent sends instead of a response when USM rejects a request.
+var usmReports = map[string]string{
+	"1.3.6.1.6.3.15.1.1.1.0": "unsupported security level",
+	"1.3.6.1.6.3.15.1.1.2.0": "not in time window",
+	"1.3.6.1.6.3.15.1.1.3.0": "unknown user name",
+	"1.3.6.1.6.3.15.1.1.4.0": "unknown engine ID",
+	"1.3.6.1.6.3.15.1.1.5.0": "wrong digest (check the authentication password)",
+	"1.3.6.1.6.3.15.1.1.6.0": "decryption error (check the privacy password)",
+}
+
+const (
+	oidNotInTimeWindow = "1.3.6.1.6.3.15.1.1.2.0"
+	oidUnknownEngineID = "1.3.6.1.6.3.15.1.1.4.0"
+)
+
+var errAuthentication = errors.New("message authentication failed")
+
+// saltCounter makes every encrypted message use a fresh IV.
+var saltCounter atomic.Uint64
+
+func init() {
+	var seed [8]byte
+	_, _ = rand.Read(seed[:])
+	saltCounter.Store(binary.BigEndian.Uint64(seed[:]))
+}
+
+// usmKeys are a user's keys localized to one engine.
+type usmKeys struct {
+	user    User
+	hash    func() hash.Hash
+	authKey []byte
+	privKey []byte
+}
+
+func newUSMKeys(user User, engineID []byte) (*usmKeys, error) {
+	keys := &usmKeys{user: user}
+	switch strings.ToUpper(user.AuthProtocol) {
+	case "":
+		if user.PrivProtocol != "" {
+			return nil, fmt.Errorf("privacy requires an authentication protocol")
+		}
+		return keys, nil
+	case "MD5":
+		keys.hash = md5.New
+	case "SHA", "SHA1":
+		keys.hash = sha1.New
+	default:
+		return nil, fmt.Errorf("unsupported authentication protocol %q", user.AuthProtocol)
+	}
+
+	var err error
+	if keys.authKey, err = localizeKey(keys.hash, user.AuthPassword, engineID); err != nil {
+		return nil, fmt.Errorf("invalid authentication password: %w", err)
+	}
+	switch strings.ToUpper(user.PrivProtocol) {
+	case "":
+		return keys, nil
+	case "DES", "AES", "AES128":
+	default:
+		return nil, fmt.Errorf("unsupported privacy protocol %q", user.PrivProtocol)
+	}
+	if keys.privKey, err = localizeKey(keys.hash, user.PrivPassword, engineID); err != nil {
+		return nil, fmt.Errorf("invalid privacy password: %w", err)
+	}
+	return keys, nil
+}
+
+// localizeKey derives a key from password as in RFC 3414 A.2: hash one
+// megabyte of the repeated password, then bind the result to engineID.
+func localizeKey(newHash func() hash.Hash, password string, engineID []byte) ([]byte, error) {
+	if len(password) < 8 {
+		return nil, fmt.Errorf("must be at least 8 characters")
+	}
+	h := newHash()
+	buf := make([]byte, 64)
+	for i := 0; i < 1048576; i += len(buf) {
+		for j := range buf {
+			buf[j] = password[(i+j)%len(password)]
+		}
+		h.Write(buf)
+	}
+	ku := h.Sum(nil)
+
+	h.Reset()
+	h.Write(ku)
+	h.Write(engineID)
+	h.Write(ku)
+	return h.Sum(nil), nil
+}
+
+func (k *usmKeys) sign(raw []byte) []byte {
+	mac := hmac.New(k.hash, k.authKey)
+	mac.Write(raw)
+	return mac.Sum(nil)[:authParamsLength]
+}
+
+// verify checks the HMAC of a received message.
+func (k *usmKeys) verify(m *message) error {
+	if k.hash == nil || len(m.usm.authParams) != authParamsLength {
+		return errAuthentication
+	}
+	raw := append([]byte(nil), m.raw...)
+	copy(raw[m.authOffset:], make([]byte, authParamsLength))
+	if !hmac.Equal(k.sign(raw), m.usm.authParams) {
+		return errAuthentication
+	}
+	return nil
+}
+
+func (k *usmKeys) aes() bool {
+	return strings.HasPrefix(strings.ToUpper(k.user.PrivProtocol), "AES")
+}
+
+// encrypt returns the ciphertext of a scoped PDU and the salt to send as
+// privacy parameters: CBC-DES as in RFC 3414 8.1.1, or CFB-AES-128 as in
+// RFC 3826.
+func (k *usmKeys) encrypt(plaintext []byte, boots, engineTime int) ([]byte, []byte, error) {
+	salt := make([]byte, 8)
+	counter := saltCounter.Add(1)
+
+	if k.aes() {
+		binary.BigEndian.PutUint64(salt, counter)
+		block, err := aes.NewCipher(k.privKey[:16])
+		if err != nil {
+			return nil, nil, err
+		}
+		ciphertext := make([]byte, len(plaintext))
+		cipher.NewCFBEncrypter(block, aesIV(boots, engineTime, salt)).XORKeyStream(ciphertext, plaintext)
+		return ciphertext, salt, nil
+	}
+
+	binary.BigEndian.PutUint32(salt, uint32(boots))
+	binary.BigEndian.PutUint32(salt[4:], uint32(counter))
+	block, err := des.NewCipher(k.privKey[:8])
+	if err != nil {
+		return nil, nil, err
+	}
+	if pad := len(plaintext) % des.BlockSize; pad != 0 {
+		plaintext = append(plaintext, make([]byte, des.BlockSize-pad)...)
+	}
+	ciphertext := make([]byte, len(plaintext))
+	cipher.NewCBCEncrypter(block, k.desIV(salt)).CryptBlocks(ciphertext, plaintext)
+	return ciphertext, salt, nil
+}
+
+func (k *usmKeys) decrypt(m *message) error {
+	salt := m.usm.privParams
+	if k.privKey == nil || len(salt) != 8 {
+		return fmt.Errorf("message is encrypted but no privacy key is configured")
+	}
+	plaintext := make([]byte, len(m.encrypted))
+
+	if k.aes() {
+		block, err := aes.NewCipher(k.privKey[:16])
+		if err != nil {
+			return err
+		}
+		cipher.NewCFBDecrypter(block, aesIV(m.usm.boots, m.usm.time, salt)).XORKeyStream(plaintext, m.encrypted)
+	} else {
+		if len(m.encrypted)%des.BlockSize != 0 {
+			return fmt.Errorf("encrypted PDU is not a multiple of the DES block size")
+		}
+		block, err := des.NewCipher(k.privKey[:8])
+		if err != nil {
+			return err
+		}
+		cipher.NewCBCDecrypter(block, k.desIV(salt)).CryptBlocks(plaintext, m.encrypted)
+	}
+
+	if err := m.parseScoped(plaintext); err != nil {
+		return fmt.Errorf("failed to decrypt PDU: %w", err)
+	}
+	m.encrypted = nil
+	return nil
+}
+
+func aesIV(boots, engineTime int, salt []byte) []byte {
+	iv := make([]byte, 16)
+	binary.BigEndian.PutUint32(iv, uint32(boots))
+	binary.BigEndian.PutUint32(iv[4:], uint32(engineTime))
+	copy(iv[8:], salt)
+	return iv
+}
+
+func (k *usmKeys) desIV(salt []byte) []byte {
+	iv := make([]byte, des.BlockSize)
+	for i := range iv {
+		iv[i] = k.privKey[8+i] ^ salt[i]
+	}
+	return iv
+}
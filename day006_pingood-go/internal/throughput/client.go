@@ -0,0 +1,351 @@
+package throughput
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type Direction string
+
+const (
+	Download Direction = "download"
+	Upload   Direction = "upload"
+)
+
+// Test describes one throughput measurement. Endpoint is an http(s):// URL
+// or tcp://host:port.
+type Test struct {
+	Endpoint string
+	Duration time.Duration
+	Streams  int
+	// ProbeInterval is how often connection latency is sampled, both before
+	// the transfer (idle) and during it (loaded).
+	ProbeInterval time.Duration
+	// Token is sent to servers that require one.
+	Token string
+}
+
+type Result struct {
+	Direction     Direction
+	Streams       int
+	Bytes         int64
+	Duration      time.Duration
+	Mbps          float64
+	IdleLatency   time.Duration
+	LoadedLatency time.Duration
+	IdleSamples   []time.Duration
+	LoadedSamples []time.Duration
+}
+
+// Bufferbloat is how much latency grows under load.
+func (r Result) Bufferbloat() time.Duration {
+	if r.LoadedLatency < r.IdleLatency {
+		return 0
+	}
+	return r.LoadedLatency - r.IdleLatency
+}
+
+// Run measures one direction: a short idle latency baseline, then Streams
+// parallel transfers for Duration while latency keeps being sampled. Upload
+// rates count the bytes the server reports it received, not those handed
+// to the local socket.
+func (t Test) Run(ctx context.Context, direction Direction) (Result, error) {
+	if t.Streams <= 0 {
+		t.Streams = 1
+	}
+	if t.Duration <= 0 {
+		t.Duration = 5 * time.Second
+	}
+	if t.ProbeInterval <= 0 {
+		t.ProbeInterval = 200 * time.Millisecond
+	}
+	result := Result{Direction: direction, Streams: t.Streams}
+
+	endpoint, err := url.Parse(t.Endpoint)
+	if err != nil {
+		return result, fmt.Errorf("invalid throughput endpoint: %w", err)
+	}
+	probeAddr, err := probeAddress(endpoint)
+	if err != nil {
+		return result, err
+	}
+
+	result.IdleSamples = t.probe(ctx, probeAddr, 5*t.ProbeInterval)
+	if len(result.IdleSamples) == 0 {
+		return result, fmt.Errorf("endpoint %s is not reachable", probeAddr)
+	}
+	result.IdleLatency = median(result.IdleSamples)
+
+	runCtx, cancel := context.WithTimeout(ctx, t.Duration)
+	defer cancel()
+
+	var transferred atomic.Int64
+	var wg sync.WaitGroup
+	errs := make(chan error, t.Streams)
+	start := time.Now()
+	for i := 0; i < t.Streams; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := t.transfer(ctx, runCtx, endpoint, direction, &transferred); err != nil {
+				errs <- err
+			}
+		}()
+	}
+
+	result.LoadedSamples = t.probe(runCtx, probeAddr, t.Duration)
+	wg.Wait()
+	close(errs)
+
+	result.Duration = time.Since(start)
+	result.Bytes = transferred.Load()
+	result.Mbps = float64(result.Bytes) * 8 / result.Duration.Seconds() / 1e6
+	if len(result.LoadedSamples) > 0 {
+		result.LoadedLatency = median(result.LoadedSamples)
+	}
+
+	if result.Bytes == 0 {
+		if err := <-errs; err != nil {
+			return result, err
+		}
+		return result, errors.New("no data was transferred")
+	}
+	return result, nil
+}
+
+// Metrics returns the values throughput assertions can refer to, keyed by
+// direction, e.g. download_mbps or upload_bufferbloat. Times are in ms.
+func (r Result) Metrics() map[string]float64 {
+	prefix := string(r.Direction) + "_"
+	return map[string]float64{
+		prefix + "mbps":           r.Mbps,
+		prefix + "idle_latency":   millis(r.IdleLatency),
+		prefix + "loaded_latency": millis(r.LoadedLatency),
+		prefix + "bufferbloat":    millis(r.Bufferbloat()),
+	}
+}
+
+// reportTimeout bounds the wait for the server's byte count once an upload
+// has stopped sending.
+const reportTimeout = 5 * time.Second
+
+// transfer runs one stream until stop ends. Uploads then wait, under ctx,
+// for the server to report how much it received.
+func (t Test) transfer(ctx, stop context.Context, endpoint *url.URL, direction Direction, counter *atomic.Int64) error {
+	var err error
+	switch endpoint.Scheme {
+	case "http", "https":
+		err = t.transferHTTP(ctx, stop, endpoint, direction, counter)
+	case "tcp":
+		err = t.transferTCP(ctx, stop, endpoint.Host, direction, counter)
+	default:
+		return fmt.Errorf("unsupported throughput endpoint scheme %q", endpoint.Scheme)
+	}
+
+	// Running into the deadline is how every download ends.
+	if direction == Download && stop.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+func (t Test) transferHTTP(ctx, stop context.Context, endpoint *url.URL, direction Direction, counter *atomic.Int64) error {
+	target := *endpoint
+	var req *http.Request
+	var err error
+	if direction == Download {
+		target.Path = joinPath(target.Path, DownloadPath)
+		req, err = http.NewRequestWithContext(stop, http.MethodGet, target.String(), nil)
+	} else {
+		reqCtx, cancel := context.WithTimeout(ctx, time.Until(deadlineOf(stop))+reportTimeout)
+		defer cancel()
+		target.Path = joinPath(target.Path, UploadPath)
+		req, err = http.NewRequestWithContext(reqCtx, http.MethodPost, target.String(), &countingReader{ctx: stop, counter: new(atomic.Int64)})
+	}
+	if err != nil {
+		return err
+	}
+	if t.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+t.Token)
+	}
+
+	// A fresh transport per stream so that streams do not share a connection.
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyFromEnvironment, DisableCompression: true}}
+	defer client.CloseIdleConnections()
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("endpoint returned %s", resp.Status)
+	}
+	if direction == Download {
+		_, err = io.Copy(io.Discard, &countingReader{ctx: stop, counter: counter, source: resp.Body})
+		return err
+	}
+	return readReceived(resp.Body, counter)
+}
+
+func (t Test) transferTCP(ctx, stop context.Context, addr string, direction Direction, counter *atomic.Int64) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(stop, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(deadlineOf(stop))
+
+	var hello []byte
+	if t.Token != "" {
+		hello = append([]byte(t.Token), '\n')
+	}
+	command := byte(commandDownload)
+	if direction == Upload {
+		command = commandUpload
+	}
+	if _, err := conn.Write(append(hello, command)); err != nil {
+		return err
+	}
+
+	if direction == Download {
+		_, err = io.Copy(io.Discard, &countingReader{ctx: stop, counter: counter, source: conn})
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		return err
+	}
+
+	io.Copy(conn, &countingReader{ctx: stop, counter: new(atomic.Int64)})
+	// Closing our side tells the server the upload is over; it answers
+	// with what it received.
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.CloseWrite()
+	}
+	conn.SetDeadline(time.Now().Add(reportTimeout))
+	stopRead := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stopRead()
+	return readReceived(conn, counter)
+}
+
+// readReceived adds the byte count a server reports for an upload to
+// counter.
+func readReceived(r io.Reader, counter *atomic.Int64) error {
+	line, err := bufio.NewReader(io.LimitReader(r, 32)).ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("server did not report the bytes it received: %w", err)
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(line), 10, 64)
+	if err != nil {
+		return fmt.Errorf("server reported an invalid byte count %q", strings.TrimSpace(line))
+	}
+	counter.Add(n)
+	return nil
+}
+
+// deadlineOf returns when ctx ends, or a minute from now without a
+// deadline.
+func deadlineOf(ctx context.Context) time.Time {
+	if deadline, ok := ctx.Deadline(); ok {
+		return deadline
+	}
+	return time.Now().Add(time.Minute)
+}
+
+// countingReader counts bytes read from source, or produces zeros when
+// there is no source, until ctx ends.
+type countingReader struct {
+	ctx     context.Context
+	counter *atomic.Int64
+	source  io.Reader
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, io.EOF
+	}
+	if r.source == nil {
+		if len(p) > chunkSize {
+			p = p[:chunkSize]
+		}
+		clear(p)
+		r.counter.Add(int64(len(p)))
+		return len(p), nil
+	}
+	n, err := r.source.Read(p)
+	r.counter.Add(int64(n))
+	return n, err
+}
+
+// probe measures TCP connect times to addr every ProbeInterval for the given
+// duration or until ctx ends.
+func (t Test) probe(ctx context.Context, addr string, duration time.Duration) []time.Duration {
+	var samples []time.Duration
+	end := time.Now().Add(duration)
+	ticker := time.NewTicker(t.ProbeInterval)
+	defer ticker.Stop()
+
+	for time.Now().Before(end) {
+		dialer := net.Dialer{Timeout: 2 * time.Second}
+		start := time.Now()
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err == nil {
+			samples = append(samples, time.Since(start))
+			conn.Close()
+		}
+
+		select {
+		case <-ctx.Done():
+			return samples
+		case <-ticker.C:
+		}
+	}
+	return samples
+}
+
+func probeAddress(endpoint *url.URL) (string, error) {
+	if endpoint.Host == "" {
+		return "", fmt.Errorf("throughput endpoint %q has no host", endpoint.String())
+	}
+	if endpoint.Port() != "" {
+		return endpoint.Host, nil
+	}
+	switch endpoint.Scheme {
+	case "http":
+		return net.JoinHostPort(endpoint.Hostname(), "80"), nil
+	case "https":
+		return net.JoinHostPort(endpoint.Hostname(), "443"), nil
+	default:
+		return "", fmt.Errorf("throughput endpoint %q needs a port", endpoint.String())
+	}
+}
+
+func joinPath(base, path string) string {
+	for len(base) > 0 && base[len(base)-1] == '/' {
+		base = base[:len(base)-1]
+	}
+	return base + path
+}
+
+func median(samples []time.Duration) time.Duration {
+	sorted := append([]time.Duration(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[len(sorted)/2]
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,145 @@
+// Package throughput measures download and upload rates against an HTTP or
+// raw TCP endpoint, together with the latency seen while the link is
+// loaded. Server is the matching endpoint behind `pingood throughput-server`.
+//
+// HTTP endpoints serve GET /download (a body that lasts until the server's
+// limits) and POST /upload (the body is discarded and the server answers
+// with the number of bytes it received). Raw TCP endpoints read one command
+// byte: 'D' makes the server send data until the client closes, 'U' makes
+// it discard everything the client sends until the client closes its side,
+// and then answer with the byte count on a line of its own.
+//
+// A server with a token wants it as "Authorization: Bearer <token>" over
+// HTTP and as "<token>\n" before the command byte over raw TCP.
+package throughput
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	DownloadPath = "/download"
+	UploadPath   = "/upload"
+
+	commandDownload = 'D'
+	commandUpload   = 'U'
+
+	chunkSize = 64 * 1024
+)
+
+// maxTokenLength bounds the token line a raw TCP client may send.
+const maxTokenLength = 256
+
+// Server is an endpoint for throughput tests. MaxDuration and MaxBytes cap
+// each transfer in either direction so abandoned or greedy clients do not
+// keep streams open forever. Token, when set, is required from clients.
+type Server struct {
+	MaxDuration time.Duration
+	MaxBytes    int64
+	Token       string
+
+	payload []byte
+}
+
+func NewServer() (*Server, error) {
+	payload := make([]byte, chunkSize)
+	// Random data so that compression on the path cannot inflate results.
+	if _, err := rand.Read(payload); err != nil {
+		return nil, fmt.Errorf("failed to generate payload: %w", err)
+	}
+	return &Server{MaxDuration: time.Minute, MaxBytes: 10 << 30, payload: payload}, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.Token != "" && !s.validToken(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")) {
+		http.Error(w, "invalid or missing token", http.StatusUnauthorized)
+		return
+	}
+
+	deadline := time.Now().Add(s.MaxDuration)
+	controller := http.NewResponseController(w)
+	switch {
+	case r.URL.Path == DownloadPath && r.Method == http.MethodGet:
+		controller.SetWriteDeadline(deadline)
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Cache-Control", "no-store")
+		s.send(w, deadline)
+	case r.URL.Path == UploadPath && r.Method == http.MethodPost:
+		controller.SetReadDeadline(deadline)
+		n, _ := io.Copy(io.Discard, io.LimitReader(r.Body, s.MaxBytes))
+		fmt.Fprintf(w, "%d\n", n)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) validToken(token string) bool {
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.Token)) == 1
+}
+
+// ServeTCP accepts raw TCP test connections until the listener is closed.
+func (s *Server) ServeTCP(ln net.Listener) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go s.handleTCP(conn)
+	}
+}
+
+func (s *Server) handleTCP(conn net.Conn) {
+	defer conn.Close()
+	deadline := time.Now().Add(s.MaxDuration)
+	conn.SetDeadline(deadline)
+
+	reader := bufio.NewReaderSize(conn, maxTokenLength+1)
+	if s.Token != "" {
+		line, err := reader.ReadSlice('\n')
+		if err != nil || !s.validToken(strings.TrimSuffix(string(line), "\n")) {
+			log.Printf("Warning: Throughput client %s sent no valid token", conn.RemoteAddr())
+			return
+		}
+	}
+
+	command, err := reader.ReadByte()
+	if err != nil {
+		return
+	}
+
+	switch command {
+	case commandDownload:
+		s.send(conn, deadline)
+	case commandUpload:
+		n, err := io.Copy(io.Discard, io.LimitReader(reader, s.MaxBytes))
+		if err == nil {
+			fmt.Fprintf(conn, "%d\n", n)
+		}
+	default:
+		log.Printf("Warning: Unknown throughput command %q from %s", command, conn.RemoteAddr())
+	}
+}
+
+// send writes the payload until deadline or until MaxBytes are sent.
+func (s *Server) send(w io.Writer, deadline time.Time) {
+	remaining := s.MaxBytes
+	for remaining > 0 && time.Now().Before(deadline) {
+		chunk := s.payload
+		if int64(len(chunk)) > remaining {
+			chunk = chunk[:remaining]
+		}
+		if _, err := w.Write(chunk); err != nil {
+			return
+		}
+		remaining -= int64(len(chunk))
+	}
+}
@@ -0,0 +1,174 @@
+package throughput
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func startServers(t *testing.T, configure ...func(*Server)) (httpURL, tcpURL string) {
+	t.Helper()
+
+	server, err := NewServer()
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+	server.MaxDuration = 5 * time.Second
+	for _, f := range configure {
+		f(server)
+	}
+
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go server.ServeTCP(ln)
+
+	return httpServer.URL, "tcp://" + ln.Addr().String()
+}
+
+func TestRunAgainstBundledServer(t *testing.T) {
+	httpURL, tcpURL := startServers(t)
+
+	for _, endpoint := range []string{httpURL, tcpURL} {
+		for _, direction := range []Direction{Download, Upload} {
+			name := strings.SplitN(endpoint, ":", 2)[0] + "/" + string(direction)
+			t.Run(name, func(t *testing.T) {
+				test := Test{Endpoint: endpoint, Duration: 300 * time.Millisecond, Streams: 2, ProbeInterval: 20 * time.Millisecond}
+
+				result, err := test.Run(context.Background(), direction)
+				if err != nil {
+					t.Fatalf("Run failed: %v", err)
+				}
+				if result.Bytes == 0 || result.Mbps <= 0 {
+					t.Errorf("Expected data to be transferred, got %d bytes (%.1f Mbps)", result.Bytes, result.Mbps)
+				}
+				if result.Streams != 2 || result.Direction != direction {
+					t.Errorf("Unexpected result header: %+v", result)
+				}
+				if len(result.IdleSamples) == 0 || len(result.LoadedSamples) == 0 {
+					t.Errorf("Expected idle and loaded latency samples, got %d/%d", len(result.IdleSamples), len(result.LoadedSamples))
+				}
+				if result.Duration < 300*time.Millisecond || result.Duration > 2*time.Second {
+					t.Errorf("Expected the transfer to stop after the test duration, took %v", result.Duration)
+				}
+
+				metrics := result.Metrics()
+				for _, name := range []string{"mbps", "idle_latency", "loaded_latency", "bufferbloat"} {
+					if _, ok := metrics[string(direction)+"_"+name]; !ok {
+						t.Errorf("Missing metric %s_%s", direction, name)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestServerToken(t *testing.T) {
+	httpURL, tcpURL := startServers(t, func(s *Server) { s.Token = "secret" })
+
+	for _, endpoint := range []string{httpURL, tcpURL} {
+		for _, direction := range []Direction{Download, Upload} {
+			name := strings.SplitN(endpoint, ":", 2)[0] + "/" + string(direction)
+			t.Run(name, func(t *testing.T) {
+				test := Test{Endpoint: endpoint, Duration: 200 * time.Millisecond, ProbeInterval: 20 * time.Millisecond}
+				if _, err := test.Run(context.Background(), direction); err == nil {
+					t.Error("Expected a run without the token to fail")
+				}
+				test.Token = "wrong"
+				if _, err := test.Run(context.Background(), direction); err == nil {
+					t.Error("Expected a run with the wrong token to fail")
+				}
+				test.Token = "secret"
+				if result, err := test.Run(context.Background(), direction); err != nil || result.Bytes == 0 {
+					t.Errorf("Expected a run with the token to transfer data, got %d bytes (%v)", result.Bytes, err)
+				}
+			})
+		}
+	}
+}
+
+func TestServerMaxBytes(t *testing.T) {
+	const limit = 256 * 1024
+	httpURL, tcpURL := startServers(t, func(s *Server) { s.MaxBytes = limit })
+
+	for _, endpoint := range []string{httpURL, tcpURL} {
+		test := Test{Endpoint: endpoint, Duration: 300 * time.Millisecond, Streams: 2, ProbeInterval: 20 * time.Millisecond}
+		result, err := test.Run(context.Background(), Download)
+		if err != nil {
+			t.Fatalf("Run against %s failed: %v", endpoint, err)
+		}
+		if result.Bytes == 0 || result.Bytes > 2*limit {
+			t.Errorf("Expected %s to send at most %d bytes per stream, got %d in total", endpoint, limit, result.Bytes)
+		}
+	}
+}
+
+func TestUploadCountsBytesTheServerReceived(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		if r.URL.Query().Get("report") != "no" {
+			fmt.Fprintln(w, 1000)
+		}
+	}))
+	defer server.Close()
+
+	test := Test{Endpoint: server.URL, Duration: 200 * time.Millisecond, Streams: 3, ProbeInterval: 20 * time.Millisecond}
+	result, err := test.Run(context.Background(), Upload)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if result.Bytes != 3000 {
+		t.Errorf("Expected the 3x1000 bytes the server reported, got %d", result.Bytes)
+	}
+
+	test.Endpoint = server.URL + "?report=no"
+	if _, err := test.Run(context.Background(), Upload); err == nil {
+		t.Error("Expected an error from a server that reports no byte count")
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	closedAddr := ln.Addr().String()
+	ln.Close()
+
+	tests := map[string]string{
+		"unreachable":        "tcp://" + closedAddr,
+		"unsupported scheme": "ftp://127.0.0.1:21",
+		"missing port":       "tcp://127.0.0.1",
+	}
+	for name, endpoint := range tests {
+		t.Run(name, func(t *testing.T) {
+			test := Test{Endpoint: endpoint, Duration: 100 * time.Millisecond, ProbeInterval: 10 * time.Millisecond}
+			if _, err := test.Run(context.Background(), Download); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestBufferbloat(t *testing.T) {
+	result := Result{IdleLatency: 10 * time.Millisecond, LoadedLatency: 85 * time.Millisecond}
+	if result.Bufferbloat() != 75*time.Millisecond {
+		t.Errorf("Expected 75ms, got %v", result.Bufferbloat())
+	}
+
+	result.LoadedLatency = 5 * time.Millisecond
+	if result.Bufferbloat() != 0 {
+		t.Errorf("Expected lower loaded latency to count as no bufferbloat, got %v", result.Bufferbloat())
+	}
+}
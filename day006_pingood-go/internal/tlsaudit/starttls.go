@@ -0,0 +1,101 @@
+package tlsaudit
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strings"
+)
+
+// startTLS upgrades a plain connection so the TLS handshake can follow.
+// Responses are read one byte at a time so that nothing belonging to the
+// handshake ends up in a buffer.
+func startTLS(conn io.ReadWriter, protocol string) error {
+	switch strings.ToLower(protocol) {
+	case "":
+		return nil
+	case "smtp":
+		return startTLSSMTP(conn)
+	case "imap":
+		return startTLSIMAP(conn)
+	default:
+		return fmt.Errorf("unsupported STARTTLS protocol %q", protocol)
+	}
+}
+
+func startTLSSMTP(conn io.ReadWriter) error {
+	tp := textproto.NewReader(bufio.NewReader(byteReader{conn}))
+
+	if _, _, err := tp.ReadResponse(220); err != nil {
+		return fmt.Errorf("failed to read SMTP greeting: %w", err)
+	}
+
+	if _, err := fmt.Fprintf(conn, "EHLO pingood\r\n"); err != nil {
+		return fmt.Errorf("failed to send EHLO: %w", err)
+	}
+	_, extensions, err := tp.ReadResponse(250)
+	if err != nil {
+		return fmt.Errorf("failed to read EHLO response: %w", err)
+	}
+	if !hasLine(extensions, "STARTTLS") {
+		return fmt.Errorf("server does not offer STARTTLS")
+	}
+
+	if _, err := fmt.Fprintf(conn, "STARTTLS\r\n"); err != nil {
+		return fmt.Errorf("failed to send STARTTLS: %w", err)
+	}
+	if _, _, err := tp.ReadResponse(220); err != nil {
+		return fmt.Errorf("STARTTLS refused: %w", err)
+	}
+	return nil
+}
+
+func startTLSIMAP(conn io.ReadWriter) error {
+	tp := textproto.NewReader(bufio.NewReader(byteReader{conn}))
+
+	greeting, err := tp.ReadLine()
+	if err != nil {
+		return fmt.Errorf("failed to read IMAP greeting: %w", err)
+	}
+	if !strings.HasPrefix(greeting, "* OK") {
+		return fmt.Errorf("unexpected IMAP greeting: %s", greeting)
+	}
+
+	if _, err := fmt.Fprintf(conn, "a1 STARTTLS\r\n"); err != nil {
+		return fmt.Errorf("failed to send STARTTLS: %w", err)
+	}
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return fmt.Errorf("failed to read STARTTLS response: %w", err)
+		}
+		if !strings.HasPrefix(line, "a1 ") {
+			continue
+		}
+		if !strings.HasPrefix(line, "a1 OK") {
+			return fmt.Errorf("STARTTLS refused: %s", line)
+		}
+		return nil
+	}
+}
+
+func hasLine(message, want string) bool {
+	for _, line := range strings.Split(message, "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 && strings.EqualFold(fields[0], want) {
+			return true
+		}
+	}
+	return false
+}
+
+type byteReader struct {
+	r io.Reader
+}
+
+func (b byteReader) Read(p []byte) (int, error) {
+	if len(p) > 1 {
+		p = p[:1]
+	}
+	return b.r.Read(p)
+}
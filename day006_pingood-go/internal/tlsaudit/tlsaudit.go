@@ -0,0 +1,221 @@
+// Package tlsaudit connects to TLS endpoints, including services that
+// upgrade a plain connection with STARTTLS, and checks the certificate
+// chain, expiry, hostname and protocol version against a policy.
+package tlsaudit
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// Target is one endpoint to audit. StartTLS is empty for implicit TLS
+// (HTTPS, SMTPS, LDAPS, ...) or names the protocol used to upgrade the
+// connection: "smtp" or "imap".
+type Target struct {
+	Address    string
+	ServerName string
+	StartTLS   string
+}
+
+// Auditor holds the policy endpoints are checked against. The zero value
+// uses the system roots, a 10 second timeout, TLS 1.2 as the minimum
+// version and no expiry warning.
+type Auditor struct {
+	// Roots verifies certificate chains; nil means the system pool.
+	Roots         *x509.CertPool
+	Timeout       time.Duration
+	MinVersion    uint16
+	ExpiryWarning time.Duration
+	Now           func() time.Time
+}
+
+type Result struct {
+	Target       Target
+	ServerName   string
+	Version      uint16
+	CipherSuite  uint16
+	Handshake    time.Duration
+	Leaf         *x509.Certificate
+	ChainLength  int
+	ChainError   error
+	HostError    error
+	Expiry       time.Time
+	WeakVersions []uint16
+	// Issues lists every policy violation; an empty list means the endpoint
+	// passed.
+	Issues []string
+}
+
+// DaysLeft is the number of days until the leaf certificate expires,
+// negative once it has.
+func (r Result) DaysLeft(now time.Time) float64 {
+	return r.Expiry.Sub(now).Hours() / 24
+}
+
+// Audit connects to target, records what the server negotiated and checks
+// it against the policy. Only connection failures are returned as errors;
+// policy violations end up in Result.Issues.
+func (a Auditor) Audit(ctx context.Context, target Target) (Result, error) {
+	now := time.Now()
+	if a.Now != nil {
+		now = a.Now()
+	}
+	minVersion := a.MinVersion
+	if minVersion == 0 {
+		minVersion = tls.VersionTLS12
+	}
+
+	serverName, err := serverNameOf(target)
+	if err != nil {
+		return Result{}, err
+	}
+	result := Result{Target: target, ServerName: serverName}
+
+	start := time.Now()
+	state, err := a.handshake(ctx, target, serverName, tls.VersionTLS10, tls.VersionTLS13)
+	if err != nil {
+		return result, err
+	}
+	result.Handshake = time.Since(start)
+	result.Version = state.Version
+	result.CipherSuite = state.CipherSuite
+	result.ChainLength = len(state.PeerCertificates)
+	if len(state.PeerCertificates) == 0 {
+		return result, fmt.Errorf("server sent no certificate")
+	}
+
+	leaf := state.PeerCertificates[0]
+	result.Leaf = leaf
+	result.Expiry = leaf.NotAfter
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, result.ChainError = leaf.Verify(x509.VerifyOptions{
+		Roots:         a.Roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+	})
+	result.HostError = leaf.VerifyHostname(serverName)
+
+	for _, version := range []uint16{tls.VersionTLS10, tls.VersionTLS11, tls.VersionTLS12} {
+		if version >= minVersion || version == state.Version {
+			continue
+		}
+		if _, err := a.handshake(ctx, target, serverName, version, version); err == nil {
+			result.WeakVersions = append(result.WeakVersions, version)
+		}
+	}
+
+	switch {
+	case now.After(leaf.NotAfter):
+		result.Issues = append(result.Issues, fmt.Sprintf("certificate expired on %s", leaf.NotAfter.Format("2006-01-02")))
+	case now.Before(leaf.NotBefore):
+		result.Issues = append(result.Issues, fmt.Sprintf("certificate not valid before %s", leaf.NotBefore.Format("2006-01-02")))
+	case a.ExpiryWarning > 0 && leaf.NotAfter.Sub(now) < a.ExpiryWarning:
+		result.Issues = append(result.Issues, fmt.Sprintf("certificate expires in %.0f days", result.DaysLeft(now)))
+	}
+	if result.ChainError != nil {
+		// Expiry is reported on its own above.
+		if invalid, ok := result.ChainError.(x509.CertificateInvalidError); !ok || invalid.Reason != x509.Expired {
+			result.Issues = append(result.Issues, fmt.Sprintf("untrusted chain: %v", result.ChainError))
+		}
+	}
+	if result.HostError != nil {
+		result.Issues = append(result.Issues, fmt.Sprintf("hostname mismatch: %v", result.HostError))
+	}
+	if state.Version < minVersion {
+		result.Issues = append(result.Issues, fmt.Sprintf("negotiated %s, policy requires %s", VersionName(state.Version), VersionName(minVersion)))
+	}
+	for _, version := range result.WeakVersions {
+		result.Issues = append(result.Issues, fmt.Sprintf("accepts %s", VersionName(version)))
+	}
+
+	return result, nil
+}
+
+// handshake dials target and completes a TLS handshake limited to the given
+// versions. Certificates are checked afterwards by Audit so that a bad
+// chain can still be described.
+func (a Auditor) handshake(ctx context.Context, target Target, serverName string, minVersion, maxVersion uint16) (tls.ConnectionState, error) {
+	timeout := a.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", target.Address)
+	if err != nil {
+		return tls.ConnectionState{}, fmt.Errorf("failed to connect: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if err := startTLS(conn, target.StartTLS); err != nil {
+		return tls.ConnectionState{}, err
+	}
+
+	tlsConn := tls.Client(conn, &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: true,
+		MinVersion:         minVersion,
+		MaxVersion:         maxVersion,
+	})
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return tls.ConnectionState{}, fmt.Errorf("failed to complete TLS handshake: %w", err)
+	}
+	return tlsConn.ConnectionState(), nil
+}
+
+func serverNameOf(target Target) (string, error) {
+	if target.ServerName != "" {
+		return target.ServerName, nil
+	}
+	host, _, err := net.SplitHostPort(target.Address)
+	if err != nil {
+		return "", fmt.Errorf("invalid address %q: %w", target.Address, err)
+	}
+	return host, nil
+}
+
+// ParseVersion accepts "1.0" to "1.3", optionally prefixed with "TLS".
+func ParseVersion(s string) (uint16, error) {
+	s = strings.TrimSpace(strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(s)), "TLS"))
+	switch strings.TrimPrefix(s, "V") {
+	case "1.0", "1":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unknown TLS version %q", s)
+	}
+}
+
+func VersionName(version uint16) string {
+	switch version {
+	case tls.VersionTLS10:
+		return "TLS 1.0"
+	case tls.VersionTLS11:
+		return "TLS 1.1"
+	case tls.VersionTLS12:
+		return "TLS 1.2"
+	case tls.VersionTLS13:
+		return "TLS 1.3"
+	default:
+		return fmt.Sprintf("0x%04x", version)
+	}
+}
@@ -0,0 +1,288 @@
+package tlsaudit
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+}
+
+func newTestCA(t *testing.T) testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "pingood test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(365 * 24 * time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return testCA{cert: cert, key: key, pool: pool}
+}
+
+func (ca testCA) issue(t *testing.T, validFor time.Duration, names ...string) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: names[0]},
+		DNSNames:     names,
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(validFor),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// serve accepts connections until the test ends, runs greet on the plain
+// connection and then hands it to a TLS server.
+func serve(t *testing.T, config *tls.Config, greet func(net.Conn) error) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				if greet != nil {
+					if err := greet(conn); err != nil {
+						return
+					}
+				}
+				tlsConn := tls.Server(conn, config)
+				if err := tlsConn.Handshake(); err == nil {
+					tlsConn.Close()
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestAudit(t *testing.T) {
+	ca := newTestCA(t)
+	valid := ca.issue(t, 90*24*time.Hour, "mail.example.com")
+	expiring := ca.issue(t, 10*24*time.Hour, "mail.example.com")
+
+	tests := []struct {
+		name       string
+		server     *tls.Config
+		serverName string
+		roots      *x509.CertPool
+		issues     []string
+		weak       int
+	}{
+		{
+			name:       "valid",
+			server:     &tls.Config{Certificates: []tls.Certificate{valid}},
+			serverName: "mail.example.com",
+			roots:      ca.pool,
+		},
+		{
+			name:       "hostname mismatch",
+			server:     &tls.Config{Certificates: []tls.Certificate{valid}},
+			serverName: "www.example.org",
+			roots:      ca.pool,
+			issues:     []string{"hostname mismatch"},
+		},
+		{
+			name:       "untrusted",
+			server:     &tls.Config{Certificates: []tls.Certificate{valid}},
+			serverName: "mail.example.com",
+			roots:      x509.NewCertPool(),
+			issues:     []string{"untrusted chain"},
+		},
+		{
+			name:       "expiring",
+			server:     &tls.Config{Certificates: []tls.Certificate{expiring}},
+			serverName: "mail.example.com",
+			roots:      ca.pool,
+			issues:     []string{"certificate expires in 10 days"},
+		},
+		{
+			name:       "legacy versions",
+			server:     &tls.Config{Certificates: []tls.Certificate{valid}, MinVersion: tls.VersionTLS10, MaxVersion: tls.VersionTLS12},
+			serverName: "mail.example.com",
+			roots:      ca.pool,
+			issues:     []string{"accepts TLS 1.0", "accepts TLS 1.1"},
+			weak:       2,
+		},
+		{
+			name:       "old negotiated version",
+			server:     &tls.Config{Certificates: []tls.Certificate{valid}, MinVersion: tls.VersionTLS11, MaxVersion: tls.VersionTLS11},
+			serverName: "mail.example.com",
+			roots:      ca.pool,
+			issues:     []string{"negotiated TLS 1.1, policy requires TLS 1.2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := serve(t, tt.server, nil)
+			auditor := Auditor{Roots: tt.roots, Timeout: 5 * time.Second, ExpiryWarning: 30 * 24 * time.Hour}
+
+			result, err := auditor.Audit(context.Background(), Target{Address: addr, ServerName: tt.serverName})
+			if err != nil {
+				t.Fatalf("Audit failed: %v", err)
+			}
+			if len(result.Issues) != len(tt.issues) {
+				t.Fatalf("Expected issues %q, got %q", tt.issues, result.Issues)
+			}
+			for i, want := range tt.issues {
+				if !strings.HasPrefix(result.Issues[i], want) {
+					t.Errorf("Expected issue %q, got %q", want, result.Issues[i])
+				}
+			}
+			if len(result.WeakVersions) != tt.weak {
+				t.Errorf("Expected %d weak versions, got %v", tt.weak, result.WeakVersions)
+			}
+			if result.Leaf == nil || result.Leaf.Subject.CommonName != "mail.example.com" {
+				t.Errorf("Expected leaf certificate to be recorded, got %+v", result.Leaf)
+			}
+		})
+	}
+}
+
+func TestAuditStartTLS(t *testing.T) {
+	ca := newTestCA(t)
+	config := &tls.Config{Certificates: []tls.Certificate{ca.issue(t, 90*24*time.Hour, "mail.example.com")}}
+
+	smtp := func(offer bool) func(net.Conn) error {
+		return func(conn net.Conn) error {
+			r := bufio.NewReader(conn)
+			conn.Write([]byte("220 mail.example.com ESMTP\r\n"))
+			if line, _ := r.ReadString('\n'); !strings.HasPrefix(line, "EHLO ") {
+				return net.ErrClosed
+			}
+			conn.Write([]byte("250-mail.example.com\r\n250-PIPELINING\r\n"))
+			if offer {
+				conn.Write([]byte("250-STARTTLS\r\n"))
+			}
+			conn.Write([]byte("250 8BITMIME\r\n"))
+			if line, _ := r.ReadString('\n'); line != "STARTTLS\r\n" {
+				return net.ErrClosed
+			}
+			_, err := conn.Write([]byte("220 Ready to start TLS\r\n"))
+			return err
+		}
+	}
+	imap := func(conn net.Conn) error {
+		r := bufio.NewReader(conn)
+		conn.Write([]byte("* OK [CAPABILITY IMAP4rev1 STARTTLS] ready\r\n"))
+		line, _ := r.ReadString('\n')
+		tag, _, _ := strings.Cut(line, " ")
+		_, err := conn.Write([]byte(tag + " OK Begin TLS negotiation now\r\n"))
+		return err
+	}
+
+	tests := []struct {
+		name     string
+		protocol string
+		greet    func(net.Conn) error
+		wantErr  string
+	}{
+		{name: "smtp", protocol: "smtp", greet: smtp(true)},
+		{name: "smtp without STARTTLS", protocol: "smtp", greet: smtp(false), wantErr: "does not offer STARTTLS"},
+		{name: "imap", protocol: "IMAP", greet: imap},
+		{name: "unknown protocol", protocol: "gopher", greet: imap, wantErr: "unsupported STARTTLS protocol"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := serve(t, config, tt.greet)
+			auditor := Auditor{Roots: ca.pool, Timeout: 5 * time.Second}
+
+			result, err := auditor.Audit(context.Background(), Target{Address: addr, ServerName: "mail.example.com", StartTLS: tt.protocol})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Audit failed: %v", err)
+			}
+			if len(result.Issues) != 0 {
+				t.Errorf("Expected no issues, got %q", result.Issues)
+			}
+		})
+	}
+}
+
+func TestAuditConnectionFailure(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	if _, err := (Auditor{Timeout: time.Second}).Audit(context.Background(), Target{Address: addr}); err == nil {
+		t.Error("Expected an error for a closed port")
+	}
+	if _, err := (Auditor{}).Audit(context.Background(), Target{Address: "no-port"}); err == nil {
+		t.Error("Expected an error for an address without port")
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	tests := map[string]uint16{
+		"1.2":     tls.VersionTLS12,
+		"TLS1.3":  tls.VersionTLS13,
+		"tls 1.0": tls.VersionTLS10,
+		"TLSv1.1": tls.VersionTLS11,
+	}
+	for input, want := range tests {
+		got, err := ParseVersion(input)
+		if err != nil || got != want {
+			t.Errorf("ParseVersion(%q) = %v, %v, want %v", input, got, err, want)
+		}
+	}
+	if _, err := ParseVersion("SSLv3"); err == nil {
+		t.Error("Expected an error for SSLv3")
+	}
+}
@@ -0,0 +1,255 @@
+package pingood
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/capture"
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/config"
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/report"
+)
+
+// packetCapture records the packets of every check into a pcap file of its
+// own when Runner.CaptureDir is set.
+type packetCapture struct {
+	dir   string
+	iface string
+	cfg   *config.Config
+	// err is why capturing failed to start; the remaining checks run
+	// without it.
+	err     error
+	results []checkCapture
+}
+
+type checkCapture struct {
+	check   string
+	path    string
+	rules   []capture.Rule
+	summary capture.Summary
+	dropped int
+	err     error
+}
+
+// maxCaptureDetails limits the flows and ICMP errors listed per check;
+// a traceroute alone draws dozens of "time exceeded".
+const maxCaptureDetails = 10
+
+func newPacketCapture(parent, iface string, cfg *config.Config, started time.Time) (*packetCapture, error) {
+	dir := filepath.Join(parent, "pingood-capture-"+started.Format("20060102-150405"))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create capture directory: %w", err)
+	}
+	return &packetCapture{dir: dir, iface: iface, cfg: cfg}, nil
+}
+
+// wrap makes c capture its packets. Checks that send nothing of their own,
+// such as reading the interface addresses, are left alone.
+func (p *packetCapture) wrap(c Check) Check {
+	rules, ok := captureRules(p.cfg, c.Name)
+	if !ok {
+		return c
+	}
+	return Check{c.Name, func(ctx context.Context, checked *report.Report, emit func(report.Section)) {
+		if p.err != nil {
+			c.run(ctx, checked, emit)
+			return
+		}
+		capt, err := capture.Start(p.iface, rules)
+		if err != nil {
+			p.err = err
+			c.run(ctx, checked, emit)
+			return
+		}
+		c.run(ctx, checked, emit)
+
+		packets, err := capt.Stop()
+		result := checkCapture{check: c.Name, rules: rules, summary: capture.Summarize(packets), dropped: capt.Dropped(), err: err}
+		result.path = filepath.Join(p.dir, fmt.Sprintf("%02d-%s.pcap", len(p.results)+1, c.Name))
+		if err := capture.WritePcapFile(result.path, packets); err != nil && result.err == nil {
+			result.err = err
+		}
+		p.results = append(p.results, result)
+	}}
+}
+
+func (p *packetCapture) section() report.Section {
+	section := report.Section{ID: "capture", Title: "Packet Capture", Summary: fmt.Sprintf("Interface: %s, files in %s", p.iface, p.dir)}
+	if p.err != nil && len(p.results) == 0 {
+		section.Error = fmt.Sprintf("Packet capture failed: %v", p.err)
+		return section
+	}
+	for _, r := range p.results {
+		s := r.summary
+		item := report.Item{
+			Name:   r.check,
+			Status: report.StatusInfo,
+			Summary: fmt.Sprintf("%s: %d packets in %d flows, %d retransmissions, %d resets, %d ICMP errors",
+				r.check, s.Packets, len(s.Flows), s.Retransmissions, s.Resets, len(s.ICMPErrors)),
+			Metrics: map[string]float64{
+				"packets":         float64(s.Packets),
+				"flows":           float64(len(s.Flows)),
+				"retransmissions": float64(s.Retransmissions),
+				"resets":          float64(s.Resets),
+				"icmp_errors":     float64(len(s.ICMPErrors)),
+			},
+			Attributes: map[string]string{"pcap": r.path},
+		}
+		var filters []string
+		for _, rule := range r.rules {
+			filters = append(filters, rule.String())
+		}
+		item.Attributes["filter"] = strings.Join(filters, ", ")
+		if r.err != nil {
+			item.Status = report.StatusFail
+			item.Summary += fmt.Sprintf(" (%v)", r.err)
+		}
+
+		var details []string
+		for _, f := range s.Flows {
+			if f.Retransmissions > 0 || f.Resets > 0 {
+				details = append(details, fmt.Sprintf("%s: %d retransmissions, %d resets", f, f.Retransmissions, f.Resets))
+			}
+		}
+		for _, e := range s.ICMPErrors {
+			details = append(details, e.String())
+		}
+		if len(details) > maxCaptureDetails {
+			details = append(details[:maxCaptureDetails], fmt.Sprintf("... and %d more", len(details)-maxCaptureDetails))
+		}
+		if r.dropped > 0 {
+			details = append(details, fmt.Sprintf("%d packets not kept: the capture was full", r.dropped))
+		}
+		item.Details = append(details, "Saved to "+r.path)
+		section.Items = append(section.Items, item)
+	}
+	if p.err != nil {
+		section.Summary += fmt.Sprintf("\n⚠️  Later checks were not captured: %v", p.err)
+	}
+	return section
+}
+
+// captureRules returns the 5-tuple patterns of the traffic a check sends:
+// ICMP to the ping targets, anything to the traceroute target, DNS, and
+// the ports of the HTTP, TLS and throughput endpoints, anything to the
+// middlebox echo endpoint, and the DNS lookups before them. ok is false
+// for checks that send nothing.
+func captureRules(cfg *config.Config, check string) (rules []capture.Rule, ok bool) {
+	dns := []capture.Rule{{Port: 53}}
+	switch check {
+	case "gateway":
+		return []capture.Rule{{Protocol: capture.ICMP}}, true
+	case "dhcp":
+		return []capture.Rule{{Protocol: capture.UDP, Port: 67}, {Protocol: capture.UDP, Port: 68}, {Protocol: capture.UDP, Port: 546}, {Protocol: capture.UDP, Port: 547}}, true
+	case "ping":
+		for _, target := range append(append([]string(nil), cfg.PingTargetsIPv4...), cfg.PingTargetsIPv6...) {
+			addrs := captureHosts(target)
+			if len(addrs) == 0 {
+				return []capture.Rule{{Protocol: capture.ICMP}}, true
+			}
+			for _, addr := range addrs {
+				rules = append(rules, capture.Rule{Protocol: capture.ICMP, Host: addr})
+			}
+		}
+		if len(rules) == 0 {
+			rules = []capture.Rule{{Protocol: capture.ICMP}}
+		}
+		return rules, true
+	case "traceroute":
+		addrs := captureHosts(cfg.TracerouteTarget)
+		for _, addr := range addrs {
+			rules = append(rules, capture.Rule{Host: addr})
+		}
+		if len(addrs) == 0 {
+			rules = []capture.Rule{{Protocol: capture.ICMP}, {Protocol: capture.UDP}}
+		}
+		if cfg.SNMPVersion != "" {
+			rules = append(rules, capture.Rule{Protocol: capture.UDP, Port: 161})
+		}
+		return append(rules, dns...), true
+	case "dns":
+		return dns, true
+	case "http":
+		for _, group := range httpTargetGroups(cfg) {
+			for _, target := range group.targets {
+				rules = appendEndpointRule(rules, target.URL)
+			}
+		}
+	case "http_checks":
+		for _, c := range cfg.HTTPChecks {
+			rules = appendEndpointRule(rules, c.URL)
+		}
+	case "http_protocols":
+		for _, target := range cfg.HTTPProtocolTargets {
+			rules = appendEndpointRule(rules, target.URL)
+		}
+	case "tls_audit":
+		for _, endpoint := range cfg.TLSAuditEndpoints {
+			rules = appendEndpointRule(rules, endpoint.Address)
+		}
+	case "tls_interception":
+		for _, pin := range cfg.TLSPins {
+			rules = appendEndpointRule(rules, pin.Address)
+		}
+	case "middlebox":
+		for _, addr := range captureHosts(cfg.MiddleboxEchoHost) {
+			rules = append(rules, capture.Rule{Host: addr})
+		}
+	case "throughput":
+		rules = appendEndpointRule(rules, cfg.ThroughputEndpoint)
+	case "ipv6_readiness":
+		target := cfg.IPv6ReadinessTarget
+		if target == "" {
+			target = cfg.HTTPIPv4Target
+		}
+		if target != "" {
+			rules = appendEndpointRule(rules, target)
+		}
+	default:
+		return nil, false
+	}
+	return append(rules, dns...), true
+}
+
+// appendEndpointRule adds a rule for the port of a URL or host:port, and
+// its address when it is one. Both TCP and UDP match so HTTP/3 is
+// included. Invalid targets are skipped; their checks say what is wrong.
+func appendEndpointRule(rules []capture.Rule, target string) []capture.Rule {
+	address, err := hostPort(target)
+	if err != nil {
+		return rules
+	}
+	host, port, _ := net.SplitHostPort(address)
+	n, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return rules
+	}
+	rule := capture.Rule{Port: uint16(n)}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		rule.Host = addr
+	}
+	return append(rules, rule)
+}
+
+// captureHosts resolves a target to the addresses the check will use.
+func captureHosts(target string) []netip.Addr {
+	if target == "" {
+		return nil
+	}
+	if addr, err := netip.ParseAddr(target); err == nil {
+		return []netip.Addr{addr}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", target)
+	if err != nil {
+		return nil
+	}
+	return addrs
+}
@@ -0,0 +1,1600 @@
+package pingood
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/analysis"
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/checker"
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/config"
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/dhcp"
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/hopinfo"
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/middlebox"
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/readiness"
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/report"
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/snmp"
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/throughput"
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/tlsaudit"
+)
+
+// configuredChecks returns the checks cfg enables, in the order a run
+// runs them.
+func configuredChecks(nc checker.NetChecker, cfg *config.Config, iface string) []Check {
+	checks := []Check{
+		{"ip", func(ctx context.Context, _ *report.Report, emit func(report.Section)) {
+			nc := checker.WithContext(nc, ctx)
+			emit(ipSection(nc, iface))
+		}},
+		{"wireless", func(ctx context.Context, _ *report.Report, emit func(report.Section)) {
+			nc := checker.WithContext(nc, ctx)
+			if section, ok := wirelessSection(nc, cfg, iface); ok {
+				emit(section)
+			}
+		}},
+		{"gateway", func(ctx context.Context, _ *report.Report, emit func(report.Section)) {
+			nc := checker.WithContext(nc, ctx)
+			emit(gatewaySection(nc, cfg, iface))
+		}},
+		{"dhcp", func(ctx context.Context, _ *report.Report, emit func(report.Section)) {
+			nc := checker.WithContext(nc, ctx)
+			emit(dhcpSection(nc, cfg, iface))
+		}},
+	}
+	if len(cfg.RouteExpectations) > 0 {
+		checks = append(checks, Check{"route", func(ctx context.Context, _ *report.Report, emit func(report.Section)) {
+			nc := checker.WithContext(nc, ctx)
+			emit(routeSection(nc, cfg.RouteExpectations))
+		}})
+	}
+	checks = append(checks,
+		Check{"ping", func(ctx context.Context, _ *report.Report, emit func(report.Section)) {
+			nc := checker.WithContext(nc, ctx)
+			pingAssertions, err := checker.ParseAssertions(cfg.PingAssertions)
+			for _, ping := range []struct {
+				id, title string
+				targets   []string
+				ipv6      bool
+			}{
+				{"ping_ipv4", "ICMP Ping Test (IPv4)", cfg.PingTargetsIPv4, false},
+				{"ping_ipv6", "ICMP Ping Test (IPv6)", cfg.PingTargetsIPv6, true},
+			} {
+				section := pingSection(nc, ping.id, ping.title, cfg, ping.targets, ping.ipv6, pingAssertions)
+				if err != nil {
+					section.Summary = fmt.Sprintf("⚠️  Ignoring ping assertions: %v", err)
+				}
+				emit(section)
+			}
+		}},
+		Check{"traceroute", func(ctx context.Context, _ *report.Report, emit func(report.Section)) {
+			nc := checker.WithContext(nc, ctx)
+			trace := tracerouteSection(ctx, nc, cfg)
+			emit(trace)
+			if cfg.SNMPVersion != "" {
+				emit(snmpSection(ctx, cfg, trace))
+			}
+		}},
+		Check{"dns", func(ctx context.Context, _ *report.Report, emit func(report.Section)) {
+			nc := checker.WithContext(nc, ctx)
+			emit(dnsSection(nc, "dns_a", "DNS Resolution Test (A Records)", cfg.DomainARecords, "A"))
+			emit(dnsSection(nc, "dns_aaaa", "DNS Resolution Test (AAAA Records)", cfg.DomainAAAARecords, "AAAA"))
+		}},
+		Check{"http", func(ctx context.Context, _ *report.Report, emit func(report.Section)) {
+			nc := checker.WithContext(nc, ctx)
+			for _, group := range httpTargetGroups(cfg) {
+				emit(httpSection(nc, group.id, group.title, group.targets))
+			}
+		}},
+	)
+	if len(cfg.HTTPChecks) > 0 {
+		checks = append(checks, Check{"http_checks", func(ctx context.Context, _ *report.Report, emit func(report.Section)) {
+			emit(httpChecksSection(ctx, cfg))
+		}})
+	}
+	if len(cfg.HTTPProtocolTargets) > 0 {
+		checks = append(checks, Check{"http_protocols", func(ctx context.Context, _ *report.Report, emit func(report.Section)) {
+			emit(httpProtocolsSection(ctx, cfg))
+		}})
+	}
+	if len(cfg.TLSAuditEndpoints) > 0 {
+		checks = append(checks, Check{"tls_audit", func(ctx context.Context, _ *report.Report, emit func(report.Section)) {
+			emit(tlsAuditSection(ctx, cfg))
+		}})
+	}
+	if len(cfg.TLSPins) > 0 {
+		checks = append(checks, Check{"tls_interception", func(ctx context.Context, _ *report.Report, emit func(report.Section)) {
+			emit(tlsInterceptionSection(ctx, cfg))
+		}})
+	}
+	if cfg.MiddleboxEchoHost != "" {
+		checks = append(checks, Check{"middlebox", func(ctx context.Context, _ *report.Report, emit func(report.Section)) {
+			emit(middleboxSection(ctx, cfg, iface))
+		}})
+	}
+	if cfg.ThroughputEndpoint != "" {
+		checks = append(checks, Check{"throughput", func(ctx context.Context, _ *report.Report, emit func(report.Section)) {
+			emit(throughputSection(ctx, cfg))
+		}})
+	}
+	return append(checks, Check{"ipv6_readiness", func(ctx context.Context, checked *report.Report, emit func(report.Section)) {
+		nc := checker.WithContext(nc, ctx)
+		emit(ipv6ReadinessSection(ctx, nc, cfg, checked))
+	}})
+}
+
+func diagnosisSection(cfg *config.Config, checked *report.Report) report.Section {
+	section := report.Section{ID: "diagnosis", Title: "Diagnosis"}
+
+	rules := append([]analysis.Rule(nil), analysis.DefaultRules...)
+	for _, rule := range cfg.AnalysisRules {
+		rules = append(rules, analysis.Rule{Name: rule.Name, Priority: rule.Priority, When: rule.When, Diagnosis: rule.Diagnosis, Hint: rule.Hint})
+	}
+	engine, err := analysis.NewEngine(rules...)
+	if err != nil {
+		section.Summary = fmt.Sprintf("⚠️  Ignoring ANALYSIS_RULES: %v", err)
+		engine, _ = analysis.NewEngine(analysis.DefaultRules...)
+	}
+
+	findings := engine.Analyze(checked)
+	for i, finding := range findings {
+		item := report.Item{
+			Name:    finding.Rule.Name,
+			Status:  report.StatusInfo,
+			Summary: fmt.Sprintf("%d. %s", i+1, finding.Rule.Diagnosis),
+		}
+		if finding.Rule.Hint != "" {
+			item.Details = append(item.Details, "Hint: "+finding.Rule.Hint)
+		}
+		item.Details = append(item.Details, "Because "+strings.Join(finding.Evidence, ", "))
+		section.Items = append(section.Items, item)
+	}
+
+	if len(findings) == 0 {
+		failed := false
+		for _, s := range checked.Sections {
+			failed = failed || s.Status() == report.StatusFail
+		}
+		note := "No problems found"
+		if failed {
+			note = "No known failure pattern matched; see the failed checks above"
+		}
+		if section.Summary != "" {
+			section.Summary += "\n"
+		}
+		section.Summary += note
+	}
+	return section
+}
+
+func ipSection(nc checker.NetChecker, iface string) report.Section {
+	section := report.Section{ID: "ip", Title: "IP Address Check"}
+
+	ipv4, ipv6, err := nc.GetIPAddresses(iface)
+	if err != nil {
+		section.Error = fmt.Sprintf("Failed to get IP addresses: %v", err)
+		return section
+	}
+
+	for _, addr := range []struct{ name, value string }{{"IPv4", ipv4}, {"IPv6", ipv6}} {
+		if addr.value != "" {
+			section.Items = append(section.Items, report.Item{Name: addr.name, Status: report.StatusPass, Summary: addr.value})
+		} else {
+			section.Items = append(section.Items, report.Item{Name: addr.name, Status: report.StatusFail, Summary: "Not found"})
+		}
+	}
+	return section
+}
+
+// defaultWiFiAssertions flag a link that is likely to cause trouble before
+// anything else is blamed. They are skipped when the driver does not report
+// the metric.
+var defaultWiFiAssertions = []string{"signal >= -70dBm", "retry_rate <= 10%"}
+
+// wirelessSection reports the Wi-Fi link of iface. It returns false for
+// wired interfaces and platforms without a wireless check, so the section
+// is left out instead of failing.
+func wirelessSection(nc checker.NetChecker, cfg *config.Config, iface string) (report.Section, bool) {
+	section := report.Section{ID: "wireless", Title: "Wi-Fi Link Check"}
+
+	info, err := nc.CheckWireless(iface)
+	switch {
+	case errors.Is(err, checker.ErrNotWireless), errors.Is(err, errors.ErrUnsupported):
+		return section, false
+	case err != nil:
+		section.Error = fmt.Sprintf("Failed to read Wi-Fi link: %v", err)
+		return section, true
+	}
+
+	association := report.Item{Name: "Association", Status: report.StatusFail, Summary: "Not connected"}
+	if !info.Connected {
+		section.Items = append(section.Items, association)
+		return section, true
+	}
+	association.Status = report.StatusPass
+	association.Attributes = map[string]string{"ssid": info.SSID, "bssid": info.BSSID}
+	if info.SSID != "" {
+		association.Summary = fmt.Sprintf("SSID %q, BSSID %s", info.SSID, info.BSSID)
+		if info.Frequency != 0 {
+			association.Summary += fmt.Sprintf(", channel %d (%s, %d MHz)", info.Channel, info.Band(), info.Frequency)
+		}
+	} else {
+		association.Summary = fmt.Sprintf("Associated (from %s; install iw for SSID and bitrate)", info.Source)
+	}
+	section.Items = append(section.Items, association)
+
+	link := report.Item{Name: "Link quality", Status: report.StatusFail, Metrics: info.Metrics()}
+	link.Summary = fmt.Sprintf("signal %d dBm (%s)", info.Signal, checker.SignalQuality(info.Signal))
+	if info.TxBitrate != 0 || info.RxBitrate != 0 {
+		link.Summary += fmt.Sprintf(", bitrate tx/rx %.1f/%.1f Mbit/s", info.TxBitrate, info.RxBitrate)
+	}
+	if info.TxPackets != 0 {
+		link.Summary += fmt.Sprintf(", retries %.1f%%", info.RetryRate())
+		link.Details = append(link.Details, fmt.Sprintf("tx packets %d, retries %d, failed %d", info.TxPackets, info.TxRetries, info.TxFailed))
+	}
+	if info.Noise != 0 {
+		link.Details = append(link.Details, fmt.Sprintf("noise %d dBm, SNR %d dB", info.Noise, info.Signal-info.Noise))
+	}
+
+	exprs := cfg.WiFiAssertions
+	if len(exprs) == 0 {
+		exprs = defaultWiFiAssertions
+	}
+	assertions, err := checker.ParseAssertions(exprs)
+	if err != nil {
+		section.Summary = fmt.Sprintf("⚠️  Ignoring Wi-Fi assertions: %v", err)
+	}
+	if len(cfg.WiFiAssertions) == 0 {
+		var available []checker.Assertion
+		for _, assertion := range assertions {
+			if _, ok := link.Metrics[assertion.Metric]; ok {
+				available = append(available, assertion)
+			}
+		}
+		assertions = available
+	}
+	assertionResults, passed := checker.EvaluateAssertions(assertions, link.Metrics)
+	if passed {
+		link.Status = report.StatusPass
+	}
+	for _, ar := range assertionResults {
+		link.Details = append(link.Details, assertionDetail(ar))
+	}
+	section.Items = append(section.Items, link)
+
+	return section, true
+}
+
+func gatewaySection(nc checker.NetChecker, cfg *config.Config, iface string) report.Section {
+	section := report.Section{ID: "gateway", Title: "Default Gateway Check"}
+
+	result, err := nc.CheckGateway(iface, cfg.PingCount, cfg.PingInterval)
+	if err != nil {
+		section.Error = fmt.Sprintf("Failed to get default gateway: %v", err)
+		return section
+	}
+
+	ipv6Count := 0
+	for _, ping := range result.Ping {
+		item := pingItem(ping, nil)
+		switch {
+		case ping.Target == result.IPv4Gateway:
+			item.Name = "Gateway"
+		case ipv6Count == 0:
+			item.Name = "IPv6 Gateway"
+			ipv6Count++
+		default:
+			ipv6Count++
+			item.Name = fmt.Sprintf("IPv6 Gateway %d", ipv6Count)
+		}
+		item.Summary = fmt.Sprintf("%s - %s", ping.Target, item.Summary)
+		section.Items = append(section.Items, item)
+	}
+
+	for _, neighbor := range result.Neighbors {
+		section.Items = append(section.Items, neighborItem(neighbor))
+	}
+
+	for _, ip := range sortedKeysOf(result.ARPReplies) {
+		if macs := result.ARPReplies[ip]; len(macs) > 1 {
+			section.Items = append(section.Items, report.Item{
+				Name:    "Duplicate IP " + ip,
+				Status:  report.StatusFail,
+				Summary: "answered by " + strings.Join(macs, ", "),
+			})
+		}
+	}
+
+	for _, change := range neighborChanges(cfg, iface, result.Neighbors, &result) {
+		section.Items = append(section.Items, report.Item{
+			Name:   "MAC change " + change.IP,
+			Status: report.StatusFail,
+			Summary: fmt.Sprintf("changed from %s to %s (previous MAC last seen %s)",
+				change.OldMAC, change.NewMAC, change.LastSeen.Format("2006-01-02 15:04:05")),
+		})
+	}
+
+	for _, ra := range result.RouterAdvertisements {
+		section.Items = append(section.Items, routerAdvertisementItem(ra))
+	}
+
+	for _, warning := range result.Warnings {
+		section.Items = append(section.Items, report.Item{Name: "warning", Status: report.StatusInfo, Summary: "⚠️  " + warning})
+	}
+	return section
+}
+
+func neighborItem(neighbor checker.NeighborEntry) report.Item {
+	item := report.Item{
+		Name:       "Neighbor " + neighbor.IP,
+		Status:     report.StatusPass,
+		Attributes: map[string]string{"mac": neighbor.MAC, "state": neighbor.State},
+	}
+
+	mac := neighbor.MAC
+	if mac == "" {
+		mac = "no MAC"
+	}
+	item.Summary = mac
+	if neighbor.State != "" {
+		item.Summary += " " + neighbor.State
+	}
+	if neighbor.Router {
+		item.Summary += " (router)"
+	}
+
+	switch neighbor.State {
+	case "FAILED", "INCOMPLETE":
+		item.Status = report.StatusFail
+	}
+	if neighbor.MAC == "" {
+		item.Status = report.StatusFail
+	}
+	return item
+}
+
+// neighborChanges compares the gateway MACs with the previous run and saves
+// the new state. Without a history file it keeps no state, so replays, tests
+// and library callers do not leave one behind. Problems with the history
+// file are reported as warnings.
+func neighborChanges(cfg *config.Config, iface string, neighbors []checker.NeighborEntry, result *checker.GatewayResult) []checker.MACChange {
+	path := cfg.NeighborHistoryFile
+	if path == "" {
+		return nil
+	}
+
+	history, err := checker.LoadNeighborHistory(path)
+	if err != nil {
+		result.Warnings = append(result.Warnings, err.Error())
+	}
+	changes := history.Update(iface, neighbors, time.Now())
+	if err := history.Save(path); err != nil {
+		result.Warnings = append(result.Warnings, err.Error())
+	}
+	return changes
+}
+
+func routerAdvertisementItem(ra checker.RouterAdvertisement) report.Item {
+	item := report.Item{
+		Name:       "RA " + ra.Router,
+		Status:     report.StatusInfo,
+		Attributes: map[string]string{"router": ra.Router, "mac": ra.MAC, "source": ra.Source},
+		Metrics:    map[string]float64{"lifetime_s": ra.Lifetime.Seconds()},
+	}
+
+	router := ra.Router
+	if router == "" {
+		router = "unknown router"
+	}
+	item.Summary = fmt.Sprintf("RA from %s: lifetime %s", router, formatLifetime(ra.Lifetime))
+	if ra.Preference != "" {
+		item.Summary += ", preference " + ra.Preference
+	}
+	if ra.MTU > 0 {
+		item.Summary += fmt.Sprintf(", MTU %d", ra.MTU)
+	}
+	item.Summary += fmt.Sprintf(" [%s]", ra.Source)
+
+	for _, prefix := range ra.Prefixes {
+		detail := fmt.Sprintf("prefix %s valid %s preferred %s", prefix.Prefix,
+			formatLifetime(prefix.ValidLifetime), formatLifetime(prefix.PreferredLifetime))
+		if prefix.OnLink {
+			detail += ", on-link"
+		}
+		if prefix.Autonomous {
+			detail += ", SLAAC"
+		}
+		item.Details = append(item.Details, detail)
+	}
+	return item
+}
+
+func formatLifetime(d time.Duration) string {
+	if d == checker.InfiniteLifetime {
+		return "infinite"
+	}
+	return d.String()
+}
+
+func dhcpSection(nc checker.NetChecker, cfg *config.Config, iface string) report.Section {
+	section := report.Section{ID: "dhcp", Title: "DHCP Lease Check"}
+
+	lease, err := nc.CheckDHCP(iface)
+	if err != nil {
+		section.Items = append(section.Items, report.Item{
+			Name:    "Lease",
+			Status:  report.StatusInfo,
+			Summary: fmt.Sprintf("No DHCP lease found, the interface may be configured statically (%v)", err),
+		})
+	} else {
+		section.Items = append(section.Items, leaseItem(lease))
+
+		if ipv4, _, err := nc.GetIPAddresses(iface); err == nil {
+			section.Items = append(section.Items, matchItem("Address matches interface", ipv4, []string{lease.Address},
+				fmt.Sprintf("lease has %s, interface uses %s", lease.Address, ipv4)))
+		}
+		if gateway, err := nc.GetDefaultGateway(iface); err == nil {
+			section.Items = append(section.Items, matchItem("Router matches default gateway", gateway, lease.Routers,
+				fmt.Sprintf("lease has %s, default gateway is %s", strings.Join(lease.Routers, ", "), gateway)))
+		}
+	}
+
+	if cfg.DHCPProbe != "" {
+		section.Items = append(section.Items, dhcpProbeItems(nc, cfg, iface, lease, err == nil)...)
+	}
+	return section
+}
+
+func leaseItem(lease checker.DHCPLease) report.Item {
+	item := report.Item{
+		Name:       "Lease",
+		Status:     report.StatusPass,
+		Summary:    fmt.Sprintf("%s from %s (%s)", lease.Address, lease.Server, lease.Source),
+		Metrics:    map[string]float64{"lease_time_s": lease.LeaseTime.Seconds()},
+		Attributes: map[string]string{"address": lease.Address, "server": lease.Server, "source": lease.Source},
+	}
+
+	for _, detail := range []struct{ name, value string }{
+		{"Subnet mask", lease.SubnetMask},
+		{"Routers", strings.Join(lease.Routers, ", ")},
+		{"DNS", strings.Join(lease.DNS, ", ")},
+		{"Domain", lease.Domain},
+	} {
+		if detail.value != "" {
+			item.Details = append(item.Details, fmt.Sprintf("%s: %s", detail.name, detail.value))
+		}
+	}
+	if lease.LeaseTime > 0 {
+		item.Details = append(item.Details, fmt.Sprintf("Lease time: %s", lease.LeaseTime))
+	}
+
+	if !lease.Expiry.IsZero() {
+		remaining := time.Until(lease.Expiry)
+		item.Metrics["remaining_s"] = remaining.Seconds()
+		item.Details = append(item.Details, fmt.Sprintf("Expires: %s", lease.Expiry.Local().Format("2006-01-02 15:04:05")))
+		if remaining <= 0 {
+			item.Status = report.StatusFail
+			item.Summary += " - expired"
+		}
+	}
+	return item
+}
+
+func matchItem(name, actual string, accepted []string, mismatch string) report.Item {
+	for _, value := range accepted {
+		if value == actual {
+			return report.Item{Name: name, Status: report.StatusPass, Summary: actual}
+		}
+	}
+	return report.Item{Name: name, Status: report.StatusFail, Summary: mismatch}
+}
+
+// dhcpProbeItems asks a DHCP server directly, which also catches a second
+// (rogue) server answering on the link.
+func dhcpProbeItems(nc checker.NetChecker, cfg *config.Config, iface string, lease checker.DHCPLease, haveLease bool) []report.Item {
+	item := report.Item{Name: "DHCP " + strings.ToUpper(cfg.DHCPProbe), Status: report.StatusFail}
+
+	client := &dhcp.Client{Server: cfg.DHCPServer, Interface: iface}
+	if client.Server != "" {
+		if _, _, err := net.SplitHostPort(client.Server); err != nil {
+			client.Server = net.JoinHostPort(client.Server, "67")
+		}
+	}
+
+	netIface, err := net.InterfaceByName(iface)
+	if err != nil {
+		item.Summary = fmt.Sprintf("Failed - %v", err)
+		return []report.Item{item}
+	}
+
+	var reply *dhcp.Message
+	switch strings.ToLower(cfg.DHCPProbe) {
+	case "discover":
+		reply, err = client.Discover(netIface.HardwareAddr)
+	case "inform":
+		ipv4, _, ipErr := nc.GetIPAddresses(iface)
+		if ipErr != nil {
+			err = ipErr
+			break
+		}
+		reply, err = client.Inform(netIface.HardwareAddr, net.ParseIP(ipv4))
+	default:
+		err = fmt.Errorf("unknown DHCP_PROBE %q, expected discover or inform", cfg.DHCPProbe)
+	}
+	if err != nil {
+		item.Summary = fmt.Sprintf("Failed - %v", err)
+		return []report.Item{item}
+	}
+
+	item.Status = report.StatusPass
+	item.Summary = fmt.Sprintf("%s from %v", reply.Type(), reply.ServerID())
+	if !reply.YourIP.IsUnspecified() {
+		item.Summary += fmt.Sprintf(", offered %v", reply.YourIP)
+	}
+	item.Details = append(item.Details,
+		fmt.Sprintf("Routers: %v", joinIPs(reply.Routers())),
+		fmt.Sprintf("DNS: %v", joinIPs(reply.DNS())),
+		fmt.Sprintf("Domain: %s", reply.DomainName()))
+	if lt := reply.LeaseTime(); lt > 0 {
+		item.Details = append(item.Details, fmt.Sprintf("Lease time: %s", lt))
+	}
+	items := []report.Item{item}
+
+	if haveLease && lease.Server != "" {
+		server := reply.ServerID().String()
+		items = append(items, matchItem("Probe answered by lease server", server, []string{lease.Server},
+			fmt.Sprintf("lease is from %s but %s answered, check for a second DHCP server", lease.Server, server)))
+	}
+	return items
+}
+
+func joinIPs(ips []net.IP) string {
+	var parts []string
+	for _, ip := range ips {
+		parts = append(parts, ip.String())
+	}
+	return strings.Join(parts, ", ")
+}
+
+func routeSection(nc checker.NetChecker, expectations []config.RouteExpectation) report.Section {
+	section := report.Section{ID: "routes", Title: "Route Lookup Check"}
+
+	for _, expected := range expectations {
+		item := report.Item{Name: expected.Destination, Status: report.StatusFail}
+
+		route, err := nc.LookupRoute(expected.Destination)
+		if err != nil {
+			item.Summary = fmt.Sprintf("Failed - %v", strings.TrimSpace(err.Error()))
+			section.Items = append(section.Items, item)
+			continue
+		}
+
+		item.Attributes = map[string]string{"interface": route.Interface, "gateway": route.Gateway}
+		item.Summary = fmt.Sprintf("%s dev %s", route.Address, route.Interface)
+		if route.Gateway != "" {
+			item.Summary = fmt.Sprintf("%s via %s dev %s", route.Address, route.Gateway, route.Interface)
+		}
+
+		if expected.Interface == "" && expected.Via == "" {
+			item.Status = report.StatusInfo
+		} else if mismatches := checker.MatchRoute(route, expected.Interface, expected.Via); len(mismatches) > 0 {
+			item.Details = mismatches
+		} else {
+			item.Status = report.StatusPass
+		}
+		section.Items = append(section.Items, item)
+	}
+	return section
+}
+
+func pingSection(nc checker.NetChecker, id, title string, cfg *config.Config, targets []string, ipv6 bool, assertions []checker.Assertion) report.Section {
+	section := report.Section{ID: id, Title: title}
+
+	results, err := nc.PingTest(targets, cfg.PingCount, cfg.PingInterval, ipv6)
+	if err != nil {
+		section.Error = fmt.Sprintf("Ping test failed: %v", err)
+		return section
+	}
+
+	for _, result := range results {
+		section.Items = append(section.Items, pingItem(result, assertions))
+	}
+	return section
+}
+
+func pingItem(result checker.PingResult, assertions []checker.Assertion) report.Item {
+	item := report.Item{Name: result.Target, Status: report.StatusFail}
+
+	if !result.Success {
+		item.Summary = "Failed"
+		if result.Error != nil {
+			item.Summary += fmt.Sprintf(" - %v", strings.TrimSpace(result.Error.Error()))
+		}
+		for _, icmpErr := range result.ICMPErrors {
+			item.Details = append(item.Details, fmt.Sprintf("icmp_seq=%d: %s from %s", icmpErr.Seq, icmpErr.Message, icmpErr.From))
+		}
+		return item
+	}
+
+	item.Metrics = result.Metrics()
+	for _, sample := range result.Stats.Samples {
+		item.Samples = append(item.Samples, millis(sample))
+	}
+
+	assertionResults, passed := checker.EvaluateAssertions(assertions, item.Metrics)
+	if passed {
+		item.Status = report.StatusPass
+	}
+	item.Summary = fmt.Sprintf("%.1f%% packet loss, RTT min/avg/max = %.1f/%.1f/%.1f ms",
+		result.PacketLoss, millis(result.MinRTT), millis(result.AvgRTT), millis(result.MaxRTT))
+	item.Details = append(item.Details, fmt.Sprintf("stddev/jitter = %.1f/%.1f ms, p50/p95/p99 = %.1f/%.1f/%.1f ms, duplicates %d, out-of-order %d",
+		millis(result.StdDevRTT), millis(result.Stats.Jitter),
+		millis(result.Stats.P50), millis(result.Stats.P95), millis(result.Stats.P99),
+		result.Duplicates, result.Stats.OutOfOrder))
+	for _, ar := range assertionResults {
+		item.Details = append(item.Details, assertionDetail(ar))
+	}
+	return item
+}
+
+func assertionDetail(ar checker.AssertionResult) string {
+	switch {
+	case ar.Error != nil:
+		return fmt.Sprintf("❌ %s: %v", ar.Assertion.Expr, ar.Error)
+	case ar.Passed:
+		return fmt.Sprintf("✅ %s (actual %.2f)", ar.Assertion.Expr, ar.Actual)
+	default:
+		return fmt.Sprintf("❌ %s (actual %.2f)", ar.Assertion.Expr, ar.Actual)
+	}
+}
+
+func tracerouteSection(ctx context.Context, nc checker.NetChecker, cfg *config.Config) report.Section {
+	section := report.Section{ID: "traceroute", Title: "Traceroute Test"}
+
+	result, err := nc.Traceroute(cfg.TracerouteTarget, cfg.TracerouteCount, cfg.TracerouteInterval, cfg.ViaNetworkDevices)
+	if err != nil {
+		section.Error = fmt.Sprintf("Traceroute failed: %v", strings.TrimSpace(err.Error()))
+		return section
+	}
+
+	section.Summary = fmt.Sprintf("Target: %s", result.Target)
+	if err := enrichHops(ctx, cfg, &result); err != nil {
+		section.Summary += fmt.Sprintf("\n⚠️  Hops not enriched: %v", err)
+	}
+	if path := asPath(result.Hops); path != "" {
+		section.Summary += "\nAS path: " + path
+	}
+	for _, hop := range result.Hops {
+		item := report.Item{
+			Name:       fmt.Sprintf("hop %d", hop.Number),
+			Status:     report.StatusInfo,
+			Metrics:    map[string]float64{"hop": float64(hop.Number)},
+			Attributes: map[string]string{"address": hop.Address, "name": hop.Name},
+		}
+		if hop.Address == "" {
+			item.Summary = fmt.Sprintf("%2d. *", hop.Number)
+		} else {
+			item.Summary = fmt.Sprintf("%2d. %s (%s)", hop.Number, hop.Address, hop.Name)
+		}
+		if network := hopNetwork(hop); network != "" {
+			item.Summary += " [" + network + "]"
+			item.Attributes["network"] = network
+		}
+		if len(hop.RTT) > 0 {
+			item.Summary += " -"
+			for _, rtt := range hop.RTT {
+				item.Summary += fmt.Sprintf(" %.1f ms", millis(rtt))
+				item.Samples = append(item.Samples, millis(rtt))
+			}
+		}
+		section.Items = append(section.Items, item)
+	}
+
+	for _, device := range sortedKeys(result.PassesExpected) {
+		if result.PassesExpected[device] {
+			section.Items = append(section.Items, report.Item{Name: device, Status: report.StatusPass, Summary: "Passed"})
+		} else {
+			section.Items = append(section.Items, report.Item{Name: device, Status: report.StatusFail, Summary: "Not found"})
+		}
+	}
+
+	for i, configured := range cfg.TraceroutePathRules {
+		rule := checker.PathRule{Name: configured.Name, Traverse: configured.Traverse, Avoid: configured.Avoid}
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("path rule %d", i+1)
+		}
+		item := report.Item{Name: rule.Name, Status: report.StatusFail}
+
+		var err error
+		if rule.FirstHop, rule.LastHop, err = checker.ParseHopRange(configured.Hops); err != nil {
+			item.Summary = fmt.Sprintf("Invalid rule - %v", err)
+			section.Items = append(section.Items, item)
+			continue
+		}
+		result := checker.EvaluatePathRule(rule, result.Hops)
+		if result.Passed {
+			item.Status = report.StatusPass
+		}
+		item.Summary = result.Reason
+		section.Items = append(section.Items, item)
+	}
+	return section
+}
+
+// enrichHops replaces the numeric hop names with PTR names when
+// TRACEROUTE_REVERSE_DNS is set, adds ASNs from ASN_DATABASE and marks
+// private ranges, then re-evaluates VIA_NW_DEVICES, which may name an ASN.
+func enrichHops(ctx context.Context, cfg *config.Config, result *checker.TracerouteResult) error {
+	enricher := &hopinfo.Enricher{Timeout: 2 * time.Second}
+	if cfg.TracerouteReverseDNS {
+		enricher.Resolver = net.DefaultResolver
+	}
+	var err error
+	if cfg.ASNDatabase != "" {
+		enricher.Database, err = hopinfo.LoadDatabase(cfg.ASNDatabase)
+	}
+
+	var addresses []string
+	for _, hop := range result.Hops {
+		addresses = append(addresses, hop.Addresses...)
+	}
+	infos := enricher.Lookup(ctx, addresses)
+	for i := range result.Hops {
+		hop := &result.Hops[i]
+		info, ok := infos[hop.Address]
+		if !ok {
+			continue
+		}
+		if info.Name != "" {
+			hop.Name = info.Name
+		}
+		hop.ASN, hop.Org, hop.Scope = info.ASN.Number, info.ASN.Org, info.Scope
+	}
+	result.CheckExpected(cfg.ViaNetworkDevices)
+	return err
+}
+
+func hopNetwork(hop checker.Hop) string {
+	switch {
+	case hop.Scope != "":
+		return hop.Scope
+	case hop.ASN != 0:
+		return hopinfo.ASN{Number: hop.ASN, Org: hop.Org}.String()
+	default:
+		return ""
+	}
+}
+
+// asPath lists the ASNs the route crosses, in order, without repeats.
+func asPath(hops []checker.Hop) string {
+	var path []string
+	for _, hop := range hops {
+		if hop.ASN == 0 {
+			continue
+		}
+		asn := fmt.Sprintf("AS%d", hop.ASN)
+		if len(path) == 0 || path[len(path)-1] != asn {
+			path = append(path, asn)
+		}
+	}
+	return strings.Join(path, " → ")
+}
+
+// snmpSection polls every device in VIA_NW_DEVICES, so a device that shows
+// up in the traceroute can be checked for down interfaces and errors too.
+func snmpSection(ctx context.Context, cfg *config.Config, trace report.Section) report.Section {
+	section := report.Section{ID: "snmp", Title: "Network Device Check (SNMP)"}
+
+	version, err := snmp.ParseVersion(cfg.SNMPVersion)
+	if err != nil {
+		section.Error = err.Error()
+		return section
+	}
+	port := cfg.SNMPPort
+	if port == 0 {
+		port = 161
+	}
+
+	names := make([]string, 0, len(cfg.ViaNetworkDevices))
+	for name := range cfg.ViaNetworkDevices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		address := cfg.ViaNetworkDevices[name]
+		item := report.Item{Name: name, Status: report.StatusFail, Attributes: map[string]string{"address": address}}
+		if checker.IsHopPattern(address) {
+			item.Status = report.StatusInfo
+			item.Summary = fmt.Sprintf("Not pollable - %s is a pattern, not a device address", address)
+			section.Items = append(section.Items, item)
+			continue
+		}
+
+		client := &snmp.Client{
+			Address:   net.JoinHostPort(address, strconv.Itoa(port)),
+			Version:   version,
+			Community: cfg.SNMPCommunity,
+			User: snmp.User{
+				Name:         cfg.SNMPUser,
+				AuthProtocol: cfg.SNMPAuthProtocol,
+				AuthPassword: cfg.SNMPAuthPassword,
+				PrivProtocol: cfg.SNMPPrivProtocol,
+				PrivPassword: cfg.SNMPPrivPassword,
+			},
+			Timeout: 2 * time.Second,
+			Retries: 1,
+		}
+		device, err := snmp.Poll(ctx, client)
+		client.Close()
+		if err != nil {
+			item.Summary = fmt.Sprintf("Failed - %v", err)
+		} else {
+			item.Attributes["sys_name"] = device.Name
+			down := device.InterfacesDown()
+			var errs, discards uint64
+			for _, iface := range device.Interfaces {
+				errs += iface.InErrors + iface.OutErrors
+				discards += iface.InDiscards + iface.OutDiscards
+				if iface.Down() {
+					item.Details = append(item.Details, fmt.Sprintf("%s: admin up, oper %s", iface.Name, iface.OperStatus))
+				}
+				if iface.InErrors+iface.OutErrors > 0 {
+					item.Details = append(item.Details, fmt.Sprintf("%s: %d input / %d output errors", iface.Name, iface.InErrors, iface.OutErrors))
+				}
+			}
+			item.Metrics = map[string]float64{
+				"uptime":          device.Uptime.Seconds(),
+				"interfaces":      float64(len(device.Interfaces)),
+				"interfaces_down": float64(len(down)),
+				"errors":          float64(errs),
+				"discards":        float64(discards),
+			}
+			item.Summary = fmt.Sprintf("%s, up %s, %d interface(s), %d down, %d errors",
+				device.Name, formatUptime(device.Uptime), len(device.Interfaces), len(down), errs)
+			if len(down) == 0 {
+				item.Status = report.StatusPass
+			}
+		}
+
+		if traced, ok := trace.Item(name); ok {
+			switch traced.Status {
+			case report.StatusPass:
+				item.Details = append(item.Details, "Seen in traceroute path")
+			case report.StatusFail:
+				item.Details = append(item.Details, "Not seen in traceroute path")
+			}
+		}
+		section.Items = append(section.Items, item)
+	}
+	return section
+}
+
+func formatUptime(d time.Duration) string {
+	days := int(d / (24 * time.Hour))
+	d -= time.Duration(days) * 24 * time.Hour
+	if days > 0 {
+		return fmt.Sprintf("%dd %dh", days, int(d/time.Hour))
+	}
+	return d.Truncate(time.Minute).String()
+}
+
+func dnsSection(nc checker.NetChecker, id, title string, domains []string, recordType string) report.Section {
+	section := report.Section{ID: id, Title: title}
+
+	results, err := nc.CheckDNS(domains, recordType)
+	if err != nil {
+		section.Error = fmt.Sprintf("DNS check failed: %v", err)
+		return section
+	}
+
+	for _, result := range results {
+		item := report.Item{Name: result.Domain, Status: report.StatusFail}
+		if result.Success {
+			item.Status = report.StatusPass
+			item.Summary = fmt.Sprintf("%v", result.Records)
+			item.Metrics = map[string]float64{"records": float64(len(result.Records))}
+		} else {
+			item.Summary = "Failed"
+			if result.Error != nil {
+				item.Summary += fmt.Sprintf(" - %v", strings.TrimSpace(result.Error.Error()))
+			}
+		}
+		section.Items = append(section.Items, item)
+	}
+	return section
+}
+
+type httpTargetGroup struct {
+	id, title string
+	targets   []config.HTTPTarget
+}
+
+// httpTargetGroups sorts HTTP_IPV4_TARGET, HTTP_IPV6_TARGET and
+// HTTP_TARGETS into one section per address family. A target with an
+// invalid FAMILY lands in the dual-stack section, where its check fails.
+func httpTargetGroups(cfg *config.Config) []httpTargetGroup {
+	groups := []httpTargetGroup{
+		{id: "http_ipv4", title: "HTTP Connectivity Test (IPv4)"},
+		{id: "http_ipv6", title: "HTTP Connectivity Test (IPv6)"},
+		{id: "http_dual", title: "HTTP Connectivity Test (Dual Stack)"},
+	}
+	targets := cfg.HTTPTargets
+	if cfg.HTTPIPv6Target != "" {
+		targets = append([]config.HTTPTarget{{URL: cfg.HTTPIPv6Target, Family: "ipv6"}}, targets...)
+	}
+	if cfg.HTTPIPv4Target != "" {
+		targets = append([]config.HTTPTarget{{URL: cfg.HTTPIPv4Target, Family: "ipv4"}}, targets...)
+	}
+	for _, target := range targets {
+		switch family, _ := checker.ParseFamily(target.Family); family {
+		case checker.FamilyIPv4:
+			groups[0].targets = append(groups[0].targets, target)
+		case checker.FamilyIPv6:
+			groups[1].targets = append(groups[1].targets, target)
+		default:
+			groups[2].targets = append(groups[2].targets, target)
+		}
+	}
+
+	var nonEmpty []httpTargetGroup
+	for _, group := range groups {
+		if len(group.targets) > 0 {
+			nonEmpty = append(nonEmpty, group)
+		}
+	}
+	return nonEmpty
+}
+
+// httpTransport reads the FAMILY, REDIRECTS and PROTOCOL settings shared
+// by HTTP_TARGETS and HTTP_CHECKS into check.
+func httpTransport(check *checker.HTTPCheck, family, redirects string, maxRedirects int, protocol string) error {
+	var err error
+	if check.Family, err = checker.ParseFamily(family); err != nil {
+		return err
+	}
+	if check.Protocol, err = checker.ParseProtocol(protocol); err != nil {
+		return err
+	}
+	if check.Redirects, err = checker.ParseRedirectPolicy(redirects); err != nil {
+		return err
+	}
+	check.MaxRedirects = maxRedirects
+	return nil
+}
+
+func httpSection(nc checker.NetChecker, id, title string, targets []config.HTTPTarget) report.Section {
+	section := report.Section{ID: id, Title: title}
+
+	for _, target := range targets {
+		item := report.Item{Name: target.URL, Status: report.StatusFail}
+
+		check := checker.HTTPCheck{URL: target.URL}
+		err := httpTransport(&check, target.Family, target.Redirects, target.MaxRedirects, target.Protocol)
+		var result checker.HTTPResult
+		if err == nil {
+			result, err = nc.CheckHTTP(check)
+		}
+		switch {
+		case err != nil:
+			item.Summary = fmt.Sprintf("Failed - %v", err)
+		case result.Success:
+			item.Status = report.StatusPass
+			item.Summary = fmt.Sprintf("Status %d, Time %.2fs", result.StatusCode, result.Duration.Seconds())
+		default:
+			item.Summary = fmt.Sprintf("Status %d", result.StatusCode)
+		}
+		item.Details = append(item.Details, httpConnectionDetails(result)...)
+		item.Timings = httpTimings(result, "")
+		if err == nil {
+			item.Metrics = map[string]float64{
+				"status":      float64(result.StatusCode),
+				"duration_ms": millis(result.Duration),
+				"redirects":   float64(len(result.Redirects)),
+			}
+			item.Attributes = httpConnectionAttributes(result)
+		}
+		section.Items = append(section.Items, item)
+	}
+	return section
+}
+
+// httpConnectionDetails lists the redirects a check followed and the
+// address that answered last.
+func httpConnectionDetails(result checker.HTTPResult) []string {
+	var details []string
+	for _, redirect := range result.Redirects {
+		details = append(details, fmt.Sprintf("↪ %d %s", redirect.StatusCode, redirect.Location))
+	}
+	if result.RemoteAddr != "" {
+		details = append(details, fmt.Sprintf("Connected to %s (%s)", result.RemoteAddr, addrFamily(result.RemoteAddr)))
+	}
+	return details
+}
+
+// httpTimings turns the phases of a request into timings, with prefix
+// before their names when an item makes several requests.
+func httpTimings(result checker.HTTPResult, prefix string) []report.Timing {
+	var timings []report.Timing
+	for _, phase := range result.Phases() {
+		timings = append(timings, report.Timing{
+			Name:     prefix + phase.Name,
+			Start:    result.Started.Add(phase.Start),
+			Duration: phase.End - phase.Start,
+			Detail:   phase.Detail,
+			Error:    phase.Error,
+		})
+	}
+	return timings
+}
+
+func httpConnectionAttributes(result checker.HTTPResult) map[string]string {
+	attributes := map[string]string{}
+	if result.RemoteAddr != "" {
+		attributes["remote_addr"] = result.RemoteAddr
+		attributes["family"] = addrFamily(result.RemoteAddr)
+	}
+	return attributes
+}
+
+func addrFamily(hostport string) string {
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host = hostport
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+		return "IPv6"
+	}
+	return "IPv4"
+}
+
+func httpChecksSection(ctx context.Context, cfg *config.Config) report.Section {
+	section := report.Section{ID: "http_checks", Title: "HTTP Endpoint Checks"}
+
+	for _, check := range cfg.HTTPChecks {
+		name := check.Name
+		if name == "" {
+			name = check.URL
+		}
+		item := report.Item{Name: name, Status: report.StatusFail}
+
+		assertions, err := checker.ParseAssertions(check.Assertions)
+		if err != nil {
+			item.Summary = fmt.Sprintf("Invalid assertions: %v", err)
+			section.Items = append(section.Items, item)
+			continue
+		}
+
+		headers := make(map[string]string, len(check.Headers))
+		for key, value := range check.Headers {
+			headers[key] = config.ExpandEnv(value)
+		}
+		request := checker.HTTPCheck{
+			URL:               check.URL,
+			Method:            check.Method,
+			Headers:           headers,
+			Body:              check.Body,
+			ExpectStatus:      check.ExpectStatus,
+			BodyContains:      check.BodyContains,
+			BodyMatches:       check.BodyMatches,
+			JSON:              check.JSON,
+			ResponseHeaders:   check.ResponseHeaders,
+			BasicAuthUser:     check.BasicAuthUser,
+			BasicAuthPassword: config.ExpandEnv(check.BasicAuthPassword),
+			BearerToken:       config.ExpandEnv(check.BearerToken),
+			ClientCert:        check.ClientCert,
+			ClientKey:         check.ClientKey,
+			CABundle:          check.CABundle,
+			Timeout:           time.Duration(check.Timeout * float64(time.Second)),
+		}
+		if err := httpTransport(&request, check.Family, check.Redirects, check.MaxRedirects, check.Protocol); err != nil {
+			item.Summary = fmt.Sprintf("Invalid check: %v", err)
+			section.Items = append(section.Items, item)
+			continue
+		}
+		result, err := checker.RunHTTPCheck(ctx, request)
+		item.Timings = httpTimings(result, "")
+		if err != nil {
+			item.Summary = fmt.Sprintf("Failed - %v", err)
+			section.Items = append(section.Items, item)
+			continue
+		}
+
+		item.Summary = fmt.Sprintf("Status %d, %.0f ms, %s", result.StatusCode, millis(result.Duration), result.Proto)
+		item.Metrics = map[string]float64{
+			"status":      float64(result.StatusCode),
+			"duration_ms": millis(result.Duration),
+			"body_bytes":  float64(result.BodySize),
+		}
+		item.Attributes = httpConnectionAttributes(result)
+		item.Attributes["url"] = check.URL
+		item.Details = httpConnectionDetails(result)
+		for _, c := range result.Checks {
+			mark := "✅"
+			if !c.Passed {
+				mark = "❌"
+			}
+			if c.Actual != "" {
+				item.Details = append(item.Details, fmt.Sprintf("%s %s (actual %s)", mark, c.Check, c.Actual))
+			} else {
+				item.Details = append(item.Details, fmt.Sprintf("%s %s", mark, c.Check))
+			}
+		}
+		assertionResults, passed := checker.EvaluateAssertions(assertions, item.Metrics)
+		for _, ar := range assertionResults {
+			item.Details = append(item.Details, assertionDetail(ar))
+		}
+		if result.Success && passed {
+			item.Status = report.StatusPass
+		}
+		section.Items = append(section.Items, item)
+	}
+	return section
+}
+
+var defaultHTTPProtocols = []checker.Protocol{checker.ProtocolHTTP1, checker.ProtocolHTTP2, checker.ProtocolHTTP3}
+
+// httpProtocolMetric names a protocol in metric and attribute keys.
+var httpProtocolMetric = map[checker.Protocol]string{
+	checker.ProtocolHTTP1: "http1",
+	checker.ProtocolHTTP2: "http2",
+	checker.ProtocolHTTP3: "http3",
+}
+
+// httpProtocolsSection fetches each HTTP_PROTOCOL_TARGETS URL once per
+// protocol. A target passes only if every protocol works, and HTTP/3
+// failing while HTTP over TCP works is called out since it usually means
+// UDP/443 is filtered.
+func httpProtocolsSection(ctx context.Context, cfg *config.Config) report.Section {
+	section := report.Section{ID: "http_protocols", Title: "HTTP Protocol Comparison"}
+
+	for _, target := range cfg.HTTPProtocolTargets {
+		item := report.Item{Name: target.URL, Status: report.StatusFail}
+
+		family, err := checker.ParseFamily(target.Family)
+		protocols := defaultHTTPProtocols
+		if err == nil && len(target.Protocols) > 0 {
+			protocols = nil
+			for _, name := range target.Protocols {
+				protocol, perr := checker.ParseProtocol(name)
+				if perr != nil {
+					err = perr
+					break
+				}
+				protocols = append(protocols, protocol)
+			}
+		}
+		if err != nil {
+			item.Summary = fmt.Sprintf("Invalid target: %v", err)
+			section.Items = append(section.Items, item)
+			continue
+		}
+
+		item.Metrics = map[string]float64{}
+		item.Attributes = map[string]string{}
+		var results []string
+		passed := map[checker.Protocol]bool{}
+		allPassed := true
+		for _, protocol := range protocols {
+			result, err := checker.RunHTTPCheck(ctx, checker.HTTPCheck{
+				URL:      target.URL,
+				Family:   family,
+				Protocol: protocol,
+				Timeout:  time.Duration(target.Timeout * float64(time.Second)),
+			})
+			key := httpProtocolMetric[protocol]
+			if key == "" {
+				key = "auto"
+			}
+			item.Timings = append(item.Timings, httpTimings(result, protocol.Name()+" ")...)
+			switch {
+			case err != nil:
+				results = append(results, protocol.Name()+" failed")
+				item.Details = append(item.Details, fmt.Sprintf("❌ %s: %v", protocol.Name(), strings.TrimSpace(err.Error())))
+			case !result.Success:
+				results = append(results, fmt.Sprintf("%s status %d", protocol.Name(), result.StatusCode))
+				item.Details = append(item.Details, fmt.Sprintf("❌ %s: status %d in %.0f ms", protocol.Name(), result.StatusCode, millis(result.Duration)))
+			default:
+				passed[protocol] = true
+				results = append(results, fmt.Sprintf("%s %.0f ms", protocol.Name(), millis(result.Duration)))
+				detail := fmt.Sprintf("✅ %s: status %d in %.0f ms, negotiated %s", protocol.Name(), result.StatusCode, millis(result.Duration), result.Proto)
+				if result.RemoteAddr != "" {
+					detail += fmt.Sprintf(" with %s (%s)", result.RemoteAddr, addrFamily(result.RemoteAddr))
+				}
+				item.Details = append(item.Details, detail)
+			}
+			item.Metrics[key+"_success"] = 0
+			if passed[protocol] {
+				item.Metrics[key+"_success"] = 1
+			} else {
+				allPassed = false
+			}
+			if err == nil {
+				item.Metrics[key+"_duration_ms"] = millis(result.Duration)
+				item.Attributes[key+"_proto"] = result.Proto
+			}
+		}
+
+		item.Summary = strings.Join(results, ", ")
+		_, triedHTTP3 := item.Metrics["http3_success"]
+		if triedHTTP3 && strings.HasPrefix(target.URL, "https://") && !passed[checker.ProtocolHTTP3] && (passed[checker.ProtocolHTTP1] || passed[checker.ProtocolHTTP2]) {
+			item.Summary += " - HTTP/3 fails while HTTP over TCP works (UDP/443 blocked?)"
+		}
+		if allPassed {
+			item.Status = report.StatusPass
+		}
+		section.Items = append(section.Items, item)
+	}
+	return section
+}
+
+// ipv6ReadinessSection grades IPv6 readiness from the sections already in
+// checked and a few IPv6 probes of its own.
+func ipv6ReadinessSection(ctx context.Context, nc checker.NetChecker, cfg *config.Config, checked *report.Report) report.Section {
+	section := report.Section{ID: "ipv6_readiness", Title: "IPv6 Readiness Scorecard"}
+	const timeout = 5 * time.Second
+
+	in := readiness.Input{Report: checked}
+	in.Route, in.RouteErr = nc.LookupRoute("2001:4860:4860::8888")
+
+	servers, err := checker.NameServers("/etc/resolv.conf")
+	in.ResolversErr = err
+	name := "google.com"
+	if len(cfg.DomainAAAARecords) > 0 {
+		name = cfg.DomainAAAARecords[0]
+	}
+	for _, server := range servers {
+		if server.Is6() && !server.Is4In6() {
+			in.Resolvers = append(in.Resolvers, checker.ProbeDNSServer(ctx, server, name, timeout))
+		}
+	}
+
+	in.NAT64, in.NAT64Found, in.NAT64Err = checker.DetectNAT64(ctx, net.DefaultResolver)
+
+	target := cfg.IPv6ReadinessTarget
+	if target == "" {
+		target = cfg.HTTPIPv4Target
+	}
+	if target != "" {
+		address, err := hostPort(target)
+		if err != nil {
+			section.Error = fmt.Sprintf("Invalid IPV6_READINESS_TARGET: %v", err)
+			return section
+		}
+		preference := checker.ProbeFamilyPreference(ctx, address, timeout)
+		in.Preference = &preference
+	}
+
+	card := readiness.Evaluate(in)
+	section.Items = append(section.Items, report.Item{
+		Name:       "Grade",
+		Status:     report.StatusInfo,
+		Summary:    fmt.Sprintf("Grade %s (%d/100): %s", card.Grade, card.Score, card.Verdict),
+		Metrics:    map[string]float64{"score": float64(card.Score)},
+		Attributes: map[string]string{"grade": card.Grade},
+	})
+	for _, c := range card.Criteria {
+		item := report.Item{
+			Name:    c.Name,
+			Status:  c.Status,
+			Summary: fmt.Sprintf("%d/%d - %s", c.Points, c.Max, c.Explanation),
+			Metrics: map[string]float64{"points": float64(c.Points), "max_points": float64(c.Max)},
+		}
+		if c.Status == report.StatusInfo {
+			// Info items are printed without their name.
+			item.Summary = fmt.Sprintf("%s: not assessed - %s", c.Name, c.Explanation)
+			item.Metrics = nil
+		}
+		section.Items = append(section.Items, item)
+	}
+	return section
+}
+
+// hostPort turns a URL or host:port into host:port, with the scheme's
+// default port for URLs without one.
+func hostPort(target string) (string, error) {
+	if !strings.Contains(target, "://") {
+		if _, _, err := net.SplitHostPort(target); err != nil {
+			return "", err
+		}
+		return target, nil
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", err
+	}
+	port := u.Port()
+	if port == "" {
+		port = "443"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port), nil
+}
+
+func tlsAuditSection(ctx context.Context, cfg *config.Config) report.Section {
+	section := report.Section{ID: "tls_audit", Title: "TLS Audit"}
+
+	auditor := tlsaudit.Auditor{ExpiryWarning: 30 * 24 * time.Hour}
+	if cfg.TLSExpiryWarnDays > 0 {
+		auditor.ExpiryWarning = time.Duration(cfg.TLSExpiryWarnDays) * 24 * time.Hour
+	}
+	if cfg.TLSMinVersion != "" {
+		version, err := tlsaudit.ParseVersion(cfg.TLSMinVersion)
+		if err != nil {
+			section.Error = err.Error()
+			return section
+		}
+		auditor.MinVersion = version
+	}
+	if cfg.TLSCABundle != "" {
+		pool, err := loadCertPool(cfg.TLSCABundle)
+		if err != nil {
+			section.Error = err.Error()
+			return section
+		}
+		auditor.Roots = pool
+	}
+
+	section.Table = [][]string{{"ENDPOINT", "VERSION", "CIPHER", "EXPIRES", "DAYS", "ISSUER"}}
+	now := time.Now()
+	for _, endpoint := range cfg.TLSAuditEndpoints {
+		target := tlsaudit.Target{Address: endpoint.Address, ServerName: endpoint.ServerName, StartTLS: endpoint.StartTLS}
+		name := endpoint.Address
+		if endpoint.StartTLS != "" {
+			name += " (" + strings.ToLower(endpoint.StartTLS) + ")"
+		}
+		item := report.Item{Name: name, Status: report.StatusFail}
+
+		result, err := auditor.Audit(ctx, target)
+		if err != nil {
+			item.Summary = fmt.Sprintf("Failed - %v", err)
+			section.Table = append(section.Table, []string{name, "-", "-", "-", "-", "-"})
+			section.Items = append(section.Items, item)
+			continue
+		}
+
+		section.Table = append(section.Table, []string{
+			name,
+			tlsaudit.VersionName(result.Version),
+			tls.CipherSuiteName(result.CipherSuite),
+			result.Expiry.Format("2006-01-02"),
+			fmt.Sprintf("%.0f", result.DaysLeft(now)),
+			result.Leaf.Issuer.CommonName,
+		})
+		item.Metrics = map[string]float64{
+			"days_left":    result.DaysLeft(now),
+			"handshake_ms": millis(result.Handshake),
+		}
+		if len(result.Issues) == 0 {
+			item.Status = report.StatusPass
+			item.Summary = fmt.Sprintf("Valid for %s, %s", result.ServerName, tlsaudit.VersionName(result.Version))
+		} else {
+			item.Summary = fmt.Sprintf("%d issue(s)", len(result.Issues))
+			item.Details = result.Issues
+		}
+		section.Items = append(section.Items, item)
+	}
+	return section
+}
+
+func tlsInterceptionSection(ctx context.Context, cfg *config.Config) report.Section {
+	section := report.Section{ID: "tls_interception", Title: "TLS Interception Check"}
+	for _, pin := range cfg.TLSPins {
+		item := report.Item{Name: pin.Address, Status: report.StatusFail}
+		result, err := middlebox.CheckPin(ctx, middlebox.Pin{Address: pin.Address, ServerName: pin.ServerName, Fingerprints: pin.Fingerprints}, 10*time.Second)
+		if err != nil {
+			item.Summary = fmt.Sprintf("Failed - %v", err)
+			section.Items = append(section.Items, item)
+			continue
+		}
+
+		leaf := result.Chain[0]
+		if !result.Intercepted() {
+			item.Status = report.StatusPass
+			item.Summary = fmt.Sprintf("Pinned certificate presented (%s)", result.Matched)
+			section.Items = append(section.Items, item)
+			continue
+		}
+		item.Summary = fmt.Sprintf("Certificate does not match any pinned fingerprint: issued by %q, TLS is intercepted", leaf.Issuer.CommonName)
+		item.Details = []string{
+			"Subject: " + leaf.Subject.String(),
+			"Issuer: " + leaf.Issuer.String(),
+			"SHA-256: " + middlebox.Fingerprint(leaf),
+			"Public key: " + middlebox.KeyFingerprint(leaf),
+		}
+		if result.Trusted {
+			item.Details = append(item.Details, "Trusted by the system roots: the intercepting CA is installed on this host")
+		} else {
+			item.Details = append(item.Details, "Not trusted by the system roots")
+		}
+		section.Items = append(section.Items, item)
+	}
+	return section
+}
+
+func middleboxSection(ctx context.Context, cfg *config.Config, iface string) report.Section {
+	section := report.Section{ID: "middlebox", Title: "Firewall and Middlebox Detection"}
+
+	baseline, err := middlebox.ParsePort(cfg.MiddleboxBaseline)
+	if cfg.MiddleboxBaseline == "" {
+		err = fmt.Errorf("MIDDLEBOX_BASELINE_PORT is required")
+	}
+	var ports []middlebox.Port
+	for _, s := range cfg.MiddleboxPorts {
+		port, perr := middlebox.ParsePort(s)
+		if err == nil {
+			err = perr
+		}
+		ports = append(ports, port)
+	}
+	if err != nil {
+		section.Error = err.Error()
+		return section
+	}
+
+	prober := middlebox.Prober{
+		Host:      cfg.MiddleboxEchoHost,
+		Timeout:   time.Duration(cfg.MiddleboxTimeout * float64(time.Second)),
+		Interface: iface,
+	}
+	result, err := prober.Run(ctx, baseline, ports)
+	section.Summary = fmt.Sprintf("Echo endpoint: %s, baseline %s", cfg.MiddleboxEchoHost, baseline)
+	if result.Address.IsValid() && result.Address.String() != cfg.MiddleboxEchoHost {
+		section.Summary = fmt.Sprintf("Echo endpoint: %s (%s), baseline %s", cfg.MiddleboxEchoHost, result.Address, baseline)
+	}
+	if err != nil {
+		section.Error = err.Error()
+		return section
+	}
+	if result.CaptureError != nil {
+		section.Summary += fmt.Sprintf("\n⚠️  TCP handshakes not compared: %v", result.CaptureError)
+	}
+
+	section.Table = [][]string{{"PORT", "VERDICT", "RTT", "TTL", "MSS", "OPTIONS", "CLIENT"}}
+	for i, r := range append([]middlebox.Result{result.Baseline}, result.Results...) {
+		row := []string{r.Port.String(), string(r.Verdict), "-", "-", "-", "-", "-"}
+		if i == 0 {
+			row[1] = "baseline"
+		}
+		if r.Verdict == middlebox.Open || r.Verdict == middlebox.Proxied {
+			row[2] = fmt.Sprintf("%.1f ms", millis(r.RTT))
+		}
+		if h := r.Handshake; h != nil {
+			row[3] = strconv.Itoa(h.TTL)
+			if h.SYN() {
+				row[4], row[5] = strconv.Itoa(h.MSS), h.Options
+			}
+		}
+		if r.Reply != nil {
+			row[6] = r.Reply.Client
+		}
+		section.Table = append(section.Table, row)
+	}
+
+	for _, r := range result.Results {
+		item := report.Item{
+			Name:       r.Port.String(),
+			Status:     report.StatusFail,
+			Attributes: map[string]string{"verdict": string(r.Verdict)},
+		}
+		switch r.Verdict {
+		case middlebox.Open:
+			item.Status = report.StatusPass
+			item.Summary = fmt.Sprintf("%s: open, answered by the echo server like the baseline", r.Port)
+		case middlebox.Proxied:
+			item.Summary = fmt.Sprintf("%s: proxied, the connection is terminated or rewritten on the way", r.Port)
+		case middlebox.Intercepted:
+			item.Summary = fmt.Sprintf("%s: intercepted, something other than the echo server answers", r.Port)
+		case middlebox.Blocked:
+			item.Summary = fmt.Sprintf("%s: blocked - %v", r.Port, r.Err)
+		case middlebox.Filtered:
+			item.Summary = fmt.Sprintf("%s: filtered, no answer", r.Port)
+		}
+		if r.Verdict == middlebox.Open || r.Verdict == middlebox.Proxied {
+			item.Metrics = map[string]float64{"rtt_ms": millis(r.RTT)}
+		}
+		item.Details = r.Issues
+		section.Items = append(section.Items, item)
+	}
+	return section
+}
+
+func loadCertPool(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+	return pool, nil
+}
+
+func throughputSection(ctx context.Context, cfg *config.Config) report.Section {
+	section := report.Section{ID: "throughput", Title: "Throughput Test", Summary: fmt.Sprintf("Endpoint: %s", cfg.ThroughputEndpoint)}
+
+	assertions, err := checker.ParseAssertions(cfg.ThroughputAsserts)
+	for _, assertion := range assertions {
+		if err == nil && !strings.HasPrefix(assertion.Metric, "download_") && !strings.HasPrefix(assertion.Metric, "upload_") {
+			err = fmt.Errorf("%q: metric must start with download_ or upload_", assertion.Expr)
+		}
+	}
+	if err != nil {
+		section.Summary += fmt.Sprintf("\n⚠️  Ignoring throughput assertions: %v", err)
+		assertions = nil
+	}
+
+	test := throughput.Test{
+		Endpoint: cfg.ThroughputEndpoint,
+		Duration: time.Duration(cfg.ThroughputDuration * float64(time.Second)),
+		Streams:  cfg.ThroughputStreams,
+		Token:    config.ExpandEnv(cfg.ThroughputToken),
+	}
+	for _, direction := range []throughput.Direction{throughput.Download, throughput.Upload} {
+		name := strings.ToUpper(string(direction[:1])) + string(direction[1:])
+		item := report.Item{Name: name, Status: report.StatusFail}
+
+		result, err := test.Run(ctx, direction)
+		if err != nil {
+			item.Summary = fmt.Sprintf("Failed - %v", err)
+			section.Items = append(section.Items, item)
+			continue
+		}
+
+		item.Metrics = result.Metrics()
+		for _, sample := range result.LoadedSamples {
+			item.Samples = append(item.Samples, millis(sample))
+		}
+		item.Summary = fmt.Sprintf("%.1f Mbps (%d streams, %.1fs)", result.Mbps, result.Streams, result.Duration.Seconds())
+		item.Details = append(item.Details, fmt.Sprintf("latency idle/loaded = %.1f/%.1f ms, bufferbloat +%.1f ms",
+			millis(result.IdleLatency), millis(result.LoadedLatency), millis(result.Bufferbloat())))
+
+		var own []checker.Assertion
+		for _, assertion := range assertions {
+			if strings.HasPrefix(assertion.Metric, string(direction)+"_") {
+				own = append(own, assertion)
+			}
+		}
+		assertionResults, passed := checker.EvaluateAssertions(own, item.Metrics)
+		if passed {
+			item.Status = report.StatusPass
+		}
+		for _, ar := range assertionResults {
+			item.Details = append(item.Details, assertionDetail(ar))
+		}
+		section.Items = append(section.Items, item)
+	}
+	return section
+}
+
+func sortedKeysOf(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,74 @@
+package pingood
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/checker"
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/config"
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/report"
+)
+
+func TestSNMPSectionSkipsPatterns(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.SNMPVersion = "2c"
+	cfg.SNMPCommunity = "public"
+	cfg.ViaNetworkDevices = map[string]string{
+		"router": "127.0.0.1",
+		"isp":    "203.0.113.0/24",
+		"edge":   "*.isp.example.net",
+		"google": "AS15169",
+	}
+	// Nothing answers SNMP here; the literal address fails once ctx ends.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	section := snmpSection(ctx, cfg, report.Section{})
+	want := map[string]report.Status{
+		"router": report.StatusFail,
+		"isp":    report.StatusInfo,
+		"edge":   report.StatusInfo,
+		"google": report.StatusInfo,
+	}
+	if len(section.Items) != len(want) {
+		t.Fatalf("Expected %d items, got %+v", len(want), section.Items)
+	}
+	for _, item := range section.Items {
+		if item.Status != want[item.Name] {
+			t.Errorf("%s: expected %s, got %s (%s)", item.Name, want[item.Name], item.Status, item.Summary)
+		}
+	}
+}
+
+func TestNeighborChangesNeedHistoryFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(home, ".cache"))
+
+	cfg := config.DefaultConfig()
+	before := []checker.NeighborEntry{{IP: "192.0.2.1", MAC: "00:00:5e:00:53:01"}}
+	after := []checker.NeighborEntry{{IP: "192.0.2.1", MAC: "00:00:5e:00:53:02"}}
+	for _, neighbors := range [][]checker.NeighborEntry{before, after} {
+		var result checker.GatewayResult
+		if changes := neighborChanges(cfg, "eth0", neighbors, &result); len(changes) != 0 {
+			t.Errorf("Expected no changes without a history file, got %+v", changes)
+		}
+	}
+	if entries, _ := os.ReadDir(home); len(entries) != 0 {
+		t.Errorf("Expected nothing written without a history file, got %v", entries)
+	}
+
+	cfg.NeighborHistoryFile = filepath.Join(t.TempDir(), "neighbors.json")
+	var result checker.GatewayResult
+	neighborChanges(cfg, "eth0", before, &result)
+	changes := neighborChanges(cfg, "eth0", after, &result)
+	if len(changes) != 1 || changes[0].IP != "192.0.2.1" {
+		t.Errorf("Expected the MAC change to be reported, got %+v", changes)
+	}
+	if len(result.Warnings) != 0 {
+		t.Errorf("Expected no warnings, got %v", result.Warnings)
+	}
+}
@@ -0,0 +1,350 @@
+// Package pingood runs pingood's network diagnostics from other Go
+// programs. A Runner takes a configuration, runs the checks it enables, or
+// a selection of them, and hands each section of results to a callback, or
+// to Run.Next, as soon as it is ready, the way the pingood command prints
+// them:
+//
+//	cfg, err := pingood.LoadConfig("conf.yaml")
+//	if err != nil {
+//		return err
+//	}
+//	runner := &pingood.Runner{Config: cfg, Only: []string{"gateway", "dns"}}
+//	rep, err := runner.Run(ctx, func(section pingood.Section) {
+//		fmt.Println(section.Title, section.Status())
+//	})
+//
+// The configuration and result types are those of the pingood command, so
+// a service can load the same conf.yaml and produce the same JSON.
+package pingood
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/checker"
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/config"
+	"github.com/junenu/solo-hackathon/day006_pingood-go/internal/report"
+)
+
+// The configuration, as read from conf.yaml.
+type (
+	Config             = config.Config
+	RouteExpectation   = config.RouteExpectation
+	PathRule           = config.PathRule
+	HTTPTarget         = config.HTTPTarget
+	HTTPProtocolTarget = config.HTTPProtocolTarget
+	HTTPCheck          = config.HTTPCheck
+	TLSEndpoint        = config.TLSEndpoint
+	TLSPin             = config.TLSPin
+	AnalysisRule       = config.AnalysisRule
+	ScheduledCheck     = config.ScheduledCheck
+)
+
+// LoadConfig reads a conf.yaml file.
+func LoadConfig(path string) (*Config, error) {
+	return config.LoadConfig(path)
+}
+
+// DefaultConfig is the configuration pingood uses without a conf.yaml.
+func DefaultConfig() *Config {
+	return config.DefaultConfig()
+}
+
+// The results. Each check emits one or more sections, such as
+// "ping_ipv4" and "ping_ipv6" for the ping check; a Report collects the
+// sections of a run.
+type (
+	Report  = report.Report
+	Section = report.Section
+	Item    = report.Item
+	Timing  = report.Timing
+	Status  = report.Status
+	Command = report.Command
+)
+
+const (
+	StatusPass = report.StatusPass
+	StatusFail = report.StatusFail
+	StatusInfo = report.StatusInfo
+)
+
+// CommandRunner executes the external commands the checks use, such as
+// ping, traceroute and dig, so that they can be recorded, replayed or run
+// somewhere else.
+type CommandRunner = checker.CommandRunner
+
+// SetLogger sends the checks' log to l. Nothing is logged until it is
+// called. It is the default for Runners without a Logger of their own.
+func SetLogger(l *slog.Logger) {
+	checker.SetLogger(l)
+}
+
+// DefaultInterface is the interface checked when Runner.Interface is
+// empty.
+func DefaultInterface() string {
+	switch runtime.GOOS {
+	case "darwin":
+		return "en0"
+	case "linux":
+		return "eth0"
+	default:
+		return "eth0"
+	}
+}
+
+// Runner runs the checks a configuration enables.
+type Runner struct {
+	// Config is the configuration; nil means DefaultConfig.
+	Config *Config
+	// Interface is the network interface to check; empty means
+	// DefaultInterface.
+	Interface string
+	// Only limits a run to the named checks, such as "dns" or
+	// "traceroute"; empty runs every configured check. See Checks for the
+	// names.
+	Only []string
+	// Commands runs the external commands; nil executes them directly.
+	Commands CommandRunner
+	// CaptureDir, when set, captures the packets of every check into a
+	// pcap file per check in a new directory under it and adds a "capture"
+	// section. Capturing needs Linux and root or CAP_NET_RAW.
+	CaptureDir string
+	// Logger receives the log of the checks; nil means the logger set with
+	// SetLogger.
+	Logger *slog.Logger
+}
+
+// Check is one step of a run, such as "ping" or "http". Schedulers can run
+// checks on their own cadence.
+type Check struct {
+	Name string
+	run  func(ctx context.Context, checked *report.Report, emit func(report.Section))
+}
+
+// Run runs the check and hands each section it produces to emit. checked
+// holds the sections of earlier checks, which some build on, such as the
+// IPv6 readiness scorecard; it may be nil. Cancelling ctx stops the
+// commands and requests of the check, which then reports them as failed.
+func (c Check) Run(ctx context.Context, checked *Report, emit func(Section)) {
+	if checked == nil {
+		checked = &Report{}
+	}
+	c.run(ctx, checked, emit)
+}
+
+func (r *Runner) config() *Config {
+	if r.Config == nil {
+		return DefaultConfig()
+	}
+	return r.Config
+}
+
+func (r *Runner) iface() string {
+	if r.Interface == "" {
+		return DefaultInterface()
+	}
+	return r.Interface
+}
+
+// Checks returns the checks a run runs, in order: the configured ones,
+// limited to Only when it is set. It fails when Only names a check that
+// does not exist or is not configured.
+func (r *Runner) Checks() ([]Check, error) {
+	nc := checker.New()
+	if r.Commands != nil {
+		nc = checker.NewForPlatform(runtime.GOOS, r.Commands)
+	}
+	checks := configuredChecks(nc, r.config(), r.iface())
+	if r.Logger != nil {
+		for i, c := range checks {
+			run := c.run
+			checks[i].run = func(ctx context.Context, checked *report.Report, emit func(report.Section)) {
+				run(checker.ContextWithLogger(ctx, r.Logger), checked, emit)
+			}
+		}
+	}
+	if len(r.Only) == 0 {
+		return checks, nil
+	}
+
+	var names []string
+	for _, c := range checks {
+		names = append(names, c.Name)
+	}
+	var selected []Check
+	for _, name := range r.Only {
+		found := false
+		for _, c := range checks {
+			if c.Name == name {
+				selected, found = append(selected, c), true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown or unconfigured check %q (available: %s)", name, strings.Join(names, ", "))
+		}
+	}
+	return selected, nil
+}
+
+func (r *Runner) newReport() Report {
+	return Report{
+		Platform:  fmt.Sprintf("%s/%s", runtime.GOOS, runtime.GOARCH),
+		Interface: r.iface(),
+		StartedAt: time.Now(),
+	}
+}
+
+// Run runs the checks in order, hands each section to emit as soon as it
+// is ready and returns the report of the run. emit may be nil. The next
+// check starts only once emit has returned, so whatever emit files away
+// with a section, such as the commands run so far, belongs to it. The
+// last section is always the "diagnosis", the likely root causes of what
+// failed. Cancelling ctx stops the check that is running and skips the
+// rest.
+func (r *Runner) Run(ctx context.Context, emit func(Section)) (*Report, error) {
+	p, err := r.plan()
+	if err != nil {
+		return nil, err
+	}
+	return p.run(ctx, func(section Section) {
+		if emit != nil {
+			emit(section)
+		}
+	}), nil
+}
+
+// Start begins a run in the background, for callers that prefer pulling
+// sections with Next to a callback. Errors in the configuration are
+// returned at once; failing checks are results, not errors. The run holds
+// a goroutine until Wait returns or ctx is cancelled, so a caller that
+// stops calling Next early must do either.
+func (r *Runner) Start(ctx context.Context) (*Run, error) {
+	p, err := r.plan()
+	if err != nil {
+		return nil, err
+	}
+	run := &Run{
+		header:   p.header,
+		sections: make(chan Section),
+		handled:  make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go func() {
+		defer close(run.done)
+		defer close(run.sections)
+		// Once ctx is done, sections nobody takes are dropped so that the
+		// run can finish without a caller.
+		run.report = p.run(ctx, func(section Section) {
+			select {
+			case run.sections <- section:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case <-run.handled:
+			case <-ctx.Done():
+			}
+		})
+	}()
+	return run, nil
+}
+
+// plan is a run ready to go: the checks to run and, with CaptureDir, the
+// capture they are wrapped in.
+type plan struct {
+	cfg    *Config
+	header Report
+	checks []Check
+	pc     *packetCapture
+}
+
+func (r *Runner) plan() (*plan, error) {
+	checks, err := r.Checks()
+	if err != nil {
+		return nil, err
+	}
+	p := &plan{cfg: r.config(), header: r.newReport(), checks: checks}
+	if r.CaptureDir != "" {
+		if p.pc, err = newPacketCapture(r.CaptureDir, r.iface(), p.cfg, p.header.StartedAt); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// run runs the checks one after the other. emit is called from the check
+// that produced the section and returns before the next check starts.
+func (p *plan) run(ctx context.Context, emit func(Section)) *Report {
+	checked := &Report{}
+	add := func(section Section) {
+		checked.Add(section)
+		emit(section)
+	}
+	for _, c := range p.checks {
+		if ctx.Err() != nil {
+			break
+		}
+		if p.pc != nil {
+			c = p.pc.wrap(c)
+		}
+		c.run(ctx, checked, add)
+	}
+	if p.pc != nil {
+		add(p.pc.section())
+	}
+	add(diagnosisSection(p.cfg, checked))
+	rep := p.header
+	rep.Sections = checked.Sections
+	return &rep
+}
+
+// Run is a run started by Runner.Start.
+type Run struct {
+	header   Report
+	report   *Report
+	sections chan Section
+	handled  chan struct{}
+	pending  bool
+	done     chan struct{}
+}
+
+// Next waits for the next section of the run; ok is false once the run is
+// over. Calling Next again tells the run the previous section has been
+// handled: a check starts only after every section before it has been, so
+// whatever the caller files away with a section, such as the commands run
+// so far, belongs to it. Next and Wait are meant for one goroutine.
+func (run *Run) Next() (section Section, ok bool) {
+	if run.pending {
+		select {
+		case run.handled <- struct{}{}:
+		case <-run.done:
+		}
+	}
+	section, ok = <-run.sections
+	run.pending = ok
+	return section, ok
+}
+
+// Header is the report of the run without its sections, available at
+// once, such as for printing a header before the results arrive.
+func (run *Run) Header() Report {
+	return run.header
+}
+
+// Wait waits for the run to finish and returns its report. Sections not
+// taken with Next yet are skipped. Waiting releases the run, so a caller
+// done with it before the last section calls Wait, or cancels ctx.
+func (run *Run) Wait() *Report {
+	for {
+		if _, ok := run.Next(); !ok {
+			break
+		}
+	}
+	<-run.done
+	return run.report
+}
@@ -0,0 +1,227 @@
+package pingood_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/junenu/solo-hackathon/day006_pingood-go/pingood"
+)
+
+// fakeCommands answers dig with a fixed address and fails everything else.
+type fakeCommands struct{}
+
+func (fakeCommands) Run(name string, args ...string) (string, string, error) {
+	if name == "dig" {
+		return "192.0.2.1\n", "", nil
+	}
+	return "", "not found", errors.New("exit status 1")
+}
+
+// countingCommands counts the commands run so far.
+type countingCommands struct {
+	fakeCommands
+	n atomic.Int32
+}
+
+func (c *countingCommands) Run(name string, args ...string) (string, string, error) {
+	c.n.Add(1)
+	return c.fakeCommands.Run(name, args...)
+}
+
+// blockingCommands runs every command until it is cancelled.
+type blockingCommands struct{}
+
+func (blockingCommands) Run(name string, args ...string) (string, string, error) {
+	select {}
+}
+
+func (blockingCommands) RunContext(ctx context.Context, name string, args ...string) (string, string, error) {
+	<-ctx.Done()
+	return "", "", ctx.Err()
+}
+
+func newRunner(only ...string) *pingood.Runner {
+	cfg := pingood.DefaultConfig()
+	cfg.DomainARecords = []string{"example.com"}
+	cfg.DomainAAAARecords = []string{"example.com"}
+	return &pingood.Runner{Config: cfg, Interface: "eth0", Only: only, Commands: fakeCommands{}}
+}
+
+func TestRunnerRun(t *testing.T) {
+	var ids []string
+	rep, err := newRunner("dns").Run(context.Background(), func(section pingood.Section) {
+		ids = append(ids, section.ID)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(ids, ","); got != "dns_a,dns_aaaa,diagnosis" {
+		t.Errorf("Expected dns_a,dns_aaaa,diagnosis, got %s", got)
+	}
+	if len(rep.Sections) != 3 || rep.Interface != "eth0" || rep.StartedAt.IsZero() {
+		t.Fatalf("Unexpected report %+v", rep)
+	}
+	section, _ := rep.Section("dns_a")
+	if section.Status() != pingood.StatusPass || !strings.Contains(section.Items[0].Summary, "192.0.2.1") {
+		t.Errorf("Unexpected section %+v", section)
+	}
+}
+
+func TestRunnerStart(t *testing.T) {
+	run, err := newRunner("ip", "dns").Start(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header := run.Header(); header.Interface != "eth0" || len(header.Sections) != 0 {
+		t.Errorf("Unexpected header %+v", header)
+	}
+	var ids []string
+	for section, ok := run.Next(); ok; section, ok = run.Next() {
+		ids = append(ids, section.ID)
+	}
+	if got := strings.Join(ids, ","); got != "ip,dns_a,dns_aaaa,diagnosis" {
+		t.Errorf("Expected ip,dns_a,dns_aaaa,diagnosis, got %s", got)
+	}
+	if rep := run.Wait(); len(rep.Sections) != len(ids) {
+		t.Errorf("Expected %d sections in the report, got %d", len(ids), len(rep.Sections))
+	}
+}
+
+func TestRunnerStartWaitsForNext(t *testing.T) {
+	commands := &countingCommands{}
+	runner := newRunner("ip", "dns")
+	runner.Commands = commands
+	run, err := runner.Start(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	section, ok := run.Next()
+	if !ok || section.ID != "ip" {
+		t.Fatalf("Expected the ip section, got %+v", section)
+	}
+	n := commands.n.Load()
+	time.Sleep(50 * time.Millisecond)
+	if got := commands.n.Load(); got != n {
+		t.Errorf("Expected the dns check to wait for Next, but %d commands ran", got-n)
+	}
+	if rep := run.Wait(); len(rep.Sections) != 4 || commands.n.Load() == n {
+		t.Errorf("Expected Wait to finish the run, got %d sections", len(rep.Sections))
+	}
+}
+
+func TestRunnerStartReleasedByCancel(t *testing.T) {
+	before := runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(context.Background())
+	run, err := newRunner("ip", "dns").Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := run.Next(); !ok {
+		t.Fatal("Expected a section")
+	}
+	// The caller walks away from the run.
+	cancel()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the run to finish after cancel, %d goroutines left over", runtime.NumGoroutine()-before)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, ok := run.Next(); ok {
+		t.Error("Expected no sections after the run was released")
+	}
+}
+
+func TestRunnerLogger(t *testing.T) {
+	var buf bytes.Buffer
+	runner := newRunner("dns")
+	runner.Logger = slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	if _, err := runner.Run(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "dns lookup finished") || !strings.Contains(buf.String(), "command=\"dig ") {
+		t.Errorf("Expected the checks to log to the runner's logger, got %q", buf.String())
+	}
+}
+
+func TestRunnerRunEmitsBeforeNextCheck(t *testing.T) {
+	commands := &countingCommands{}
+	runner := newRunner("ip", "dns")
+	runner.Commands = commands
+	counts := map[string]int32{}
+	_, err := runner.Run(context.Background(), func(section pingood.Section) {
+		counts[section.ID] = commands.n.Load()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts["ip"] == 0 || counts["dns_a"] <= counts["ip"] {
+		t.Errorf("Expected the dns commands to run after the ip section was emitted, got %v", counts)
+	}
+}
+
+func TestRunnerCancelStopsCheck(t *testing.T) {
+	runner := newRunner("dns", "ip")
+	runner.Commands = blockingCommands{}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	done := make(chan *pingood.Report)
+	go func() {
+		rep, _ := runner.Run(ctx, nil)
+		done <- rep
+	}()
+	select {
+	case rep := <-done:
+		if _, ok := rep.Section("ip"); ok {
+			t.Error("Expected the ip check to be skipped")
+		}
+		if section, _ := rep.Section("dns_a"); section.Status() != pingood.StatusFail {
+			t.Errorf("Expected the cancelled lookup to fail, got %+v", section)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected cancelling ctx to stop the running check")
+	}
+}
+
+func TestRunnerCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rep, err := newRunner().Run(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rep.Sections) != 1 || rep.Sections[0].ID != "diagnosis" {
+		t.Errorf("Expected only the diagnosis, got %+v", rep.Sections)
+	}
+}
+
+func TestRunnerChecks(t *testing.T) {
+	checks, err := newRunner().Checks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, c := range checks {
+		names = append(names, c.Name)
+	}
+	if got := strings.Join(names, ","); got != "ip,wireless,gateway,dhcp,ping,traceroute,dns,http,ipv6_readiness" {
+		t.Errorf("Unexpected checks %s", got)
+	}
+
+	_, err = newRunner("dns", "throughput").Checks()
+	if err == nil || !strings.Contains(err.Error(), `"throughput"`) {
+		t.Errorf("Expected an error for the unconfigured throughput check, got %v", err)
+	}
+	if _, err := newRunner("dns", "throughput").Start(context.Background()); err == nil {
+		t.Error("Expected Start to fail too")
+	}
+}